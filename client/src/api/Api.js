@@ -8,6 +8,22 @@ import { BASE_URL } from '../../constants';
 import i18n from '../i18n';
 import { LANGUAGES } from '../helpers/twosky';
 
+const CSRF_COOKIE_NAME = 'agh_csrf';
+const CSRF_HEADER_NAME = 'X-CSRF-Token';
+
+/**
+ * @returns {string} CSRF token of the current session or an empty string
+ */
+const getCsrfToken = () => {
+    const prefix = `${CSRF_COOKIE_NAME}=`;
+    const cookie = document.cookie
+        .split(';')
+        .map((c) => c.trim())
+        .find((c) => c.startsWith(prefix));
+
+    return cookie ? decodeURIComponent(cookie.slice(prefix.length)) : '';
+};
+
 class Api {
     baseUrl = BASE_URL;
 
@@ -20,6 +36,14 @@ class Api {
             axiosConfig.headers['Content-Type'] = axiosConfig.headers['Content-Type'] || 'application/json';
         }
 
+        if (method !== 'GET') {
+            const csrfToken = getCsrfToken();
+            if (csrfToken) {
+                axiosConfig.headers = axiosConfig.headers || {};
+                axiosConfig.headers[CSRF_HEADER_NAME] = csrfToken;
+            }
+        }
+
         try {
             const response = await axios({
                 url,
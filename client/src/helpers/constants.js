@@ -286,6 +286,9 @@ export const FILTERED_STATUS = {
     FILTERED_SAFE_SEARCH: 'FilteredSafeSearch',
     FILTERED_SAFE_BROWSING: 'FilteredSafeBrowsing',
     FILTERED_PARENTAL: 'FilteredParental',
+    FILTERED_BYPASS: 'FilteredBypass',
+    FILTERED_GEOIP: 'FilteredGeoIP',
+    FILTERED_ANOMALY: 'FilteredAnomaly',
 };
 
 export const RESPONSE_FILTER = {
@@ -386,6 +389,18 @@ export const FILTERED_STATUS_TO_META_MAP = {
         LABEL: RESPONSE_FILTER.BLOCKED_ADULT_WEBSITES.LABEL,
         COLOR: QUERY_STATUS_COLORS.YELLOW,
     },
+    [FILTERED_STATUS.FILTERED_BYPASS]: {
+        LABEL: RESPONSE_FILTER.BLOCKED.LABEL,
+        COLOR: QUERY_STATUS_COLORS.RED,
+    },
+    [FILTERED_STATUS.FILTERED_GEOIP]: {
+        LABEL: RESPONSE_FILTER.BLOCKED.LABEL,
+        COLOR: QUERY_STATUS_COLORS.RED,
+    },
+    [FILTERED_STATUS.FILTERED_ANOMALY]: {
+        LABEL: 'suspicious',
+        COLOR: QUERY_STATUS_COLORS.YELLOW,
+    },
 };
 
 export const DEFAULT_TIME_FORMAT = 'HH:mm:ss';
@@ -420,6 +435,9 @@ export const SPECIAL_FILTER_ID = {
     PARENTAL: -3,
     SAFE_BROWSING: -4,
     SAFE_SEARCH: -5,
+    BYPASS: -6,
+    GEOIP: -7,
+    ANOMALY: -8,
 };
 
 export const BLOCK_ACTIONS = {
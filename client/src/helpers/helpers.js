@@ -839,6 +839,12 @@ export const getSpecialFilterName = (filterId) => {
             return i18n.t('safe_browsing');
         case SPECIAL_FILTER_ID.SAFE_SEARCH:
             return i18n.t('safe_search');
+        case SPECIAL_FILTER_ID.BYPASS:
+            return i18n.t('dns_bypass_prevention');
+        case SPECIAL_FILTER_ID.GEOIP:
+            return i18n.t('geoip_filtering');
+        case SPECIAL_FILTER_ID.ANOMALY:
+            return i18n.t('anomaly_detection');
         default:
             return i18n.t('unknown_filter', { filterId });
     }
@@ -2,6 +2,7 @@ package aghnet
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"time"
 
@@ -129,3 +130,23 @@ type interfaceName string
 // Use interfaceName in the OS-independent code since it's actually only used in
 // several OS-dependent implementations which causes linting issues.
 var _ = interfaceName("")
+
+// AddrWatcher notifies about the changes of the network interfaces and their
+// addresses.
+type AddrWatcher interface {
+	// Start starts watching the changes.
+	Start() (err error)
+
+	// Close stops watching the changes and closes the events channel.
+	io.Closer
+
+	// Events returns the channel to notify about the changes.  Several changes
+	// made at once may be reported as a single event.
+	Events() (e <-chan struct{})
+}
+
+// NewAddrWatcher returns a new AddrWatcher for the current OS.  It returns an
+// error if the OS isn't supported.
+func NewAddrWatcher() (w AddrWatcher, err error) {
+	return newAddrWatcher()
+}
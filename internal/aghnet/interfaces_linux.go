@@ -3,10 +3,17 @@
 package aghnet
 
 import (
+	"fmt"
 	"net"
+	"slices"
+	"sync/atomic"
 
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
 )
 
 // listenPacketReusable announces on the local network address additionally
@@ -21,3 +28,114 @@ func listenPacketReusable(ifaceName, network, address string) (c net.PacketConn,
 	// TODO(e.burkov):  Inspect nclient4.NewRawUDPConn and implement here.
 	return nclient4.NewRawUDPConn(ifaceName, int(port))
 }
+
+// netlinkWatcher is an [AddrWatcher] receiving the notifications about the
+// changes of the network interfaces and their addresses from the kernel.
+type netlinkWatcher struct {
+	// conn is the netlink connection subscribed to the link and address
+	// notifications.
+	conn *netlink.Conn
+
+	// events is the channel to notify about the changes.
+	events chan struct{}
+
+	// closed is set when the watcher is closed to tell the closing from the
+	// receiving errors.
+	closed atomic.Bool
+}
+
+// netlinkWatcherPref is a prefix for logging and wrapping errors in
+// netlinkWatcher's methods.
+const netlinkWatcherPref = "netlink watcher"
+
+// newAddrWatcher returns a new netlink-based AddrWatcher.
+func newAddrWatcher() (w AddrWatcher, err error) {
+	conn, err := netlink.Dial(unix.NETLINK_ROUTE, &netlink.Config{
+		Groups: unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: dialing: %w", netlinkWatcherPref, err)
+	}
+
+	return &netlinkWatcher{
+		conn:   conn,
+		events: make(chan struct{}, 1),
+	}, nil
+}
+
+// type check
+var _ AddrWatcher = (*netlinkWatcher)(nil)
+
+// Start implements the [AddrWatcher] interface for *netlinkWatcher.
+func (w *netlinkWatcher) Start() (err error) {
+	go w.receive()
+
+	return nil
+}
+
+// Close implements the [AddrWatcher] interface for *netlinkWatcher.
+func (w *netlinkWatcher) Close() (err error) {
+	w.closed.Store(true)
+
+	return w.conn.Close()
+}
+
+// Events implements the [AddrWatcher] interface for *netlinkWatcher.
+func (w *netlinkWatcher) Events() (e <-chan struct{}) {
+	return w.events
+}
+
+// receive receives the netlink notifications until the watcher is closed.  It
+// is intended to be used as a goroutine.
+func (w *netlinkWatcher) receive() {
+	defer log.OnPanic(netlinkWatcherPref)
+
+	defer close(w.events)
+
+	for {
+		msgs, err := w.conn.Receive()
+		if err == nil {
+			if slices.ContainsFunc(msgs, isAddrChange) {
+				w.notify()
+			}
+
+			continue
+		}
+
+		if w.closed.Load() {
+			return
+		} else if errors.Is(err, unix.ENOBUFS) {
+			// Some of the notifications have been dropped by the kernel, so
+			// assume that they contained changes.
+			log.Debug("%s: receiving: %s", netlinkWatcherPref, err)
+			w.notify()
+
+			continue
+		}
+
+		log.Error("%s: receiving: %s", netlinkWatcherPref, err)
+
+		return
+	}
+}
+
+// notify sends an event unless there is already one pending.
+func (w *netlinkWatcher) notify() {
+	select {
+	case w.events <- struct{}{}:
+		// Go on.
+	default:
+		log.Debug("%s: events buffer is full", netlinkWatcherPref)
+	}
+}
+
+// isAddrChange returns true if m notifies about a change of a network interface
+// or its address.
+func isAddrChange(m netlink.Message) (ok bool) {
+	switch m.Header.Type {
+	case unix.RTM_NEWADDR, unix.RTM_DELADDR, unix.RTM_NEWLINK, unix.RTM_DELLINK:
+		return true
+	default:
+		return false
+	}
+}
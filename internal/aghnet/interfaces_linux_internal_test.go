@@ -0,0 +1,53 @@
+//go:build linux
+
+package aghnet
+
+import (
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestIsAddrChange(t *testing.T) {
+	testCases := []struct {
+		name string
+		typ  netlink.HeaderType
+		want bool
+	}{{
+		name: "new_addr",
+		typ:  unix.RTM_NEWADDR,
+		want: true,
+	}, {
+		name: "del_addr",
+		typ:  unix.RTM_DELADDR,
+		want: true,
+	}, {
+		name: "new_link",
+		typ:  unix.RTM_NEWLINK,
+		want: true,
+	}, {
+		name: "del_link",
+		typ:  unix.RTM_DELLINK,
+		want: true,
+	}, {
+		name: "new_route",
+		typ:  unix.RTM_NEWROUTE,
+		want: false,
+	}, {
+		name: "error",
+		typ:  netlink.Error,
+		want: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := netlink.Message{
+				Header: netlink.Header{Type: tc.typ},
+			}
+
+			assert.Equal(t, tc.want, isAddrChange(m))
+		})
+	}
+}
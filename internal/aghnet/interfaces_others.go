@@ -0,0 +1,11 @@
+//go:build !linux
+
+package aghnet
+
+import "github.com/AdguardTeam/AdGuardHome/internal/aghos"
+
+// newAddrWatcher returns an error, since watching the addresses is only
+// supported on Linux.
+func newAddrWatcher() (w AddrWatcher, err error) {
+	return nil, aghos.Unsupported("watching interface addresses")
+}
@@ -0,0 +1,319 @@
+package aghnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/miekg/dns"
+)
+
+// MDNSGroupAddr is the address of the IPv4 multicast group of mDNS.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6762#section-3.
+var MDNSGroupAddr = netip.AddrPortFrom(netip.AddrFrom4([4]byte{224, 0, 0, 251}), 5353)
+
+// mdnsDomain is the domain of the names resolved with mDNS.
+const mdnsDomain = "local."
+
+// mdnsCacheFlushBit is the bit of the class of an mDNS resource record, which
+// tells that the record replaces the cached ones.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6762#section-10.2.
+const mdnsCacheFlushBit uint16 = 1 << 15
+
+// DefaultMDNSTimeout is the default time to wait for the responses to an mDNS
+// query.
+const DefaultMDNSTimeout = 1 * time.Second
+
+// ListenPacketFunc is the semantic alias for functions opening packet
+// connections, such as [net.ListenPacket].
+type ListenPacketFunc = func(network, address string) (c net.PacketConn, err error)
+
+// MDNSConfig is the configuration of an [MDNSBridge].
+type MDNSConfig struct {
+	// ListenPacket opens the connection used to send the queries to and receive
+	// the responses from the multicast group.  If nil, [net.ListenPacket] is
+	// used.
+	ListenPacket ListenPacketFunc
+
+	// Domain is the domain of the unicast names bridged to the names within the
+	// "local." domain, for example "home.lan".  It must be a valid domain name.
+	Domain string
+
+	// Timeout is the time to wait for the responses to a query.  If zero,
+	// [DefaultMDNSTimeout] is used.
+	Timeout time.Duration
+}
+
+// MDNSBridge resolves the unicast DNS requests for the names within a domain
+// using the one-shot multicast DNS queries for the same names within the
+// "local." domain, including the DNS-SD browsing ones.  It's only a querier:
+// it never answers the multicast queries from the other devices, so the names
+// of AdGuard Home itself and of its DHCP clients aren't announced over mDNS.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6762#section-5.1.
+type MDNSBridge struct {
+	listenPacket ListenPacketFunc
+
+	// domain is the lowercased FQDN of the bridged domain.
+	domain string
+
+	timeout time.Duration
+}
+
+// NewMDNSBridge returns a new properly initialized *MDNSBridge.  conf must not
+// be nil.
+func NewMDNSBridge(conf *MDNSConfig) (b *MDNSBridge, err error) {
+	domain := strings.ToLower(strings.TrimSuffix(conf.Domain, "."))
+	err = netutil.ValidateDomainName(domain)
+	if err != nil {
+		return nil, fmt.Errorf("mdns domain: %w", err)
+	}
+
+	b = &MDNSBridge{
+		listenPacket: conf.ListenPacket,
+		domain:       dns.Fqdn(domain),
+		timeout:      conf.Timeout,
+	}
+
+	if b.listenPacket == nil {
+		b.listenPacket = net.ListenPacket
+	}
+
+	if b.timeout == 0 {
+		b.timeout = DefaultMDNSTimeout
+	}
+
+	return b, nil
+}
+
+// Matches returns true if fqdn is within the bridged domain.
+func (b *MDNSBridge) Matches(fqdn string) (ok bool) {
+	_, ok = replaceDomain(fqdn, b.domain, mdnsDomain)
+
+	return ok
+}
+
+// Resolve resolves the request req using mDNS.  req must have a single question
+// for a name within the bridged domain.  If no device responds within the
+// timeout, resp is an NXDOMAIN response.
+func (b *MDNSBridge) Resolve(ctx context.Context, req *dns.Msg) (resp *dns.Msg, err error) {
+	defer func() { err = errors.Annotate(err, "mdns: %w") }()
+
+	q := req.Question[0]
+	localName, ok := replaceDomain(q.Name, b.domain, mdnsDomain)
+	if !ok {
+		return nil, fmt.Errorf("name %q is not within %q", q.Name, b.domain)
+	}
+
+	query := &dns.Msg{
+		MsgHdr: dns.MsgHdr{
+			Id: dns.Id(),
+		},
+		Question: []dns.Question{{
+			Name:   localName,
+			Qtype:  q.Qtype,
+			Qclass: dns.ClassINET,
+		}},
+	}
+
+	resps, err := b.exchange(ctx, query)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	return b.newResponse(req, query.Question[0], resps), nil
+}
+
+// exchange sends query to the multicast group and returns the responses to it
+// received within the timeout.  The responses without the answers to the
+// question of query, such as the unsolicited announcements of other devices,
+// are skipped.
+func (b *MDNSBridge) exchange(ctx context.Context, query *dns.Msg) (resps []*dns.Msg, err error) {
+	data, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing query: %w", err)
+	}
+
+	// Use a random port to send a one-shot query, so that the responders reply
+	// directly to it.
+	conn, err := b.listenPacket("udp4", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("listening: %w", err)
+	}
+	defer func() { err = errors.WithDeferred(err, conn.Close()) }()
+
+	deadline := time.Now().Add(b.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, fmt.Errorf("setting deadline: %w", err)
+	}
+
+	_, err = conn.WriteTo(data, net.UDPAddrFromAddrPort(MDNSGroupAddr))
+	if err != nil {
+		return nil, fmt.Errorf("sending query: %w", err)
+	}
+
+	q := query.Question[0]
+	isBrowsing := q.Qtype == dns.TypePTR
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		var n int
+		n, _, err = conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return resps, nil
+		} else if err != nil {
+			return resps, fmt.Errorf("receiving response: %w", err)
+		}
+
+		resp := &dns.Msg{}
+		err = resp.Unpack(buf[:n])
+		if err != nil || !resp.Response || resp.Opcode != dns.OpcodeQuery {
+			log.Debug("mdns: skipping bad response: %v", err)
+
+			continue
+		} else if !hasAnswer(resp, q) {
+			log.Debug("mdns: skipping response not answering %s %q", dns.Type(q.Qtype), q.Name)
+
+			continue
+		}
+
+		resps = append(resps, resp)
+
+		// The hostnames are unique within the link, but there may be many
+		// instances of a service, so wait for all of them when browsing.
+		if !isBrowsing {
+			return resps, nil
+		}
+	}
+}
+
+// hasAnswer returns true if resp contains a record answering q or an NSEC
+// record telling that the name of q has no records of its type.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6762#section-6.1.
+func hasAnswer(resp *dns.Msg, q dns.Question) (ok bool) {
+	for _, rr := range resp.Answer {
+		hdr := rr.Header()
+		if isAnswer(rr, q) || hdr.Rrtype == dns.TypeNSEC && strings.EqualFold(hdr.Name, q.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isAnswer returns true if rr has the name and the type of q.
+func isAnswer(rr dns.RR, q dns.Question) (ok bool) {
+	hdr := rr.Header()
+
+	return strings.EqualFold(hdr.Name, q.Name) && (q.Qtype == dns.TypeANY || hdr.Rrtype == q.Qtype)
+}
+
+// newResponse returns the response to req made from the records for the names
+// within the "local." domain in resps, which must all contain the answers to
+// localQ.  localQ is the question within the "local." domain.  The records in
+// the answer sections of resps, which don't answer localQ, are moved to the
+// additional section.
+func (b *MDNSBridge) newResponse(
+	req *dns.Msg,
+	localQ dns.Question,
+	resps []*dns.Msg,
+) (resp *dns.Msg) {
+	resp = (&dns.Msg{}).SetReply(req)
+	resp.Authoritative = true
+	resp.RecursionAvailable = true
+
+	if len(resps) == 0 {
+		resp.Rcode = dns.RcodeNameError
+
+		return resp
+	}
+
+	for _, r := range resps {
+		for _, rr := range r.Answer {
+			if isAnswer(rr, localQ) {
+				resp.Answer = b.appendRR(resp.Answer, rr)
+			} else {
+				resp.Extra = b.appendRR(resp.Extra, rr)
+			}
+		}
+
+		for _, rr := range r.Extra {
+			resp.Extra = b.appendRR(resp.Extra, rr)
+		}
+	}
+
+	return resp
+}
+
+// appendRR appends the copy of rr with the names within the "local." domain
+// replaced to rrs, unless it's an mDNS-specific record or a duplicate.  The
+// NSEC records are also skipped, since they are only used to tell that the
+// name exists with other types.
+func (b *MDNSBridge) appendRR(rrs []dns.RR, rr dns.RR) (res []dns.RR) {
+	if rr.Header().Rrtype == dns.TypeOPT || rr.Header().Rrtype == dns.TypeNSEC {
+		return rrs
+	}
+
+	rr = dns.Copy(rr)
+	hdr := rr.Header()
+	hdr.Class &^= mdnsCacheFlushBit
+
+	var ok bool
+	hdr.Name, ok = replaceDomain(hdr.Name, mdnsDomain, b.domain)
+	if !ok {
+		return rrs
+	}
+
+	switch rr := rr.(type) {
+	case *dns.PTR:
+		rr.Ptr, _ = replaceDomain(rr.Ptr, mdnsDomain, b.domain)
+	case *dns.SRV:
+		rr.Target, _ = replaceDomain(rr.Target, mdnsDomain, b.domain)
+	case *dns.CNAME:
+		rr.Target, _ = replaceDomain(rr.Target, mdnsDomain, b.domain)
+	default:
+		// Go on.
+	}
+
+	for _, r := range rrs {
+		if dns.IsDuplicate(r, rr) {
+			return rrs
+		}
+	}
+
+	return append(rrs, rr)
+}
+
+// replaceDomain replaces the domain from in fqdn with the domain to.  If fqdn
+// isn't within from, it returns fqdn and false.  from and to must be lowercased
+// FQDNs.
+func replaceDomain(fqdn, from, to string) (res string, ok bool) {
+	lower := strings.ToLower(fqdn)
+	if lower == from {
+		return to, true
+	}
+
+	sub, ok := strings.CutSuffix(lower, "."+from)
+	if !ok {
+		return fqdn, false
+	}
+
+	// Preserve the case of the subdomain labels, since the service instance
+	// names are displayed to users.
+	return fqdn[:len(sub)] + "." + to, true
+}
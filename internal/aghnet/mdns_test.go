@@ -0,0 +1,313 @@
+package aghnet_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mdnsResponder is a device responding to mDNS queries.  It returns nil if it
+// doesn't respond to the query.
+type mdnsResponder func(query *dns.Msg) (resp *dns.Msg)
+
+// memMulticast is an in-memory multicast network with mDNS responders.
+type memMulticast struct {
+	responders []mdnsResponder
+}
+
+// listenPacket implements the [aghnet.ListenPacketFunc] for *memMulticast.
+func (m *memMulticast) listenPacket(_, _ string) (c net.PacketConn, err error) {
+	return &memConn{
+		network: m,
+		resps:   make(chan []byte, len(m.responders)),
+	}, nil
+}
+
+// memConn is the in-memory connection to a *memMulticast.
+type memConn struct {
+	network  *memMulticast
+	resps    chan []byte
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+// type check
+var _ net.PacketConn = (*memConn)(nil)
+
+// ReadFrom implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	c.mu.Lock()
+	timer := time.NewTimer(time.Until(c.deadline))
+	c.mu.Unlock()
+	defer timer.Stop()
+
+	select {
+	case data := <-c.resps:
+		return copy(b, data), &net.UDPAddr{}, nil
+	case <-timer.C:
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+}
+
+// WriteTo implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if addr.String() != aghnet.MDNSGroupAddr.String() {
+		return 0, errors.Error("not a multicast group")
+	}
+
+	query := &dns.Msg{}
+	err = query.Unpack(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, r := range c.network.responders {
+		resp := r(query)
+		if resp == nil {
+			continue
+		}
+
+		var data []byte
+		data, err = resp.Pack()
+		if err != nil {
+			return 0, err
+		}
+
+		c.resps <- data
+	}
+
+	return len(b), nil
+}
+
+// Close implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) Close() (err error) { return nil }
+
+// LocalAddr implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) LocalAddr() (addr net.Addr) { return &net.UDPAddr{} }
+
+// SetDeadline implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) SetDeadline(t time.Time) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deadline = t
+
+	return nil
+}
+
+// SetReadDeadline implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) SetReadDeadline(t time.Time) (err error) { return c.SetDeadline(t) }
+
+// SetWriteDeadline implements the [net.PacketConn] interface for *memConn.
+func (c *memConn) SetWriteDeadline(_ time.Time) (err error) { return nil }
+
+// newMDNSResponder returns a responder answering the queries for name and qtype
+// with ans and extra records.
+func newMDNSResponder(name string, qtype uint16, ans, extra []dns.RR) (r mdnsResponder) {
+	return func(query *dns.Msg) (resp *dns.Msg) {
+		q := query.Question[0]
+		if !strings.EqualFold(q.Name, name) || q.Qtype != qtype {
+			return nil
+		}
+
+		resp = (&dns.Msg{}).SetReply(query)
+		resp.Authoritative = true
+		resp.Answer = ans
+		resp.Extra = extra
+
+		return resp
+	}
+}
+
+func TestMDNSBridge_Resolve(t *testing.T) {
+	const (
+		cacheFlushIN = dns.ClassINET | 1<<15
+		ttl          = 120
+	)
+
+	hostIP := netip.MustParseAddr("192.168.1.10")
+
+	hostA := &dns.A{
+		Hdr: dns.RR_Header{
+			Name:   "printer.local.",
+			Rrtype: dns.TypeA,
+			Class:  cacheFlushIN,
+			Ttl:    ttl,
+		},
+		A: hostIP.AsSlice(),
+	}
+
+	newPTR := func(name, ptr string) (rr *dns.PTR) {
+		return &dns.PTR{
+			Hdr: dns.RR_Header{
+				Name:   name,
+				Rrtype: dns.TypePTR,
+				Class:  dns.ClassINET,
+				Ttl:    ttl,
+			},
+			Ptr: ptr,
+		}
+	}
+
+	instSRV := &dns.SRV{
+		Hdr: dns.RR_Header{
+			Name:   "Office-Printer._ipp._tcp.local.",
+			Rrtype: dns.TypeSRV,
+			Class:  cacheFlushIN,
+			Ttl:    ttl,
+		},
+		Port:   631,
+		Target: "printer.local.",
+	}
+
+	const svcsName = "_services._dns-sd._udp.local."
+
+	// announcer is a device announcing its own records in response to any
+	// query, which must not be taken for the answers.
+	announcer := func(query *dns.Msg) (resp *dns.Msg) {
+		resp = (&dns.Msg{}).SetReply(query)
+		resp.Id = 0
+		resp.Question = nil
+		resp.Authoritative = true
+		resp.Answer = []dns.RR{&dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:   "tv.local.",
+				Rrtype: dns.TypeAAAA,
+				Class:  cacheFlushIN,
+				Ttl:    ttl,
+			},
+			AAAA: net.ParseIP("fe80::1"),
+		}}
+
+		return resp
+	}
+
+	network := &memMulticast{
+		responders: []mdnsResponder{
+			announcer,
+			newMDNSResponder("printer.local.", dns.TypeA, []dns.RR{hostA}, nil),
+			newMDNSResponder(svcsName, dns.TypePTR, []dns.RR{
+				newPTR(svcsName, "_ipp._tcp.local."),
+			}, nil),
+			newMDNSResponder(svcsName, dns.TypePTR, []dns.RR{
+				newPTR(svcsName, "_http._tcp.local."),
+				newPTR(svcsName, "_ipp._tcp.local."),
+			}, nil),
+			newMDNSResponder("_ipp._tcp.local.", dns.TypePTR, []dns.RR{
+				newPTR("_ipp._tcp.local.", "Office-Printer._ipp._tcp.local."),
+			}, []dns.RR{instSRV, hostA}),
+		},
+	}
+
+	b, err := aghnet.NewMDNSBridge(&aghnet.MDNSConfig{
+		ListenPacket: network.listenPacket,
+		Domain:       "home.lan",
+		Timeout:      100 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		qname     string
+		wantAns   []string
+		wantExtra []string
+		qtype     uint16
+		wantRcode int
+	}{{
+		name:  "host",
+		qname: "Printer.home.lan.",
+		wantAns: []string{
+			"printer.home.lan.\t120\tIN\tA\t192.168.1.10",
+		},
+		wantExtra: nil,
+		qtype:     dns.TypeA,
+		wantRcode: dns.RcodeSuccess,
+	}, {
+		name:      "unknown_host",
+		qname:     "scanner.home.lan.",
+		wantAns:   nil,
+		wantExtra: nil,
+		qtype:     dns.TypeA,
+		wantRcode: dns.RcodeNameError,
+	}, {
+		name:  "services",
+		qname: "_services._dns-sd._udp.home.lan.",
+		wantAns: []string{
+			"_services._dns-sd._udp.home.lan.\t120\tIN\tPTR\t_ipp._tcp.home.lan.",
+			"_services._dns-sd._udp.home.lan.\t120\tIN\tPTR\t_http._tcp.home.lan.",
+		},
+		wantExtra: nil,
+		qtype:     dns.TypePTR,
+		wantRcode: dns.RcodeSuccess,
+	}, {
+		name:  "instances",
+		qname: "_ipp._tcp.home.lan.",
+		wantAns: []string{
+			`_ipp._tcp.home.lan.	120	IN	PTR	Office-Printer._ipp._tcp.home.lan.`,
+		},
+		wantExtra: []string{
+			`Office-Printer._ipp._tcp.home.lan.	120	IN	SRV	0 0 631 printer.home.lan.`,
+			"printer.home.lan.\t120\tIN\tA\t192.168.1.10",
+		},
+		qtype:     dns.TypePTR,
+		wantRcode: dns.RcodeSuccess,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := (&dns.Msg{}).SetQuestion(tc.qname, tc.qtype)
+
+			resp, resErr := b.Resolve(context.Background(), req)
+			require.NoError(t, resErr)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantRcode, resp.Rcode)
+			assert.Equal(t, req.Id, resp.Id)
+
+			assert.Equal(t, tc.wantAns, rrStrings(resp.Answer))
+			assert.Equal(t, tc.wantExtra, rrStrings(resp.Extra))
+		})
+	}
+}
+
+// rrStrings returns the string representations of rrs.
+func rrStrings(rrs []dns.RR) (strs []string) {
+	for _, rr := range rrs {
+		strs = append(strs, rr.String())
+	}
+
+	return strs
+}
+
+func TestMDNSBridge_Matches(t *testing.T) {
+	b, err := aghnet.NewMDNSBridge(&aghnet.MDNSConfig{
+		Domain: "Home.Lan.",
+	})
+	require.NoError(t, err)
+
+	assert.True(t, b.Matches("home.lan."))
+	assert.True(t, b.Matches("printer.HOME.lan."))
+	assert.True(t, b.Matches("_services._dns-sd._udp.home.lan."))
+
+	assert.False(t, b.Matches("lan."))
+	assert.False(t, b.Matches("myhome.lan."))
+	assert.False(t, b.Matches("printer.local."))
+}
+
+func TestNewMDNSBridge_error(t *testing.T) {
+	_, err := aghnet.NewMDNSBridge(&aghnet.MDNSConfig{
+		Domain: "",
+	})
+	assert.Error(t, err)
+}
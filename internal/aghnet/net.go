@@ -213,6 +213,31 @@ func InterfaceByIP(ip netip.Addr) (ifaceName string) {
 	return ""
 }
 
+// InterfacesAddrs returns the addresses of the network interfaces with the
+// given names, excluding the link-local IPv4 ones.  The interfaces which don't
+// exist are skipped, since they may appear later, like the PPPoE ones.
+func InterfacesAddrs(names []string) (addrs []netip.Addr, err error) {
+	for _, name := range names {
+		iface, ifaceErr := net.InterfaceByName(name)
+		if ifaceErr != nil {
+			log.Debug("aghnet: getting interface %q: %s", name, ifaceErr)
+
+			continue
+		}
+
+		var niface *NetInterface
+		niface, err = NetInterfaceFrom(iface)
+		if err != nil {
+			// Don't wrap the error, because it's informative enough as is.
+			return nil, err
+		}
+
+		addrs = append(addrs, niface.Addresses...)
+	}
+
+	return addrs, nil
+}
+
 // GetSubnet returns the subnet corresponding to the interface of zero prefix if
 // the search fails.
 //
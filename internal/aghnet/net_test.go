@@ -10,6 +10,7 @@ import (
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(m *testing.M) {
@@ -88,3 +89,31 @@ ParseAddr(""): unable to parse IP`,
 		})
 	}
 }
+
+func TestInterfacesAddrs(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+
+	var loName string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loName = iface.Name
+
+			break
+		}
+	}
+
+	if loName == "" {
+		t.Skip("no loopback interface")
+	}
+
+	addrs, err := aghnet.InterfacesAddrs([]string{loName, "nonexistent0"})
+	require.NoError(t, err)
+
+	assert.Contains(t, addrs, netutil.IPv4Localhost())
+
+	addrs, err = aghnet.InterfacesAddrs([]string{"nonexistent0"})
+	require.NoError(t, err)
+
+	assert.Empty(t, addrs)
+}
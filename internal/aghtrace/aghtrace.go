@@ -0,0 +1,67 @@
+// Package aghtrace contains the OpenTelemetry tracing of AdGuard Home.  It
+// wraps the OpenTelemetry SDK, samples the traces by their IDs, propagates the
+// trace context using the W3C Trace Context headers, and exports the spans in
+// batches using the OTLP/HTTP protocol.
+//
+// All methods of a nil *Tracer and a nil *Span are no-ops, so the callers
+// don't need to check whether the tracing is enabled.
+package aghtrace
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attr is a key-value attribute of a span.
+type Attr = attribute.KeyValue
+
+// String returns a string attribute.
+func String(key, val string) (a Attr) {
+	return attribute.String(key, val)
+}
+
+// Int returns an integer attribute.
+func Int(key string, val int) (a Attr) {
+	return attribute.Int(key, val)
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, val bool) (a Attr) {
+	return attribute.Bool(key, val)
+}
+
+// Span is a single recorded operation within a trace.
+type Span struct {
+	// span is the underlying OpenTelemetry span.  It is always recording.
+	span trace.Span
+}
+
+// SetAttrs adds the attributes to s.
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+
+	s.span.SetAttributes(attrs...)
+}
+
+// RecordError marks s as failed with err, if it's not nil.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End finishes s and queues it for export.  Calling End more than once has no
+// effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.span.End()
+}
@@ -0,0 +1,228 @@
+package aghtrace_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
+
+// newTestTracer returns a new tracer exporting into memory with the given
+// sample ratio.
+func newTestTracer(
+	t *testing.T,
+	ratio float64,
+) (tr *aghtrace.Tracer, exp *tracetest.InMemoryExporter) {
+	t.Helper()
+
+	exp = tracetest.NewInMemoryExporter()
+	tr = aghtrace.New(&aghtrace.Config{
+		Exporter:    exp,
+		ServiceName: "adguardhome",
+		SampleRatio: ratio,
+	})
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+
+		require.NoError(t, tr.Shutdown(ctx))
+	})
+
+	return tr, exp
+}
+
+func TestTracer_Start(t *testing.T) {
+	tr, exp := newTestTracer(t, 1)
+
+	ctx, root := tr.StartServer(context.Background(), "root", aghtrace.String("key", "val"))
+	require.NotNil(t, root)
+
+	_, child := tr.Start(ctx, "child")
+	require.NotNil(t, child)
+
+	child.SetAttrs(aghtrace.Int("num", 42))
+	child.RecordError(errors.New("test error"))
+	child.End()
+
+	end := time.Now()
+	start := end.Add(-time.Second)
+	tr.RecordClient(ctx, "exchange", start, end, nil, aghtrace.Bool("cached", false))
+
+	root.End()
+
+	// Ending a span twice must not export it twice.
+	root.End()
+
+	err := tr.Flush(context.Background())
+	require.NoError(t, err)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 3)
+
+	gotChild, gotExchange, gotRoot := spans[0], spans[1], spans[2]
+	assert.Equal(t, "root", gotRoot.Name)
+	assert.Equal(t, trace.SpanKindServer, gotRoot.SpanKind)
+	assert.False(t, gotRoot.Parent.IsValid())
+	assert.Equal(t, []aghtrace.Attr{aghtrace.String("key", "val")}, gotRoot.Attributes)
+	assert.Equal(t, codes.Unset, gotRoot.Status.Code)
+
+	rootSC := gotRoot.SpanContext
+	assert.Equal(t, "child", gotChild.Name)
+	assert.Equal(t, trace.SpanKindInternal, gotChild.SpanKind)
+	assert.Equal(t, rootSC.TraceID(), gotChild.SpanContext.TraceID())
+	assert.Equal(t, rootSC.SpanID(), gotChild.Parent.SpanID())
+	assert.Equal(t, []aghtrace.Attr{aghtrace.Int("num", 42)}, gotChild.Attributes)
+	assert.Equal(t, codes.Error, gotChild.Status.Code)
+	assert.Equal(t, "test error", gotChild.Status.Description)
+
+	assert.Equal(t, "exchange", gotExchange.Name)
+	assert.Equal(t, trace.SpanKindClient, gotExchange.SpanKind)
+	assert.Equal(t, rootSC.SpanID(), gotExchange.Parent.SpanID())
+	assert.True(t, start.Equal(gotExchange.StartTime))
+	assert.True(t, end.Equal(gotExchange.EndTime))
+}
+
+func TestTracer_Start_sampling(t *testing.T) {
+	tr, exp := newTestTracer(t, 0)
+
+	ctx, root := tr.Start(context.Background(), "root")
+	assert.Nil(t, root)
+
+	// The children of unsampled spans mustn't be sampled either.
+	_, child := tr.Start(ctx, "child")
+	assert.Nil(t, child)
+
+	// Methods of nil spans must not panic.
+	child.SetAttrs(aghtrace.Bool("key", true))
+	child.RecordError(errors.New("test error"))
+	child.End()
+
+	tr.RecordClient(ctx, "exchange", time.Now(), time.Now(), nil)
+
+	require.NoError(t, tr.Flush(context.Background()))
+	assert.Empty(t, exp.GetSpans())
+
+	var nilTracer *aghtrace.Tracer
+	_, s := nilTracer.Start(context.Background(), "span")
+	assert.Nil(t, s)
+
+	assert.NoError(t, nilTracer.Shutdown(context.Background()))
+}
+
+func TestTracer_Extract(t *testing.T) {
+	const (
+		traceIDStr = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanIDStr  = "00f067aa0ba902b7"
+	)
+
+	// Don't sample the local traces to make sure that the sampling decision
+	// of the remote parent is respected.
+	tr, exp := newTestTracer(t, 0)
+
+	h := http.Header{}
+	h.Set("Traceparent", "00-"+traceIDStr+"-"+spanIDStr+"-01")
+
+	ctx := tr.Extract(context.Background(), h)
+	_, s := tr.StartServer(ctx, "http GET /control/status")
+	require.NotNil(t, s)
+
+	s.End()
+
+	require.NoError(t, tr.Flush(context.Background()))
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+
+	got := spans[0]
+	assert.Equal(t, traceIDStr, got.SpanContext.TraceID().String())
+	assert.Equal(t, spanIDStr, got.Parent.SpanID().String())
+	assert.True(t, got.Parent.IsRemote())
+}
+
+func TestNewOTLPExporter(t *testing.T) {
+	bodyCh := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		bodyCh <- b
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	exp, err := aghtrace.NewOTLPExporter(ctx, srv.URL+"/v1/traces")
+	require.NoError(t, err)
+
+	tr := aghtrace.New(&aghtrace.Config{
+		Exporter:    exp,
+		ServiceName: "adguardhome",
+		SampleRatio: 1,
+	})
+
+	_, s := tr.Start(ctx, "dns.request", aghtrace.Int("dns.qtype", 1))
+	s.RecordError(errors.New("test error"))
+	s.End()
+
+	// Shutting down must export the remaining spans.
+	err = tr.Shutdown(ctx)
+	require.NoError(t, err)
+
+	req := &coltracepb.ExportTraceServiceRequest{}
+	err = proto.Unmarshal(<-bodyCh, req)
+	require.NoError(t, err)
+
+	require.Len(t, req.ResourceSpans, 1)
+	rs := req.ResourceSpans[0]
+
+	var svcName string
+	for _, a := range rs.Resource.Attributes {
+		if a.Key == "service.name" {
+			svcName = a.Value.GetStringValue()
+		}
+	}
+
+	assert.Equal(t, "adguardhome", svcName)
+
+	require.Len(t, rs.ScopeSpans, 1)
+	require.Len(t, rs.ScopeSpans[0].Spans, 1)
+
+	span := rs.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "dns.request", span.Name)
+	assert.Len(t, span.TraceId, 16)
+	assert.Equal(t, "test error", span.Status.Message)
+
+	require.Len(t, span.Attributes, 1)
+	assert.Equal(t, int64(1), span.Attributes[0].Value.GetIntValue())
+}
+
+func TestTracer_Shutdown(t *testing.T) {
+	tr, _ := newTestTracer(t, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// Shutting down a tracer that has never exported anything mustn't block.
+	err := tr.Shutdown(ctx)
+	require.NoError(t, err)
+}
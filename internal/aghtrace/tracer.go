@@ -0,0 +1,186 @@
+package aghtrace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scopeName is the name of the instrumentation scope of the spans.
+const scopeName = "github.com/AdguardTeam/AdGuardHome"
+
+// Config is the configuration structure for the tracer.
+type Config struct {
+	// Exporter is used to export the finished spans in batches.  It must not
+	// be nil.
+	Exporter sdktrace.SpanExporter
+
+	// ServiceName is the value of the service.name resource attribute.
+	ServiceName string
+
+	// ServiceVersion is the value of the service.version resource attribute.
+	ServiceVersion string
+
+	// SampleRatio is the share of the traces to sample, from 0 to 1.  The
+	// traces continued from the remote parents follow the sampling decision
+	// of the parent.
+	SampleRatio float64
+}
+
+// Tracer starts spans and exports them in batches.  A nil *Tracer is a valid
+// tracer that doesn't record anything.
+type Tracer struct {
+	// provider manages the export of the spans.
+	provider *sdktrace.TracerProvider
+
+	// tracer starts the spans.
+	tracer trace.Tracer
+
+	// propagator extracts the trace context from the HTTP headers.
+	propagator propagation.TextMapPropagator
+}
+
+// New returns a new properly initialized tracer.  c must not be nil.
+func New(c *Config) (t *Tracer) {
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+		semconv.ServiceVersion(c.ServiceVersion),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(c.Exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	)
+
+	return &Tracer{
+		provider:   provider,
+		tracer:     provider.Tracer(scopeName),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+// NewOTLPExporter returns a new exporter sending the spans to the OTLP/HTTP
+// traces endpoint, for example "http://localhost:4318/v1/traces".
+func NewOTLPExporter(ctx context.Context, endpoint string) (e sdktrace.SpanExporter, err error) {
+	e, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp exporter: %w", err)
+	}
+
+	return e, nil
+}
+
+// Start begins a new internal span with the given name and attributes.  If
+// ctx contains a span, the new span becomes its child; otherwise, a new trace
+// is started and sampled according to the sample ratio.  s is nil if the trace
+// isn't sampled.  The span must be finished with [Span.End].
+func (t *Tracer) Start(
+	ctx context.Context,
+	name string,
+	attrs ...Attr,
+) (spanCtx context.Context, s *Span) {
+	return t.start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer is like [Tracer.Start] but begins a span handling a request of a
+// remote client.
+func (t *Tracer) StartServer(
+	ctx context.Context,
+	name string,
+	attrs ...Attr,
+) (spanCtx context.Context, s *Span) {
+	return t.start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// RecordClient records a finished span of a request to a remote server, which
+// has been sent at start and completed at end, as a child of the span from
+// ctx.  err is the error of the request, if any.
+func (t *Tracer) RecordClient(
+	ctx context.Context,
+	name string,
+	start time.Time,
+	end time.Time,
+	err error,
+	attrs ...Attr,
+) {
+	_, s := t.start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+	)
+	if s == nil {
+		return
+	}
+
+	s.RecordError(err)
+	s.span.End(trace.WithTimestamp(end))
+}
+
+// start begins a new span with the given options.  The context returned for
+// the traces that aren't sampled still contains the span, so that its
+// children aren't sampled either.
+func (t *Tracer) start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (spanCtx context.Context, s *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	spanCtx, span := t.tracer.Start(ctx, name, opts...)
+	if !span.IsRecording() {
+		return spanCtx, nil
+	}
+
+	return spanCtx, &Span{
+		span: span,
+	}
+}
+
+// Extract returns a copy of ctx containing the remote span context from the
+// W3C Trace Context headers in h, if there are any.  The spans started with
+// the returned context continue the remote trace.
+func (t *Tracer) Extract(ctx context.Context, h http.Header) (extracted context.Context) {
+	if t == nil {
+		return ctx
+	}
+
+	return t.propagator.Extract(ctx, propagation.HeaderCarrier(h))
+}
+
+// Flush exports all the finished spans synchronously.
+func (t *Tracer) Flush(ctx context.Context) (err error) {
+	if t == nil {
+		return nil
+	}
+
+	return t.provider.ForceFlush(ctx)
+}
+
+// Shutdown exports the remaining spans and stops the tracer.  The spans
+// finished after that aren't exported.
+func (t *Tracer) Shutdown(ctx context.Context) (err error) {
+	if t == nil {
+		return nil
+	}
+
+	return t.provider.Shutdown(ctx)
+}
@@ -0,0 +1,332 @@
+// Package anomaly implements the heuristic detection of the DNS tunneling and
+// the algorithmically generated domains (DGA) in the DNS requests.
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Action is the action performed on the suspicious requests.
+type Action string
+
+// Action values.
+const (
+	// ActionBlock means that the suspicious requests are blocked.
+	ActionBlock Action = "block"
+
+	// ActionFlag means that the suspicious requests are only marked in the
+	// query log and processed as usual.
+	ActionFlag Action = "flag"
+)
+
+// Heuristic is the name of a single check performed on the requests.
+type Heuristic string
+
+// Heuristic values.
+const (
+	// HeuristicLabelLength means that the request has a too long label.
+	HeuristicLabelLength Heuristic = "label_length"
+
+	// HeuristicEntropy means that the subdomain part or the registrable label
+	// of the request has a too high Shannon entropy.
+	HeuristicEntropy Heuristic = "entropy"
+
+	// HeuristicNGram means that the subdomain part or the registrable label of
+	// the request has too many letter bigrams unusual for the natural
+	// languages.
+	HeuristicNGram Heuristic = "ngram"
+
+	// HeuristicTXTRate means that there are too many TXT and NULL requests for
+	// the registrable domain.
+	HeuristicTXTRate Heuristic = "txt_rate"
+
+	// HeuristicUniqueSubdomains means that there are too many unique
+	// subdomains requested for the registrable domain.
+	HeuristicUniqueSubdomains Heuristic = "unique_subdomains"
+)
+
+// Default values of the configuration.
+const (
+	DefaultLabelLength      uint    = 40
+	DefaultEntropy          float64 = 3.8
+	DefaultNGram            float64 = 0.6
+	DefaultTXTRate          uint    = 30
+	DefaultUniqueSubdomains uint    = 100
+	DefaultMinScore         uint    = 2
+)
+
+// Config is the configuration of the anomaly detection.
+type Config struct {
+	// Action is the action performed on the suspicious requests.
+	Action Action `yaml:"action"`
+
+	// LabelLength is the length of a label, exceeding which is considered
+	// suspicious.
+	LabelLength uint `yaml:"label_length"`
+
+	// Entropy is the Shannon entropy in bits per character of the subdomain
+	// part or of the registrable label of the hostname, exceeding which is
+	// considered suspicious.  Registrable labels shorter than 12 characters
+	// aren't scored.
+	Entropy float64 `yaml:"entropy"`
+
+	// NGram is the share of the letter bigrams unusual for the natural
+	// languages in the subdomain part or in the registrable label of the
+	// hostname, exceeding which is considered suspicious.  Registrable labels
+	// shorter than 12 characters aren't scored.  It must be within (0, 1].
+	NGram float64 `yaml:"ngram"`
+
+	// TXTRate is the number of TXT and NULL requests per minute for a single
+	// registrable domain, exceeding which is considered suspicious.
+	TXTRate uint `yaml:"txt_rate"`
+
+	// UniqueSubdomains is the number of unique subdomains requested per minute
+	// for a single registrable domain, exceeding which is considered
+	// suspicious.
+	UniqueSubdomains uint `yaml:"unique_subdomains"`
+
+	// MinScore is the number of the heuristics, which must be triggered for
+	// the request to be suspicious.
+	MinScore uint `yaml:"min_score"`
+
+	// Enabled defines if the requests are checked.
+	Enabled bool `yaml:"enabled"`
+}
+
+// Validate returns an error if c is not valid.
+func (c *Config) Validate() (err error) {
+	var errs []error
+	switch c.Action {
+	case ActionBlock, ActionFlag:
+		// Go on.
+	default:
+		errs = append(errs, fmt.Errorf("action: bad value %q", c.Action))
+	}
+
+	if c.LabelLength == 0 {
+		errs = append(errs, errors.Error("label_length: must be positive"))
+	}
+
+	if c.Entropy <= 0 {
+		errs = append(errs, errors.Error("entropy: must be positive"))
+	}
+
+	if c.NGram <= 0 || c.NGram > 1 {
+		errs = append(errs, fmt.Errorf("ngram: out of range: %v", c.NGram))
+	}
+
+	if c.TXTRate == 0 {
+		errs = append(errs, errors.Error("txt_rate: must be positive"))
+	}
+
+	if c.UniqueSubdomains == 0 {
+		errs = append(errs, errors.Error("unique_subdomains: must be positive"))
+	}
+
+	if c.MinScore == 0 {
+		errs = append(errs, errors.Error("min_score: must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Result is the result of checking a request.
+type Result struct {
+	// Triggered are the heuristics triggered by the request.
+	Triggered []Heuristic
+
+	// Suspicious is true if the number of the triggered heuristics reaches the
+	// configured minimum score.
+	Suspicious bool
+}
+
+// Rule returns the text describing the triggered heuristics, for example
+// "anomaly:entropy,ngram".
+func (r *Result) Rule() (rule string) {
+	b := &strings.Builder{}
+	b.WriteString("anomaly:")
+	for i, h := range r.Triggered {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(string(h))
+	}
+
+	return b.String()
+}
+
+// rateWindow is the duration of the window within which the requests per
+// registrable domain are counted.
+const rateWindow = 1 * time.Minute
+
+// maxTrackedDomains is the maximum number of the registrable domains tracked
+// within a single window.
+const maxTrackedDomains = 10_000
+
+// domainStats are the statistics of the requests for a registrable domain
+// within the current window.
+type domainStats struct {
+	// subdomains are the unique subdomains requested.  The set stops growing
+	// after exceeding the threshold to keep the memory usage bounded.
+	subdomains map[string]struct{}
+
+	// txtNum is the number of TXT and NULL requests.
+	txtNum uint
+}
+
+// Detector checks the DNS requests for the signs of the DNS tunneling and the
+// algorithmically generated domains.
+type Detector struct {
+	// mu protects domains and windowStart.
+	mu          *sync.Mutex
+	domains     map[string]*domainStats
+	windowStart time.Time
+
+	// now returns the current time.  It's replaced in tests.
+	now func() (t time.Time)
+
+	conf *Config
+}
+
+// New returns a new properly initialized *Detector.  conf must not be nil and
+// must be valid.
+func New(conf *Config) (d *Detector) {
+	return &Detector{
+		mu:      &sync.Mutex{},
+		domains: map[string]*domainStats{},
+		now:     time.Now,
+		conf:    conf,
+	}
+}
+
+// Action returns the action to perform on the suspicious requests.
+func (d *Detector) Action() (a Action) {
+	return d.conf.Action
+}
+
+// Check checks the request for host, which must be lowercased and without the
+// trailing dot, with the type qtype.  The requests for the public suffixes and
+// the reverse lookups are never suspicious.  It's safe for concurrent use.
+func (d *Detector) Check(host string, qtype uint16) (res *Result) {
+	res = &Result{}
+	if qtype == dns.TypePTR || strings.HasSuffix(host, ".arpa") {
+		return res
+	}
+
+	regDomain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// The host is a public suffix itself, so there is nothing to check.
+		return res
+	}
+
+	suffix, _ := publicsuffix.PublicSuffix(host)
+	labels := strings.Split(strings.TrimSuffix(host, "."+suffix), ".")
+
+	if d.checkLabelLength(labels) {
+		res.Triggered = append(res.Triggered, HeuristicLabelLength)
+	}
+
+	e, share := score(labels)
+	if e > d.conf.Entropy {
+		res.Triggered = append(res.Triggered, HeuristicEntropy)
+	}
+
+	if share > d.conf.NGram {
+		res.Triggered = append(res.Triggered, HeuristicNGram)
+	}
+
+	res.Triggered = append(res.Triggered, d.checkRates(regDomain, host, qtype)...)
+
+	res.Suspicious = uint(len(res.Triggered)) >= d.conf.MinScore
+
+	return res
+}
+
+// minRegLabelLen is the minimum length of the registrable label, for which its
+// entropy and bigrams are scored.  The shorter registrable labels are usually
+// abbreviations or brands chosen by the domain owners.
+const minRegLabelLen = 12
+
+// score returns the higher of the Shannon entropies and of the rare bigrams
+// shares of the subdomain part and of the registrable label within labels.
+// labels must not be empty and must not contain the public suffix.  The
+// registrable label is scored separately from the subdomain part, so that the
+// owner's choice of the name doesn't affect the score of the subdomains and
+// vice versa.
+func score(labels []string) (e, share float64) {
+	regLabel := labels[len(labels)-1]
+	sub := labels[:len(labels)-1]
+
+	e, share = entropy(sub), rareBigramsShare(sub)
+	if len(regLabel) < minRegLabelLen {
+		return e, share
+	}
+
+	reg := []string{regLabel}
+
+	return max(e, entropy(reg)), max(share, rareBigramsShare(reg))
+}
+
+// checkLabelLength returns true if any of labels is longer than allowed.
+func (d *Detector) checkLabelLength(labels []string) (ok bool) {
+	for _, l := range labels {
+		if uint(len(l)) > d.conf.LabelLength {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkRates records the request for host within regDomain and returns the
+// triggered rate heuristics.
+func (d *Detector) checkRates(regDomain, host string, qtype uint16) (triggered []Heuristic) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if now.Sub(d.windowStart) >= rateWindow {
+		clear(d.domains)
+		d.windowStart = now
+	}
+
+	ds, ok := d.domains[regDomain]
+	if !ok {
+		if len(d.domains) >= maxTrackedDomains {
+			log.Debug("anomaly: too many domains, not tracking %q", regDomain)
+
+			return nil
+		}
+
+		ds = &domainStats{
+			subdomains: map[string]struct{}{},
+		}
+		d.domains[regDomain] = ds
+	}
+
+	if qtype == dns.TypeTXT || qtype == dns.TypeNULL {
+		ds.txtNum++
+		if ds.txtNum > d.conf.TXTRate {
+			triggered = append(triggered, HeuristicTXTRate)
+		}
+	}
+
+	if uint(len(ds.subdomains)) <= d.conf.UniqueSubdomains {
+		ds.subdomains[host] = struct{}{}
+	}
+
+	if uint(len(ds.subdomains)) > d.conf.UniqueSubdomains {
+		triggered = append(triggered, HeuristicUniqueSubdomains)
+	}
+
+	return triggered
+}
@@ -0,0 +1,212 @@
+package anomaly_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/anomaly"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConfig returns a new *anomaly.Config with the default values.
+func newTestConfig() (conf *anomaly.Config) {
+	return &anomaly.Config{
+		Action:           anomaly.ActionBlock,
+		LabelLength:      anomaly.DefaultLabelLength,
+		Entropy:          anomaly.DefaultEntropy,
+		NGram:            anomaly.DefaultNGram,
+		TXTRate:          anomaly.DefaultTXTRate,
+		UniqueSubdomains: anomaly.DefaultUniqueSubdomains,
+		MinScore:         anomaly.DefaultMinScore,
+		Enabled:          true,
+	}
+}
+
+func TestDetector_Check(t *testing.T) {
+	d := anomaly.New(newTestConfig())
+
+	testCases := []struct {
+		name           string
+		host           string
+		wantTriggered  []anomaly.Heuristic
+		qtype          uint16
+		wantSuspicious bool
+	}{{
+		name:           "common",
+		host:           "www.example.com",
+		wantTriggered:  nil,
+		qtype:          dns.TypeA,
+		wantSuspicious: false,
+	}, {
+		name:           "cdn",
+		host:           "r3---sn-4g5e6nsz.googlevideo.com",
+		wantTriggered:  nil,
+		qtype:          dns.TypeA,
+		wantSuspicious: false,
+	}, {
+		name: "tunnel",
+		host: "4a5f3e9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f.t.example.org",
+		wantTriggered: []anomaly.Heuristic{
+			anomaly.HeuristicLabelLength,
+			anomaly.HeuristicEntropy,
+		},
+		qtype:          dns.TypeTXT,
+		wantSuspicious: true,
+	}, {
+		name: "random_subdomain",
+		host: "qxvbnmzkjwertplk.example.com",
+		wantTriggered: []anomaly.Heuristic{
+			anomaly.HeuristicEntropy,
+			anomaly.HeuristicNGram,
+		},
+		qtype:          dns.TypeA,
+		wantSuspicious: true,
+	}, {
+		name:           "high_entropy_only",
+		host:           "d3k9x7q2m8p4z6w1.example.net",
+		wantTriggered:  []anomaly.Heuristic{anomaly.HeuristicEntropy},
+		qtype:          dns.TypeA,
+		wantSuspicious: false,
+	}, {
+		name: "random_registrable",
+		host: "qxvbnmzkjwertplk.com",
+		wantTriggered: []anomaly.Heuristic{
+			anomaly.HeuristicEntropy,
+			anomaly.HeuristicNGram,
+		},
+		qtype:          dns.TypeA,
+		wantSuspicious: true,
+	}, {
+		name:           "short_registrable",
+		host:           "bkfzqwtmxl.com",
+		wantTriggered:  nil,
+		qtype:          dns.TypeA,
+		wantSuspicious: false,
+	}, {
+		name:           "long_brand",
+		host:           "githubusercontent.com",
+		wantTriggered:  nil,
+		qtype:          dns.TypeA,
+		wantSuspicious: false,
+	}, {
+		name:           "ptr",
+		host:           "f.e.d.c.b.a.9.8.7.6.5.4.3.2.1.0.ip6.arpa",
+		wantTriggered:  nil,
+		qtype:          dns.TypePTR,
+		wantSuspicious: false,
+	}, {
+		name:           "public_suffix",
+		host:           "com",
+		wantTriggered:  nil,
+		qtype:          dns.TypeA,
+		wantSuspicious: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res := d.Check(tc.host, tc.qtype)
+			require.NotNil(t, res)
+
+			assert.Equal(t, tc.wantTriggered, res.Triggered)
+			assert.Equal(t, tc.wantSuspicious, res.Suspicious)
+		})
+	}
+}
+
+func TestDetector_Check_rates(t *testing.T) {
+	conf := newTestConfig()
+	conf.TXTRate = 2
+	conf.UniqueSubdomains = 3
+
+	t.Run("txt", func(t *testing.T) {
+		d := anomaly.New(conf)
+
+		for range conf.TXTRate {
+			res := d.Check("txt.example.com", dns.TypeTXT)
+			assert.Empty(t, res.Triggered)
+		}
+
+		res := d.Check("txt.example.com", dns.TypeNULL)
+		assert.Equal(t, []anomaly.Heuristic{anomaly.HeuristicTXTRate}, res.Triggered)
+		assert.False(t, res.Suspicious)
+
+		res = d.Check("txt.example.net", dns.TypeTXT)
+		assert.Empty(t, res.Triggered)
+	})
+
+	t.Run("subdomains", func(t *testing.T) {
+		d := anomaly.New(conf)
+
+		for i := range conf.UniqueSubdomains {
+			res := d.Check(fmt.Sprintf("sub%d.example.com", i), dns.TypeA)
+			assert.Empty(t, res.Triggered)
+		}
+
+		res := d.Check("sub0.example.com", dns.TypeA)
+		assert.Empty(t, res.Triggered)
+
+		res = d.Check("another.example.com", dns.TypeTXT)
+		assert.Equal(t, []anomaly.Heuristic{anomaly.HeuristicUniqueSubdomains}, res.Triggered)
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		d := anomaly.New(conf)
+
+		var res *anomaly.Result
+		for i := range conf.UniqueSubdomains + 1 {
+			res = d.Check(fmt.Sprintf("%d.tunnel.example", i), dns.TypeTXT)
+		}
+
+		assert.Equal(t, []anomaly.Heuristic{
+			anomaly.HeuristicTXTRate,
+			anomaly.HeuristicUniqueSubdomains,
+		}, res.Triggered)
+		assert.True(t, res.Suspicious)
+		assert.Equal(t, "anomaly:txt_rate,unique_subdomains", res.Rule())
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		conf       func() (c *anomaly.Config)
+		name       string
+		wantErrMsg string
+	}{{
+		conf:       newTestConfig,
+		name:       "valid",
+		wantErrMsg: "",
+	}, {
+		conf: func() (c *anomaly.Config) {
+			c = newTestConfig()
+			c.Action = "drop"
+
+			return c
+		},
+		name:       "bad_action",
+		wantErrMsg: `action: bad value "drop"`,
+	}, {
+		conf: func() (c *anomaly.Config) {
+			c = newTestConfig()
+			c.NGram = 1.5
+			c.MinScore = 0
+
+			return c
+		},
+		name: "several",
+		wantErrMsg: strings.Join([]string{
+			"ngram: out of range: 1.5",
+			"min_score: must be positive",
+		}, "\n"),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.conf().Validate()
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package anomaly
+
+import (
+	"math"
+	"strings"
+)
+
+// minEntropyLen is the minimum total length of the labels, for which the
+// entropy is calculated.  The entropy of the shorter strings is too low to be
+// meaningful.
+const minEntropyLen = 12
+
+// entropy returns the Shannon entropy in bits per character of the
+// concatenated labels.  It returns zero if the labels are too short.
+func entropy(labels []string) (e float64) {
+	var counts [256]uint
+	n := 0
+	for _, l := range labels {
+		for i := range len(l) {
+			counts[l[i]]++
+		}
+
+		n += len(l)
+	}
+
+	if n < minEntropyLen {
+		return 0
+	}
+
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+
+		p := float64(c) / float64(n)
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
+
+// minBigrams is the minimum number of the letter bigrams in the labels, for
+// which their share is calculated.
+const minBigrams = 6
+
+// rareBigramsShare returns the share of the letter bigrams within labels, which
+// are unusual for the natural languages.  The bigrams containing digits and
+// hyphens are ignored.  It returns zero if there are too few letter bigrams.
+func rareBigramsShare(labels []string) (share float64) {
+	total, rare := 0, 0
+	for _, l := range labels {
+		for i := 1; i < len(l); i++ {
+			a, b := l[i-1], l[i]
+			if !isLetter(a) || !isLetter(b) {
+				continue
+			}
+
+			total++
+			if !strings.Contains(commonBigrams[a-'a'], string(b)) {
+				rare++
+			}
+		}
+	}
+
+	if total < minBigrams {
+		return 0
+	}
+
+	return float64(rare) / float64(total)
+}
+
+// isLetter returns true if c is a lowercase ASCII letter.
+func isLetter(c byte) (ok bool) {
+	return c >= 'a' && c <= 'z'
+}
+
+// commonBigrams contains the second letters of the frequent bigrams in English
+// and the domain names indexed by the first letter.
+var commonBigrams = [26]string{
+	'a' - 'a': "bcdefgilmnprstuvwyz",
+	'b' - 'a': "abeiloruy",
+	'c' - 'a': "acehiklortuy",
+	'd' - 'a': "adegilmorsuvy",
+	'e' - 'a': "abcdefgilmnopqrstuvwxy",
+	'f' - 'a': "aefilortuy",
+	'g' - 'a': "aeghilnorsuy",
+	'h' - 'a': "aeimnorty",
+	'i' - 'a': "abcdefgklmnopqrstvz",
+	'j' - 'a': "aeou",
+	'k' - 'a': "aeilnsy",
+	'l' - 'a': "abcdefiklmoprstuvwy",
+	'm' - 'a': "abeimnopsuy",
+	'n' - 'a': "acdefgiklnorstuvy",
+	'o' - 'a': "abcdefgiklmnoprstuvwxy",
+	'p' - 'a': "aehilmoprstuy",
+	'q' - 'a': "u",
+	'r' - 'a': "abcdefgiklmnoprstuvy",
+	's' - 'a': "acehiklmnopqstuwy",
+	't' - 'a': "aehilmorstuwy",
+	'u' - 'a': "abcdegilmnprst",
+	'v' - 'a': "aeioy",
+	'w' - 'a': "aehinorsl",
+	'x' - 'a': "aceipt",
+	'y' - 'a': "acdeilmnoprst",
+	'z' - 'a': "aeioz",
+}
@@ -0,0 +1,230 @@
+// Package bench contains a DNS load generator used to benchmark a DNS server,
+// including AdGuard Home itself.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+)
+
+// pacingIvl is the interval between the checks of how many queries must be
+// sent to keep the target rate.
+const pacingIvl = 5 * time.Millisecond
+
+// Config is the configuration of a benchmark run.
+type Config struct {
+	// Upstream is used to send the queries.  It must not be nil.
+	Upstream upstream.Upstream
+
+	// Queries are the queries to send.  They are sent in order, starting over
+	// when the end is reached.  It must not be empty.
+	Queries []Query
+
+	// Duration is the maximum duration of the run.  If zero, the run is only
+	// limited by Count.
+	Duration time.Duration
+
+	// Count is the maximum number of the queries to send.  If both Count and
+	// Duration are zero, each query is sent once.
+	Count uint64
+
+	// Rate is the target number of the queries per second.  If zero, the
+	// queries are sent as fast as the workers allow.
+	Rate uint
+
+	// Concurrency is the number of the workers sending the queries
+	// simultaneously.  It must be positive.
+	Concurrency uint
+}
+
+// Run performs the benchmark and returns the report.  The run stops when ctx is
+// canceled, in which case the report for the queries sent so far is returned.
+func Run(ctx context.Context, conf *Config) (r *Report, err error) {
+	if len(conf.Queries) == 0 {
+		return nil, errors.Error("bench: no queries")
+	} else if conf.Concurrency == 0 {
+		return nil, errors.Error("bench: concurrency must be positive")
+	}
+
+	count := conf.Count
+	if count == 0 && conf.Duration == 0 {
+		count = uint64(len(conf.Queries))
+	}
+
+	if conf.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Duration)
+		defer cancel()
+	}
+
+	jobs := make(chan Query, conf.Concurrency)
+	workerStats := make([]*stats, conf.Concurrency)
+
+	wg := &sync.WaitGroup{}
+	for i := range workerStats {
+		s := newStats()
+		workerStats[i] = s
+
+		wg.Add(1)
+		go work(conf.Upstream, jobs, s, wg)
+	}
+
+	start := time.Now()
+
+	var dropped uint64
+	if conf.Rate == 0 {
+		feed(ctx, conf.Queries, count, jobs)
+	} else {
+		dropped = feedPaced(ctx, conf.Queries, count, conf.Rate, jobs)
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+
+	total := newStats()
+	for _, s := range workerStats {
+		total.merge(s)
+	}
+
+	return total.report(conf.Upstream.Address(), elapsed, dropped), nil
+}
+
+// feed sends the queries into jobs as soon as the workers are ready.  If count
+// is zero, the queries are sent until ctx is canceled.
+func feed(ctx context.Context, qs []Query, count uint64, jobs chan<- Query) {
+	for i := uint64(0); count == 0 || i < count; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- qs[i%uint64(len(qs))]:
+			// Go on.
+		}
+	}
+}
+
+// feedPaced sends the queries into jobs keeping the rate of rate queries per
+// second.  The queries, for which there are no ready workers, are dropped.  If
+// count is zero, the queries are sent until ctx is canceled.
+func feedPaced(
+	ctx context.Context,
+	qs []Query,
+	count uint64,
+	rate uint,
+	jobs chan<- Query,
+) (dropped uint64) {
+	ticker := time.NewTicker(pacingIvl)
+	defer ticker.Stop()
+
+	start := time.Now()
+	var sent uint64
+	for count == 0 || sent < count {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return dropped
+		case now = <-ticker.C:
+			// Go on.
+		}
+
+		due := uint64(now.Sub(start).Seconds() * float64(rate))
+		if count != 0 {
+			due = min(due, count)
+		}
+
+		for ; sent < due; sent++ {
+			select {
+			case jobs <- qs[sent%uint64(len(qs))]:
+				// Go on.
+			default:
+				dropped++
+			}
+		}
+	}
+
+	return dropped
+}
+
+// work sends the queries from jobs using u and records the results into s
+// until jobs is closed.
+func work(u upstream.Upstream, jobs <-chan Query, s *stats, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for q := range jobs {
+		req := &dns.Msg{}
+		req.SetQuestion(q.Host, q.QType)
+		req.RecursionDesired = true
+
+		start := time.Now()
+		resp, err := u.Exchange(req)
+		if err != nil {
+			log.Debug("bench: exchanging %s %s: %s", q.Host, dns.Type(q.QType), err)
+
+			s.errs[errorCategory(err)]++
+
+			continue
+		}
+
+		s.latency.add(time.Since(start))
+		s.rcodes[resp.Rcode]++
+	}
+}
+
+// errorCategory returns the category of the exchange error for the report.
+func errorCategory(err error) (cat string) {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return errCatTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errCatRefused
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return errCatTimeout
+		}
+
+		return errCatNetwork
+	default:
+		return errCatOther
+	}
+}
+
+// NewUpstream returns a new upstream for the server address, which may be any
+// address supported by dnsproxy, for example "udp://127.0.0.1:53" or
+// "https://dns.example/dns-query/client-id".  If bootstrap is valid, the
+// hostname of the address is resolved into it, which allows to use the
+// ClientIDs in the server names when benchmarking a local instance.
+func NewUpstream(
+	addr string,
+	bootstrap netip.Addr,
+	timeout time.Duration,
+	insecure bool,
+) (u upstream.Upstream, err error) {
+	opts := &upstream.Options{
+		Timeout:            timeout,
+		InsecureSkipVerify: insecure,
+	}
+
+	if bootstrap.IsValid() {
+		opts.Bootstrap = upstream.StaticResolver{bootstrap}
+	}
+
+	u, err = upstream.AddressToUpstream(addr, opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating upstream: %w", err)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,135 @@
+package bench_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/bench"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
+
+// nxHost is the hostname, for which the test server responds with NXDOMAIN.
+const nxHost = "nx.example."
+
+// startServer starts a local plain DNS server and returns its address.
+func startServer(t *testing.T) (addr string) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	startedCh := make(chan struct{})
+	srv := &dns.Server{
+		PacketConn: pc,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := (&dns.Msg{}).SetReply(req)
+			if req.Question[0].Name == nxHost {
+				resp.Rcode = dns.RcodeNameError
+			}
+
+			_ = w.WriteMsg(resp)
+		}),
+		NotifyStartedFunc: func() { close(startedCh) },
+	}
+
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	_, _ = testutil.RequireReceive(t, startedCh, testTimeout)
+
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func TestRun(t *testing.T) {
+	u, err := bench.NewUpstream(startServer(t), netip.Addr{}, testTimeout, false)
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, u.Close)
+
+	qs := []bench.Query{{
+		Host:  "example.com.",
+		QType: dns.TypeA,
+	}, {
+		Host:  nxHost,
+		QType: dns.TypeAAAA,
+	}}
+
+	t.Run("count", func(t *testing.T) {
+		r, runErr := bench.Run(context.Background(), &bench.Config{
+			Upstream:    u,
+			Queries:     qs,
+			Count:       10,
+			Concurrency: 2,
+		})
+		require.NoError(t, runErr)
+
+		assert.Equal(t, uint64(10), r.Sent)
+		assert.Equal(t, uint64(10), r.Succeeded)
+		assert.Zero(t, r.Failed)
+		assert.Zero(t, r.Dropped)
+		assert.Equal(t, map[string]uint64{"NOERROR": 5, "NXDOMAIN": 5}, r.RCodes)
+
+		require.NotNil(t, r.Latency)
+
+		assert.LessOrEqual(t, r.Latency.Min, r.Latency.P50)
+		assert.LessOrEqual(t, r.Latency.P50, r.Latency.P99)
+		assert.LessOrEqual(t, r.Latency.P99, r.Latency.Max)
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, r.WriteText(buf))
+
+		assert.Contains(t, buf.String(), "succeeded: 10\n")
+	})
+
+	t.Run("rate", func(t *testing.T) {
+		r, runErr := bench.Run(context.Background(), &bench.Config{
+			Upstream:    u,
+			Queries:     qs,
+			Count:       20,
+			Rate:        100,
+			Concurrency: 2,
+		})
+		require.NoError(t, runErr)
+
+		assert.Equal(t, uint64(20), r.Sent+r.Dropped)
+
+		// 20 queries at 100 qps take at least 200ms.
+		assert.GreaterOrEqual(t, r.Elapsed, 0.19)
+	})
+}
+
+func TestRun_errors(t *testing.T) {
+	// Listen and close immediately to get an address, which doesn't respond.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := pc.LocalAddr().String()
+	require.NoError(t, pc.Close())
+
+	u, err := bench.NewUpstream("tcp://"+addr, netip.Addr{}, testTimeout, false)
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, u.Close)
+
+	r, err := bench.Run(context.Background(), &bench.Config{
+		Upstream: u,
+		Queries: []bench.Query{{
+			Host:  "example.com.",
+			QType: dns.TypeA,
+		}},
+		Concurrency: 1,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), r.Failed)
+	assert.Equal(t, map[string]uint64{"refused": 1}, r.Errors)
+	assert.Nil(t, r.Latency)
+}
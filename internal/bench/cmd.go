@@ -0,0 +1,186 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+)
+
+// Default values of the command-line options.
+const (
+	defaultConcurrency = 10
+	defaultTimeout     = 2 * time.Second
+)
+
+// cmdOptions are the command-line options of the benchmark subcommand.
+type cmdOptions struct {
+	server      string
+	input       string
+	qtype       string
+	bootstrap   string
+	duration    time.Duration
+	timeout     time.Duration
+	count       uint64
+	rate        uint
+	concurrency uint
+	insecure    bool
+	json        bool
+}
+
+// Main parses the arguments of the benchmark subcommand, performs the
+// benchmark, and writes the report into out.  The queries are read from
+// stdin if the input is "-".
+func Main(ctx context.Context, cmdName string, args []string, out io.Writer) (err error) {
+	opts, err := parseOptions(cmdName, args)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	conf, err := opts.toConfig()
+	if err != nil {
+		return fmt.Errorf("bench: %w", err)
+	}
+	defer func() { err = errors.WithDeferred(err, conf.Upstream.Close()) }()
+
+	log.Info(
+		"bench: sending %d unique queries to %s with %d workers",
+		len(conf.Queries),
+		conf.Upstream.Address(),
+		conf.Concurrency,
+	)
+
+	r, err := Run(ctx, conf)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	if opts.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	} else {
+		err = r.WriteText(out)
+	}
+	if err != nil {
+		return fmt.Errorf("bench: writing report: %w", err)
+	}
+
+	return nil
+}
+
+// parseOptions parses the command-line arguments of the subcommand.
+func parseOptions(cmdName string, args []string) (opts *cmdOptions, err error) {
+	opts = &cmdOptions{}
+
+	fs := flag.NewFlagSet(cmdName+" bench", flag.ContinueOnError)
+	fs.StringVar(
+		&opts.server,
+		"server",
+		"udp://127.0.0.1:53",
+		"Address of the DNS server: udp://, tcp://, tls://, https://, or quic://.  "+
+			"Put the ClientID into the path or the server name.",
+	)
+	fs.StringVar(
+		&opts.input,
+		"input",
+		"",
+		"Path to the domain list or the query log file, or - for stdin.  Required.",
+	)
+	fs.StringVar(&opts.qtype, "qtype", "A", "Question type for the domains without one.")
+	fs.StringVar(
+		&opts.bootstrap,
+		"bootstrap",
+		"",
+		"IP address to connect to instead of resolving the server name.",
+	)
+	fs.DurationVar(&opts.duration, "duration", 0, "Maximum duration of the run.")
+	fs.DurationVar(&opts.timeout, "timeout", defaultTimeout, "Timeout of a single query.")
+	fs.Uint64Var(
+		&opts.count,
+		"count",
+		0,
+		"Maximum number of queries.  If it and -duration are zero, each query is sent once.",
+	)
+	fs.UintVar(&opts.rate, "rate", 0, "Target queries per second.  Zero means no limit.")
+	fs.UintVar(&opts.concurrency, "concurrency", defaultConcurrency, "Number of workers.")
+	fs.BoolVar(&opts.insecure, "insecure", false, "Don't verify the server certificate.")
+	fs.BoolVar(&opts.json, "json", false, "Print the report as JSON.")
+
+	err = fs.Parse(args)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("bench: unexpected arguments: %q", fs.Args())
+	} else if opts.input == "" {
+		return nil, errors.Error("bench: -input is required")
+	}
+
+	return opts, nil
+}
+
+// toConfig reads the queries and returns the benchmark configuration.
+func (opts *cmdOptions) toConfig() (conf *Config, err error) {
+	defQType, ok := dns.StringToType[strings.ToUpper(opts.qtype)]
+	if !ok {
+		return nil, fmt.Errorf("bad -qtype %q", opts.qtype)
+	}
+
+	var bootstrap netip.Addr
+	if opts.bootstrap != "" {
+		bootstrap, err = netip.ParseAddr(opts.bootstrap)
+		if err != nil {
+			return nil, fmt.Errorf("-bootstrap: %w", err)
+		}
+	}
+
+	qs, err := readQueriesFrom(opts.input, defQType)
+	if err != nil {
+		return nil, fmt.Errorf("-input: %w", err)
+	}
+
+	u, err := NewUpstream(opts.server, bootstrap, opts.timeout, opts.insecure)
+	if err != nil {
+		return nil, fmt.Errorf("-server: %w", err)
+	}
+
+	return &Config{
+		Upstream:    u,
+		Queries:     qs,
+		Duration:    opts.duration,
+		Count:       opts.count,
+		Rate:        opts.rate,
+		Concurrency: opts.concurrency,
+	}, nil
+}
+
+// readQueriesFrom reads the queries from the file at path or from stdin if
+// path is "-".
+func readQueriesFrom(path string, defQType uint16) (qs []Query, err error) {
+	if path == "-" {
+		return ReadQueries(os.Stdin, defQType)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+	defer func() { err = errors.WithDeferred(err, f.Close()) }()
+
+	return ReadQueries(f, defQType)
+}
@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+)
+
+// Query is a single DNS question sent during the benchmark.
+type Query struct {
+	// Host is the requested hostname.
+	Host string
+
+	// QType is the type of the question.
+	QType uint16
+}
+
+// qlogEntry is the part of a query log entry used to build a query.
+type qlogEntry struct {
+	Host  string `json:"QH"`
+	QType string `json:"QT"`
+}
+
+// ReadQueries reads the queries from r.  Each line is either a query log entry
+// in the JSON format, as written by AdGuard Home, or a hostname optionally
+// followed by the question type, for example:
+//
+//	example.com
+//	example.org AAAA
+//
+// Empty lines and lines starting with "#" or "!" are skipped.  defQType is used
+// for the hostnames without the question type.
+func ReadQueries(r io.Reader, defQType uint16) (qs []Query, err error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), 1024*1024)
+
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+
+		var q Query
+		q, err = parseQuery(line, defQType)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		qs = append(qs, q)
+	}
+
+	err = s.Err()
+	if err != nil {
+		return nil, fmt.Errorf("reading: %w", err)
+	}
+
+	if len(qs) == 0 {
+		return nil, errors.Error("no queries")
+	}
+
+	return qs, nil
+}
+
+// parseQuery parses a single non-empty line of the input.
+func parseQuery(line string, defQType uint16) (q Query, err error) {
+	var host, qtypeStr string
+	if line[0] == '{' {
+		e := &qlogEntry{}
+		err = json.Unmarshal([]byte(line), e)
+		if err != nil {
+			return Query{}, fmt.Errorf("parsing query log entry: %w", err)
+		}
+
+		host, qtypeStr = e.Host, e.QType
+	} else {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			host = fields[0]
+		case 2:
+			host, qtypeStr = fields[0], fields[1]
+		default:
+			return Query{}, fmt.Errorf("bad number of fields: %d", len(fields))
+		}
+	}
+
+	if host == "" {
+		return Query{}, errors.Error("empty hostname")
+	}
+
+	q = Query{
+		Host:  dns.Fqdn(host),
+		QType: defQType,
+	}
+
+	if qtypeStr != "" {
+		var ok bool
+		q.QType, ok = dns.StringToType[strings.ToUpper(qtypeStr)]
+		if !ok {
+			return Query{}, fmt.Errorf("bad question type %q", qtypeStr)
+		}
+	}
+
+	return q, nil
+}
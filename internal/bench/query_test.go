@@ -0,0 +1,81 @@
+package bench_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/bench"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadQueries(t *testing.T) {
+	testCases := []struct {
+		name       string
+		in         string
+		wantErrMsg string
+		want       []bench.Query
+	}{{
+		name: "domains",
+		in: strings.Join([]string{
+			"# Comment.",
+			"example.com",
+			"",
+			"  example.org   aaaa  ",
+			"example.net. TXT",
+		}, "\n"),
+		wantErrMsg: "",
+		want: []bench.Query{{
+			Host:  "example.com.",
+			QType: dns.TypeA,
+		}, {
+			Host:  "example.org.",
+			QType: dns.TypeAAAA,
+		}, {
+			Host:  "example.net.",
+			QType: dns.TypeTXT,
+		}},
+	}, {
+		name: "querylog",
+		in: `{"T":"2024-01-01T00:00:00Z","QH":"example.com","QT":"HTTPS","QC":"IN"}` +
+			"\n" + `{"QH":"example.org","QT":"A"}`,
+		wantErrMsg: "",
+		want: []bench.Query{{
+			Host:  "example.com.",
+			QType: dns.TypeHTTPS,
+		}, {
+			Host:  "example.org.",
+			QType: dns.TypeA,
+		}},
+	}, {
+		name:       "empty",
+		in:         "# Comment.\n\n",
+		wantErrMsg: "no queries",
+		want:       nil,
+	}, {
+		name:       "bad_qtype",
+		in:         "example.com\nexample.org BAD",
+		wantErrMsg: `line 2: bad question type "BAD"`,
+		want:       nil,
+	}, {
+		name:       "bad_fields",
+		in:         "example.com A 1",
+		wantErrMsg: "line 1: bad number of fields: 3",
+		want:       nil,
+	}, {
+		name:       "empty_host",
+		in:         `{"QT":"A"}`,
+		wantErrMsg: "line 1: empty hostname",
+		want:       nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := bench.ReadQueries(strings.NewReader(tc.in), dns.TypeA)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, qs)
+		})
+	}
+}
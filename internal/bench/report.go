@@ -0,0 +1,341 @@
+package bench
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Error categories of the failed queries.
+const (
+	errCatTimeout = "timeout"
+	errCatRefused = "refused"
+	errCatNetwork = "network"
+	errCatOther   = "other"
+)
+
+// Report is the result of a benchmark run.
+type Report struct {
+	// Latency contains the latency statistics of the successful queries.  It
+	// is nil if there are none.
+	Latency *LatencyReport `json:"latency"`
+
+	// RCodes are the numbers of the responses by their response codes.
+	RCodes map[string]uint64 `json:"rcodes"`
+
+	// Errors are the numbers of the failed queries by the error categories:
+	// "timeout", "refused", "network", and "other".
+	Errors map[string]uint64 `json:"errors"`
+
+	// Server is the address of the benchmarked server.
+	Server string `json:"server"`
+
+	// Elapsed is the duration of the run in seconds.
+	Elapsed float64 `json:"elapsed_sec"`
+
+	// QPS is the number of the completed queries per second.
+	QPS float64 `json:"qps"`
+
+	// Sent is the number of the sent queries.
+	Sent uint64 `json:"sent"`
+
+	// Succeeded is the number of the queries with a response.
+	Succeeded uint64 `json:"succeeded"`
+
+	// Failed is the number of the queries without a response.
+	Failed uint64 `json:"failed"`
+
+	// Dropped is the number of the queries, which weren't sent, because all
+	// workers were busy.  A non-zero value means that the target rate wasn't
+	// reached.
+	Dropped uint64 `json:"dropped"`
+}
+
+// LatencyReport contains the latency statistics in milliseconds.
+type LatencyReport struct {
+	Min float64 `json:"min_ms"`
+	Avg float64 `json:"avg_ms"`
+	P50 float64 `json:"p50_ms"`
+	P90 float64 `json:"p90_ms"`
+	P95 float64 `json:"p95_ms"`
+	P99 float64 `json:"p99_ms"`
+	Max float64 `json:"max_ms"`
+}
+
+// stats are the statistics collected by a single worker.
+type stats struct {
+	rcodes  map[int]uint64
+	errs    map[string]uint64
+	latency *latencyHist
+}
+
+// newStats returns a new properly initialized *stats.
+func newStats() (s *stats) {
+	return &stats{
+		rcodes:  map[int]uint64{},
+		errs:    map[string]uint64{},
+		latency: &latencyHist{},
+	}
+}
+
+// merge adds the statistics from other to s.
+func (s *stats) merge(other *stats) {
+	for rc, n := range other.rcodes {
+		s.rcodes[rc] += n
+	}
+
+	for cat, n := range other.errs {
+		s.errs[cat] += n
+	}
+
+	s.latency.merge(other.latency)
+}
+
+// report returns the report built from the statistics.
+func (s *stats) report(server string, elapsed time.Duration, dropped uint64) (r *Report) {
+	r = &Report{
+		RCodes:    make(map[string]uint64, len(s.rcodes)),
+		Errors:    maps.Clone(s.errs),
+		Server:    server,
+		Elapsed:   elapsed.Seconds(),
+		Succeeded: s.latency.count,
+		Dropped:   dropped,
+	}
+
+	for rc, n := range s.rcodes {
+		r.RCodes[rcodeString(rc)] += n
+	}
+
+	for _, n := range s.errs {
+		r.Failed += n
+	}
+
+	r.Sent = r.Succeeded + r.Failed
+	if elapsed > 0 {
+		r.QPS = float64(r.Sent) / elapsed.Seconds()
+	}
+
+	r.Latency = s.latency.report()
+
+	return r
+}
+
+// rcodeString returns the name of the response code or its numeric value if
+// it's unknown.
+func rcodeString(rc int) (s string) {
+	s, ok := dns.RcodeToString[rc]
+	if !ok {
+		return fmt.Sprintf("RCODE%d", rc)
+	}
+
+	return s
+}
+
+// Latency histogram parameters.
+const (
+	// histBase is the ratio between the bounds of each bucket, which makes the
+	// relative error of the percentiles at most 1%.
+	histBase = 1.01
+
+	// histBucketsNum is the number of the buckets.  The last one covers all the
+	// latencies of about 12 minutes and more.
+	histBucketsNum = 2048
+
+	// histUnit is the upper bound of the first bucket.
+	histUnit = time.Microsecond
+)
+
+// latencyHist is a histogram of the latencies with logarithmic buckets.  It
+// uses the same amount of memory regardless of the number of the queries.
+type latencyHist struct {
+	// buckets are the numbers of the latencies in each bucket.  The bucket i
+	// contains the latencies from histUnit*histBase^i, exclusive, to
+	// histUnit*histBase^(i+1), inclusive.
+	buckets [histBucketsNum]uint64
+
+	// count is the total number of the latencies.
+	count uint64
+
+	// sum is the sum of all latencies.
+	sum time.Duration
+
+	// min is the lowest latency.  It's only valid if count is not zero.
+	min time.Duration
+
+	// max is the highest latency.
+	max time.Duration
+}
+
+// add records the latency d.
+func (h *latencyHist) add(d time.Duration) {
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+
+	h.max = max(h.max, d)
+	h.sum += d
+	h.count++
+	h.buckets[histBucket(d)]++
+}
+
+// merge adds the latencies from other to h.
+func (h *latencyHist) merge(other *latencyHist) {
+	if other.count == 0 {
+		return
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+
+	h.max = max(h.max, other.max)
+	h.sum += other.sum
+	h.count += other.count
+	for i, n := range other.buckets {
+		h.buckets[i] += n
+	}
+}
+
+// report returns the statistics about the latencies.  It returns nil if there
+// are none.
+func (h *latencyHist) report() (lr *LatencyReport) {
+	if h.count == 0 {
+		return nil
+	}
+
+	return &LatencyReport{
+		Min: toMillis(h.min),
+		Avg: toMillis(h.sum / time.Duration(h.count)),
+		P50: toMillis(h.percentile(50)),
+		P90: toMillis(h.percentile(90)),
+		P95: toMillis(h.percentile(95)),
+		P99: toMillis(h.percentile(99)),
+		Max: toMillis(h.max),
+	}
+}
+
+// percentile returns the approximate p-th percentile of the latencies using
+// the nearest-rank method.  It's the upper bound of the bucket containing the
+// latency of that rank, but never lower than min or higher than max.  h must
+// not be empty.
+func (h *latencyHist) percentile(p int) (d time.Duration) {
+	// Round up to get the nearest rank.
+	rank := max((h.count*uint64(p)+99)/100, 1)
+
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			return min(max(histUpperBound(i), h.min), h.max)
+		}
+	}
+
+	return h.max
+}
+
+// histBucket returns the index of the bucket for the latency d.
+func histBucket(d time.Duration) (i int) {
+	if d <= histUnit {
+		return 0
+	}
+
+	i = int(math.Ceil(math.Log(float64(d)/float64(histUnit))/math.Log(histBase))) - 1
+
+	return min(max(i, 0), histBucketsNum-1)
+}
+
+// histUpperBound returns the upper bound of the bucket with index i.
+func histUpperBound(i int) (d time.Duration) {
+	return time.Duration(float64(histUnit) * math.Pow(histBase, float64(i+1)))
+}
+
+// toMillis returns d in milliseconds.
+func toMillis(d time.Duration) (ms float64) {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// WriteText writes the human-readable report into w.
+func (r *Report) WriteText(w io.Writer) (err error) {
+	_, err = fmt.Fprintf(
+		w,
+		"server:    %s\n"+
+			"elapsed:   %.2fs\n"+
+			"sent:      %d\n"+
+			"succeeded: %d\n"+
+			"failed:    %d\n"+
+			"dropped:   %d\n"+
+			"qps:       %.1f\n",
+		r.Server,
+		r.Elapsed,
+		r.Sent,
+		r.Succeeded,
+		r.Failed,
+		r.Dropped,
+		r.QPS,
+	)
+	if err != nil {
+		return err
+	}
+
+	if lr := r.Latency; lr != nil {
+		_, err = fmt.Fprintf(
+			w,
+			"latency:   min %.2fms, avg %.2fms, p50 %.2fms, p90 %.2fms, "+
+				"p95 %.2fms, p99 %.2fms, max %.2fms\n",
+			lr.Min,
+			lr.Avg,
+			lr.P50,
+			lr.P90,
+			lr.P95,
+			lr.P99,
+			lr.Max,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeCounts(w, "rcodes", r.RCodes)
+	if err != nil {
+		return err
+	}
+
+	return writeCounts(w, "errors", r.Errors)
+}
+
+// writeCounts writes the non-empty counts into w sorted by the number in the
+// descending order.
+func writeCounts(w io.Writer, title string, counts map[string]uint64) (err error) {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	_, err = fmt.Fprintf(w, "%s:\n", title)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+
+	slices.SortFunc(keys, func(a, b string) (res int) {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), cmp.Compare(a, b))
+	})
+
+	for _, k := range keys {
+		_, err = fmt.Fprintf(w, "  %-10s %d\n", k, counts[k])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
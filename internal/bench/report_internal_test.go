@@ -0,0 +1,76 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyHist(t *testing.T) {
+	h := &latencyHist{}
+	require.Nil(t, h.report())
+
+	other := &latencyHist{}
+	for i := 1; i <= 1000; i++ {
+		d := time.Duration(i) * time.Millisecond
+		if i%2 == 0 {
+			h.add(d)
+		} else {
+			other.add(d)
+		}
+	}
+
+	h.merge(other)
+	h.merge(&latencyHist{})
+
+	lr := h.report()
+	require.NotNil(t, lr)
+
+	// The percentiles may exceed the exact values by 1%.
+	const delta = 0.01
+
+	assert.Equal(t, 1.0, lr.Min)
+	assert.InEpsilon(t, 500.5, lr.Avg, delta)
+	assert.InEpsilon(t, 500.0, lr.P50, delta)
+	assert.InEpsilon(t, 900.0, lr.P90, delta)
+	assert.InEpsilon(t, 950.0, lr.P95, delta)
+	assert.InEpsilon(t, 990.0, lr.P99, delta)
+	assert.Equal(t, 1000.0, lr.Max)
+
+	assert.GreaterOrEqual(t, lr.P50, 500.0)
+	assert.LessOrEqual(t, lr.P99, lr.Max)
+}
+
+func TestHistBucket(t *testing.T) {
+	testCases := []struct {
+		name string
+		d    time.Duration
+		want int
+	}{{
+		name: "zero",
+		d:    0,
+		want: 0,
+	}, {
+		name: "unit",
+		d:    histUnit,
+		want: 0,
+	}, {
+		name: "huge",
+		d:    24 * time.Hour,
+		want: histBucketsNum - 1,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, histBucket(tc.d))
+		})
+	}
+
+	for _, d := range []time.Duration{time.Millisecond, 37 * time.Millisecond, time.Second} {
+		i := histBucket(d)
+		assert.LessOrEqual(t, d, histUpperBound(i))
+		assert.Greater(t, d, histUpperBound(i-1))
+	}
+}
@@ -0,0 +1,257 @@
+// Package bypass implements the prevention and detection of the DNS bypass,
+// that is the usage of public encrypted DNS resolvers instead of AdGuard Home.
+package bypass
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/golibs/container"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// Match is the result of checking a hostname.
+type Match uint8
+
+// Match values.
+const (
+	// MatchNone means that the hostname isn't related to the DNS bypass.
+	MatchNone Match = iota
+
+	// MatchResolver means that the hostname is the one of a public DNS
+	// resolver or its subdomain.
+	MatchResolver
+
+	// MatchCanary means that the hostname is a canary domain, which tells the
+	// applications to not use their own DNS resolvers.
+	MatchCanary
+)
+
+// canaryDomains are the domains telling the applications to disable their
+// built-in encrypted DNS or private relays, if they can't be resolved.
+//
+// See https://support.mozilla.org/en-US/kb/canary-domain-use-application-dnsnet
+// and https://developer.apple.com/support/prepare-your-network-for-icloud-private-relay.
+var canaryDomains = []string{
+	"use-application-dns.net",
+	"mask.icloud.com",
+	"mask-h2.icloud.com",
+}
+
+// Config is the configuration of the DNS bypass prevention.
+type Config struct {
+	// HTTPClient is the client used to download the list of resolvers.  It
+	// must not be nil, if ListURL is set.
+	HTTPClient *http.Client
+
+	// ConfigModified is called when the configuration is changed using the
+	// HTTP API.  It must not be nil.
+	ConfigModified func()
+
+	// HTTPRegister registers the HTTP handlers.  It may be nil.
+	HTTPRegister aghhttp.RegisterFunc
+
+	// ListURL is the URL of the list of the resolvers' hostnames, which is
+	// used in addition to the built-in one.  If empty, only the built-in list
+	// is used.
+	ListURL string
+
+	// Enabled defines if the requests for the resolvers and the canary domains
+	// are blocked and the clients are checked for the DNS bypass.
+	Enabled bool
+}
+
+// Detector blocks the requests for the public DNS resolvers and the canary
+// domains and detects the clients which seem to bypass AdGuard Home.
+type Detector struct {
+	// resolvers is the set of the hostnames of the public DNS resolvers,
+	// including the built-in and the downloaded ones.
+	resolvers atomic.Pointer[container.MapSet[string]]
+
+	// clients contains the statistics of the clients.
+	clients *clientsStats
+
+	// done is closed when the detector is closed.
+	done chan struct{}
+
+	// now returns the current time.  It's a field to be replaced in tests.
+	now func() (t time.Time)
+
+	// listUpdated is the time of the last successful update of the list.
+	listUpdated time.Time
+
+	httpClient     *http.Client
+	configModified func()
+
+	// listURL is the URL of the list of resolvers.  It may be nil.
+	listURL *url.URL
+
+	// confMu protects enabled, listURL, and listUpdated.
+	confMu *sync.RWMutex
+
+	enabled bool
+}
+
+// New returns a new properly initialized *Detector.  conf must not be nil.
+func New(conf *Config) (d *Detector, err error) {
+	listURL, err := parseListURL(conf.ListURL)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	d = &Detector{
+		clients:        newClientsStats(),
+		done:           make(chan struct{}),
+		now:            time.Now,
+		httpClient:     conf.HTTPClient,
+		configModified: conf.ConfigModified,
+		listURL:        listURL,
+		confMu:         &sync.RWMutex{},
+		enabled:        conf.Enabled,
+	}
+
+	d.resolvers.Store(defaultResolvers())
+
+	if conf.HTTPRegister != nil {
+		d.registerHTTPHandlers(conf.HTTPRegister)
+	}
+
+	return d, nil
+}
+
+// parseListURL parses and validates the URL of the list of resolvers.  If s is
+// empty, u is nil.
+func parseListURL(s string) (u *url.URL, err error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	u, err = url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("bypass: list url: %w", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("bypass: list url: bad scheme %q", u.Scheme)
+	}
+
+	return u, nil
+}
+
+// Start starts the periodic updates of the list of resolvers.  It must only be
+// called once.
+func (d *Detector) Start() {
+	go d.updateLoop()
+}
+
+// Close stops the periodic updates of the list of resolvers.  It must only be
+// called once.
+func (d *Detector) Close() {
+	close(d.done)
+}
+
+// WriteDiskConfig writes the current configuration to c.  c must not be nil.
+func (d *Detector) WriteDiskConfig(c *Config) {
+	d.confMu.RLock()
+	defer d.confMu.RUnlock()
+
+	c.Enabled = d.enabled
+	c.ListURL = urlString(d.listURL)
+}
+
+// isEnabled returns true if the bypass prevention is enabled.
+func (d *Detector) isEnabled() (ok bool) {
+	d.confMu.RLock()
+	defer d.confMu.RUnlock()
+
+	return d.enabled
+}
+
+// Check returns the match of host requested by the client with ip and records
+// the request for the detection.  host must be a lowercased domain name
+// without the trailing dot.  If the bypass prevention is disabled, it always
+// returns [MatchNone].
+func (d *Detector) Check(ip netip.Addr, host string) (m Match) {
+	if !d.isEnabled() {
+		return MatchNone
+	}
+
+	switch {
+	case matchesAny(host, canaryDomains):
+		m = MatchCanary
+	case d.isResolver(host):
+		m = MatchResolver
+	default:
+		m = MatchNone
+	}
+
+	if ip.IsValid() {
+		d.clients.record(ip, host, m, d.now())
+	}
+
+	return m
+}
+
+// isResolver returns true if host is the hostname of a known resolver or its
+// subdomain.
+func (d *Detector) isResolver(host string) (ok bool) {
+	resolvers := d.resolvers.Load()
+	for h := host; h != ""; {
+		if resolvers.Has(h) {
+			return true
+		}
+
+		_, h, _ = strings.Cut(h, ".")
+	}
+
+	return false
+}
+
+// matchesAny returns true if host is one of domains or their subdomain.
+func matchesAny(host string, domains []string) (ok bool) {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setConfig sets the new configuration and updates the list of resolvers, if
+// its URL has changed.
+func (d *Detector) setConfig(enabled bool, listURL *url.URL) {
+	d.confMu.Lock()
+	defer d.confMu.Unlock()
+
+	d.enabled = enabled
+
+	if urlString(listURL) == urlString(d.listURL) {
+		return
+	}
+
+	d.listURL = listURL
+	d.listUpdated = time.Time{}
+
+	d.resolvers.Store(defaultResolvers())
+	if listURL != nil {
+		go d.update(listURL)
+	}
+
+	log.Debug("bypass: list url changed to %q", urlString(listURL))
+}
+
+// urlString returns the string representation of u.  If u is nil, s is empty.
+func urlString(u *url.URL) (s string) {
+	if u == nil {
+		return ""
+	}
+
+	return u.String()
+}
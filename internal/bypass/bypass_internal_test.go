@@ -0,0 +1,142 @@
+package bypass
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseList(t *testing.T) {
+	testCases := []struct {
+		name       string
+		in         string
+		wantErrMsg string
+		want       []string
+	}{{
+		name:       "success",
+		in:         "# Resolvers.\n\nDoH.Example.\n  dot.example  \n",
+		wantErrMsg: "",
+		want:       []string{"doh.example", "dot.example"},
+	}, {
+		name:       "empty",
+		in:         "",
+		wantErrMsg: "",
+		want:       nil,
+	}, {
+		name: "bad_host",
+		in:   "doh.example\nbad host\n",
+		wantErrMsg: `line 2: bad domain name "bad host": ` +
+			`bad top-level domain name label "bad host": ` +
+			`bad top-level domain name label rune ' '`,
+		want: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			hosts, err := parseList(strings.NewReader(tc.in))
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, hosts)
+		})
+	}
+}
+
+func TestClientsStats_dropOff(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ip := netip.MustParseAddr("192.168.1.2")
+
+	// record makes n queries from ip within the hour h since start.
+	record := func(s *clientsStats, h, n int) {
+		at := start.Add(time.Duration(h) * time.Hour)
+		for range n {
+			s.record(ip, "example.org", MatchNone, at)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		lastHour int
+		want     bool
+	}{{
+		name:     "drop_off",
+		lastHour: 1,
+		want:     true,
+	}, {
+		name:     "steady",
+		lastHour: 100,
+		want:     false,
+	}, {
+		name:     "offline",
+		lastHour: 0,
+		want:     false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newClientsStats()
+			for h := range statsHours {
+				record(s, h, 100)
+			}
+
+			// The hour before the current one.
+			record(s, statsHours, tc.lastHour)
+
+			now := start.Add((statsHours + 1) * time.Hour)
+			reports := s.reports(now)
+			if !tc.want {
+				assert.Empty(t, reports)
+
+				return
+			}
+
+			require.Len(t, reports, 1)
+
+			assert.Equal(t, []Reason{ReasonQueryDropOff}, reports[0].Reasons)
+		})
+	}
+}
+
+func TestClientsStats_evict(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// newIP returns a unique address for i.
+	newIP := func(i int) (ip netip.Addr) {
+		return netip.AddrFrom4([4]byte{10, 0, byte(i >> 8), byte(i)})
+	}
+
+	t.Run("least_recent", func(t *testing.T) {
+		s := newClientsStats()
+		for i := range maxClients {
+			s.record(newIP(i), "dns.google", MatchResolver, start.Add(time.Duration(i)))
+		}
+
+		newcomer := netip.MustParseAddr("192.168.1.2")
+		s.record(newcomer, "dns.google", MatchResolver, start.Add(maxClients))
+
+		assert.Len(t, s.clients, maxClients)
+		assert.Contains(t, s.clients, newcomer)
+		assert.NotContains(t, s.clients, newIP(0))
+		assert.Contains(t, s.clients, newIP(1))
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		s := newClientsStats()
+		for i := range maxClients {
+			s.record(newIP(i), "dns.google", MatchResolver, start)
+		}
+
+		s.record(newIP(0), "dns.google", MatchResolver, start.Add(statsHours*time.Hour))
+
+		newcomer := netip.MustParseAddr("192.168.1.2")
+		s.record(newcomer, "dns.google", MatchResolver, start.Add((statsHours+1)*time.Hour))
+
+		assert.Len(t, s.clients, 2)
+		assert.Contains(t, s.clients, newcomer)
+		assert.Contains(t, s.clients, newIP(0))
+	})
+}
@@ -0,0 +1,85 @@
+package bypass_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetector_Check(t *testing.T) {
+	d, err := bypass.New(&bypass.Config{
+		Enabled: true,
+	})
+	require.NoError(t, err)
+
+	ip := netip.MustParseAddr("192.168.1.2")
+
+	testCases := []struct {
+		name string
+		host string
+		want bypass.Match
+	}{{
+		name: "resolver",
+		host: "dns.google",
+		want: bypass.MatchResolver,
+	}, {
+		name: "resolver_subdomain",
+		host: "abc123.dns.nextdns.io",
+		want: bypass.MatchResolver,
+	}, {
+		name: "canary",
+		host: "use-application-dns.net",
+		want: bypass.MatchCanary,
+	}, {
+		name: "canary_relay",
+		host: "mask.icloud.com",
+		want: bypass.MatchCanary,
+	}, {
+		name: "parent_of_resolver",
+		host: "google",
+		want: bypass.MatchNone,
+	}, {
+		name: "other",
+		host: "example.org",
+		want: bypass.MatchNone,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, d.Check(ip, tc.host))
+		})
+	}
+
+	reports := d.Reports()
+	require.Len(t, reports, 1)
+
+	rep := reports[0]
+	assert.Equal(t, ip, rep.IP)
+	assert.Equal(t, []bypass.Reason{
+		bypass.ReasonResolverLookup,
+		bypass.ReasonCanaryLookup,
+	}, rep.Reasons)
+	assert.Equal(t, uint64(2), rep.ResolverLookups)
+	assert.Equal(t, uint64(2), rep.CanaryLookups)
+	assert.Equal(t, "abc123.dns.nextdns.io", rep.LastResolver)
+}
+
+func TestDetector_Check_disabled(t *testing.T) {
+	d, err := bypass.New(&bypass.Config{
+		Enabled: false,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, bypass.MatchNone, d.Check(netip.MustParseAddr("192.168.1.2"), "dns.google"))
+	assert.Empty(t, d.Reports())
+}
+
+func TestNew_badListURL(t *testing.T) {
+	_, err := bypass.New(&bypass.Config{
+		ListURL: "ftp://example.org/list.txt",
+	})
+	assert.Error(t, err)
+}
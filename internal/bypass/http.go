@@ -0,0 +1,151 @@
+package bypass
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+)
+
+// statusResp is the response to the GET /control/bypass/status HTTP API.
+type statusResp struct {
+	// ListUpdated is the time of the last update of the list of resolvers in
+	// RFC 3339 format.  It's empty if the list has never been updated.
+	ListUpdated string `json:"list_updated,omitempty"`
+
+	// ListURL is the URL of the list of resolvers.
+	ListURL string `json:"list_url"`
+
+	// ResolversNum is the number of the known resolvers' hostnames.
+	ResolversNum int `json:"resolvers_num"`
+
+	// Enabled shows if the bypass prevention is enabled.
+	Enabled bool `json:"enabled"`
+}
+
+// configReq is the request to the PUT /control/bypass/config HTTP API.
+type configReq struct {
+	// ListURL is the URL of the list of resolvers.  It may be empty.
+	ListURL string `json:"list_url"`
+
+	// Enabled shows if the bypass prevention is enabled.  It is an
+	// [aghalg.NullBool] to be able to tell when it's set without using
+	// pointers.
+	Enabled aghalg.NullBool `json:"enabled"`
+}
+
+// clientJSON is the information about a client suspected of the DNS bypass.
+type clientJSON struct {
+	// LastResolverLookup is the time of the last lookup of a resolver's
+	// hostname in RFC 3339 format.  It's empty if there were none.
+	LastResolverLookup string `json:"last_resolver_lookup,omitempty"`
+
+	// LastResolver is the last looked up hostname of a resolver.
+	LastResolver string `json:"last_resolver,omitempty"`
+
+	IP              netip.Addr `json:"ip"`
+	Reasons         []Reason   `json:"reasons"`
+	ResolverLookups uint64     `json:"resolver_lookups"`
+	CanaryLookups   uint64     `json:"canary_lookups"`
+}
+
+// clientsResp is the response to the GET /control/bypass/clients HTTP API.
+type clientsResp struct {
+	Clients []*clientJSON `json:"clients"`
+}
+
+// registerHTTPHandlers registers the HTTP API handlers.
+func (d *Detector) registerHTTPHandlers(reg aghhttp.RegisterFunc) {
+	reg(http.MethodGet, "/control/bypass/status", d.handleStatus)
+	reg(http.MethodPut, "/control/bypass/config", d.handleConfig)
+	reg(http.MethodGet, "/control/bypass/clients", d.handleClients)
+}
+
+// formatTime returns t in RFC 3339 format or an empty string, if t is zero.
+func formatTime(t time.Time) (s string) {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// handleStatus is the handler for the GET /control/bypass/status HTTP API.
+func (d *Detector) handleStatus(w http.ResponseWriter, r *http.Request) {
+	d.confMu.RLock()
+	resp := &statusResp{
+		ListUpdated:  formatTime(d.listUpdated),
+		ListURL:      urlString(d.listURL),
+		ResolversNum: d.resolvers.Load().Len(),
+		Enabled:      d.enabled,
+	}
+	d.confMu.RUnlock()
+
+	aghhttp.WriteJSONResponseOK(w, r, resp)
+}
+
+// handleConfig is the handler for the PUT /control/bypass/config HTTP API.
+func (d *Detector) handleConfig(w http.ResponseWriter, r *http.Request) {
+	req := &configReq{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+		return
+	}
+
+	if req.Enabled == aghalg.NBNull {
+		aghhttp.Error(r, w, http.StatusUnprocessableEntity, "enabled is null")
+
+		return
+	}
+
+	listURL, err := parseListURL(req.ListURL)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusUnprocessableEntity, "%s", err)
+
+		return
+	}
+
+	d.setConfig(req.Enabled == aghalg.NBTrue, listURL)
+	d.configModified()
+}
+
+// handleClients is the handler for the GET /control/bypass/clients HTTP API.
+// The optional "ip" query parameter filters the clients by their address.
+func (d *Detector) handleClients(w http.ResponseWriter, r *http.Request) {
+	var ip netip.Addr
+	if ipStr := r.URL.Query().Get("ip"); ipStr != "" {
+		var err error
+		ip, err = netip.ParseAddr(ipStr)
+		if err != nil {
+			aghhttp.Error(r, w, http.StatusBadRequest, "ip: %s", err)
+
+			return
+		}
+	}
+
+	resp := &clientsResp{
+		Clients: []*clientJSON{},
+	}
+
+	for _, rep := range d.Reports() {
+		if ip.IsValid() && rep.IP != ip {
+			continue
+		}
+
+		resp.Clients = append(resp.Clients, &clientJSON{
+			LastResolverLookup: formatTime(rep.LastResolverLookup),
+			LastResolver:       rep.LastResolver,
+			IP:                 rep.IP,
+			Reasons:            rep.Reasons,
+			ResolverLookups:    rep.ResolverLookups,
+			CanaryLookups:      rep.CanaryLookups,
+		})
+	}
+
+	aghhttp.WriteJSONResponseOK(w, r, resp)
+}
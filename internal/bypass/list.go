@@ -0,0 +1,158 @@
+package bypass
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/container"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+)
+
+// updateIvl is the interval between the updates of the list of resolvers.
+const updateIvl = 24 * time.Hour
+
+// maxListSize is the maximum size of the downloaded list of resolvers.
+const maxListSize = 1024 * 1024
+
+// builtinResolvers are the hostnames of the well-known public DoH and DoT
+// resolvers.  Their subdomains are matched as well.
+var builtinResolvers = []string{
+	"1dot1dot1dot1.cloudflare-dns.com",
+	"8888.google",
+	"adblock.dns.mullvad.net",
+	"chrome.cloudflare-dns.com",
+	"cloudflare-dns.com",
+	"dns.adguard-dns.com",
+	"dns.alidns.com",
+	"dns.cloudflare.com",
+	"dns.controld.com",
+	"dns.google",
+	"dns.google.com",
+	"dns.mullvad.net",
+	"dns.nextdns.io",
+	"dns.quad9.net",
+	"dns.sb",
+	"dns.switch.ch",
+	"dns0.eu",
+	"dns11.quad9.net",
+	"dns9.quad9.net",
+	"doh.cleanbrowsing.org",
+	"doh.dns.sb",
+	"doh.familyshield.opendns.com",
+	"doh.mullvad.net",
+	"doh.opendns.com",
+	"doh.pub",
+	"doh.xfinity.com",
+	"dot.pub",
+	"family.adguard-dns.com",
+	"family.cloudflare-dns.com",
+	"mozilla.cloudflare-dns.com",
+	"one.one.one.one",
+	"security.cloudflare-dns.com",
+	"unfiltered.adguard-dns.com",
+}
+
+// defaultResolvers returns the set of the built-in resolvers' hostnames.
+func defaultResolvers() (set *container.MapSet[string]) {
+	return container.NewMapSet(builtinResolvers...)
+}
+
+// updateLoop updates the list of resolvers periodically until the detector is
+// closed.  It is intended to be used as a goroutine.
+func (d *Detector) updateLoop() {
+	defer log.OnPanic("bypass: updating list")
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		d.confMu.RLock()
+		u, updated := d.listURL, d.listUpdated
+		d.confMu.RUnlock()
+
+		if u != nil && d.now().Sub(updated) >= updateIvl {
+			d.update(u)
+		}
+
+		select {
+		case <-ticker.C:
+			// Go on.
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// update downloads the list of resolvers from u and replaces the current one
+// with it, unless the URL has changed in the meantime.
+func (d *Detector) update(u *url.URL) {
+	hosts, err := d.download(u)
+	if err != nil {
+		log.Error("bypass: updating list: %s", err)
+
+		return
+	}
+
+	set := defaultResolvers()
+	for _, h := range hosts {
+		set.Add(h)
+	}
+
+	d.confMu.Lock()
+	defer d.confMu.Unlock()
+
+	if urlString(d.listURL) != u.String() {
+		log.Debug("bypass: list url changed during update; skipping")
+
+		return
+	}
+
+	d.resolvers.Store(set)
+	d.listUpdated = d.now()
+
+	log.Info("bypass: updated list of resolvers: %d hosts", set.Len())
+}
+
+// download downloads and parses the list of resolvers from u.
+func (d *Detector) download(u *url.URL) (hosts []string, err error) {
+	resp, err := d.httpClient.Get(u.String())
+	if err != nil {
+		return nil, fmt.Errorf("requesting: %w", err)
+	}
+	defer func() { err = errors.WithDeferred(err, resp.Body.Close()) }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	return parseList(io.LimitReader(resp.Body, maxListSize))
+}
+
+// parseList parses the list of resolvers' hostnames from r.  Each non-empty
+// line, which isn't a comment starting with '#', must be a valid domain name.
+func parseList(r io.Reader) (hosts []string, err error) {
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		host := strings.ToLower(strings.TrimSuffix(line, "."))
+		err = netutil.ValidateDomainName(host)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, s.Err()
+}
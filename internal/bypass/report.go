@@ -0,0 +1,248 @@
+package bypass
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// maxClients is the maximum number of the clients tracked for the
+	// detection.  When it's reached, the least recently seen client is
+	// evicted to track a new one.
+	maxClients = 4096
+
+	// statsHours is the number of the hours the queries of the clients are
+	// counted for.
+	statsHours = 24
+
+	// minAvgQueries is the minimum average number of queries per hour the
+	// client should have made, for its drop-off to be considered.
+	minAvgQueries = 10
+
+	// dropOffRatio is the ratio of the number of queries within the last
+	// complete hour to the average, below which the client is considered to
+	// have dropped off.
+	dropOffRatio = 0.1
+)
+
+// Reason is the reason the client is suspected of the DNS bypass.
+type Reason string
+
+// Reason values.
+const (
+	// ReasonResolverLookup means that the client has looked up the hostname of
+	// a public DNS resolver.
+	ReasonResolverLookup Reason = "resolver_lookup"
+
+	// ReasonCanaryLookup means that the client has looked up a canary domain.
+	ReasonCanaryLookup Reason = "canary_lookup"
+
+	// ReasonQueryDropOff means that the number of the queries from the client
+	// has suddenly dropped.
+	ReasonQueryDropOff Reason = "query_drop_off"
+)
+
+// Report is the information about a client suspected of the DNS bypass.
+type Report struct {
+	// LastResolverLookup is the time of the last lookup of a resolver's
+	// hostname.  It's zero if there were none.
+	LastResolverLookup time.Time
+
+	// IP is the address of the client.
+	IP netip.Addr
+
+	// LastResolver is the last looked up hostname of a resolver.
+	LastResolver string
+
+	// Reasons are the reasons the client is suspected.  It's never empty.
+	Reasons []Reason
+
+	// ResolverLookups is the number of lookups of the resolvers' hostnames.
+	ResolverLookups uint64
+
+	// CanaryLookups is the number of lookups of the canary domains.
+	CanaryLookups uint64
+}
+
+// clientStats is the statistics of a single client.
+type clientStats struct {
+	// lastSeen is the time of the last recorded query.
+	lastSeen time.Time
+
+	lastResolverLookup time.Time
+	lastResolver       string
+
+	// hourly is the ring of the numbers of queries per hour.  The index of the
+	// counter for a Unix hour h is h % statsHours.
+	hourly [statsHours]uint64
+
+	// lastHour is the Unix hour of the last recorded query.
+	lastHour int64
+
+	resolverLookups uint64
+	canaryLookups   uint64
+}
+
+// clientsStats is the statistics of the clients.
+type clientsStats struct {
+	// mu protects clients.
+	mu      *sync.Mutex
+	clients map[netip.Addr]*clientStats
+}
+
+// newClientsStats returns a new properly initialized *clientsStats.
+func newClientsStats() (s *clientsStats) {
+	return &clientsStats{
+		mu:      &sync.Mutex{},
+		clients: map[netip.Addr]*clientStats{},
+	}
+}
+
+// unixHour returns the number of hours since the Unix epoch at t.
+func unixHour(t time.Time) (h int64) {
+	return t.Unix() / int64(time.Hour/time.Second)
+}
+
+// record records the query for host from the client with ip at now.
+func (s *clientsStats) record(ip netip.Addr, host string, m Match, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cs, ok := s.clients[ip]
+	if !ok {
+		if len(s.clients) >= maxClients {
+			s.evict(now)
+		}
+
+		cs = &clientStats{}
+		s.clients[ip] = cs
+	}
+
+	cs.lastSeen = now
+
+	hour := unixHour(now)
+	cs.advance(hour)
+	cs.hourly[hour%statsHours]++
+
+	switch m {
+	case MatchResolver:
+		cs.resolverLookups++
+		cs.lastResolver = host
+		cs.lastResolverLookup = now
+	case MatchCanary:
+		cs.canaryLookups++
+	default:
+		// Go on.
+	}
+}
+
+// evict removes the clients which haven't been seen within the statistics
+// period before now.  If there are none, it removes the least recently seen
+// client.  s.mu is expected to be locked.
+func (s *clientsStats) evict(now time.Time) {
+	staleBefore := now.Add(-statsHours * time.Hour)
+
+	var oldestIP netip.Addr
+	var oldest time.Time
+	for ip, cs := range s.clients {
+		if cs.lastSeen.Before(staleBefore) {
+			delete(s.clients, ip)
+		} else if oldest.IsZero() || cs.lastSeen.Before(oldest) {
+			oldestIP, oldest = ip, cs.lastSeen
+		}
+	}
+
+	if len(s.clients) >= maxClients {
+		delete(s.clients, oldestIP)
+	}
+}
+
+// advance resets the counters of the hours between the last recorded one and
+// hour.
+func (cs *clientStats) advance(hour int64) {
+	if hour <= cs.lastHour {
+		return
+	}
+
+	for h := cs.lastHour + 1; h <= hour && h <= cs.lastHour+statsHours; h++ {
+		cs.hourly[h%statsHours] = 0
+	}
+
+	cs.lastHour = hour
+}
+
+// isDroppedOff returns true if the number of queries within the last complete
+// hour before now is much less than the average of the preceding ones.  A
+// client which stopped querying at all is considered to have gone offline
+// rather than to bypass AdGuard Home.
+func (cs *clientStats) isDroppedOff(now time.Time) (ok bool) {
+	hour := unixHour(now)
+
+	// Ignore the current hour, since it's incomplete, and require the client
+	// to be active within the previous one.
+	last := hour - 1
+	if cs.lastHour < last || cs.lastHour > hour {
+		return false
+	}
+
+	var sum uint64
+	for h := hour - statsHours + 1; h < last; h++ {
+		sum += cs.hourly[h%statsHours]
+	}
+
+	avg := float64(sum) / float64(statsHours-2)
+	if avg < minAvgQueries {
+		return false
+	}
+
+	n := cs.hourly[last%statsHours]
+
+	return n > 0 && float64(n) < avg*dropOffRatio
+}
+
+// reports returns the reports about the suspected clients at now sorted by
+// their addresses.
+func (s *clientsStats) reports(now time.Time) (reports []*Report) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ip, cs := range s.clients {
+		var reasons []Reason
+		if cs.resolverLookups > 0 {
+			reasons = append(reasons, ReasonResolverLookup)
+		}
+
+		if cs.canaryLookups > 0 {
+			reasons = append(reasons, ReasonCanaryLookup)
+		}
+
+		if cs.isDroppedOff(now) {
+			reasons = append(reasons, ReasonQueryDropOff)
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		reports = append(reports, &Report{
+			LastResolverLookup: cs.lastResolverLookup,
+			IP:                 ip,
+			LastResolver:       cs.lastResolver,
+			Reasons:            reasons,
+			ResolverLookups:    cs.resolverLookups,
+			CanaryLookups:      cs.canaryLookups,
+		})
+	}
+
+	slices.SortFunc(reports, func(a, b *Report) (res int) { return a.IP.Compare(b.IP) })
+
+	return reports
+}
+
+// Reports returns the reports about the clients suspected of the DNS bypass
+// sorted by their addresses.
+func (d *Detector) Reports() (reports []*Report) {
+	return d.clients.reports(d.now())
+}
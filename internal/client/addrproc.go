@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
 	"github.com/AdguardTeam/AdGuardHome/internal/rdns"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/AdguardTeam/golibs/errors"
@@ -54,6 +55,10 @@ type DefaultAddrProcConfig struct {
 	// address.  It must not be nil.
 	AddressUpdater AddressUpdater
 
+	// Tracer records the spans of the address processing.  If it's nil,
+	// nothing is recorded.
+	Tracer *aghtrace.Tracer
+
 	// InitialAddresses are the addresses that are queued for processing
 	// immediately by [NewDefaultAddrProc].
 	InitialAddresses []netip.Addr
@@ -110,6 +115,9 @@ type DefaultAddrProc struct {
 	// private.
 	privateSubnets netutil.SubnetSet
 
+	// tracer records the spans of the address processing.  It may be nil.
+	tracer *aghtrace.Tracer
+
 	// isClosed is set to true once the address processor is closed.
 	isClosed bool
 
@@ -142,6 +150,7 @@ func NewDefaultAddrProc(c *DefaultAddrProcConfig) (p *DefaultAddrProc) {
 		addrUpdater:    c.AddressUpdater,
 		whois:          &whois.Empty{},
 		privateSubnets: c.PrivateSubnets,
+		tracer:         c.Tracer,
 		usePrivateRDNS: c.UsePrivateRDNS,
 	}
 
@@ -228,10 +237,18 @@ func (p *DefaultAddrProc) process(catchPanics bool) {
 	log.Info("clients: processing addresses")
 
 	for ip := range p.clientIPs {
-		host := p.processRDNS(ip)
-		info := p.processWHOIS(ip)
+		ctx, span := p.tracer.Start(
+			context.Background(),
+			"clients.process_address",
+			aghtrace.String("client.address", ip.String()),
+		)
+
+		host := p.processRDNS(ctx, ip)
+		info := p.processWHOIS(ctx, ip)
 
 		p.addrUpdater.UpdateAddress(ip, host, info)
+
+		span.End()
 	}
 
 	log.Info("clients: finished processing addresses")
@@ -239,7 +256,7 @@ func (p *DefaultAddrProc) process(catchPanics bool) {
 
 // processRDNS resolves the clients' IP addresses using reverse DNS.  host is
 // empty if there were errors or if the information hasn't changed.
-func (p *DefaultAddrProc) processRDNS(ip netip.Addr) (host string) {
+func (p *DefaultAddrProc) processRDNS(ctx context.Context, ip netip.Addr) (host string) {
 	start := time.Now()
 	log.Debug("clients: processing %s with rdns", ip)
 	defer func() {
@@ -251,7 +268,11 @@ func (p *DefaultAddrProc) processRDNS(ip netip.Addr) (host string) {
 		return
 	}
 
+	_, span := p.tracer.Start(ctx, "clients.rdns")
+	defer span.End()
+
 	host, changed := p.rdns.Process(ip)
+	span.SetAttrs(aghtrace.Bool("clients.changed", changed))
 	if !changed {
 		host = ""
 	}
@@ -268,16 +289,20 @@ func (p *DefaultAddrProc) shouldResolve(ip netip.Addr) (ok bool) {
 // processWHOIS looks up the information about clients' IP addresses in the
 // WHOIS databases.  info is nil if there were errors or if the information
 // hasn't changed.
-func (p *DefaultAddrProc) processWHOIS(ip netip.Addr) (info *whois.Info) {
+func (p *DefaultAddrProc) processWHOIS(ctx context.Context, ip netip.Addr) (info *whois.Info) {
 	start := time.Now()
 	log.Debug("clients: processing %s with whois", ip)
 	defer func() {
 		log.Debug("clients: finished processing %s with whois in %s", ip, time.Since(start))
 	}()
 
+	_, span := p.tracer.Start(ctx, "clients.whois")
+	defer span.End()
+
 	// TODO(s.chzhen):  Move the timeout logic from WHOIS configuration to the
 	// context.
 	info, changed := p.whois.Process(context.Background(), ip)
+	span.SetAttrs(aghtrace.Bool("clients.changed", changed))
 	if !changed {
 		info = nil
 	}
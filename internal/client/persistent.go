@@ -3,6 +3,7 @@ package client
 import (
 	"encoding"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"slices"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/safesearch"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/golibs/container"
 	"github.com/AdguardTeam/golibs/errors"
@@ -71,6 +73,14 @@ type Persistent struct {
 	// BlockedServices is the configuration of blocked services of a client.
 	BlockedServices *filtering.BlockedServices
 
+	// GeoIP is the GeoIP filtering policy of the client.  If nil, the global
+	// one is used.
+	GeoIP *geoip.Policy
+
+	// BlockingMode is the blocking mode of the client.  If empty, the one of
+	// the client's tags, of the filter list, or the global one is used.
+	BlockingMode filtering.BlockingMode
+
 	Name string
 
 	Tags      []string
@@ -95,6 +105,15 @@ type Persistent struct {
 	UseOwnBlockedServices bool
 	IgnoreQueryLog        bool
 	IgnoreStatistics      bool
+
+	// IgnoreAnomalyDetection defines if the requests of the client are exempt
+	// from the detection of the DNS tunneling and the algorithmically generated
+	// domains.
+	IgnoreAnomalyDetection bool
+
+	// UseDNS64 defines if DNS64 is enabled for the client regardless of the
+	// global setting.
+	UseDNS64 bool
 }
 
 // SetTags sets the tags if they are known, otherwise logs an unknown tag.
@@ -268,12 +287,20 @@ func (c *Persistent) CloseUpstreams() (err error) {
 }
 
 // SetSafeSearch initializes and sets the safe search filter for this client.
+// logger is used for logging the operation of the filter.
 func (c *Persistent) SetSafeSearch(
+	logger *slog.Logger,
 	conf filtering.SafeSearchConfig,
 	cacheSize uint,
 	cacheTTL time.Duration,
 ) (err error) {
-	ss, err := safesearch.NewDefault(conf, fmt.Sprintf("client %q", c.Name), cacheSize, cacheTTL)
+	ss, err := safesearch.NewDefault(
+		logger,
+		conf,
+		fmt.Sprintf("client %q", c.Name),
+		cacheSize,
+		cacheTTL,
+	)
 	if err != nil {
 		// Don't wrap the error, because it's informative enough as is.
 		return err
@@ -3,3 +3,7 @@ package configmigrate
 
 // LastSchemaVersion is the most recent schema version.
 const LastSchemaVersion uint = 28
+
+// FirstDowngradableVersion is the oldest schema version the configuration file
+// can be downgraded to.  See [Migrator.Downgrade].
+const FirstDowngradableVersion uint = 23
@@ -0,0 +1,184 @@
+package configmigrate
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines printed around each change.
+const diffContext = 3
+
+// diffOp is the kind of a line in the diff.
+type diffOp byte
+
+// diffOp values.  These are also the prefixes of the lines in the unified diff
+// format.
+const (
+	diffOpEqual  diffOp = ' '
+	diffOpDelete diffOp = '-'
+	diffOpInsert diffOp = '+'
+)
+
+// diffLine is a single line of the diff.
+type diffLine struct {
+	text string
+	op   diffOp
+}
+
+// yamlDiff returns the difference between oldBody and newBody in the unified
+// diff format with oldName and newName as the names of the compared files.
+// diff is empty if the bodies are equal.
+func yamlDiff(oldName, newName string, oldBody, newBody []byte) (diff string) {
+	if bytes.Equal(oldBody, newBody) {
+		return ""
+	}
+
+	lines := diffLines(splitLines(oldBody), splitLines(newBody))
+
+	b := &strings.Builder{}
+	_, _ = fmt.Fprintf(b, "--- %s\n+++ %s\n", oldName, newName)
+	writeHunks(b, lines)
+
+	return b.String()
+}
+
+// splitLines splits body into lines without the trailing newlines.
+func splitLines(body []byte) (lines []string) {
+	s := strings.TrimSuffix(string(body), "\n")
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(s, "\n")
+}
+
+// diffLines returns the shortest edit script transforming a into b using the
+// longest common subsequence of lines.
+func diffLines(a, b []string) (lines []diffLine) {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and
+	// b[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{text: a[i], op: diffOpEqual})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{text: a[i], op: diffOpDelete})
+			i++
+		default:
+			lines = append(lines, diffLine{text: b[j], op: diffOpInsert})
+			j++
+		}
+	}
+
+	for ; i < n; i++ {
+		lines = append(lines, diffLine{text: a[i], op: diffOpDelete})
+	}
+
+	for ; j < m; j++ {
+		lines = append(lines, diffLine{text: b[j], op: diffOpInsert})
+	}
+
+	return lines
+}
+
+// writeHunks writes the changed lines along with [diffContext] unchanged lines
+// around them to b, grouped into hunks.
+func writeHunks(b *strings.Builder, lines []diffLine) {
+	// oldNums[k] and newNums[k] are the numbers of the lines of the old and
+	// the new body, respectively, preceding lines[k].
+	oldNums := make([]int, len(lines)+1)
+	newNums := make([]int, len(lines)+1)
+	for k, l := range lines {
+		oldNums[k+1], newNums[k+1] = oldNums[k], newNums[k]
+		if l.op != diffOpInsert {
+			oldNums[k+1]++
+		}
+
+		if l.op != diffOpDelete {
+			newNums[k+1]++
+		}
+	}
+
+	for k := 0; k < len(lines); {
+		for k < len(lines) && lines[k].op == diffOpEqual {
+			k++
+		}
+
+		if k == len(lines) {
+			break
+		}
+
+		start := max(k-diffContext, 0)
+		end := hunkEnd(lines, k)
+
+		oldStart, oldLen := hunkRange(oldNums[start], oldNums[end])
+		newStart, newLen := hunkRange(newNums[start], newNums[end])
+		_, _ = fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+
+		for _, l := range lines[start:end] {
+			_, _ = fmt.Fprintf(b, "%c%s\n", l.op, l.text)
+		}
+
+		k = end
+	}
+}
+
+// hunkEnd returns the index of the line following the hunk which contains the
+// changed line at index k.  Changes separated by no more than twice the
+// [diffContext] unchanged lines are merged into the same hunk.
+func hunkEnd(lines []diffLine, k int) (end int) {
+	end = k
+	for end < len(lines) {
+		if lines[end].op != diffOpEqual {
+			end++
+
+			continue
+		}
+
+		run := 0
+		for end+run < len(lines) && lines[end+run].op == diffOpEqual {
+			run++
+		}
+
+		if end+run == len(lines) || run > 2*diffContext {
+			return min(end+diffContext, len(lines))
+		}
+
+		end += run
+	}
+
+	return end
+}
+
+// hunkRange returns the first line number and the number of lines of a hunk
+// spanning the lines from before, exclusive, to after, inclusive, in the
+// unified diff format.
+func hunkRange(before, after int) (start, n int) {
+	n = after - before
+	if n == 0 {
+		return before, 0
+	}
+
+	return before + 1, n
+}
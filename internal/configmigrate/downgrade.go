@@ -0,0 +1,54 @@
+package configmigrate
+
+import (
+	"fmt"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// validateDowngradeVersion validates the current and desired schema versions
+// for a downgrade.
+func validateDowngradeVersion(current, target uint) (err error) {
+	switch {
+	case current > LastSchemaVersion:
+		return fmt.Errorf("unknown current schema version %d", current)
+	case target > current:
+		return fmt.Errorf("target schema version %d higher than current %d", target, current)
+	case target < FirstDowngradableVersion:
+		return fmt.Errorf(
+			"target schema version %d lower than first downgradable %d",
+			target,
+			FirstDowngradableVersion,
+		)
+	default:
+		return nil
+	}
+}
+
+// downgradeConfigSchema downgrades the configuration schema in diskConf from
+// current to target version.  current must be greater than target, and both
+// must be within the range from [FirstDowngradableVersion] to
+// [LastSchemaVersion].
+func downgradeConfigSchema(current, target uint, diskConf yobj) (err error) {
+	// downgrades are indexed by the version being downgraded from, with the
+	// first downgradable version as zero.
+	downgrades := [LastSchemaVersion - FirstDowngradableVersion]migrateFunc{
+		0: downgradeTo23,
+		1: downgradeTo24,
+		2: downgradeTo25,
+		3: downgradeTo26,
+		4: downgradeTo27,
+	}
+
+	for cur := current; cur > target; cur-- {
+		prev := cur - 1
+
+		log.Printf("Downgrade yaml: %d to %d", cur, prev)
+
+		if err = downgrades[prev-FirstDowngradableVersion](diskConf); err != nil {
+			return fmt.Errorf("migrating schema %d to %d: %w", cur, prev, err)
+		}
+	}
+
+	return nil
+}
@@ -18,6 +18,10 @@ type Config struct {
 type Migrator struct {
 	// workingDir is an absolute path to the working directory of AdGuardHome.
 	workingDir string
+
+	// dryRun, if true, makes the migrations not to touch any files besides the
+	// configuration.
+	dryRun bool
 }
 
 // New creates a new Migrator.
@@ -32,42 +36,154 @@ func New(cfg *Config) (m *Migrator) {
 // whether the file was upgraded, and an error, if any.  If upgraded is false,
 // the body is the same as the input.
 func (m *Migrator) Migrate(body []byte, target uint) (newBody []byte, upgraded bool, err error) {
-	diskConf := yobj{}
-	err = yaml.Unmarshal(body, &diskConf)
+	diskConf, current, err := parseConf(body)
 	if err != nil {
-		return body, false, fmt.Errorf("parsing config file for upgrade: %w", err)
+		// Don't wrap the error, since it's informative enough as is.
+		return body, false, err
 	}
 
-	currentInt, _, err := fieldVal[int](diskConf, "schema_version")
+	if err = validateVersion(current, target); err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return body, false, err
+	} else if current == target {
+		return body, false, nil
+	}
+
+	if err = m.upgradeConfigSchema(current, target, diskConf); err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return body, false, err
+	}
+
+	newBody, err = encodeConf(diskConf)
 	if err != nil {
 		// Don't wrap the error, since it's informative enough as is.
 		return body, false, err
 	}
 
-	current := uint(currentInt)
-	log.Debug("got schema version %v", current)
+	return newBody, true, nil
+}
 
-	if err = validateVersion(current, target); err != nil {
+// Downgrade performs necessary reverse migrations to convert file to the older
+// target schema version, so that it could be used by an older version of
+// AdGuard Home.  target must not be less than [FirstDowngradableVersion].  It
+// returns the body of the downgraded config file, whether the file was
+// downgraded, and an error, if any.  If downgraded is false, the body is the
+// same as the input.
+func (m *Migrator) Downgrade(body []byte, target uint) (newBody []byte, downgraded bool, err error) {
+	diskConf, current, err := parseConf(body)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return body, false, err
+	}
+
+	if err = validateDowngradeVersion(current, target); err != nil {
 		// Don't wrap the error, since it's informative enough as is.
 		return body, false, err
 	} else if current == target {
 		return body, false, nil
 	}
 
-	if err = m.upgradeConfigSchema(current, target, diskConf); err != nil {
+	if err = downgradeConfigSchema(current, target, diskConf); err != nil {
 		// Don't wrap the error, since it's informative enough as is.
 		return body, false, err
 	}
 
-	buf := bytes.NewBuffer(newBody)
+	newBody, err = encodeConf(diskConf)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return body, false, err
+	}
+
+	return newBody, true, nil
+}
+
+// DryRun returns the difference between the file and its version migrated to
+// target schema version in the unified diff format without applying any
+// changes, including the removal of the obsolete files.  target may be either
+// greater or less than the current schema version.  diff is empty if there are
+// no changes.
+//
+// Both versions are encoded the same way before comparison, so the difference
+// in formatting, key order, and comments of the original file isn't reported.
+func (m *Migrator) DryRun(body []byte, target uint) (diff string, err error) {
+	diskConf, current, err := parseConf(body)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	oldBody, err := encodeConf(diskConf)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	if target < current {
+		err = validateDowngradeVersion(current, target)
+		if err == nil {
+			err = downgradeConfigSchema(current, target, diskConf)
+		}
+	} else {
+		err = validateVersion(current, target)
+		if err == nil {
+			dryRunner := &Migrator{
+				workingDir: m.workingDir,
+				dryRun:     true,
+			}
+			err = dryRunner.upgradeConfigSchema(current, target, diskConf)
+		}
+	}
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	newBody, err := encodeConf(diskConf)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	return yamlDiff(
+		fmt.Sprintf("schema_version %d", current),
+		fmt.Sprintf("schema_version %d", target),
+		oldBody,
+		newBody,
+	), nil
+}
+
+// parseConf parses the configuration file body and returns its schema
+// version.
+func parseConf(body []byte) (diskConf yobj, current uint, err error) {
+	diskConf = yobj{}
+	err = yaml.Unmarshal(body, &diskConf)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parsing config file for upgrade: %w", err)
+	}
+
+	currentInt, _, err := fieldVal[int](diskConf, "schema_version")
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, 0, err
+	}
+
+	current = uint(currentInt)
+	log.Debug("got schema version %v", current)
+
+	return diskConf, current, nil
+}
+
+// encodeConf encodes diskConf into YAML.
+func encodeConf(diskConf yobj) (body []byte, err error) {
+	buf := &bytes.Buffer{}
 	enc := yaml.NewEncoder(buf)
 	enc.SetIndent(2)
 
 	if err = enc.Encode(diskConf); err != nil {
-		return body, false, fmt.Errorf("generating new config: %w", err)
+		return nil, fmt.Errorf("generating new config: %w", err)
 	}
 
-	return buf.Bytes(), true, nil
+	return buf.Bytes(), nil
 }
 
 // validateVersion validates the current and desired schema versions.
@@ -8,6 +8,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/configmigrate"
 	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/bcrypt"
 	yaml "gopkg.in/yaml.v3"
@@ -211,3 +212,164 @@ func TestMigrateConfig_Migrate(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrator_Downgrade(t *testing.T) {
+	const lastConf = `
+schema_version: 28
+http:
+  address: 0.0.0.0:3000
+  pprof:
+    enabled: true
+    port: 6060
+dns:
+  bind_hosts:
+  - 127.0.0.1
+  upstream_mode: parallel
+filtering:
+  filtering_enabled: true
+  protection_enabled: true
+querylog:
+  ignored:
+  - '|.^'
+  - example.com
+statistics:
+  ignored:
+  - '|.^'
+log:
+  file: ""
+  max_backups: 0
+  max_size: 100
+  max_age: 3
+  compress: false
+  local_time: false
+  verbose: false
+`
+
+	const firstDowngradableConf = `
+schema_version: 23
+http:
+  address: 0.0.0.0:3000
+debug_pprof: true
+dns:
+  bind_hosts:
+  - 127.0.0.1
+  all_servers: true
+  fastest_addr: false
+  filtering_enabled: true
+  protection_enabled: true
+querylog:
+  ignored:
+  - '.'
+  - example.com
+statistics:
+  ignored:
+  - '.'
+log_file: ""
+log_max_backups: 0
+log_max_size: 100
+log_max_age: 3
+log_compress: false
+log_localtime: false
+verbose: false
+`
+
+	migrator := configmigrate.New(&configmigrate.Config{
+		WorkingDir: t.TempDir(),
+	})
+
+	t.Run("round_trip", func(t *testing.T) {
+		oldBody, downgraded, err := migrator.Downgrade(
+			[]byte(lastConf),
+			configmigrate.FirstDowngradableVersion,
+		)
+		require.NoError(t, err)
+		require.True(t, downgraded)
+
+		require.YAMLEq(t, firstDowngradableConf, string(oldBody))
+
+		newBody, upgraded, err := migrator.Migrate(oldBody, configmigrate.LastSchemaVersion)
+		require.NoError(t, err)
+		require.True(t, upgraded)
+
+		require.YAMLEq(t, lastConf, string(newBody))
+	})
+
+	t.Run("same_version", func(t *testing.T) {
+		body, downgraded, err := migrator.Downgrade(
+			[]byte(lastConf),
+			configmigrate.LastSchemaVersion,
+		)
+		require.NoError(t, err)
+		require.False(t, downgraded)
+
+		require.Equal(t, lastConf, string(body))
+	})
+
+	t.Run("too_old", func(t *testing.T) {
+		_, _, err := migrator.Downgrade(
+			[]byte(lastConf),
+			configmigrate.FirstDowngradableVersion-1,
+		)
+		testutil.AssertErrorMsg(t, "target schema version 22 lower than first downgradable 23", err)
+	})
+
+	t.Run("newer", func(t *testing.T) {
+		_, _, err := migrator.Downgrade([]byte(firstDowngradableConf), 24)
+		testutil.AssertErrorMsg(t, "target schema version 24 higher than current 23", err)
+	})
+}
+
+func TestMigrator_DryRun(t *testing.T) {
+	const conf = `
+schema_version: 27
+dns:
+  all_servers: false
+  fastest_addr: true
+`
+
+	migrator := configmigrate.New(&configmigrate.Config{
+		WorkingDir: t.TempDir(),
+	})
+
+	testCases := []struct {
+		name     string
+		wantDiff string
+		target   uint
+	}{{
+		name: "upgrade",
+		wantDiff: "--- schema_version 27\n" +
+			"+++ schema_version 28\n" +
+			"@@ -1,4 +1,3 @@\n" +
+			" dns:\n" +
+			"-  all_servers: false\n" +
+			"-  fastest_addr: true\n" +
+			"-schema_version: 27\n" +
+			"+  upstream_mode: fastest_addr\n" +
+			"+schema_version: 28\n",
+		target: 28,
+	}, {
+		name: "downgrade",
+		wantDiff: "--- schema_version 27\n" +
+			"+++ schema_version 26\n" +
+			"@@ -1,4 +1,4 @@\n" +
+			" dns:\n" +
+			"   all_servers: false\n" +
+			"   fastest_addr: true\n" +
+			"-schema_version: 27\n" +
+			"+schema_version: 26\n",
+		target: 26,
+	}, {
+		name:     "same",
+		wantDiff: "",
+		target:   27,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := migrator.DryRun([]byte(conf), tc.target)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantDiff, diff)
+		})
+	}
+}
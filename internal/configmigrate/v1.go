@@ -23,12 +23,16 @@ func (m *Migrator) migrateTo1(diskConf yobj) (err error) {
 	diskConf["schema_version"] = 1
 
 	dnsFilterPath := filepath.Join(m.workingDir, "dnsfilter.txt")
-	log.Printf("deleting %s as we don't need it anymore", dnsFilterPath)
-	err = os.Remove(dnsFilterPath)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Info("warning: %s", err)
+	if m.dryRun {
+		log.Printf("dry run: not deleting %s", dnsFilterPath)
+	} else {
+		log.Printf("deleting %s as we don't need it anymore", dnsFilterPath)
+		err = os.Remove(dnsFilterPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Info("warning: %s", err)
 
-		// Go on.
+			// Go on.
+		}
 	}
 
 	return nil
@@ -25,12 +25,16 @@ func (m *Migrator) migrateTo2(diskConf yobj) (err error) {
 	diskConf["schema_version"] = 2
 
 	coreFilePath := filepath.Join(m.workingDir, "Corefile")
-	log.Printf("deleting %s as we don't need it anymore", coreFilePath)
-	err = os.Remove(coreFilePath)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Info("warning: %s", err)
+	if m.dryRun {
+		log.Printf("dry run: not deleting %s", coreFilePath)
+	} else {
+		log.Printf("deleting %s as we don't need it anymore", coreFilePath)
+		err = os.Remove(coreFilePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Info("warning: %s", err)
 
-		// Go on.
+			// Go on.
+		}
 	}
 
 	return moveVal[any](diskConf, diskConf, "coredns", "dns")
@@ -50,3 +50,56 @@ func migrateTo24(diskConf yobj) (err error) {
 
 	return nil
 }
+
+// downgradeTo23 performs the following changes:
+//
+//	# BEFORE:
+//	'schema_version': 24
+//	'log':
+//	  'file': ""
+//	  'max_backups': 0
+//	  'max_size': 100
+//	  'max_age': 3
+//	  'compress': false
+//	  'local_time': false
+//	  'verbose': false
+//	# …
+//
+//	# AFTER:
+//	'schema_version': 23
+//	'log_file': ""
+//	'log_max_backups': 0
+//	'log_max_size': 100
+//	'log_max_age': 3
+//	'log_compress': false
+//	'log_localtime': false
+//	'verbose': false
+//	# …
+//
+// It reverts [migrateTo24].
+func downgradeTo23(diskConf yobj) (err error) {
+	diskConf["schema_version"] = 23
+
+	logObj, ok, err := fieldVal[yobj](diskConf, "log")
+	if !ok {
+		return err
+	}
+
+	err = errors.Join(
+		moveVal[string](logObj, diskConf, "file", "log_file"),
+		moveVal[int](logObj, diskConf, "max_backups", "log_max_backups"),
+		moveVal[int](logObj, diskConf, "max_size", "log_max_size"),
+		moveVal[int](logObj, diskConf, "max_age", "log_max_age"),
+		moveVal[bool](logObj, diskConf, "compress", "log_compress"),
+		moveVal[bool](logObj, diskConf, "local_time", "log_localtime"),
+		moveVal[bool](logObj, diskConf, "verbose", "verbose"),
+	)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	delete(diskConf, "log")
+
+	return nil
+}
@@ -36,3 +36,42 @@ func migrateTo25(diskConf yobj) (err error) {
 
 	return nil
 }
+
+// downgradeTo24 performs the following changes:
+//
+//	# BEFORE:
+//	'schema_version': 25
+//	'http':
+//	  'pprof':
+//	    'enabled': true
+//	    'port': 6060
+//	# …
+//
+//	# AFTER:
+//	'schema_version': 24
+//	'debug_pprof': true
+//	# …
+//
+// It reverts [migrateTo25].  Note that the custom pprof port is lost.
+func downgradeTo24(diskConf yobj) (err error) {
+	diskConf["schema_version"] = 24
+
+	httpObj, ok, err := fieldVal[yobj](diskConf, "http")
+	if !ok {
+		return err
+	}
+
+	pprofObj, ok, err := fieldVal[yobj](httpObj, "pprof")
+	if !ok {
+		return err
+	}
+
+	err = moveVal[bool](pprofObj, diskConf, "enabled", "debug_pprof")
+	if err != nil {
+		return err
+	}
+
+	delete(httpObj, "pprof")
+
+	return nil
+}
@@ -111,3 +111,49 @@ func migrateTo26(diskConf yobj) (err error) {
 
 	return nil
 }
+
+// downgradeTo25 performs the following changes:
+//
+//	# BEFORE:
+//	'schema_version': 26
+//	'filtering':
+//	  'filtering_enabled': true
+//	  'filters_update_interval': 24
+//	  # …
+//	'dns'
+//	  # …
+//	# …
+//
+//	# AFTER:
+//	'schema_version': 25
+//	'dns':
+//	  'filtering_enabled': true
+//	  'filters_update_interval': 24
+//	  # …
+//	# …
+//
+// It reverts [migrateTo26].
+func downgradeTo25(diskConf yobj) (err error) {
+	diskConf["schema_version"] = 25
+
+	filteringObj, ok, err := fieldVal[yobj](diskConf, "filtering")
+	if !ok {
+		return err
+	}
+
+	dns, ok, err := fieldVal[yobj](diskConf, "dns")
+	if err != nil {
+		return err
+	} else if !ok {
+		dns = yobj{}
+		diskConf["dns"] = dns
+	}
+
+	for k, v := range filteringObj {
+		dns[k] = v
+	}
+
+	delete(diskConf, "filtering")
+
+	return nil
+}
@@ -32,7 +32,7 @@ func migrateTo27(diskConf yobj) (err error) {
 
 	keys := []string{"querylog", "statistics"}
 	for _, k := range keys {
-		err = replaceDot(diskConf, k)
+		err = replaceIgnored(diskConf, k, ".", "|.^")
 		if err != nil {
 			return err
 		}
@@ -41,9 +41,53 @@ func migrateTo27(diskConf yobj) (err error) {
 	return nil
 }
 
-// replaceDot replaces rules blocking root domain "." with AdBlock style syntax
-// "|.^".
-func replaceDot(diskConf yobj, key string) (err error) {
+// downgradeTo26 performs the following changes:
+//
+//	# BEFORE:
+//	'querylog':
+//	  'ignored':
+//	  - '|.^'
+//	  - # …
+//	  # …
+//	'statistics':
+//	  'ignored':
+//	  - '|.^'
+//	  - # …
+//	  # …
+//	# …
+//
+//	# AFTER:
+//	'querylog':
+//	  'ignored':
+//	  - '.'
+//	  - # …
+//	  # …
+//	'statistics':
+//	  'ignored':
+//	  - '.'
+//	  - # …
+//	  # …
+//	# …
+//
+// It reverts [migrateTo27].
+func downgradeTo26(diskConf yobj) (err error) {
+	diskConf["schema_version"] = 26
+
+	keys := []string{"querylog", "statistics"}
+	for _, k := range keys {
+		err = replaceIgnored(diskConf, k, "|.^", ".")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceIgnored replaces the ignored domain rules equal to from with to.  It's
+// used to convert the rules blocking root domain "." into AdBlock style syntax
+// "|.^" and back.
+func replaceIgnored(diskConf yobj, key, from, to string) (err error) {
 	var obj yobj
 	var ok bool
 	obj, ok, err = fieldVal[yobj](diskConf, key)
@@ -68,8 +112,8 @@ func replaceDot(diskConf yobj, key string) (err error) {
 			continue
 		}
 
-		if host == "." {
-			ignored[i] = "|.^"
+		if host == from {
+			ignored[i] = to
 		}
 	}
 
@@ -45,3 +45,40 @@ func migrateTo28(diskConf yobj) (err error) {
 
 	return nil
 }
+
+// downgradeTo27 performs the following changes:
+//
+//	# BEFORE:
+//	'dns':
+//	  'upstream_mode': 'parallel'
+//	  # …
+//	# …
+//
+//	# AFTER:
+//	'dns':
+//	  'all_servers': true
+//	  'fastest_addr': false
+//	  # …
+//	# …
+//
+// It reverts [migrateTo28].
+func downgradeTo27(diskConf yobj) (err error) {
+	diskConf["schema_version"] = 27
+
+	dns, ok, err := fieldVal[yobj](diskConf, "dns")
+	if !ok {
+		return err
+	}
+
+	upstreamMode, _, err := fieldVal[string](dns, "upstream_mode")
+	if err != nil {
+		return err
+	}
+
+	dns["all_servers"] = upstreamMode == string(dnsforward.UpstreamModeParallel)
+	dns["fastest_addr"] = upstreamMode == string(dnsforward.UpstreamModeFastestAddr)
+
+	delete(dns, "upstream_mode")
+
+	return nil
+}
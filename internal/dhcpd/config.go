@@ -2,12 +2,14 @@ package dhcpd
 
 import (
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
 	"github.com/AdguardTeam/golibs/errors"
 )
@@ -43,6 +45,14 @@ type ServerConfig struct {
 	// DataDir is used to store DHCP leases.
 	DataDir string `yaml:"-"`
 
+	// Tracer records the spans of the DHCP packets processing.  If it's nil,
+	// nothing is recorded.
+	Tracer *aghtrace.Tracer `yaml:"-"`
+
+	// Logger is used for logging the operation of the DHCP server.  If nil,
+	// [slog.Default] is used.
+	Logger *slog.Logger `yaml:"-"`
+
 	// dbFilePath is the path to the file with stored DHCP leases.
 	dbFilePath string `yaml:"-"`
 }
@@ -133,6 +143,13 @@ type V4ServerConf struct {
 	// TODO(a.garipov): This is utter madness and must be refactored.  It just
 	// begs for deadlock bugs and other nastiness.
 	notify func(uint32)
+
+	// tracer records the spans of the packets processing.  It may be nil.
+	tracer *aghtrace.Tracer
+
+	// logger is used for logging the operation of the server.  It may be nil,
+	// in which case [slog.Default] is used.
+	logger *slog.Logger
 }
 
 // errNilConfig is an error returned by validation method if the config is nil.
@@ -244,4 +261,11 @@ type V6ServerConf struct {
 
 	// Server calls this function when leases data changes
 	notify func(uint32)
+
+	// tracer records the spans of the packets processing.  It may be nil.
+	tracer *aghtrace.Tracer
+
+	// logger is used for logging the operation of the server.  It may be nil,
+	// in which case [slog.Default] is used.
+	logger *slog.Logger
 }
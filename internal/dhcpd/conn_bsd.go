@@ -9,7 +9,7 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -268,10 +268,15 @@ func (s *v4Server) send(peer net.Addr, conn net.PacketConn, req, resp *dhcpv4.DH
 
 	pktData := resp.ToBytes()
 
-	log.Debug("dhcpv4: sending %d bytes to %s: %s", len(pktData), peer, resp.Summary())
+	s.logger.Debug(
+		"sending",
+		"client", peer,
+		"len", len(pktData),
+		"summary", resp.Summary(),
+	)
 
 	_, err := conn.WriteTo(pktData, peer)
 	if err != nil {
-		log.Error("dhcpv4: conn.Write to %s failed: %s", peer, err)
+		s.logger.Error("writing response", "client", peer, slogutil.KeyError, err)
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"net"
 	"testing"
 
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -114,7 +115,9 @@ func TestBuildEtherPkt(t *testing.T) {
 }
 
 func TestV4Server_Send(t *testing.T) {
-	s := &v4Server{}
+	s := &v4Server{
+		logger: slogutil.NewDiscardLogger(),
+	}
 
 	var (
 		defaultIP = net.IP{99, 99, 99, 99}
@@ -5,6 +5,7 @@ package dhcpd
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"os"
@@ -14,7 +15,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/google/renameio/v2/maybe"
 )
 
@@ -113,7 +114,7 @@ func (s *server) dbLoad() (err error) {
 		var lease *dhcpsvc.Lease
 		lease, err = l.toLease()
 		if err != nil {
-			log.Info("dhcp: invalid lease: %s", err)
+			s.logger.Info("skipping invalid lease", slogutil.KeyError, err)
 
 			continue
 		}
@@ -137,11 +138,11 @@ func (s *server) dbLoad() (err error) {
 		}
 	}
 
-	log.Info(
-		"dhcp: loaded leases v4:%d  v6:%d  total-read:%d from DB",
-		len(leases4),
-		len(leases6),
-		len(leases),
+	s.logger.Info(
+		"loaded leases from db",
+		"v4", len(leases4),
+		"v6", len(leases6),
+		"total", len(leases),
 	)
 
 	return nil
@@ -163,11 +164,11 @@ func (s *server) dbStore() (err error) {
 		}
 	}
 
-	return writeDB(s.conf.dbFilePath, leases)
+	return writeDB(s.logger, s.conf.dbFilePath, leases)
 }
 
 // writeDB writes leases to file at path.
-func writeDB(path string, leases []*dbLease) (err error) {
+func writeDB(logger *slog.Logger, path string, leases []*dbLease) (err error) {
 	defer func() { err = errors.Annotate(err, "writing db: %w") }()
 
 	slices.SortFunc(leases, func(a, b *dbLease) (res int) {
@@ -191,7 +192,7 @@ func writeDB(path string, leases []*dbLease) (err error) {
 		return err
 	}
 
-	log.Info("dhcp: stored %d leases in %q", len(leases), path)
+	logger.Info("stored leases", "num", len(leases), "path", path)
 
 	return nil
 }
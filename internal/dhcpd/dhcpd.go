@@ -3,13 +3,14 @@ package dhcpd
 
 import (
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"path/filepath"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 )
 
@@ -88,6 +89,9 @@ type Interface interface {
 
 // server is the DHCP service that handles DHCPv4, DHCPv6, and HTTP API.
 type server struct {
+	// logger is used for logging the operation of the server.
+	logger *slog.Logger
+
 	srv4 DHCPServer
 	srv6 DHCPServer
 
@@ -105,7 +109,13 @@ var _ Interface = (*server)(nil)
 // Create initializes and returns the DHCP server handling both address
 // families.  It also registers the corresponding HTTP API endpoints.
 func Create(conf *ServerConfig) (s *server, err error) {
+	logger := conf.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	s = &server{
+		logger: logger,
 		conf: &ServerConfig{
 			ConfigModified: conf.ConfigModified,
 
@@ -138,7 +148,7 @@ func Create(conf *ServerConfig) (s *server, err error) {
 	}
 
 	// Migrate leases db if needed.
-	err = migrateDB(conf)
+	err = migrateDB(s.logger, conf)
 	if err != nil {
 		// Don't wrap the error since it's informative enough as is.
 		return nil, err
@@ -161,6 +171,8 @@ func (s *server) setServers(conf *ServerConfig) (v4Enabled, v6Enabled bool, err
 	v4conf := conf.Conf4
 	v4conf.InterfaceName = s.conf.InterfaceName
 	v4conf.notify = s.onNotify
+	v4conf.tracer = conf.Tracer
+	v4conf.logger = s.logger
 	v4conf.Enabled = s.conf.Enabled && v4conf.RangeStart.IsValid()
 
 	s.srv4, err = v4Create(&v4conf)
@@ -169,12 +181,14 @@ func (s *server) setServers(conf *ServerConfig) (v4Enabled, v6Enabled bool, err
 			return false, false, fmt.Errorf("creating dhcpv4 srv: %w", err)
 		}
 
-		log.Debug("dhcpd: warning: creating dhcpv4 srv: %s", err)
+		s.logger.Warn("creating dhcpv4 srv", slogutil.KeyError, err)
 	}
 
 	v6conf := conf.Conf6
 	v6conf.InterfaceName = s.conf.InterfaceName
 	v6conf.notify = s.onNotify
+	v6conf.tracer = conf.Tracer
+	v6conf.logger = s.logger
 	v6conf.Enabled = s.conf.Enabled && len(v6conf.RangeStart) != 0
 
 	s.srv6, err = v6Create(v6conf)
@@ -212,7 +226,7 @@ func (s *server) onNotify(flags uint32) {
 	if flags == LeaseChangedDBStore {
 		err := s.dbStore()
 		if err != nil {
-			log.Error("updating db: %s", err)
+			s.logger.Error("updating db", slogutil.KeyError, err)
 		}
 
 		return
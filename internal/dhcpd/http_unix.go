@@ -18,7 +18,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 )
 
@@ -186,13 +186,14 @@ func (s *server) enableDHCP(ifaceName string) (code int, err error) {
 			// TODO(a.garipov): I was thinking about moving this into
 			// IfaceHasStaticIP, but then we wouldn't be able to log it.  Think
 			// about it more.
-			log.Info("error while checking static ip: %s; "+
-				"assuming machine has static ip and going on", err)
+			s.logger.Info(
+				"checking static ip; assuming machine has static ip and going on",
+				slogutil.KeyError, err,
+			)
 			hasStaticIP = true
 		} else if errors.Is(err, aghnet.ErrNoStaticIPInfo) {
 			// Couldn't obtain a definitive answer.  Assume static IP an go on.
-			log.Info("can't check for static ip; " +
-				"assuming machine has static ip and going on")
+			s.logger.Info("can't check for static ip; assuming machine has static ip and going on")
 			hasStaticIP = true
 		} else {
 			err = fmt.Errorf("checking static ip: %w", err)
@@ -251,6 +252,7 @@ func (s *server) handleDHCPSetConfigV4(
 	v4Conf.notify = c4.notify
 	v4Conf.ICMPTimeout = c4.ICMPTimeout
 	v4Conf.Options = c4.Options
+	v4Conf.logger = s.logger
 
 	srv4, err := v4Create(v4Conf)
 
@@ -280,6 +282,7 @@ func (s *server) handleDHCPSetConfigV6(
 	enabled = v6Conf.Enabled
 	v6Conf.InterfaceName = conf.InterfaceName
 	v6Conf.notify = s.onNotify
+	v6Conf.logger = s.logger
 
 	srv6, err = v6Create(v6Conf)
 
@@ -675,7 +678,7 @@ func (s *server) handleReset(w http.ResponseWriter, r *http.Request) {
 
 	err = os.Remove(s.conf.dbFilePath)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Error("dhcp: removing db: %s", err)
+		s.logger.Error("removing db", slogutil.KeyError, err)
 	}
 
 	s.conf = &ServerConfig{
@@ -693,12 +696,14 @@ func (s *server) handleReset(w http.ResponseWriter, r *http.Request) {
 		LeaseDuration: DefaultDHCPLeaseTTL,
 		ICMPTimeout:   DefaultDHCPTimeoutICMP,
 		notify:        s.onNotify,
+		logger:        s.logger,
 	}
 	s.srv4, _ = v4Create(v4conf)
 
 	v6conf := V6ServerConf{
 		LeaseDuration: DefaultDHCPLeaseTTL,
 		notify:        s.onNotify,
+		logger:        s.logger,
 	}
 	s.srv6, _ = v6Create(v6conf)
 
@@ -3,6 +3,7 @@ package dhcpd
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"os"
@@ -10,7 +11,6 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
 )
 
 const (
@@ -61,7 +61,7 @@ func readOldDB(path string) (leases []*leaseJSON, err error) {
 }
 
 // migrateDB migrates stored leases if necessary.
-func migrateDB(conf *ServerConfig) (err error) {
+func migrateDB(logger *slog.Logger, conf *ServerConfig) (err error) {
 	defer func() { err = errors.Annotate(err, "migrating db: %w") }()
 
 	oldLeasesPath := filepath.Join(conf.WorkDir, dbFilename)
@@ -81,7 +81,7 @@ func migrateDB(conf *ServerConfig) (err error) {
 		l.IP = normalizeIP(l.IP)
 		ip, ok := netip.AddrFromSlice(l.IP)
 		if !ok {
-			log.Info("dhcp: invalid IP: %s", l.IP)
+			logger.Info("skipping lease: invalid ip", "ip", l.IP)
 
 			continue
 		}
@@ -95,7 +95,7 @@ func migrateDB(conf *ServerConfig) (err error) {
 		})
 	}
 
-	err = writeDB(dataDirPath, leases)
+	err = writeDB(logger, dataDirPath, leases)
 	if err != nil {
 		// Don't wrap the error since an annotation deferred already.
 		return err
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -45,7 +46,7 @@ func TestMigrateDB(t *testing.T) {
 		DataDir: dir,
 	}
 
-	err = migrateDB(conf)
+	err = migrateDB(slogutil.NewDiscardLogger(), conf)
 	require.NoError(t, err)
 
 	_, err = os.Stat(oldLeasesPath)
@@ -11,7 +11,7 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/insomniacslk/dhcp/dhcpv4"
@@ -379,7 +379,7 @@ func (s *v4Server) prepareOptions() {
 	for i, o := range s.conf.Options {
 		code, val, err := parseDHCPOption(o)
 		if err != nil {
-			log.Error("dhcpv4: bad option string at index %d: %s", i, err)
+			s.logger.Error("bad option string", "idx", i, slogutil.KeyError, err)
 
 			continue
 		}
@@ -389,8 +389,8 @@ func (s *v4Server) prepareOptions() {
 		delete(s.implicitOpts, code.Code())
 	}
 
-	log.Debug("dhcpv4: implicit options:\n%s", s.implicitOpts.Summary(nil))
-	log.Debug("dhcpv4: explicit options:\n%s", s.explicitOpts.Summary(nil))
+	s.logger.Debug("implicit options", "summary", s.implicitOpts.Summary(nil))
+	s.logger.Debug("explicit options", "summary", s.explicitOpts.Summary(nil))
 
 	if len(s.explicitOpts) == 0 {
 		s.explicitOpts = nil
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/insomniacslk/dhcp/dhcpv4"
@@ -250,6 +251,7 @@ func TestPrepareOptions(t *testing.T) {
 
 	for _, tc := range testCases {
 		s := &v4Server{
+			logger: slogutil.NewDiscardLogger(),
 			conf: &V4ServerConf{
 				Options: tc.opts,
 			},
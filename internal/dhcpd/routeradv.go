@@ -3,18 +3,22 @@ package dhcpd
 import (
 	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"net"
 	"sync/atomic"
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv6"
 )
 
 type raCtx struct {
+	// logger is used for logging the operation of the router advertisement.
+	logger *slog.Logger
+
 	raAllowSLAAC     bool   // send RA packets without MO flags
 	raSLAACOnly      bool   // send RA packets with MO flags
 	ipAddr           net.IP // source IP address (link-local-unicast)
@@ -204,7 +208,7 @@ func (ra *raCtx) Init() (err error) {
 		return nil
 	}
 
-	log.Debug("dhcpv6 ra: source IP address: %s  DNS IP address: %s", ra.ipAddr, ra.dnsIPAddr)
+	ra.logger.Debug("ra: initializing", "ip", ra.ipAddr, "dns_ip", ra.dnsIPAddr)
 
 	params := icmpv6RA{
 		managedAddressConfiguration: !ra.raSLAACOnly,
@@ -255,15 +259,15 @@ func (ra *raCtx) Init() (err error) {
 	}
 
 	go func() {
-		log.Debug("dhcpv6 ra: starting to send periodic RouterAdvertisement packets")
+		ra.logger.Debug("ra: starting to send periodic router advertisement packets")
 		for ra.stop.Load() == 0 {
 			_, err = con6.WriteTo(data, msg, addr)
 			if err != nil {
-				log.Error("dhcpv6 ra: WriteTo: %s", err)
+				ra.logger.Error("ra: writing packet", slogutil.KeyError, err)
 			}
 			time.Sleep(ra.packetSendPeriod)
 		}
-		log.Debug("dhcpv6 ra: loop exit")
+		ra.logger.Debug("ra: loop exit")
 	}()
 
 	return nil
@@ -271,7 +275,7 @@ func (ra *raCtx) Init() (err error) {
 
 // Close closes the module.
 func (ra *raCtx) Close() (err error) {
-	log.Debug("dhcpv6 ra: closing")
+	ra.logger.Debug("ra: closing")
 
 	ra.stop.Store(1)
 
@@ -4,7 +4,9 @@ package dhcpd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"slices"
@@ -13,9 +15,10 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/go-ping/ping"
@@ -27,6 +30,9 @@ import (
 //
 // TODO(a.garipov): Think about unifying this and v6Server.
 type v4Server struct {
+	// logger is used for logging the operation of the server.
+	logger *slog.Logger
+
 	conf *V4ServerConf
 
 	srv *server4.Server
@@ -101,7 +107,7 @@ func normalizeHostname(hostname string) (norm string, err error) {
 func (s *v4Server) validHostnameForClient(cliHostname string, ip netip.Addr) (hostname string) {
 	hostname, err := normalizeHostname(cliHostname)
 	if err != nil {
-		log.Info("dhcpv4: %s", err)
+		s.logger.Info("normalizing hostname", slogutil.KeyError, err)
 	}
 
 	if hostname == "" {
@@ -110,7 +116,7 @@ func (s *v4Server) validHostnameForClient(cliHostname string, ip netip.Addr) (ho
 
 	err = netutil.ValidateHostname(hostname)
 	if err != nil {
-		log.Info("dhcpv4: %s", err)
+		s.logger.Info("validating hostname", slogutil.KeyError, err)
 		hostname = ""
 	}
 
@@ -164,7 +170,12 @@ func (s *v4Server) ResetLeases(leases []*dhcpsvc.Lease) (err error) {
 		err = s.addLease(l)
 		if err != nil {
 			// TODO(a.garipov): Wrap and bubble up the error.
-			log.Error("dhcpv4: reset: re-adding a lease for %s (%s): %s", l.IP, l.HWAddr, err)
+			s.logger.Error(
+				"reset: re-adding lease",
+				"ip", l.IP,
+				"mac", l.HWAddr,
+				slogutil.KeyError, err,
+			)
 
 			continue
 		}
@@ -260,7 +271,7 @@ func (s *v4Server) rmLeaseByIndex(i int) {
 	n := len(s.leases)
 	if i >= n {
 		// TODO(a.garipov): Better error handling.
-		log.Debug("dhcpv4: can't remove lease at index %d: no such lease", i)
+		s.logger.Debug("removing lease: no such lease", "idx", i)
 
 		return
 	}
@@ -278,7 +289,7 @@ func (s *v4Server) rmLeaseByIndex(i int) {
 	delete(s.hostsIndex, l.Hostname)
 	delete(s.ipIndex, l.IP)
 
-	log.Debug("dhcpv4: removed lease %s (%s)", l.IP, l.HWAddr)
+	s.logger.Debug("removed lease", "ip", l.IP, "mac", l.HWAddr)
 }
 
 // Remove a dynamic lease with the same properties
@@ -573,7 +584,7 @@ func (s *v4Server) addrAvailable(target net.IP) (avail bool) {
 
 	pinger, err := ping.NewPinger(target.String())
 	if err != nil {
-		log.Error("dhcpv4: ping.NewPinger(): %s", err)
+		s.logger.Error("creating pinger", slogutil.KeyError, err)
 
 		return true
 	}
@@ -586,22 +597,22 @@ func (s *v4Server) addrAvailable(target net.IP) (avail bool) {
 		reply = true
 	}
 
-	log.Debug("dhcpv4: sending icmp echo to %s", target)
+	s.logger.Debug("sending icmp echo", "target", target)
 
 	err = pinger.Run()
 	if err != nil {
-		log.Error("dhcpv4: pinger.Run(): %s", err)
+		s.logger.Error("running pinger", slogutil.KeyError, err)
 
 		return true
 	}
 
 	if reply {
-		log.Info("dhcpv4: ip conflict: %s is already used by another device", target)
+		s.logger.Info("ip conflict: already used by another device", "target", target)
 
 		return false
 	}
 
-	log.Debug("dhcpv4: icmp procedure is complete: %q", target)
+	s.logger.Debug("icmp procedure is complete", "target", target)
 
 	return true
 }
@@ -685,7 +696,7 @@ func (s *v4Server) commitLease(l *dhcpsvc.Lease, hostname string) {
 	hostname = s.validHostnameForClient(hostname, l.IP)
 
 	if _, ok := s.hostsIndex[hostname]; ok {
-		log.Info("dhcpv4: hostname %q already exists", hostname)
+		s.logger.Info("hostname already exists", "hostname", hostname)
 
 		if prev == "" {
 			// The lease is just allocated due to DHCPDISCOVER.
@@ -742,7 +753,7 @@ func (s *v4Server) handleDiscover(req, resp *dhcpv4.DHCPv4) (l *dhcpsvc.Lease, e
 		reqIP := req.RequestedIPAddress()
 		leaseIP := net.IP(l.IP.AsSlice())
 		if len(reqIP) != 0 && !reqIP.Equal(leaseIP) {
-			log.Debug("dhcpv4: different RequestedIP: %s != %s", reqIP, leaseIP)
+			s.logger.Debug("different requested ip", "requested", reqIP, "leased", leaseIP)
 		}
 
 		resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer))
@@ -754,7 +765,7 @@ func (s *v4Server) handleDiscover(req, resp *dhcpv4.DHCPv4) (l *dhcpsvc.Lease, e
 	if err != nil {
 		return nil, err
 	} else if l == nil {
-		log.Debug("dhcpv4: no more ip addresses")
+		s.logger.Debug("no more ip addresses")
 
 		return nil, nil
 	}
@@ -794,7 +805,7 @@ func (s *v4Server) checkLease(mac net.HardwareAddr, ip net.IP) (l *dhcpsvc.Lease
 
 	netIP, ok := netip.AddrFromSlice(ip)
 	if !ok {
-		log.Info("check lease: invalid IP: %s", ip)
+		s.logger.Info("checking lease: invalid ip", "ip", ip)
 
 		return nil, false
 	}
@@ -808,10 +819,7 @@ func (s *v4Server) checkLease(mac net.HardwareAddr, ip net.IP) (l *dhcpsvc.Lease
 			return l, false
 		}
 
-		log.Debug(
-			`dhcpv4: mismatched OptionRequestedIPAddress in req msg for %s`,
-			mac,
-		)
+		s.logger.Debug("mismatched requested ip address in req msg", "mac", mac)
 
 		return nil, true
 	}
@@ -830,11 +838,11 @@ func (s *v4Server) handleSelecting(
 	mac := req.ClientHWAddr
 
 	if !sid.Equal(s.conf.dnsIPAddrs[0].AsSlice()) {
-		log.Debug("dhcpv4: bad server identifier in req msg for %s: %s", mac, sid)
+		s.logger.Debug("bad server identifier in req msg", "mac", mac, "sid", sid)
 
 		return nil, false
 	} else if ciaddr := req.ClientIPAddr; ciaddr != nil && !ciaddr.IsUnspecified() {
-		log.Debug("dhcpv4: non-zero ciaddr in selecting req msg for %s", mac)
+		s.logger.Debug("non-zero ciaddr in selecting req msg", "mac", mac)
 
 		return nil, false
 	}
@@ -842,7 +850,7 @@ func (s *v4Server) handleSelecting(
 	// Requested IP address MUST be filled in with the yiaddr value from the
 	// chosen DHCPOFFER.
 	if ip4 := reqIP.To4(); ip4 == nil {
-		log.Debug("dhcpv4: bad requested address in req msg for %s: %s", mac, reqIP)
+		s.logger.Debug("bad requested address in req msg", "mac", mac, "requested", reqIP)
 
 		return nil, false
 	}
@@ -851,7 +859,7 @@ func (s *v4Server) handleSelecting(
 	if l, mismatch = s.checkLease(mac, reqIP); mismatch {
 		return nil, true
 	} else if l == nil {
-		log.Debug("dhcpv4: no reserved lease for %s", mac)
+		s.logger.Debug("no reserved lease", "mac", mac)
 	}
 
 	return l, true
@@ -866,7 +874,7 @@ func (s *v4Server) handleInitReboot(
 
 	ip4 := reqIP.To4()
 	if ip4 == nil {
-		log.Debug("dhcpv4: bad requested address in req msg for %s: %s", mac, reqIP)
+		s.logger.Debug("bad requested address in req msg", "mac", mac, "requested", reqIP)
 
 		return nil, false
 	}
@@ -874,7 +882,7 @@ func (s *v4Server) handleInitReboot(
 	// ciaddr MUST be zero.  The client is seeking to verify a previously
 	// allocated, cached configuration.
 	if ciaddr := req.ClientIPAddr; ciaddr != nil && !ciaddr.IsUnspecified() {
-		log.Debug("dhcpv4: non-zero ciaddr in init-reboot req msg for %s", mac)
+		s.logger.Debug("non-zero ciaddr in init-reboot req msg", "mac", mac)
 
 		return nil, false
 	}
@@ -882,7 +890,7 @@ func (s *v4Server) handleInitReboot(
 	if !s.conf.subnet.Contains(netip.AddrFrom4([4]byte(ip4))) {
 		// If the DHCP server detects that the client is on the wrong net then
 		// the server SHOULD send a DHCPNAK message to the client.
-		log.Debug("dhcpv4: wrong subnet in init-reboot req msg for %s: %s", mac, reqIP)
+		s.logger.Debug("wrong subnet in init-reboot req msg", "mac", mac, "requested", reqIP)
 
 		return nil, true
 	}
@@ -893,7 +901,7 @@ func (s *v4Server) handleInitReboot(
 	} else if l == nil {
 		// If the DHCP server has no record of this client, then it MUST remain
 		// silent, and MAY output a warning to the network administrator.
-		log.Info("dhcpv4: warning: no existing lease for %s", mac)
+		s.logger.Warn("no existing lease", "mac", mac)
 
 		return nil, false
 	}
@@ -909,7 +917,7 @@ func (s *v4Server) handleRenew(req *dhcpv4.DHCPv4) (l *dhcpsvc.Lease, needsReply
 	// ciaddr MUST be filled in with client's IP address.
 	ciaddr := req.ClientIPAddr
 	if ciaddr == nil || ciaddr.IsUnspecified() || ciaddr.To4() == nil {
-		log.Debug("dhcpv4: bad ciaddr in renew req msg for %s: %s", mac, ciaddr)
+		s.logger.Debug("bad ciaddr in renew req msg", "mac", mac, "ciaddr", ciaddr)
 
 		return nil, false
 	}
@@ -920,7 +928,7 @@ func (s *v4Server) handleRenew(req *dhcpv4.DHCPv4) (l *dhcpsvc.Lease, needsReply
 	} else if l == nil {
 		// If the DHCP server has no record of this client, then it MUST remain
 		// silent, and MAY output a warning to the network administrator.
-		log.Info("dhcpv4: warning: no existing lease for %s", mac)
+		s.logger.Warn("no existing lease", "mac", mac)
 
 		return nil, false
 	}
@@ -1008,7 +1016,7 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 
 	oldLease := s.findLeaseForIP(reqIP, mac)
 	if oldLease == nil {
-		log.Info("dhcpv4: lease with IP %s for %s not found", reqIP, mac)
+		s.logger.Info("lease not found", "ip", reqIP, "mac", mac)
 
 		return nil
 	}
@@ -1022,7 +1030,7 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 	if err != nil {
 		return fmt.Errorf("allocating new lease for %s: %w", mac, err)
 	} else if newLease == nil {
-		log.Info("dhcpv4: allocating new lease for %s: no more IP addresses", mac)
+		s.logger.Info("allocating new lease: no more ip addresses", "mac", mac)
 
 		resp.YourIPAddr = make([]byte, 4)
 		resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))
@@ -1038,7 +1046,7 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 		return fmt.Errorf("adding new lease for %s: %w", mac, err)
 	}
 
-	log.Info("dhcpv4: changed IP from %s to %s for %s", reqIP, newLease.IP, mac)
+	s.logger.Info("changed ip", "from", reqIP, "to", newLease.IP, "mac", mac)
 
 	resp.YourIPAddr = newLease.IP.AsSlice()
 	resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))
@@ -1050,7 +1058,7 @@ func (s *v4Server) handleDecline(req, resp *dhcpv4.DHCPv4) (err error) {
 func (s *v4Server) findLeaseForIP(ip net.IP, mac net.HardwareAddr) (l *dhcpsvc.Lease) {
 	netIP, ok := netip.AddrFromSlice(ip)
 	if !ok {
-		log.Info("dhcpv4: invalid IP: %s", ip)
+		s.logger.Info("invalid ip", "ip", ip)
 
 		return nil
 	}
@@ -1082,7 +1090,7 @@ func (s *v4Server) handleRelease(req, resp *dhcpv4.DHCPv4) (err error) {
 
 	netIP, ok := netip.AddrFromSlice(reqIP)
 	if !ok {
-		log.Info("dhcpv4: invalid IP: %s", reqIP)
+		s.logger.Info("invalid ip", "ip", reqIP)
 
 		return nil
 	}
@@ -1102,7 +1110,7 @@ func (s *v4Server) handleRelease(req, resp *dhcpv4.DHCPv4) (err error) {
 		n++
 	}
 
-	log.Info("dhcpv4: released %d dynamic leases for %s", n, mac)
+	s.logger.Info("released dynamic leases", "count", n, "mac", mac)
 
 	resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))
 
@@ -1203,7 +1211,7 @@ func (s *v4Server) handle(req, resp *dhcpv4.DHCPv4) (rCode int) {
 
 	rCode, l, err := handler(s, req, resp)
 	if err != nil {
-		log.Error("dhcpv4: %s", err)
+		s.logger.Error("handling request", slogutil.KeyError, err)
 
 		return 0
 	}
@@ -1259,7 +1267,15 @@ func (s *v4Server) updateOptions(req, resp *dhcpv4.DHCPv4) {
 // client(0.0.0.0:68) -> (Request:ClientMAC,Type=Request,ClientID,ReqIP||ClientIP,HostName,ServerID,ParamReqList) -> server(255.255.255.255:67)
 // client(255.255.255.255:68) <- (Reply:YourIP,ClientMAC,Type=ACK,ServerID,SubnetMask,LeaseTime) <- server(<IP>:67)
 func (s *v4Server) packetHandler(conn net.PacketConn, peer net.Addr, req *dhcpv4.DHCPv4) {
-	log.Debug("dhcpv4: received message: %s", req.Summary())
+	s.logger.Debug("received message", "client", peer, "summary", req.Summary())
+
+	_, span := s.conf.tracer.StartServer(
+		context.Background(),
+		"dhcpv4.packet",
+		aghtrace.String("dhcp.message_type", req.MessageType().String()),
+		aghtrace.String("dhcp.client_hwaddr", req.ClientHWAddr.String()),
+	)
+	defer span.End()
 
 	switch req.MessageType() {
 	case
@@ -1269,21 +1285,21 @@ func (s *v4Server) packetHandler(conn net.PacketConn, peer net.Addr, req *dhcpv4
 		dhcpv4.MessageTypeRelease:
 		// Go on.
 	default:
-		log.Debug("dhcpv4: unsupported message type %d", req.MessageType())
+		s.logger.Debug("unsupported message type", "type", req.MessageType())
 
 		return
 	}
 
 	resp, err := dhcpv4.NewReplyFromRequest(req)
 	if err != nil {
-		log.Debug("dhcpv4: dhcpv4.New: %s", err)
+		s.logger.Debug("creating reply", slogutil.KeyError, err)
 
 		return
 	}
 
 	err = netutil.ValidateMAC(req.ClientHWAddr)
 	if err != nil {
-		log.Error("dhcpv4: invalid ClientHWAddr: %s", err)
+		s.logger.Error("invalid client hardware address", "client", peer, slogutil.KeyError, err)
 
 		return
 	}
@@ -1312,7 +1328,7 @@ func (s *v4Server) Start() (err error) {
 		return fmt.Errorf("finding interface %s by name: %w", ifaceName, err)
 	}
 
-	log.Debug("dhcpv4: starting...")
+	s.logger.Debug("starting")
 
 	dnsIPAddrs, err := aghnet.IfaceDNSIPAddrs(
 		iface,
@@ -1347,13 +1363,13 @@ func (s *v4Server) Start() (err error) {
 		return err
 	}
 
-	log.Info("dhcpv4: listening")
+	s.logger.Info("listening")
 
 	go func() {
 		if sErr := s.srv.Serve(); errors.Is(sErr, net.ErrClosed) {
-			log.Info("dhcpv4: server is closed")
+			s.logger.Info("server is closed")
 		} else if sErr != nil {
-			log.Error("dhcpv4: srv.Serve: %s", sErr)
+			s.logger.Error("serving", slogutil.KeyError, sErr)
 		}
 	}()
 
@@ -1392,7 +1408,7 @@ func (s *v4Server) Stop() (err error) {
 		return
 	}
 
-	log.Debug("dhcpv4: stopping")
+	s.logger.Debug("stopping")
 	err = s.srv.Close()
 	if err != nil {
 		return fmt.Errorf("closing dhcpv4 srv: %w", err)
@@ -1409,7 +1425,13 @@ func (s *v4Server) Stop() (err error) {
 
 // Create DHCPv4 server
 func v4Create(conf *V4ServerConf) (srv *v4Server, err error) {
+	logger := conf.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	s := &v4Server{
+		logger:     logger.With("proto", "dhcpv4"),
 		hostsIndex: map[string]*dhcpsvc.Lease{},
 		ipIndex:    map[netip.Addr]*dhcpsvc.Lease{},
 	}
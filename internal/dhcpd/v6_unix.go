@@ -4,16 +4,19 @@ package dhcpd
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"sync"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/insomniacslk/dhcp/dhcpv6"
@@ -27,6 +30,9 @@ const valueIAID = "ADGH" // value for IANA.ID
 //
 // TODO(a.garipov): Think about unifying this and v4Server.
 type v6Server struct {
+	// logger is used for logging the operation of the server.
+	logger *slog.Logger
+
 	ra   raCtx
 	conf V6ServerConf
 	sid  dhcpv6.DUID
@@ -99,7 +105,7 @@ func (s *v6Server) ResetLeases(leases []*dhcpsvc.Lease) (err error) {
 		ip := net.IP(l.IP.AsSlice())
 		if !l.IsStatic && !ip6InRange(s.conf.ipStart, ip) {
 
-			log.Debug("dhcpv6: skipping a lease with IP %v: not within current IP range", l.IP)
+			s.logger.Debug("skipping lease: not within current ip range", "ip", l.IP)
 
 			continue
 		}
@@ -166,7 +172,7 @@ func (s *v6Server) FindMACbyIP(ip netip.Addr) (mac net.HardwareAddr) {
 func (s *v6Server) leaseRemoveSwapByIndex(i int) {
 	leaseIP := s.leases[i].IP.As16()
 	s.ipAddrs[leaseIP[15]] = 0
-	log.Debug("dhcpv6: removed lease %s", s.leases[i].HWAddr)
+	s.logger.Debug("removed lease", "mac", s.leases[i].HWAddr)
 
 	n := len(s.leases)
 	if i != n-1 {
@@ -299,7 +305,7 @@ func (s *v6Server) addLease(l *dhcpsvc.Lease) {
 	s.leases = append(s.leases, l)
 	ip := l.IP.As16()
 	s.ipAddrs[ip[15]] = 1
-	log.Debug("dhcpv6: added lease %s <-> %s", l.IP, l.HWAddr)
+	s.logger.Debug("added lease", "ip", l.IP, "mac", l.HWAddr)
 }
 
 // Remove a lease with the same properties
@@ -504,7 +510,7 @@ func (s *v6Server) process(msg *dhcpv6.Message, req, resp dhcpv6.DHCPv6) bool {
 
 	mac, err := dhcpv6.ExtractMAC(req)
 	if err != nil {
-		log.Debug("dhcpv6: dhcpv6.ExtractMAC: %s", err)
+		s.logger.Debug("extracting mac", slogutil.KeyError, err)
 
 		return false
 	}
@@ -518,7 +524,7 @@ func (s *v6Server) process(msg *dhcpv6.Message, req, resp dhcpv6.DHCPv6) bool {
 	}()
 
 	if lease == nil {
-		log.Debug("dhcpv6: no lease for: %s", mac)
+		s.logger.Debug("no lease", "mac", mac)
 
 		switch msg.Type() {
 
@@ -535,7 +541,7 @@ func (s *v6Server) process(msg *dhcpv6.Message, req, resp dhcpv6.DHCPv6) bool {
 
 	err = s.checkIA(msg, lease)
 	if err != nil {
-		log.Debug("dhcpv6: %s", err)
+		s.logger.Debug("checking ia", slogutil.KeyError, err)
 
 		return false
 	}
@@ -593,22 +599,31 @@ func (s *v6Server) process(msg *dhcpv6.Message, req, resp dhcpv6.DHCPv6) bool {
 func (s *v6Server) packetHandler(conn net.PacketConn, peer net.Addr, req dhcpv6.DHCPv6) {
 	msg, err := req.GetInnerMessage()
 	if err != nil {
-		log.Error("dhcpv6: %s", err)
+		s.logger.Error("getting inner message", "client", peer, slogutil.KeyError, err)
 
 		return
 	}
 
-	log.Debug("dhcpv6: received: %s", req.Summary())
+	s.logger.Debug("received message", "client", peer, "summary", req.Summary())
+
+	_, span := s.conf.tracer.StartServer(
+		context.Background(),
+		"dhcpv6.packet",
+		aghtrace.String("dhcp.message_type", msg.Type().String()),
+	)
+	defer span.End()
 
 	err = s.checkCID(msg)
 	if err != nil {
-		log.Debug("%s", err)
+		s.logger.Debug("checking client id", "client", peer, slogutil.KeyError, err)
+
 		return
 	}
 
 	err = s.checkSID(msg)
 	if err != nil {
-		log.Debug("%s", err)
+		s.logger.Debug("checking server id", "client", peer, slogutil.KeyError, err)
+
 		return
 	}
 
@@ -631,12 +646,12 @@ func (s *v6Server) packetHandler(conn net.PacketConn, peer net.Addr, req dhcpv6.
 		dhcpv6.MessageTypeInformationRequest:
 		resp, err = dhcpv6.NewReplyFromMessage(msg)
 	default:
-		log.Error("dhcpv6: message type %d not supported", msg.Type())
+		s.logger.Error("message type not supported", "type", msg.Type())
 
 		return
 	}
 	if err != nil {
-		log.Error("dhcpv6: %s", err)
+		s.logger.Error("creating reply", "client", peer, slogutil.KeyError, err)
 
 		return
 	}
@@ -645,11 +660,11 @@ func (s *v6Server) packetHandler(conn net.PacketConn, peer net.Addr, req dhcpv6.
 
 	_ = s.process(msg, req, resp)
 
-	log.Debug("dhcpv6: sending: %s", resp.Summary())
+	s.logger.Debug("sending", "client", peer, "summary", resp.Summary())
 
 	_, err = conn.WriteTo(resp.ToBytes(), peer)
 	if err != nil {
-		log.Error("dhcpv6: conn.Write to %s failed: %s", peer, err)
+		s.logger.Error("writing response", "client", peer, slogutil.KeyError, err)
 
 		return
 	}
@@ -713,7 +728,7 @@ func (s *v6Server) Start() (err error) {
 		return fmt.Errorf("finding interface %s by name: %w", ifaceName, err)
 	}
 
-	log.Debug("dhcpv6: starting...")
+	s.logger.Debug("starting")
 
 	ok, err := s.configureDNSIPAddrs(iface)
 	if err != nil {
@@ -728,7 +743,7 @@ func (s *v6Server) Start() (err error) {
 
 	// Don't initialize DHCPv6 server if we must force the clients to use SLAAC.
 	if s.conf.RASLAACOnly {
-		log.Debug("not starting dhcpv6 server due to ra_slaac_only=true")
+		s.logger.Debug("not starting server due to ra_slaac_only=true")
 
 		return nil
 	}
@@ -749,13 +764,13 @@ func (s *v6Server) Start() (err error) {
 		return err
 	}
 
-	log.Debug("dhcpv6: listening...")
+	s.logger.Debug("listening")
 
 	go func() {
 		if sErr := s.srv.Serve(); errors.Is(sErr, net.ErrClosed) {
-			log.Info("dhcpv6: server is closed")
+			s.logger.Info("server is closed")
 		} else if sErr != nil {
-			log.Error("dhcpv6: srv.Serve: %s", sErr)
+			s.logger.Error("serving", slogutil.KeyError, sErr)
 		}
 	}()
 
@@ -774,7 +789,7 @@ func (s *v6Server) Stop() (err error) {
 		return
 	}
 
-	log.Debug("dhcpv6: stopping")
+	s.logger.Debug("stopping")
 	err = s.srv.Close()
 	if err != nil {
 		return fmt.Errorf("closing dhcpv6 srv: %w", err)
@@ -788,7 +803,15 @@ func (s *v6Server) Stop() (err error) {
 
 // Create DHCPv6 server
 func v6Create(conf V6ServerConf) (DHCPServer, error) {
-	s := &v6Server{}
+	logger := conf.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	s := &v6Server{
+		logger: logger.With("proto", "dhcpv6"),
+	}
+	s.ra.logger = s.logger
 	s.conf = conf
 
 	if !conf.Enabled {
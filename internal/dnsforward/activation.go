@@ -0,0 +1,151 @@
+package dnsforward
+
+import (
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/miekg/dns"
+)
+
+// newActivatedServer returns a server for the pre-bound DNS sockets from the
+// configuration, if there are any.  p is the proxy used to resolve the
+// requests, proxyConf is its configuration.  srv is nil if there are no
+// sockets to serve.  s.ratelimiter must be set.
+func (s *Server) newActivatedServer(
+	p *proxy.Proxy,
+	proxyConf *proxy.Config,
+) (srv *socketact.DNSServer) {
+	act := s.conf.Activated
+	if act == nil {
+		return nil
+	}
+
+	ls := &socketact.DNSListeners{
+		TLS:  act.TLS,
+		QUIC: act.QUIC,
+	}
+
+	if s.conf.ServePlainDNS {
+		ls.UDP, ls.TCP = act.UDP, act.TCP
+	}
+
+	if !ls.HasPlain() && !ls.HasEncrypted() {
+		return nil
+	}
+
+	if s.conf.ProxyProtocol.Enabled {
+		ls = s.wrapProxyProto(ls)
+	}
+
+	rl := s.ratelimiter
+
+	return socketact.NewDNSServer(&socketact.DNSServerConfig{
+		Listeners: ls,
+		TLSConfig: proxyConf.TLSConfig,
+		Handler: func(dctx *proxy.DNSContext) {
+			s.handleActivated(p, rl, dctx)
+		},
+	})
+}
+
+// handleActivated handles the DNS request received over a pre-bound socket the
+// same way p handles the requests received over its own sockets, including the
+// ratelimit of the requests over UDP applied with rl and the validation of the
+// requests.  It leaves the response nil if the request should be dropped.
+func (s *Server) handleActivated(p *proxy.Proxy, rl *ratelimiter, dctx *proxy.DNSContext) {
+	req := dctx.Req
+	if req.Response {
+		s.logger.Debug("activated: dropping incoming response packet", "client", dctx.Addr)
+
+		return
+	}
+
+	if dctx.Proto == proxy.ProtoUDP && rl.isRatelimited(dctx.Addr.Addr()) {
+		s.logger.Debug("activated: ratelimiting", "client", dctx.Addr)
+
+		// Don't reply to the ratelimited clients.
+		return
+	}
+
+	dctx.IsPrivateClient = s.privateNets.Contains(dctx.Addr.Addr())
+
+	err := s.HandleBefore(p, dctx)
+	if err != nil {
+		s.logger.Debug(
+			"activated: handling before request",
+			"client", dctx.Addr,
+			slogutil.KeyError, err,
+		)
+
+		berr := &proxy.BeforeRequestError{}
+		if errors.As(err, &berr) {
+			dctx.Res = berr.Response
+		}
+
+		return
+	}
+
+	dctx.Res = s.validateActivated(dctx)
+	if dctx.Res != nil {
+		return
+	}
+
+	err = s.handleDNSRequest(p, dctx)
+	if err != nil {
+		s.logger.Debug("activated: handling request", "client", dctx.Addr, slogutil.KeyError, err)
+
+		if dctx.Res == nil {
+			dctx.Res = s.NewMsgSERVFAIL(req)
+		}
+	}
+}
+
+// validateActivated returns the response to the invalid request from dctx or
+// nil if the request is valid.  It performs the same checks as the DNS proxy
+// does for the requests received over its own sockets.  It also sets
+// dctx.RequestedPrivateRDNS for the PTR requests for the private addresses.
+func (s *Server) validateActivated(dctx *proxy.DNSContext) (resp *dns.Msg) {
+	req := dctx.Req
+	if len(req.Question) != 1 {
+		return s.reply(req, dns.RcodeFormatError)
+	}
+
+	q := req.Question[0]
+	switch {
+	case s.conf.RefuseAny && q.Qtype == dns.TypeANY:
+		s.logger.Debug("activated: refusing type=ANY request", "client", dctx.Addr)
+
+		return s.NewMsgNOTIMPLEMENTED(req)
+	case s.recDetector.check(req):
+		s.logger.Debug(
+			"activated: recursion detected",
+			"client", dctx.Addr,
+			"name", q.Name,
+		)
+
+		return s.NewMsgNXDOMAIN(req)
+	case q.Qtype == dns.TypePTR:
+		pref, err := netutil.ExtractReversedAddr(q.Name)
+		if err != nil || !s.privateNets.Contains(pref.Addr()) {
+			return nil
+		}
+
+		if !dctx.IsPrivateClient {
+			s.logger.Debug(
+				"activated: request for a private arpa domain",
+				"client", dctx.Addr,
+				"name", q.Name,
+			)
+
+			return s.NewMsgNXDOMAIN(req)
+		}
+
+		dctx.RequestedPrivateRDNS = pref
+
+		return nil
+	default:
+		return nil
+	}
+}
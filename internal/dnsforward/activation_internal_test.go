@@ -0,0 +1,238 @@
+package dnsforward
+
+import (
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testExchangeTimeout is the timeout of waiting for a response, which is
+// expected to be dropped.
+const testExchangeTimeout = 200 * time.Millisecond
+
+// exchangeFunc sends req to the tested server and returns its response.
+type exchangeFunc func(req *dns.Msg) (resp *dns.Msg, err error)
+
+// newOutsideProxyTestServer returns a server with conf, which upstream answers
+// every request successfully.
+func newOutsideProxyTestServer(t *testing.T, conf ServerConfig) (s *Server) {
+	t.Helper()
+
+	conf.Config.UpstreamMode = UpstreamModeLoadBalance
+	conf.Config.EDNSClientSubnet = &EDNSClientSubnet{Enabled: false}
+	conf.Config.RefuseAny = true
+	conf.ServePlainDNS = true
+
+	s = createTestServer(t, &filtering.Config{
+		BlockingMode: filtering.BlockingModeDefault,
+	}, conf)
+
+	ups := aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+		return (&dns.Msg{}).SetReply(req), nil
+	})
+	s.conf.UpstreamConfig.Upstreams = []upstream.Upstream{ups}
+
+	startDeferStop(t, s)
+
+	return s
+}
+
+func TestServer_handleActivated(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, conn.Close)
+
+	s := newOutsideProxyTestServer(t, ServerConfig{
+		Activated: &socketact.DNSListeners{
+			UDP: []net.PacketConn{conn},
+		},
+	})
+
+	addr := conn.LocalAddr().String()
+	cli := &dns.Client{
+		Timeout: testExchangeTimeout,
+	}
+
+	testOutsideProxyValidation(t, s, func(req *dns.Msg) (resp *dns.Msg, err error) {
+		resp, _, err = cli.Exchange(req, addr)
+
+		return resp, err
+	})
+}
+
+func TestServer_proxyProto(t *testing.T) {
+	s := newOutsideProxyTestServer(t, ServerConfig{
+		TCPListenAddrs: []*net.TCPAddr{{IP: net.IP{127, 0, 0, 1}}},
+		Config: Config{
+			ProxyProtocol: ProxyProtocolConfig{
+				TrustedSources: []netutil.Prefix{{
+					Prefix: netip.MustParsePrefix("127.0.0.0/8"),
+				}},
+				Enabled: true,
+			},
+		},
+	})
+
+	var addr string
+	for _, c := range s.proxyProtoSockets {
+		if l, ok := c.(net.Listener); ok {
+			addr = l.Addr().String()
+		}
+	}
+	require.NotEmpty(t, addr)
+
+	testOutsideProxyValidation(t, s, func(req *dns.Msg) (resp *dns.Msg, err error) {
+		c, err := net.DialTimeout("tcp", addr, testExchangeTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { err = errors.WithDeferred(err, c.Close()) }()
+
+		_, err = io.WriteString(c, "PROXY TCP4 192.0.2.1 192.0.2.2 12345 53\r\n")
+		if err != nil {
+			return nil, err
+		}
+
+		dc := &dns.Conn{Conn: c}
+		err = dc.SetDeadline(time.Now().Add(testExchangeTimeout))
+		if err != nil {
+			return nil, err
+		}
+
+		err = dc.WriteMsg(req)
+		if err != nil {
+			return nil, err
+		}
+
+		return dc.ReadMsg()
+	})
+}
+
+// testOutsideProxyValidation checks that the requests received by s outside of
+// the DNS proxy with exchange are validated the same way as the ones received
+// by the proxy itself.
+func testOutsideProxyValidation(t *testing.T, s *Server, exchange exchangeFunc) {
+	t.Helper()
+
+	respReq := createTestMessage("example.org.")
+	respReq.Response = true
+
+	testCases := []struct {
+		req       *dns.Msg
+		name      string
+		wantRcode int
+		recursive bool
+		wantDrop  bool
+	}{{
+		req:       createTestMessage("example.org."),
+		name:      "success",
+		wantRcode: dns.RcodeSuccess,
+		recursive: false,
+		wantDrop:  false,
+	}, {
+		req:       createTestMessageWithType("example.org.", dns.TypeANY),
+		name:      "any",
+		wantRcode: dns.RcodeNotImplemented,
+		recursive: false,
+		wantDrop:  false,
+	}, {
+		req:       createTestMessage("recursive.example.org."),
+		name:      "recursion",
+		wantRcode: dns.RcodeNameError,
+		recursive: true,
+		wantDrop:  false,
+	}, {
+		req:       respReq,
+		name:      "response",
+		wantRcode: 0,
+		recursive: false,
+		wantDrop:  true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.recursive {
+				s.recDetector.add(tc.req)
+			}
+
+			resp, err := exchange(tc.req)
+			if tc.wantDrop {
+				// The stream connections are closed and the datagram ones time
+				// out.
+				assert.Error(t, err)
+				assert.Nil(t, resp)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantRcode, resp.Rcode)
+		})
+	}
+}
+
+func TestServer_Start_stopsOnError(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, conn.Close)
+
+	// Occupy the address of the PROXY protocol listener to make it fail.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, busy.Close)
+
+	busyAddr := testutil.RequireTypeAssert[*net.TCPAddr](t, busy.Addr())
+
+	s := createTestServer(t, &filtering.Config{
+		BlockingMode: filtering.BlockingModeDefault,
+	}, ServerConfig{
+		TCPListenAddrs: []*net.TCPAddr{busyAddr},
+		Config: Config{
+			UpstreamMode:     UpstreamModeLoadBalance,
+			EDNSClientSubnet: &EDNSClientSubnet{Enabled: false},
+			ProxyProtocol: ProxyProtocolConfig{
+				TrustedSources: []netutil.Prefix{{
+					Prefix: netip.MustParsePrefix("192.0.2.0/24"),
+				}},
+				Enabled: true,
+			},
+		},
+		Activated: &socketact.DNSListeners{
+			UDP: []net.PacketConn{conn},
+		},
+		ServePlainDNS: true,
+	})
+
+	ups := aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+		return (&dns.Msg{}).SetReply(req), nil
+	})
+	s.conf.UpstreamConfig.Upstreams = []upstream.Upstream{ups}
+
+	err = s.Start()
+	require.Error(t, err)
+
+	assert.False(t, s.IsRunning())
+
+	cli := &dns.Client{
+		Timeout: testExchangeTimeout,
+	}
+
+	_, _, err = cli.Exchange(createTestMessage("example.org."), conn.LocalAddr().String())
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
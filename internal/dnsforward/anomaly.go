@@ -0,0 +1,61 @@
+package dnsforward
+
+import (
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/anomaly"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+)
+
+// processAnomaly checks the request for the signs of the DNS tunneling and the
+// algorithmically generated domains.  The suspicious requests are either
+// blocked or only flagged, depending on the configuration.
+func (s *Server) processAnomaly(dctx *dnsContext) (rc resultCode) {
+	d := s.conf.Anomaly
+	pctx := dctx.proxyCtx
+	if d == nil || pctx.Res != nil || dctx.result.Reason != filtering.NotFilteredNotFound {
+		return resultCodeSuccess
+	}
+
+	if !dctx.protectionEnabled ||
+		!dctx.setts.FilteringEnabled ||
+		dctx.setts.IgnoreAnomalyDetection {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing anomaly")
+	defer dctx.logger.Debug("finished processing anomaly")
+
+	q := pctx.Req.Question[0]
+	host := aghnet.NormalizeDomain(q.Name)
+
+	res := d.Check(host, q.Qtype)
+	if !res.Suspicious {
+		return resultCodeSuccess
+	}
+
+	rule := res.Rule()
+	block := d.Action() == anomaly.ActionBlock
+
+	dctx.logger.Debug(
+		"anomaly: host matches",
+		"host", host,
+		"rule", rule,
+		"blocking", block,
+	)
+
+	dctx.result = &filtering.Result{
+		Rules: []*filtering.ResultRule{{
+			Text:         rule,
+			FilterListID: rulelist.URLFilterIDAnomaly,
+		}},
+		Reason:     filtering.FilteredAnomaly,
+		IsFiltered: block,
+	}
+
+	if block {
+		pctx.Res = s.genDNSFilterMessage(dctx, dctx.result)
+	}
+
+	return resultCodeSuccess
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
 	"github.com/miekg/dns"
 )
 
@@ -41,7 +40,12 @@ func (s *Server) HandleBefore(
 		qt := q.Qtype
 		host := aghnet.NormalizeDomain(q.Name)
 		if s.access.isBlockedHost(host, qt) {
-			log.Debug("access: request %s %s is in access blocklist", dns.Type(qt), host)
+			s.logger.Debug(
+				"access: request is in access blocklist",
+				"client", pctx.Addr,
+				"qtype", dns.Type(qt),
+				"host", host,
+			)
 
 			return s.preBlockedResponse(pctx)
 		}
@@ -79,7 +83,7 @@ func (s *Server) clientIDFromDNSContext(pctx *proxy.DNSContext) (clientID string
 		return "", nil
 	}
 
-	cliSrvName, err := clientServerName(pctx, proto)
+	cliSrvName, err := clientServerName(s.logger, pctx, proto)
 	if err != nil {
 		return "", err
 	}
@@ -3,12 +3,12 @@ package dnsforward
 import (
 	"crypto/tls"
 	"fmt"
+	"log/slog"
 	"path"
 	"strings"
 
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/quic-go/quic-go"
 )
@@ -112,8 +112,12 @@ type quicConnection interface {
 
 // clientServerName returns the TLS server name based on the protocol.  For
 // DNS-over-HTTPS requests, it will return the hostname part of the Host header
-// if there is one.
-func clientServerName(pctx *proxy.DNSContext, proto proxy.Proto) (srvName string, err error) {
+// if there is one.  logger is used for logging the operation.
+func clientServerName(
+	logger *slog.Logger,
+	pctx *proxy.DNSContext,
+	proto proxy.Proto,
+) (srvName string, err error) {
 	from := "tls conn"
 
 	switch proto {
@@ -149,7 +153,7 @@ func clientServerName(pctx *proxy.DNSContext, proto proxy.Proto) (srvName string
 		srvName = tc.ConnectionState().ServerName
 	}
 
-	log.Debug("dnsforward: got client server name %q from %s", srvName, from)
+	logger.Debug("got client server name", "client", pctx.Addr, "name", srvName, "from", from)
 
 	return srvName, nil
 }
@@ -217,7 +217,8 @@ func TestServer_clientIDFromDNSContext(t *testing.T) {
 			}
 
 			srv := &Server{
-				conf: ServerConfig{TLSConfig: tlsConf},
+				logger: testLogger,
+				conf:   ServerConfig{TLSConfig: tlsConf},
 			}
 
 			var (
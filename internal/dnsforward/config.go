@@ -1,9 +1,11 @@
 package dnsforward
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"os"
@@ -15,13 +17,21 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtls"
+	"github.com/AdguardTeam/AdGuardHome/internal/anomaly"
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
 	"github.com/AdguardTeam/AdGuardHome/internal/client"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/AdGuardHome/internal/rrl"
+	"github.com/AdguardTeam/AdGuardHome/internal/secondary"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/container"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/stringutil"
 	"github.com/AdguardTeam/golibs/timeutil"
@@ -70,6 +80,10 @@ type Config struct {
 	// RatelimitWhitelist is the list of whitelisted client IP addresses.
 	RatelimitWhitelist []netip.Addr `yaml:"ratelimit_whitelist"`
 
+	// RRL is the configuration of the response rate limiting.  The clients
+	// from RatelimitWhitelist aren't limited.
+	RRL rrl.Config `yaml:"rrl"`
+
 	// RefuseAny, if true, refuse ANY requests.
 	RefuseAny bool `yaml:"refuse_any"`
 
@@ -116,6 +130,10 @@ type Config struct {
 	// empty slice for this field makes Proxy not trust any address.
 	TrustedProxies []netutil.Prefix `yaml:"trusted_proxies"`
 
+	// ProxyProtocol is the configuration of accepting the PROXY protocol
+	// headers from the load balancers and the reverse proxies.
+	ProxyProtocol ProxyProtocolConfig `yaml:"proxy_protocol"`
+
 	// DNS cache settings
 
 	// CacheSize is the DNS cache size (in bytes).
@@ -281,6 +299,16 @@ type ServerConfig struct {
 	// DNS64Prefixes is a slice of NAT64 prefixes to be used for DNS64.
 	DNS64Prefixes []netip.Prefix
 
+	// DNS64Tags are the client tags for which DNS64 is enabled even if
+	// UseDNS64 is false.
+	DNS64Tags []string
+
+	// DNS64ListenAddrs are the local addresses on which the requests are
+	// received for which DNS64 is enabled even if UseDNS64 is false.  Note
+	// that the local address of a plain DNS-over-UDP request is the address
+	// the listener is bound to, which may be unspecified.
+	DNS64ListenAddrs []netip.Addr
+
 	// UsePrivateRDNS defines if the PTR requests for unknown addresses from
 	// locally-served networks should be resolved via private PTR resolvers.
 	UsePrivateRDNS bool
@@ -288,6 +316,11 @@ type ServerConfig struct {
 	// UseDNS64 defines if DNS64 is enabled for incoming requests.
 	UseDNS64 bool
 
+	// DNS64Discovery defines if the NAT64 prefix should be discovered from
+	// the upstream servers as described in RFC 7050.  DNS64Prefixes are used
+	// until the discovery succeeds.
+	DNS64Discovery bool
+
 	// ServeHTTP3 defines if HTTP/3 is be allowed for incoming requests.
 	ServeHTTP3 bool
 
@@ -297,6 +330,45 @@ type ServerConfig struct {
 
 	// ServePlainDNS defines if plain DNS is allowed for incoming requests.
 	ServePlainDNS bool
+
+	// Activated are the pre-bound DNS sockets passed by the service manager.
+	// If it contains sockets for a protocol, the configured addresses of that
+	// protocol are ignored.  It may be nil.
+	Activated *socketact.DNSListeners
+
+	// MDNS resolves the requests for the names within its domain using mDNS.
+	// It may be nil.
+	MDNS *aghnet.MDNSBridge
+
+	// Bypass blocks the requests for the public DNS resolvers and detects the
+	// clients bypassing the server.  It may be nil.
+	Bypass *bypass.Detector
+
+	// GeoIP looks up the countries and the autonomous systems of the addresses
+	// in the answers.  It may be nil.
+	GeoIP *geoip.Resolver
+
+	// GeoIPPolicy is the global policy of blocking the answers by the countries
+	// and the autonomous systems of their addresses.  It may be nil.
+	GeoIPPolicy *geoip.Policy
+
+	// Anomaly detects the DNS tunneling and the algorithmically generated
+	// domains.  It may be nil.
+	Anomaly *anomaly.Detector
+
+	// Recursor resolves the requests for the upstreams configured as
+	// [recursor.UpstreamAddr].  It may be nil, in which case such upstreams
+	// fail.
+	Recursor *recursor.Resolver
+
+	// Secondary serves the secondary zones transferred from their primary
+	// servers.  It may be nil.
+	Secondary *secondary.Manager
+
+	// UpstreamProxies chooses the proxies for the connections to the upstream
+	// servers.  It may be nil, in which case the upstreams are connected to
+	// directly.
+	UpstreamProxies *upsproxy.Proxies
 }
 
 // UpstreamMode is a enumeration of upstream mode representations.  See
@@ -332,7 +404,7 @@ func (s *Server) newProxyConfig() (conf *proxy.Config, err error) {
 		HTTPSServerName:           aghhttp.UserAgent(),
 		EnableEDNSClientSubnet:    srvConf.EDNSClientSubnet.Enabled,
 		MaxGoroutines:             srvConf.MaxGoroutines,
-		UseDNS64:                  srvConf.UseDNS64,
+		UseDNS64:                  s.proxyDNS64(),
 		DNS64Prefs:                srvConf.DNS64Prefixes,
 		UsePrivateRDNS:            srvConf.UsePrivateRDNS,
 		PrivateSubnets:            s.privateNets,
@@ -364,6 +436,14 @@ func (s *Server) newProxyConfig() (conf *proxy.Config, err error) {
 		return nil, fmt.Errorf("validating plain: %w", err)
 	}
 
+	s.prepareActivated(conf)
+
+	err = s.prepareProxyProto(conf)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
 	if c := srvConf.DNSCryptConfig; c.Enabled {
 		conf.DNSCryptUDPListenAddr = c.UDPListenAddrs
 		conf.DNSCryptTCPListenAddr = c.TCPListenAddrs
@@ -455,7 +535,7 @@ func (s *Server) initDefaultSettings() {
 func (s *Server) prepareIpsetListSettings() (err error) {
 	fn := s.conf.IpsetListFileName
 	if fn == "" {
-		return s.ipset.init(s.conf.IpsetList)
+		return s.ipset.init(s.logger, s.conf.IpsetList)
 	}
 
 	// #nosec G304 -- Trust the path explicitly given by the user.
@@ -467,14 +547,14 @@ func (s *Server) prepareIpsetListSettings() (err error) {
 	ipsets := stringutil.SplitTrimmed(string(data), "\n")
 	ipsets = stringutil.FilterOut(ipsets, IsCommentOrEmpty)
 
-	log.Debug("dns: using %d ipset rules from file %q", len(ipsets), fn)
+	s.logger.Debug("using ipset rules from file", "num", len(ipsets), "path", fn)
 
-	return s.ipset.init(ipsets)
+	return s.ipset.init(s.logger, ipsets)
 }
 
 // loadUpstreams parses upstream DNS servers from the configured file or from
-// the configuration itself.
-func (conf *ServerConfig) loadUpstreams() (upstreams []string, err error) {
+// the configuration itself.  logger is used for logging the operation.
+func (conf *ServerConfig) loadUpstreams(logger *slog.Logger) (upstreams []string, err error) {
 	if conf.UpstreamDNSFileName == "" {
 		return stringutil.FilterOut(conf.UpstreamDNS, IsCommentOrEmpty), nil
 	}
@@ -487,7 +567,7 @@ func (conf *ServerConfig) loadUpstreams() (upstreams []string, err error) {
 
 	upstreams = stringutil.SplitTrimmed(string(data), "\n")
 
-	log.Debug("dnsforward: got %d upstreams in %q", len(upstreams), conf.UpstreamDNSFileName)
+	logger.Debug("got upstreams", "num", len(upstreams), "path", conf.UpstreamDNSFileName)
 
 	return stringutil.FilterOut(upstreams, IsCommentOrEmpty), nil
 }
@@ -594,16 +674,16 @@ func filterOutAddrs(upsConf *proxy.UpstreamConfig, set addrPortSet) (err error)
 }
 
 // ourAddrsSet returns an addrPortSet that contains all the configured listening
-// addresses.
-func (conf *ServerConfig) ourAddrsSet() (m addrPortSet, err error) {
+// addresses.  logger is used for logging the operation.
+func (conf *ServerConfig) ourAddrsSet(logger *slog.Logger) (m addrPortSet, err error) {
 	addrs, unspecPorts := conf.collectDNSAddrs()
 	switch {
 	case addrs.Len() == 0:
-		log.Debug("dnsforward: no listen addresses")
+		logger.Debug("no listen addresses")
 
 		return emptyAddrPortSet{}, nil
 	case unspecPorts.Len() == 0:
-		log.Debug("dnsforward: filtering out addresses %s", addrs)
+		logger.Debug("filtering out addresses", "addrs", addrs)
 
 		return addrs, nil
 	default:
@@ -614,7 +694,7 @@ func (conf *ServerConfig) ourAddrsSet() (m addrPortSet, err error) {
 			return nil, err
 		}
 
-		log.Debug("dnsforward: filtering out addresses %s on ports %d", ifaceAddrs, unspecPorts)
+		logger.Debug("filtering out addresses", "addrs", ifaceAddrs, "ports", unspecPorts)
 
 		return &combinedAddrPortSet{
 			ports: unspecPorts,
@@ -629,7 +709,9 @@ func (s *Server) prepareTLS(proxyConfig *proxy.Config) (err error) {
 		return nil
 	}
 
-	if s.conf.TLSListenAddrs == nil && s.conf.QUICListenAddrs == nil {
+	if s.conf.TLSListenAddrs == nil &&
+		s.conf.QUICListenAddrs == nil &&
+		!s.conf.Activated.HasEncrypted() {
 		return nil
 	}
 
@@ -658,11 +740,14 @@ func (s *Server) prepareTLS(proxyConfig *proxy.Config) (err error) {
 	if s.conf.StrictSNICheck {
 		if len(cert.DNSNames) != 0 {
 			s.conf.dnsNames = cert.DNSNames
-			log.Debug("dns: using certificate's SAN as DNS names: %v", cert.DNSNames)
+			s.logger.Debug("using certificate's san as dns names", "names", cert.DNSNames)
 			slices.Sort(s.conf.dnsNames)
 		} else {
 			s.conf.dnsNames = append(s.conf.dnsNames, cert.Subject.CommonName)
-			log.Debug("dns: using certificate's CN as DNS name: %s", cert.Subject.CommonName)
+			s.logger.Debug(
+				"using certificate's cn as dns name",
+				"name", cert.Subject.CommonName,
+			)
 		}
 	}
 
@@ -711,7 +796,7 @@ func anyNameMatches(dnsNames []string, sni string) (ok bool) {
 // If the server name (from SNI) supplied by client is incorrect - we terminate the ongoing TLS handshake.
 func (s *Server) onGetCertificate(ch *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	if s.conf.StrictSNICheck && !anyNameMatches(s.conf.dnsNames, ch.ServerName) {
-		log.Info("dns: tls: unknown SNI in Client Hello: %s", ch.ServerName)
+		s.logger.Info("tls: unknown sni in client hello", "server_name", ch.ServerName)
 		return nil, fmt.Errorf("invalid SNI")
 	}
 	return &s.conf.cert, nil
@@ -732,16 +817,48 @@ func (s *Server) preparePlain(proxyConf *proxy.Config) (err error) {
 		len(proxyConf.HTTPSListenAddr) +
 		len(proxyConf.QUICListenAddr) +
 		len(proxyConf.TLSListenAddr)
+	if act := s.conf.Activated; act != nil {
+		lenEncrypted += len(act.TLS) + len(act.QUIC)
+	}
+
 	if lenEncrypted == 0 {
 		// TODO(a.garipov): Support full disabling of all DNS.
 		return errors.Error("disabling plain dns requires at least one encrypted protocol")
 	}
 
-	log.Info("dnsforward: warning: plain dns is disabled")
+	s.logger.Warn("plain dns is disabled")
 
 	return nil
 }
 
+// prepareActivated removes the listen addresses of the protocols served over
+// the pre-bound sockets from proxyConf.  It assumes that prepareTLS and
+// preparePlain have already been called.
+func (s *Server) prepareActivated(proxyConf *proxy.Config) {
+	act := s.conf.Activated
+	if act == nil {
+		return
+	}
+
+	if len(act.UDP) > 0 {
+		proxyConf.UDPListenAddr = nil
+	}
+
+	if len(act.TCP) > 0 {
+		proxyConf.TCPListenAddr = nil
+	}
+
+	if len(act.TLS) > 0 {
+		proxyConf.TLSListenAddr = nil
+	}
+
+	if len(act.QUIC) > 0 {
+		proxyConf.QUICListenAddr = nil
+	}
+
+	s.logger.Info("serving activated sockets instead of the configured addresses")
+}
+
 // UpdatedProtectionStatus updates protection state, if the protection was
 // disabled temporarily.  Returns the updated state of protection.
 func (s *Server) UpdatedProtectionStatus() (enabled bool, disabledUntil *time.Time) {
@@ -773,7 +890,7 @@ func (s *Server) UpdatedProtectionStatus() (enabled bool, disabledUntil *time.Ti
 // enableProtectionAfterPause sets the protection configuration to enabled
 // values.  It is intended to be used as a goroutine.
 func (s *Server) enableProtectionAfterPause() {
-	defer log.OnPanic("dns: enabling protection after pause")
+	defer slogutil.RecoverAndLog(context.TODO(), s.logger)
 
 	defer s.protectionUpdateInProgress.Store(false)
 
@@ -784,7 +901,7 @@ func (s *Server) enableProtectionAfterPause() {
 
 	s.dnsFilter.SetProtectionStatus(true, nil)
 
-	log.Info("dns: protection is restarted after pause")
+	s.logger.Info("protection is restarted after pause")
 }
 
 // validateCacheTTL returns an error if the configuration of the cache TTL
@@ -1,13 +1,17 @@
 package dnsforward
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/miekg/dns"
 )
 
@@ -15,6 +19,9 @@ import (
 // a corresponding [*proxy.UpstreamConfig] and checks the actual DNS
 // availability of each upstream.
 type upstreamConfigValidator struct {
+	// logger is used for logging the operation of the validator.
+	logger *slog.Logger
+
 	// generalUpstreamResults contains upstream results of a general section.
 	generalUpstreamResults map[string]*upstreamResult
 
@@ -56,29 +63,35 @@ type parseResult struct {
 
 // newUpstreamConfigValidator parses the upstream configuration and returns a
 // validator for it.  cv already contains the parsed upstreams along with errors
-// related.
+// related.  rec resolves the recursive upstreams, if any, and prx chooses the
+// proxies for the general and fallback upstreams.  logger is used for logging
+// the operation of the validator.
 func newUpstreamConfigValidator(
+	logger *slog.Logger,
 	general []string,
 	fallback []string,
 	private []string,
 	opts *upstream.Options,
+	rec *recursor.Resolver,
+	prx *upsproxy.Proxies,
 ) (cv *upstreamConfigValidator) {
 	cv = &upstreamConfigValidator{
+		logger:                  logger,
 		generalUpstreamResults:  map[string]*upstreamResult{},
 		fallbackUpstreamResults: map[string]*upstreamResult{},
 		privateUpstreamResults:  map[string]*upstreamResult{},
 	}
 
-	conf, err := proxy.ParseUpstreamsConfig(general, opts)
-	cv.generalParseResults = collectErrResults(general, err)
+	conf, err := ParseUpstreamsConfig(general, opts, rec, prx)
+	cv.generalParseResults = collectErrResults(logger, general, err)
 	insertConfResults(conf, cv.generalUpstreamResults)
 
-	conf, err = proxy.ParseUpstreamsConfig(fallback, opts)
-	cv.fallbackParseResults = collectErrResults(fallback, err)
+	conf, err = ParseUpstreamsConfig(fallback, opts, rec, prx)
+	cv.fallbackParseResults = collectErrResults(logger, fallback, err)
 	insertConfResults(conf, cv.fallbackUpstreamResults)
 
 	conf, err = proxy.ParseUpstreamsConfig(private, opts)
-	cv.privateParseResults = collectErrResults(private, err)
+	cv.privateParseResults = collectErrResults(logger, private, err)
 	insertConfResults(conf, cv.privateUpstreamResults)
 
 	return cv
@@ -86,8 +99,12 @@ func newUpstreamConfigValidator(
 
 // collectErrResults parses err and returns parsing results containing the
 // original upstream configuration line and the corresponding error.  err can be
-// nil.
-func collectErrResults(lines []string, err error) (results []*parseResult) {
+// nil.  logger is used for logging the unexpected errors.
+func collectErrResults(
+	logger *slog.Logger,
+	lines []string,
+	err error,
+) (results []*parseResult) {
 	if err == nil {
 		return nil
 	}
@@ -97,7 +114,7 @@ func collectErrResults(lines []string, err error) (results []*parseResult) {
 
 	wrapper, ok := err.(errors.WrapperSlice)
 	if !ok {
-		log.Debug("dnsforward: configvalidator: unwrapping: %s", err)
+		logger.Debug("configvalidator: unwrapping", slogutil.KeyError, err)
 
 		return nil
 	}
@@ -107,7 +124,11 @@ func collectErrResults(lines []string, err error) (results []*parseResult) {
 	for i, e := range errs {
 		var parseErr *proxy.ParseError
 		if !errors.As(e, &parseErr) {
-			log.Debug("dnsforward: configvalidator: inserting unexpected error %d: %s", i, err)
+			logger.Debug(
+				"configvalidator: inserting unexpected error",
+				"idx", i,
+				slogutil.KeyError, err,
+			)
 
 			continue
 		}
@@ -164,25 +185,13 @@ func insertListResults(ups []upstream.Upstream, results map[string]*upstreamResu
 // [upsConfValidator.close] method, since it makes no sense to check the closed
 // upstreams.
 func (cv *upstreamConfigValidator) check() {
-	const (
-		// testTLD is the special-use fully-qualified domain name for testing
-		// the DNS server reachability.
-		//
-		// See https://datatracker.ietf.org/doc/html/rfc6761#section-6.2.
-		testTLD = "test."
-
-		// inAddrARPATLD is the special-use fully-qualified domain name for PTR
-		// IP address resolution.
-		//
-		// See https://datatracker.ietf.org/doc/html/rfc1035#section-3.5.
-		inAddrARPATLD = "in-addr.arpa."
-	)
+	// inAddrARPATLD is the special-use fully-qualified domain name for PTR IP
+	// address resolution.
+	//
+	// See https://datatracker.ietf.org/doc/html/rfc1035#section-3.5.
+	const inAddrARPATLD = "in-addr.arpa."
 
-	commonChecker := &healthchecker{
-		hostname: testTLD,
-		qtype:    dns.TypeA,
-		ansEmpty: true,
-	}
+	commonChecker := newCommonHealthchecker()
 
 	arpaChecker := &healthchecker{
 		hostname: inAddrARPATLD,
@@ -196,13 +205,13 @@ func (cv *upstreamConfigValidator) check() {
 		len(cv.privateUpstreamResults))
 
 	for _, res := range cv.generalUpstreamResults {
-		go checkSrv(res, wg, commonChecker)
+		go cv.checkSrv(res, wg, commonChecker)
 	}
 	for _, res := range cv.fallbackUpstreamResults {
-		go checkSrv(res, wg, commonChecker)
+		go cv.checkSrv(res, wg, commonChecker)
 	}
 	for _, res := range cv.privateUpstreamResults {
-		go checkSrv(res, wg, arpaChecker)
+		go cv.checkSrv(res, wg, arpaChecker)
 	}
 
 	wg.Wait()
@@ -211,8 +220,15 @@ func (cv *upstreamConfigValidator) check() {
 // checkSrv runs hc on the server from res, if any, and stores any occurred
 // error in res.  wg is always marked done in the end.  It is intended to be
 // used as a goroutine.
-func checkSrv(res *upstreamResult, wg *sync.WaitGroup, hc *healthchecker) {
-	defer log.OnPanic(fmt.Sprintf("dnsforward: checking upstream %s", res.server.Address()))
+func (cv *upstreamConfigValidator) checkSrv(
+	res *upstreamResult,
+	wg *sync.WaitGroup,
+	hc *healthchecker,
+) {
+	defer slogutil.RecoverAndLog(
+		context.TODO(),
+		cv.logger.With("upstream", res.server.Address()),
+	)
 	defer wg.Done()
 
 	res.err = hc.check(res.server)
@@ -264,18 +280,18 @@ func (cv *upstreamConfigValidator) status() (results map[string]string) {
 	results = map[string]string{}
 
 	for original, res := range cv.generalUpstreamResults {
-		upstreamResultToStatus(generalSection, string(original), res, results)
+		cv.upstreamResultToStatus(generalSection, string(original), res, results)
 	}
 	for original, res := range cv.fallbackUpstreamResults {
-		upstreamResultToStatus(fallbackSection, string(original), res, results)
+		cv.upstreamResultToStatus(fallbackSection, string(original), res, results)
 	}
 	for original, res := range cv.privateUpstreamResults {
-		upstreamResultToStatus(privateSection, string(original), res, results)
+		cv.upstreamResultToStatus(privateSection, string(original), res, results)
 	}
 
-	parseResultToStatus(generalTextLabel, generalSection, cv.generalParseResults, results)
-	parseResultToStatus(fallbackTextLabel, fallbackSection, cv.fallbackParseResults, results)
-	parseResultToStatus(privateTextLabel, privateSection, cv.privateParseResults, results)
+	cv.parseResultToStatus(generalTextLabel, generalSection, cv.generalParseResults, results)
+	cv.parseResultToStatus(fallbackTextLabel, fallbackSection, cv.fallbackParseResults, results)
+	cv.parseResultToStatus(privateTextLabel, privateSection, cv.privateParseResults, results)
 
 	return results
 }
@@ -287,7 +303,7 @@ func (cv *upstreamConfigValidator) status() (results map[string]string) {
 // TODO(e.burkov):  Currently, the HTTP handler expects that all the results are
 // put together in a single map, which may lead to collisions, see AG-27539.
 // Improve the results compilation.
-func upstreamResultToStatus(
+func (cv *upstreamConfigValidator) upstreamResultToStatus(
 	section string,
 	original string,
 	res *upstreamResult,
@@ -303,14 +319,14 @@ func upstreamResultToStatus(
 	case "":
 		resMap[original] = val
 	case val:
-		log.Debug("dnsforward: duplicating %s config line %q", section, original)
+		cv.logger.Debug("duplicating config line", "section", section, "line", original)
 	default:
-		log.Debug(
-			"dnsforward: warning: %s config line %q (%v) had different result %v",
-			section,
-			val,
-			original,
-			prevVal,
+		cv.logger.Warn(
+			"config line had different result",
+			"section", section,
+			"line", original,
+			"result", val,
+			"prev_result", prevVal,
 		)
 	}
 }
@@ -325,7 +341,7 @@ func upstreamResultToStatus(
 //
 // Where sectionTextLabel is a section text label of a localization and line is
 // a line number.
-func parseResultToStatus(
+func (cv *upstreamConfigValidator) parseResultToStatus(
 	textLabel string,
 	section string,
 	results []*parseResult,
@@ -335,7 +351,7 @@ func parseResultToStatus(
 		original := res.original
 		_, ok := resMap[original]
 		if ok {
-			log.Debug("dnsforward: duplicating %s parsing error %q", section, original)
+			cv.logger.Debug("duplicating parsing error", "section", section, "line", original)
 
 			continue
 		}
@@ -371,6 +387,22 @@ func (err domainSpecificTestError) Unwrap() (wrapped error) {
 	return err.Err
 }
 
+// testTLD is the special-use fully-qualified domain name for testing the DNS
+// server reachability.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6761#section-6.2.
+const testTLD = "test."
+
+// newCommonHealthchecker returns a healthchecker for the general upstream
+// servers.
+func newCommonHealthchecker() (h *healthchecker) {
+	return &healthchecker{
+		hostname: testTLD,
+		qtype:    dns.TypeA,
+		ansEmpty: true,
+	}
+}
+
 // healthchecker checks the upstream's status by exchanging with it.
 type healthchecker struct {
 	// hostname is the name of the host to put into healthcheck DNS request.
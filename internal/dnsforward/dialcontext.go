@@ -9,14 +9,13 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
 )
 
 // DialContext is an [aghnet.DialContextFunc] that uses s to resolve hostnames.
 // addr should be a valid host:port address, where host could be a domain name
 // or an IP address.
 func (s *Server) DialContext(ctx context.Context, network, addr string) (conn net.Conn, err error) {
-	log.Debug("dnsforward: dialing %q for network %q", addr, network)
+	s.logger.Debug("dialing", "addr", addr, "network", network)
 
 	host, portStr, err := net.SplitHostPort(addr)
 	if err != nil {
@@ -44,7 +43,7 @@ func (s *Server) DialContext(ctx context.Context, network, addr string) (conn ne
 		return nil, fmt.Errorf("no addresses for host %q", host)
 	}
 
-	log.Debug("dnsforward: resolved %q: %v", host, ips)
+	s.logger.Debug("resolved", "host", host, "ips", ips)
 
 	var dialErrs []error
 	for _, ip := range ips {
@@ -1,47 +1,474 @@
 package dnsforward
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
 	"net/netip"
+	"slices"
+	"time"
 
 	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/miekg/dns"
 )
 
+// dns64WellKnownPref is the default prefix to use in an algorithmic mapping for
+// DNS64.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6052#section-2.1.
+var dns64WellKnownPref = netip.MustParsePrefix("64:ff9b::/96")
+
+// dns64DefaultTTL is the maximum TTL for synthesized DNS64 responses with no
+// SOA records in seconds.
+//
+// See https://datatracker.ietf.org/doc/html/rfc6147#section-5.1.7.
+const dns64DefaultTTL uint32 = 600
+
+// ipv4OnlyFQDN is the well-known name having only the A records, which is used
+// to discover the NAT64 prefix.
+//
+// See https://datatracker.ietf.org/doc/html/rfc7050#section-2.
+const ipv4OnlyFQDN = "ipv4only.arpa."
+
+// NAT64 prefix discovery intervals.
+const (
+	// nat64RetryIvlMin is the interval before the first retry of the failed
+	// discovery.  It's doubled after each subsequent failure.
+	nat64RetryIvlMin = 1 * time.Second
+
+	// nat64RetryIvlMax is the maximum interval between the retries of the
+	// failed discovery.
+	nat64RetryIvlMax = 1 * time.Hour
+
+	// nat64RefreshAdvance is how long before the expiration of the discovered
+	// prefix it's rediscovered.
+	nat64RefreshAdvance = 10 * time.Second
+
+	// nat64RefreshIvlMin is the minimum interval between the successful
+	// discoveries, which protects from the answers with low TTLs.
+	nat64RefreshIvlMin = 1 * time.Minute
+)
+
+// ipv4OnlyAddrs are the well-known IPv4 addresses of [ipv4OnlyFQDN].
+var ipv4OnlyAddrs = []netip.Addr{
+	netip.AddrFrom4([4]byte{192, 0, 0, 170}),
+	netip.AddrFrom4([4]byte{192, 0, 0, 171}),
+}
+
 // setupDNS64 initializes DNS64 settings, the NAT64 prefixes in particular.  If
-// the DNS64 feature is enabled and no prefixes are configured, the default
-// Well-Known Prefix is used, just like Section 5.2 of RFC 6147 prescribes.  Any
-// configured set of prefixes discards the default Well-Known prefix unless it
-// is specified explicitly.  Each prefix also validated to be a valid IPv6
-// CIDR with a maximum length of 96 bits.  The first specified prefix is then
-// used to synthesize AAAA records.
-func (s *Server) setupDNS64() {
-	if !s.conf.UseDNS64 {
-		return
+// no prefixes are configured, the default Well-Known Prefix is used, just like
+// Section 5.2 of RFC 6147 prescribes.  Any configured set of prefixes discards
+// the default Well-Known prefix unless it is specified explicitly.  The first
+// specified prefix is then used to synthesize AAAA records.  The prefix is set
+// up even if DNS64 is disabled globally, since it may be enabled for some of
+// the clients.
+func (s *Server) setupDNS64() (err error) {
+	pref := dns64WellKnownPref
+	if len(s.conf.DNS64Prefixes) > 0 {
+		pref = s.conf.DNS64Prefixes[0]
+	}
+
+	// The proxy validates the prefixes itself, but it doesn't get them unless
+	// it performs DNS64.
+	if !pref.Addr().Is6() || pref.Bits() > proxy.NAT64PrefixLength*8 {
+		return fmt.Errorf("prefix %s: must be an ipv6 prefix of at most 96 bits", pref)
 	}
 
-	if len(s.conf.DNS64Prefixes) == 0 {
-		// dns64WellKnownPref is the default prefix to use in an algorithmic
-		// mapping for DNS64.
-		//
-		// See https://datatracker.ietf.org/doc/html/rfc6052#section-2.1.
-		dns64WellKnownPref := netip.MustParsePrefix("64:ff9b::/96")
+	s.dns64Pref.Store(&pref)
+
+	return nil
+}
 
-		s.dns64Pref = dns64WellKnownPref
-	} else {
-		s.dns64Pref = s.conf.DNS64Prefixes[0]
+// proxyDNS64 returns true if DNS64 is entirely performed by the proxy, which is
+// the case when it's enabled for all requests and the prefixes are static.
+func (s *Server) proxyDNS64() (ok bool) {
+	return s.conf.UseDNS64 && !s.conf.DNS64Discovery
+}
+
+// dns64Prefix returns the NAT64 prefix to use for the request and true, if
+// DNS64 is enabled for it.
+func (s *Server) dns64Prefix(dctx *dnsContext) (pref netip.Prefix, ok bool) {
+	p := s.dns64Pref.Load()
+	if p == nil || !s.isDNS64Enabled(dctx) {
+		return netip.Prefix{}, false
 	}
+
+	return *p, true
 }
 
-// mapDNS64 maps ip to IPv6 address using configured DNS64 prefix.  ip must be a
-// valid IPv4.  It panics, if there are no configured DNS64 prefixes, because
-// synthesis should not be performed unless DNS64 function enabled.
-func (s *Server) mapDNS64(ip netip.Addr) (mapped net.IP) {
-	pref := s.dns64Pref.Masked().Addr().As16()
+// isDNS64Enabled returns true if DNS64 is enabled for the request globally, for
+// the client, one of its tags, or the listener which received the request.
+func (s *Server) isDNS64Enabled(dctx *dnsContext) (ok bool) {
+	if s.conf.UseDNS64 {
+		return true
+	}
+
+	if setts := dctx.setts; setts != nil {
+		if setts.UseDNS64 {
+			return true
+		}
+
+		for _, tag := range setts.ClientTags {
+			if slices.Contains(s.conf.DNS64Tags, tag) {
+				return true
+			}
+		}
+	}
+
+	if len(s.conf.DNS64ListenAddrs) == 0 {
+		return false
+	}
+
+	return slices.Contains(s.conf.DNS64ListenAddrs, localAddr(dctx.proxyCtx))
+}
+
+// localAddr returns the local address on which the request of pctx has been
+// received, if it's known.
+func localAddr(pctx *proxy.DNSContext) (addr netip.Addr) {
+	var netAddr net.Addr
+	switch {
+	case pctx.Conn != nil:
+		netAddr = pctx.Conn.LocalAddr()
+	case pctx.QUICConnection != nil:
+		netAddr = pctx.QUICConnection.LocalAddr()
+	case pctx.HTTPRequest != nil:
+		netAddr, _ = pctx.HTTPRequest.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	default:
+		// Go on.
+	}
+
+	if netAddr == nil {
+		return netip.Addr{}
+	}
+
+	return netutil.NetAddrToAddrPort(netAddr).Addr().Unmap()
+}
+
+// mapDNS64 maps ip to IPv6 address using pref.  ip must be a valid IPv4.
+func mapDNS64(pref netip.Prefix, ip netip.Addr) (mapped net.IP) {
+	prefData := pref.Masked().Addr().As16()
 	ipData := ip.As4()
 
 	mapped = make(net.IP, net.IPv6len)
-	copy(mapped[:proxy.NAT64PrefixLength], pref[:])
+	copy(mapped[:proxy.NAT64PrefixLength], prefData[:])
 	copy(mapped[proxy.NAT64PrefixLength:], ipData[:])
 
 	return mapped
 }
+
+// resolveDNS64 resolves the request of dctx using prx and performs DNS64 for
+// it, if it's enabled for the request and isn't performed by the proxy itself.
+func (s *Server) resolveDNS64(prx *proxy.Proxy, dctx *dnsContext) (err error) {
+	pctx := dctx.proxyCtx
+
+	pref, ok := s.dns64Prefix(dctx)
+	if !ok || s.proxyDNS64() {
+		return prx.Resolve(pctx)
+	}
+
+	switch pctx.Req.Question[0].Qtype {
+	case dns.TypeAAAA:
+		err = prx.Resolve(pctx)
+		if err != nil {
+			return err
+		}
+
+		synthDNS64(dctx.logger, prx, pctx, pref)
+
+		return nil
+	case dns.TypePTR:
+		ok, err = s.resolveDNS64PTR(prx, pctx, pref)
+		if ok {
+			return err
+		}
+	default:
+		// Go on.
+	}
+
+	return prx.Resolve(pctx)
+}
+
+// newDNS64Context returns a copy of pctx with a copy of its request for the
+// additional resolving performed for DNS64.
+func newDNS64Context(pctx *proxy.DNSContext) (dctx *proxy.DNSContext) {
+	return &proxy.DNSContext{
+		Proto:                pctx.Proto,
+		Addr:                 pctx.Addr,
+		Req:                  pctx.Req.Copy(),
+		CustomUpstreamConfig: pctx.CustomUpstreamConfig,
+		IsPrivateClient:      pctx.IsPrivateClient,
+	}
+}
+
+// synthDNS64 synthesizes the AAAA records of the response in pctx from the A
+// records of the same name, if the response contains no AAAA records outside
+// of pref.  See Section 5.1 of RFC 6147.  logger is used for logging the
+// operation.
+func synthDNS64(
+	logger *slog.Logger,
+	prx *proxy.Proxy,
+	pctx *proxy.DNSContext,
+	pref netip.Prefix,
+) {
+	res := pctx.Res
+	if res == nil || res.Rcode != dns.RcodeSuccess {
+		return
+	}
+
+	// Remove the AAAA records with the addresses within the NAT64 prefix, since
+	// they are synthesized by some other DNS64 server and may be unroutable.
+	res.Answer = slices.DeleteFunc(res.Answer, func(rr dns.RR) (del bool) {
+		aaaa, ok := rr.(*dns.AAAA)
+		if !ok {
+			return false
+		}
+
+		ip, ok := netip.AddrFromSlice(aaaa.AAAA)
+
+		return ok && pref.Contains(ip)
+	})
+
+	if slices.ContainsFunc(res.Answer, isAAAA) {
+		return
+	}
+
+	aCtx := newDNS64Context(pctx)
+	aCtx.Req.Question[0].Qtype = dns.TypeA
+
+	err := prx.Resolve(aCtx)
+	if err != nil {
+		logger.Debug("dns64: resolving a records", slogutil.KeyError, err)
+
+		return
+	}
+
+	aRes := aCtx.Res
+	if aRes == nil || aRes.Rcode != dns.RcodeSuccess {
+		return
+	}
+
+	maxTTL := dns64SynTTL(res)
+	answer := make([]dns.RR, 0, len(aRes.Answer))
+	for _, rr := range aRes.Answer {
+		switch rr := rr.(type) {
+		case *dns.A:
+			ip, ok := netip.AddrFromSlice(rr.A)
+			if !ok {
+				continue
+			}
+
+			answer = append(answer, &dns.AAAA{
+				Hdr: dns.RR_Header{
+					Name:   rr.Hdr.Name,
+					Rrtype: dns.TypeAAAA,
+					Class:  rr.Hdr.Class,
+					Ttl:    min(rr.Hdr.Ttl, maxTTL),
+				},
+				AAAA: mapDNS64(pref, ip.Unmap()),
+			})
+		case *dns.CNAME, *dns.DNAME:
+			answer = append(answer, rr)
+		default:
+			// Go on.
+		}
+	}
+
+	if !slices.ContainsFunc(answer, isAAAA) {
+		return
+	}
+
+	res.Answer = answer
+	res.Ns = aRes.Ns
+}
+
+// isAAAA returns true if rr is an AAAA record.
+func isAAAA(rr dns.RR) (ok bool) {
+	return rr.Header().Rrtype == dns.TypeAAAA
+}
+
+// dns64SynTTL returns the maximum TTL for the synthesized records, which is the
+// TTL of the SOA record in the negative response res, if there is one.
+func dns64SynTTL(res *dns.Msg) (ttl uint32) {
+	for _, rr := range res.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Hdr.Ttl
+		}
+	}
+
+	return dns64DefaultTTL
+}
+
+// resolveDNS64PTR resolves the PTR request in pctx for an address within pref
+// by synthesizing a CNAME record pointing to the reversed name of the embedded
+// IPv4 address.  See Section 5.3.1 of RFC 6147.  ok is false if the request
+// isn't for such an address or the embedded address mustn't be resolved.
+func (s *Server) resolveDNS64PTR(
+	prx *proxy.Proxy,
+	pctx *proxy.DNSContext,
+	pref netip.Prefix,
+) (ok bool, err error) {
+	q := pctx.Req.Question[0]
+	ip, err := netutil.IPFromReversedAddr(q.Name)
+	if err != nil || !ip.Is6() || !pref.Contains(ip) {
+		return false, nil
+	}
+
+	ipData := ip.As16()
+	ip4 := netip.AddrFrom4([4]byte(ipData[proxy.NAT64PrefixLength:]))
+
+	// Don't check the error, since it's only returned for invalid addresses.
+	arpa, _ := netutil.IPToReversedAddr(ip4.AsSlice())
+	arpa = dns.Fqdn(arpa)
+
+	ptrCtx := newDNS64Context(pctx)
+	ptrCtx.Req.Question[0].Name = arpa
+	if s.privateNets.Contains(ip4) {
+		if !s.conf.UsePrivateRDNS || !pctx.IsPrivateClient {
+			return false, nil
+		}
+
+		ptrCtx.RequestedPrivateRDNS = netip.PrefixFrom(ip4, ip4.BitLen())
+	}
+
+	err = prx.Resolve(ptrCtx)
+	if err != nil {
+		return true, fmt.Errorf("resolving %q: %w", arpa, err)
+	}
+
+	res := ptrCtx.Res
+	if res == nil {
+		return true, errors.Error("no response")
+	}
+
+	ttl := dns64DefaultTTL
+	if len(res.Answer) > 0 {
+		ttl = res.Answer[0].Header().Ttl
+	}
+
+	cname := &dns.CNAME{
+		Hdr: dns.RR_Header{
+			Name:   q.Name,
+			Rrtype: dns.TypeCNAME,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Target: arpa,
+	}
+
+	res.Question = []dns.Question{q}
+	res.Answer = append([]dns.RR{cname}, res.Answer...)
+	pctx.Res = res
+
+	return true, nil
+}
+
+// discoverNAT64 discovers the NAT64 prefix using prx and uses it for DNS64, if
+// found, until done is closed.  The prefix is rediscovered before the TTL of
+// the answer expires, and the failed discovery is retried with an exponential
+// backoff, see Section 3 of RFC 7050.  It is intended to be used as a
+// goroutine.
+func (s *Server) discoverNAT64(prx *proxy.Proxy, done <-chan struct{}) {
+	defer slogutil.RecoverAndLog(context.TODO(), s.logger)
+
+	retryIvl := nat64RetryIvlMin
+	for {
+		var ivl time.Duration
+		pref, ttl, err := discoverNAT64Prefix(prx)
+		if err != nil {
+			s.logger.Warn(
+				"discovering nat64 prefix",
+				"retry_in", retryIvl,
+				slogutil.KeyError, err,
+			)
+
+			ivl, retryIvl = retryIvl, min(2*retryIvl, nat64RetryIvlMax)
+		} else {
+			ivl, retryIvl = nat64RefreshIvl(ttl), nat64RetryIvlMin
+
+			prev := s.dns64Pref.Swap(&pref)
+			if prev == nil || *prev != pref {
+				s.logger.Info("discovered nat64 prefix", "prefix", pref)
+			}
+
+			s.logger.Debug("rediscovering nat64 prefix", "in", ivl)
+		}
+
+		timer := time.NewTimer(ivl)
+		select {
+		case <-timer.C:
+			// Go on.
+		case <-done:
+			timer.Stop()
+
+			return
+		}
+	}
+}
+
+// nat64RefreshIvl returns the interval before the rediscovery of the NAT64
+// prefix from the answer with ttl in seconds.
+func nat64RefreshIvl(ttl uint32) (ivl time.Duration) {
+	ivl = time.Duration(ttl)*time.Second - nat64RefreshAdvance
+
+	return max(ivl, nat64RefreshIvlMin)
+}
+
+// discoverNAT64Prefix resolves the AAAA records of [ipv4OnlyFQDN] using prx and
+// returns the NAT64 prefix found in them along with the lowest TTL of the
+// answer in seconds.  See Section 3 of RFC 7050.
+func discoverNAT64Prefix(prx *proxy.Proxy) (pref netip.Prefix, ttl uint32, err error) {
+	dctx := &proxy.DNSContext{
+		Proto: proxy.ProtoUDP,
+		Req:   (&dns.Msg{}).SetQuestion(ipv4OnlyFQDN, dns.TypeAAAA),
+	}
+
+	err = prx.Resolve(dctx)
+	if err != nil {
+		return netip.Prefix{}, 0, fmt.Errorf("resolving %q: %w", ipv4OnlyFQDN, err)
+	} else if dctx.Res == nil {
+		return netip.Prefix{}, 0, errors.Error("no response")
+	}
+
+	pref, err = nat64PrefixFrom(dctx.Res.Answer)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return netip.Prefix{}, 0, err
+	}
+
+	ttl = dctx.Res.Answer[0].Header().Ttl
+	for _, rr := range dctx.Res.Answer[1:] {
+		ttl = min(ttl, rr.Header().Ttl)
+	}
+
+	return pref, ttl, nil
+}
+
+// nat64PrefixFrom returns the NAT64 prefix from the first AAAA record in answer
+// containing one of [ipv4OnlyAddrs].  Only the prefixes of 96 bits are
+// recognized, since the synthesis doesn't support the others.
+func nat64PrefixFrom(answer []dns.RR) (pref netip.Prefix, err error) {
+	for _, rr := range answer {
+		aaaa, ok := rr.(*dns.AAAA)
+		if !ok {
+			continue
+		}
+
+		ip, ok := netip.AddrFromSlice(aaaa.AAAA)
+		if !ok || !ip.Is6() || ip.Is4In6() {
+			continue
+		}
+
+		ipData := ip.As16()
+		embedded := netip.AddrFrom4([4]byte(ipData[proxy.NAT64PrefixLength:]))
+		if slices.Contains(ipv4OnlyAddrs, embedded) {
+			return netip.PrefixFrom(ip, proxy.NAT64PrefixLength*8).Masked(), nil
+		}
+	}
+
+	return netip.Prefix{}, errors.Error("no nat64 prefix of 96 bits in the response")
+}
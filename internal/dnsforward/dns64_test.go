@@ -2,11 +2,16 @@ package dnsforward
 
 import (
 	"net"
+	"net/netip"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/miekg/dns"
@@ -361,3 +366,240 @@ func TestServer_dns64WithDisabledRDNS(t *testing.T) {
 
 	assert.Equal(t, dns.RcodeNameError, resp.Rcode)
 }
+
+func TestServer_HandleDNSRequest_dns64Client(t *testing.T) {
+	t.Parallel()
+
+	const (
+		domain = "ipv4.only."
+		tag    = "device_phone"
+	)
+
+	aRR := newRR(t, domain, dns.TypeA, 3600, net.IP{1, 2, 3, 4})
+	mappedIPv6 := net.ParseIP("64:ff9b::102:304")
+
+	upsHdlr := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := (&dns.Msg{}).SetReply(req)
+		if req.Question[0].Qtype == dns.TypeA {
+			resp.Answer = []dns.RR{aRR}
+		}
+
+		require.NoError(testutil.PanicT{}, w.WriteMsg(resp))
+	})
+	upsAddr := aghtest.StartLocalhostUpstream(t, upsHdlr).String()
+
+	testCases := []struct {
+		setts   func(setts *filtering.Settings)
+		name    string
+		tags    []string
+		wantAns []dns.RR
+	}{{
+		setts:   func(_ *filtering.Settings) {},
+		name:    "disabled",
+		tags:    []string{tag},
+		wantAns: nil,
+	}, {
+		setts: func(setts *filtering.Settings) {
+			setts.UseDNS64 = true
+		},
+		name: "client",
+		tags: nil,
+		wantAns: []dns.RR{&dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:     domain,
+				Rrtype:   dns.TypeAAAA,
+				Class:    dns.ClassINET,
+				Ttl:      maxDNS64SynTTL,
+				Rdlength: 16,
+			},
+			AAAA: mappedIPv6,
+		}},
+	}, {
+		setts: func(setts *filtering.Settings) {
+			setts.ClientTags = []string{tag}
+		},
+		name: "tag",
+		tags: []string{tag},
+		wantAns: []dns.RR{&dns.AAAA{
+			Hdr: dns.RR_Header{
+				Name:     domain,
+				Rrtype:   dns.TypeAAAA,
+				Class:    dns.ClassINET,
+				Ttl:      maxDNS64SynTTL,
+				Rdlength: 16,
+			},
+			AAAA: mappedIPv6,
+		}},
+	}}
+
+	client := &dns.Client{
+		Net:     string(proxy.ProtoTCP),
+		Timeout: testTimeout,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := createTestServer(t, &filtering.Config{
+				BlockingMode: filtering.BlockingModeDefault,
+			}, ServerConfig{
+				UDPListenAddrs: []*net.UDPAddr{{}},
+				TCPListenAddrs: []*net.TCPAddr{{}},
+				DNS64Tags:      tc.tags,
+				Config: Config{
+					UpstreamMode:     UpstreamModeLoadBalance,
+					EDNSClientSubnet: &EDNSClientSubnet{Enabled: false},
+					UpstreamDNS:      []string{upsAddr},
+					FilterHandler: func(_ netip.Addr, _ string, setts *filtering.Settings) {
+						tc.setts(setts)
+					},
+				},
+				ServePlainDNS: true,
+			})
+			startDeferStop(t, s)
+
+			req := (&dns.Msg{}).SetQuestion(domain, dns.TypeAAAA)
+
+			resp, _, err := client.Exchange(req, s.proxy().Addr(proxy.ProtoTCP).String())
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantAns, resp.Answer)
+		})
+	}
+}
+
+func TestNAT64PrefixFrom(t *testing.T) {
+	t.Parallel()
+
+	newAAAA := func(ip string) (rr dns.RR) {
+		return newRR(t, ipv4OnlyFQDN, dns.TypeAAAA, 3600, net.ParseIP(ip))
+	}
+
+	testCases := []struct {
+		want       netip.Prefix
+		name       string
+		wantErrMsg string
+		answer     []dns.RR
+	}{{
+		want:       netip.MustParsePrefix("2001:db8:1:2:3:4::/96"),
+		name:       "success",
+		wantErrMsg: "",
+		answer:     []dns.RR{newAAAA("2001:db8:1:2:3:4:c000:aa")},
+	}, {
+		want:       netip.MustParsePrefix("2001:db8::/96"),
+		name:       "second_addr",
+		wantErrMsg: "",
+		answer: []dns.RR{
+			newRR(t, ipv4OnlyFQDN, dns.TypeCNAME, 3600, "nat64.example."),
+			newAAAA("2001:db8::c000:ab"),
+		},
+	}, {
+		want:       netip.Prefix{},
+		name:       "other_addr",
+		wantErrMsg: "no nat64 prefix of 96 bits in the response",
+		answer:     []dns.RR{newAAAA("2001:db8::102:304")},
+	}, {
+		want:       netip.Prefix{},
+		name:       "empty",
+		wantErrMsg: "no nat64 prefix of 96 bits in the response",
+		answer:     nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pref, err := nat64PrefixFrom(tc.answer)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, pref)
+		})
+	}
+}
+
+func TestNAT64RefreshIvl(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		want time.Duration
+		ttl  uint32
+	}{{
+		name: "hour",
+		want: time.Hour - nat64RefreshAdvance,
+		ttl:  3600,
+	}, {
+		name: "low",
+		want: nat64RefreshIvlMin,
+		ttl:  30,
+	}, {
+		name: "zero",
+		want: nat64RefreshIvlMin,
+		ttl:  0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.want, nat64RefreshIvl(tc.ttl))
+		})
+	}
+}
+
+func TestServer_discoverNAT64(t *testing.T) {
+	t.Parallel()
+
+	wantPref := netip.MustParsePrefix("2001:db8::/96")
+	ans := newRR(t, ipv4OnlyFQDN, dns.TypeAAAA, 3600, net.ParseIP("2001:db8::c000:aa"))
+
+	var reqNum atomic.Int32
+	ups := aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+		if reqNum.Add(1) == 1 {
+			return nil, errors.Error("test error")
+		}
+
+		resp = (&dns.Msg{}).SetReply(req)
+		resp.Answer = []dns.RR{ans}
+
+		return resp, nil
+	})
+
+	prx, err := proxy.New(&proxy.Config{
+		UpstreamConfig: &proxy.UpstreamConfig{
+			Upstreams: []upstream.Upstream{ups},
+		},
+	})
+	require.NoError(t, err)
+
+	s := &Server{
+		logger: testLogger,
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		s.discoverNAT64(prx, done)
+	}()
+
+	// The first attempt fails, so the prefix is only discovered after the
+	// retry.
+	require.Eventually(t, func() (ok bool) {
+		return s.dns64Pref.Load() != nil
+	}, 2*nat64RetryIvlMin, nat64RetryIvlMin/10)
+
+	assert.Equal(t, wantPref, *s.dns64Pref.Load())
+	assert.Equal(t, int32(2), reqNum.Load())
+
+	close(done)
+	require.Eventually(t, func() (ok bool) {
+		select {
+		case <-stopped:
+			return true
+		default:
+			return false
+		}
+	}, testTimeout, testTimeout/10)
+}
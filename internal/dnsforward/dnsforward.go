@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/netip"
@@ -17,16 +18,19 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
 	"github.com/AdguardTeam/AdGuardHome/internal/client"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
 	"github.com/AdguardTeam/AdGuardHome/internal/rdns"
+	"github.com/AdguardTeam/AdGuardHome/internal/rrl"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/cache"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/netutil/sysresolv"
 	"github.com/AdguardTeam/golibs/stringutil"
@@ -101,9 +105,43 @@ type SystemResolvers interface {
 //
 // The zero Server is empty and ready for use.
 type Server struct {
+	// logger is used for logging the operation of the DNS server.
+	logger *slog.Logger
+
 	// dnsProxy is the DNS proxy for forwarding client's DNS requests.
 	dnsProxy *proxy.Proxy
 
+	// activatedSrv serves the pre-bound sockets passed by the service manager
+	// using dnsProxy.  It is nil if there are none.
+	activatedSrv *socketact.DNSServer
+
+	// proxyProtoAddrs are the addresses served with the PROXY protocol
+	// support instead of dnsProxy.  It is nil if the PROXY protocol is
+	// disabled.
+	proxyProtoAddrs *proxyProtoAddrs
+
+	// proxyProtoSrv serves the sockets bound for proxyProtoAddrs using
+	// dnsProxy.  It is nil if the server isn't running or the PROXY protocol is
+	// disabled.
+	proxyProtoSrv *socketact.DNSServer
+
+	// proxyProtoSockets are the sockets bound for proxyProtoAddrs.
+	proxyProtoSockets []io.Closer
+
+	// ratelimiter limits the rate of the requests to activatedSrv and
+	// proxyProtoSrv, since the ratelimit of dnsProxy only applies to its own
+	// sockets.  It is nil if the ratelimit is disabled.
+	ratelimiter *ratelimiter
+
+	// recDetector detects the requests to the private upstreams received back
+	// over activatedSrv and proxyProtoSrv, since the recursion detection of
+	// dnsProxy only applies to its own sockets.
+	recDetector *recursionDetector
+
+	// rrl limits the rate of the responses sent over UDP.  It is nil if the
+	// response rate limiting is disabled.
+	rrl *rrl.Limiter
+
 	// dnsFilter is the DNS filter for filtering client's DNS requests and
 	// responses.
 	dnsFilter *filtering.DNSFilter
@@ -152,14 +190,24 @@ type Server struct {
 	// [upstream.Resolver] interface.
 	bootResolvers []*upstream.UpstreamResolver
 
-	// dns64Pref is the NAT64 prefix used for DNS64 response mapping.  The major
-	// part of DNS64 happens inside the [proxy] package, but there still are
-	// some places where response mapping is needed (e.g. DHCP).
-	dns64Pref netip.Prefix
+	// dns64Pref is the NAT64 prefix used for DNS64 response mapping.  When
+	// DNS64 is enabled for all requests with static prefixes, the major part
+	// of DNS64 happens inside the [proxy] package, but there still are some
+	// places where response mapping is needed (e.g. DHCP).  It's replaced with
+	// the discovered prefix, if the discovery is enabled.
+	dns64Pref atomic.Pointer[netip.Prefix]
+
+	// nat64Done is closed to stop the discovery of the NAT64 prefix.  It's nil
+	// if the discovery isn't running.  It's protected by serverLock.
+	nat64Done chan struct{}
 
 	// anonymizer masks the client's IP addresses if needed.
 	anonymizer *aghnet.IPMut
 
+	// tracer records the spans of the DNS request processing.  It may be nil,
+	// in which case nothing is recorded.
+	tracer *aghtrace.Tracer
+
 	// clientIDCache is a temporary storage for ClientIDs that were extracted
 	// during the BeforeRequestHandler stage.
 	clientIDCache cache.Cache
@@ -197,7 +245,12 @@ type DNSCreateParams struct {
 	PrivateNets netutil.SubnetSet
 	Anonymizer  *aghnet.IPMut
 	EtcHosts    *aghnet.HostsContainer
+	Tracer      *aghtrace.Tracer
 	LocalDomain string
+
+	// Logger is used for logging the operation of the DNS server.  If nil,
+	// [slog.Default] is used.
+	Logger *slog.Logger
 }
 
 // NewServer creates a new instance of the dnsforward.Server
@@ -227,7 +280,13 @@ func NewServer(p DNSCreateParams) (s *Server, err error) {
 		etcHosts = upstream.NewHostsResolver(p.EtcHosts)
 	}
 
+	logger := p.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	s = &Server{
+		logger:      logger,
 		dnsFilter:   p.DNSFilter,
 		dhcpServer:  p.DHCPServer,
 		stats:       p.Stats,
@@ -240,7 +299,9 @@ func NewServer(p DNSCreateParams) (s *Server, err error) {
 			EnableLRU: true,
 			MaxCount:  defaultClientIDCacheCount,
 		}),
-		anonymizer: p.Anonymizer,
+		anonymizer:  p.Anonymizer,
+		tracer:      p.Tracer,
+		recDetector: newRecursionDetector(),
 		conf: ServerConfig{
 			ServePlainDNS: true,
 		},
@@ -275,7 +336,7 @@ func (s *Server) Close() {
 	s.dnsProxy = nil
 
 	if err := s.ipset.close(); err != nil {
-		log.Error("dnsforward: closing ipset: %s", err)
+		s.logger.Error("closing ipset", slogutil.KeyError, err)
 	}
 }
 
@@ -293,6 +354,7 @@ func (s *Server) WriteDiskConfig(c *Config) {
 	c.DisallowedClients = slices.Clone(sc.DisallowedClients)
 	c.BlockedHosts = slices.Clone(sc.BlockedHosts)
 	c.TrustedProxies = slices.Clone(sc.TrustedProxies)
+	c.ProxyProtocol.TrustedSources = slices.Clone(sc.ProxyProtocol.TrustedSources)
 	c.UpstreamDNS = slices.Clone(sc.UpstreamDNS)
 }
 
@@ -379,6 +441,7 @@ func (s *Server) Exchange(ip netip.Addr) (host string, ttl time.Duration, err er
 
 		errMsg = "resolving a private address: %w"
 		dctx.RequestedPrivateRDNS = netip.PrefixFrom(ip, ip.BitLen())
+		s.recDetector.add(req)
 	} else {
 		errMsg = "resolving an address: %w"
 	}
@@ -386,11 +449,14 @@ func (s *Server) Exchange(ip netip.Addr) (host string, ttl time.Duration, err er
 		return "", 0, fmt.Errorf(errMsg, err)
 	}
 
-	return hostFromPTR(dctx.Res)
+	return hostFromPTR(s.logger, dctx.Res)
 }
 
 // hostFromPTR returns domain name from the PTR response or error.
-func hostFromPTR(resp *dns.Msg) (host string, ttl time.Duration, err error) {
+func hostFromPTR(
+	logger *slog.Logger,
+	resp *dns.Msg,
+) (host string, ttl time.Duration, err error) {
 	// Distinguish between NODATA response and a failed request.
 	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
 		return "", 0, fmt.Errorf(
@@ -402,7 +468,7 @@ func hostFromPTR(resp *dns.Msg) (host string, ttl time.Duration, err error) {
 
 	var ttlSec uint32
 
-	log.Debug("dnsforward: resolving ptr, received %d answers", len(resp.Answer))
+	logger.Debug("resolving ptr", "answers", len(resp.Answer))
 	for _, ans := range resp.Answer {
 		ptr, ok := ans.(*dns.PTR)
 		if !ok {
@@ -439,16 +505,81 @@ func (s *Server) Start() error {
 	return s.startLocked()
 }
 
-// startLocked starts the DNS server without locking.  s.serverLock is expected
-// to be locked.
-func (s *Server) startLocked() error {
-	// TODO(e.burkov):  Use context properly.
-	err := s.dnsProxy.Start(context.Background())
-	if err == nil {
-		s.isRunning = true
+// startLocked starts the DNS server without locking.  If any of the servers
+// fails to start, the ones already started are stopped.  s.serverLock is
+// expected to be locked.
+func (s *Server) startLocked() (err error) {
+	// The proxy refuses to start without listen addresses, which is the case
+	// when all of them are replaced by the activated sockets.
+	proxyStarted := hasListenAddrs(&s.dnsProxy.Config)
+	if proxyStarted {
+		// TODO(e.burkov):  Use context properly.
+		err = s.dnsProxy.Start(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	defer func() {
+		if err != nil {
+			s.stopPartial(proxyStarted)
+		}
+	}()
+
+	if s.activatedSrv != nil {
+		err = s.activatedSrv.Start()
+		if err != nil {
+			return fmt.Errorf("starting activated sockets: %w", err)
+		}
 	}
 
-	return err
+	err = s.startProxyProto()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	if s.conf.DNS64Discovery {
+		s.nat64Done = make(chan struct{})
+		go s.discoverNAT64(s.internalProxy, s.nat64Done)
+	}
+
+	s.isRunning = true
+
+	return nil
+}
+
+// stopPartial stops the servers started by [Server.startLocked] before it
+// failed.  proxyStarted is true if s.dnsProxy has been started.  s.serverLock
+// is expected to be locked.
+func (s *Server) stopPartial(proxyStarted bool) {
+	s.stopProxyProto()
+
+	if s.activatedSrv != nil {
+		err := s.activatedSrv.Shutdown(context.Background())
+		if err != nil {
+			s.logger.Error("stopping activated sockets", slogutil.KeyError, err)
+		}
+	}
+
+	if proxyStarted {
+		// TODO(e.burkov):  Use context properly.
+		err := s.dnsProxy.Shutdown(context.Background())
+		if err != nil {
+			s.logger.Error("stopping proxy", slogutil.KeyError, err)
+		}
+	}
+}
+
+// hasListenAddrs returns true if c contains any addresses to listen on.
+func hasListenAddrs(c *proxy.Config) (ok bool) {
+	return len(c.UDPListenAddr) > 0 ||
+		len(c.TCPListenAddr) > 0 ||
+		len(c.TLSListenAddr) > 0 ||
+		len(c.HTTPSListenAddr) > 0 ||
+		len(c.QUICListenAddr) > 0 ||
+		len(c.DNSCryptUDPListenAddr) > 0 ||
+		len(c.DNSCryptTCPListenAddr) > 0
 }
 
 // Prepare initializes parameters of s using data from conf.  conf must not be
@@ -478,7 +609,16 @@ func (s *Server) Prepare(conf *ServerConfig) (err error) {
 		return fmt.Errorf("preparing proxy: %w", err)
 	}
 
-	s.setupDNS64()
+	err = s.setupDNS64()
+	if err != nil {
+		return fmt.Errorf("preparing dns64: %w", err)
+	}
+
+	err = s.prepareRRL()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
 
 	s.access, err = newAccessCtx(
 		s.conf.AllowedClients,
@@ -500,6 +640,8 @@ func (s *Server) Prepare(conf *ServerConfig) (err error) {
 	}
 
 	s.dnsProxy = dnsProxy
+	s.ratelimiter = newRatelimiter(s.logger, &s.conf)
+	s.activatedSrv = s.newActivatedServer(dnsProxy, proxyConfig)
 
 	s.setupAddrProc()
 
@@ -512,12 +654,12 @@ func (s *Server) Prepare(conf *ServerConfig) (err error) {
 func (s *Server) prepareUpstreamSettings(boot upstream.Resolver) (err error) {
 	// Load upstreams either from the file, or from the settings
 	var upstreams []string
-	upstreams, err = s.conf.loadUpstreams()
+	upstreams, err = s.conf.loadUpstreams(s.logger)
 	if err != nil {
 		return fmt.Errorf("loading upstreams: %w", err)
 	}
 
-	uc, err := newUpstreamConfig(upstreams, defaultDNS, &upstream.Options{
+	uc, err := newUpstreamConfig(s.logger, upstreams, defaultDNS, &upstream.Options{
 		Bootstrap:    boot,
 		Timeout:      s.conf.UpstreamTimeout,
 		HTTPVersions: UpstreamHTTPVersions(s.conf.UseHTTP3Upstreams),
@@ -531,7 +673,7 @@ func (s *Server) prepareUpstreamSettings(boot upstream.Resolver) (err error) {
 		// TODO(a.garipov): Investigate if that's true.
 		RootCAs:      s.conf.TLSv12Roots,
 		CipherSuites: s.conf.TLSCiphers,
-	})
+	}, s.conf.Recursor, s.conf.UpstreamProxies)
 	if err != nil {
 		return fmt.Errorf("preparing upstream config: %w", err)
 	}
@@ -570,7 +712,7 @@ func (s *Server) prepareLocalResolvers() (uc *proxy.UpstreamConfig, err error) {
 	}
 
 	var ownAddrs addrPortSet
-	ownAddrs, err = s.conf.ourAddrsSet()
+	ownAddrs, err = s.conf.ourAddrsSet(s.logger)
 	if err != nil {
 		// Don't wrap the error, because it's informative enough as is.
 		return nil, err
@@ -584,7 +726,7 @@ func (s *Server) prepareLocalResolvers() (uc *proxy.UpstreamConfig, err error) {
 	}
 
 	addrs := s.conf.LocalPTRResolvers
-	uc, err = newPrivateConfig(addrs, ownAddrs, s.sysResolvers, s.privateNets, opts)
+	uc, err = newPrivateConfig(s.logger, addrs, ownAddrs, s.sysResolvers, s.privateNets, opts)
 	if err != nil {
 		return nil, fmt.Errorf("preparing resolvers: %w", err)
 	}
@@ -606,7 +748,13 @@ func (s *Server) prepareInternalDNS() (err error) {
 		HTTPVersions: UpstreamHTTPVersions(s.conf.UseHTTP3Upstreams),
 	}
 
-	s.bootstrap, s.bootResolvers, err = newBootstrap(s.conf.BootstrapDNS, s.etcHosts, bootOpts)
+	s.bootstrap, s.bootResolvers, err = newBootstrap(
+		s.logger,
+		s.conf.BootstrapDNS,
+		s.etcHosts,
+		bootOpts,
+		s.conf.UpstreamProxies,
+	)
 	if err != nil {
 		// Don't wrap the error, because it's informative enough as is.
 		return err
@@ -639,12 +787,12 @@ func (s *Server) setupFallbackDNS() (uc *proxy.UpstreamConfig, err error) {
 		return nil, nil
 	}
 
-	uc, err = proxy.ParseUpstreamsConfig(fallbacks, &upstream.Options{
+	uc, err = ParseUpstreamsConfig(fallbacks, &upstream.Options{
 		// TODO(s.chzhen):  Investigate if other options are needed.
 		Timeout:    s.conf.UpstreamTimeout,
 		PreferIPv6: s.conf.BootstrapPreferIPv6,
 		// TODO(e.burkov):  Use bootstrap.
-	})
+	}, s.conf.Recursor, s.conf.UpstreamProxies)
 	if err != nil {
 		// Do not wrap the error because it's informative enough as is.
 		return nil, err
@@ -712,7 +860,7 @@ func (s *Server) prepareInternalProxy() (err error) {
 		PrivateRDNSUpstreamConfig: srvConf.PrivateRDNSUpstreamConfig,
 		UpstreamConfig:            srvConf.UpstreamConfig,
 		MaxGoroutines:             srvConf.MaxGoroutines,
-		UseDNS64:                  srvConf.UseDNS64,
+		UseDNS64:                  s.proxyDNS64(),
 		DNS64Prefs:                srvConf.DNS64Prefixes,
 		UsePrivateRDNS:            srvConf.UsePrivateRDNS,
 		PrivateSubnets:            s.privateNets,
@@ -744,16 +892,30 @@ func (s *Server) stopLocked() (err error) {
 	// This will require filtering all the non-critical errors in
 	// [upstream.Upstream] implementations.
 
+	if s.activatedSrv != nil {
+		err = s.activatedSrv.Shutdown(context.Background())
+		if err != nil {
+			s.logger.Error("stopping activated sockets", slogutil.KeyError, err)
+		}
+	}
+
+	s.stopProxyProto()
+
+	if s.nat64Done != nil {
+		close(s.nat64Done)
+		s.nat64Done = nil
+	}
+
 	if s.dnsProxy != nil {
 		// TODO(e.burkov):  Use context properly.
 		err = s.dnsProxy.Shutdown(context.Background())
 		if err != nil {
-			log.Error("dnsforward: closing primary resolvers: %s", err)
+			s.logger.Error("closing primary resolvers", slogutil.KeyError, err)
 		}
 	}
 
 	for _, b := range s.bootResolvers {
-		logCloserErr(b, "dnsforward: closing bootstrap %s: %s", b.Address())
+		logCloserErr(s.logger, b, "closing bootstrap", "addr", b.Address())
 	}
 
 	s.isRunning = false
@@ -761,15 +923,16 @@ func (s *Server) stopLocked() (err error) {
 	return nil
 }
 
-// logCloserErr logs the error returned by c, if any.
-func logCloserErr(c io.Closer, format string, args ...any) {
+// logCloserErr logs the error returned by c, if any, with msg and args using
+// logger.
+func logCloserErr(logger *slog.Logger, c io.Closer, msg string, args ...any) {
 	if c == nil {
 		return
 	}
 
 	err := c.Close()
 	if err != nil {
-		log.Error(format, append(args, err)...)
+		logger.Error(msg, append(args, slogutil.KeyError, err)...)
 	}
 }
 
@@ -801,8 +964,8 @@ func (s *Server) Reconfigure(conf *ServerConfig) error {
 	s.serverLock.Lock()
 	defer s.serverLock.Unlock()
 
-	log.Info("dnsforward: starting reconfiguring server")
-	defer log.Info("dnsforward: finished reconfiguring server")
+	s.logger.Info("starting reconfiguring server")
+	defer s.logger.Info("finished reconfiguring server")
 
 	err := s.stopLocked()
 	if err != nil {
@@ -819,7 +982,7 @@ func (s *Server) Reconfigure(conf *ServerConfig) error {
 	} else {
 		closeErr := s.addrProc.Close()
 		if closeErr != nil {
-			log.Error("dnsforward: closing address processor: %s", closeErr)
+			s.logger.Error("closing address processor", slogutil.KeyError, closeErr)
 		}
 	}
 
@@ -862,13 +1025,17 @@ func (s *Server) IsBlockedClient(ip netip.Addr, clientID string) (blocked bool,
 	// Allow if at least one of the checks allows in allowlist mode, but block
 	// if at least one of the checks blocks in blocklist mode.
 	if allowlistMode && blockedByIP && blockedByClientID {
-		log.Debug("dnsforward: client %v (id %q) is not in access allowlist", ip, clientID)
+		s.logger.Debug(
+			"client is not in access allowlist",
+			"client", ip,
+			"client_id", clientID,
+		)
 
 		// Return now without substituting the empty rule for the
 		// clientID because the rule can't be empty here.
 		return true, rule
 	} else if !allowlistMode && (blockedByIP || blockedByClientID) {
-		log.Debug("dnsforward: client %v (id %q) is in access blocklist", ip, clientID)
+		s.logger.Debug("client is in access blocklist", "client", ip, "client_id", clientID)
 
 		blocked = true
 	}
@@ -29,6 +29,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/safesearch"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/AdguardTeam/golibs/timeutil"
@@ -41,6 +42,9 @@ func TestMain(m *testing.M) {
 	testutil.DiscardLogOutput(m)
 }
 
+// testLogger is the common logger for tests.
+var testLogger = slogutil.NewDiscardLogger()
+
 // testTimeout is the common timeout for tests.
 //
 // TODO(a.garipov): Use more.
@@ -514,6 +518,7 @@ func TestSafeSearch(t *testing.T) {
 		CacheTime:           30,
 	}
 	safeSearch, err := safesearch.NewDefault(
+		slogutil.NewDiscardLogger(),
 		safeSearchConf,
 		"",
 		filterConf.SafeSearchCacheSize,
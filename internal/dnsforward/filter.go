@@ -6,8 +6,8 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
-	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/urlfilter/rules"
 	"github.com/miekg/dns"
 )
@@ -31,7 +31,13 @@ func (s *Server) filterDNSRequest(dctx *dnsContext) (res *filtering.Result, err
 	req := pctx.Req
 	q := req.Question[0]
 	host := strings.TrimSuffix(q.Name, ".")
+
+	_, span := s.tracer.Start(dctx.ctx, "filtering.check_host", aghtrace.String(attrDNSQName, host))
 	resVal, err := s.dnsFilter.CheckHost(host, q.Qtype, dctx.setts)
+	span.RecordError(err)
+	span.SetAttrs(resultAttrs(&resVal)...)
+	span.End()
+
 	if err != nil {
 		return nil, fmt.Errorf("checking host %q: %w", host, err)
 	}
@@ -40,13 +46,13 @@ func (s *Server) filterDNSRequest(dctx *dnsContext) (res *filtering.Result, err
 	res = &resVal
 	switch {
 	case res.IsFiltered:
-		log.Debug(
-			"dnsforward: host %q is filtered, reason: %q; rule: %q",
-			host,
-			res.Reason,
-			res.Rules[0].Text,
+		dctx.logger.Debug(
+			"host is filtered",
+			"host", host,
+			"reason", res.Reason,
+			"rule", res.Rules[0].Text,
 		)
-		pctx.Res = s.genDNSFilterMessage(pctx, res)
+		pctx.Res = s.genDNSFilterMessage(dctx, res)
 	case res.Reason.In(filtering.Rewritten, filtering.RewrittenRule) &&
 		res.CanonName != "" &&
 		len(res.IPList) == 0:
@@ -95,6 +101,14 @@ func (s *Server) filterDNSResponse(dctx *dnsContext) (err error) {
 
 	var res *filtering.Result
 	pctx := dctx.proxyCtx
+
+	_, span := s.tracer.Start(dctx.ctx, "filtering.check_response")
+	defer func() {
+		span.RecordError(err)
+		span.SetAttrs(resultAttrs(res)...)
+		span.End()
+	}()
+
 	for i, a := range pctx.Res.Answer {
 		host := ""
 		var rrtype rules.RRType
@@ -120,16 +134,25 @@ func (s *Server) filterDNSResponse(dctx *dnsContext) (err error) {
 			continue
 		}
 
-		log.Debug("dnsforward: checked %s %s for %s", dns.Type(rrtype), host, a.Header().Name)
+		dctx.logger.Debug(
+			"checked answer",
+			"qtype", dns.Type(rrtype),
+			"host", host,
+			"name", a.Header().Name,
+		)
 
 		if err != nil {
 			return fmt.Errorf("filtering answer at index %d: %w", i, err)
 		} else if res != nil && res.IsFiltered {
 			dctx.result = res
 			dctx.origResp = pctx.Res
-			pctx.Res = s.genDNSFilterMessage(pctx, res)
+			pctx.Res = s.genDNSFilterMessage(dctx, res)
 
-			log.Debug("dnsforward: matched %q by response: %q", pctx.Req.Question[0].Name, host)
+			dctx.logger.Debug(
+				"matched by response",
+				"name", pctx.Req.Question[0].Name,
+				"host", host,
+			)
 
 			break
 		}
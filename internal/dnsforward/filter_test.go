@@ -331,6 +331,7 @@ func TestHandleDNSRequest_filterDNSResponse(t *testing.T) {
 			}
 
 			dctx := &dnsContext{
+				logger:   testLogger,
 				proxyCtx: pctx,
 				setts: &filtering.Settings{
 					ProtectionEnabled: true,
@@ -0,0 +1,101 @@
+package dnsforward
+
+import (
+	"cmp"
+	"net/netip"
+	"slices"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
+	"github.com/miekg/dns"
+)
+
+// processGeoIP looks up the countries and the autonomous systems of the
+// addresses in the response from upstream and blocks it, if any of them is
+// blocked by the GeoIP policy of the client.
+func (s *Server) processGeoIP(dctx *dnsContext) (rc resultCode) {
+	r := s.conf.GeoIP
+	pctx := dctx.proxyCtx
+	if r == nil || !dctx.responseFromUpstream || pctx.Res == nil || dctx.result.IsFiltered {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing geoip")
+	defer dctx.logger.Debug("finished processing geoip")
+
+	dctx.answerGeo = lookupAnswerGeo(r, pctx.Res.Answer)
+
+	if !dctx.protectionEnabled ||
+		!dctx.setts.FilteringEnabled ||
+		dctx.result.Reason == filtering.NotFilteredAllowList {
+		return resultCodeSuccess
+	}
+
+	policy := cmp.Or(dctx.setts.GeoIPPolicy, s.conf.GeoIPPolicy)
+	for _, info := range dctx.answerGeo {
+		rule, blocked := policy.Check(info)
+		if !blocked {
+			continue
+		}
+
+		dctx.logger.Debug("geoip: answer matches", "ip", info.IP, "rule", rule)
+
+		dctx.result = &filtering.Result{
+			Rules: []*filtering.ResultRule{{
+				Text:         rule,
+				FilterListID: rulelist.URLFilterIDGeoIP,
+			}},
+			Reason:     filtering.FilteredGeoIP,
+			IsFiltered: true,
+		}
+		dctx.origResp = pctx.Res
+		pctx.Res = s.genDNSFilterMessage(dctx, dctx.result)
+
+		break
+	}
+
+	return resultCodeSuccess
+}
+
+// lookupAnswerGeo returns the information about the addresses of the A and
+// AAAA records in ans.
+func lookupAnswerGeo(r *geoip.Resolver, ans []dns.RR) (infos []*geoip.IPInfo) {
+	for _, rr := range ans {
+		var ip netip.Addr
+		switch rr := rr.(type) {
+		case *dns.A:
+			ip, _ = netip.AddrFromSlice(rr.A.To4())
+		case *dns.AAAA:
+			ip, _ = netip.AddrFromSlice(rr.AAAA)
+		default:
+			continue
+		}
+
+		if !ip.IsValid() {
+			continue
+		}
+
+		if info := r.Lookup(ip); info != nil {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// answerGeoKeys returns the unique countries and autonomous systems of the
+// addresses in infos for the statistics.
+func answerGeoKeys(infos []*geoip.IPInfo) (countries, asns []string) {
+	for _, info := range infos {
+		if c := info.Country; c != "" && !slices.Contains(countries, c) {
+			countries = append(countries, c)
+		}
+
+		if asn := info.ASNString(); asn != "" && !slices.Contains(asns, asn) {
+			asns = append(asns, asn)
+		}
+	}
+
+	return countries, asns
+}
@@ -0,0 +1,56 @@
+package dnsforward
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+)
+
+// CheckUpstreams exchanges with each of the general upstream servers
+// concurrently and returns the number of the ones that have responded properly
+// along with their total number.  It blocks until all the exchanges are
+// finished or timed out, so the callers should cache the result.
+func (s *Server) CheckUpstreams() (healthy, total int) {
+	var ups []upstream.Upstream
+	func() {
+		s.serverLock.RLock()
+		defer s.serverLock.RUnlock()
+
+		if s.conf.UpstreamConfig != nil {
+			ups = slices.Clone(s.conf.UpstreamConfig.Upstreams)
+		}
+	}()
+
+	hc := newCommonHealthchecker()
+	healthyNum := &atomic.Int64{}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(len(ups))
+	for _, u := range ups {
+		go func(u upstream.Upstream) {
+			defer slogutil.RecoverAndLog(context.TODO(), s.logger.With("upstream", u.Address()))
+			defer wg.Done()
+
+			err := hc.check(u)
+			if err != nil {
+				s.logger.Debug(
+					"upstream is unhealthy",
+					"upstream", u.Address(),
+					slogutil.KeyError, err,
+				)
+
+				return
+			}
+
+			healthyNum.Add(1)
+		}(u)
+	}
+
+	wg.Wait()
+
+	return int(healthyNum.Load()), len(ups)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/netip"
 	"slices"
@@ -12,10 +13,11 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/stringutil"
 )
@@ -176,7 +178,7 @@ func (s *Server) getDNSConfig() (c *jsonDNSConfig) {
 
 	defPTRUps, err := s.defaultLocalPTRUpstreams()
 	if err != nil {
-		log.Error("dnsforward: %s", err)
+		s.logger.Error("getting default local ptr upstreams", slogutil.KeyError, err)
 	}
 
 	return &jsonDNSConfig{
@@ -214,7 +216,7 @@ func (s *Server) getDNSConfig() (c *jsonDNSConfig) {
 // defaultLocalPTRUpstreams returns the list of default local PTR resolvers
 // filtered of AdGuard Home's own DNS server addresses.  It may appear empty.
 func (s *Server) defaultLocalPTRUpstreams() (ups []string, err error) {
-	matcher, err := s.conf.ourAddrsSet()
+	matcher, err := s.conf.ourAddrsSet(s.logger)
 	if err != nil {
 		// Don't wrap the error because it's informative enough as is.
 		return nil, err
@@ -266,13 +268,15 @@ func (req *jsonDNSConfig) checkUpstreamMode() (err error) {
 //
 // TODO(s.chzhen):  Parse, don't validate.
 func (req *jsonDNSConfig) validate(
+	logger *slog.Logger,
 	ownAddrs addrPortSet,
 	sysResolvers SystemResolvers,
 	privateNets netutil.SubnetSet,
+	prx *upsproxy.Proxies,
 ) (err error) {
 	defer func() { err = errors.Annotate(err, "validating dns config: %w") }()
 
-	err = req.validateUpstreamDNSServers(ownAddrs, sysResolvers, privateNets)
+	err = req.validateUpstreamDNSServers(logger, ownAddrs, sysResolvers, privateNets, prx)
 	if err != nil {
 		// Don't wrap the error since it's informative enough as is.
 		return err
@@ -336,6 +340,7 @@ func (req *jsonDNSConfig) checkBootstrap() (err error) {
 // checkPrivateRDNS returns an error if the configuration of the private RDNS is
 // not valid.
 func (req *jsonDNSConfig) checkPrivateRDNS(
+	logger *slog.Logger,
 	ownAddrs addrPortSet,
 	sysResolvers SystemResolvers,
 	privateNets netutil.SubnetSet,
@@ -346,7 +351,14 @@ func (req *jsonDNSConfig) checkPrivateRDNS(
 
 	addrs := cmp.Or(req.LocalPTRUpstreams, &[]string{})
 
-	uc, err := newPrivateConfig(*addrs, ownAddrs, sysResolvers, privateNets, &upstream.Options{})
+	uc, err := newPrivateConfig(
+		logger,
+		*addrs,
+		ownAddrs,
+		sysResolvers,
+		privateNets,
+		&upstream.Options{},
+	)
 	err = errors.WithDeferred(err, uc.Close())
 	if err != nil {
 		return fmt.Errorf("private upstream servers: %w", err)
@@ -356,23 +368,27 @@ func (req *jsonDNSConfig) checkPrivateRDNS(
 }
 
 // validateUpstreamDNSServers returns an error if any field of req is invalid.
+// prx chooses the proxies for the upstreams, so that the upstreams, which
+// don't support proxies, are rejected before being applied.
 func (req *jsonDNSConfig) validateUpstreamDNSServers(
+	logger *slog.Logger,
 	ownAddrs addrPortSet,
 	sysResolvers SystemResolvers,
 	privateNets netutil.SubnetSet,
+	prx *upsproxy.Proxies,
 ) (err error) {
 	var uc *proxy.UpstreamConfig
 	opts := &upstream.Options{}
 
 	if req.Upstreams != nil {
-		uc, err = proxy.ParseUpstreamsConfig(*req.Upstreams, opts)
+		uc, err = ParseUpstreamsConfig(*req.Upstreams, opts, nil, prx)
 		err = errors.WithDeferred(err, uc.Close())
 		if err != nil {
 			return fmt.Errorf("upstream servers: %w", err)
 		}
 	}
 
-	err = req.checkPrivateRDNS(ownAddrs, sysResolvers, privateNets)
+	err = req.checkPrivateRDNS(logger, ownAddrs, sysResolvers, privateNets)
 	if err != nil {
 		// Don't wrap the error since it's informative enough as is.
 		return err
@@ -385,7 +401,7 @@ func (req *jsonDNSConfig) validateUpstreamDNSServers(
 	}
 
 	if req.Fallbacks != nil {
-		uc, err = proxy.ParseUpstreamsConfig(*req.Fallbacks, opts)
+		uc, err = ParseUpstreamsConfig(*req.Fallbacks, opts, nil, prx)
 		err = errors.WithDeferred(err, uc.Close())
 		if err != nil {
 			return fmt.Errorf("fallback servers: %w", err)
@@ -459,7 +475,7 @@ func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// TODO(e.burkov):  Consider prebuilding this set on startup.
-	ourAddrs, err := s.conf.ourAddrsSet()
+	ourAddrs, err := s.conf.ourAddrsSet(s.logger)
 	if err != nil {
 		// TODO(e.burkov):  Put into openapi.
 		aghhttp.Error(r, w, http.StatusInternalServerError, "getting our addresses: %s", err)
@@ -467,7 +483,7 @@ func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = req.validate(ourAddrs, s.sysResolvers, s.privateNets)
+	err = req.validate(s.logger, ourAddrs, s.sysResolvers, s.privateNets, s.conf.UpstreamProxies)
 	if err != nil {
 		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
 
@@ -592,10 +608,11 @@ type upstreamJSON struct {
 	PrivateUpstreams []string `json:"private_upstream"`
 }
 
-// closeBoots closes all the provided bootstrap servers and logs errors if any.
-func closeBoots(boots []*upstream.UpstreamResolver) {
+// closeBoots closes all the provided bootstrap servers and logs errors if any
+// using logger.
+func closeBoots(logger *slog.Logger, boots []*upstream.UpstreamResolver) {
 	for _, c := range boots {
-		logCloserErr(c, "dnsforward: closing bootstrap %s: %s", c.Address())
+		logCloserErr(logger, c, "closing bootstrap", "addr", c.Address())
 	}
 }
 
@@ -618,15 +635,29 @@ func (s *Server) handleTestUpstreamDNS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var boots []*upstream.UpstreamResolver
-	opts.Bootstrap, boots, err = newBootstrap(req.BootstrapDNS, s.etcHosts, opts)
+	opts.Bootstrap, boots, err = newBootstrap(
+		s.logger,
+		req.BootstrapDNS,
+		s.etcHosts,
+		opts,
+		s.conf.UpstreamProxies,
+	)
 	if err != nil {
 		aghhttp.Error(r, w, http.StatusBadRequest, "Failed to parse bootstrap servers: %s", err)
 
 		return
 	}
-	defer closeBoots(boots)
-
-	cv := newUpstreamConfigValidator(req.Upstreams, req.FallbackDNS, req.PrivateUpstreams, opts)
+	defer closeBoots(s.logger, boots)
+
+	cv := newUpstreamConfigValidator(
+		s.logger,
+		req.Upstreams,
+		req.FallbackDNS,
+		req.PrivateUpstreams,
+		opts,
+		s.conf.Recursor,
+		s.conf.UpstreamProxies,
+	)
 	cv.check()
 	cv.close()
 
@@ -53,6 +53,7 @@ func TestIpsetCtx_process(t *testing.T) {
 
 	t.Run("nil", func(t *testing.T) {
 		dctx := &dnsContext{
+			logger:   testLogger,
 			proxyCtx: &proxy.DNSContext{},
 
 			responseFromUpstream: true,
@@ -68,6 +69,7 @@ func TestIpsetCtx_process(t *testing.T) {
 
 	t.Run("ipv4", func(t *testing.T) {
 		dctx := &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req: req4,
 				Res: resp4,
@@ -92,6 +94,7 @@ func TestIpsetCtx_process(t *testing.T) {
 
 	t.Run("ipv6", func(t *testing.T) {
 		dctx := &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req: req6,
 				Res: resp6,
@@ -136,6 +139,7 @@ func TestIpsetCtx_SkipIpsetProcessing(t *testing.T) {
 		name: "basic",
 		want: false,
 		dctx: &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req: req4,
 				Res: resp4,
@@ -147,6 +151,7 @@ func TestIpsetCtx_SkipIpsetProcessing(t *testing.T) {
 		name: "rewrite",
 		want: true,
 		dctx: &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req: req4,
 				Res: resp4,
@@ -158,6 +163,7 @@ func TestIpsetCtx_SkipIpsetProcessing(t *testing.T) {
 		name: "empty_req",
 		want: true,
 		dctx: &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req: nil,
 				Res: resp4,
@@ -169,6 +175,7 @@ func TestIpsetCtx_SkipIpsetProcessing(t *testing.T) {
 		name: "empty_res",
 		want: true,
 		dctx: &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req: req4,
 				Res: nil,
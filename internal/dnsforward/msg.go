@@ -6,7 +6,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/dnsproxy/proxy"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/urlfilter/rules"
 	"github.com/miekg/dns"
 )
@@ -44,17 +44,16 @@ func ipsFromRules(resRules []*filtering.ResultRule) (ips []netip.Addr) {
 	return ips
 }
 
-// genDNSFilterMessage generates a filtered response to req for the filtering
-// result res.
-func (s *Server) genDNSFilterMessage(
-	dctx *proxy.DNSContext,
-	res *filtering.Result,
-) (resp *dns.Msg) {
-	req := dctx.Req
+// genDNSFilterMessage generates a filtered response to the request of dctx for
+// the filtering result res.
+func (s *Server) genDNSFilterMessage(dctx *dnsContext, res *filtering.Result) (resp *dns.Msg) {
+	pctx := dctx.proxyCtx
+	req := pctx.Req
+	mode, bIPv4, bIPv6 := s.blockingMode(dctx.setts, res)
+
 	qt := req.Question[0].Qtype
 	if qt != dns.TypeA && qt != dns.TypeAAAA {
-		m, _, _ := s.dnsFilter.BlockingMode()
-		if m == filtering.BlockingModeNullIP {
+		if mode == filtering.BlockingModeNullIP {
 			return s.replyCompressed(req)
 		}
 
@@ -63,16 +62,58 @@ func (s *Server) genDNSFilterMessage(
 
 	switch res.Reason {
 	case filtering.FilteredSafeBrowsing:
-		return s.genBlockedHost(req, s.dnsFilter.SafeBrowsingBlockHost(), dctx)
+		return s.genBlockedHost(req, s.dnsFilter.SafeBrowsingBlockHost(), pctx)
 	case filtering.FilteredParental:
-		return s.genBlockedHost(req, s.dnsFilter.ParentalBlockHost(), dctx)
+		return s.genBlockedHost(req, s.dnsFilter.ParentalBlockHost(), pctx)
 	case filtering.FilteredSafeSearch:
 		// If Safe Search generated the necessary IP addresses, use them.
 		// Otherwise, if there were no errors, there are no addresses for the
 		// requested IP version, so produce a NODATA response.
 		return s.getCNAMEWithIPs(req, ipsFromRules(res.Rules), res.CanonName)
 	default:
-		return s.genForBlockingMode(req, ipsFromRules(res.Rules))
+		return s.genForBlockingMode(req, ipsFromRules(res.Rules), mode, bIPv4, bIPv6)
+	}
+}
+
+// blockingMode returns the blocking mode and the blocking IP addresses for the
+// request of the client with setts, which may be nil, filtered with res.  The
+// mode of the client or of its tags takes precedence over the one of the filter
+// list, which in turn takes precedence over the global one.
+func (s *Server) blockingMode(
+	setts *filtering.Settings,
+	res *filtering.Result,
+) (mode filtering.BlockingMode, bIPv4, bIPv6 netip.Addr) {
+	mode, bIPv4, bIPv6 = s.dnsFilter.BlockingMode()
+
+	var override filtering.BlockingMode
+	if setts != nil {
+		override = setts.BlockingMode
+	}
+
+	for _, r := range res.Rules {
+		if override != "" {
+			break
+		}
+
+		override = s.dnsFilter.FilterBlockingMode(r.FilterListID)
+	}
+
+	switch override {
+	case "", mode:
+		return mode, bIPv4, bIPv6
+	case filtering.BlockingModeCustomIP:
+		if !bIPv4.Is4() || !bIPv6.Is6() {
+			// The overrides are validated against the global blocking addresses
+			// when they're configured, and those can't be unset afterwards, so
+			// this should never happen.
+			s.logger.Warn("no blocking ips for custom_ip override, using null ip")
+
+			return filtering.BlockingModeNullIP, bIPv4, bIPv6
+		}
+
+		return override, bIPv4, bIPv6
+	default:
+		return override, bIPv4, bIPv6
 	}
 }
 
@@ -110,10 +151,16 @@ func (s *Server) getCNAMEWithIPs(req *dns.Msg, ips []netip.Addr, cname string) (
 	return resp
 }
 
-// genForBlockingMode generates a filtered response to req based on the server's
-// blocking mode.
-func (s *Server) genForBlockingMode(req *dns.Msg, ips []netip.Addr) (resp *dns.Msg) {
-	switch mode, bIPv4, bIPv6 := s.dnsFilter.BlockingMode(); mode {
+// genForBlockingMode generates a filtered response to req based on the blocking
+// mode and the blocking IP addresses.
+func (s *Server) genForBlockingMode(
+	req *dns.Msg,
+	ips []netip.Addr,
+	mode filtering.BlockingMode,
+	bIPv4 netip.Addr,
+	bIPv6 netip.Addr,
+) (resp *dns.Msg) {
+	switch mode {
 	case filtering.BlockingModeCustomIP:
 		return s.makeResponseCustomIP(req, bIPv4, bIPv6)
 	case filtering.BlockingModeDefault:
@@ -129,7 +176,7 @@ func (s *Server) genForBlockingMode(req *dns.Msg, ips []netip.Addr) (resp *dns.M
 	case filtering.BlockingModeREFUSED:
 		return s.makeResponseREFUSED(req)
 	default:
-		log.Error("dnsforward: invalid blocking mode %q", mode)
+		s.logger.Error("invalid blocking mode", "mode", mode)
 
 		return s.replyCompressed(req)
 	}
@@ -150,7 +197,7 @@ func (s *Server) makeResponseCustomIP(
 	default:
 		// Generally shouldn't happen, since the types are checked in
 		// genDNSFilterMessage.
-		log.Error("dnsforward: invalid msg type %s for custom IP blocking mode", dns.Type(qt))
+		s.logger.Error("invalid msg type for custom ip blocking mode", "qtype", dns.Type(qt))
 
 		return s.replyCompressed(req)
 	}
@@ -261,7 +308,7 @@ func (s *Server) genResponseWithIPs(req *dns.Msg, ips []netip.Addr) (resp *dns.M
 func (s *Server) genAnswersWithIPv4s(req *dns.Msg, ips []netip.Addr) (ans []dns.RR) {
 	for _, ip := range ips {
 		if !ip.Is4() {
-			log.Info("dnsforward: warning: ip %s is not ipv4 address", ip)
+			s.logger.Warn("ip is not ipv4 address", "ip", ip)
 
 			return nil
 		}
@@ -293,7 +340,7 @@ func (s *Server) makeResponseNullIP(req *dns.Msg) (resp *dns.Msg) {
 
 func (s *Server) genBlockedHost(request *dns.Msg, newAddr string, d *proxy.DNSContext) *dns.Msg {
 	if newAddr == "" {
-		log.Info("dnsforward: block host is not specified")
+		s.logger.Info("block host is not specified")
 
 		return s.NewMsgSERVFAIL(request)
 	}
@@ -316,14 +363,19 @@ func (s *Server) genBlockedHost(request *dns.Msg, newAddr string, d *proxy.DNSCo
 
 	prx := s.proxy()
 	if prx == nil {
-		log.Debug("dnsforward: %s", srvClosedErr)
+		s.logger.Debug("looking up replacement host", slogutil.KeyError, srvClosedErr)
 
 		return s.NewMsgSERVFAIL(request)
 	}
 
 	err = prx.Resolve(newContext)
 	if err != nil {
-		log.Info("dnsforward: looking up replacement host %q: %s", newAddr, err)
+		s.logger.Info(
+			"looking up replacement host",
+			"client", d.Addr,
+			"host", newAddr,
+			slogutil.KeyError, err,
+		)
 
 		return s.NewMsgSERVFAIL(request)
 	}
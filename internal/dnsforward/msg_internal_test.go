@@ -0,0 +1,140 @@
+package dnsforward
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_GenDNSFilterMessage_blockingMode(t *testing.T) {
+	const (
+		nxdomainListID rulelist.URLFilterID = 1
+		nullIPListID   rulelist.URLFilterID = 2
+	)
+
+	blockingIPv4 := netip.MustParseAddr("192.0.2.1")
+
+	f, err := filtering.New(&filtering.Config{
+		ProtectionEnabled: true,
+		FilteringEnabled:  true,
+		BlockingMode:      filtering.BlockingModeCustomIP,
+		BlockingIPv4:      blockingIPv4,
+		BlockingIPv6:      netip.MustParseAddr("2001:db8::1"),
+		DataDir:           t.TempDir(),
+		Filters: []filtering.FilterYAML{{
+			URL:          "https://nxdomain.example/list.txt",
+			Filter:       filtering.Filter{ID: nxdomainListID},
+			BlockingMode: filtering.BlockingModeNXDOMAIN,
+		}, {
+			// Use different URLs, since the lists with the same URL are
+			// deduplicated.
+			URL:          "https://null.example/list.txt",
+			Filter:       filtering.Filter{ID: nullIPListID},
+			BlockingMode: filtering.BlockingModeNullIP,
+		}},
+	}, []filtering.Filter{{
+		ID:   rulelist.URLFilterIDCustom,
+		Data: []byte("||custom.example^\n"),
+	}, {
+		ID:   nxdomainListID,
+		Data: []byte("||nxdomain.example^\n"),
+	}, {
+		ID:   nullIPListID,
+		Data: []byte("||null.example^\n"),
+	}})
+	require.NoError(t, err)
+
+	s, err := NewServer(DNSCreateParams{
+		DHCPServer: &testDHCP{
+			OnEnabled:  func() (ok bool) { return false },
+			OnHostByIP: func(_ netip.Addr) (host string) { panic("not implemented") },
+			OnIPByHost: func(_ string) (ip netip.Addr) { panic("not implemented") },
+		},
+		DNSFilter:   f,
+		PrivateNets: netutil.SubnetSetFunc(netutil.IsLocallyServed),
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		wantIP     netip.Addr
+		name       string
+		host       string
+		clientMode filtering.BlockingMode
+		wantRcode  int
+	}{{
+		wantIP:     blockingIPv4,
+		name:       "global",
+		host:       "custom.example",
+		clientMode: "",
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		wantIP:     netip.Addr{},
+		name:       "list_nxdomain",
+		host:       "nxdomain.example",
+		clientMode: "",
+		wantRcode:  dns.RcodeNameError,
+	}, {
+		wantIP:     netip.IPv4Unspecified(),
+		name:       "list_null_ip",
+		host:       "null.example",
+		clientMode: "",
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		wantIP:     netip.Addr{},
+		name:       "client_over_list",
+		host:       "nxdomain.example",
+		clientMode: filtering.BlockingModeREFUSED,
+		wantRcode:  dns.RcodeRefused,
+	}, {
+		wantIP:     blockingIPv4,
+		name:       "client_custom_ip",
+		host:       "null.example",
+		clientMode: filtering.BlockingModeCustomIP,
+		wantRcode:  dns.RcodeSuccess,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setts := &filtering.Settings{
+				ProtectionEnabled: true,
+				FilteringEnabled:  true,
+				BlockingMode:      tc.clientMode,
+			}
+
+			res, resErr := f.CheckHost(tc.host, dns.TypeA, setts)
+			require.NoError(t, resErr)
+			require.True(t, res.IsFiltered)
+
+			dctx := &dnsContext{
+				logger: testLogger,
+				proxyCtx: &proxy.DNSContext{
+					Req: (&dns.Msg{}).SetQuestion(dns.Fqdn(tc.host), dns.TypeA),
+				},
+				setts: setts,
+			}
+
+			resp := s.genDNSFilterMessage(dctx, &res)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantRcode, resp.Rcode)
+			if !tc.wantIP.IsValid() {
+				assert.Empty(t, resp.Answer)
+
+				return
+			}
+
+			require.Len(t, resp.Answer, 1)
+
+			a := testutil.RequireTypeAssert[*dns.A](t, resp.Answer[0])
+			assert.Equal(t, tc.wantIP.AsSlice(), []byte(a.A.To4()))
+		})
+	}
+}
@@ -2,15 +2,23 @@ package dnsforward
 
 import (
 	"cmp"
+	"context"
 	"encoding/binary"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"strings"
 	"time"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
+	"github.com/AdguardTeam/AdGuardHome/internal/rrl"
 	"github.com/AdguardTeam/dnsproxy/proxy"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/miekg/dns"
 )
@@ -20,6 +28,14 @@ import (
 // TODO(s.chzhen):  Add lowercased, non-FQDN version of the hostname from the
 // question of the request.  Add persistent client.
 type dnsContext struct {
+	// logger is used for logging the processing of the request.  It contains
+	// the address of the client.
+	logger *slog.Logger
+
+	// ctx is the context of the current stage of the request processing.  It
+	// contains the tracing span of the stage, if the request is traced.
+	ctx context.Context
+
 	proxyCtx *proxy.DNSContext
 
 	// setts are the filtering settings for the client.
@@ -31,9 +47,20 @@ type dnsContext struct {
 	// response is modified by filters.
 	origResp *dns.Msg
 
+	// answerGeo is the information about the addresses in the answer section
+	// of the response from upstream, if any.
+	answerGeo []*geoip.IPInfo
+
 	// err is the error returned from a processing function.
 	err error
 
+	// rrlAction is the action of the response rate limiting on the response.
+	// It's set even if the limiter is in the log-only mode.
+	rrlAction rrl.Action
+
+	// bypassMatch is the kind of the DNS bypass related hostname requested.
+	bypassMatch bypass.Match
+
 	// clientID is the ClientID from DoH, DoQ, or DoT, if provided.
 	clientID string
 
@@ -81,34 +108,97 @@ const (
 // See https://www.ietf.org/archive/id/draft-ietf-add-ddr-06.html.
 const ddrHostFQDN = "_dns.resolver.arpa."
 
+// processStage is a single named stage of the request processing.
+type processStage struct {
+	// process is the function performing the stage.
+	process func(dctx *dnsContext) (rc resultCode)
+
+	// name is the name of the stage used in the tracing spans.
+	name string
+}
+
 // handleDNSRequest filters the incoming DNS requests and writes them to the query log
-func (s *Server) handleDNSRequest(_ *proxy.Proxy, pctx *proxy.DNSContext) error {
+func (s *Server) handleDNSRequest(_ *proxy.Proxy, pctx *proxy.DNSContext) (err error) {
 	dctx := &dnsContext{
+		logger:    s.logger.With("client", pctx.Addr),
 		proxyCtx:  pctx,
 		result:    &filtering.Result{},
 		startTime: time.Now(),
 	}
 
-	type modProcessFunc func(ctx *dnsContext) (rc resultCode)
+	ctx := context.Background()
+	if r := pctx.HTTPRequest; r != nil {
+		// Continue the trace of the DNS-over-HTTPS client, if there is one.
+		ctx = s.tracer.Extract(ctx, r.Header)
+	}
+
+	ctx, span := s.tracer.StartServer(ctx, "dns.request", requestAttrs(pctx)...)
+	dctx.ctx = ctx
+	defer func() {
+		span.SetAttrs(responseAttrs(dctx)...)
+		span.RecordError(err)
+		span.End()
+	}()
 
 	// Since (*dnsforward.Server).handleDNSRequest(...) is used as
 	// proxy.(Config).RequestHandler, there is no need for additional index
 	// out of range checking in any of the following functions, because the
 	// (*proxy.Proxy).handleDNSRequest method performs it before calling the
 	// appropriate handler.
-	mods := []modProcessFunc{
-		s.processInitial,
-		s.processDDRQuery,
-		s.processDHCPHosts,
-		s.processDHCPAddrs,
-		s.processFilteringBeforeRequest,
-		s.processUpstream,
-		s.processFilteringAfterResponse,
-		s.ipset.process,
-		s.processQueryLogsAndStats,
-	}
-	for _, process := range mods {
-		r := process(dctx)
+	stages := []processStage{{
+		process: s.processInitial,
+		name:    "initial",
+	}, {
+		process: s.processDDRQuery,
+		name:    "ddr",
+	}, {
+		process: s.processDHCPHosts,
+		name:    "dhcp_hosts",
+	}, {
+		process: s.processDHCPAddrs,
+		name:    "dhcp_addrs",
+	}, {
+		process: s.processMDNS,
+		name:    "mdns",
+	}, {
+		process: s.processSecondaryNotify,
+		name:    "secondary_notify",
+	}, {
+		process: s.processBypass,
+		name:    "bypass",
+	}, {
+		process: s.processFilteringBeforeRequest,
+		name:    "filtering_request",
+	}, {
+		process: s.processBypassBlock,
+		name:    "bypass_block",
+	}, {
+		process: s.processSecondary,
+		name:    "secondary",
+	}, {
+		process: s.processAnomaly,
+		name:    "anomaly",
+	}, {
+		process: s.processUpstream,
+		name:    "upstream",
+	}, {
+		process: s.processFilteringAfterResponse,
+		name:    "filtering_response",
+	}, {
+		process: s.processGeoIP,
+		name:    "geoip",
+	}, {
+		process: s.ipset.process,
+		name:    "ipset",
+	}, {
+		process: s.processRRL,
+		name:    "rrl",
+	}, {
+		process: s.processQueryLogsAndStats,
+		name:    "querylog_stats",
+	}}
+	for _, stage := range stages {
+		r := s.processTraced(ctx, dctx, stage)
 		switch r {
 		case resultCodeSuccess:
 			// continue: call the next filter
@@ -129,6 +219,28 @@ func (s *Server) handleDNSRequest(_ *proxy.Proxy, pctx *proxy.DNSContext) error
 	return nil
 }
 
+// processTraced performs the stage of the request processing within a tracing
+// span, if the request is traced.
+func (s *Server) processTraced(
+	ctx context.Context,
+	dctx *dnsContext,
+	stage processStage,
+) (rc resultCode) {
+	stageCtx, span := s.tracer.Start(ctx, "dns.stage."+stage.name)
+	defer span.End()
+
+	dctx.ctx = stageCtx
+
+	rc = stage.process(dctx)
+	if rc == resultCodeError {
+		span.RecordError(dctx.err)
+	}
+
+	span.SetAttrs(stageAttrs(dctx, stage.name)...)
+
+	return rc
+}
+
 // mozillaFQDN is the domain used to signal the Firefox browser to not use its
 // own DoH server.
 //
@@ -150,11 +262,11 @@ const healthcheckFQDN = "healthcheck.adguardhome.test."
 //
 // TODO(e.burkov):  Decompose into less general processors.
 func (s *Server) processInitial(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing initial")
-	defer log.Debug("dnsforward: finished processing initial")
+	dctx.logger.Debug("started processing initial")
+	defer dctx.logger.Debug("finished processing initial")
 
 	pctx := dctx.proxyCtx
-	s.processClientIP(pctx.Addr.Addr())
+	s.processClientIP(dctx.logger, pctx.Addr.Addr())
 
 	q := pctx.Req.Question[0]
 	qt := q.Qtype
@@ -191,9 +303,10 @@ func (s *Server) processInitial(dctx *dnsContext) (rc resultCode) {
 }
 
 // processClientIP sends the client IP address to s.addrProc, if needed.
-func (s *Server) processClientIP(addr netip.Addr) {
+// logger is used for logging the invalid addresses.
+func (s *Server) processClientIP(logger *slog.Logger, addr netip.Addr) {
 	if !addr.IsValid() {
-		log.Info("dnsforward: warning: bad client addr %q", addr)
+		logger.Warn("bad client addr", "addr", addr)
 
 		return
 	}
@@ -212,8 +325,8 @@ func (s *Server) processClientIP(addr netip.Addr) {
 //
 // See https://www.ietf.org/archive/id/draft-ietf-add-ddr-10.html.
 func (s *Server) processDDRQuery(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing ddr")
-	defer log.Debug("dnsforward: finished processing ddr")
+	dctx.logger.Debug("started processing ddr")
+	defer dctx.logger.Debug("finished processing ddr")
 
 	if !s.conf.HandleDDR {
 		return resultCodeSuccess
@@ -311,8 +424,8 @@ func (s *Server) makeDDRResponse(req *dns.Msg) (resp *dns.Msg) {
 //
 // TODO(a.garipov): Adapt to AAAA as well.
 func (s *Server) processDHCPHosts(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing dhcp hosts")
-	defer log.Debug("dnsforward: finished processing dhcp hosts")
+	dctx.logger.Debug("started processing dhcp hosts")
+	defer dctx.logger.Debug("finished processing dhcp hosts")
 
 	pctx := dctx.proxyCtx
 	req := pctx.Req
@@ -324,7 +437,7 @@ func (s *Server) processDHCPHosts(dctx *dnsContext) (rc resultCode) {
 	}
 
 	if !pctx.IsPrivateClient {
-		log.Debug("dnsforward: %q requests for dhcp host %q", pctx.Addr, dhcpHost)
+		dctx.logger.Debug("request for dhcp host from public client", "host", dhcpHost)
 		pctx.Res = s.NewMsgNXDOMAIN(req)
 
 		// Do not even put into query log.
@@ -335,12 +448,12 @@ func (s *Server) processDHCPHosts(dctx *dnsContext) (rc resultCode) {
 	if ip == (netip.Addr{}) {
 		// Go on and process them with filters, including dnsrewrite ones, and
 		// possibly route them to a domain-specific upstream.
-		log.Debug("dnsforward: no dhcp record for %q", dhcpHost)
+		dctx.logger.Debug("no dhcp record", "host", dhcpHost)
 
 		return resultCodeSuccess
 	}
 
-	log.Debug("dnsforward: dhcp record for %q is %s", dhcpHost, ip)
+	dctx.logger.Debug("found dhcp record", "host", dhcpHost, "ip", ip)
 
 	resp := s.replyCompressed(req)
 	switch q.Qtype {
@@ -351,12 +464,12 @@ func (s *Server) processDHCPHosts(dctx *dnsContext) (rc resultCode) {
 		}
 		resp.Answer = append(resp.Answer, a)
 	case dns.TypeAAAA:
-		if s.dns64Pref != (netip.Prefix{}) {
+		if pref, ok := s.dns64Prefix(dctx); ok {
 			// Respond with DNS64-mapped address for IPv4 host if DNS64 is
 			// enabled.
 			aaaa := &dns.AAAA{
 				Hdr:  s.hdr(req, dns.TypeAAAA),
-				AAAA: s.mapDNS64(ip),
+				AAAA: mapDNS64(pref, ip),
 			}
 			resp.Answer = append(resp.Answer, aaaa)
 		}
@@ -369,11 +482,117 @@ func (s *Server) processDHCPHosts(dctx *dnsContext) (rc resultCode) {
 	return resultCodeSuccess
 }
 
+// processMDNS resolves the requests for the names within the domain bridged to
+// mDNS.  Like the DHCP hostnames, these are only resolved for private clients.
+func (s *Server) processMDNS(dctx *dnsContext) (rc resultCode) {
+	pctx := dctx.proxyCtx
+	req := pctx.Req
+
+	b := s.conf.MDNS
+	if b == nil || pctx.Res != nil || !b.Matches(req.Question[0].Name) {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing mdns")
+	defer dctx.logger.Debug("finished processing mdns")
+
+	if !pctx.IsPrivateClient {
+		dctx.logger.Debug(
+			"request for mdns name from public client",
+			"name", req.Question[0].Name,
+		)
+		pctx.Res = s.NewMsgNXDOMAIN(req)
+
+		// Do not even put into query log.
+		return resultCodeFinish
+	}
+
+	resp, err := b.Resolve(dctx.ctx, req)
+	if err != nil {
+		dctx.err = fmt.Errorf("resolving with mdns: %w", err)
+
+		return resultCodeError
+	}
+
+	pctx.Res = resp
+
+	return resultCodeSuccess
+}
+
+// processBypass records the request for the DNS bypass detection.  The
+// requests for the public DNS resolvers and the canary domains are blocked
+// later by [Server.processBypassBlock], so that the allowlist rules can
+// override the blocking.
+func (s *Server) processBypass(dctx *dnsContext) (rc resultCode) {
+	d := s.conf.Bypass
+	if d == nil {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing bypass")
+	defer dctx.logger.Debug("finished processing bypass")
+
+	pctx := dctx.proxyCtx
+	host := aghnet.NormalizeDomain(pctx.Req.Question[0].Name)
+
+	// Record the requests answered by the previous stages as well, since the
+	// detection of the drop-off relies on the number of all requests.
+	dctx.bypassMatch = d.Check(pctx.Addr.Addr(), host)
+
+	return resultCodeSuccess
+}
+
+// processBypassBlock blocks the requests for the public DNS resolvers and the
+// canary domains, unless they're already answered or allowlisted.
+func (s *Server) processBypassBlock(dctx *dnsContext) (rc resultCode) {
+	pctx := dctx.proxyCtx
+	if dctx.bypassMatch == bypass.MatchNone || pctx.Res != nil {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing bypass block")
+	defer dctx.logger.Debug("finished processing bypass block")
+
+	if !dctx.protectionEnabled || !dctx.setts.FilteringEnabled {
+		return resultCodeSuccess
+	} else if dctx.result != nil && dctx.result.Reason == filtering.NotFilteredAllowList {
+		// The user has explicitly allowed the hostname.
+		return resultCodeSuccess
+	} else if dctx.origQuestion.Name != "" {
+		// The user has explicitly rewritten the hostname.
+		return resultCodeSuccess
+	}
+
+	req := pctx.Req
+	host := aghnet.NormalizeDomain(req.Question[0].Name)
+
+	dctx.logger.Debug("bypass: blocking", "host", host)
+
+	dctx.result = &filtering.Result{
+		Rules: []*filtering.ResultRule{{
+			Text:         host,
+			FilterListID: rulelist.URLFilterIDBypass,
+		}},
+		Reason:     filtering.FilteredBypass,
+		IsFiltered: true,
+	}
+
+	if dctx.bypassMatch == bypass.MatchCanary {
+		// The canary domains must not resolve for the applications to disable
+		// their own resolvers.
+		pctx.Res = s.NewMsgNXDOMAIN(req)
+	} else {
+		pctx.Res = s.genDNSFilterMessage(dctx, dctx.result)
+	}
+
+	return resultCodeSuccess
+}
+
 // processDHCPAddrs responds to PTR requests if the target IP is leased by the
 // DHCP server.
 func (s *Server) processDHCPAddrs(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing dhcp addrs")
-	defer log.Debug("dnsforward: finished processing dhcp addrs")
+	dctx.logger.Debug("started processing dhcp addrs")
+	defer dctx.logger.Debug("finished processing dhcp addrs")
 
 	pctx := dctx.proxyCtx
 	if pctx.Res != nil {
@@ -391,7 +610,7 @@ func (s *Server) processDHCPAddrs(dctx *dnsContext) (rc resultCode) {
 		return resultCodeSuccess
 	}
 
-	log.Debug("dnsforward: dhcp client %s is %q", addr, host)
+	dctx.logger.Debug("found dhcp client", "addr", addr, "host", host)
 
 	req := pctx.Req
 	resp := s.replyCompressed(req)
@@ -414,8 +633,8 @@ func (s *Server) processDHCPAddrs(dctx *dnsContext) (rc resultCode) {
 
 // Apply filtering logic
 func (s *Server) processFilteringBeforeRequest(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing filtering before req")
-	defer log.Debug("dnsforward: finished processing filtering before req")
+	dctx.logger.Debug("started processing filtering before req")
+	defer dctx.logger.Debug("finished processing filtering before req")
 
 	if dctx.proxyCtx.RequestedPrivateRDNS != (netip.Prefix{}) {
 		// There is no need to filter request for locally served ARPA hostname
@@ -455,8 +674,8 @@ func ipStringFromAddr(addr net.Addr) (ipStr string) {
 
 // processUpstream passes request to upstream servers and handles the response.
 func (s *Server) processUpstream(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing upstream")
-	defer log.Debug("dnsforward: finished processing upstream")
+	dctx.logger.Debug("started processing upstream")
+	defer dctx.logger.Debug("finished processing upstream")
 
 	pctx := dctx.proxyCtx
 	req := pctx.Req
@@ -471,16 +690,22 @@ func (s *Server) processUpstream(dctx *dnsContext) (rc resultCode) {
 		// TODO(a.garipov): Route such queries to a custom upstream for the
 		// local domain name if there is one.
 		name := req.Question[0].Name
-		log.Debug("dnsforward: dhcp client hostname %q was not filtered", name[:len(name)-1])
+		dctx.logger.Debug("dhcp client hostname was not filtered", "host", name[:len(name)-1])
 		pctx.Res = s.NewMsgNXDOMAIN(req)
 
 		return resultCodeFinish
 	}
 
-	s.setCustomUpstream(pctx, dctx.clientID)
+	s.setCustomUpstream(dctx)
 
 	reqWantsDNSSEC := s.setReqAD(req)
 
+	if pctx.RequestedPrivateRDNS != (netip.Prefix{}) && s.conf.UsePrivateRDNS {
+		// The request is going to be sent to the private upstreams, which may
+		// send it back to the sockets served outside of the DNS proxy.
+		s.recDetector.add(req)
+	}
+
 	// Process the request further since it wasn't filtered.
 	prx := s.proxy()
 	if prx == nil {
@@ -489,7 +714,10 @@ func (s *Server) processUpstream(dctx *dnsContext) (rc resultCode) {
 		return resultCodeError
 	}
 
-	if dctx.err = prx.Resolve(pctx); dctx.err != nil {
+	start := time.Now()
+	dctx.err = s.resolveDNS64(prx, dctx)
+	s.traceUpstream(dctx, start)
+	if dctx.err != nil {
 		return resultCodeError
 	}
 
@@ -566,8 +794,10 @@ func (s *Server) dhcpHostFromRequest(q *dns.Question) (reqHost string) {
 	return reqHost[:len(reqHost)-len(s.localDomainSuffix)-1]
 }
 
-// setCustomUpstream sets custom upstream settings in pctx, if necessary.
-func (s *Server) setCustomUpstream(pctx *proxy.DNSContext, clientID string) {
+// setCustomUpstream sets custom upstream settings in the proxy context of dctx,
+// if necessary.
+func (s *Server) setCustomUpstream(dctx *dnsContext) {
+	pctx, clientID := dctx.proxyCtx, dctx.clientID
 	if !pctx.Addr.IsValid() || s.conf.ClientsContainer == nil {
 		return
 	}
@@ -576,13 +806,13 @@ func (s *Server) setCustomUpstream(pctx *proxy.DNSContext, clientID string) {
 	id := cmp.Or(clientID, pctx.Addr.Addr().String())
 	upsConf, err := s.conf.ClientsContainer.UpstreamConfigByID(id, s.bootstrap)
 	if err != nil {
-		log.Error("dnsforward: getting custom upstreams for client %s: %s", id, err)
+		dctx.logger.Error("getting custom upstreams", "id", id, slogutil.KeyError, err)
 
 		return
 	}
 
 	if upsConf != nil {
-		log.Debug("dnsforward: using custom upstreams for client %s", id)
+		dctx.logger.Debug("using custom upstreams", "id", id)
 
 		pctx.CustomUpstreamConfig = upsConf
 	}
@@ -590,8 +820,8 @@ func (s *Server) setCustomUpstream(pctx *proxy.DNSContext, clientID string) {
 
 // Apply filtering logic after we have received response from upstream servers
 func (s *Server) processFilteringAfterResponse(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing filtering after resp")
-	defer log.Debug("dnsforward: finished processing filtering after resp")
+	dctx.logger.Debug("started processing filtering after resp")
+	defer dctx.logger.Debug("finished processing filtering after resp")
 
 	switch res := dctx.result; res.Reason {
 	case filtering.NotFilteredAllowList:
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
@@ -94,6 +95,7 @@ func TestServer_ProcessInitial(t *testing.T) {
 			}
 
 			dctx := &dnsContext{
+				logger: testLogger,
 				proxyCtx: &proxy.DNSContext{
 					Req:       createTestMessageWithType(tc.target, tc.qType),
 					Addr:      testClientAddrPort,
@@ -188,6 +190,7 @@ func TestServer_ProcessFilteringAfterResponse(t *testing.T) {
 
 			resp := newResp(dns.RcodeSuccess, tc.req, tc.respAns)
 			dctx := &dnsContext{
+				logger: testLogger,
 				setts: &filtering.Settings{
 					FilteringEnabled:  true,
 					ProtectionEnabled: true,
@@ -340,6 +343,7 @@ func TestServer_ProcessDDRQuery(t *testing.T) {
 			req := createTestMessageWithType(tc.host, tc.qtype)
 
 			dctx := &dnsContext{
+				logger: testLogger,
 				proxyCtx: &proxy.DNSContext{
 					Req: req,
 				},
@@ -427,6 +431,7 @@ func TestServer_ProcessDHCPHosts_localRestriction(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			s := &Server{
+				logger:            testLogger,
 				dnsFilter:         createTestDNSFilter(t),
 				dhcpServer:        dhcp,
 				localDomainSuffix: localDomainSuffix,
@@ -444,6 +449,7 @@ func TestServer_ProcessDHCPHosts_localRestriction(t *testing.T) {
 			}
 
 			dctx := &dnsContext{
+				logger: testLogger,
 				proxyCtx: &proxy.DNSContext{
 					Req:             req,
 					IsPrivateClient: tc.isLocalCli,
@@ -562,6 +568,7 @@ func TestServer_ProcessDHCPHosts(t *testing.T) {
 		}
 
 		s := &Server{
+			logger:            testLogger,
 			dnsFilter:         createTestDNSFilter(t),
 			dhcpServer:        testDHCP,
 			localDomainSuffix: tc.suffix,
@@ -579,6 +586,7 @@ func TestServer_ProcessDHCPHosts(t *testing.T) {
 		}
 
 		dctx := &dnsContext{
+			logger: testLogger,
 			proxyCtx: &proxy.DNSContext{
 				Req:             req,
 				IsPrivateClient: true,
@@ -792,7 +800,7 @@ func TestServer_ProcessUpstream_localPTR(t *testing.T) {
 		)
 		pctx := newPrxCtx()
 
-		rc := s.processUpstream(&dnsContext{proxyCtx: pctx})
+		rc := s.processUpstream(&dnsContext{logger: testLogger, proxyCtx: pctx})
 		require.Equal(t, resultCodeSuccess, rc)
 		require.NotEmpty(t, pctx.Res.Answer)
 		ptr := testutil.RequireTypeAssert[*dns.PTR](t, pctx.Res.Answer[0])
@@ -820,12 +828,122 @@ func TestServer_ProcessUpstream_localPTR(t *testing.T) {
 		)
 		pctx := newPrxCtx()
 
-		rc := s.processUpstream(&dnsContext{proxyCtx: pctx})
+		rc := s.processUpstream(&dnsContext{logger: testLogger, proxyCtx: pctx})
 		require.Equal(t, resultCodeError, rc)
 		require.Empty(t, pctx.Res.Answer)
 	})
 }
 
+func TestServer_ProcessBypassBlock(t *testing.T) {
+	s := createTestServer(t, &filtering.Config{
+		BlockingMode: filtering.BlockingModeDefault,
+	}, ServerConfig{
+		UDPListenAddrs: []*net.UDPAddr{{}},
+		TCPListenAddrs: []*net.TCPAddr{{}},
+		Config: Config{
+			UpstreamMode:     UpstreamModeLoadBalance,
+			EDNSClientSubnet: &EDNSClientSubnet{Enabled: false},
+		},
+		ServePlainDNS: true,
+	})
+
+	testCases := []struct {
+		result     *filtering.Result
+		name       string
+		host       string
+		origName   string
+		wantReason filtering.Reason
+		wantRcode  int
+		match      bypass.Match
+		wantRes    bool
+	}{{
+		result:     &filtering.Result{},
+		name:       "resolver",
+		host:       "dns.google.",
+		origName:   "",
+		wantReason: filtering.FilteredBypass,
+		wantRcode:  dns.RcodeSuccess,
+		match:      bypass.MatchResolver,
+		wantRes:    true,
+	}, {
+		result:     &filtering.Result{},
+		name:       "canary",
+		host:       "use-application-dns.net.",
+		origName:   "",
+		wantReason: filtering.FilteredBypass,
+		wantRcode:  dns.RcodeNameError,
+		match:      bypass.MatchCanary,
+		wantRes:    true,
+	}, {
+		result:     &filtering.Result{},
+		name:       "none",
+		host:       "example.org.",
+		origName:   "",
+		wantReason: filtering.NotFilteredNotFound,
+		wantRcode:  0,
+		match:      bypass.MatchNone,
+		wantRes:    false,
+	}, {
+		result: &filtering.Result{
+			Reason: filtering.NotFilteredAllowList,
+		},
+		name:       "allowlisted",
+		host:       "dns.google.",
+		origName:   "",
+		wantReason: filtering.NotFilteredAllowList,
+		wantRcode:  0,
+		match:      bypass.MatchResolver,
+		wantRes:    false,
+	}, {
+		result: &filtering.Result{
+			Reason: filtering.Rewritten,
+		},
+		name:       "rewritten",
+		host:       "rewritten.example.",
+		origName:   "dns.google.",
+		wantReason: filtering.Rewritten,
+		wantRcode:  0,
+		match:      bypass.MatchResolver,
+		wantRes:    false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dctx := &dnsContext{
+				logger: testLogger,
+				proxyCtx: &proxy.DNSContext{
+					Addr: testClientAddrPort,
+					Req:  createTestMessage(tc.host),
+				},
+				setts: &filtering.Settings{
+					ProtectionEnabled: true,
+					FilteringEnabled:  true,
+				},
+				result:            tc.result,
+				origQuestion:      dns.Question{Name: tc.origName},
+				protectionEnabled: true,
+				bypassMatch:       tc.match,
+			}
+
+			rc := s.processBypassBlock(dctx)
+			require.Equal(t, resultCodeSuccess, rc)
+
+			assert.Equal(t, tc.wantReason, dctx.result.Reason)
+
+			res := dctx.proxyCtx.Res
+			if !tc.wantRes {
+				assert.Nil(t, res)
+
+				return
+			}
+
+			require.NotNil(t, res)
+
+			assert.Equal(t, tc.wantRcode, res.Rcode)
+		})
+	}
+}
+
 func TestIPStringFromAddr(t *testing.T) {
 	t.Run("not_nil", func(t *testing.T) {
 		addr := net.UDPAddr{
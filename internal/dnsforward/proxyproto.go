@@ -0,0 +1,251 @@
+package dnsforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/proxyproto"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+	"github.com/AdguardTeam/golibs/netutil"
+)
+
+// ProxyProtocolConfig is the configuration of accepting the PROXY protocol
+// headers, which carry the original addresses of the clients connected through
+// the load balancers and the reverse proxies.
+type ProxyProtocolConfig struct {
+	// TrustedSources are the networks of the proxies, from which the headers
+	// are accepted.  The headers from other addresses aren't parsed.
+	TrustedSources []netutil.Prefix `yaml:"trusted_sources"`
+
+	// Enabled defines if the headers are accepted on the plain DNS,
+	// DNS-over-TLS, DNS-over-QUIC, and web listeners.  The DNSCrypt listeners
+	// don't accept the headers.
+	Enabled bool `yaml:"enabled"`
+}
+
+// TrustedSet returns the set of the trusted networks.  c must not be nil.
+func (c *ProxyProtocolConfig) TrustedSet() (set netutil.SubnetSet) {
+	return netutil.SliceSubnetSet(netutil.UnembedPrefixes(c.TrustedSources))
+}
+
+// WrapListener returns l wrapped to accept the headers, if enabled.  c must not
+// be nil.
+func (c *ProxyProtocolConfig) WrapListener(l net.Listener) (wrapped net.Listener) {
+	if !c.Enabled {
+		return l
+	}
+
+	return proxyproto.NewListener(l, c.TrustedSet())
+}
+
+// proxyProtoAddrs are the addresses served with the PROXY protocol support
+// instead of the DNS proxy.
+type proxyProtoAddrs struct {
+	udp  []*net.UDPAddr
+	tcp  []*net.TCPAddr
+	tls  []*net.TCPAddr
+	quic []*net.UDPAddr
+}
+
+// prepareProxyProto moves the listen addresses of the protocols supporting the
+// PROXY protocol from proxyConf, so that they are served by the server started
+// with [Server.startProxyProto].  It assumes that prepareActivated has already
+// been called.
+func (s *Server) prepareProxyProto(proxyConf *proxy.Config) (err error) {
+	s.proxyProtoAddrs = nil
+
+	c := s.conf.ProxyProtocol
+	if !c.Enabled {
+		return nil
+	} else if len(c.TrustedSources) == 0 {
+		return errors.Error("proxy_protocol: no trusted_sources")
+	}
+
+	s.proxyProtoAddrs = &proxyProtoAddrs{
+		udp:  proxyConf.UDPListenAddr,
+		tcp:  proxyConf.TCPListenAddr,
+		tls:  proxyConf.TLSListenAddr,
+		quic: proxyConf.QUICListenAddr,
+	}
+
+	proxyConf.UDPListenAddr = nil
+	proxyConf.TCPListenAddr = nil
+	proxyConf.TLSListenAddr = nil
+	proxyConf.QUICListenAddr = nil
+
+	if len(proxyConf.DNSCryptUDPListenAddr)+len(proxyConf.DNSCryptTCPListenAddr) > 0 {
+		// The DNSCrypt listeners are served by the DNS proxy, which doesn't
+		// support the PROXY protocol.
+		s.logger.Warn("proxy protocol is not supported for dnscrypt")
+	}
+
+	return nil
+}
+
+// wrapProxyProto returns the listeners from ls wrapped to accept the PROXY
+// protocol headers.
+func (s *Server) wrapProxyProto(ls *socketact.DNSListeners) (wrapped *socketact.DNSListeners) {
+	trusted := s.conf.ProxyProtocol.TrustedSet()
+
+	wrapped = &socketact.DNSListeners{}
+	for _, c := range ls.UDP {
+		// Wrap the socket into the session one before the PROXY protocol
+		// one, since the latter hides the type of the socket.
+		c = socketact.NewSessionConn(c)
+		wrapped.UDP = append(wrapped.UDP, proxyproto.NewPacketConn(c, trusted))
+	}
+
+	for _, l := range ls.TCP {
+		wrapped.TCP = append(wrapped.TCP, proxyproto.NewListener(l, trusted))
+	}
+
+	for _, l := range ls.TLS {
+		wrapped.TLS = append(wrapped.TLS, proxyproto.NewListener(l, trusted))
+	}
+
+	for _, c := range ls.QUIC {
+		wrapped.QUIC = append(wrapped.QUIC, proxyproto.NewPacketConn(c, trusted))
+	}
+
+	return wrapped
+}
+
+// startProxyProto binds the sockets for s.proxyProtoAddrs and starts serving
+// them, if there are any.  s.serverLock is expected to be locked.
+func (s *Server) startProxyProto() (err error) {
+	addrs := s.proxyProtoAddrs
+	if addrs == nil {
+		return nil
+	}
+
+	ls, err := s.listenProxyProto(addrs)
+	if err != nil {
+		return fmt.Errorf("proxy protocol: %w", err)
+	}
+
+	p, rl := s.dnsProxy, s.ratelimiter
+	s.proxyProtoSrv = socketact.NewDNSServer(&socketact.DNSServerConfig{
+		Listeners: s.wrapProxyProto(ls),
+		TLSConfig: p.TLSConfig,
+		Handler: func(dctx *proxy.DNSContext) {
+			s.handleActivated(p, rl, dctx)
+		},
+	})
+
+	err = s.proxyProtoSrv.Start()
+	if err != nil {
+		return fmt.Errorf("proxy protocol: starting: %w", err)
+	}
+
+	s.logger.Info("accepting proxy protocol", "from", s.conf.ProxyProtocol.TrustedSources)
+
+	return nil
+}
+
+// listenProxyProto binds the sockets for addrs.  If any of them fails, it
+// closes the ones already bound.
+func (s *Server) listenProxyProto(
+	addrs *proxyProtoAddrs,
+) (ls *socketact.DNSListeners, err error) {
+	ls = &socketact.DNSListeners{}
+	defer func() {
+		if err != nil {
+			s.closeProxyProtoSockets()
+		}
+	}()
+
+	for _, addr := range addrs.udp {
+		var c net.PacketConn
+		c, err = s.listenUDP(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ls.UDP = append(ls.UDP, c)
+	}
+
+	for _, addr := range addrs.tcp {
+		var l net.Listener
+		l, err = s.listenTCP(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ls.TCP = append(ls.TCP, l)
+	}
+
+	for _, addr := range addrs.tls {
+		var l net.Listener
+		l, err = s.listenTCP(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ls.TLS = append(ls.TLS, l)
+	}
+
+	for _, addr := range addrs.quic {
+		var c net.PacketConn
+		c, err = s.listenUDP(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ls.QUIC = append(ls.QUIC, c)
+	}
+
+	return ls, nil
+}
+
+// listenUDP binds a UDP socket to addr and tracks it for closing.
+func (s *Server) listenUDP(addr *net.UDPAddr) (c net.PacketConn, err error) {
+	c, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening udp on %s: %w", addr, err)
+	}
+
+	s.proxyProtoSockets = append(s.proxyProtoSockets, c)
+
+	return c, nil
+}
+
+// listenTCP binds a TCP socket to addr and tracks it for closing.
+func (s *Server) listenTCP(addr *net.TCPAddr) (l net.Listener, err error) {
+	l, err = net.ListenTCP("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening tcp on %s: %w", addr, err)
+	}
+
+	s.proxyProtoSockets = append(s.proxyProtoSockets, l)
+
+	return l, nil
+}
+
+// stopProxyProto stops serving the sockets bound by [Server.startProxyProto]
+// and closes them.  s.serverLock is expected to be locked.
+func (s *Server) stopProxyProto() {
+	if s.proxyProtoSrv != nil {
+		err := s.proxyProtoSrv.Shutdown(context.Background())
+		if err != nil {
+			s.logger.Error("proxy protocol: shutting down", slogutil.KeyError, err)
+		}
+
+		s.proxyProtoSrv = nil
+	}
+
+	s.closeProxyProtoSockets()
+}
+
+// closeProxyProtoSockets closes the sockets bound by
+// [Server.listenProxyProto].
+func (s *Server) closeProxyProtoSockets() {
+	for _, c := range s.proxyProtoSockets {
+		logCloserErr(s.logger, c, "proxy protocol: closing socket")
+	}
+
+	s.proxyProtoSockets = nil
+}
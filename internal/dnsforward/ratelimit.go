@@ -0,0 +1,119 @@
+package dnsforward
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	rate "github.com/beefsack/go-rate"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+// ratelimitBucketTTL is the time a client's bucket is kept after the last
+// request.
+const ratelimitBucketTTL = 1 * time.Hour
+
+// ratelimiter limits the rate of the plain DNS requests received over UDP on
+// the sockets served outside of the DNS proxy, such as the pre-bound ones and
+// the ones accepting the PROXY protocol.  It works the same way as the
+// ratelimit of [proxy.Proxy], which only applies to the proxy's own sockets.
+// A nil *ratelimiter doesn't limit anything.
+type ratelimiter struct {
+	// logger is used for logging the operation of the ratelimiter.
+	logger *slog.Logger
+
+	// mu protects buckets from creating several buckets for the same subnet.
+	mu *sync.Mutex
+
+	// buckets are the *rate.RateLimiter values for the client subnets.
+	buckets *gocache.Cache
+
+	// allowlist are the sorted addresses of the clients which aren't limited.
+	allowlist []netip.Addr
+
+	// limit is the maximum number of requests per second from a subnet.
+	limit int
+
+	// subnetLenIPv4 is the length of the IPv4 client subnets.
+	subnetLenIPv4 int
+
+	// subnetLenIPv6 is the length of the IPv6 client subnets.
+	subnetLenIPv6 int
+}
+
+// newRatelimiter returns a new properly initialized *ratelimiter.  rl is nil if
+// the ratelimit is disabled in c.  logger and c must not be nil.
+func newRatelimiter(logger *slog.Logger, c *ServerConfig) (rl *ratelimiter) {
+	if c.Ratelimit == 0 {
+		return nil
+	}
+
+	allowlist := make([]netip.Addr, 0, len(c.RatelimitWhitelist))
+	for _, addr := range c.RatelimitWhitelist {
+		allowlist = append(allowlist, addr.Unmap())
+	}
+
+	slices.SortFunc(allowlist, netip.Addr.Compare)
+
+	return &ratelimiter{
+		logger:        logger,
+		mu:            &sync.Mutex{},
+		buckets:       gocache.New(ratelimitBucketTTL, ratelimitBucketTTL),
+		allowlist:     allowlist,
+		limit:         int(c.Ratelimit),
+		subnetLenIPv4: c.RatelimitSubnetLenIPv4,
+		subnetLenIPv6: c.RatelimitSubnetLenIPv6,
+	}
+}
+
+// isRatelimited returns true if the request from addr exceeds the limit of
+// the client's subnet.
+func (rl *ratelimiter) isRatelimited(addr netip.Addr) (ok bool) {
+	if rl == nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	if _, ok = slices.BinarySearchFunc(rl.allowlist, addr, netip.Addr.Compare); ok {
+		return false
+	}
+
+	bits := rl.subnetLenIPv6
+	if addr.Is4() {
+		bits = rl.subnetLenIPv4
+	}
+
+	subnet, err := addr.Prefix(bits)
+	if err != nil {
+		// Shouldn't happen, since the lengths are validated with the
+		// configuration.
+		subnet = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	allow, _ := rl.bucket(subnet.Addr().String()).Try()
+
+	return !allow
+}
+
+// bucket returns the limiter for the subnet with the given key, creating it
+// if necessary.
+func (rl *ratelimiter) bucket(key string) (b *rate.RateLimiter) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if v, found := rl.buckets.Get(key); found {
+		if b, ok := v.(*rate.RateLimiter); ok {
+			return b
+		}
+
+		rl.logger.Error("ratelimit: unexpected value in cache", "type", fmt.Sprintf("%T", v))
+	}
+
+	b = rate.New(rl.limit, time.Second)
+	rl.buckets.Set(key, b, ratelimitBucketTTL)
+
+	return b
+}
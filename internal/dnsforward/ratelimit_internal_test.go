@@ -0,0 +1,44 @@
+package dnsforward
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRatelimiter_isRatelimited(t *testing.T) {
+	var (
+		clientAddr  = netip.MustParseAddr("192.0.2.1")
+		sameSubnet  = netip.MustParseAddr("192.0.2.2")
+		otherSubnet = netip.MustParseAddr("198.51.100.1")
+		allowedAddr = netip.MustParseAddr("192.0.2.3")
+	)
+
+	rl := newRatelimiter(testLogger, &ServerConfig{
+		Config: Config{
+			Ratelimit:              1,
+			RatelimitSubnetLenIPv4: 24,
+			RatelimitSubnetLenIPv6: 56,
+			RatelimitWhitelist:     []netip.Addr{allowedAddr},
+		},
+	})
+	require.NotNil(t, rl)
+
+	assert.False(t, rl.isRatelimited(clientAddr))
+	assert.True(t, rl.isRatelimited(clientAddr))
+	assert.True(t, rl.isRatelimited(sameSubnet))
+	assert.True(t, rl.isRatelimited(netip.AddrFrom16(sameSubnet.As16())))
+
+	assert.False(t, rl.isRatelimited(otherSubnet))
+
+	for range 3 {
+		assert.False(t, rl.isRatelimited(allowedAddr))
+	}
+
+	disabled := newRatelimiter(testLogger, &ServerConfig{})
+	require.Nil(t, disabled)
+
+	assert.False(t, disabled.isRatelimited(clientAddr))
+}
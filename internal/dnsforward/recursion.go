@@ -0,0 +1,75 @@
+package dnsforward
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/AdguardTeam/golibs/cache"
+	"github.com/miekg/dns"
+)
+
+// Recursion detection parameters, the same as the ones of the DNS proxy.
+const (
+	// recursionTTL is the time a request sent to the private upstreams is
+	// remembered for.
+	recursionTTL = 1 * time.Second
+
+	// recursionMaxReqs is the maximum number of the remembered requests.
+	recursionMaxReqs = 1000
+)
+
+// recursionDetector detects the requests sent by the server to the private
+// upstreams, which come back to it over the sockets served outside of the DNS
+// proxy.  The DNS proxy has its own detector, which only checks the requests
+// received over its own sockets.
+type recursionDetector struct {
+	recent cache.Cache
+}
+
+// newRecursionDetector returns a new properly initialized *recursionDetector.
+func newRecursionDetector() (rd *recursionDetector) {
+	return &recursionDetector{
+		recent: cache.New(cache.Config{
+			EnableLRU: true,
+			MaxCount:  recursionMaxReqs,
+		}),
+	}
+}
+
+// add remembers req, if it has a question.  It's safe for concurrent use.
+func (rd *recursionDetector) add(req *dns.Msg) {
+	if len(req.Question) == 0 {
+		return
+	}
+
+	expire := make([]byte, 8)
+	binary.BigEndian.PutUint64(expire, uint64(time.Now().Add(recursionTTL).UnixNano()))
+
+	rd.recent.Set(recursionKey(req), expire)
+}
+
+// check returns true if req has recently been sent by the server.  It's safe
+// for concurrent use.
+func (rd *recursionDetector) check(req *dns.Msg) (ok bool) {
+	if len(req.Question) == 0 {
+		return false
+	}
+
+	expire := rd.recent.Get(recursionKey(req))
+	if len(expire) != 8 {
+		return false
+	}
+
+	return time.Now().UnixNano() < int64(binary.BigEndian.Uint64(expire))
+}
+
+// recursionKey returns the key identifying req by its ID, question type, and
+// question name.  req must have a question.
+func recursionKey(req *dns.Msg) (key []byte) {
+	q := req.Question[0]
+	key = make([]byte, 4, 4+len(q.Name))
+	binary.BigEndian.PutUint16(key, req.Id)
+	binary.BigEndian.PutUint16(key[2:], q.Qtype)
+
+	return append(key, q.Name...)
+}
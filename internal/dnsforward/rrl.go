@@ -0,0 +1,65 @@
+package dnsforward
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/rrl"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+)
+
+// prepareRRL creates the response rate limiter, if it's enabled.
+func (s *Server) prepareRRL() (err error) {
+	s.rrl = nil
+
+	c := s.conf.RRL
+	if !c.Enabled {
+		return nil
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return fmt.Errorf("rrl: %w", err)
+	}
+
+	s.rrl = rrl.New(&c)
+
+	return nil
+}
+
+// processRRL limits the rate of the responses sent over UDP.  The limited
+// responses are either dropped or replaced with the truncated ones, unless the
+// limiter is in the log-only mode.  The requests from the clients in the rate
+// limiting allowlist are never limited.
+func (s *Server) processRRL(dctx *dnsContext) (rc resultCode) {
+	pctx := dctx.proxyCtx
+	if s.rrl == nil || pctx.Res == nil || pctx.Proto != proxy.ProtoUDP {
+		return resultCodeSuccess
+	}
+
+	addr := pctx.Addr.Addr().Unmap()
+	if slices.Contains(s.conf.RatelimitWhitelist, addr) {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing rrl")
+	defer dctx.logger.Debug("finished processing rrl")
+
+	dctx.rrlAction = s.rrl.Check(addr, pctx.Res)
+	if s.rrl.LogOnly() {
+		return resultCodeSuccess
+	}
+
+	switch dctx.rrlAction {
+	case rrl.ActionDrop:
+		pctx.Res = nil
+	case rrl.ActionSlip:
+		resp := s.reply(pctx.Req, pctx.Res.Rcode)
+		resp.Truncated = true
+		pctx.Res = resp
+	default:
+		// Go on.
+	}
+
+	return resultCodeSuccess
+}
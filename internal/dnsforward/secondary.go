@@ -0,0 +1,44 @@
+package dnsforward
+
+import (
+	"github.com/miekg/dns"
+)
+
+// processSecondaryNotify responds to the NOTIFY requests for the secondary
+// zones.  It precedes the filtering, since the NOTIFY requests only come from
+// the primaries and aren't the requests of the clients.
+func (s *Server) processSecondaryNotify(dctx *dnsContext) (rc resultCode) {
+	m := s.conf.Secondary
+	pctx := dctx.proxyCtx
+	req := pctx.Req
+	if m == nil || pctx.Res != nil || req.Opcode != dns.OpcodeNotify {
+		return resultCodeSuccess
+	}
+
+	pctx.Res = m.Notify(req, pctx.Addr.Addr())
+
+	// Don't put the NOTIFY requests into the query log.
+	return resultCodeFinish
+}
+
+// processSecondary answers the requests for the names within the secondary
+// zones authoritatively from their local copies.  It follows the filtering, so
+// the blocked requests and the rewritten ones are handled as usual.  The
+// requests for the zones, which aren't loaded yet or have expired, are resolved
+// as usual.
+func (s *Server) processSecondary(dctx *dnsContext) (rc resultCode) {
+	m := s.conf.Secondary
+	pctx := dctx.proxyCtx
+	if m == nil || pctx.Res != nil {
+		return resultCodeSuccess
+	}
+
+	dctx.logger.Debug("started processing secondary zones")
+	defer dctx.logger.Debug("finished processing secondary zones")
+
+	if resp := m.Answer(pctx.Req); resp != nil {
+		pctx.Res = resp
+	}
+
+	return resultCodeSuccess
+}
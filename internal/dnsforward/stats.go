@@ -7,16 +7,16 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
+	"github.com/AdguardTeam/AdGuardHome/internal/rrl"
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
 	"github.com/AdguardTeam/dnsproxy/proxy"
-	"github.com/AdguardTeam/golibs/log"
 	"github.com/miekg/dns"
 )
 
 // Write Stats data and logs
 func (s *Server) processQueryLogsAndStats(dctx *dnsContext) (rc resultCode) {
-	log.Debug("dnsforward: started processing querylog and stats")
-	defer log.Debug("dnsforward: finished processing querylog and stats")
+	dctx.logger.Debug("started processing querylog and stats")
+	defer dctx.logger.Debug("finished processing querylog and stats")
 
 	pctx := dctx.proxyCtx
 	q := pctx.Req.Question[0]
@@ -27,7 +27,7 @@ func (s *Server) processQueryLogsAndStats(dctx *dnsContext) (rc resultCode) {
 	s.anonymizer.Load()(ip)
 	ipStr := net.IP(ip).String()
 
-	log.Debug("dnsforward: client ip for stats and querylog: %s", ipStr)
+	dctx.logger.Debug("client ip for stats and querylog", "ip", ipStr)
 
 	ids := []string{ipStr}
 	if dctx.clientID != "" {
@@ -47,24 +47,24 @@ func (s *Server) processQueryLogsAndStats(dctx *dnsContext) (rc resultCode) {
 	if s.shouldLog(host, qt, cl, ids) {
 		s.logQuery(dctx, ip, processingTime)
 	} else {
-		log.Debug(
-			"dnsforward: request %s %s %q from %s ignored; not adding to querylog",
-			dns.Class(cl),
-			dns.Type(qt),
-			host,
-			ipStr,
+		dctx.logger.Debug(
+			"request ignored; not adding to querylog",
+			"qclass", dns.Class(cl),
+			"qtype", dns.Type(qt),
+			"host", host,
+			"ip", ipStr,
 		)
 	}
 
 	if s.shouldCountStat(host, qt, cl, ids) {
 		s.updateStats(dctx, ipStr, processingTime)
 	} else {
-		log.Debug(
-			"dnsforward: request %s %s %q from %s ignored; not counting in stats",
-			dns.Class(cl),
-			dns.Type(qt),
-			host,
-			ipStr,
+		dctx.logger.Debug(
+			"request ignored; not counting in stats",
+			"qclass", dns.Class(cl),
+			"qtype", dns.Type(qt),
+			"host", host,
+			"ip", ipStr,
 		)
 	}
 
@@ -105,6 +105,7 @@ func (s *Server) logQuery(dctx *dnsContext, ip net.IP, processingTime time.Durat
 		ClientIP:          ip,
 		Elapsed:           processingTime,
 		AuthenticatedData: dctx.responseAD,
+		AnswerGeo:         dctx.answerGeo,
 	}
 
 	switch pctx.Proto {
@@ -151,6 +152,10 @@ func (s *Server) updateStats(dctx *dnsContext, clientIP string, processingTime t
 		e.Client = clientIP
 	}
 
+	e.Countries, e.ASNs = answerGeoKeys(dctx.answerGeo)
+	e.RRLDropped = dctx.rrlAction == rrl.ActionDrop
+	e.RRLSlipped = dctx.rrlAction == rrl.ActionSlip
+
 	switch dctx.result.Reason {
 	case filtering.FilteredSafeBrowsing:
 		e.Result = stats.RSafeBrowsing
@@ -161,8 +166,14 @@ func (s *Server) updateStats(dctx *dnsContext, clientIP string, processingTime t
 	case
 		filtering.FilteredBlockList,
 		filtering.FilteredInvalid,
-		filtering.FilteredBlockedService:
+		filtering.FilteredBlockedService,
+		filtering.FilteredBypass,
+		filtering.FilteredGeoIP:
 		e.Result = stats.RFiltered
+	case filtering.FilteredAnomaly:
+		if dctx.result.IsFiltered {
+			e.Result = stats.RFiltered
+		}
 	}
 
 	s.stats.Update(e)
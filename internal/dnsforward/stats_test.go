@@ -202,6 +202,7 @@ func TestServer_ProcessQueryLogsAndStats(t *testing.T) {
 		ql := &testQueryLog{}
 		st := &testStats{}
 		srv := &Server{
+			logger:     testLogger,
 			queryLog:   ql,
 			stats:      st,
 			anonymizer: aghnet.NewIPMut(nil),
@@ -220,6 +221,7 @@ func TestServer_ProcessQueryLogsAndStats(t *testing.T) {
 				Upstream: ups,
 			}
 			dctx := &dnsContext{
+				logger:    testLogger,
 				proxyCtx:  pctx,
 				startTime: time.Now(),
 				result: &filtering.Result{
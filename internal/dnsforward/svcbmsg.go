@@ -2,10 +2,11 @@ package dnsforward
 
 import (
 	"encoding/base64"
+	"log/slog"
 	"net"
 	"strconv"
 
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/urlfilter/rules"
 	"github.com/miekg/dns"
 )
@@ -44,21 +45,22 @@ var strToSVCBKey = map[string]dns.SVCBKey{
 	"echconfig": dns.SVCB_ECHCONFIG,
 }
 
-// svcbKeyHandler is a handler for one SVCB parameter key.
-type svcbKeyHandler func(valStr string) (val dns.SVCBKeyValue)
+// svcbKeyHandler is a handler for one SVCB parameter key.  logger is used for
+// logging the invalid values.
+type svcbKeyHandler func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue)
 
 // svcbKeyHandlers are the supported SVCB parameters handlers.
 var svcbKeyHandlers = map[string]svcbKeyHandler{
-	"alpn": func(valStr string) (val dns.SVCBKeyValue) {
+	"alpn": func(_ *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		return &dns.SVCBAlpn{
 			Alpn: []string{valStr},
 		}
 	},
 
-	"ech": func(valStr string) (val dns.SVCBKeyValue) {
+	"ech": func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		ech, err := base64.StdEncoding.DecodeString(valStr)
 		if err != nil {
-			log.Debug("can't parse svcb/https ech: %s; ignoring", err)
+			logger.Debug("can't parse svcb/https ech; ignoring", slogutil.KeyError, err)
 
 			return nil
 		}
@@ -68,10 +70,10 @@ var svcbKeyHandlers = map[string]svcbKeyHandler{
 		}
 	},
 
-	"ipv4hint": func(valStr string) (val dns.SVCBKeyValue) {
+	"ipv4hint": func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		ip := net.ParseIP(valStr)
 		if ip4 := ip.To4(); ip == nil || ip4 == nil {
-			log.Debug("can't parse svcb/https ipv4 hint %q; ignoring", valStr)
+			logger.Debug("can't parse svcb/https ipv4 hint; ignoring", "hint", valStr)
 
 			return nil
 		}
@@ -81,10 +83,10 @@ var svcbKeyHandlers = map[string]svcbKeyHandler{
 		}
 	},
 
-	"ipv6hint": func(valStr string) (val dns.SVCBKeyValue) {
+	"ipv6hint": func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		ip := net.ParseIP(valStr)
 		if ip == nil {
-			log.Debug("can't parse svcb/https ipv6 hint %q; ignoring", valStr)
+			logger.Debug("can't parse svcb/https ipv6 hint; ignoring", "hint", valStr)
 
 			return nil
 		}
@@ -94,10 +96,10 @@ var svcbKeyHandlers = map[string]svcbKeyHandler{
 		}
 	},
 
-	"mandatory": func(valStr string) (val dns.SVCBKeyValue) {
+	"mandatory": func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		code, ok := strToSVCBKey[valStr]
 		if !ok {
-			log.Debug("unknown svcb/https mandatory key %q, ignoring", valStr)
+			logger.Debug("unknown svcb/https mandatory key; ignoring", "key", valStr)
 
 			return nil
 		}
@@ -107,14 +109,14 @@ var svcbKeyHandlers = map[string]svcbKeyHandler{
 		}
 	},
 
-	"no-default-alpn": func(_ string) (val dns.SVCBKeyValue) {
+	"no-default-alpn": func(_ *slog.Logger, _ string) (val dns.SVCBKeyValue) {
 		return &dns.SVCBNoDefaultAlpn{}
 	},
 
-	"port": func(valStr string) (val dns.SVCBKeyValue) {
+	"port": func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		port64, err := strconv.ParseUint(valStr, 10, 16)
 		if err != nil {
-			log.Debug("can't parse svcb/https port: %s; ignoring", err)
+			logger.Debug("can't parse svcb/https port; ignoring", slogutil.KeyError, err)
 
 			return nil
 		}
@@ -126,15 +128,12 @@ var svcbKeyHandlers = map[string]svcbKeyHandler{
 
 	// TODO(a.garipov): This is the previous name for the parameter that has
 	// since been changed.  Remove this in v0.109.0.
-	"echconfig": func(valStr string) (val dns.SVCBKeyValue) {
-		log.Info(
-			`warning: svcb/https record parameter name "echconfig" is deprecated; ` +
-				`use "ech" instead`,
-		)
+	"echconfig": func(logger *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
+		logger.Warn(`svcb/https record parameter name "echconfig" is deprecated; use "ech" instead`)
 
 		ech, err := base64.StdEncoding.DecodeString(valStr)
 		if err != nil {
-			log.Debug("can't parse svcb/https ech: %s; ignoring", err)
+			logger.Debug("can't parse svcb/https ech; ignoring", slogutil.KeyError, err)
 
 			return nil
 		}
@@ -144,7 +143,7 @@ var svcbKeyHandlers = map[string]svcbKeyHandler{
 		}
 	},
 
-	"dohpath": func(valStr string) (val dns.SVCBKeyValue) {
+	"dohpath": func(_ *slog.Logger, valStr string) (val dns.SVCBKeyValue) {
 		return &dns.SVCBDoHPath{
 			Template: valStr,
 		}
@@ -177,12 +176,12 @@ func (s *Server) genAnswerSVCB(req *dns.Msg, svcb *rules.DNSSVCB) (ans *dns.SVCB
 	for k, valStr := range svcb.Params {
 		handler, ok := svcbKeyHandlers[k]
 		if !ok {
-			log.Debug("unknown svcb/https key %q, ignoring", k)
+			s.logger.Debug("unknown svcb/https key; ignoring", "key", k)
 
 			continue
 		}
 
-		val := handler(valStr)
+		val := handler(s.logger, valStr)
 		if val == nil {
 			continue
 		}
@@ -0,0 +1,127 @@
+package dnsforward
+
+import (
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/miekg/dns"
+)
+
+// Keys of the tracing span attributes.
+const (
+	attrClientAddr      = "client.address"
+	attrClientID        = "client.id"
+	attrDNSProto        = "dns.protocol"
+	attrDNSQName        = "dns.question.name"
+	attrDNSQType        = "dns.question.type"
+	attrDNSRcode        = "dns.response.rcode"
+	attrFilteringReason = "filtering.reason"
+	attrFilteringRule   = "filtering.rule"
+	attrFilteringListID = "filtering.list_id"
+	attrUpstreamAddr    = "dns.upstream.address"
+	attrUpstreamCached  = "dns.upstream.cached"
+)
+
+// requestAttrs returns the tracing span attributes describing the request.
+func requestAttrs(pctx *proxy.DNSContext) (attrs []aghtrace.Attr) {
+	q := pctx.Req.Question[0]
+
+	return []aghtrace.Attr{
+		aghtrace.String(attrDNSQName, q.Name),
+		aghtrace.String(attrDNSQType, dns.Type(q.Qtype).String()),
+		aghtrace.String(attrDNSProto, string(pctx.Proto)),
+		aghtrace.String(attrClientAddr, pctx.Addr.Addr().String()),
+	}
+}
+
+// responseAttrs returns the tracing span attributes describing the result of
+// the request processing.
+func responseAttrs(dctx *dnsContext) (attrs []aghtrace.Attr) {
+	if dctx.clientID != "" {
+		attrs = append(attrs, aghtrace.String(attrClientID, dctx.clientID))
+	}
+
+	if res := dctx.proxyCtx.Res; res != nil {
+		attrs = append(attrs, aghtrace.String(attrDNSRcode, dns.RcodeToString[res.Rcode]))
+	}
+
+	return append(attrs, filteringAttrs(dctx)...)
+}
+
+// stageAttrs returns the tracing span attributes describing the result of the
+// stage with the given name.
+func stageAttrs(dctx *dnsContext, name string) (attrs []aghtrace.Attr) {
+	switch name {
+	case "filtering_request", "filtering_response":
+		return filteringAttrs(dctx)
+	case "upstream":
+		pctx := dctx.proxyCtx
+		if pctx.Upstream != nil {
+			return []aghtrace.Attr{
+				aghtrace.String(attrUpstreamAddr, pctx.Upstream.Address()),
+				aghtrace.Bool(attrUpstreamCached, false),
+			}
+		} else if pctx.CachedUpstreamAddr != "" {
+			return []aghtrace.Attr{
+				aghtrace.String(attrUpstreamAddr, pctx.CachedUpstreamAddr),
+				aghtrace.Bool(attrUpstreamCached, true),
+			}
+		}
+
+		return nil
+	default:
+		return nil
+	}
+}
+
+// filteringAttrs returns the tracing span attributes describing the filtering
+// result, if there is one.
+func filteringAttrs(dctx *dnsContext) (attrs []aghtrace.Attr) {
+	return resultAttrs(dctx.result)
+}
+
+// resultAttrs returns the tracing span attributes describing res, if it's a
+// match.  res may be nil.
+func resultAttrs(res *filtering.Result) (attrs []aghtrace.Attr) {
+	if res == nil || !res.Reason.Matched() {
+		return nil
+	}
+
+	attrs = []aghtrace.Attr{aghtrace.String(attrFilteringReason, res.Reason.String())}
+	if len(res.Rules) > 0 {
+		r := res.Rules[0]
+		attrs = append(
+			attrs,
+			aghtrace.String(attrFilteringRule, r.Text),
+			aghtrace.Int(attrFilteringListID, int(r.FilterListID)),
+		)
+	}
+
+	return attrs
+}
+
+// traceUpstream records the tracing span of the exchange with the upstream
+// server, which has been started at start, if the response hasn't been taken
+// from the cache.  Since the upstreams of dnsproxy don't accept a context, the
+// span covers the whole exchange, including the retries and the fallback
+// servers, and describes the upstream which has resolved the request.
+func (s *Server) traceUpstream(dctx *dnsContext, start time.Time) {
+	pctx := dctx.proxyCtx
+	if pctx.Upstream == nil && dctx.err == nil {
+		// Go on, since there was no exchange.
+		return
+	}
+
+	var attrs []aghtrace.Attr
+	if pctx.Upstream != nil {
+		attrs = append(attrs, aghtrace.String(attrUpstreamAddr, pctx.Upstream.Address()))
+	}
+
+	if res := pctx.Res; res != nil {
+		attrs = append(attrs, aghtrace.String(attrDNSRcode, dns.RcodeToString[res.Rcode]))
+	}
+
+	s.tracer.RecordClient(dctx.ctx, "dns.upstream.exchange", start, time.Now(), dctx.err, attrs...)
+}
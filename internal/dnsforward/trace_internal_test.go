@@ -0,0 +1,139 @@
+package dnsforward
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestServer_handleDNSRequest_tracing(t *testing.T) {
+	s := createTestServer(t, &filtering.Config{
+		ProtectionEnabled: true,
+		BlockingMode:      filtering.BlockingModeDefault,
+	}, ServerConfig{
+		UDPListenAddrs: []*net.UDPAddr{{}},
+		TCPListenAddrs: []*net.TCPAddr{{}},
+		Config: Config{
+			UpstreamMode:     UpstreamModeLoadBalance,
+			EDNSClientSubnet: &EDNSClientSubnet{Enabled: false},
+		},
+		ServePlainDNS: true,
+	})
+	s.conf.UpstreamConfig.Upstreams = []upstream.Upstream{
+		aghtest.NewUpstreamMock(func(req *dns.Msg) (resp *dns.Msg, err error) {
+			return (&dns.Msg{}).SetReply(req), nil
+		}),
+	}
+
+	exp := tracetest.NewInMemoryExporter()
+	s.tracer = aghtrace.New(&aghtrace.Config{
+		Exporter:    exp,
+		SampleRatio: 1,
+	})
+
+	startDeferStop(t, s)
+
+	// handle handles the request and returns its spans mapped by their
+	// names.
+	handle := func(t *testing.T, pctx *proxy.DNSContext) (spans map[string]tracetest.SpanStub) {
+		t.Helper()
+
+		exp.Reset()
+
+		err := s.handleDNSRequest(nil, pctx)
+		require.NoError(t, err)
+
+		err = s.tracer.Flush(context.Background())
+		require.NoError(t, err)
+
+		spans = map[string]tracetest.SpanStub{}
+		for _, sd := range exp.GetSpans() {
+			spans[sd.Name] = sd
+		}
+
+		require.Contains(t, spans, "dns.request")
+
+		return spans
+	}
+
+	t.Run("blocked", func(t *testing.T) {
+		spans := handle(t, &proxy.DNSContext{
+			Proto: proxy.ProtoUDP,
+			Req:   createTestMessage("nxdomain.example.org."),
+			Addr:  testClientAddrPort,
+		})
+
+		root := spans["dns.request"]
+		assert.Equal(t, trace.SpanKindServer, root.SpanKind)
+		assert.Contains(t, root.Attributes, aghtrace.String(attrDNSQName, "nxdomain.example.org."))
+		assert.Contains(t, root.Attributes, aghtrace.String(
+			attrFilteringReason,
+			filtering.FilteredBlockList.String(),
+		))
+
+		for name, sd := range spans {
+			assert.Equalf(t, root.SpanContext.TraceID(), sd.SpanContext.TraceID(), "span %q", name)
+		}
+
+		require.Contains(t, spans, "dns.stage.filtering_request")
+		stage := spans["dns.stage.filtering_request"]
+		assert.Equal(t, root.SpanContext.SpanID(), stage.Parent.SpanID())
+
+		require.Contains(t, spans, "filtering.check_host")
+		check := spans["filtering.check_host"]
+		assert.Equal(t, stage.SpanContext.SpanID(), check.Parent.SpanID())
+		assert.Contains(
+			t,
+			check.Attributes,
+			aghtrace.String(attrFilteringRule, "||nxdomain.example.org"),
+		)
+
+		// The request is blocked, so it isn't sent to the upstream.
+		require.Contains(t, spans, "dns.stage.upstream")
+		assert.Empty(t, spans["dns.stage.upstream"].Attributes)
+		assert.NotContains(t, spans, "dns.upstream.exchange")
+	})
+
+	t.Run("upstream", func(t *testing.T) {
+		const (
+			traceIDStr = "4bf92f3577b34da6a3ce929d0e0e4736"
+			spanIDStr  = "00f067aa0ba902b7"
+		)
+
+		r := httptest.NewRequest(http.MethodPost, "/dns-query", nil)
+		r.Header.Set("Traceparent", "00-"+traceIDStr+"-"+spanIDStr+"-01")
+
+		spans := handle(t, &proxy.DNSContext{
+			Proto:       proxy.ProtoHTTPS,
+			Req:         createTestMessage("example.org."),
+			Addr:        testClientAddrPort,
+			HTTPRequest: r,
+		})
+
+		root := spans["dns.request"]
+		assert.Equal(t, traceIDStr, root.SpanContext.TraceID().String())
+		assert.Equal(t, spanIDStr, root.Parent.SpanID().String())
+
+		require.Contains(t, spans, "dns.stage.upstream")
+		stage := spans["dns.stage.upstream"]
+
+		require.Contains(t, spans, "dns.upstream.exchange")
+		exchange := spans["dns.upstream.exchange"]
+		assert.Equal(t, trace.SpanKindClient, exchange.SpanKind)
+		assert.Equal(t, stage.SpanContext.SpanID(), exchange.Parent.SpanID())
+		assert.Contains(t, exchange.Attributes, aghtrace.String(attrUpstreamAddr, "upstream.example"))
+	})
+}
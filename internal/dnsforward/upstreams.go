@@ -2,13 +2,18 @@ package dnsforward
 
 import (
 	"fmt"
+	"log/slog"
+	"net"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/stringutil"
 )
@@ -24,9 +29,11 @@ import (
 // improve when the [proxy.UpstreamConfig] will become an [upstream.Resolver]
 // and be used here.
 func newBootstrap(
+	logger *slog.Logger,
 	addrs []string,
 	etcHosts upstream.Resolver,
 	opts *upstream.Options,
+	prx *upsproxy.Proxies,
 ) (r upstream.Resolver, boots []*upstream.UpstreamResolver, err error) {
 	if len(addrs) == 0 {
 		addrs = defaultBootstrap
@@ -38,6 +45,14 @@ func newBootstrap(
 		return nil, nil, err
 	}
 
+	err = proxyBootstraps(boots, opts, prx)
+	if err != nil {
+		closeBoots(logger, boots)
+
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, nil, err
+	}
+
 	var parallel upstream.ParallelResolver
 	for _, b := range boots {
 		parallel = append(parallel, upstream.NewCachingResolver(b))
@@ -52,25 +67,197 @@ func newBootstrap(
 	return r, boots, nil
 }
 
+// proxyBootstraps makes boots connect to their servers through the global
+// proxy chosen by prx, if any.
+func proxyBootstraps(
+	boots []*upstream.UpstreamResolver,
+	opts *upstream.Options,
+	prx *upsproxy.Proxies,
+) (err error) {
+	var errs []error
+	for i, b := range boots {
+		var u upstream.Upstream
+		u, err = prx.WrapBootstrap(b.Upstream, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bootstrap at index %d: %w", i, err))
+		} else if u != b.Upstream {
+			errs = append(errs, b.Upstream.Close())
+			b.Upstream = u
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// errRecursiveProxied is returned by [ParseUpstreamsConfig] when there is a
+// proxy configured for the [recursor.UpstreamAddr] upstream.
+const errRecursiveProxied errors.Error = "recursive upstream doesn't support proxies, " +
+	"since it queries the authoritative servers over udp"
+
+// recursivePlaceholder is the address substituted for [recursor.UpstreamAddr]
+// before parsing the upstream configuration, so that the domain specifications
+// are handled by [proxy.ParseUpstreamsConfig] as usual.  The .invalid top-level
+// domain is reserved, see RFC 6761.
+const recursivePlaceholder = "recursive.invalid"
+
+// ParseUpstreamsConfig is a wrapper around [proxy.ParseUpstreamsConfig], which
+// also accepts [recursor.UpstreamAddr] as an upstream address, including the
+// ones with the domain specifications.  Such upstreams resolve the requests
+// with rec, which may be nil if the configuration is only validated.  The other
+// upstreams connect to their servers through the proxies chosen by prx, which
+// may be nil.  It returns an error if prx chooses a proxy for an upstream, which
+// doesn't support proxies, such as the recursive, DNS-over-QUIC, or DNSCrypt
+// ones.
+func ParseUpstreamsConfig(
+	upstreams []string,
+	opts *upstream.Options,
+	rec *recursor.Resolver,
+	prx *upsproxy.Proxies,
+) (uc *proxy.UpstreamConfig, err error) {
+	lines := make([]string, 0, len(upstreams))
+	hasRecursive := false
+	for _, l := range upstreams {
+		var replaced bool
+		l, replaced = replaceRecursive(l)
+		hasRecursive = hasRecursive || replaced
+		lines = append(lines, l)
+	}
+
+	uc, err = proxy.ParseUpstreamsConfig(lines, opts)
+	if err != nil || (!hasRecursive && prx == nil) {
+		// Don't wrap the error, since it's informative enough as is.
+		return uc, err
+	}
+
+	// The same upstream may be used for several domains, so replace each one
+	// only once.
+	replaced := map[upstream.Upstream]upstream.Upstream{}
+	var errs []error
+	replace := func(ups []upstream.Upstream) {
+		for i, u := range ups {
+			if r, ok := replaced[u]; ok {
+				ups[i] = r
+
+				continue
+			}
+
+			r, rErr := replacement(u, opts, rec, prx)
+			if rErr != nil {
+				errs = append(errs, rErr)
+
+				continue
+			} else if r != u {
+				errs = append(errs, u.Close())
+			}
+
+			replaced[u], ups[i] = r, r
+		}
+	}
+
+	replace(uc.Upstreams)
+	for _, ups := range uc.DomainReservedUpstreams {
+		replace(ups)
+	}
+
+	for _, ups := range uc.SpecifiedDomainUpstreams {
+		replace(ups)
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return uc, fmt.Errorf("replacing upstreams: %w", err)
+	}
+
+	return uc, nil
+}
+
+// replacement returns the upstream replacing u: the recursive one, if u is the
+// placeholder, or the one connecting through the proxy chosen by prx.  r is u
+// if it doesn't need to be replaced.  The recursive upstreams can't be proxied,
+// so an error is returned if prx chooses a proxy for them.
+func replacement(
+	u upstream.Upstream,
+	opts *upstream.Options,
+	rec *recursor.Resolver,
+	prx *upsproxy.Proxies,
+) (r upstream.Upstream, err error) {
+	if !isRecursivePlaceholder(u) {
+		return prx.Wrap(u, opts)
+	} else if prx.IsProxied(u) {
+		return nil, errRecursiveProxied
+	}
+
+	return recursor.NewUpstream(rec), nil
+}
+
+// replaceRecursive returns line with the [recursor.UpstreamAddr] addresses
+// replaced with [recursivePlaceholder].  replaced is false if there are none.
+func replaceRecursive(line string) (res string, replaced bool) {
+	if IsCommentOrEmpty(line) {
+		return line, false
+	}
+
+	domains, addrs := "", line
+	if strings.HasPrefix(line, "[/") {
+		if i := strings.Index(line, "]"); i >= 0 {
+			domains, addrs = line[:i+1], line[i+1:]
+		}
+	}
+
+	fields := strings.Fields(addrs)
+	for i, f := range fields {
+		if f == recursor.UpstreamAddr {
+			fields[i] = recursivePlaceholder
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		return line, false
+	}
+
+	return domains + strings.Join(fields, " "), true
+}
+
+// isRecursivePlaceholder returns true if u is the upstream created for
+// [recursivePlaceholder].
+func isRecursivePlaceholder(u upstream.Upstream) (ok bool) {
+	addr := u.Address()
+	if _, after, found := strings.Cut(addr, "://"); found {
+		addr = after
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	return host == recursivePlaceholder
+}
+
 // newUpstreamConfig returns the upstream configuration based on upstreams.  If
 // upstreams slice specifies no default upstreams, defaultUpstreams are used to
 // create upstreams with no domain specifications.  opts are used when creating
-// upstream configuration.
+// upstream configuration.  rec resolves the recursive upstreams, if any, and prx
+// chooses the proxies for the upstreams.
 func newUpstreamConfig(
+	logger *slog.Logger,
 	upstreams []string,
 	defaultUpstreams []string,
 	opts *upstream.Options,
+	rec *recursor.Resolver,
+	prx *upsproxy.Proxies,
 ) (uc *proxy.UpstreamConfig, err error) {
-	uc, err = proxy.ParseUpstreamsConfig(upstreams, opts)
+	uc, err = ParseUpstreamsConfig(upstreams, opts, rec, prx)
 	if err != nil {
 		return uc, fmt.Errorf("parsing upstreams: %w", err)
 	}
 
 	if len(uc.Upstreams) == 0 && len(defaultUpstreams) > 0 {
-		log.Info("dnsforward: warning: no default upstreams specified, using %v", defaultUpstreams)
+		logger.Warn("no default upstreams specified", "using", defaultUpstreams)
 
 		var defaultUpstreamConfig *proxy.UpstreamConfig
-		defaultUpstreamConfig, err = proxy.ParseUpstreamsConfig(defaultUpstreams, opts)
+		defaultUpstreamConfig, err = ParseUpstreamsConfig(defaultUpstreams, opts, nil, prx)
 		if err != nil {
 			return uc, fmt.Errorf("parsing default upstreams: %w", err)
 		}
@@ -86,6 +273,7 @@ func newUpstreamConfig(
 // addresses or from the system resolvers.  unwanted filters the resulting
 // upstream configuration.
 func newPrivateConfig(
+	logger *slog.Logger,
 	addrs []string,
 	unwanted addrPortSet,
 	sysResolvers SystemResolvers,
@@ -103,7 +291,7 @@ func newPrivateConfig(
 		}
 	}
 
-	log.Debug("dnsforward: upstreams to resolve ptr for local addresses: %v", addrs)
+	logger.Debug("upstreams to resolve ptr for local addresses", "addrs", addrs)
 
 	uc, err = proxy.ParseUpstreamsConfig(addrs, opts)
 	if err != nil {
@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/miekg/dns"
@@ -143,10 +145,18 @@ func TestUpstreamConfigValidator(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			cv := newUpstreamConfigValidator(tc.general, tc.fallback, tc.private, &upstream.Options{
-				Timeout:   upsTimeout,
-				Bootstrap: net.DefaultResolver,
-			})
+			cv := newUpstreamConfigValidator(
+				testLogger,
+				tc.general,
+				tc.fallback,
+				tc.private,
+				&upstream.Options{
+					Timeout:   upsTimeout,
+					Bootstrap: net.DefaultResolver,
+				},
+				nil,
+				nil,
+			)
 			cv.check()
 			cv.close()
 
@@ -194,9 +204,9 @@ func TestUpstreamConfigValidator_Check_once(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			cv := newUpstreamConfigValidator(tc.ups, nil, nil, &upstream.Options{
+			cv := newUpstreamConfigValidator(testLogger, tc.ups, nil, nil, &upstream.Options{
 				Timeout: testTimeout,
-			})
+			}, nil, nil)
 
 			go func() {
 				cv.check()
@@ -214,3 +224,108 @@ func TestUpstreamConfigValidator_Check_once(t *testing.T) {
 		})
 	}
 }
+
+func TestParseUpstreamsConfig_recursive(t *testing.T) {
+	uc, err := ParseUpstreamsConfig([]string{
+		"# " + recursor.UpstreamAddr,
+		recursor.UpstreamAddr,
+		"[/example.org/]" + recursor.UpstreamAddr + " 192.0.2.1",
+	}, &upstream.Options{}, nil, nil)
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, uc.Close)
+
+	require.Len(t, uc.Upstreams, 1)
+	assert.IsType(t, &recursor.Upstream{}, uc.Upstreams[0])
+
+	var addrs []string
+	for _, ups := range uc.SpecifiedDomainUpstreams {
+		for _, u := range ups {
+			addrs = append(addrs, u.Address())
+		}
+	}
+
+	require.Len(t, addrs, 2)
+	assert.Contains(t, addrs, recursor.UpstreamAddr)
+}
+
+func TestParseUpstreamsConfig_proxied(t *testing.T) {
+	prx, err := upsproxy.New(&upsproxy.Config{
+		Upstreams: map[string]string{
+			"quic://192.0.2.2": "",
+		},
+		Global: "socks5://127.0.0.1:1080",
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		wantErr error
+		name    string
+		ups     []string
+	}{{
+		wantErr: nil,
+		name:    "supported",
+		ups:     []string{"192.0.2.1", "tls://192.0.2.1", "https://192.0.2.1/dns-query"},
+	}, {
+		wantErr: nil,
+		name:    "direct",
+		ups:     []string{"quic://192.0.2.2"},
+	}, {
+		wantErr: upsproxy.ErrUnsupportedScheme,
+		name:    "quic",
+		ups:     []string{"quic://192.0.2.1"},
+	}, {
+		wantErr: errRecursiveProxied,
+		name:    "recursive",
+		ups:     []string{"[/example.org/]" + recursor.UpstreamAddr},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uc, pErr := ParseUpstreamsConfig(tc.ups, &upstream.Options{}, nil, prx)
+			testutil.CleanupAndRequireSuccess(t, uc.Close)
+
+			if tc.wantErr == nil {
+				assert.NoError(t, pErr)
+			} else {
+				assert.ErrorIs(t, pErr, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestReplaceRecursive(t *testing.T) {
+	testCases := []struct {
+		name         string
+		line         string
+		want         string
+		wantReplaced bool
+	}{{
+		name:         "plain",
+		line:         "recursive",
+		want:         recursivePlaceholder,
+		wantReplaced: true,
+	}, {
+		name:         "domains",
+		line:         "[/example.org/local/]recursive tls://dns.example",
+		want:         "[/example.org/local/]" + recursivePlaceholder + " tls://dns.example",
+		wantReplaced: true,
+	}, {
+		name:         "comment",
+		line:         "# recursive",
+		want:         "# recursive",
+		wantReplaced: false,
+	}, {
+		name:         "other",
+		line:         "[/recursive/]udp://recursive.example",
+		want:         "[/recursive/]udp://recursive.example",
+		wantReplaced: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, replaced := replaceRecursive(tc.line)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantReplaced, replaced)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package filtering
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"slices"
 	"time"
@@ -10,7 +11,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/urlfilter/rules"
 )
 
@@ -20,8 +21,9 @@ var serviceRules map[string][]*rules.NetworkRule
 // serviceIDs contains service IDs sorted alphabetically.
 var serviceIDs []string
 
-// initBlockedServices initializes package-level blocked service data.
-func initBlockedServices() {
+// initBlockedServices initializes package-level blocked service data.  logger
+// is used to log the invalid rules.
+func initBlockedServices(logger *slog.Logger) {
 	l := len(blockedServices)
 	serviceIDs = make([]string, l)
 	serviceRules = make(map[string][]*rules.NetworkRule, l)
@@ -31,7 +33,12 @@ func initBlockedServices() {
 		for _, text := range s.Rules {
 			rule, err := rules.NewNetworkRule(text, rulelist.URLFilterIDBlockedService)
 			if err != nil {
-				log.Error("parsing blocked service %q rule %q: %s", s.ID, text, err)
+				logger.Error(
+					"parsing blocked service rule",
+					"service", s.ID,
+					"rule", text,
+					slogutil.KeyError, err,
+				)
 
 				continue
 			}
@@ -45,7 +52,7 @@ func initBlockedServices() {
 
 	slices.Sort(serviceIDs)
 
-	log.Debug("filtering: initialized %d services", l)
+	logger.Debug("initialized services", "count", l)
 }
 
 // BlockedServices is the configuration of blocked services.
@@ -102,7 +109,7 @@ func (d *DNSFilter) ApplyBlockedServicesList(setts *Settings, list []string) {
 	for _, name := range list {
 		rules, ok := serviceRules[name]
 		if !ok {
-			log.Error("unknown service name: %s", name)
+			d.logger.Error("unknown service", "name", name)
 
 			continue
 		}
@@ -160,7 +167,7 @@ func (d *DNSFilter) handleBlockedServicesSet(w http.ResponseWriter, r *http.Requ
 		defer d.confMu.Unlock()
 
 		d.conf.BlockedServices.IDs = list
-		log.Debug("Updated blocked services list: %d", len(list))
+		d.logger.Debug("updated blocked services list", "count", len(list))
 	}()
 
 	d.conf.ConfigModified()
@@ -209,7 +216,7 @@ func (d *DNSFilter) handleBlockedServicesUpdate(w http.ResponseWriter, r *http.R
 		d.conf.BlockedServices = bsvc
 	}()
 
-	log.Debug("updated blocked services schedule: %d", len(bsvc.IDs))
+	d.logger.Debug("updated blocked services schedule", "count", len(bsvc.IDs))
 
 	d.conf.ConfigModified()
 }
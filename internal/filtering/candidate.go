@@ -0,0 +1,198 @@
+package filtering
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// CandidateConfig is the configuration of a candidate filter, which extends the
+// current filtering configuration.
+type CandidateConfig struct {
+	// URLs are the URLs or the absolute paths of the additional blocklists.
+	URLs []string
+
+	// UserRules are the additional custom filtering rules.
+	UserRules []string
+
+	// BlockedServices are the IDs of the additional blocked services.
+	BlockedServices []string
+
+	// DisabledFilters are the IDs of the currently enabled blocklists, which
+	// are disabled in the candidate.
+	DisabledFilters []rulelist.URLFilterID
+}
+
+// Candidate checks the hosts against both the current and the candidate
+// filtering rules and blocked services.  The current configuration isn't
+// changed.
+type Candidate struct {
+	// cur is the current filter.
+	cur *DNSFilter
+
+	// filter only contains the candidate filtering engines.
+	filter *DNSFilter
+
+	// services are the rules of the additional blocked services.
+	services []ServiceEntry
+}
+
+// NewCandidate returns a new candidate filter based on the current
+// configuration of d.  The additional blocklists are downloaded.  The returned
+// candidate must be closed after use.  c must not be nil.
+func (d *DNSFilter) NewCandidate(c *CandidateConfig) (cand *Candidate, err error) {
+	defer func() { err = errors.Annotate(err, "filtering: candidate: %w") }()
+
+	svcs := &Settings{}
+	for _, id := range c.BlockedServices {
+		if _, ok := serviceRules[id]; !ok {
+			return nil, fmt.Errorf("unknown blocked service %q", id)
+		}
+	}
+
+	d.ApplyBlockedServicesList(svcs, c.BlockedServices)
+
+	blockFilters, allowFilters := d.candidateFilters(c)
+	for _, u := range c.URLs {
+		var flt Filter
+		flt, err = d.downloadCandidate(u)
+		if err != nil {
+			// Don't wrap the error, since it's informative enough as is.
+			return nil, err
+		}
+
+		blockFilters = append(blockFilters, flt)
+	}
+
+	filter := &DNSFilter{
+		logger:  d.logger,
+		bufPool: d.bufPool,
+		confMu:  &sync.RWMutex{},
+		conf:    &Config{},
+	}
+
+	err = filter.initFiltering(allowFilters, blockFilters)
+	if err != nil {
+		return nil, fmt.Errorf("initializing: %w", err)
+	}
+
+	return &Candidate{
+		cur:      d,
+		filter:   filter,
+		services: svcs.ServicesRules,
+	}, nil
+}
+
+// candidateFilters returns the current lists with the changes from c applied,
+// excluding the additional blocklists.
+func (d *DNSFilter) candidateFilters(c *CandidateConfig) (blockFilters, allowFilters []Filter) {
+	d.conf.filtersMu.RLock()
+	defer d.conf.filtersMu.RUnlock()
+
+	userRules := slices.Concat(d.conf.UserRules, c.UserRules)
+	blockFilters = []Filter{{
+		ID:   rulelist.URLFilterIDCustom,
+		Data: []byte(strings.Join(userRules, "\n")),
+	}}
+
+	for _, flt := range d.conf.Filters {
+		if !flt.Enabled || slices.Contains(c.DisabledFilters, flt.ID) {
+			continue
+		}
+
+		blockFilters = append(blockFilters, Filter{
+			ID:       flt.ID,
+			FilePath: flt.Path(d.conf.DataDir),
+		})
+	}
+
+	for _, flt := range d.conf.WhitelistFilters {
+		if !flt.Enabled {
+			continue
+		}
+
+		allowFilters = append(allowFilters, Filter{
+			ID:       flt.ID,
+			FilePath: flt.Path(d.conf.DataDir),
+		})
+	}
+
+	return blockFilters, allowFilters
+}
+
+// downloadCandidate downloads and parses the blocklist from u into memory.
+func (d *DNSFilter) downloadCandidate(u string) (flt Filter, err error) {
+	err = validateFilterURL(u)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return Filter{}, err
+	}
+
+	r, err := d.reader(u)
+	if err != nil {
+		return Filter{}, fmt.Errorf("list %q: %w", u, err)
+	}
+	defer func() { err = errors.WithDeferred(err, r.Close()) }()
+
+	bufPtr := d.bufPool.Get()
+	defer d.bufPool.Put(bufPtr)
+
+	data := &bytes.Buffer{}
+	_, err = rulelist.NewParser().Parse(data, r, *bufPtr)
+	if err != nil {
+		return Filter{}, fmt.Errorf("list %q: %w", u, err)
+	}
+
+	return Filter{
+		ID:   d.idGen.next(),
+		Data: data.Bytes(),
+	}, nil
+}
+
+// Check returns the results of checking host against the current and the
+// candidate filtering rules and blocked services.  setts are the current
+// settings of the client and aren't modified.  The rewrites, the system hosts
+// file, and the network-based checks are skipped.
+func (c *Candidate) Check(
+	host string,
+	qtype uint16,
+	setts *Settings,
+) (cur, cand Result, err error) {
+	host = strings.ToLower(host)
+
+	cur, err = c.cur.checkRules(host, qtype, setts)
+	if err != nil {
+		return Result{}, Result{}, fmt.Errorf("current: %w", err)
+	}
+
+	candSetts := *setts
+	candSetts.ServicesRules = slices.Concat(setts.ServicesRules, c.services)
+
+	cand, err = c.filter.checkRules(host, qtype, &candSetts)
+	if err != nil {
+		return Result{}, Result{}, fmt.Errorf("candidate: %w", err)
+	}
+
+	return cur, cand, nil
+}
+
+// Close releases the resources of the candidate filter.
+func (c *Candidate) Close() {
+	c.filter.Close()
+}
+
+// checkRules checks host against the filtering rules and the blocked services
+// only.
+func (d *DNSFilter) checkRules(host string, qtype uint16, setts *Settings) (res Result, err error) {
+	res, err = d.matchHost(host, qtype, setts)
+	if err != nil || res.Reason.Matched() {
+		return res, err
+	}
+
+	return d.matchBlockedServicesRules(host, qtype, setts)
+}
@@ -0,0 +1,136 @@
+package filtering
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSFilter_NewCandidate(t *testing.T) {
+	userRules := []string{
+		"||blocked.example^",
+		"||unblocked.example^",
+	}
+
+	d, setts := newForTest(t, &Config{
+		DataDir:   t.TempDir(),
+		UserRules: userRules,
+	}, []Filter{{
+		ID:   rulelist.URLFilterIDCustom,
+		Data: []byte(strings.Join(userRules, "\n")),
+	}})
+	t.Cleanup(d.Close)
+
+	listPath := filepath.Join(t.TempDir(), "list.txt")
+	err := os.WriteFile(listPath, []byte("||listed.example^\n"), 0o644)
+	require.NoError(t, err)
+
+	cand, err := d.NewCandidate(&CandidateConfig{
+		URLs: []string{listPath},
+		UserRules: []string{
+			"||new.example^",
+			"@@||unblocked.example^",
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(cand.Close)
+
+	testCases := []struct {
+		host        string
+		wantCur     bool
+		wantCand    bool
+		wantCandTxt string
+	}{{
+		host:        "blocked.example",
+		wantCur:     true,
+		wantCand:    true,
+		wantCandTxt: "||blocked.example^",
+	}, {
+		host:        "unblocked.example",
+		wantCur:     true,
+		wantCand:    false,
+		wantCandTxt: "@@||unblocked.example^",
+	}, {
+		host:        "new.example",
+		wantCur:     false,
+		wantCand:    true,
+		wantCandTxt: "||new.example^",
+	}, {
+		host:        "listed.example",
+		wantCur:     false,
+		wantCand:    true,
+		wantCandTxt: "||listed.example^",
+	}, {
+		host:        "other.example",
+		wantCur:     false,
+		wantCand:    false,
+		wantCandTxt: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			cur, next, checkErr := cand.Check(tc.host, dns.TypeA, setts)
+			require.NoError(t, checkErr)
+
+			assert.Equal(t, tc.wantCur, cur.IsFiltered)
+			assert.Equal(t, tc.wantCand, next.IsFiltered)
+
+			if tc.wantCandTxt == "" {
+				assert.Empty(t, next.Rules)
+
+				return
+			}
+
+			require.Len(t, next.Rules, 1)
+
+			assert.Equal(t, tc.wantCandTxt, next.Rules[0].Text)
+		})
+	}
+
+	t.Run("current_unchanged", func(t *testing.T) {
+		res, checkErr := d.CheckHost("new.example", dns.TypeA, setts)
+		require.NoError(t, checkErr)
+
+		assert.False(t, res.IsFiltered)
+	})
+}
+
+func TestDNSFilter_NewCandidate_errors(t *testing.T) {
+	d, _ := newForTest(t, &Config{
+		DataDir: t.TempDir(),
+	}, nil)
+	t.Cleanup(d.Close)
+
+	testCases := []struct {
+		conf       *CandidateConfig
+		name       string
+		wantErrMsg string
+	}{{
+		conf: &CandidateConfig{
+			BlockedServices: []string{"nonexistent"},
+		},
+		name:       "bad_service",
+		wantErrMsg: `filtering: candidate: unknown blocked service "nonexistent"`,
+	}, {
+		conf: &CandidateConfig{
+			URLs: []string{"ftp://example.com/list.txt"},
+		},
+		name: "bad_url",
+		wantErrMsg: `filtering: candidate: checking filter: Check scheme ` +
+			`"ftp://example.com/list.txt": only [http https] allowed`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := d.NewCandidate(tc.conf)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Package catalog implements the catalog of the vetted filtering rule lists
+// based on the Hostlists Registry.
+package catalog
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+)
+
+// DefaultURL is the default URL of the catalog.
+const DefaultURL = "https://adguardteam.github.io/HostlistsRegistry/assets/filters.json"
+
+// Category IDs.
+const (
+	CategoryGeneral  = "general"
+	CategoryOther    = "other"
+	CategoryRegional = "regional"
+	CategorySecurity = "security"
+)
+
+// categories are the IDs of all known categories in the order of appearance
+// in the web UI.
+var categories = []string{
+	CategoryGeneral,
+	CategoryRegional,
+	CategorySecurity,
+	CategoryOther,
+}
+
+// Filter is a filtering rule list from the catalog.
+type Filter struct {
+	// ID is the identifier of the list in the catalog, which stays the same
+	// even if the list is renamed or moved.
+	ID string
+
+	// Name is the human-readable name of the list.
+	Name string
+
+	// Description is the description of the list.  It may be empty.
+	Description string
+
+	// Homepage is the URL of the homepage of the list.  It may be empty.
+	Homepage string
+
+	// License is the URL or the name of the license of the list.  It may be
+	// empty.
+	License string
+
+	// CategoryID is the ID of the category of the list.  It's empty if the
+	// list doesn't fit any of the known categories.
+	CategoryID string
+
+	// SourceURL is the current URL of the contents of the list.
+	SourceURL string
+
+	// RulesCount is the number of rules in the list.  It's zero if unknown.
+	RulesCount int
+
+	// UpdateIvl is the expected interval between the updates of the list.
+	// It's zero if unknown.
+	UpdateIvl time.Duration
+}
+
+// Config is the configuration of the catalog.
+type Config struct {
+	// Logger is used for logging the operation of the catalog.  If nil,
+	// [slog.Default] is used.
+	Logger *slog.Logger
+
+	// HTTPClient is the client used to download the catalog.  It must not be
+	// nil.
+	HTTPClient *http.Client
+
+	// HTTPRegister registers the HTTP handlers.  It may be nil.
+	HTTPRegister aghhttp.RegisterFunc
+
+	// URL is the URL of the catalog in the Hostlists Registry format.  It must
+	// be an HTTP or HTTPS URL.
+	URL string
+
+	// CachePath is the path to the file in which the downloaded catalog is
+	// cached between restarts.  If empty, the catalog isn't cached.
+	CachePath string
+}
+
+// Catalog is the catalog of the vetted filtering rule lists, which is
+// periodically downloaded and cached on disk.
+type Catalog struct {
+	// logger is used for logging the operation of the catalog.
+	logger *slog.Logger
+
+	// idx is the current index of the lists.  It's never nil.
+	idx atomic.Pointer[index]
+
+	// done is closed when the catalog is closed.
+	done chan struct{}
+
+	// now returns the current time.  It's a field to be replaced in tests.
+	now func() (t time.Time)
+
+	// httpClient is the client used to download the catalog.
+	httpClient *http.Client
+
+	// url is the URL of the catalog.
+	url *url.URL
+
+	// mu protects updated.
+	mu *sync.Mutex
+
+	// updated is the time of the last successful update of the catalog.
+	updated time.Time
+
+	// cachePath is the path to the file with the cached catalog.  If empty,
+	// the catalog isn't cached.
+	cachePath string
+}
+
+// New returns a new properly initialized *Catalog.  The previously cached
+// catalog, if any, is loaded immediately.  conf must not be nil.
+func New(conf *Config) (c *Catalog, err error) {
+	u, err := url.Parse(conf.URL)
+	if err != nil {
+		return nil, fmt.Errorf("catalog: url: %w", err)
+	} else if u.Scheme != aghhttp.SchemeHTTP && u.Scheme != aghhttp.SchemeHTTPS {
+		return nil, fmt.Errorf("catalog: url: bad scheme %q", u.Scheme)
+	}
+
+	logger := conf.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	c = &Catalog{
+		logger:     logger,
+		done:       make(chan struct{}),
+		now:        time.Now,
+		httpClient: conf.HTTPClient,
+		url:        u,
+		mu:         &sync.Mutex{},
+		cachePath:  conf.CachePath,
+	}
+
+	c.idx.Store(&index{})
+	c.loadCache()
+
+	if conf.HTTPRegister != nil {
+		c.registerHTTPHandlers(conf.HTTPRegister)
+	}
+
+	return c, nil
+}
+
+// Start starts the periodic updates of the catalog.  It must only be called
+// once.
+func (c *Catalog) Start() {
+	go c.updateLoop()
+}
+
+// Close stops the periodic updates of the catalog.  It must only be called
+// once.
+func (c *Catalog) Close() {
+	close(c.done)
+}
+
+// SourceURL returns the current URL of the contents of the list with the given
+// catalog id.  ok is false if there is no such list in the catalog.  It's safe
+// for concurrent use.
+func (c *Catalog) SourceURL(id string) (u string, ok bool) {
+	f, ok := c.idx.Load().filters[id]
+	if !ok {
+		return "", false
+	}
+
+	return f.SourceURL, true
+}
+
+// Filters returns the lists from the catalog sorted by their IDs.  The
+// returned filters must not be modified.  It's safe for concurrent use.
+func (c *Catalog) Filters() (filters []*Filter) {
+	return c.idx.Load().sorted
+}
+
+// lastUpdated returns the time of the last successful update of the catalog.
+func (c *Catalog) lastUpdated() (t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.updated
+}
+
+// setIndex replaces the current index with idx updated at t.
+func (c *Catalog) setIndex(idx *index, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.idx.Store(idx)
+	c.updated = t
+
+	c.logger.Debug("set filters", "count", len(idx.sorted), "updated", t)
+}
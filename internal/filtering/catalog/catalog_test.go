@@ -0,0 +1,138 @@
+package catalog_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/catalog"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
+
+// testRegistry is the catalog in the Hostlists Registry format used in tests.
+const testRegistry = `{"filters":[{
+	"filterId": "adguard_dns_filter",
+	"name": "AdGuard DNS filter",
+	"description": "Filter composed of several other filters.",
+	"homepage": "https://github.com/AdguardTeam/AdGuardSDNSFilter",
+	"license": "GPL-3.0",
+	"tags": ["lang:en", "purpose:general"],
+	"expires": 345600,
+	"ruleCount": 42,
+	"downloadUrl": "https://filters.example/filter_1.txt"
+},{
+	"filterId": "old_filter",
+	"name": "Old filter",
+	"deprecated": true,
+	"downloadUrl": "https://filters.example/filter_2.txt"
+},{
+	"filterId": "bad_url",
+	"name": "Bad URL",
+	"downloadUrl": "ftp://filters.example/filter_3.txt"
+},{
+	"filterId": "malware",
+	"name": "Malware domains",
+	"tags": ["purpose:security"],
+	"downloadUrl": "https://filters.example/filter_4.txt"
+}]}`
+
+func TestCatalog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, err := io.WriteString(w, testRegistry)
+		assert.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	conf := &catalog.Config{
+		HTTPClient: srv.Client(),
+		URL:        srv.URL,
+		CachePath:  filepath.Join(t.TempDir(), "filters_catalog.json"),
+	}
+
+	c, err := catalog.New(conf)
+	require.NoError(t, err)
+
+	_, ok := c.SourceURL("adguard_dns_filter")
+	require.False(t, ok)
+
+	c.Start()
+	t.Cleanup(c.Close)
+
+	require.Eventually(t, func() (ok bool) {
+		_, ok = c.SourceURL("adguard_dns_filter")
+
+		return ok
+	}, testTimeout, testTimeout/10)
+
+	want := []*catalog.Filter{{
+		ID:          "adguard_dns_filter",
+		Name:        "AdGuard DNS filter",
+		Description: "Filter composed of several other filters.",
+		Homepage:    "https://github.com/AdguardTeam/AdGuardSDNSFilter",
+		License:     "GPL-3.0",
+		CategoryID:  catalog.CategoryGeneral,
+		SourceURL:   "https://filters.example/filter_1.txt",
+		RulesCount:  42,
+		UpdateIvl:   96 * time.Hour,
+	}, {
+		ID:         "malware",
+		Name:       "Malware domains",
+		CategoryID: catalog.CategorySecurity,
+		SourceURL:  "https://filters.example/filter_4.txt",
+	}}
+	assert.Equal(t, want, c.Filters())
+
+	for _, id := range []string{"old_filter", "bad_url", "unknown"} {
+		_, ok = c.SourceURL(id)
+		assert.Falsef(t, ok, "id %q", id)
+	}
+
+	t.Run("cache", func(t *testing.T) {
+		cached, cErr := catalog.New(conf)
+		require.NoError(t, cErr)
+
+		u, found := cached.SourceURL("malware")
+		require.True(t, found)
+
+		assert.Equal(t, "https://filters.example/filter_4.txt", u)
+		assert.Equal(t, want, cached.Filters())
+	})
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name       string
+		url        string
+		wantErrMsg string
+	}{{
+		name:       "success",
+		url:        catalog.DefaultURL,
+		wantErrMsg: "",
+	}, {
+		name:       "bad_scheme",
+		url:        "ftp://filters.example/filters.json",
+		wantErrMsg: `catalog: url: bad scheme "ftp"`,
+	}, {
+		name:       "no_scheme",
+		url:        "filters.json",
+		wantErrMsg: `catalog: url: bad scheme ""`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := catalog.New(&catalog.Config{
+				HTTPClient: http.DefaultClient,
+				URL:        tc.url,
+			})
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package catalog
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+)
+
+// filterJSON is the JSON representation of a [Filter].
+type filterJSON struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+	License     string `json:"license,omitempty"`
+	CategoryID  string `json:"category_id,omitempty"`
+	SourceURL   string `json:"source"`
+	RulesCount  int    `json:"rules_count,omitempty"`
+
+	// UpdateIvl is the expected interval between the updates of the list in
+	// seconds.  It's zero if unknown.
+	UpdateIvl uint32 `json:"update_interval,omitempty"`
+}
+
+// catalogResp is the response to the GET /control/filtering/catalog HTTP API.
+type catalogResp struct {
+	// Updated is the time of the last update of the catalog in RFC 3339
+	// format.  It's empty if the catalog has never been updated.
+	Updated string `json:"updated,omitempty"`
+
+	// URL is the URL of the catalog.
+	URL string `json:"url"`
+
+	// Categories are the IDs of the categories in the order of appearance.
+	Categories []string `json:"categories"`
+
+	// Filters are the lists from the catalog sorted by their IDs.
+	Filters []*filterJSON `json:"filters"`
+}
+
+// registerHTTPHandlers registers the HTTP API handlers.
+func (c *Catalog) registerHTTPHandlers(reg aghhttp.RegisterFunc) {
+	reg(http.MethodGet, "/control/filtering/catalog", c.handleCatalog)
+}
+
+// handleCatalog is the handler for the GET /control/filtering/catalog HTTP
+// API.
+func (c *Catalog) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	resp := &catalogResp{
+		URL:        c.url.String(),
+		Categories: categories,
+		Filters:    []*filterJSON{},
+	}
+
+	if updated := c.lastUpdated(); !updated.IsZero() {
+		resp.Updated = updated.Format(time.RFC3339)
+	}
+
+	for _, f := range c.Filters() {
+		resp.Filters = append(resp.Filters, &filterJSON{
+			ID:          f.ID,
+			Name:        f.Name,
+			Description: f.Description,
+			Homepage:    f.Homepage,
+			License:     f.License,
+			CategoryID:  f.CategoryID,
+			SourceURL:   f.SourceURL,
+			RulesCount:  f.RulesCount,
+			UpdateIvl:   uint32(f.UpdateIvl / time.Second),
+		})
+	}
+
+	aghhttp.WriteJSONResponseOK(w, r, resp)
+}
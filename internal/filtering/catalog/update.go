@@ -0,0 +1,268 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+	"github.com/google/renameio/v2/maybe"
+)
+
+// updateIvl is the interval between the updates of the catalog.
+const updateIvl = 24 * time.Hour
+
+// failRetryIvl is the interval between the attempts to update the catalog
+// after a failed one.
+const failRetryIvl = 1 * time.Hour
+
+// maxCatalogSize is the maximum size of the downloaded catalog.
+const maxCatalogSize = 4 * 1024 * 1024
+
+// index is an immutable set of the lists from the catalog.
+type index struct {
+	// filters are the lists by their IDs.
+	filters map[string]*Filter
+
+	// sorted are the lists sorted by their IDs.
+	sorted []*Filter
+}
+
+// registryFilters is the JSON structure of the Hostlists Registry rule list
+// index.
+type registryFilters struct {
+	Filters []*registryFilter `json:"filters"`
+}
+
+// registryFilter is the JSON structure of a list in the Hostlists Registry.
+type registryFilter struct {
+	Description string   `json:"description"`
+	DownloadURL string   `json:"downloadUrl"`
+	FilterID    string   `json:"filterId"`
+	Homepage    string   `json:"homepage"`
+	License     string   `json:"license"`
+	Name        string   `json:"name"`
+	Tags        []string `json:"tags"`
+
+	// Expires is the expected interval between the updates of the list in
+	// seconds.
+	Expires uint32 `json:"expires"`
+
+	RuleCount  int  `json:"ruleCount"`
+	Deprecated bool `json:"deprecated"`
+}
+
+// category returns the category of f.  If there is no fitting category, cat is
+// empty.
+func (f *registryFilter) category() (cat string) {
+	for _, t := range f.Tags {
+		cat, _ = strings.CutPrefix(t, "purpose:")
+		if slices.Contains(categories, cat) {
+			return cat
+		}
+	}
+
+	return ""
+}
+
+// parseIndex parses the catalog from data.  The deprecated lists and the lists
+// without an ID or a valid URL are skipped.
+func (c *Catalog) parseIndex(data []byte) (idx *index, err error) {
+	reg := &registryFilters{}
+	err = json.Unmarshal(data, reg)
+	if err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+
+	idx = &index{
+		filters: make(map[string]*Filter, len(reg.Filters)),
+	}
+
+	for i, f := range reg.Filters {
+		err = validateRegistryFilter(f)
+		if err != nil {
+			c.logger.Debug("skipping filter", "idx", i, slogutil.KeyError, err)
+
+			continue
+		} else if _, ok := idx.filters[f.FilterID]; ok {
+			c.logger.Debug("skipping filter with duplicate id", "idx", i, "id", f.FilterID)
+
+			continue
+		}
+
+		flt := &Filter{
+			ID:          f.FilterID,
+			Name:        f.Name,
+			Description: f.Description,
+			Homepage:    f.Homepage,
+			License:     f.License,
+			CategoryID:  f.category(),
+			SourceURL:   f.DownloadURL,
+			RulesCount:  f.RuleCount,
+			UpdateIvl:   time.Duration(f.Expires) * time.Second,
+		}
+
+		idx.filters[flt.ID] = flt
+		idx.sorted = append(idx.sorted, flt)
+	}
+
+	slices.SortFunc(idx.sorted, func(a, b *Filter) (res int) {
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return idx, nil
+}
+
+// validateRegistryFilter returns an error if f can't be used.
+func validateRegistryFilter(f *registryFilter) (err error) {
+	switch {
+	case f == nil:
+		return errors.Error("no filter")
+	case f.Deprecated:
+		return fmt.Errorf("filter %q is deprecated", f.FilterID)
+	case f.FilterID == "":
+		return errors.Error("no filter id")
+	}
+
+	u, err := url.Parse(f.DownloadURL)
+	if err != nil {
+		return fmt.Errorf("filter %q: download url: %w", f.FilterID, err)
+	} else if u.Scheme != aghhttp.SchemeHTTP && u.Scheme != aghhttp.SchemeHTTPS {
+		return fmt.Errorf("filter %q: download url: bad scheme %q", f.FilterID, u.Scheme)
+	}
+
+	return nil
+}
+
+// loadCache loads the catalog cached by a previous update, if any.
+func (c *Catalog) loadCache() {
+	if c.cachePath == "" {
+		return
+	}
+
+	data, err := os.ReadFile(c.cachePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			c.logger.Error("reading cache", slogutil.KeyError, err)
+		}
+
+		return
+	}
+
+	fi, err := os.Stat(c.cachePath)
+	if err != nil {
+		c.logger.Error("reading cache", slogutil.KeyError, err)
+
+		return
+	}
+
+	idx, err := c.parseIndex(data)
+	if err != nil {
+		c.logger.Error("parsing cache", slogutil.KeyError, err)
+
+		return
+	}
+
+	c.setIndex(idx, fi.ModTime())
+}
+
+// updateLoop updates the catalog periodically until it's closed.  It is
+// intended to be used as a goroutine.
+func (c *Catalog) updateLoop() {
+	defer slogutil.RecoverAndLog(context.TODO(), c.logger)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var retryAt time.Time
+	for {
+		retryAt = c.maybeUpdate(retryAt)
+
+		select {
+		case <-ticker.C:
+			// Go on.
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// maybeUpdate updates the catalog if it's outdated and the time of the next
+// attempt, retryAt, has come.  It returns the time of the next attempt, which
+// is [failRetryIvl] later if the update fails.
+func (c *Catalog) maybeUpdate(retryAt time.Time) (nextRetryAt time.Time) {
+	now := c.now()
+	if now.Sub(c.lastUpdated()) < updateIvl || now.Before(retryAt) {
+		return retryAt
+	}
+
+	err := c.update()
+	if err != nil {
+		c.logger.Error("updating", "retry_in", failRetryIvl, slogutil.KeyError, err)
+
+		return now.Add(failRetryIvl)
+	}
+
+	return time.Time{}
+}
+
+// update downloads the catalog, replaces the current one with it, and caches
+// it on disk.
+func (c *Catalog) update() (err error) {
+	data, err := c.download()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	idx, err := c.parseIndex(data)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	c.setIndex(idx, c.now())
+
+	c.logger.Info("updated", "count", len(idx.sorted))
+
+	if c.cachePath == "" {
+		return nil
+	}
+
+	// Don't return the error, since the catalog is updated anyway.
+	err = maybe.WriteFile(c.cachePath, data, 0o644)
+	if err != nil {
+		c.logger.Error("writing cache", slogutil.KeyError, err)
+	}
+
+	return nil
+}
+
+// download downloads the catalog.
+func (c *Catalog) download() (data []byte, err error) {
+	resp, err := c.httpClient.Get(c.url.String())
+	if err != nil {
+		return nil, fmt.Errorf("requesting: %w", err)
+	}
+	defer func() { err = errors.WithDeferred(err, resp.Body.Close()) }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got status code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	data, err = io.ReadAll(io.LimitReader(resp.Body, maxCatalogSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,45 @@
+package catalog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog_maybeUpdate(t *testing.T) {
+	var reqNum atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		reqNum.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := New(&Config{
+		HTTPClient: srv.Client(),
+		URL:        srv.URL,
+	})
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	c.now = func() (t time.Time) { return now }
+
+	retryAt := c.maybeUpdate(time.Time{})
+	assert.Equal(t, start.Add(failRetryIvl), retryAt)
+	assert.Equal(t, int32(1), reqNum.Load())
+
+	now = start.Add(failRetryIvl / 2)
+	retryAt = c.maybeUpdate(retryAt)
+	assert.Equal(t, start.Add(failRetryIvl), retryAt)
+	assert.Equal(t, int32(1), reqNum.Load())
+
+	now = start.Add(failRetryIvl)
+	retryAt = c.maybeUpdate(retryAt)
+	assert.Equal(t, now.Add(failRetryIvl), retryAt)
+	assert.Equal(t, int32(2), reqNum.Load())
+}
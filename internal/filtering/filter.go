@@ -1,9 +1,11 @@
 package filtering
 
 import (
+	"cmp"
 	"fmt"
 	"io"
 	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"slices"
@@ -15,7 +17,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/golibs/container"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 )
 
 // filterDir is the subdirectory of a data directory to store downloaded
@@ -34,6 +36,16 @@ type FilterYAML struct {
 	checksum    uint32    // checksum of the file data
 	white       bool
 
+	// BlockingMode is the blocking mode for the requests blocked by the rules
+	// of this list.  If empty, the global one is used.  It's ignored for the
+	// allowlists.
+	BlockingMode BlockingMode `yaml:"blocking_mode,omitempty"`
+
+	// CatalogID is the ID of the list in the catalog, if the list was added
+	// from it.  If not empty, URL follows the URL from the catalog when the
+	// list is renamed or moved.
+	CatalogID string `yaml:"catalog_id,omitempty"`
+
 	Filter `yaml:",inline"`
 }
 
@@ -103,23 +115,29 @@ func (d *DNSFilter) filterSetProperties(
 	}
 
 	flt := &filters[i]
-	log.Debug(
-		"filtering: set name to %q, url to %s, enabled to %t for filter %s",
-		newList.Name,
-		newList.URL,
-		newList.Enabled,
-		flt.URL,
+	d.logger.Debug(
+		"updating filter",
+		"url", flt.URL,
+		"new_name", newList.Name,
+		"new_url", newList.URL,
+		"new_enabled", newList.Enabled,
 	)
 
-	defer func(oldURL, oldName string, oldEnabled bool, oldUpdated time.Time, oldRulesCount int) {
+	defer func(
+		oldURL, oldName, oldCatalogID string,
+		oldEnabled bool,
+		oldUpdated time.Time,
+		oldRulesCount int,
+	) {
 		if err != nil {
 			flt.URL = oldURL
 			flt.Name = oldName
+			flt.CatalogID = oldCatalogID
 			flt.Enabled = oldEnabled
 			flt.LastUpdated = oldUpdated
 			flt.RulesCount = oldRulesCount
 		}
-	}(flt.URL, flt.Name, flt.Enabled, flt.LastUpdated, flt.RulesCount)
+	}(flt.URL, flt.Name, flt.CatalogID, flt.Enabled, flt.LastUpdated, flt.RulesCount)
 
 	flt.Name = newList.Name
 
@@ -130,7 +148,10 @@ func (d *DNSFilter) filterSetProperties(
 
 		shouldRestart = true
 
+		// The URL set manually takes precedence over the one from the
+		// catalog, so stop following it.
 		flt.URL = newList.URL
+		flt.CatalogID = ""
 		flt.LastUpdated = time.Time{}
 		flt.unload()
 	}
@@ -157,6 +178,53 @@ func (d *DNSFilter) filterSetProperties(
 	return shouldRestart, err
 }
 
+// UnloadedFilters returns the names of the enabled blocklists and allowlists,
+// which haven't been loaded yet, for example because they couldn't be
+// downloaded.  It's safe for concurrent use.
+func (d *DNSFilter) UnloadedFilters() (names []string) {
+	d.conf.filtersMu.RLock()
+	defer d.conf.filtersMu.RUnlock()
+
+	for _, lists := range [][]FilterYAML{d.conf.Filters, d.conf.WhitelistFilters} {
+		for _, f := range lists {
+			if f.Enabled && f.LastUpdated.IsZero() {
+				names = append(names, cmp.Or(f.Name, f.URL))
+			}
+		}
+	}
+
+	return names
+}
+
+// FilterBlockingMode returns the blocking mode of the blocklist with the given
+// id.  mode is empty if the list has no own blocking mode or there is no such
+// list.  It's safe for concurrent use.
+func (d *DNSFilter) FilterBlockingMode(id rulelist.URLFilterID) (mode BlockingMode) {
+	d.conf.filtersMu.RLock()
+	defer d.conf.filtersMu.RUnlock()
+
+	i := slices.IndexFunc(d.conf.Filters, func(flt FilterYAML) (ok bool) { return flt.ID == id })
+	if i == -1 {
+		return ""
+	}
+
+	return d.conf.Filters[i].BlockingMode
+}
+
+// validateBlockingModes returns an error if any of the blocklists has an invalid
+// blocking mode.  bIPv4 and bIPv6 are the global blocking IP addresses.
+func validateBlockingModes(filters []FilterYAML, bIPv4, bIPv6 netip.Addr) (err error) {
+	var errs []error
+	for i, flt := range filters {
+		err = ValidateBlockingModeOverride(flt.BlockingMode, bIPv4, bIPv6)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("filter at index %d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // filterExists returns true if a filter with the same url exists in d.  It's
 // safe for concurrent use.
 func (d *DNSFilter) filterExists(url string) (ok bool) {
@@ -216,7 +284,7 @@ func (d *DNSFilter) loadFilters(array []FilterYAML) {
 		filter := &array[i] // otherwise we're operating on a copy
 		if filter.ID == 0 {
 			newID := d.idGen.next()
-			log.Info("filtering: warning: filter at index %d has no id; assigning to %d", i, newID)
+			d.logger.Warn("filter has no id; assigning new one", "idx", i, "id", newID)
 
 			filter.ID = newID
 		}
@@ -228,7 +296,7 @@ func (d *DNSFilter) loadFilters(array []FilterYAML) {
 
 		err := d.load(filter)
 		if err != nil {
-			log.Error("filtering: loading filter %d: %s", filter.ID, err)
+			d.logger.Error("loading filter", "id", filter.ID, slogutil.KeyError, err)
 		}
 	}
 }
@@ -313,7 +381,7 @@ func (d *DNSFilter) refreshFiltersArray(filters *[]FilterYAML, force bool) (int,
 		updateFlags = append(updateFlags, updated)
 		if err != nil {
 			failNum++
-			log.Error("filtering: updating filter from url %q: %s\n", uf.URL, err)
+			d.logger.Error("updating filter", "url", uf.URL, slogutil.KeyError, err)
 
 			continue
 		}
@@ -343,11 +411,11 @@ func (d *DNSFilter) refreshFiltersArray(filters *[]FilterYAML, force bool) (int,
 				continue
 			}
 
-			log.Info(
-				"filtering: updated filter %d; rule count: %d (was %d)",
-				f.ID,
-				uf.RulesCount,
-				f.RulesCount,
+			d.logger.Info(
+				"updated filter",
+				"id", f.ID,
+				"rules", uf.RulesCount,
+				"prev_rules", f.RulesCount,
 			)
 
 			f.Name = uf.Name
@@ -360,6 +428,62 @@ func (d *DNSFilter) refreshFiltersArray(filters *[]FilterYAML, force bool) (int,
 	return updateCount, updateFilters, updateFlags, false
 }
 
+// followCatalog sets the URLs of the lists subscribed by their catalog IDs to
+// the current ones from the catalog, so that the renamed and moved lists are
+// downloaded from the new locations.
+func (d *DNSFilter) followCatalog() {
+	if d.conf.Catalog == nil {
+		return
+	}
+
+	moved := false
+	func() {
+		d.conf.filtersMu.Lock()
+		defer d.conf.filtersMu.Unlock()
+
+		for _, filters := range [][]FilterYAML{d.conf.Filters, d.conf.WhitelistFilters} {
+			for i := range filters {
+				moved = d.followCatalogLocked(&filters[i]) || moved
+			}
+		}
+	}()
+
+	if moved {
+		d.conf.ConfigModified()
+	}
+}
+
+// followCatalogLocked sets the URL of flt to the one from the catalog, if flt
+// is subscribed by its catalog ID and the list has been moved.  d.filtersMu is
+// expected to be locked.
+func (d *DNSFilter) followCatalogLocked(flt *FilterYAML) (moved bool) {
+	if flt.CatalogID == "" {
+		return false
+	}
+
+	u, ok := d.conf.Catalog.SourceURL(flt.CatalogID)
+	if !ok || u == flt.URL {
+		return false
+	}
+
+	if d.filterExistsLocked(u) {
+		d.logger.Info(
+			"catalog url is already used by another filter",
+			"id", flt.ID,
+			"url", u,
+		)
+
+		return false
+	}
+
+	d.logger.Info("following catalog url", "id", flt.ID, "from", flt.URL, "to", u)
+
+	flt.URL = u
+	flt.LastUpdated = time.Time{}
+
+	return true
+}
+
 // refreshFiltersIntl checks filters and updates them if necessary.  If force is
 // true, it ignores the filter.LastUpdated field value.
 //
@@ -379,9 +503,11 @@ func (d *DNSFilter) refreshFiltersArray(filters *[]FilterYAML, force bool) (int,
 //
 // TODO(a.garipov, e.burkov): What the hell?
 func (d *DNSFilter) refreshFiltersIntl(block, allow, force bool) (int, bool) {
+	d.followCatalog()
+
 	updNum := 0
-	log.Debug("filtering: starting updating")
-	defer func() { log.Debug("filtering: finished updating, %d updated", updNum) }()
+	d.logger.Debug("starting updating")
+	defer func() { d.logger.Debug("finished updating", "updated", updNum) }()
 
 	var lists []FilterYAML
 	var toUpd []bool
@@ -415,7 +541,7 @@ func (d *DNSFilter) refreshFiltersIntl(block, allow, force bool) (int, bool) {
 			p := uf.Path(d.conf.DataDir)
 			err := os.Remove(p + ".old")
 			if err != nil {
-				log.Debug("filtering: removing old filter file %q: %s", p, err)
+				d.logger.Debug("removing old filter file", "file", p, slogutil.KeyError, err)
 			}
 		}
 	}
@@ -434,7 +560,7 @@ func (d *DNSFilter) update(filter *FilterYAML) (b bool, err error) {
 			filter.LastUpdated,
 		)
 		if chErr != nil {
-			log.Error("filtering: os.Chtimes(): %s", chErr)
+			d.logger.Error("changing filter file times", slogutil.KeyError, chErr)
 		}
 	}
 
@@ -444,7 +570,7 @@ func (d *DNSFilter) update(filter *FilterYAML) (b bool, err error) {
 // updateIntl updates the flt rewriting it's actual file.  It returns true if
 // the actual update has been performed.
 func (d *DNSFilter) updateIntl(flt *FilterYAML) (ok bool, err error) {
-	log.Debug("filtering: downloading update for filter %d from %q", flt.ID, flt.URL)
+	d.logger.Debug("downloading filter update", "id", flt.ID, "url", flt.URL)
 
 	var res *rulelist.ParseResult
 
@@ -487,13 +613,13 @@ func (d *DNSFilter) finalizeUpdate(
 	id := flt.ID
 	if !updated {
 		if returned == nil {
-			log.Debug("filtering: filter %d from url %q has no changes, skipping", id, flt.URL)
+			d.logger.Debug("filter has no changes, skipping", "id", id, "url", flt.URL)
 		}
 
 		return errors.WithDeferred(returned, file.Cleanup())
 	}
 
-	log.Info("filtering: saving contents of filter %d into %q", id, flt.Path(d.conf.DataDir))
+	d.logger.Info("saving filter contents", "id", id, "file", flt.Path(d.conf.DataDir))
 
 	err = file.CloseReplace()
 	if err != nil {
@@ -501,7 +627,7 @@ func (d *DNSFilter) finalizeUpdate(
 	}
 
 	rulesCount := res.RulesCount
-	log.Info("filtering: updated filter %d: %d bytes, %d rules", id, res.BytesWritten, rulesCount)
+	d.logger.Info("updated filter", "id", id, "bytes", res.BytesWritten, "rules", rulesCount)
 
 	flt.ensureName(res.Title)
 	flt.checksum = res.Checksum
@@ -550,7 +676,7 @@ func (d *DNSFilter) readerFromURL(fltURL string) (r io.ReadCloser, err error) {
 func (d *DNSFilter) load(flt *FilterYAML) (err error) {
 	fileName := flt.Path(d.conf.DataDir)
 
-	log.Debug("filtering: loading filter %d from %q", flt.ID, fileName)
+	d.logger.Debug("loading filter", "id", flt.ID, "file", fileName)
 
 	file, err := os.Open(fileName)
 	if errors.Is(err, os.ErrNotExist) {
@@ -566,7 +692,7 @@ func (d *DNSFilter) load(flt *FilterYAML) (err error) {
 		return fmt.Errorf("getting filter file stat: %w", err)
 	}
 
-	log.Debug("filtering: file %q, id %d, length %d", fileName, flt.ID, st.Size())
+	d.logger.Debug("opened filter file", "id", flt.ID, "file", fileName, "size", st.Size())
 
 	bufPtr := d.bufPool.Get()
 	defer d.bufPool.Put(bufPtr)
@@ -622,7 +748,7 @@ func (d *DNSFilter) enableFiltersLocked(async bool) {
 
 	err := d.setFilters(filters, allowFilters, async)
 	if err != nil {
-		log.Error("filtering: enabling filters: %s", err)
+		d.logger.Error("enabling filters", slogutil.KeyError, err)
 	}
 
 	d.SetEnabled(d.conf.FilteringEnabled)
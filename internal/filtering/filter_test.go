@@ -173,3 +173,81 @@ func TestFilterYAML_EnsureName(t *testing.T) {
 		assert.Equal(t, "List 0", f.Name)
 	})
 }
+
+// testCatalog is a [Catalog] for tests.
+type testCatalog map[string]string
+
+// type check
+var _ Catalog = testCatalog(nil)
+
+// SourceURL implements the [Catalog] interface for testCatalog.
+func (c testCatalog) SourceURL(id string) (u string, ok bool) {
+	u, ok = c[id]
+
+	return u, ok
+}
+
+func TestDNSFilter_FollowCatalog(t *testing.T) {
+	const (
+		movedURL   = "https://filters.example/moved.txt"
+		takenURL   = "https://filters.example/taken.txt"
+		oldURL     = "https://filters.example/old.txt"
+		otherURL   = "https://filters.example/other.txt"
+		allowedURL = "https://filters.example/allowed.txt"
+	)
+
+	modified := 0
+	dnsFilter, err := New(&Config{
+		DataDir: t.TempDir(),
+		Catalog: testCatalog{
+			"moved":   movedURL,
+			"taken":   takenURL,
+			"allowed": "https://filters.example/allowed_new.txt",
+		},
+		ConfigModified: func() { modified++ },
+		Filters: []FilterYAML{{
+			URL:         oldURL,
+			LastUpdated: time.Now(),
+			CatalogID:   "moved",
+			Filter:      Filter{ID: 1},
+		}, {
+			URL:       otherURL,
+			CatalogID: "taken",
+			Filter:    Filter{ID: 2},
+		}, {
+			URL:    takenURL,
+			Filter: Filter{ID: 3},
+		}, {
+			URL:       "https://filters.example/unknown.txt",
+			CatalogID: "unknown",
+			Filter:    Filter{ID: 4},
+		}},
+		WhitelistFilters: []FilterYAML{{
+			URL:       allowedURL,
+			CatalogID: "allowed",
+			Filter:    Filter{ID: 5},
+		}},
+	}, nil)
+	require.NoError(t, err)
+
+	dnsFilter.followCatalog()
+
+	assert.Equal(t, 1, modified)
+
+	flts := dnsFilter.conf.Filters
+	assert.Equal(t, movedURL, flts[0].URL)
+	assert.True(t, flts[0].LastUpdated.IsZero())
+
+	assert.Equal(t, otherURL, flts[1].URL)
+	assert.Equal(t, takenURL, flts[2].URL)
+	assert.Equal(t, "https://filters.example/unknown.txt", flts[3].URL)
+
+	allowlists := dnsFilter.conf.WhitelistFilters
+	assert.Equal(t, "https://filters.example/allowed_new.txt", allowlists[0].URL)
+
+	t.Run("no_changes", func(t *testing.T) {
+		dnsFilter.followCatalog()
+
+		assert.Equal(t, 1, modified)
+	})
+}
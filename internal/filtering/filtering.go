@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/netip"
@@ -20,10 +21,11 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/golibs/container"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/hostsfile"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/mathutil"
 	"github.com/AdguardTeam/golibs/syncutil"
 	"github.com/AdguardTeam/urlfilter"
@@ -54,6 +56,22 @@ type Settings struct {
 
 	// ClientSafeSearch is a client configured safe search.
 	ClientSafeSearch SafeSearch
+
+	// GeoIPPolicy is the client's own policy of blocking the answers by the
+	// countries and the autonomous systems of their addresses.  If nil, the
+	// global one is used.
+	GeoIPPolicy *geoip.Policy
+
+	// UseDNS64 defines if DNS64 is enabled for the client.
+	UseDNS64 bool
+
+	// IgnoreAnomalyDetection defines if the requests of the client are exempt
+	// from the anomaly detection.
+	IgnoreAnomalyDetection bool
+
+	// BlockingMode is the blocking mode of the client or of one of its tags.
+	// If empty, the one of the filter list or the global one is used.
+	BlockingMode BlockingMode
 }
 
 // Resolver is the interface for net.Resolver to simplify testing.
@@ -61,8 +79,20 @@ type Resolver interface {
 	LookupIP(ctx context.Context, network, host string) (ips []net.IP, err error)
 }
 
+// Catalog is the catalog of the filtering rule lists which allows following
+// the lists that were renamed or moved.
+type Catalog interface {
+	// SourceURL returns the current URL of the contents of the list with the
+	// given catalog id.  ok is false if there is no such list in the catalog.
+	SourceURL(id string) (u string, ok bool)
+}
+
 // Config allows you to configure DNS filtering with New() or just change variables directly.
 type Config struct {
+	// Logger is used for logging the operation of the filtering.  If nil,
+	// [slog.Default] is used.
+	Logger *slog.Logger `yaml:"-"`
+
 	// BlockingIPv4 is the IP address to be returned for a blocked A request.
 	BlockingIPv4 netip.Addr `yaml:"blocking_ipv4"`
 
@@ -96,6 +126,10 @@ type Config struct {
 	// HTTPClient is the client to use for updating the remote filters.
 	HTTPClient *http.Client `yaml:"-"`
 
+	// Catalog is used to resolve the URLs of the lists subscribed by their
+	// catalog IDs.  It may be nil.
+	Catalog Catalog `yaml:"-"`
+
 	// filtersMu protects filter lists.
 	filtersMu *sync.RWMutex
 
@@ -111,6 +145,10 @@ type Config struct {
 	// BlockingMode defines the way how blocked responses are constructed.
 	BlockingMode BlockingMode `yaml:"blocking_mode"`
 
+	// CatalogURL is the URL of the catalog of the filtering rule lists in the
+	// Hostlists Registry format.  If empty, the catalog is disabled.
+	CatalogURL string `yaml:"catalog_url"`
+
 	// ParentalBlockHost is the IP (or domain name) which is used to respond to
 	// DNS requests blocked by parental control.
 	ParentalBlockHost string `yaml:"parental_block_host"`
@@ -185,6 +223,33 @@ const (
 	BlockingModeREFUSED BlockingMode = "refused"
 )
 
+// ValidateBlockingModeOverride returns an error if mode isn't a valid override
+// of the global blocking mode.  The empty mode is valid and means that there is
+// no override.  The overrides with [BlockingModeCustomIP] use the global
+// blocking IP addresses bIPv4 and bIPv6, so both of them must be set.
+func ValidateBlockingModeOverride(mode BlockingMode, bIPv4, bIPv6 netip.Addr) (err error) {
+	switch mode {
+	case BlockingModeCustomIP:
+		if !bIPv4.Is4() || !bIPv6.Is6() {
+			return fmt.Errorf(
+				"blocking mode %q requires valid global blocking_ipv4 and blocking_ipv6",
+				mode,
+			)
+		}
+
+		return nil
+	case
+		"",
+		BlockingModeDefault,
+		BlockingModeNullIP,
+		BlockingModeNXDOMAIN,
+		BlockingModeREFUSED:
+		return nil
+	default:
+		return fmt.Errorf("bad blocking mode %q", mode)
+	}
+}
+
 // LookupStats store stats collected during safebrowsing or parental checks
 type LookupStats struct {
 	Requests   uint64 // number of HTTP requests that were sent
@@ -219,6 +284,9 @@ type Checker interface {
 
 // DNSFilter matches hostnames and DNS requests against filtering rules.
 type DNSFilter struct {
+	// logger is used for logging the operation of the filtering.
+	logger *slog.Logger
+
 	// idGen is used to generate IDs for package urlfilter.
 	idGen *idGenerator
 
@@ -315,6 +383,19 @@ const (
 	//
 	// See https://github.com/AdguardTeam/AdGuardHome/issues/2499.
 	RewrittenRule
+
+	// FilteredBypass is returned when the host is a public DNS resolver or a
+	// canary domain blocked to prevent the DNS bypass.
+	FilteredBypass
+
+	// FilteredGeoIP is returned when the response is blocked because of the
+	// country or the autonomous system of an IP address in it.
+	FilteredGeoIP
+
+	// FilteredAnomaly is returned when the request looks like the DNS
+	// tunneling or an algorithmically generated domain.  The request is only
+	// blocked if [Result.IsFiltered] is true.
+	FilteredAnomaly
 )
 
 // TODO(a.garipov): Resync with actual code names or replace completely
@@ -334,6 +415,10 @@ var reasonNames = []string{
 	Rewritten:          "Rewrite",
 	RewrittenAutoHosts: "RewriteEtcHosts",
 	RewrittenRule:      "RewriteRule",
+
+	FilteredBypass:  "FilteredBypass",
+	FilteredGeoIP:   "FilteredGeoIP",
+	FilteredAnomaly: "FilteredAnomaly",
 }
 
 func (r Reason) String() string {
@@ -432,13 +517,13 @@ func (d *DNSFilter) Close() {
 func (d *DNSFilter) reset() {
 	if d.rulesStorage != nil {
 		if err := d.rulesStorage.Close(); err != nil {
-			log.Error("filtering: rulesStorage.Close: %s", err)
+			d.logger.Error("closing rules storage", slogutil.KeyError, err)
 		}
 	}
 
 	if d.rulesStorageAllow != nil {
 		if err := d.rulesStorageAllow.Close(); err != nil {
-			log.Error("filtering: rulesStorageAllow.Close: %s", err)
+			d.logger.Error("closing allowlist rules storage", slogutil.KeyError, err)
 		}
 	}
 }
@@ -636,7 +721,7 @@ func (d *DNSFilter) processRewrites(host string, qtype uint16) (res Result) {
 		rwPat := rw.Domain
 		rwAns := rw.Answer
 
-		log.Debug("rewrite: cname for %s is %s", host, rwAns)
+		d.logger.Debug("rewrite: found cname", "host", host, "cname", rwAns)
 
 		if origHost == rwAns || rwPat == rwAns {
 			// Either a request for the hostname itself or a rewrite of
@@ -655,7 +740,7 @@ func (d *DNSFilter) processRewrites(host string, qtype uint16) (res Result) {
 
 		host = rwAns
 		if cnames.Has(host) {
-			log.Info("rewrite: cname loop for %q on %q", origHost, host)
+			d.logger.Info("rewrite: cname loop", "host", origHost, "cname", host)
 
 			return res
 		}
@@ -665,7 +750,7 @@ func (d *DNSFilter) processRewrites(host string, qtype uint16) (res Result) {
 		rewrites, matched = findRewrites(d.conf.Rewrites, host, qtype)
 	}
 
-	setRewriteResult(&res, host, rewrites, qtype)
+	d.setRewriteResult(&res, host, rewrites, qtype)
 
 	return res
 }
@@ -673,7 +758,7 @@ func (d *DNSFilter) processRewrites(host string, qtype uint16) (res Result) {
 // matchBlockedServicesRules checks the host against the blocked services rules
 // in settings, if any.  The err is always nil, it is only there to make this
 // a valid hostChecker function.
-func matchBlockedServicesRules(
+func (d *DNSFilter) matchBlockedServicesRules(
 	host string,
 	_ uint16,
 	setts *Settings,
@@ -701,8 +786,12 @@ func matchBlockedServicesRules(
 					Text:         ruleText,
 				}}
 
-				log.Debug("blocked services: matched rule: %s  host: %s  service: %s",
-					ruleText, host, s.Name)
+				d.logger.Debug(
+					"blocked services: matched rule",
+					"rule", ruleText,
+					"host", host,
+					"service", s.Name,
+				)
 
 				return res, nil
 			}
@@ -794,7 +883,7 @@ func (d *DNSFilter) initFiltering(allowFilters, blockFilters []Filter) (err erro
 	// Make sure that the OS reclaims memory as soon as possible.
 	debug.FreeOSMemory()
 
-	log.Debug("filtering: initialized filtering engine")
+	d.logger.Debug("initialized filtering engine")
 
 	return nil
 }
@@ -832,7 +921,7 @@ func (d *DNSFilter) matchHostProcessAllowList(
 		return Result{}, fmt.Errorf("invalid dns result: rules are empty")
 	}
 
-	log.Debug("filtering: allowlist rules for host %q: %+v", host, matchedRules)
+	d.logger.Debug("found allowlist rules", "host", host, "rules", matchedRules)
 
 	return makeResult(matchedRules, NotFilteredAllowList), nil
 }
@@ -946,11 +1035,11 @@ func (d *DNSFilter) matchHost(
 
 	res = d.matchHostProcessDNSResult(rrtype, dnsres)
 	for _, r := range res.Rules {
-		log.Debug(
-			"filtering: found rule %q for host %q, filter list id: %d",
-			r.Text,
-			host,
-			r.FilterListID,
+		d.logger.Debug(
+			"found rule",
+			"rule", r.Text,
+			"host", host,
+			"filter_list_id", r.FilterListID,
 		)
 	}
 
@@ -974,15 +1063,22 @@ func makeResult(matchedRules []rules.Rule, reason Reason) (res Result) {
 	}
 }
 
-// InitModule manually initializes blocked services map.
-func InitModule() {
-	initBlockedServices()
+// InitModule manually initializes blocked services map.  logger is used to log
+// the initialization.
+func InitModule(logger *slog.Logger) {
+	initBlockedServices(logger)
 }
 
 // New creates properly initialized DNS Filter that is ready to be used.  c must
 // be non-nil.
 func New(c *Config, blockFilters []Filter) (d *DNSFilter, err error) {
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	d = &DNSFilter{
+		logger:                 logger,
 		idGen:                  newIDGenerator(int32(time.Now().Unix())),
 		bufPool:                syncutil.NewSlicePool[byte](rulelist.DefaultRuleBufSize),
 		refreshLock:            &sync.Mutex{},
@@ -1000,7 +1096,7 @@ func New(c *Config, blockFilters []Filter) (d *DNSFilter, err error) {
 		check: d.matchHost,
 		name:  "filtering",
 	}, {
-		check: matchBlockedServicesRules,
+		check: d.matchBlockedServicesRules,
 		name:  "blocked services",
 	}, {
 		check: d.checkSafeBrowsing,
@@ -1030,6 +1126,12 @@ func New(c *Config, blockFilters []Filter) (d *DNSFilter, err error) {
 		}
 	}
 
+	err = validateBlockingModes(d.conf.Filters, d.conf.BlockingIPv4, d.conf.BlockingIPv6)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
 	if blockFilters != nil {
 		err = d.initFiltering(nil, blockFilters)
 		if err != nil {
@@ -1047,8 +1149,8 @@ func New(c *Config, blockFilters []Filter) (d *DNSFilter, err error) {
 	d.conf.Filters = deduplicateFilters(d.conf.Filters)
 	d.conf.WhitelistFilters = deduplicateFilters(d.conf.WhitelistFilters)
 
-	d.idGen.fix(d.conf.Filters)
-	d.idGen.fix(d.conf.WhitelistFilters)
+	d.idGen.fix(d.logger, d.conf.Filters)
+	d.idGen.fix(d.logger, d.conf.WhitelistFilters)
 
 	return d, nil
 }
@@ -1065,7 +1167,7 @@ func (d *DNSFilter) Start() {
 
 // updatesLoop initializes new filters and checks for filters updates in a loop.
 func (d *DNSFilter) updatesLoop() {
-	defer log.OnPanic("filtering: updates loop")
+	defer slogutil.RecoverAndLog(context.TODO(), d.logger)
 
 	ivl := time.Second * 5
 	t := time.NewTimer(ivl)
@@ -1075,7 +1177,7 @@ func (d *DNSFilter) updatesLoop() {
 		case params := <-d.filtersInitializerChan:
 			err := d.initFiltering(params.allowFilters, params.blockFilters)
 			if err != nil {
-				log.Error("filtering: initializing: %s", err)
+				d.logger.Error("initializing", slogutil.KeyError, err)
 
 				continue
 			}
@@ -1124,9 +1226,11 @@ func (d *DNSFilter) checkSafeBrowsing(
 		return Result{}, nil
 	}
 
-	if log.GetLevel() >= log.DEBUG {
-		timer := log.StartTimer()
-		defer timer.LogElapsed("filtering: safebrowsing lookup for %q", host)
+	if d.logger.Enabled(context.TODO(), slog.LevelDebug) {
+		start := time.Now()
+		defer func() {
+			d.logger.Debug("safebrowsing lookup", "host", host, "elapsed", time.Since(start))
+		}()
 	}
 
 	res = Result{
@@ -1156,9 +1260,11 @@ func (d *DNSFilter) checkParental(
 		return Result{}, nil
 	}
 
-	if log.GetLevel() >= log.DEBUG {
-		timer := log.StartTimer()
-		defer timer.LogElapsed("filtering: parental lookup for %q", host)
+	if d.logger.Enabled(context.TODO(), slog.LevelDebug) {
+		start := time.Now()
+		defer func() {
+			d.logger.Debug("parental lookup", "host", host, "elapsed", time.Since(start))
+		}()
 	}
 
 	res = Result{
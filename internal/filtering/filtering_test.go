@@ -3,12 +3,14 @@ package filtering
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/netip"
 	"testing"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/hashprefix"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/AdguardTeam/urlfilter/rules"
@@ -45,12 +47,24 @@ func newForTest(t testing.TB, c *Config, filters []Filter) (f *DNSFilter, setts
 		// It must not be nil.
 		c = &Config{}
 	}
+
+	if c.Logger == nil {
+		c.Logger = slogutil.NewDiscardLogger()
+	}
+
 	f, err := New(c, filters)
 	require.NoError(t, err)
 
 	return f, setts
 }
 
+// newTestLogger returns a logger writing the debug records to w.
+func newTestLogger(w io.Writer) (l *slog.Logger) {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	}))
+}
+
 func newChecker(host string) Checker {
 	return hashprefix.New(&hashprefix.Config{
 		CacheTime: 10,
@@ -168,12 +182,11 @@ func TestDNSFilter_CheckHost_hostRules(t *testing.T) {
 
 func TestSafeBrowsing(t *testing.T) {
 	logOutput := &bytes.Buffer{}
-	aghtest.ReplaceLogWriter(t, logOutput)
-	aghtest.ReplaceLogLevel(t, log.DEBUG)
 
 	sbChecker := newChecker(sbBlocked)
 
 	d, setts := newForTest(t, &Config{
+		Logger:              newTestLogger(logOutput),
 		SafeBrowsingEnabled: true,
 		SafeBrowsingChecker: sbChecker,
 	}, nil)
@@ -181,7 +194,7 @@ func TestSafeBrowsing(t *testing.T) {
 
 	d.checkMatch(t, sbBlocked, setts)
 
-	require.Contains(t, logOutput.String(), fmt.Sprintf("safebrowsing lookup for %q", sbBlocked))
+	require.Contains(t, logOutput.String(), `msg="safebrowsing lookup" host=`+sbBlocked)
 
 	d.checkMatch(t, "test."+sbBlocked, setts)
 	d.checkMatchEmpty(t, "yandex.ru", setts)
@@ -216,17 +229,16 @@ func TestParallelSB(t *testing.T) {
 
 func TestParentalControl(t *testing.T) {
 	logOutput := &bytes.Buffer{}
-	aghtest.ReplaceLogWriter(t, logOutput)
-	aghtest.ReplaceLogLevel(t, log.DEBUG)
 
 	d, setts := newForTest(t, &Config{
+		Logger:                 newTestLogger(logOutput),
 		ParentalEnabled:        true,
 		ParentalControlChecker: newChecker(pcBlocked),
 	}, nil)
 	t.Cleanup(d.Close)
 
 	d.checkMatch(t, pcBlocked, setts)
-	require.Contains(t, logOutput.String(), fmt.Sprintf("parental lookup for %q", pcBlocked))
+	require.Contains(t, logOutput.String(), `msg="parental lookup" host=`+pcBlocked)
 
 	d.checkMatch(t, "www."+pcBlocked, setts)
 	d.checkMatchEmpty(t, "www.yandex.ru", setts)
@@ -694,3 +706,54 @@ func BenchmarkSafeBrowsingParallel(b *testing.B) {
 		}
 	})
 }
+
+func TestValidateBlockingModeOverride(t *testing.T) {
+	bIPv4 := netip.MustParseAddr("192.0.2.1")
+	bIPv6 := netip.MustParseAddr("2001:db8::1")
+
+	testCases := []struct {
+		bIPv4      netip.Addr
+		bIPv6      netip.Addr
+		name       string
+		mode       BlockingMode
+		wantErrMsg string
+	}{{
+		bIPv4:      netip.Addr{},
+		bIPv6:      netip.Addr{},
+		name:       "empty",
+		mode:       "",
+		wantErrMsg: "",
+	}, {
+		bIPv4:      netip.Addr{},
+		bIPv6:      netip.Addr{},
+		name:       "nxdomain",
+		mode:       BlockingModeNXDOMAIN,
+		wantErrMsg: "",
+	}, {
+		bIPv4:      bIPv4,
+		bIPv6:      bIPv6,
+		name:       "custom_ip",
+		mode:       BlockingModeCustomIP,
+		wantErrMsg: "",
+	}, {
+		bIPv4: bIPv4,
+		bIPv6: netip.Addr{},
+		name:  "custom_ip_no_ipv6",
+		mode:  BlockingModeCustomIP,
+		wantErrMsg: `blocking mode "custom_ip" requires valid global blocking_ipv4 ` +
+			`and blocking_ipv6`,
+	}, {
+		bIPv4:      netip.Addr{},
+		bIPv6:      netip.Addr{},
+		name:       "bad",
+		mode:       "bad",
+		wantErrMsg: `bad blocking mode "bad"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBlockingModeOverride(tc.mode, tc.bIPv4, tc.bIPv6)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
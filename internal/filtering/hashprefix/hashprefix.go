@@ -5,13 +5,14 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/cache"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/stringutil"
 	"github.com/miekg/dns"
@@ -52,6 +53,10 @@ func findMatch(a, b []hostnameHash) (matched bool) {
 // Config is the configuration structure for safe browsing and parental
 // control.
 type Config struct {
+	// Logger is used for logging the operation of the checker.  If nil,
+	// [slog.Default] is used.
+	Logger *slog.Logger
+
 	// Upstream is the upstream DNS server.
 	Upstream upstream.Upstream
 
@@ -70,15 +75,15 @@ type Config struct {
 }
 
 type Checker struct {
+	// logger is used for logging the operation of the checker.
+	logger *slog.Logger
+
 	// upstream is the upstream DNS server.
 	upstream upstream.Upstream
 
 	// cache stores hostname hashes.
 	cache cache.Cache
 
-	// svc is the name of the service.
-	svc string
-
 	// txtSuffix is the TXT suffix for DNS request.
 	txtSuffix string
 
@@ -88,13 +93,18 @@ type Checker struct {
 
 // New returns Checker.
 func New(conf *Config) (c *Checker) {
+	logger := conf.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	return &Checker{
+		logger:   logger.With("service", conf.ServiceName),
 		upstream: conf.Upstream,
 		cache: cache.New(cache.Config{
 			EnableLRU: true,
 			MaxSize:   conf.CacheSize,
 		}),
-		svc:       conf.ServiceName,
 		txtSuffix: conf.TXTSuffix,
 		cacheTime: conf.CacheTime,
 	}
@@ -106,14 +116,14 @@ func (c *Checker) Check(host string) (ok bool, err error) {
 
 	found, blocked, hashesToRequest := c.findInCache(hashes)
 	if found {
-		log.Debug("%s: found %q in cache, blocked: %t", c.svc, host, blocked)
+		c.logger.Debug("found in cache", "host", host, "blocked", blocked)
 
 		return blocked, nil
 	}
 
 	question := c.getQuestion(hashesToRequest)
 
-	log.Debug("%s: checking %s: %s", c.svc, host, question)
+	c.logger.Debug("checking", "host", host, "question", question)
 	req := (&dns.Msg{}).SetQuestion(question, dns.TypeTXT)
 
 	resp, err := c.upstream.Exchange(req)
@@ -202,11 +212,11 @@ func (c *Checker) processAnswer(
 		receivedHashes = c.appendHashesFromTXT(receivedHashes, txt, host)
 	}
 
-	log.Debug("%s: received answer for %s with %d TXT count", c.svc, host, txtCount)
+	c.logger.Debug("received answer", "host", host, "txt_count", txtCount)
 
 	matched = findMatch(hashesToRequest, receivedHashes)
 	if matched {
-		log.Debug("%s: matched %s", c.svc, host)
+		c.logger.Debug("matched", "host", host)
 
 		return true, receivedHashes
 	}
@@ -220,18 +230,18 @@ func (c *Checker) appendHashesFromTXT(
 	txt *dns.TXT,
 	host string,
 ) (receivedHashes []hostnameHash) {
-	log.Debug("%s: received hashes for %s: %v", c.svc, host, txt.Txt)
+	c.logger.Debug("received hashes", "host", host, "hashes", txt.Txt)
 
 	for _, t := range txt.Txt {
 		if len(t) != hexSize {
-			log.Debug("%s: wrong hex size %d for %s %s", c.svc, len(t), host, t)
+			c.logger.Debug("wrong hex size", "host", host, "hash", t, "size", len(t))
 
 			continue
 		}
 
 		buf, err := hex.DecodeString(t)
 		if err != nil {
-			log.Debug("%s: decoding hex string %s: %s", c.svc, t, err)
+			c.logger.Debug("decoding hex string", "hash", t, slogutil.KeyError, err)
 
 			continue
 		}
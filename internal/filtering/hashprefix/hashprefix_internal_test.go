@@ -10,6 +10,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
 	"github.com/AdguardTeam/golibs/cache"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -43,7 +44,7 @@ func TestChcker_getQuestion(t *testing.T) {
 	assert.False(t, slices.Contains(hashes, hash))
 
 	c := &Checker{
-		svc:       "SafeBrowsing",
+		logger:    slogutil.NewDiscardLogger(),
 		txtSuffix: suf,
 	}
 
@@ -96,7 +97,7 @@ func TestHostnameToHashes(t *testing.T) {
 
 func TestChecker_storeInCache(t *testing.T) {
 	c := &Checker{
-		svc:       "SafeBrowsing",
+		logger:    slogutil.NewDiscardLogger(),
 		cacheTime: cacheTime,
 	}
 	conf := cache.Config{}
@@ -153,7 +154,7 @@ func TestChecker_storeInCache(t *testing.T) {
 	assert.True(t, ok)
 
 	c = &Checker{
-		svc:       "SafeBrowsing",
+		logger:    slogutil.NewDiscardLogger(),
 		cacheTime: cacheTime,
 	}
 	c.cache = cache.New(cache.Config{})
@@ -6,7 +6,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/golibs/hostsfile"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/urlfilter/rules"
 	"github.com/miekg/dns"
@@ -24,7 +24,7 @@ func (d *DNSFilter) matchSysHosts(
 		return Result{}, nil
 	}
 
-	vals, rs, matched := hostsRewrites(qtype, host, d.conf.EtcHosts)
+	vals, rs, matched := d.hostsRewrites(qtype, host, d.conf.EtcHosts)
 	if !matched {
 		return Result{}, nil
 	}
@@ -42,7 +42,7 @@ func (d *DNSFilter) matchSysHosts(
 }
 
 // hostsRewrites returns values and rules matched by qt and host within hs.
-func hostsRewrites(
+func (d *DNSFilter) hostsRewrites(
 	qtype uint16,
 	host string,
 	hs hostsfile.Storage,
@@ -56,7 +56,7 @@ func hostsRewrites(
 	case dns.TypePTR:
 		addr, err := netutil.IPFromReversedAddr(host)
 		if err != nil {
-			log.Debug("filtering: failed to parse PTR record %q: %s", host, err)
+			d.logger.Debug("parsing ptr record", "host", host, slogutil.KeyError, err)
 
 			return nil, nil, false
 		}
@@ -73,7 +73,7 @@ func hostsRewrites(
 
 		return vals, rls, len(names) > 0
 	default:
-		log.Debug("filtering: unsupported qtype %d", qtype)
+		d.logger.Debug("unsupported qtype", "qtype", qtype)
 
 		return nil, nil, false
 	}
@@ -15,7 +15,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/miekg/dns"
 )
 
@@ -51,9 +51,28 @@ func validateFilterURL(urlStr string) (err error) {
 }
 
 type filterAddJSON struct {
-	Name      string `json:"name"`
-	URL       string `json:"url"`
-	Whitelist bool   `json:"whitelist"`
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// CatalogID is the ID of the list in the catalog.  If not empty, URL is
+	// ignored and the list is subscribed to the URL from the catalog.
+	CatalogID string `json:"catalog_id"`
+
+	Whitelist bool `json:"whitelist"`
+}
+
+// catalogURL returns the current URL of the list with the given catalog id.
+func (d *DNSFilter) catalogURL(id string) (u string, err error) {
+	if d.conf.Catalog == nil {
+		return "", errors.Error("filter catalog is disabled")
+	}
+
+	u, ok := d.conf.Catalog.SourceURL(id)
+	if !ok {
+		return "", fmt.Errorf("no filter with catalog id %q", id)
+	}
+
+	return u, nil
 }
 
 func (d *DNSFilter) handleFilteringAddURL(w http.ResponseWriter, r *http.Request) {
@@ -65,6 +84,15 @@ func (d *DNSFilter) handleFilteringAddURL(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if fj.CatalogID != "" {
+		fj.URL, err = d.catalogURL(fj.CatalogID)
+		if err != nil {
+			aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
+
+			return
+		}
+	}
+
 	err = validateFilterURL(fj.URL)
 	if err != nil {
 		aghhttp.Error(r, w, http.StatusBadRequest, "%s", err)
@@ -82,10 +110,11 @@ func (d *DNSFilter) handleFilteringAddURL(w http.ResponseWriter, r *http.Request
 
 	// Set necessary properties
 	filt := FilterYAML{
-		Enabled: true,
-		URL:     fj.URL,
-		Name:    fj.Name,
-		white:   fj.Whitelist,
+		Enabled:   true,
+		URL:       fj.URL,
+		Name:      fj.Name,
+		CatalogID: fj.CatalogID,
+		white:     fj.Whitelist,
 		Filter: Filter{
 			ID: d.idGen.next(),
 		},
@@ -164,7 +193,7 @@ func (d *DNSFilter) handleFilteringRemoveURL(w http.ResponseWriter, r *http.Requ
 			return flt.URL == req.URL
 		})
 		if delIdx == -1 {
-			log.Error("deleting filter with url %q: %s", req.URL, errFilterNotExist)
+			d.logger.Error("deleting filter", "url", req.URL, slogutil.KeyError, errFilterNotExist)
 
 			return
 		}
@@ -173,14 +202,19 @@ func (d *DNSFilter) handleFilteringRemoveURL(w http.ResponseWriter, r *http.Requ
 		p := deleted.Path(d.conf.DataDir)
 		err = os.Rename(p, p+".old")
 		if err != nil && !errors.Is(err, os.ErrNotExist) {
-			log.Error("deleting filter %d: renaming file %q: %s", deleted.ID, p, err)
+			d.logger.Error(
+				"deleting filter: renaming file",
+				"id", deleted.ID,
+				"file", p,
+				slogutil.KeyError, err,
+			)
 
 			return
 		}
 
 		*filters = slices.Delete(*filters, delIdx, delIdx+1)
 
-		log.Info("deleted filter %d", deleted.ID)
+		d.logger.Info("deleted filter", "id", deleted.ID)
 	}()
 
 	d.conf.ConfigModified()
@@ -311,6 +345,7 @@ type filterJSON struct {
 	URL         string               `json:"url"`
 	Name        string               `json:"name"`
 	LastUpdated string               `json:"last_updated,omitempty"`
+	CatalogID   string               `json:"catalog_id,omitempty"`
 	ID          rulelist.URLFilterID `json:"id"`
 	RulesCount  uint32               `json:"rules_count"`
 	Enabled     bool                 `json:"enabled"`
@@ -330,6 +365,7 @@ func filterToJSON(f FilterYAML) filterJSON {
 		Enabled:    f.Enabled,
 		URL:        f.URL,
 		Name:       f.Name,
+		CatalogID:  f.CatalogID,
 		RulesCount: uint32(f.RulesCount),
 	}
 
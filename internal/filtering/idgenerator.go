@@ -2,11 +2,11 @@ package filtering
 
 import (
 	"fmt"
+	"log/slog"
 	"sync/atomic"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/golibs/container"
-	"github.com/AdguardTeam/golibs/log"
 )
 
 // idGenerator generates filtering-list IDs in a way broadly compatible with the
@@ -40,8 +40,9 @@ func (g *idGenerator) next() (id rulelist.URLFilterID) {
 	return rulelist.URLFilterID(id32)
 }
 
-// fix ensures that flts all have unique IDs.
-func (g *idGenerator) fix(flts []FilterYAML) {
+// fix ensures that flts all have unique IDs.  logger is used to log the
+// reassigned IDs.
+func (g *idGenerator) fix(logger *slog.Logger, flts []FilterYAML) {
 	set := container.NewMapSet[rulelist.URLFilterID]()
 	for i, f := range flts {
 		id := f.ID
@@ -61,11 +62,11 @@ func (g *idGenerator) fix(flts []FilterYAML) {
 			newID = g.next()
 		}
 
-		log.Info(
-			"filtering: warning: filter at index %d has duplicate id %d; reassigning to %d",
-			i,
-			id,
-			newID,
+		logger.Warn(
+			"filter has duplicate id; reassigning",
+			"idx", i,
+			"id", id,
+			"new_id", newID,
 		)
 
 		flts[i].ID = newID
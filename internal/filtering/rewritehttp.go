@@ -6,7 +6,6 @@ import (
 	"slices"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
-	"github.com/AdguardTeam/golibs/log"
 )
 
 // TODO(d.kolyshev): Use [rewrite.Item] instead.
@@ -50,7 +49,7 @@ func (d *DNSFilter) handleRewriteAdd(w http.ResponseWriter, r *http.Request) {
 		Answer: rwJSON.Answer,
 	}
 
-	err = rw.normalize()
+	err = rw.normalize(d.logger)
 	if err != nil {
 		// Shouldn't happen currently, since normalize only returns a non-nil
 		// error when a rewrite is nil, but be change-proof.
@@ -64,11 +63,11 @@ func (d *DNSFilter) handleRewriteAdd(w http.ResponseWriter, r *http.Request) {
 		defer d.confMu.Unlock()
 
 		d.conf.Rewrites = append(d.conf.Rewrites, rw)
-		log.Debug(
-			"rewrite: added element: %s -> %s [%d]",
-			rw.Domain,
-			rw.Answer,
-			len(d.conf.Rewrites),
+		d.logger.Debug(
+			"rewrite: added element",
+			"domain", rw.Domain,
+			"answer", rw.Answer,
+			"count", len(d.conf.Rewrites),
 		)
 	}()
 
@@ -98,7 +97,7 @@ func (d *DNSFilter) handleRewriteDelete(w http.ResponseWriter, r *http.Request)
 
 		for _, ent := range d.conf.Rewrites {
 			if ent.equal(entDel) {
-				log.Debug("rewrite: removed element: %s -> %s", ent.Domain, ent.Answer)
+				d.logger.Debug("rewrite: removed element", "domain", ent.Domain, "answer", ent.Answer)
 
 				continue
 			}
@@ -138,7 +137,7 @@ func (d *DNSFilter) handleRewriteUpdate(w http.ResponseWriter, r *http.Request)
 		Answer: updateJSON.Update.Answer,
 	}
 
-	err = rwAdd.normalize()
+	err = rwAdd.normalize(d.logger)
 	if err != nil {
 		// Shouldn't happen currently, since normalize only returns a non-nil
 		// error when a rewrite is nil, but be change-proof.
@@ -166,6 +165,6 @@ func (d *DNSFilter) handleRewriteUpdate(w http.ResponseWriter, r *http.Request)
 
 	d.conf.Rewrites = slices.Replace(d.conf.Rewrites, index, index+1, rwAdd)
 
-	log.Debug("rewrite: removed element: %s -> %s", rwDel.Domain, rwDel.Answer)
-	log.Debug("rewrite: added element: %s -> %s", rwAdd.Domain, rwAdd.Answer)
+	d.logger.Debug("rewrite: removed element", "domain", rwDel.Domain, "answer", rwDel.Answer)
+	d.logger.Debug("rewrite: added element", "domain", rwAdd.Domain, "answer", rwAdd.Answer)
 }
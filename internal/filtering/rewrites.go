@@ -2,12 +2,13 @@ package filtering
 
 import (
 	"fmt"
+	"log/slog"
 	"net/netip"
 	"slices"
 	"strings"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/miekg/dns"
 )
 
@@ -58,7 +59,7 @@ func (rw *LegacyRewrite) matchesQType(qt uint16) (ok bool) {
 // to domain name case, IP length, and so on.
 //
 // If rw is nil, it returns an errors.
-func (rw *LegacyRewrite) normalize() (err error) {
+func (rw *LegacyRewrite) normalize(logger *slog.Logger) (err error) {
 	if rw == nil {
 		return errors.Error("nil rewrite entry")
 	}
@@ -85,7 +86,7 @@ func (rw *LegacyRewrite) normalize() (err error) {
 
 	ip, err := netip.ParseAddr(rw.Answer)
 	if err != nil {
-		log.Debug("normalizing legacy rewrite: %s", err)
+		logger.Debug("normalizing legacy rewrite", slogutil.KeyError, err)
 		rw.Type = dns.TypeCNAME
 
 		return nil
@@ -138,7 +139,7 @@ func (rw *LegacyRewrite) Compare(b *LegacyRewrite) (res int) {
 // prepareRewrites normalizes and validates all legacy DNS rewrites.
 func (d *DNSFilter) prepareRewrites() (err error) {
 	for i, r := range d.conf.Rewrites {
-		err = r.normalize()
+		err = r.normalize(d.logger)
 		if err != nil {
 			return fmt.Errorf("at index %d: %w", i, err)
 		}
@@ -191,7 +192,12 @@ func findRewrites(
 
 // setRewriteResult sets the Reason or IPList of res if necessary.  res must not
 // be nil.
-func setRewriteResult(res *Result, host string, rewrites []*LegacyRewrite, qtype uint16) {
+func (d *DNSFilter) setRewriteResult(
+	res *Result,
+	host string,
+	rewrites []*LegacyRewrite,
+	qtype uint16,
+) {
 	for _, rw := range rewrites {
 		if rw.Type == qtype && (qtype == dns.TypeA || qtype == dns.TypeAAAA) {
 			if rw.IP == (netip.Addr{}) {
@@ -203,7 +209,7 @@ func setRewriteResult(res *Result, host string, rewrites []*LegacyRewrite, qtype
 
 			res.IPList = append(res.IPList, rw.IP)
 
-			log.Debug("rewrite: a/aaaa for %s is %s", host, rw.IP)
+			d.logger.Debug("rewrite: found a/aaaa", "host", host, "ip", rw.IP)
 		}
 	}
 }
@@ -41,6 +41,9 @@ const (
 	URLFilterIDParentalControl URLFilterID = -3
 	URLFilterIDSafeBrowsing    URLFilterID = -4
 	URLFilterIDSafeSearch      URLFilterID = -5
+	URLFilterIDBypass          URLFilterID = -6
+	URLFilterIDGeoIP           URLFilterID = -7
+	URLFilterIDAnomaly         URLFilterID = -8
 )
 
 // UID is the type for the unique IDs of filtering-rule lists.
@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"encoding/gob"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/netip"
 	"strings"
@@ -16,7 +17,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/golibs/cache"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/urlfilter"
 	"github.com/AdguardTeam/urlfilter/filterlist"
 	"github.com/AdguardTeam/urlfilter/rules"
@@ -66,15 +67,18 @@ type Default struct {
 	// engine may be nil, which means that this safe search filter is disabled.
 	engine *urlfilter.DNSEngine
 
-	cache     cache.Cache
-	resolver  filtering.Resolver
-	logPrefix string
-	cacheTTL  time.Duration
+	// logger is used for logging the operation of the safe search filter.
+	logger *slog.Logger
+
+	cache    cache.Cache
+	resolver filtering.Resolver
+	cacheTTL time.Duration
 }
 
 // NewDefault returns an initialized default safe search filter.  name is used
 // for logging.
 func NewDefault(
+	logger *slog.Logger,
 	conf filtering.SafeSearchConfig,
 	name string,
 	cacheSize uint,
@@ -93,10 +97,8 @@ func NewDefault(
 			MaxSize:   cacheSize,
 		}),
 		resolver: resolver,
-		// Use %s, because the client safe-search names already contain double
-		// quotes.
-		logPrefix: fmt.Sprintf("safesearch %s: ", name),
-		cacheTTL:  cacheTTL,
+		logger:   logger.With("safesearch", name),
+		cacheTTL: cacheTTL,
 	}
 
 	err = ss.resetEngine(rulelist.URLFilterIDSafeSearch, conf)
@@ -108,21 +110,6 @@ func NewDefault(
 	return ss, nil
 }
 
-// log is a helper for logging  that includes the name of the safe search
-// filter.  level must be one of [log.DEBUG], [log.INFO], and [log.ERROR].
-func (ss *Default) log(level log.Level, msg string, args ...any) {
-	switch level {
-	case log.DEBUG:
-		log.Debug(ss.logPrefix+msg, args...)
-	case log.INFO:
-		log.Info(ss.logPrefix+msg, args...)
-	case log.ERROR:
-		log.Error(ss.logPrefix+msg, args...)
-	default:
-		panic(fmt.Errorf("safesearch: unsupported logging level %d", level))
-	}
-}
-
 // resetEngine creates new engine for provided safe search configuration and
 // sets it in ss.
 func (ss *Default) resetEngine(
@@ -130,7 +117,7 @@ func (ss *Default) resetEngine(
 	conf filtering.SafeSearchConfig,
 ) (err error) {
 	if !conf.Enabled {
-		ss.log(log.INFO, "disabled")
+		ss.logger.Info("disabled")
 
 		return nil
 	}
@@ -155,7 +142,7 @@ func (ss *Default) resetEngine(
 
 	ss.engine = urlfilter.NewDNSEngine(rs)
 
-	ss.log(log.INFO, "reset %d rules", ss.engine.RulesCount)
+	ss.logger.Info("reset rules", "count", ss.engine.RulesCount)
 
 	return nil
 }
@@ -167,7 +154,7 @@ var _ filtering.SafeSearch = (*Default)(nil)
 func (ss *Default) CheckHost(host string, qtype rules.RRType) (res filtering.Result, err error) {
 	start := time.Now()
 	defer func() {
-		ss.log(log.DEBUG, "lookup for %q finished in %s", host, time.Since(start))
+		ss.logger.Debug("finished lookup", "host", host, "elapsed", time.Since(start))
 	}()
 
 	if qtype != dns.TypeA && qtype != dns.TypeAAAA {
@@ -177,7 +164,7 @@ func (ss *Default) CheckHost(host string, qtype rules.RRType) (res filtering.Res
 	// Check cache. Return cached result if it was found
 	cachedValue, isFound := ss.getCachedResult(host, qtype)
 	if isFound {
-		ss.log(log.DEBUG, "found in cache: %q", host)
+		ss.logger.Debug("found in cache", "host", host)
 
 		return cachedValue, nil
 	}
@@ -189,7 +176,7 @@ func (ss *Default) CheckHost(host string, qtype rules.RRType) (res filtering.Res
 
 	fltRes, err := ss.newResult(rewrite, qtype)
 	if err != nil {
-		ss.log(log.DEBUG, "looking up addresses for %q: %s", host, err)
+		ss.logger.Debug("looking up addresses", "host", host, slogutil.KeyError, err)
 
 		return filtering.Result{}, err
 	}
@@ -259,14 +246,14 @@ func (ss *Default) newResult(
 
 	res.CanonName = host
 
-	ss.log(log.DEBUG, "resolving %q", host)
+	ss.logger.Debug("resolving", "host", host)
 
 	ips, err := ss.resolver.LookupIP(context.Background(), qtypeToProto(qtype), host)
 	if err != nil {
 		return nil, fmt.Errorf("resolving cname: %w", err)
 	}
 
-	ss.log(log.DEBUG, "resolved %s", ips)
+	ss.logger.Debug("resolved", "ips", ips)
 
 	for _, ip := range ips {
 		// TODO(a.garipov): Remove this filtering once the resolver we use
@@ -320,7 +307,7 @@ func (ss *Default) setCacheResult(host string, qtype rules.RRType, res filtering
 
 	err := gob.NewEncoder(buf).Encode(res)
 	if err != nil {
-		ss.log(log.ERROR, "cache encoding: %s", err)
+		ss.logger.Error("encoding cache item", slogutil.KeyError, err)
 
 		return
 	}
@@ -328,7 +315,7 @@ func (ss *Default) setCacheResult(host string, qtype rules.RRType, res filtering
 	val := buf.Bytes()
 	_ = ss.cache.Set([]byte(dns.Type(qtype).String()+" "+host), val)
 
-	ss.log(log.DEBUG, "stored in cache: %q, %d bytes", host, len(val))
+	ss.logger.Debug("stored in cache", "host", host, "bytes", len(val))
 }
 
 // getCachedResult returns stored data from cache for host.  qtype is expected
@@ -355,7 +342,7 @@ func (ss *Default) getCachedResult(
 
 	err := gob.NewDecoder(buf).Decode(&res)
 	if err != nil {
-		ss.log(log.ERROR, "cache decoding: %s", err)
+		ss.logger.Error("decoding cache item", slogutil.KeyError, err)
 
 		return filtering.Result{}, false
 	}
@@ -11,6 +11,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/safesearch"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
@@ -49,7 +50,7 @@ var yandexIP = netip.AddrFrom4([4]byte{213, 180, 193, 56})
 
 func TestDefault_CheckHost_yandex(t *testing.T) {
 	conf := testConf
-	ss, err := safesearch.NewDefault(conf, "", testCacheSize, testCacheTTL)
+	ss, err := safesearch.NewDefault(slogutil.NewDiscardLogger(), conf, "", testCacheSize, testCacheTTL)
 	require.NoError(t, err)
 
 	// Check host for each domain.
@@ -76,7 +77,7 @@ func TestDefault_CheckHost_yandex(t *testing.T) {
 
 func TestDefault_CheckHost_yandexAAAA(t *testing.T) {
 	conf := testConf
-	ss, err := safesearch.NewDefault(conf, "", testCacheSize, testCacheTTL)
+	ss, err := safesearch.NewDefault(slogutil.NewDiscardLogger(), conf, "", testCacheSize, testCacheTTL)
 	require.NoError(t, err)
 
 	res, err := ss.CheckHost("www.yandex.ru", dns.TypeAAAA)
@@ -106,7 +107,7 @@ func TestDefault_CheckHost_google(t *testing.T) {
 
 	conf := testConf
 	conf.CustomResolver = resolver
-	ss, err := safesearch.NewDefault(conf, "", testCacheSize, testCacheTTL)
+	ss, err := safesearch.NewDefault(slogutil.NewDiscardLogger(), conf, "", testCacheSize, testCacheTTL)
 	require.NoError(t, err)
 
 	// Check host for each domain.
@@ -164,7 +165,7 @@ func TestDefault_CheckHost_duckduckgoAAAA(t *testing.T) {
 		},
 	}
 
-	ss, err := safesearch.NewDefault(conf, "", testCacheSize, testCacheTTL)
+	ss, err := safesearch.NewDefault(slogutil.NewDiscardLogger(), conf, "", testCacheSize, testCacheTTL)
 	require.NoError(t, err)
 
 	// The DuckDuckGo safe-search addresses are resolved through CNAMEs, but
@@ -186,7 +187,7 @@ func TestDefault_CheckHost_duckduckgoAAAA(t *testing.T) {
 
 func TestDefault_Update(t *testing.T) {
 	conf := testConf
-	ss, err := safesearch.NewDefault(conf, "", testCacheSize, testCacheTTL)
+	ss, err := safesearch.NewDefault(slogutil.NewDiscardLogger(), conf, "", testCacheSize, testCacheTTL)
 	require.NoError(t, err)
 
 	res, err := ss.CheckHost("www.yandex.com", testQType)
@@ -0,0 +1,270 @@
+// Package geoip contains the lookup of the countries and the autonomous systems
+// of IP addresses in the local MaxMind DB files as well as the filtering of the
+// DNS answers based on them.
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// DefaultReloadIvl is the default interval between the checks of the database
+// files for modifications.
+const DefaultReloadIvl = 1 * time.Hour
+
+// IPInfo is the information about an IP address.
+type IPInfo struct {
+	// IP is the address the information is about.
+	IP netip.Addr `json:"ip"`
+
+	// Country is the ISO 3166-1 alpha-2 code of the country, for example
+	// "DE".  It's empty if unknown.
+	Country string `json:"country,omitempty"`
+
+	// ASOrg is the name of the organization of the autonomous system.  It's
+	// empty if unknown.
+	ASOrg string `json:"as_org,omitempty"`
+
+	// ASN is the number of the autonomous system.  It's zero if unknown.
+	ASN uint32 `json:"asn,omitempty"`
+}
+
+// ASNString returns the number of the autonomous system in the "AS<number>"
+// format, for example "AS15169".  s is empty if the ASN is unknown.
+func (i *IPInfo) ASNString() (s string) {
+	if i.ASN == 0 {
+		return ""
+	}
+
+	return "AS" + strconv.FormatUint(uint64(i.ASN), 10)
+}
+
+// Config is the configuration of a [Resolver].
+type Config struct {
+	// CountryDB is the path to the country database, such as
+	// GeoLite2-Country.mmdb.  It may be empty.
+	CountryDB string
+
+	// ASNDB is the path to the ASN database, such as GeoLite2-ASN.mmdb.  It
+	// may be empty.
+	ASNDB string
+
+	// ReloadIvl is the interval between the checks of the database files for
+	// modifications.  If zero, [DefaultReloadIvl] is used.
+	ReloadIvl time.Duration
+}
+
+// Resolver looks up the information about IP addresses in the MaxMind DB files
+// and reloads the files when they are modified.
+type Resolver struct {
+	country *dbFile
+	asn     *dbFile
+
+	// done is closed when the resolver is closed.
+	done chan struct{}
+
+	reloadIvl time.Duration
+}
+
+// New returns a new properly initialized *Resolver with the databases loaded.
+// conf must not be nil.
+func New(conf *Config) (r *Resolver, err error) {
+	r = &Resolver{
+		country:   newDBFile(conf.CountryDB),
+		asn:       newDBFile(conf.ASNDB),
+		done:      make(chan struct{}),
+		reloadIvl: conf.ReloadIvl,
+	}
+
+	if r.reloadIvl == 0 {
+		r.reloadIvl = DefaultReloadIvl
+	}
+
+	for _, f := range []*dbFile{r.country, r.asn} {
+		_, err = f.reload()
+		if err != nil {
+			return nil, fmt.Errorf("geoip: %w", err)
+		}
+	}
+
+	return r, nil
+}
+
+// Start starts the periodic reloading of the databases.  It must only be called
+// once.
+func (r *Resolver) Start() {
+	go r.reloadLoop()
+}
+
+// Close stops the periodic reloading of the databases.  It must only be called
+// once.
+func (r *Resolver) Close() {
+	close(r.done)
+}
+
+// reloadLoop reloads the modified databases periodically until the resolver is
+// closed.  It is intended to be used as a goroutine.
+func (r *Resolver) reloadLoop() {
+	defer log.OnPanic("geoip: reloading")
+
+	ticker := time.NewTicker(r.reloadIvl)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Go on.
+		case <-r.done:
+			return
+		}
+
+		for _, f := range []*dbFile{r.country, r.asn} {
+			reloaded, err := f.reload()
+			if err != nil {
+				log.Error("geoip: %s", err)
+			} else if reloaded {
+				log.Info("geoip: reloaded %q", f.path)
+			}
+		}
+	}
+}
+
+// Lookup returns the information about ip.  If there is none, info is nil.
+// It's safe for concurrent use.
+func (r *Resolver) Lookup(ip netip.Addr) (info *IPInfo) {
+	info = &IPInfo{
+		IP: ip,
+	}
+
+	if vals := r.country.lookup(ip, countryPaths...); vals != nil {
+		info.Country = countryFrom(vals)
+	}
+
+	if vals := r.asn.lookup(ip, asnPaths...); vals != nil {
+		info.ASN, info.ASOrg = asnFrom(vals)
+	}
+
+	if info.Country == "" && info.ASN == 0 {
+		return nil
+	}
+
+	return info
+}
+
+// countryPaths are the paths to the country code in the record of a country or
+// a city database.  If the country is unknown, the registered country of the
+// network is used.
+var countryPaths = [][]string{
+	{"country", "iso_code"},
+	{"registered_country", "iso_code"},
+}
+
+// countryFrom returns the country code from the values at [countryPaths].
+func countryFrom(vals []any) (code string) {
+	for _, v := range vals {
+		if code, _ = v.(string); code != "" {
+			return code
+		}
+	}
+
+	return ""
+}
+
+// asnPaths are the paths to the number and the organization of the autonomous
+// system in the record of an ASN database.
+var asnPaths = [][]string{
+	{"autonomous_system_number"},
+	{"autonomous_system_organization"},
+}
+
+// asnFrom returns the number and the organization of the autonomous system
+// from the values at [asnPaths].
+func asnFrom(vals []any) (asn uint32, org string) {
+	n, _ := vals[0].(uint64)
+	org, _ = vals[1].(string)
+
+	return uint32(n), org
+}
+
+// dbFile is a database file, which is reloaded when modified.
+type dbFile struct {
+	db *atomic.Pointer[mmdb]
+
+	// mu protects modTime.
+	mu      *sync.Mutex
+	modTime time.Time
+
+	// path is the path to the file.  If empty, the database is not used.
+	path string
+}
+
+// newDBFile returns a new *dbFile for path.  The database isn't loaded.
+func newDBFile(path string) (f *dbFile) {
+	return &dbFile{
+		db:   &atomic.Pointer[mmdb]{},
+		mu:   &sync.Mutex{},
+		path: path,
+	}
+}
+
+// reload loads the database from the file, if it's modified since the last
+// load.
+func (f *dbFile) reload() (reloaded bool, err error) {
+	if f.path == "" {
+		return false, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("checking database: %w", err)
+	}
+
+	if fi.ModTime().Equal(f.modTime) {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("reading database: %w", err)
+	}
+
+	db, err := newMMDB(b)
+	if err != nil {
+		return false, fmt.Errorf("parsing database %q: %w", f.path, err)
+	}
+
+	f.db.Store(db)
+	f.modTime = fi.ModTime()
+
+	log.Debug("geoip: loaded %q database from %q", db.dbType, f.path)
+
+	return true, nil
+}
+
+// lookup returns the values at paths in the record for ip or nil, if there is
+// no record or the database isn't loaded.
+func (f *dbFile) lookup(ip netip.Addr, paths ...[]string) (vals []any) {
+	db := f.db.Load()
+	if db == nil {
+		return nil
+	}
+
+	vals, err := db.lookup(ip, paths...)
+	if err != nil {
+		log.Debug("geoip: looking up %s in %q: %s", ip, f.path, err)
+
+		return nil
+	}
+
+	return vals
+}
@@ -0,0 +1,479 @@
+package geoip_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	testutil.DiscardLogOutput(m)
+}
+
+// mmdbWriter writes the IPv6 MaxMind DB files with 24-bit records for tests.
+type mmdbWriter struct {
+	// written maps the string representations of the values to their
+	// offsets in the data section to write the repeated values as pointers.
+	written map[string]int
+
+	data bytes.Buffer
+
+	// nodes are the pairs of records.  Negative records are the data ones,
+	// see dataRecord, zero ones are empty, since the root is never a child.
+	nodes [][2]int
+}
+
+// newMMDBWriter returns a new *mmdbWriter.
+func newMMDBWriter() (w *mmdbWriter) {
+	return &mmdbWriter{
+		written: map[string]int{},
+		nodes:   [][2]int{{}},
+	}
+}
+
+// dataRecord returns the record pointing to the data at off.
+func dataRecord(off int) (rec int) { return -off - 1 }
+
+// insert associates pref with v.  The IPv4 prefixes are inserted into the
+// IPv4-compatible subtree.  pref must not overlap with the inserted ones.
+func (w *mmdbWriter) insert(t testing.TB, pref netip.Prefix, v any) {
+	t.Helper()
+
+	addr := pref.Addr().As16()
+	bits := pref.Bits()
+	if pref.Addr().Is4() {
+		addr = [16]byte{}
+		a4 := pref.Addr().As4()
+		copy(addr[12:], a4[:])
+		bits += 96
+	}
+
+	key := fmt.Sprintf("%#v", v)
+	off, ok := w.written[key]
+	if !ok {
+		off = w.data.Len()
+		w.written[key] = off
+		encodeValue(&w.data, v)
+	} else {
+		// Write a pointer to the value to test pointers.
+		ptr := off
+		off = w.data.Len()
+		w.data.Write([]byte{0x20 | byte(ptr>>8), byte(ptr)})
+	}
+
+	node := 0
+	for i := range bits {
+		bit := (addr[i/8] >> (7 - i%8)) & 1
+		if i == bits-1 {
+			w.nodes[node][bit] = dataRecord(off)
+
+			break
+		}
+
+		next := w.nodes[node][bit]
+		require.GreaterOrEqual(t, next, 0, "prefix %s overlaps", pref)
+
+		if next == 0 {
+			next = len(w.nodes)
+			w.nodes = append(w.nodes, [2]int{})
+			w.nodes[node][bit] = next
+		}
+
+		node = next
+	}
+}
+
+// write writes the database of dbType to a new file and returns its path.
+func (w *mmdbWriter) write(t testing.TB, dbType string) (path string) {
+	t.Helper()
+
+	nodeCount := len(w.nodes)
+	buf := &bytes.Buffer{}
+	for _, n := range w.nodes {
+		for _, rec := range n {
+			var v int
+			switch {
+			case rec == 0:
+				v = nodeCount
+			case rec < 0:
+				v = nodeCount + 16 + (-rec - 1)
+			default:
+				v = rec
+			}
+
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+
+	buf.Write(make([]byte, 16))
+	buf.Write(w.data.Bytes())
+	buf.WriteString("\xab\xcd\xefMaxMind.com")
+	encodeValue(buf, map[string]any{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               dbType,
+		"ip_version":                  uint16(6),
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+
+	path = filepath.Join(t.TempDir(), dbType+".mmdb")
+	err := os.WriteFile(path, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	return path
+}
+
+// encodeCtrl writes the control byte of the type typ and the size.
+func encodeCtrl(buf *bytes.Buffer, typ byte, size int) {
+	var ctrl byte
+	var ext []byte
+	if typ > 7 {
+		ext = []byte{typ - 7}
+	} else {
+		ctrl = typ << 5
+	}
+
+	switch {
+	case size < 29:
+		buf.WriteByte(ctrl | byte(size))
+		buf.Write(ext)
+	case size < 285:
+		buf.WriteByte(ctrl | 29)
+		buf.Write(ext)
+		buf.WriteByte(byte(size - 29))
+	default:
+		buf.WriteByte(ctrl | 30)
+		buf.Write(ext)
+		buf.Write([]byte{byte((size - 285) >> 8), byte(size - 285)})
+	}
+}
+
+// encodeUint writes n of the type typ using the minimum number of bytes.
+func encodeUint(buf *bytes.Buffer, typ byte, n uint64) {
+	b := binary.BigEndian.AppendUint64(nil, n)
+	for len(b) > 0 && b[0] == 0 {
+		b = b[1:]
+	}
+
+	encodeCtrl(buf, typ, len(b))
+	buf.Write(b)
+}
+
+// encodeValue writes v in the MaxMind DB data format.
+func encodeValue(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case string:
+		encodeCtrl(buf, 2, len(v))
+		buf.WriteString(v)
+	case uint16:
+		encodeUint(buf, 5, uint64(v))
+	case uint32:
+		encodeUint(buf, 6, uint64(v))
+	case uint64:
+		encodeUint(buf, 9, v)
+	case map[string]any:
+		encodeCtrl(buf, 7, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+
+		slices.Sort(keys)
+		for _, k := range keys {
+			encodeValue(buf, k)
+			encodeValue(buf, v[k])
+		}
+	case []any:
+		encodeCtrl(buf, 11, len(v))
+		for _, e := range v {
+			encodeValue(buf, e)
+		}
+	default:
+		panic(fmt.Errorf("unsupported type %T", v))
+	}
+}
+
+// countryRecord returns the record of a country database.
+func countryRecord(key, code string) (rec map[string]any) {
+	return map[string]any{
+		key: map[string]any{
+			"geoname_id": uint32(1),
+			"iso_code":   code,
+			"names": map[string]any{
+				"en": "Country " + code,
+			},
+		},
+		"continent": map[string]any{
+			"code": "XX",
+		},
+		"traits": []any{"a", "b"},
+	}
+}
+
+// asnRecord returns the record of an ASN database.
+func asnRecord(asn uint32, org string) (rec map[string]any) {
+	return map[string]any{
+		"autonomous_system_number":       asn,
+		"autonomous_system_organization": org,
+	}
+}
+
+// newTestResolver returns a *Resolver with the test databases.
+func newTestResolver(t testing.TB) (r *geoip.Resolver) {
+	t.Helper()
+
+	cw := newMMDBWriter()
+	cw.insert(t, netip.MustParsePrefix("1.2.3.0/24"), countryRecord("country", "DE"))
+	cw.insert(t, netip.MustParsePrefix("1.2.4.0/24"), countryRecord("country", "DE"))
+	cw.insert(t, netip.MustParsePrefix("5.6.0.0/16"), countryRecord("registered_country", "RU"))
+	cw.insert(t, netip.MustParsePrefix("2001:db8::/32"), countryRecord("country", "US"))
+
+	aw := newMMDBWriter()
+	aw.insert(t, netip.MustParsePrefix("1.2.0.0/16"), asnRecord(3320, "Deutsche Telekom AG"))
+	aw.insert(t, netip.MustParsePrefix("9.9.9.0/24"), asnRecord(19281, "Quad9"))
+
+	r, err := geoip.New(&geoip.Config{
+		CountryDB: cw.write(t, "GeoLite2-Country"),
+		ASNDB:     aw.write(t, "GeoLite2-ASN"),
+	})
+	require.NoError(t, err)
+
+	return r
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	r := newTestResolver(t)
+
+	testCases := []struct {
+		want *geoip.IPInfo
+		name string
+		ip   netip.Addr
+	}{{
+		want: &geoip.IPInfo{
+			IP:      netip.MustParseAddr("1.2.3.4"),
+			Country: "DE",
+			ASOrg:   "Deutsche Telekom AG",
+			ASN:     3320,
+		},
+		name: "country_and_asn",
+		ip:   netip.MustParseAddr("1.2.3.4"),
+	}, {
+		want: &geoip.IPInfo{
+			IP:      netip.MustParseAddr("1.2.4.4"),
+			Country: "DE",
+			ASOrg:   "Deutsche Telekom AG",
+			ASN:     3320,
+		},
+		name: "pointer",
+		ip:   netip.MustParseAddr("1.2.4.4"),
+	}, {
+		want: &geoip.IPInfo{
+			IP:      netip.MustParseAddr("::ffff:5.6.7.8"),
+			Country: "RU",
+		},
+		name: "registered_country_mapped",
+		ip:   netip.MustParseAddr("::ffff:5.6.7.8"),
+	}, {
+		want: &geoip.IPInfo{
+			IP:    netip.MustParseAddr("9.9.9.9"),
+			ASOrg: "Quad9",
+			ASN:   19281,
+		},
+		name: "asn_only",
+		ip:   netip.MustParseAddr("9.9.9.9"),
+	}, {
+		want: &geoip.IPInfo{
+			IP:      netip.MustParseAddr("2001:db8::1"),
+			Country: "US",
+		},
+		name: "ipv6",
+		ip:   netip.MustParseAddr("2001:db8::1"),
+	}, {
+		want: nil,
+		name: "unknown",
+		ip:   netip.MustParseAddr("10.0.0.1"),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.Lookup(tc.ip))
+		})
+	}
+}
+
+func TestResolver_reload(t *testing.T) {
+	w := newMMDBWriter()
+	w.insert(t, netip.MustParsePrefix("1.2.3.0/24"), countryRecord("country", "DE"))
+	path := w.write(t, "GeoLite2-Country")
+
+	r, err := geoip.New(&geoip.Config{
+		CountryDB: path,
+		ReloadIvl: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	r.Start()
+	testutil.CleanupAndRequireSuccess(t, func() (err error) {
+		r.Close()
+
+		return nil
+	})
+
+	ip := netip.MustParseAddr("1.2.3.4")
+	require.NotNil(t, r.Lookup(ip))
+	assert.Equal(t, "DE", r.Lookup(ip).Country)
+
+	w = newMMDBWriter()
+	w.insert(t, netip.MustParsePrefix("1.2.3.0/24"), countryRecord("country", "FR"))
+	data, err := os.ReadFile(w.write(t, "GeoLite2-Country"))
+	require.NoError(t, err)
+
+	err = os.WriteFile(path, data, 0o644)
+	require.NoError(t, err)
+
+	// Make sure the modification time changes even on the file systems with
+	// the coarse timestamps.
+	future := time.Now().Add(time.Hour)
+	err = os.Chtimes(path, future, future)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() (ok bool) {
+		info := r.Lookup(ip)
+
+		return info != nil && info.Country == "FR"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNew_error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.mmdb")
+	err := os.WriteFile(path, []byte("not a database"), 0o644)
+	require.NoError(t, err)
+
+	_, err = geoip.New(&geoip.Config{
+		CountryDB: path,
+	})
+	wantErrMsg := fmt.Sprintf(`geoip: parsing database %q: no metadata marker`, path)
+	testutil.AssertErrorMsg(t, wantErrMsg, err)
+}
+
+func FuzzResolver_Lookup(f *testing.F) {
+	w := newMMDBWriter()
+	w.insert(f, netip.MustParsePrefix("1.2.3.0/24"), countryRecord("country", "DE"))
+	w.insert(f, netip.MustParsePrefix("1.2.4.0/24"), countryRecord("country", "DE"))
+	w.insert(f, netip.MustParsePrefix("2001:db8::/32"), asnRecord(3320, "Deutsche Telekom AG"))
+
+	seed, err := os.ReadFile(w.write(f, "GeoLite2-Country"))
+	require.NoError(f, err)
+
+	f.Add(seed)
+	f.Add(seed[:len(seed)/2])
+	f.Add([]byte("\xab\xcd\xefMaxMind.com"))
+
+	ips := []netip.Addr{
+		netip.MustParseAddr("1.2.3.4"),
+		netip.MustParseAddr("1.2.4.4"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("::1"),
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "fuzz.mmdb")
+		writeErr := os.WriteFile(path, data, 0o644)
+		require.NoError(t, writeErr)
+
+		r, newErr := geoip.New(&geoip.Config{
+			CountryDB: path,
+			ASNDB:     path,
+		})
+		if newErr != nil {
+			return
+		}
+
+		for _, ip := range ips {
+			info := r.Lookup(ip)
+			if info != nil {
+				assert.Equal(t, ip, info.IP)
+			}
+		}
+	})
+}
+
+func TestPolicy_Check(t *testing.T) {
+	p := &geoip.Policy{
+		BlockedCountries: []string{"ru", "CN"},
+		AllowedCountries: []string{"DE"},
+		BlockedASNs:      []uint32{3320, 64500},
+		AllowedASNs:      []uint32{13335},
+	}
+
+	err := p.Validate()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		info        *geoip.IPInfo
+		name        string
+		wantRule    string
+		wantBlocked bool
+	}{{
+		info:        &geoip.IPInfo{Country: "RU"},
+		name:        "blocked_country",
+		wantRule:    "country:RU",
+		wantBlocked: true,
+	}, {
+		info:        &geoip.IPInfo{Country: "FR", ASN: 64500},
+		name:        "blocked_asn",
+		wantRule:    "asn:AS64500",
+		wantBlocked: true,
+	}, {
+		info:        &geoip.IPInfo{Country: "DE", ASN: 3320},
+		name:        "allowed_country",
+		wantRule:    "",
+		wantBlocked: false,
+	}, {
+		info:        &geoip.IPInfo{Country: "CN", ASN: 13335},
+		name:        "allowed_asn",
+		wantRule:    "",
+		wantBlocked: false,
+	}, {
+		info:        &geoip.IPInfo{Country: "FR"},
+		name:        "not_blocked",
+		wantRule:    "",
+		wantBlocked: false,
+	}, {
+		info:        nil,
+		name:        "unknown",
+		wantRule:    "",
+		wantBlocked: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, blocked := p.Check(tc.info)
+			assert.Equal(t, tc.wantRule, rule)
+			assert.Equal(t, tc.wantBlocked, blocked)
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	p := &geoip.Policy{
+		BlockedCountries: []string{"RUS"},
+		BlockedASNs:      []uint32{0},
+	}
+
+	err := p.Validate()
+	testutil.AssertErrorMsg(t, "bad country code \"RUS\"\nasn must not be zero", err)
+}
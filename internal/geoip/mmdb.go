@@ -0,0 +1,598 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// metadataMarker is the sequence preceding the metadata section of an MMDB
+// file.
+//
+// See https://maxmind.github.io/MaxMind-DB/#database-metadata.
+const metadataMarker = "\xab\xcd\xefMaxMind.com"
+
+// dataSectionSep is the size of the separator between the search tree and the
+// data section.
+const dataSectionSep = 16
+
+// maxDataDepth is the maximum depth of the nested maps and arrays in the data
+// section, which protects from the malicious databases.
+const maxDataDepth = 32
+
+// maxDataValues is the maximum number of the values visited by a single
+// decoder, which protects from the malicious databases with the pointers
+// referring to the same containers many times.
+const maxDataValues = 1 << 16
+
+// MMDB data types.
+//
+// See https://maxmind.github.io/MaxMind-DB/#output-data-section.
+const (
+	typeExtended = 0
+	typePointer  = 1
+	typeString   = 2
+	typeDouble   = 3
+	typeBytes    = 4
+	typeUint16   = 5
+	typeUint32   = 6
+	typeMap      = 7
+	typeInt32    = 8
+	typeUint64   = 9
+	typeUint128  = 10
+	typeArray    = 11
+	typeBool     = 14
+	typeFloat    = 15
+)
+
+// mmdb is a MaxMind DB file loaded into memory.  It only supports the
+// operations required to look up the country and the ASN data.
+//
+// See https://maxmind.github.io/MaxMind-DB.
+type mmdb struct {
+	// tree is the binary search tree section.
+	tree []byte
+
+	// data is the data section.
+	data []byte
+
+	// dbType is the type of the database, for example "GeoLite2-Country".
+	dbType string
+
+	// nodeCount is the number of nodes in the search tree.
+	nodeCount uint32
+
+	// ipv4Start is the node, from which the IPv4 addresses are looked up in an
+	// IPv6 tree.
+	ipv4Start uint32
+
+	// recordSize is the size of a record in bits.
+	recordSize uint16
+
+	// ipVersion is either 4 or 6.
+	ipVersion uint16
+}
+
+// newMMDB parses the database from b.  b must not be modified afterwards.
+func newMMDB(b []byte) (db *mmdb, err error) {
+	i := bytes.LastIndex(b, []byte(metadataMarker))
+	if i < 0 {
+		return nil, errors.Error("no metadata marker")
+	}
+
+	d := &decoder{buf: b[i+len(metadataMarker):]}
+	v, _, err := d.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+
+	meta, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("metadata: got type %T, want map", v)
+	}
+
+	db = &mmdb{}
+	db.dbType, _ = meta["database_type"].(string)
+	nodeCount, _ := meta["node_count"].(uint64)
+	recordSize, _ := meta["record_size"].(uint64)
+	ipVersion, _ := meta["ip_version"].(uint64)
+
+	switch {
+	case recordSize != 24 && recordSize != 28 && recordSize != 32:
+		return nil, fmt.Errorf("unsupported record size %d", recordSize)
+	case ipVersion != 4 && ipVersion != 6:
+		return nil, fmt.Errorf("unsupported ip version %d", ipVersion)
+	case nodeCount > math.MaxUint32:
+		return nil, fmt.Errorf("node count %d is too large", nodeCount)
+	}
+
+	db.nodeCount = uint32(nodeCount)
+	db.recordSize = uint16(recordSize)
+	db.ipVersion = uint16(ipVersion)
+
+	treeSize := uint64(db.nodeCount) * recordSize / 4
+	if treeSize+dataSectionSep > uint64(i) {
+		return nil, fmt.Errorf("tree size %d is too large", treeSize)
+	}
+
+	db.tree = b[:treeSize]
+	db.data = b[treeSize+dataSectionSep : i]
+
+	if db.ipVersion == 6 {
+		db.ipv4Start, err = db.walk(0, make([]byte, 12), 96)
+		if err != nil {
+			return nil, fmt.Errorf("finding ipv4 subtree: %w", err)
+		}
+	}
+
+	return db, nil
+}
+
+// lookup returns the values at paths in the record associated with ip.  Only
+// the values on the paths are decoded.  vals[i] is nil if there is no scalar
+// value at paths[i].  vals is nil if there is no record for ip.
+func (db *mmdb) lookup(ip netip.Addr, paths ...[]string) (vals []any, err error) {
+	ip = ip.Unmap()
+
+	var node uint32
+	if ip.Is4() {
+		a := ip.As4()
+		node, err = db.walk(db.ipv4Start, a[:], 32)
+	} else if db.ipVersion == 6 {
+		a := ip.As16()
+		node, err = db.walk(0, a[:], 128)
+	} else {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	} else if node <= db.nodeCount {
+		// Either the address isn't in the database or the bits of the address
+		// are exhausted before reaching the data, which is the same.
+		return nil, nil
+	}
+
+	off := uint64(node) - uint64(db.nodeCount) - dataSectionSep
+	d := &decoder{buf: db.data}
+	vals = make([]any, len(paths))
+	for i, path := range paths {
+		vals[i], err = d.valueAt(off, path, 0)
+		if err != nil {
+			return nil, fmt.Errorf("decoding data at %d: %w", off, err)
+		}
+	}
+
+	return vals, nil
+}
+
+// walk walks the search tree from node following the first bitLen bits of
+// addr.  It returns the record it stopped at, which is either a node, which
+// means that the bits are exhausted, or the value greater than or equal to the
+// node count.
+func (db *mmdb) walk(node uint32, addr []byte, bitLen int) (rec uint32, err error) {
+	for i := 0; i < bitLen && node < db.nodeCount; i++ {
+		bit := (addr[i/8] >> (7 - i%8)) & 1
+		node, err = db.record(node, bit)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return node, nil
+}
+
+// record returns the left, if bit is zero, or the right record of node.
+func (db *mmdb) record(node uint32, bit byte) (rec uint32, err error) {
+	size := uint64(db.recordSize) / 4
+	off := uint64(node) * size
+	if off+size > uint64(len(db.tree)) {
+		return 0, fmt.Errorf("node %d is out of range", node)
+	}
+
+	b := db.tree[off : off+size]
+	switch db.recordSize {
+	case 24:
+		if bit == 0 {
+			return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+		}
+
+		return uint32(b[3])<<16 | uint32(b[4])<<8 | uint32(b[5]), nil
+	case 28:
+		if bit == 0 {
+			return uint32(b[3]&0xf0)<<20 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+		}
+
+		return uint32(b[3]&0x0f)<<24 | uint32(b[4])<<16 | uint32(b[5])<<8 | uint32(b[6]), nil
+	default:
+		if bit == 0 {
+			return binary.BigEndian.Uint32(b[:4]), nil
+		}
+
+		return binary.BigEndian.Uint32(b[4:]), nil
+	}
+}
+
+// decoder decodes the values from the data section of an MMDB file.  The
+// unsigned integers of any size are decoded as uint64, except for uint128,
+// which is decoded as []byte, the signed ones are decoded as int64, and the
+// floating-point ones as float64.
+type decoder struct {
+	buf []byte
+
+	// visited is the number of the values visited so far.
+	visited int
+}
+
+// visit checks the limits before visiting a value at depth.
+func (d *decoder) visit(depth int) (err error) {
+	if depth > maxDataDepth {
+		return errors.Error("data is nested too deeply")
+	}
+
+	d.visited++
+	if d.visited > maxDataValues {
+		return errors.Error("too many values")
+	}
+
+	return nil
+}
+
+// errUnexpectedEnd is returned when the data ends unexpectedly.
+const errUnexpectedEnd errors.Error = "unexpected end of data"
+
+// bytesAt returns n bytes starting at off.
+func (d *decoder) bytesAt(off, n uint64) (b []byte, err error) {
+	if off+n > uint64(len(d.buf)) || off+n < off {
+		return nil, errUnexpectedEnd
+	}
+
+	return d.buf[off : off+n], nil
+}
+
+// uintFrom returns the big-endian unsigned integer from b, which must not be
+// longer than 8 bytes.
+func uintFrom(b []byte) (n uint64) {
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+
+	return n
+}
+
+// decode decodes the value at off and returns it along with the offset of the
+// next value.
+func (d *decoder) decode(off uint64, depth int) (v any, next uint64, err error) {
+	err = d.visit(depth)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	typ, size, off, err := d.decodeCtrl(off)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		var ptr uint64
+		ptr, next, err = d.decodePointer(off, size)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		v, _, err = d.decode(ptr, depth+1)
+
+		return v, next, err
+	}
+
+	switch typ {
+	case typeMap:
+		return d.decodeMap(off, size, depth)
+	case typeArray:
+		return d.decodeArray(off, size, depth)
+	case typeBool:
+		return size != 0, off, nil
+	default:
+		return d.decodeScalar(typ, off, size)
+	}
+}
+
+// valueAt returns the scalar value at path in the nested maps starting at off.
+// v is nil if there is no such value or it's a map or an array.  Unlike
+// decode, it only decodes the keys of the maps and the value itself, skipping
+// everything else.
+func (d *decoder) valueAt(off uint64, path []string, depth int) (v any, err error) {
+	err = d.visit(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, size, off, err := d.decodeCtrl(off)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case typ == typePointer:
+		var ptr uint64
+		ptr, _, err = d.decodePointer(off, size)
+		if err != nil {
+			return nil, err
+		}
+
+		return d.valueAt(ptr, path, depth+1)
+	case len(path) == 0:
+		switch typ {
+		case typeMap, typeArray:
+			return nil, nil
+		case typeBool:
+			return size != 0, nil
+		default:
+			v, _, err = d.decodeScalar(typ, off, size)
+
+			return v, err
+		}
+	case typ != typeMap:
+		return nil, nil
+	}
+
+	for i := uint64(0); i < size; i++ {
+		var key []byte
+		key, off, err = d.keyAt(off, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("map key: %w", err)
+		}
+
+		if string(key) == path[0] {
+			return d.valueAt(off, path[1:], depth+1)
+		}
+
+		off, err = d.skip(off, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("map value for %q: %w", key, err)
+		}
+	}
+
+	return nil, nil
+}
+
+// keyAt returns the map key at off without copying it along with the offset of
+// the next value.
+func (d *decoder) keyAt(off uint64, depth int) (key []byte, next uint64, err error) {
+	err = d.visit(depth)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	typ, size, off, err := d.decodeCtrl(off)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		var ptr uint64
+		ptr, next, err = d.decodePointer(off, size)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		key, _, err = d.keyAt(ptr, depth+1)
+
+		return key, next, err
+	} else if typ != typeString {
+		return nil, 0, fmt.Errorf("got type %d, want string", typ)
+	}
+
+	key, err = d.bytesAt(off, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return key, off + size, nil
+}
+
+// skip returns the offset of the value following the one at off without
+// decoding the latter.
+func (d *decoder) skip(off uint64, depth int) (next uint64, err error) {
+	err = d.visit(depth)
+	if err != nil {
+		return 0, err
+	}
+
+	typ, size, off, err := d.decodeCtrl(off)
+	if err != nil {
+		return 0, err
+	}
+
+	switch typ {
+	case typePointer:
+		_, next, err = d.decodePointer(off, size)
+
+		return next, err
+	case typeMap, typeArray:
+		n := size
+		if typ == typeMap {
+			// Skip both the keys and the values.
+			n *= 2
+		}
+
+		for i := uint64(0); i < n; i++ {
+			off, err = d.skip(off, depth+1)
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		return off, nil
+	case typeBool:
+		return off, nil
+	default:
+		_, err = d.bytesAt(off, size)
+		if err != nil {
+			return 0, err
+		}
+
+		return off + size, nil
+	}
+}
+
+// decodeCtrl decodes the control byte and the extended type and size bytes at
+// off.  For pointers size is the raw size bits of the control byte.
+func (d *decoder) decodeCtrl(off uint64) (typ byte, size, next uint64, err error) {
+	b, err := d.bytesAt(off, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	ctrl := b[0]
+	off++
+
+	typ = ctrl >> 5
+	if typ == typeExtended {
+		b, err = d.bytesAt(off, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		typ = b[0] + 7
+		off++
+	}
+
+	size = uint64(ctrl & 0x1f)
+	if typ == typePointer || size < 29 {
+		return typ, size, off, nil
+	}
+
+	n := size - 28
+	b, err = d.bytesAt(off, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	switch n {
+	case 1:
+		size = 29 + uintFrom(b)
+	case 2:
+		size = 285 + uintFrom(b)
+	default:
+		size = 65821 + uintFrom(b)
+	}
+
+	return typ, size, off + n, nil
+}
+
+// decodePointer decodes the pointer at off with sizeBits from the control byte.
+func (d *decoder) decodePointer(off, sizeBits uint64) (ptr, next uint64, err error) {
+	n := (sizeBits>>3)&0x3 + 1
+	b, err := d.bytesAt(off, n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	val := sizeBits & 0x7
+	switch n {
+	case 1:
+		ptr = val<<8 | uintFrom(b)
+	case 2:
+		ptr = (val<<16 | uintFrom(b)) + 2048
+	case 3:
+		ptr = (val<<24 | uintFrom(b)) + 526336
+	default:
+		ptr = uintFrom(b)
+	}
+
+	return ptr, off + n, nil
+}
+
+// decodeMap decodes the map of size pairs at off.
+func (d *decoder) decodeMap(off, size uint64, depth int) (v any, next uint64, err error) {
+	m := make(map[string]any, min(size, 64))
+	for i := uint64(0); i < size; i++ {
+		var key, val any
+		key, off, err = d.decode(off, depth+1)
+		if err != nil {
+			return nil, 0, fmt.Errorf("map key: %w", err)
+		}
+
+		k, ok := key.(string)
+		if !ok {
+			return nil, 0, fmt.Errorf("map key: got type %T, want string", key)
+		}
+
+		val, off, err = d.decode(off, depth+1)
+		if err != nil {
+			return nil, 0, fmt.Errorf("map value for %q: %w", k, err)
+		}
+
+		m[k] = val
+	}
+
+	return m, off, nil
+}
+
+// decodeArray decodes the array of size elements at off.
+func (d *decoder) decodeArray(off, size uint64, depth int) (v any, next uint64, err error) {
+	a := make([]any, 0, min(size, 64))
+	for i := uint64(0); i < size; i++ {
+		var val any
+		val, off, err = d.decode(off, depth+1)
+		if err != nil {
+			return nil, 0, fmt.Errorf("array element %d: %w", i, err)
+		}
+
+		a = append(a, val)
+	}
+
+	return a, off, nil
+}
+
+// decodeScalar decodes the value of a non-container type typ of size bytes at
+// off.
+func (d *decoder) decodeScalar(typ byte, off, size uint64) (v any, next uint64, err error) {
+	b, err := d.bytesAt(off, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	next = off + size
+
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes, typeUint128:
+		return b, next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("bad double size %d", size)
+		}
+
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("bad float size %d", size)
+		}
+
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("bad unsigned integer size %d", size)
+		}
+
+		return uintFrom(b), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("bad signed integer size %d", size)
+		}
+
+		// Shift the value to the left and back to extend the sign.
+		shift := 32 - 8*size
+
+		return int64(int32(uintFrom(b)<<shift) >> shift), next, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
@@ -0,0 +1,109 @@
+package geoip
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// Policy defines which answers are blocked by the countries and the autonomous
+// systems of their IP addresses.  The allowed countries and ASNs are the
+// exceptions from the blocked ones.  The addresses with no information are
+// never blocked.
+type Policy struct {
+	// BlockedCountries are the ISO 3166-1 alpha-2 codes of the countries, the
+	// addresses from which are blocked.
+	BlockedCountries []string `yaml:"blocked_countries" json:"blocked_countries"`
+
+	// AllowedCountries are the ISO 3166-1 alpha-2 codes of the countries, the
+	// addresses from which are never blocked.
+	AllowedCountries []string `yaml:"allowed_countries" json:"allowed_countries"`
+
+	// BlockedASNs are the numbers of the autonomous systems, the addresses
+	// from which are blocked.
+	BlockedASNs []uint32 `yaml:"blocked_asns" json:"blocked_asns"`
+
+	// AllowedASNs are the numbers of the autonomous systems, the addresses from
+	// which are never blocked.
+	AllowedASNs []uint32 `yaml:"allowed_asns" json:"allowed_asns"`
+}
+
+// Validate returns an error if p is not valid.  It also normalizes the country
+// codes to the upper case.  A nil p is valid.
+func (p *Policy) Validate() (err error) {
+	if p == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, codes := range [][]string{p.BlockedCountries, p.AllowedCountries} {
+		for i, c := range codes {
+			c = strings.ToUpper(c)
+			if !isCountryCode(c) {
+				errs = append(errs, fmt.Errorf("bad country code %q", c))
+			}
+
+			codes[i] = c
+		}
+	}
+
+	for _, asns := range [][]uint32{p.BlockedASNs, p.AllowedASNs} {
+		if slices.Contains(asns, 0) {
+			errs = append(errs, errors.Error("asn must not be zero"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isCountryCode returns true if c is two uppercase ASCII letters.
+func isCountryCode(c string) (ok bool) {
+	return len(c) == 2 &&
+		c[0] >= 'A' && c[0] <= 'Z' &&
+		c[1] >= 'A' && c[1] <= 'Z'
+}
+
+// Clone returns a deep copy of p.  c is nil if p is nil.
+func (p *Policy) Clone() (c *Policy) {
+	if p == nil {
+		return nil
+	}
+
+	return &Policy{
+		BlockedCountries: slices.Clone(p.BlockedCountries),
+		AllowedCountries: slices.Clone(p.AllowedCountries),
+		BlockedASNs:      slices.Clone(p.BlockedASNs),
+		AllowedASNs:      slices.Clone(p.AllowedASNs),
+	}
+}
+
+// IsEmpty returns true if p doesn't block anything.
+func (p *Policy) IsEmpty() (ok bool) {
+	return p == nil || (len(p.BlockedCountries) == 0 && len(p.BlockedASNs) == 0)
+}
+
+// Check returns true if the address with info is blocked by p along with the
+// text of the rule describing the reason.  The rule is either "country:<code>"
+// or "asn:AS<number>".  info may be nil.
+func (p *Policy) Check(info *IPInfo) (rule string, blocked bool) {
+	if p.IsEmpty() || info == nil {
+		return "", false
+	}
+
+	if slices.Contains(p.AllowedCountries, info.Country) ||
+		slices.Contains(p.AllowedASNs, info.ASN) {
+		return "", false
+	}
+
+	if info.Country != "" && slices.Contains(p.BlockedCountries, info.Country) {
+		return "country:" + info.Country, true
+	}
+
+	if info.ASN != 0 && slices.Contains(p.BlockedASNs, info.ASN) {
+		return "asn:" + info.ASNString(), true
+	}
+
+	return "", false
+}
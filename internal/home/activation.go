@@ -0,0 +1,91 @@
+package home
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// initSockets receives the pre-bound sockets passed by the service manager,
+// if there are any.
+func initSockets() (err error) {
+	Context.sockets, err = socketact.FromEnv()
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	if names := Context.sockets.Names(); len(names) > 0 {
+		log.Info("socket activation: got sockets named %q", names)
+	}
+
+	return nil
+}
+
+// serveWeb serves srv on the pre-bound stream sockets with the given name, if
+// there are any, or on addrs otherwise.  If srv has a TLS configuration, it
+// serves HTTPS.  If the PROXY protocol is enabled, the headers from the trusted
+// proxies are accepted.  It returns the first error of serving.
+func serveWeb(srv *http.Server, name string, addrs []netip.AddrPort) (err error) {
+	isTLS := srv.TLSConfig != nil
+
+	config.RLock()
+	proxyProto := config.DNS.ProxyProtocol
+	config.RUnlock()
+
+	ls := Context.sockets.Listeners(name)
+	if len(ls) > 0 {
+		log.Info("web: serving activated sockets %q instead of %s", name, srv.Addr)
+	} else if len(addrs) <= 1 && !proxyProto.Enabled {
+		if isTLS {
+			return srv.ListenAndServeTLS("", "")
+		}
+
+		return srv.ListenAndServe()
+	} else {
+		ls, err = listenAll(addrs)
+		if err != nil {
+			// Don't wrap the error, because it's informative enough as is.
+			return err
+		}
+	}
+
+	errs := make(chan error, len(ls))
+	for _, l := range ls {
+		l = proxyProto.WrapListener(l)
+		go func(l net.Listener) {
+			defer log.OnPanic("web: serving listener")
+
+			if isTLS {
+				errs <- srv.ServeTLS(l, "", "")
+			} else {
+				errs <- srv.Serve(l)
+			}
+		}(l)
+	}
+
+	return <-errs
+}
+
+// listenAll listens for TCP connections on each of addrs.  If any of them
+// fails, it closes the ones already opened.
+func listenAll(addrs []netip.AddrPort) (ls []net.Listener, err error) {
+	for _, addr := range addrs {
+		var l net.Listener
+		l, err = net.Listen("tcp", addr.String())
+		if err != nil {
+			for _, opened := range ls {
+				_ = opened.Close()
+			}
+
+			return nil, err
+		}
+
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
@@ -1,11 +1,16 @@
 package home
 
 import (
+	"cmp"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,12 +24,38 @@ import (
 // sessionTokenSize is the length of session token in bytes.
 const sessionTokenSize = 16
 
+// maxUserAgentLen is the maximum length of the user agent stored within a
+// session.  Longer user agents are truncated.
+const maxUserAgentLen = 256
+
+// session is a single authenticated session of a web UI user.
 type session struct {
+	// ip is the IP address of the client which has created the session.  It
+	// is invalid for the sessions created before it was stored.
+	ip netip.Addr
+
 	userName string
+
+	// userAgent is the value of the User-Agent header of the login request.
+	userAgent string
+
 	// expire is the expiration time, in seconds.
 	expire uint32
+
+	// created is the creation time, in seconds.  It is zero for the sessions
+	// created before it was stored.
+	created uint32
+
+	// lastSeen is the time of the last request made within the session, in
+	// seconds.  The stored value is only updated once in a while to avoid
+	// writing the database on each request.
+	lastSeen uint32
 }
 
+// serialize encodes the user name and the expiration time of s into the
+// binary format of the sessions bucket.  The format must not be changed, since
+// the previous versions read it from the same bucket.  The other fields are
+// stored separately, see [session.serializeMeta].
 func (s *session) serialize() []byte {
 	const (
 		expireLen = 4
@@ -48,7 +79,64 @@ func (s *session) deserialize(data []byte) bool {
 	if len(data) < int(nameLen) {
 		return false
 	}
-	s.userName = string(data)
+	s.userName = string(data[:nameLen])
+	return true
+}
+
+// serializeMeta encodes the fields of s other than the user name and the
+// expiration time into the binary format of the session metadata bucket.
+func (s *session) serializeMeta() (data []byte) {
+	const (
+		createdLen  = 4
+		lastSeenLen = 4
+		ipLen       = 1
+		uaLen       = 2
+	)
+
+	ip := s.ip.AsSlice()
+	ua := s.userAgent[:min(len(s.userAgent), maxUserAgentLen)]
+
+	data = make([]byte, 0, createdLen+lastSeenLen+ipLen+len(ip)+uaLen+len(ua))
+	data = binary.BigEndian.AppendUint32(data, s.created)
+	data = binary.BigEndian.AppendUint32(data, s.lastSeen)
+	data = append(data, byte(len(ip)))
+	data = append(data, ip...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(ua)))
+	data = append(data, ua...)
+
+	return data
+}
+
+// deserializeMeta decodes the fields encoded by [session.serializeMeta].
+func (s *session) deserializeMeta(data []byte) (ok bool) {
+	if len(data) < 4+4+1 {
+		return false
+	}
+
+	s.created = binary.BigEndian.Uint32(data[0:4])
+	s.lastSeen = binary.BigEndian.Uint32(data[4:8])
+	ipLen := int(data[8])
+	data = data[9:]
+
+	if len(data) < ipLen+2 {
+		return false
+	}
+
+	if ipLen > 0 {
+		s.ip, ok = netip.AddrFromSlice(data[:ipLen])
+		if !ok {
+			return false
+		}
+	}
+
+	uaLen := int(binary.BigEndian.Uint16(data[ipLen : ipLen+2]))
+	data = data[ipLen+2:]
+	if len(data) < uaLen {
+		return false
+	}
+
+	s.userAgent = string(data[:uaLen])
+
 	return true
 }
 
@@ -113,6 +201,14 @@ func bucketName() []byte {
 	return []byte("sessions-2")
 }
 
+// metaBucketName returns the name of the bucket with the session metadata,
+// see [session.serializeMeta].  The metadata are kept apart from the sessions,
+// so that the previous versions, which don't know about them, could still read
+// the sessions.  The sessions without metadata are valid.
+func metaBucketName() []byte {
+	return []byte("sessions-meta")
+}
+
 // loadSessions loads sessions from the database file and removes expired
 // sessions.
 func (a *Auth) loadSessions() {
@@ -138,6 +234,8 @@ func (a *Auth) loadSessions() {
 		removed = 1
 	}
 
+	metaBkt := tx.Bucket(metaBucketName())
+
 	now := uint32(time.Now().UTC().Unix())
 	forEach := func(k, v []byte) error {
 		s := session{}
@@ -152,10 +250,17 @@ func (a *Auth) loadSessions() {
 			return nil
 		}
 
+		loadSessionMeta(metaBkt, k, &s)
+
 		a.sessions[hex.EncodeToString(k)] = &s
 		return nil
 	}
 	_ = bkt.ForEach(forEach)
+
+	// Remove the metadata of the expired sessions and the ones removed by the
+	// previous versions.
+	removed += a.removeOrphanMeta(metaBkt)
+
 	if removed != 0 {
 		err = tx.Commit()
 		if err != nil {
@@ -166,6 +271,57 @@ func (a *Auth) loadSessions() {
 	log.Debug("auth: loaded %d sessions from DB (removed %d expired)", len(a.sessions), removed)
 }
 
+// loadSessionMeta sets the metadata of the session stored with key k from
+// metaBkt, if there are any.  metaBkt may be nil.
+func loadSessionMeta(metaBkt *bbolt.Bucket, k []byte, s *session) {
+	if metaBkt == nil {
+		return
+	}
+
+	data := metaBkt.Get(k)
+	if data == nil {
+		// The session has been stored by a previous version.
+		return
+	}
+
+	if !s.deserializeMeta(data) {
+		log.Debug("auth: bad metadata of session %s", hex.EncodeToString(k))
+
+		s.ip, s.userAgent, s.created, s.lastSeen = netip.Addr{}, "", 0, 0
+	}
+}
+
+// removeOrphanMeta removes the metadata of the sessions which aren't loaded
+// from metaBkt and returns the number of the removed records.  metaBkt may be
+// nil.
+func (a *Auth) removeOrphanMeta(metaBkt *bbolt.Bucket) (removed int) {
+	if metaBkt == nil {
+		return 0
+	}
+
+	var orphans [][]byte
+	_ = metaBkt.ForEach(func(k, _ []byte) (err error) {
+		if _, ok := a.sessions[hex.EncodeToString(k)]; !ok {
+			orphans = append(orphans, slices.Clone(k))
+		}
+
+		return nil
+	})
+
+	for _, k := range orphans {
+		err := metaBkt.Delete(k)
+		if err != nil {
+			log.Error("auth: bbolt.Delete: %s", err)
+
+			continue
+		}
+
+		removed++
+	}
+
+	return removed
+}
+
 // addSession adds a new session to the list of sessions and saves it in the
 // database file.
 func (a *Auth) addSession(data []byte, s *session) {
@@ -204,6 +360,20 @@ func (a *Auth) storeSession(data []byte, s *session) bool {
 		return false
 	}
 
+	metaBkt, err := tx.CreateBucketIfNotExists(metaBucketName())
+	if err != nil {
+		log.Error("auth: bbolt.CreateBucketIfNotExists: %s", err)
+
+		return false
+	}
+
+	err = metaBkt.Put(data, s.serializeMeta())
+	if err != nil {
+		log.Error("auth: bbolt.Put: %s", err)
+
+		return false
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Error("auth: bbolt.Commit: %s", err)
@@ -241,6 +411,15 @@ func (a *Auth) removeSessionFromFile(sess []byte) {
 		return
 	}
 
+	if metaBkt := tx.Bucket(metaBucketName()); metaBkt != nil {
+		err = metaBkt.Delete(sess)
+		if err != nil {
+			log.Error("auth: bbolt.Delete: %s", err)
+
+			return
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		log.Error("auth: bbolt.Commit: %s", err)
@@ -289,6 +468,13 @@ func (a *Auth) checkSession(sess string) (res checkSessionResult) {
 		s.expire = newExpire
 	}
 
+	if now-s.lastSeen >= sessionLastSeenIvl {
+		// Store the last-seen time after the periods of inactivity.
+		update = true
+	}
+
+	s.lastSeen = now
+
 	if update {
 		key, _ := hex.DecodeString(sess)
 		if a.storeSession(key, s) {
@@ -299,6 +485,135 @@ func (a *Auth) checkSession(sess string) (res checkSessionResult) {
 	return checkSessionOK
 }
 
+// sessionLastSeenIvl is the minimum period of inactivity, in seconds, after
+// which the last-seen time of a session is written to the database.
+const sessionLastSeenIvl = 10 * 60
+
+// sessionInfo is the information about an active session.
+type sessionInfo struct {
+	// ip is the IP address of the client which has created the session.
+	ip netip.Addr
+
+	// id is the public identifier of the session.  See [sessionID].
+	id string
+
+	// token is the hex-encoded session token.  It must not be exposed.
+	token string
+
+	userName  string
+	userAgent string
+
+	created  time.Time
+	lastSeen time.Time
+	expire   time.Time
+}
+
+// sessionID returns the public identifier of the session with the given
+// hex-encoded token.  The identifier can't be used to restore the token, so it
+// is safe to show it to the users.
+func sessionID(token string) (id string) {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// unixTime converts the time in seconds into time.Time.  It returns a zero
+// time.Time for zero seconds.
+func unixTime(sec uint32) (t time.Time) {
+	if sec == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(int64(sec), 0).UTC()
+}
+
+// sessionsList returns the information about all the unexpired sessions sorted
+// by the creation time.
+func (a *Auth) sessionsList() (infos []*sessionInfo) {
+	now := uint32(time.Now().UTC().Unix())
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for token, s := range a.sessions {
+		if s.expire <= now {
+			continue
+		}
+
+		infos = append(infos, &sessionInfo{
+			ip:        s.ip,
+			id:        sessionID(token),
+			token:     token,
+			userName:  s.userName,
+			userAgent: s.userAgent,
+			created:   unixTime(s.created),
+			lastSeen:  unixTime(s.lastSeen),
+			expire:    unixTime(s.expire),
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b *sessionInfo) (res int) {
+		return cmp.Or(a.created.Compare(b.created), strings.Compare(a.id, b.id))
+	})
+
+	return infos
+}
+
+// removeSessionByID removes the session with the given public identifier.  ok
+// is false if there is no such session.
+func (a *Auth) removeSessionByID(id string) (ok bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for token := range a.sessions {
+		if sessionID(token) == id {
+			a.removeSessionLocked(token)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeUserSessions removes all the sessions of the user with the given name
+// and returns the number of the removed sessions.
+func (a *Auth) removeUserSessions(userName string) (n int) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for token, s := range a.sessions {
+		if s.userName == userName {
+			a.removeSessionLocked(token)
+			n++
+		}
+	}
+
+	return n
+}
+
+// removeSessionLocked removes the session from the active sessions and the
+// disk.  a.lock is expected to be locked.
+func (a *Auth) removeSessionLocked(token string) {
+	delete(a.sessions, token)
+	key, _ := hex.DecodeString(token)
+	a.removeSessionFromFile(key)
+}
+
+// hasSession returns true if there is an unexpired session with the given
+// hex-encoded token.  Unlike [Auth.checkSession], it doesn't update the
+// session.
+func (a *Auth) hasSession(sess string) (ok bool) {
+	now := uint32(time.Now().UTC().Unix())
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	s, ok := a.sessions[sess]
+
+	return ok && s.expire > now
+}
+
 // removeSession removes the session from the active sessions and the disk.
 func (a *Auth) removeSession(sess string) {
 	key, _ := hex.DecodeString(sess)
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
+	"net/netip"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
 )
 
 func TestNewSessionToken(t *testing.T) {
@@ -87,3 +89,119 @@ func TestAuth(t *testing.T) {
 
 	a.Close()
 }
+
+func TestSession_serialize(t *testing.T) {
+	s := &session{
+		ip:        netip.MustParseAddr("192.0.2.1"),
+		userName:  "name",
+		userAgent: "Mozilla/5.0",
+		expire:    3,
+		created:   1,
+		lastSeen:  2,
+	}
+
+	// The sessions bucket must keep the format of the previous versions, which
+	// read the user name till the end of the record.
+	data := s.serialize()
+	assert.Equal(t, []byte{0, 0, 0, 3, 0, 4, 'n', 'a', 'm', 'e'}, data)
+
+	got := &session{}
+	require.True(t, got.deserialize(data))
+	require.True(t, got.deserializeMeta(s.serializeMeta()))
+
+	assert.Equal(t, s, got)
+
+	// Truncated metadata.
+	got = &session{}
+	assert.False(t, got.deserializeMeta([]byte{0, 0, 0, 1, 0, 0}))
+}
+
+func TestAuth_loadSessions_meta(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "sessions.db")
+	a := InitAuth(fn, nil, 60, nil, nil)
+	require.NotNil(t, a)
+
+	expire := uint32(time.Now().Add(time.Hour).Unix())
+	s := &session{
+		ip:        netip.MustParseAddr("192.0.2.1"),
+		userName:  "name",
+		userAgent: "Mozilla/5.0",
+		expire:    expire,
+		created:   1,
+		lastSeen:  2,
+	}
+
+	key, err := newSessionToken()
+	require.NoError(t, err)
+
+	orphanKey, err := newSessionToken()
+	require.NoError(t, err)
+
+	a.addSession(key, s)
+	a.addSession(orphanKey, &session{userName: "name", expire: expire})
+
+	// Emulate a previous version removing a session without its metadata.
+	err = a.db.Update(func(tx *bbolt.Tx) (uErr error) {
+		return tx.Bucket(bucketName()).Delete(orphanKey)
+	})
+	require.NoError(t, err)
+
+	a.Close()
+
+	a = InitAuth(fn, nil, 60, nil, nil)
+	require.NotNil(t, a)
+	t.Cleanup(a.Close)
+
+	assert.Equal(t, map[string]*session{hex.EncodeToString(key): s}, a.sessions)
+
+	err = a.db.View(func(tx *bbolt.Tx) (vErr error) {
+		assert.Nil(t, tx.Bucket(metaBucketName()).Get(orphanKey))
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAuth_sessions(t *testing.T) {
+	a := InitAuth(filepath.Join(t.TempDir(), "sessions.db"), nil, 60, nil, nil)
+	t.Cleanup(a.Close)
+
+	expire := uint32(time.Now().Add(time.Hour).Unix())
+	addSession := func(userName string, created uint32) (token string) {
+		sess, err := newSessionToken()
+		require.NoError(t, err)
+
+		a.addSession(sess, &session{
+			ip:        netip.MustParseAddr("192.0.2.1"),
+			userName:  userName,
+			userAgent: "test",
+			expire:    expire,
+			created:   created,
+		})
+
+		return hex.EncodeToString(sess)
+	}
+
+	tokA1 := addSession("a", 1)
+	tokA2 := addSession("a", 2)
+	tokB := addSession("b", 3)
+
+	infos := a.sessionsList()
+	require.Len(t, infos, 3)
+
+	assert.Equal(t, tokA1, infos[0].token)
+	assert.Equal(t, sessionID(tokA1), infos[0].id)
+	assert.Equal(t, "a", infos[0].userName)
+	assert.Equal(t, "test", infos[0].userAgent)
+	assert.Equal(t, time.Unix(1, 0).UTC(), infos[0].created)
+
+	require.True(t, a.removeSessionByID(sessionID(tokB)))
+	assert.False(t, a.removeSessionByID(sessionID(tokB)))
+	assert.Equal(t, checkSessionNotFound, a.checkSession(tokB))
+
+	assert.Equal(t, 2, a.removeUserSessions("a"))
+	assert.Equal(t, checkSessionNotFound, a.checkSession(tokA1))
+	assert.Equal(t, checkSessionNotFound, a.checkSession(tokA2))
+
+	assert.Empty(t, a.sessionsList())
+}
@@ -31,8 +31,14 @@ type loginJSON struct {
 	Password string `json:"password"`
 }
 
-// newCookie creates a new authentication cookie.
-func (a *Auth) newCookie(req loginJSON, addr string) (c *http.Cookie, err error) {
+// newCookie creates a new authentication cookie.  addr is the address used for
+// rate limiting.  userAgent and ip are stored within the session.
+func (a *Auth) newCookie(
+	req loginJSON,
+	addr string,
+	userAgent string,
+	ip netip.Addr,
+) (c *http.Cookie, err error) {
 	rateLimiter := a.rateLimiter
 	u, ok := a.findUser(req.Name, req.Password)
 	if !ok {
@@ -53,10 +59,15 @@ func (a *Auth) newCookie(req loginJSON, addr string) (c *http.Cookie, err error)
 	}
 
 	now := time.Now().UTC()
+	nowSec := uint32(now.Unix())
 
 	a.addSession(sess, &session{
-		userName: u.Name,
-		expire:   uint32(now.Unix()) + a.sessionTTL,
+		ip:        ip,
+		userName:  u.Name,
+		userAgent: userAgent,
+		expire:    nowSec + a.sessionTTL,
+		created:   nowSec,
+		lastSeen:  nowSec,
 	})
 
 	return &http.Cookie{
@@ -176,7 +187,12 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		log.Error("auth: getting real ip from request with remote ip %s: %s", remoteIP, err)
 	}
 
-	cookie, err := Context.auth.newCookie(req, remoteIP)
+	cookie, err := Context.auth.newCookie(
+		req,
+		remoteIP,
+		r.Header.Get(httphdr.UserAgent),
+		sessionIP(remoteIP, ip, Context.auth.trustedProxies),
+	)
 	if err != nil {
 		logIP := remoteIP
 		if Context.auth.trustedProxies.Contains(ip.Unmap()) {
@@ -191,6 +207,7 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	log.Info("auth: user %q successfully logged in from ip %s", req.Name, ip)
 
 	http.SetCookie(w, cookie)
+	http.SetCookie(w, newCSRFCookie(cookie.Value, cookie.Expires))
 
 	h := w.Header()
 	h.Set(httphdr.CacheControl, "no-store, no-cache, must-revalidate, proxy-revalidate")
@@ -200,6 +217,22 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 	aghhttp.OK(w)
 }
 
+// sessionIP returns the IP address of the client to store within the session.
+// realIP is only used if the request comes from one of the trusted proxies.
+func sessionIP(remoteIP string, realIP netip.Addr, trusted netutil.SubnetSet) (ip netip.Addr) {
+	ip, err := netip.ParseAddr(remoteIP)
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	ip = ip.Unmap()
+	if realIP.IsValid() && trusted != nil && trusted.Contains(ip) {
+		return realIP.Unmap()
+	}
+
+	return ip
+}
+
 // handleLogout is the handler for the GET /control/logout HTTP API.
 func handleLogout(w http.ResponseWriter, r *http.Request) {
 	respHdr := w.Header()
@@ -225,8 +258,17 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 		SameSite: http.SameSiteLaxMode,
 	}
 
+	csrfCookie := &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Unix(0, 0),
+		SameSite: http.SameSiteStrictMode,
+	}
+
 	respHdr.Set(httphdr.Location, "/login.html")
 	respHdr.Set(httphdr.SetCookie, c.String())
+	respHdr.Add(httphdr.SetCookie, csrfCookie.String())
 	w.WriteHeader(http.StatusFound)
 }
 
@@ -234,6 +276,9 @@ func handleLogout(w http.ResponseWriter, r *http.Request) {
 func RegisterAuthHandlers() {
 	Context.mux.Handle("/control/login", postInstallHandler(ensureHandler(http.MethodPost, handleLogin)))
 	httpRegister(http.MethodGet, "/control/logout", handleLogout)
+	httpRegister(http.MethodGet, "/control/sessions", handleSessionsList)
+	httpRegister(http.MethodPost, "/control/sessions/revoke", handleSessionsRevoke)
+	httpRegister(http.MethodPost, "/control/sessions/revoke_all", handleSessionsRevokeAll)
 }
 
 // optionalAuthThird returns true if a user should authenticate first.
@@ -262,7 +307,9 @@ func optionalAuthThird(w http.ResponseWriter, r *http.Request) (mustAuth bool) {
 	} else {
 		res := Context.auth.checkSession(cookie.Value)
 		isAuthenticated = res == checkSessionOK
-		if !isAuthenticated {
+		if isAuthenticated {
+			setCSRFCookie(w, r, cookie.Value)
+		} else {
 			log.Debug("%s: invalid cookie value: %q", pref, cookie)
 		}
 	}
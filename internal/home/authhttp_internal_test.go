@@ -68,7 +68,12 @@ func TestAuthHTTP(t *testing.T) {
 	assert.True(t, handlerCalled)
 
 	// perform login
-	cookie, err := Context.auth.newCookie(loginJSON{Name: "name", Password: "password"}, "")
+	cookie, err := Context.auth.newCookie(
+		loginJSON{Name: "name", Password: "password"},
+		"",
+		"",
+		netip.Addr{},
+	)
 	require.NoError(t, err)
 	require.NotNil(t, cookie)
 
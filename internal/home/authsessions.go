@@ -0,0 +1,154 @@
+package home
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// sessionJSON is the JSON representation of an active web UI session.
+type sessionJSON struct {
+	// ID is the public identifier of the session.
+	ID string `json:"id"`
+
+	// UserName is the name of the user who owns the session.
+	UserName string `json:"user_name"`
+
+	// IP is the IP address of the client which has created the session.  It
+	// is empty for the sessions created by the previous versions.
+	IP string `json:"ip,omitempty"`
+
+	// UserAgent is the user agent of the client which has created the
+	// session.
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// Created is the creation time of the session in RFC 3339 format.  It is
+	// empty for the sessions created by the previous versions.
+	Created string `json:"created,omitempty"`
+
+	// LastSeen is the time of the last request made within the session in RFC
+	// 3339 format.
+	LastSeen string `json:"last_seen,omitempty"`
+
+	// Expires is the expiration time of the session in RFC 3339 format.
+	Expires string `json:"expires"`
+
+	// Current is true if the session is the one used to make the request.
+	Current bool `json:"current"`
+}
+
+// sessionsListJSON is the response to the GET /control/sessions HTTP API.
+type sessionsListJSON struct {
+	Sessions []*sessionJSON `json:"sessions"`
+}
+
+// formatSessionTime formats t for the HTTP API.  It returns an empty string for
+// a zero t.
+func formatSessionTime(t time.Time) (s string) {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// handleSessionsList is the handler for the GET /control/sessions HTTP API.
+func handleSessionsList(w http.ResponseWriter, r *http.Request) {
+	var curToken string
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		curToken = c.Value
+	}
+
+	infos := Context.auth.sessionsList()
+	resp := &sessionsListJSON{
+		Sessions: make([]*sessionJSON, 0, len(infos)),
+	}
+
+	for _, si := range infos {
+		s := &sessionJSON{
+			ID:        si.id,
+			UserName:  si.userName,
+			UserAgent: si.userAgent,
+			Created:   formatSessionTime(si.created),
+			LastSeen:  formatSessionTime(si.lastSeen),
+			Expires:   formatSessionTime(si.expire),
+			Current:   si.token == curToken,
+		}
+
+		if si.ip.IsValid() {
+			s.IP = si.ip.String()
+		}
+
+		resp.Sessions = append(resp.Sessions, s)
+	}
+
+	aghhttp.WriteJSONResponseOK(w, r, resp)
+}
+
+// sessionRevokeReq is the request to the POST /control/sessions/revoke HTTP
+// API.
+type sessionRevokeReq struct {
+	ID string `json:"id"`
+}
+
+// handleSessionsRevoke is the handler for the POST /control/sessions/revoke
+// HTTP API.
+func handleSessionsRevoke(w http.ResponseWriter, r *http.Request) {
+	req := &sessionRevokeReq{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "json decode: %s", err)
+
+		return
+	}
+
+	if !Context.auth.removeSessionByID(req.ID) {
+		aghhttp.Error(r, w, http.StatusNotFound, "session %q not found", req.ID)
+
+		return
+	}
+
+	log.Info("auth: revoked session %s", req.ID)
+
+	aghhttp.OK(w)
+}
+
+// sessionsRevokeAllReq is the request to the POST /control/sessions/revoke_all
+// HTTP API.
+type sessionsRevokeAllReq struct {
+	UserName string `json:"user_name"`
+}
+
+// sessionsRevokeAllResp is the response to the POST
+// /control/sessions/revoke_all HTTP API.
+type sessionsRevokeAllResp struct {
+	// Revoked is the number of the revoked sessions.
+	Revoked int `json:"revoked"`
+}
+
+// handleSessionsRevokeAll is the handler for the POST
+// /control/sessions/revoke_all HTTP API.
+func handleSessionsRevokeAll(w http.ResponseWriter, r *http.Request) {
+	req := &sessionsRevokeAllReq{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "json decode: %s", err)
+
+		return
+	} else if req.UserName == "" {
+		aghhttp.Error(r, w, http.StatusBadRequest, "user_name: empty value")
+
+		return
+	}
+
+	n := Context.auth.removeUserSessions(req.UserName)
+
+	log.Info("auth: revoked %d sessions of user %q", n, req.UserName)
+
+	aghhttp.WriteJSONResponseOK(w, r, &sessionsRevokeAllResp{
+		Revoked: n,
+	})
+}
@@ -0,0 +1,208 @@
+package home
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/updater"
+	"github.com/AdguardTeam/AdGuardHome/internal/version"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/httphdr"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// autoUpdateCheckIvl is the interval between the checks of the maintenance
+// window.
+const autoUpdateCheckIvl = 5 * time.Minute
+
+// autoUpdate periodically checks if the current time is within the maintenance
+// window and, if so, installs the available update and restarts AdGuard Home.
+// It is intended to be used as a goroutine.
+func autoUpdate(upd *updater.Updater, execPath string, runningAsService bool) {
+	defer log.OnPanic("update: auto")
+
+	log.Info("update: automatic updates are enabled")
+
+	ticker := time.NewTicker(autoUpdateCheckIvl)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if tryAutoUpdate(upd, now) {
+			finishUpdate(context.Background(), execPath, runningAsService)
+
+			return
+		}
+	}
+}
+
+// tryAutoUpdate installs the available update if now is within the maintenance
+// window.  updated is true if the update has been installed and AdGuard Home
+// must be restarted.
+func tryAutoUpdate(upd *updater.Updater, now time.Time) (updated bool) {
+	var inWindow bool
+	func() {
+		config.RLock()
+		defer config.RUnlock()
+
+		inWindow = config.Update.MaintenanceWindow.Contains(now)
+	}()
+
+	if !inWindow {
+		return false
+	}
+
+	info, err := upd.VersionInfo(false)
+	if err != nil {
+		log.Error("update: auto: getting version info: %s", err)
+
+		return false
+	}
+
+	resp := &versionResponse{
+		VersionInfo: info,
+	}
+
+	err = resp.setAllowedToAutoUpdate()
+	if err != nil {
+		log.Error("update: auto: %s", err)
+
+		return false
+	}
+
+	newVer := upd.NewVersion()
+	if resp.CanAutoUpdate != aghalg.NBTrue || newVer == "" {
+		return false
+	}
+
+	curVer := version.Version()
+	notifyUpdate(updateEventStarted, curVer, newVer)
+
+	func() {
+		Context.controlLock.Lock()
+		defer Context.controlLock.Unlock()
+
+		err = upd.Update(false)
+	}()
+	if err != nil {
+		log.Error("update: auto: %s", err)
+		notifyUpdate(updateEventFailed, curVer, newVer)
+
+		return false
+	}
+
+	return true
+}
+
+// updateEvent is the type of an update notification event.
+type updateEvent string
+
+// updateEvent constants.
+const (
+	// updateEventStarted means that an automatic update is about to be
+	// installed.
+	updateEventStarted updateEvent = "update_started"
+
+	// updateEventFailed means that an automatic update couldn't be installed.
+	updateEventFailed updateEvent = "update_failed"
+
+	// updateEventFinished means that the updated version has started and
+	// become healthy.
+	updateEventFinished updateEvent = "update_finished"
+
+	// updateEventRolledBack means that the updated version hasn't become
+	// healthy and the previous version has been restored.
+	updateEventRolledBack updateEvent = "update_rolled_back"
+)
+
+// updateNotification is the JSON body of an update notification.
+type updateNotification struct {
+	// Time is the time of the event.
+	Time time.Time `json:"time"`
+
+	// Event is the type of the event.
+	Event updateEvent `json:"event"`
+
+	// PrevVersion is the version of AdGuard Home before the update.
+	PrevVersion string `json:"prev_version"`
+
+	// NewVersion is the version of AdGuard Home the update installs.
+	NewVersion string `json:"new_version"`
+}
+
+// notifyTimeout is the timeout for sending an update notification.
+const notifyTimeout = 30 * time.Second
+
+// notifyUpdate writes the update event to the log and sends it to the
+// configured notification URL, if any.
+func notifyUpdate(ev updateEvent, prevVer, newVer string) {
+	log.Info("update: %s: from %s to %s", ev, prevVer, newVer)
+
+	var notifyURL string
+	func() {
+		config.RLock()
+		defer config.RUnlock()
+
+		if config.Update != nil {
+			notifyURL = config.Update.NotifyURL
+		}
+	}()
+
+	if notifyURL == "" {
+		return
+	}
+
+	err := sendUpdateNotification(notifyURL, &updateNotification{
+		Time:        time.Now(),
+		Event:       ev,
+		PrevVersion: prevVer,
+		NewVersion:  newVer,
+	})
+	if err != nil {
+		log.Error("update: sending notification to %q: %s", notifyURL, err)
+	}
+}
+
+// sendUpdateNotification sends n to notifyURL as JSON.  It doesn't use
+// [httpClient], since the DNS server may not be running at the moment, for
+// example when the update is rolled back.
+func sendUpdateNotification(notifyURL string, n *updateNotification) (err error) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("encoding notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notifyURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set(httphdr.ContentType, aghhttp.HdrValApplicationJSON)
+	req.Header.Set(httphdr.UserAgent, aghhttp.UserAgent())
+
+	cli := &http.Client{
+		Transport: &http.Transport{
+			Proxy: httpProxy,
+		},
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer func() { err = errors.WithDeferred(err, resp.Body.Close()) }()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
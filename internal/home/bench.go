@@ -0,0 +1,44 @@
+package home
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/bench"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// benchCmdName is the name of the benchmark subcommand.
+const benchCmdName = "bench"
+
+// cmdlineBench runs the benchmark subcommand and exits, if the first
+// command-line argument is [benchCmdName].  Otherwise, it does nothing.  The
+// benchmark is stopped on SIGINT or SIGTERM, and the report for the queries
+// sent so far is printed.
+func cmdlineBench(args []string) {
+	if len(args) < 2 || args[1] != benchCmdName {
+		return
+	}
+
+	err := runBench(args[0], args[2:])
+	if err == nil || errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+
+	log.Error("%s", err)
+
+	os.Exit(1)
+}
+
+// runBench runs the benchmark with the subcommand arguments and prints the
+// report to stdout.
+func runBench(cmdName string, args []string) (err error) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	return bench.Main(ctx, cmdName, args, os.Stdout)
+}
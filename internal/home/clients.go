@@ -15,6 +15,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
 	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/AdguardTeam/dnsproxy/proxy"
@@ -58,6 +59,10 @@ type clientsContainer struct {
 
 	allTags *container.MapSet[string]
 
+	// tagBlockingModes are the blocking modes of the clients by their tags.  It
+	// must not be modified after initialization.
+	tagBlockingModes map[string]filtering.BlockingMode
+
 	// dhcp is the DHCP service implementation.
 	dhcp DHCP
 
@@ -100,6 +105,7 @@ func (clients *clientsContainer) Init(
 	etcHosts *aghnet.HostsContainer,
 	arpDB arpdb.Interface,
 	filteringConf *filtering.Config,
+	tagBlockingModes map[string]filtering.BlockingMode,
 ) (err error) {
 	if clients.list != nil {
 		log.Fatal("clients.list != nil")
@@ -112,6 +118,13 @@ func (clients *clientsContainer) Init(
 
 	clients.allTags = container.NewMapSet(clientTags...)
 
+	err = validateTagBlockingModes(tagBlockingModes, clients.allTags, filteringConf)
+	if err != nil {
+		return fmt.Errorf("clients: %w", err)
+	}
+
+	clients.tagBlockingModes = tagBlockingModes
+
 	// TODO(e.burkov):  Use [dhcpsvc] implementation when it's ready.
 	clients.dhcp = dhcpServer
 
@@ -143,6 +156,46 @@ func (clients *clientsContainer) Init(
 	return nil
 }
 
+// validateTagBlockingModes returns an error if modes contain unknown tags or
+// invalid blocking modes.  filteringConf contains the global blocking IP
+// addresses.
+func validateTagBlockingModes(
+	modes map[string]filtering.BlockingMode,
+	allTags *container.MapSet[string],
+	filteringConf *filtering.Config,
+) (err error) {
+	var errs []error
+	for tag, mode := range modes {
+		if !allTags.Has(tag) {
+			errs = append(errs, fmt.Errorf("tag_blocking_modes: unknown tag %q", tag))
+		} else if err = filtering.ValidateBlockingModeOverride(
+			mode,
+			filteringConf.BlockingIPv4,
+			filteringConf.BlockingIPv6,
+		); err != nil {
+			errs = append(errs, fmt.Errorf("tag_blocking_modes: tag %q: %w", tag, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// blockingMode returns the blocking mode of c: its own one or the one of the
+// first of its tags, which has it.  mode is empty if there is none.
+func (clients *clientsContainer) blockingMode(c *client.Persistent) (mode filtering.BlockingMode) {
+	if c.BlockingMode != "" {
+		return c.BlockingMode
+	}
+
+	for _, tag := range c.Tags {
+		if mode = clients.tagBlockingModes[tag]; mode != "" {
+			return mode
+		}
+	}
+
+	return ""
+}
+
 // handleHostsUpdates receives the updates from the hosts container and adds
 // them to the clients container.  It is intended to be used as a goroutine.
 func (clients *clientsContainer) handleHostsUpdates() {
@@ -185,6 +238,14 @@ type clientObject struct {
 	// BlockedServices is the configuration of blocked services of a client.
 	BlockedServices *filtering.BlockedServices `yaml:"blocked_services"`
 
+	// GeoIP is the GeoIP filtering policy of the client.  If nil, the global
+	// one is used.
+	GeoIP *geoip.Policy `yaml:"geoip"`
+
+	// BlockingMode is the blocking mode of the client.  If empty, the one of
+	// the client's tags, of the filter list, or the global one is used.
+	BlockingMode filtering.BlockingMode `yaml:"blocking_mode,omitempty"`
+
 	Name string `yaml:"name"`
 
 	IDs       []string `yaml:"ids"`
@@ -210,6 +271,13 @@ type clientObject struct {
 
 	IgnoreQueryLog   bool `yaml:"ignore_querylog"`
 	IgnoreStatistics bool `yaml:"ignore_statistics"`
+
+	// IgnoreAnomalyDetection defines if the requests of the client are exempt
+	// from the anomaly detection.
+	IgnoreAnomalyDetection bool `yaml:"ignore_anomaly_detection"`
+
+	// UseDNS64 defines if DNS64 is enabled for the client.
+	UseDNS64 bool `yaml:"use_dns64"`
 }
 
 // toPersistent returns an initialized persistent client if there are no errors.
@@ -224,16 +292,18 @@ func (o *clientObject) toPersistent(
 
 		UID: o.UID,
 
-		UseOwnSettings:        !o.UseGlobalSettings,
-		FilteringEnabled:      o.FilteringEnabled,
-		ParentalEnabled:       o.ParentalEnabled,
-		SafeSearchConf:        o.SafeSearchConf,
-		SafeBrowsingEnabled:   o.SafeBrowsingEnabled,
-		UseOwnBlockedServices: !o.UseGlobalBlockedServices,
-		IgnoreQueryLog:        o.IgnoreQueryLog,
-		IgnoreStatistics:      o.IgnoreStatistics,
-		UpstreamsCacheEnabled: o.UpstreamsCacheEnabled,
-		UpstreamsCacheSize:    o.UpstreamsCacheSize,
+		UseOwnSettings:         !o.UseGlobalSettings,
+		FilteringEnabled:       o.FilteringEnabled,
+		ParentalEnabled:        o.ParentalEnabled,
+		SafeSearchConf:         o.SafeSearchConf,
+		SafeBrowsingEnabled:    o.SafeBrowsingEnabled,
+		UseOwnBlockedServices:  !o.UseGlobalBlockedServices,
+		IgnoreQueryLog:         o.IgnoreQueryLog,
+		IgnoreStatistics:       o.IgnoreStatistics,
+		IgnoreAnomalyDetection: o.IgnoreAnomalyDetection,
+		UpstreamsCacheEnabled:  o.UpstreamsCacheEnabled,
+		UpstreamsCacheSize:     o.UpstreamsCacheSize,
+		UseDNS64:               o.UseDNS64,
 	}
 
 	err = cli.SetIDs(o.IDs)
@@ -252,6 +322,7 @@ func (o *clientObject) toPersistent(
 		o.SafeSearchConf.CustomResolver = safeSearchResolver{}
 
 		err = cli.SetSafeSearch(
+			Context.logs.logger(logModuleFiltering),
 			o.SafeSearchConf,
 			filteringConf.SafeSearchCacheSize,
 			time.Minute*time.Duration(filteringConf.CacheTime),
@@ -268,6 +339,24 @@ func (o *clientObject) toPersistent(
 
 	cli.BlockedServices = o.BlockedServices.Clone()
 
+	err = o.GeoIP.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("init geoip policy %q: %w", cli.Name, err)
+	}
+
+	cli.GeoIP = o.GeoIP.Clone()
+
+	err = filtering.ValidateBlockingModeOverride(
+		o.BlockingMode,
+		filteringConf.BlockingIPv4,
+		filteringConf.BlockingIPv6,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("init blocking mode %q: %w", cli.Name, err)
+	}
+
+	cli.BlockingMode = o.BlockingMode
+
 	cli.SetTags(o.Tags, allTags)
 
 	return cli, nil
@@ -308,6 +397,10 @@ func (clients *clientsContainer) forConfig() (objs []*clientObject) {
 
 			BlockedServices: cli.BlockedServices.Clone(),
 
+			GeoIP: cli.GeoIP.Clone(),
+
+			BlockingMode: cli.BlockingMode,
+
 			IDs:       cli.IDs(),
 			Tags:      slices.Clone(cli.Tags),
 			Upstreams: slices.Clone(cli.Upstreams),
@@ -322,8 +415,10 @@ func (clients *clientsContainer) forConfig() (objs []*clientObject) {
 			UseGlobalBlockedServices: !cli.UseOwnBlockedServices,
 			IgnoreQueryLog:           cli.IgnoreQueryLog,
 			IgnoreStatistics:         cli.IgnoreStatistics,
+			IgnoreAnomalyDetection:   cli.IgnoreAnomalyDetection,
 			UpstreamsCacheEnabled:    cli.UpstreamsCacheEnabled,
 			UpstreamsCacheSize:       cli.UpstreamsCacheSize,
+			UseDNS64:                 cli.UseDNS64,
 		}
 
 		objs = append(objs, o)
@@ -492,7 +587,7 @@ func (clients *clientsContainer) UpstreamConfigByID(
 	}
 
 	var upsConf *proxy.UpstreamConfig
-	upsConf, err = proxy.ParseUpstreamsConfig(
+	upsConf, err = dnsforward.ParseUpstreamsConfig(
 		upstreams,
 		&upstream.Options{
 			Bootstrap:    bootstrap,
@@ -500,6 +595,8 @@ func (clients *clientsContainer) UpstreamConfigByID(
 			HTTPVersions: dnsforward.UpstreamHTTPVersions(config.DNS.UseHTTP3Upstreams),
 			PreferIPv6:   config.DNS.BootstrapPreferIPv6,
 		},
+		Context.recursor,
+		Context.upsProxies,
 	)
 	if err != nil {
 		// Don't wrap the error since it's informative enough as is.
@@ -605,7 +702,13 @@ func (clients *clientsContainer) check(c *client.Persistent) (err error) {
 	// TODO(s.chzhen):  Move to the constructor.
 	slices.Sort(c.Tags)
 
-	_, err = proxy.ParseUpstreamsConfig(c.Upstreams, &upstream.Options{})
+	upsConf, err := dnsforward.ParseUpstreamsConfig(
+		c.Upstreams,
+		&upstream.Options{},
+		nil,
+		Context.upsProxies,
+	)
+	err = errors.WithDeferred(err, upsConf.Close())
 	if err != nil {
 		return fmt.Errorf("invalid upstream servers: %w", err)
 	}
@@ -46,7 +46,7 @@ func newClientsContainer(t *testing.T) (c *clientsContainer) {
 		OnMACBy:  func(ip netip.Addr) (mac net.HardwareAddr) { return nil },
 	}
 
-	require.NoError(t, c.Init(nil, dhcp, nil, nil, &filtering.Config{}))
+	require.NoError(t, c.Init(nil, dhcp, nil, nil, &filtering.Config{}, nil))
 
 	return c
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/client"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 )
@@ -38,6 +39,14 @@ type clientJSON struct {
 	// Schedule is blocked services schedule for every day of the week.
 	Schedule *schedule.Weekly `json:"blocked_services_schedule"`
 
+	// GeoIP is the GeoIP filtering policy of the client.  If nil, the global
+	// one is used.
+	GeoIP *geoip.Policy `json:"geoip,omitempty"`
+
+	// BlockingMode is the blocking mode of the client.  If empty, the one of
+	// the client's tags, of the filter list, or the global one is used.
+	BlockingMode *filtering.BlockingMode `json:"blocking_mode,omitempty"`
+
 	Name string `json:"name"`
 
 	// BlockedServices is the names of blocked services.
@@ -57,8 +66,12 @@ type clientJSON struct {
 	IgnoreQueryLog   aghalg.NullBool `json:"ignore_querylog"`
 	IgnoreStatistics aghalg.NullBool `json:"ignore_statistics"`
 
+	IgnoreAnomalyDetection aghalg.NullBool `json:"ignore_anomaly_detection"`
+
 	UpstreamsCacheSize    uint32          `json:"upstreams_cache_size"`
 	UpstreamsCacheEnabled aghalg.NullBool `json:"upstreams_cache_enabled"`
+
+	UseDNS64 aghalg.NullBool `json:"use_dns64"`
 }
 
 // runtimeClientJSON is a JSON representation of the [client.Runtime].
@@ -138,16 +151,20 @@ func initPrev(cj clientJSON, prev *client.Persistent) (c *client.Persistent, err
 		uid              client.UID
 		ignoreQueryLog   bool
 		ignoreStatistics bool
+		ignoreAnomaly    bool
 		upsCacheEnabled  bool
 		upsCacheSize     uint32
+		useDNS64         bool
 	)
 
 	if prev != nil {
 		uid = prev.UID
 		ignoreQueryLog = prev.IgnoreQueryLog
 		ignoreStatistics = prev.IgnoreStatistics
+		ignoreAnomaly = prev.IgnoreAnomalyDetection
 		upsCacheEnabled = prev.UpstreamsCacheEnabled
 		upsCacheSize = prev.UpstreamsCacheSize
+		useDNS64 = prev.UseDNS64
 	}
 
 	if cj.IgnoreQueryLog != aghalg.NBNull {
@@ -158,16 +175,35 @@ func initPrev(cj clientJSON, prev *client.Persistent) (c *client.Persistent, err
 		ignoreStatistics = cj.IgnoreStatistics == aghalg.NBTrue
 	}
 
+	if cj.IgnoreAnomalyDetection != aghalg.NBNull {
+		ignoreAnomaly = cj.IgnoreAnomalyDetection == aghalg.NBTrue
+	}
+
 	if cj.UpstreamsCacheEnabled != aghalg.NBNull {
 		upsCacheEnabled = cj.UpstreamsCacheEnabled == aghalg.NBTrue
 		upsCacheSize = cj.UpstreamsCacheSize
 	}
 
+	if cj.UseDNS64 != aghalg.NBNull {
+		useDNS64 = cj.UseDNS64 == aghalg.NBTrue
+	}
+
 	svcs, err := copyBlockedServices(cj.Schedule, cj.BlockedServices, prev)
 	if err != nil {
 		return nil, fmt.Errorf("invalid blocked services: %w", err)
 	}
 
+	geoIP, err := copyGeoIP(cj.GeoIP, prev)
+	if err != nil {
+		return nil, fmt.Errorf("invalid geoip policy: %w", err)
+	}
+
+	blockingMode, err := copyBlockingMode(cj.BlockingMode, prev)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
 	if (uid == client.UID{}) {
 		uid, err = client.NewUID()
 		if err != nil {
@@ -176,12 +212,16 @@ func initPrev(cj clientJSON, prev *client.Persistent) (c *client.Persistent, err
 	}
 
 	return &client.Persistent{
-		BlockedServices:       svcs,
-		UID:                   uid,
-		IgnoreQueryLog:        ignoreQueryLog,
-		IgnoreStatistics:      ignoreStatistics,
-		UpstreamsCacheEnabled: upsCacheEnabled,
-		UpstreamsCacheSize:    upsCacheSize,
+		BlockedServices:        svcs,
+		GeoIP:                  geoIP,
+		BlockingMode:           blockingMode,
+		UID:                    uid,
+		IgnoreQueryLog:         ignoreQueryLog,
+		IgnoreStatistics:       ignoreStatistics,
+		IgnoreAnomalyDetection: ignoreAnomaly,
+		UpstreamsCacheEnabled:  upsCacheEnabled,
+		UpstreamsCacheSize:     upsCacheSize,
+		UseDNS64:               useDNS64,
 	}, nil
 }
 
@@ -215,6 +255,7 @@ func (clients *clientsContainer) jsonToClient(
 
 	if c.SafeSearchConf.Enabled {
 		err = c.SetSafeSearch(
+			Context.logs.logger(logModuleFiltering),
 			c.SafeSearchConf,
 			clients.safeSearchCacheSize,
 			clients.safeSearchCacheTTL,
@@ -284,6 +325,51 @@ func copyBlockedServices(
 	return svcs, nil
 }
 
+// copyGeoIP returns a validated copy of the GeoIP filtering policy from the
+// request or the previous one, if the request doesn't contain it.
+func copyGeoIP(p *geoip.Policy, prev *client.Persistent) (c *geoip.Policy, err error) {
+	if p == nil {
+		if prev != nil {
+			return prev.GeoIP.Clone(), nil
+		}
+
+		return nil, nil
+	}
+
+	c = p.Clone()
+	err = c.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// copyBlockingMode returns the validated blocking mode from the request or the
+// previous one, if the request doesn't contain it.  The mode is validated
+// against the current global blocking IP addresses.
+func copyBlockingMode(
+	m *filtering.BlockingMode,
+	prev *client.Persistent,
+) (mode filtering.BlockingMode, err error) {
+	if m == nil {
+		if prev != nil {
+			return prev.BlockingMode, nil
+		}
+
+		return "", nil
+	}
+
+	_, bIPv4, bIPv6 := Context.filters.BlockingMode()
+	err = filtering.ValidateBlockingModeOverride(*m, bIPv4, bIPv6)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	return *m, nil
+}
+
 // clientToJSON converts persistent client object to JSON object.
 func clientToJSON(c *client.Persistent) (cj *clientJSON) {
 	// TODO(d.kolyshev): Remove after cleaning the deprecated
@@ -291,6 +377,12 @@ func clientToJSON(c *client.Persistent) (cj *clientJSON) {
 	cloneVal := c.SafeSearchConf
 	safeSearchConf := &cloneVal
 
+	var blockingMode *filtering.BlockingMode
+	if c.BlockingMode != "" {
+		mode := c.BlockingMode
+		blockingMode = &mode
+	}
+
 	return &clientJSON{
 		Name:                c.Name,
 		IDs:                 c.IDs(),
@@ -307,13 +399,21 @@ func clientToJSON(c *client.Persistent) (cj *clientJSON) {
 		Schedule:        c.BlockedServices.Schedule,
 		BlockedServices: c.BlockedServices.IDs,
 
+		GeoIP: c.GeoIP.Clone(),
+
+		BlockingMode: blockingMode,
+
 		Upstreams: c.Upstreams,
 
 		IgnoreQueryLog:   aghalg.BoolToNullBool(c.IgnoreQueryLog),
 		IgnoreStatistics: aghalg.BoolToNullBool(c.IgnoreStatistics),
 
+		IgnoreAnomalyDetection: aghalg.BoolToNullBool(c.IgnoreAnomalyDetection),
+
 		UpstreamsCacheSize:    c.UpstreamsCacheSize,
 		UpstreamsCacheEnabled: aghalg.BoolToNullBool(c.UpstreamsCacheEnabled),
+
+		UseDNS64: aghalg.BoolToNullBool(c.UseDNS64),
 	}
 }
 
@@ -4,25 +4,35 @@ import (
 	"bytes"
 	"fmt"
 	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtls"
+	"github.com/AdguardTeam/AdGuardHome/internal/anomaly"
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
 	"github.com/AdguardTeam/AdGuardHome/internal/configmigrate"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
 	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/catalog"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
+	"github.com/AdguardTeam/AdGuardHome/internal/rrl"
 	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/AdGuardHome/internal/secondary"
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
+	"github.com/AdguardTeam/AdGuardHome/internal/version"
 	"github.com/AdguardTeam/dnsproxy/fastip"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/google/renameio/v2/maybe"
+	"golang.org/x/mod/semver"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -56,6 +66,16 @@ type logSettings struct {
 	// is the computer's local time.
 	LocalTime bool `yaml:"local_time"`
 
+	// Levels are the minimum levels of the messages for each module, which
+	// override the global one.  The keys are the names of the modules, see
+	// [logModules], and the values are "debug", "info", "warn", or "error".
+	Levels map[string]string `yaml:"levels"`
+
+	// Format is the format of the log output.  It must be empty or one of
+	// [logFormatText], [logFormatJSON], and [logFormatLogfmt].  Structured
+	// formats aren't supported for syslog.
+	Format string `yaml:"format"`
+
 	// Verbose determines, if verbose (aka debug) logging is enabled.
 	Verbose bool `yaml:"verbose"`
 }
@@ -78,6 +98,9 @@ type clientsConfig struct {
 	Sources *clientSourcesConfig `yaml:"runtime_sources"`
 	// Persistent are the configured clients.
 	Persistent []*clientObject `yaml:"persistent"`
+	// TagBlockingModes are the blocking modes of the clients by their tags.
+	// The own blocking modes of the clients take precedence over these.
+	TagBlockingModes map[string]filtering.BlockingMode `yaml:"tag_blocking_modes,omitempty"`
 }
 
 // clientSourceConfig is used to configure where the runtime clients will be
@@ -122,6 +145,20 @@ type configuration struct {
 	TLS      tlsConfigSettings `yaml:"tls"`
 	QueryLog queryLogConfig    `yaml:"querylog"`
 	Stats    statsConfig       `yaml:"statistics"`
+	Bypass   bypassConfig      `yaml:"bypass"`
+	GeoIP    geoIPConfig       `yaml:"geoip"`
+
+	// AnomalyDetection is the configuration of the detection of the DNS
+	// tunneling and the algorithmically generated domains.
+	AnomalyDetection anomaly.Config `yaml:"anomaly_detection"`
+
+	// Recursor is the configuration of the recursive resolution used by the
+	// upstreams configured as "recursive".
+	Recursor recursorConfig `yaml:"recursor"`
+
+	// SecondaryZones are the zones transferred from their primary servers and
+	// served authoritatively.
+	SecondaryZones []*secondary.ZoneConfig `yaml:"secondary_zones"`
 
 	// Filters reflects the filters from [filtering.Config].  It's cloned to the
 	// config used in the filtering module at the startup.  Afterwards it's
@@ -142,9 +179,15 @@ type configuration struct {
 	// Keep this field sorted to ensure consistent ordering.
 	Clients *clientsConfig `yaml:"clients"`
 
+	// Update is a block with the update settings.
+	Update *updateConfig `yaml:"update"`
+
 	// Log is a block with log configuration settings.
 	Log logSettings `yaml:"log"`
 
+	// Tracing is a block with the tracing settings.
+	Tracing *tracingConfig `yaml:"tracing"`
+
 	OSConfig *osConfig `yaml:"os"`
 
 	sync.RWMutex `yaml:"-"`
@@ -165,6 +208,11 @@ type httpConfig struct {
 	// Address is the address to serve the web UI on.
 	Address netip.AddrPort
 
+	// BindInterface is the name of the network interface to serve the web UI
+	// on.  If set, the web UI is served on all addresses of the interface
+	// using the port from Address, and is rebound when they change.
+	BindInterface string `yaml:"bind_interface,omitempty"`
+
 	// SessionTTL for a web session.
 	// An active session is automatically refreshed once a day.
 	SessionTTL timeutil.Duration `yaml:"session_ttl"`
@@ -185,7 +233,13 @@ type httpPprofConfig struct {
 // not absolutely necessary.
 type dnsConfig struct {
 	BindHosts []netip.Addr `yaml:"bind_hosts"`
-	Port      uint16       `yaml:"port"`
+
+	// BindInterfaces are the names of the network interfaces to serve DNS on
+	// in addition to BindHosts.  The servers are rebound when the addresses of
+	// the interfaces change.
+	BindInterfaces []string `yaml:"bind_interfaces,omitempty"`
+
+	Port uint16 `yaml:"port"`
 
 	// AnonymizeClientIP defines if clients' IP addresses should be anonymized
 	// in query log and statistics.
@@ -217,6 +271,25 @@ type dnsConfig struct {
 	// DNS64Prefixes is the list of NAT64 prefixes to be used for DNS64.
 	DNS64Prefixes []netip.Prefix `yaml:"dns64_prefixes"`
 
+	// DNS64Discovery defines if the NAT64 prefix should be discovered from the
+	// upstream servers.  DNS64Prefixes are used until it's discovered.
+	DNS64Discovery bool `yaml:"dns64_discovery"`
+
+	// DNS64Tags are the client tags for which DNS64 is enabled when UseDNS64
+	// is false.
+	DNS64Tags []string `yaml:"dns64_tags"`
+
+	// DNS64ListenAddrs are the addresses of the listeners for the requests to
+	// which DNS64 is enabled when UseDNS64 is false.
+	DNS64ListenAddrs []netip.Addr `yaml:"dns64_listen_addrs"`
+
+	// MDNSDomain is the domain of the names resolved using multicast DNS, for
+	// example "home.lan".  If it's empty, the mDNS bridge is disabled.
+	MDNSDomain string `yaml:"mdns_domain"`
+
+	// MDNSTimeout is the time to wait for the responses to mDNS queries.
+	MDNSTimeout timeutil.Duration `yaml:"mdns_timeout"`
+
 	// ServeHTTP3 defines if HTTP/3 is allowed for incoming requests.
 	//
 	// TODO(a.garipov): Add to the UI when HTTP/3 support is no longer
@@ -230,6 +303,16 @@ type dnsConfig struct {
 	// experimental.
 	UseHTTP3Upstreams bool `yaml:"use_http3_upstreams"`
 
+	// UpstreamProxy is the URL of the SOCKS5 or HTTP proxy used for the
+	// connections to all the upstream servers, including the bootstrap ones.
+	// If empty, the upstreams are connected to directly.
+	UpstreamProxy string `yaml:"upstream_proxy"`
+
+	// UpstreamProxies are the URLs of the proxies by the addresses of the
+	// upstream servers, overriding UpstreamProxy.  An empty URL means that the
+	// upstream is connected to directly.
+	UpstreamProxies map[string]string `yaml:"upstream_proxies"`
+
 	// ServePlainDNS defines if plain DNS is allowed for incoming requests.
 	ServePlainDNS bool `yaml:"serve_plain_dns"`
 
@@ -262,6 +345,31 @@ type tlsConfigSettings struct {
 	dnsforward.TLSConfig `yaml:",inline" json:",inline"`
 }
 
+// updateConfig is the block with the update settings.
+type updateConfig struct {
+	// MaintenanceWindow is the weekly schedule within which the updates are
+	// installed automatically, if AutoUpdate is true.
+	MaintenanceWindow *schedule.Weekly `yaml:"maintenance_window"`
+
+	// Channel is the update channel.  It must be empty or one of
+	// [version.ChannelRelease], [version.ChannelBeta], and
+	// [version.ChannelEdge].  If it's empty, the channel of the current build
+	// is used.
+	Channel string `yaml:"channel"`
+
+	// MaxVersion is the maximum version allowed to update to.  If it's empty,
+	// any version is allowed.  See [updater.Config.MaxVersion].
+	MaxVersion string `yaml:"max_version"`
+
+	// NotifyURL is the URL to which the update notifications are sent.  If
+	// it's empty, the notifications are only written to the log.
+	NotifyURL string `yaml:"notify_url"`
+
+	// AutoUpdate defines if the updates are installed automatically within the
+	// maintenance window.
+	AutoUpdate bool `yaml:"auto_update"`
+}
+
 type queryLogConfig struct {
 	// DirPath is the custom directory for logs.  If it's empty the default
 	// directory will be used.  See [homeContext.getDataDir].
@@ -300,6 +408,48 @@ type statsConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
 
+// bypassConfig is the configuration of the DNS bypass prevention.
+type bypassConfig struct {
+	// ListURL is the URL of the list of public DNS resolvers' hostnames used in
+	// addition to the built-in one.  If empty, only the built-in list is used.
+	ListURL string `yaml:"list_url"`
+
+	// Enabled defines if the requests for public DNS resolvers and canary
+	// domains are blocked and the clients bypassing AdGuard Home are detected.
+	Enabled bool `yaml:"enabled"`
+}
+
+// geoIPConfig is the configuration of the GeoIP enrichment and filtering of the
+// DNS answers.
+type geoIPConfig struct {
+	// CountryDB is the path to the MaxMind country or city database.  If
+	// empty, the countries aren't looked up.
+	CountryDB string `yaml:"country_db"`
+
+	// ASNDB is the path to the MaxMind ASN database.  If empty, the
+	// autonomous systems aren't looked up.
+	ASNDB string `yaml:"asn_db"`
+
+	// ReloadInterval is the interval between the checks of the database files
+	// for modifications.
+	ReloadInterval timeutil.Duration `yaml:"reload_interval"`
+
+	// Policy is the global GeoIP filtering policy.
+	geoip.Policy `yaml:",inline"`
+}
+
+// recursorConfig is the configuration of the full recursive resolution starting
+// from the root servers.  The DNSSEC signatures aren't validated.
+type recursorConfig struct {
+	// RootHints are the addresses of the root servers.  If empty, the
+	// addresses of the IANA root servers are used.
+	RootHints []netip.Addr `yaml:"root_hints"`
+
+	// QNAMEMinimisation defines if the names sent to the authoritative servers
+	// are minimised, see RFC 9156.
+	QNAMEMinimisation bool `yaml:"qname_minimisation"`
+}
+
 // Default block host constants.
 const (
 	defaultSafeBrowsingBlockHost = "standard-block.dns.adguard.com"
@@ -341,6 +491,14 @@ var config = &configuration{
 			}},
 			CacheSize: 4 * 1024 * 1024,
 
+			RRL: rrl.Config{
+				ResponsesPerSecond: rrl.DefaultResponsesPerSecond,
+				Window:             timeutil.Duration{Duration: rrl.DefaultWindow},
+				Slip:               rrl.DefaultSlip,
+				IPv4PrefixLen:      rrl.DefaultIPv4PrefixLen,
+				IPv6PrefixLen:      rrl.DefaultIPv6PrefixLen,
+			},
+
 			EDNSClientSubnet: &dnsforward.EDNSClientSubnet{
 				CustomIP:  netip.Addr{},
 				Enabled:   false,
@@ -354,6 +512,7 @@ var config = &configuration{
 			MaxGoroutines: 300,
 		},
 		UpstreamTimeout:  timeutil.Duration{Duration: dnsforward.DefaultTimeout},
+		MDNSTimeout:      timeutil.Duration{Duration: aghnet.DefaultMDNSTimeout},
 		UsePrivateRDNS:   true,
 		ServePlainDNS:    true,
 		HostsFileEnabled: true,
@@ -375,6 +534,22 @@ var config = &configuration{
 		Interval: timeutil.Duration{Duration: 1 * timeutil.Day},
 		Ignored:  []string{},
 	},
+	GeoIP: geoIPConfig{
+		ReloadInterval: timeutil.Duration{Duration: geoip.DefaultReloadIvl},
+	},
+	AnomalyDetection: anomaly.Config{
+		Action:           anomaly.ActionFlag,
+		LabelLength:      anomaly.DefaultLabelLength,
+		Entropy:          anomaly.DefaultEntropy,
+		NGram:            anomaly.DefaultNGram,
+		TXTRate:          anomaly.DefaultTXTRate,
+		UniqueSubdomains: anomaly.DefaultUniqueSubdomains,
+		MinScore:         anomaly.DefaultMinScore,
+		Enabled:          false,
+	},
+	Recursor: recursorConfig{
+		QNAMEMinimisation: true,
+	},
 	// NOTE: Keep these parameters in sync with the one put into
 	// client/src/helpers/filters/filters.js by scripts/vetted-filters.
 	//
@@ -398,6 +573,7 @@ var config = &configuration{
 
 		FilteringEnabled:           true,
 		FiltersUpdateIntervalHours: 24,
+		CatalogURL:                 catalog.DefaultURL,
 
 		ParentalEnabled:     false,
 		SafeBrowsingEnabled: false,
@@ -444,7 +620,12 @@ var config = &configuration{
 			HostsFile: true,
 		},
 	},
+	Update: &updateConfig{
+		MaintenanceWindow: schedule.EmptyWeekly(),
+	},
+	Tracing: defaultTracingConfig(),
 	Log: logSettings{
+		Format:     logFormatText,
 		Compress:   false,
 		LocalTime:  false,
 		MaxBackups: 0,
@@ -478,7 +659,7 @@ func configFilePath() (confPath string) {
 }
 
 // validateBindHosts returns error if any of binding hosts from configuration is
-// not a valid IP address.
+// not a valid IP address or any of binding interfaces has an empty name.
 func validateBindHosts(conf *configuration) (err error) {
 	if !conf.HTTPConfig.Address.IsValid() {
 		return errors.Error("http.address is not a valid ip address")
@@ -490,6 +671,12 @@ func validateBindHosts(conf *configuration) (err error) {
 		}
 	}
 
+	for i, name := range conf.DNS.BindInterfaces {
+		if name == "" {
+			return fmt.Errorf("dns.bind_interfaces at index %d is empty", i)
+		}
+	}
+
 	return nil
 }
 
@@ -580,6 +767,52 @@ func validateConfig() (err error) {
 		config.Filtering.FiltersUpdateIntervalHours = 24
 	}
 
+	if config.Update == nil {
+		config.Update = &updateConfig{}
+	}
+
+	err = config.Update.validate()
+	if err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	if config.Tracing == nil {
+		config.Tracing = defaultTracingConfig()
+	}
+
+	err = config.Tracing.validate()
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+
+	return nil
+}
+
+// validate returns an error if the update configuration is invalid.  It also
+// sets the default maintenance window if there is none.
+func (c *updateConfig) validate() (err error) {
+	switch c.Channel {
+	case "", version.ChannelRelease, version.ChannelBeta, version.ChannelEdge:
+		// Go on.
+	default:
+		return fmt.Errorf("channel: unsupported value %q", c.Channel)
+	}
+
+	if c.MaxVersion != "" && !semver.IsValid(c.MaxVersion) {
+		return fmt.Errorf("max_version: bad version %q", c.MaxVersion)
+	}
+
+	if c.NotifyURL != "" {
+		_, err = url.ParseRequestURI(c.NotifyURL)
+		if err != nil {
+			return fmt.Errorf("notify_url: %w", err)
+		}
+	}
+
+	if c.MaintenanceWindow == nil {
+		c.MaintenanceWindow = schedule.EmptyWeekly()
+	}
+
 	return nil
 }
 
@@ -645,6 +878,13 @@ func (c *configuration) write() (err error) {
 		config.QueryLog.Ignored = dc.Ignored.Values()
 	}
 
+	if Context.bypass != nil {
+		bc := bypass.Config{}
+		Context.bypass.WriteDiskConfig(&bc)
+		config.Bypass.Enabled = bc.Enabled
+		config.Bypass.ListURL = bc.ListURL
+	}
+
 	if Context.filters != nil {
 		Context.filters.WriteDiskConfig(config.Filtering)
 		config.Filters = config.Filtering.Filters
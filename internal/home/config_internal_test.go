@@ -0,0 +1,58 @@
+package home
+
+import (
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		conf       *updateConfig
+		name       string
+		wantErrMsg string
+	}{{
+		conf:       &updateConfig{},
+		name:       "empty",
+		wantErrMsg: "",
+	}, {
+		conf: &updateConfig{
+			Channel:    "beta",
+			MaxVersion: "v0.107.50",
+			NotifyURL:  "https://example.com/notify",
+			AutoUpdate: true,
+		},
+		name:       "valid",
+		wantErrMsg: "",
+	}, {
+		conf: &updateConfig{
+			Channel: "nightly",
+		},
+		name:       "bad_channel",
+		wantErrMsg: `channel: unsupported value "nightly"`,
+	}, {
+		conf: &updateConfig{
+			MaxVersion: "0.107",
+		},
+		name:       "bad_max_version",
+		wantErrMsg: `max_version: bad version "0.107"`,
+	}, {
+		conf: &updateConfig{
+			NotifyURL: "not a url",
+		},
+		name:       "bad_notify_url",
+		wantErrMsg: `notify_url: parse "not a url": invalid URI for request`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.conf.validate()
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			if err == nil {
+				assert.NotNil(t, tc.conf.MaintenanceWindow)
+			}
+		})
+	}
+}
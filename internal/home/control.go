@@ -67,15 +67,12 @@ func appendDNSAddrsWithIfaces(dst []string, src []netip.Addr) (res []string, err
 }
 
 // collectDNSAddresses returns the list of DNS addresses the server is listening
-// on, including the addresses on all interfaces in cases of unspecified IPs.
+// on, including the addresses on all interfaces in cases of unspecified IPs and
+// the current addresses of the bind interfaces.
 func collectDNSAddresses() (addrs []string, err error) {
-	if hosts := config.DNS.BindHosts; len(hosts) == 0 {
-		addrs = appendDNSAddrs(addrs, netutil.IPv4Localhost())
-	} else {
-		addrs, err = appendDNSAddrsWithIfaces(addrs, hosts)
-		if err != nil {
-			return nil, fmt.Errorf("collecting dns addresses: %w", err)
-		}
+	addrs, err = appendDNSAddrsWithIfaces(addrs, dnsBindHosts(&config.DNS))
+	if err != nil {
+		return nil, fmt.Errorf("collecting dns addresses: %w", err)
 	}
 
 	de := getDNSEncryption()
@@ -182,6 +179,7 @@ func registerControlHandlers(web *webAPI) {
 	httpRegister(http.MethodGet, "/control/i18n/current_language", handleI18nCurrentLanguage)
 	httpRegister(http.MethodGet, "/control/profile", handleGetProfile)
 	httpRegister(http.MethodPut, "/control/profile/update", handlePutProfile)
+	httpRegister(http.MethodPost, "/control/querylog/replay", handleQueryLogReplay)
 
 	// No auth is necessary for DoH/DoT configurations
 	Context.mux.HandleFunc("/apple/doh.mobileconfig", postInstall(handleMobileConfigDoH))
@@ -200,11 +198,14 @@ func httpRegister(method, url string, handler http.HandlerFunc) {
 }
 
 // ensure returns a wrapped handler that makes sure that the request has the
-// correct method as well as additional method and header checks.
+// correct method as well as additional method and header checks.  The handler
+// is also traced, if the tracing is enabled.
 func ensure(
 	method string,
 	handler func(http.ResponseWriter, *http.Request),
 ) (wrapped func(http.ResponseWriter, *http.Request)) {
+	handler = traceHTTP(handler)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		m, u := r.Method, r.URL
@@ -218,7 +219,7 @@ func ensure(
 		}
 
 		if modifiesData(m) {
-			if !ensureContentType(w, r) {
+			if !ensureContentType(w, r) || !ensureSameOrigin(w, r) || !ensureCSRFToken(w, r) {
 				return
 			}
 
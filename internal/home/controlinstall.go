@@ -178,19 +178,26 @@ func (web *webAPI) handleInstallCheckConfig(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	resp := &checkConfResp{}
+	aghhttp.WriteJSONResponseOK(w, r, req.validate())
+}
+
+// validate checks the web and DNS parts of the initial configuration as well
+// as the static IP address settings and returns the results of the checks.
+func (req *checkConfReq) validate() (resp *checkConfResp) {
+	resp = &checkConfResp{}
 	tcpPorts := aghalg.UniqChecker[tcpPort]{}
-	if err = req.validateWeb(tcpPorts); err != nil {
+	if err := req.validateWeb(tcpPorts); err != nil {
 		resp.Web.Status = err.Error()
 	}
 
+	var err error
 	if resp.DNS.CanAutofix, err = req.validateDNS(tcpPorts); err != nil {
 		resp.DNS.Status = err.Error()
 	} else if !req.DNS.IP.IsUnspecified() {
 		resp.StaticIP = handleStaticIP(req.DNS.IP, req.SetStaticIP)
 	}
 
-	aghhttp.WriteJSONResponseOK(w, r, resp)
+	return resp
 }
 
 // handleStaticIP - handles static IP request
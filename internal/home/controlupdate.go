@@ -17,6 +17,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/updater"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
+	yaml "gopkg.in/yaml.v3"
 )
 
 // temporaryError is the interface for temporary errors from the Go standard
@@ -223,3 +224,166 @@ func finishUpdate(ctx context.Context, execPath string, runningAsService bool) {
 		log.Fatalf("restarting: %s", err)
 	}
 }
+
+const (
+	// maxUpdateStartAttempts is the maximum number of times an updated version
+	// is allowed to start without becoming healthy before it's rolled back.
+	maxUpdateStartAttempts = 3
+
+	// updateHealthCheckTimeout is the time within which an updated version must
+	// become healthy.  Otherwise, it's rolled back.
+	updateHealthCheckTimeout = 2 * time.Minute
+
+	// updateHealthCheckIvl is the interval between health checks of an updated
+	// version.
+	updateHealthCheckIvl = 1 * time.Second
+)
+
+// loadPendingUpdate returns the information about the update that hasn't been
+// confirmed as healthy yet, if there is one.  If the updated version has
+// already failed to start too many times, loadPendingUpdate rolls it back and
+// restarts the previous version.
+func loadPendingUpdate(runningAsService bool) (p *updater.Pending) {
+	p, err := updater.ReadPending(Context.workDir)
+	if err != nil {
+		log.Error("update: reading pending update: %s", err)
+
+		return nil
+	} else if p == nil {
+		return nil
+	}
+
+	log.Info("update: checking health of %s, attempt %d", p.NewVersion, p.Attempts)
+
+	if p.Attempts > maxUpdateStartAttempts {
+		log.Error("update: %s has failed to start %d times", p.NewVersion, p.Attempts-1)
+
+		// The configuration isn't parsed yet, so read the notification URL
+		// for the rollback notification directly.
+		notifyURL := readNotifyURL()
+		func() {
+			config.Lock()
+			defer config.Unlock()
+
+			config.Update.NotifyURL = notifyURL
+		}()
+
+		rollbackUpdate(p, runningAsService)
+
+		return nil
+	}
+
+	return p
+}
+
+// readNotifyURL returns the update notification URL from the configuration
+// file without parsing the rest of it, since the updated version may be unable
+// to parse it.  The errors are only logged.
+func readNotifyURL() (notifyURL string) {
+	confPath := configFilePath()
+	data, err := os.ReadFile(confPath)
+	if err != nil {
+		log.Error("update: reading notify url: %s", err)
+
+		return ""
+	}
+
+	conf := &struct {
+		Update *struct {
+			NotifyURL string `yaml:"notify_url"`
+		} `yaml:"update"`
+	}{}
+
+	err = yaml.Unmarshal(data, conf)
+	if err != nil {
+		log.Error("update: parsing notify url from %q: %s", confPath, err)
+
+		return ""
+	} else if conf.Update == nil {
+		return ""
+	}
+
+	return conf.Update.NotifyURL
+}
+
+// superviseUpdate waits for the updated version to become healthy within
+// [updateHealthCheckTimeout].  If it does, the update is confirmed.  Otherwise,
+// superviseUpdate rolls the update back and restarts the previous version.
+func superviseUpdate(p *updater.Pending, runningAsService bool) {
+	defer log.OnPanic("update: supervising")
+
+	ticker := time.NewTicker(updateHealthCheckIvl)
+	defer ticker.Stop()
+
+	timeout := time.After(updateHealthCheckTimeout)
+	for {
+		select {
+		case <-ticker.C:
+			if !isHealthyAfterUpdate() {
+				continue
+			}
+
+			err := p.Confirm()
+			if err != nil {
+				log.Error("update: %s", err)
+			}
+
+			notifyUpdate(updateEventFinished, p.PrevVersion, p.NewVersion)
+
+			return
+		case <-timeout:
+			log.Error("update: %s hasn't become healthy in %s", p.NewVersion, updateHealthCheckTimeout)
+
+			rollbackUpdate(p, runningAsService)
+
+			return
+		}
+	}
+}
+
+// isHealthyAfterUpdate returns true if AdGuard Home has successfully started
+// all the modules it should have and its DNS and web listeners respond.
+func isHealthyAfterUpdate() (ok bool) {
+	var firstRun, running bool
+	func() {
+		Context.controlLock.Lock()
+		defer Context.controlLock.Unlock()
+
+		firstRun, running = Context.firstRun, isRunning()
+	}()
+
+	err := probeWebListener()
+	if err != nil {
+		log.Debug("update: %s", err)
+
+		return false
+	} else if firstRun {
+		return true
+	} else if !running {
+		return false
+	}
+
+	err = probeDNSListeners()
+	if err != nil {
+		log.Debug("update: %s", err)
+
+		return false
+	}
+
+	return true
+}
+
+// rollbackUpdate restores the previous version of AdGuard Home and restarts it.
+// It only returns if the rollback has failed.
+func rollbackUpdate(p *updater.Pending, runningAsService bool) {
+	err := p.Rollback()
+	if err != nil {
+		log.Error("update: rolling back: %s", err)
+
+		return
+	}
+
+	notifyUpdate(updateEventRolledBack, p.PrevVersion, p.NewVersion)
+
+	finishUpdate(context.Background(), p.ExecPath, runningAsService)
+}
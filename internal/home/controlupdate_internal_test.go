@@ -0,0 +1,45 @@
+package home
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadNotifyURL(t *testing.T) {
+	prevConfPath := Context.confFilePath
+	t.Cleanup(func() { Context.confFilePath = prevConfPath })
+
+	testCases := []struct {
+		name string
+		data string
+		want string
+	}{{
+		name: "set",
+		data: "schema_version: 100\nupdate:\n  notify_url: https://example.com/notify\n",
+		want: "https://example.com/notify",
+	}, {
+		name: "no_update",
+		data: "schema_version: 100\n",
+		want: "",
+	}, {
+		name: "bad_yaml",
+		data: "update: [",
+		want: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			confPath := filepath.Join(t.TempDir(), "AdGuardHome.yaml")
+			err := os.WriteFile(confPath, []byte(tc.data), 0o644)
+			require.NoError(t, err)
+
+			Context.confFilePath = confPath
+
+			assert.Equal(t, tc.want, readNotifyURL())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package home
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/golibs/httphdr"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+)
+
+// csrfCookieName is the name of the cookie containing the CSRF token of the
+// session.  Unlike the session cookie, it's readable by the frontend, which
+// sends its value back in the [csrfHeader] header.
+const csrfCookieName = "agh_csrf"
+
+// csrfHeader is the name of the header containing the CSRF token in the
+// data-modifying requests.
+const csrfHeader = "X-CSRF-Token"
+
+// hdrXForwardedHost is the name of the header containing the original host
+// requested by the client of a reverse proxy.
+const hdrXForwardedHost = "X-Forwarded-Host"
+
+// csrfToken returns the CSRF token of the session with the given hex-encoded
+// token.  The CSRF token is derived from the session token, so it doesn't
+// need to be stored, and it can't be used to restore the session token.
+func csrfToken(sessToken string) (tok string) {
+	sum := sha256.Sum256([]byte("csrf:" + sessToken))
+
+	return hex.EncodeToString(sum[:16])
+}
+
+// newCSRFCookie returns a new cookie with the CSRF token of the session with
+// the given hex-encoded token.
+func newCSRFCookie(sessToken string, expires time.Time) (c *http.Cookie) {
+	return &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrfToken(sessToken),
+		Path:     "/",
+		Expires:  expires,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// setCSRFCookie sets the CSRF cookie for the session with the given
+// hex-encoded token, unless the request already contains the correct one.
+// It's used to issue the CSRF tokens for the sessions created by the previous
+// versions.
+func setCSRFCookie(w http.ResponseWriter, r *http.Request, sessToken string) {
+	want := csrfToken(sessToken)
+	if c, err := r.Cookie(csrfCookieName); err == nil && c.Value == want {
+		return
+	}
+
+	http.SetCookie(w, newCSRFCookie(sessToken, time.Now().Add(cookieTTL)))
+}
+
+// ensureCSRFToken makes sure that a data-modifying request authenticated with
+// a session cookie contains the CSRF token of that session.  If it doesn't,
+// ensureCSRFToken writes a response to w, and ok is false.
+//
+// The requests without a valid session, such as the login requests and the
+// requests using Basic authentication, don't need the token, since a forged
+// request can't make use of a session in that case.
+func ensureCSRFToken(w http.ResponseWriter, r *http.Request) (ok bool) {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil || Context.auth == nil || !Context.auth.hasSession(c.Value) {
+		return true
+	}
+
+	want := csrfToken(c.Value)
+	got := r.Header.Get(csrfHeader)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1 {
+		return true
+	}
+
+	aghhttp.Error(r, w, http.StatusForbidden, "csrf: missing or invalid %s header", csrfHeader)
+
+	return false
+}
+
+// ensureSameOrigin makes sure that a data-modifying request, if made by
+// a browser, comes from a page served by AdGuard Home itself.  If it doesn't,
+// ensureSameOrigin writes a response to w, and ok is false.
+//
+// The Origin header is checked first, then the Referer one.  The requests
+// containing neither are allowed, since browsers always send at least one of
+// them with cross-origin requests.  Only the host names are compared, since
+// the frontend served over HTTP may send requests to the HTTPS server.
+func ensureSameOrigin(w http.ResponseWriter, r *http.Request) (ok bool) {
+	origin := r.Header.Get(httphdr.Origin)
+	if origin == "" {
+		origin = r.Header.Get(httphdr.Referer)
+		if origin == "" {
+			return true
+		}
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		aghhttp.Error(r, w, http.StatusForbidden, "csrf: bad origin %q", origin)
+
+		return false
+	}
+
+	reqHost := requestHost(r)
+	if !strings.EqualFold(u.Hostname(), reqHost) {
+		log.Info("csrf: origin %q doesn't match host %q", origin, reqHost)
+		aghhttp.Error(r, w, http.StatusForbidden, "csrf: cross-origin request")
+
+		return false
+	}
+
+	return true
+}
+
+// requestHost returns the host name requested by the client.  The
+// X-Forwarded-Host header is only taken into account if the request comes from
+// one of the trusted proxies.
+func requestHost(r *http.Request) (host string) {
+	host = r.Host
+	if fwd := r.Header.Get(hdrXForwardedHost); fwd != "" && isFromTrustedProxy(r) {
+		// Use the host requested from the first proxy in the chain.
+		host, _, _ = strings.Cut(fwd, ",")
+		host = strings.TrimSpace(host)
+	}
+
+	hostname, err := netutil.SplitHost(host)
+	if err != nil {
+		// There is no port, but there may be brackets around an IPv6 address.
+		return strings.Trim(host, "[]")
+	}
+
+	return hostname
+}
+
+// isFromTrustedProxy returns true if the remote address of the request belongs
+// to one of the trusted proxies.
+func isFromTrustedProxy(r *http.Request) (ok bool) {
+	if Context.auth == nil || Context.auth.trustedProxies == nil {
+		return false
+	}
+
+	ipStr, err := netutil.SplitHost(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return false
+	}
+
+	return Context.auth.trustedProxies.Contains(ip.Unmap())
+}
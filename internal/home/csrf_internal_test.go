@@ -0,0 +1,162 @@
+package home
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/httphdr"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSameOrigin(t *testing.T) {
+	prevAuth := Context.auth
+	t.Cleanup(func() { Context.auth = prevAuth })
+
+	Context.auth = &Auth{
+		trustedProxies: netutil.SliceSubnetSet{netip.MustParsePrefix("192.0.2.0/24")},
+	}
+
+	testCases := []struct {
+		hdr        http.Header
+		name       string
+		remoteAddr string
+		wantOK     bool
+	}{{
+		hdr:        http.Header{},
+		name:       "no_origin",
+		remoteAddr: "198.51.100.1:1234",
+		wantOK:     true,
+	}, {
+		hdr:        http.Header{httphdr.Origin: []string{"http://agh.example:3000"}},
+		name:       "same_origin",
+		remoteAddr: "198.51.100.1:1234",
+		wantOK:     true,
+	}, {
+		hdr:        http.Header{httphdr.Origin: []string{"http://evil.example"}},
+		name:       "cross_origin",
+		remoteAddr: "198.51.100.1:1234",
+		wantOK:     false,
+	}, {
+		hdr:        http.Header{httphdr.Referer: []string{"http://agh.example/#settings"}},
+		name:       "same_referer",
+		remoteAddr: "198.51.100.1:1234",
+		wantOK:     true,
+	}, {
+		hdr:        http.Header{httphdr.Origin: []string{"null"}},
+		name:       "null_origin",
+		remoteAddr: "198.51.100.1:1234",
+		wantOK:     false,
+	}, {
+		hdr: http.Header{
+			httphdr.Origin:    []string{"https://proxy.example"},
+			hdrXForwardedHost: []string{"proxy.example"},
+		},
+		name:       "trusted_proxy",
+		remoteAddr: "192.0.2.1:1234",
+		wantOK:     true,
+	}, {
+		hdr: http.Header{
+			httphdr.Origin:    []string{"https://proxy.example"},
+			hdrXForwardedHost: []string{"proxy.example"},
+		},
+		name:       "untrusted_proxy",
+		remoteAddr: "198.51.100.1:1234",
+		wantOK:     false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://agh.example:3000/control/test", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for k, v := range tc.hdr {
+				r.Header[http.CanonicalHeaderKey(k)] = v
+			}
+
+			w := httptest.NewRecorder()
+			ok := ensureSameOrigin(w, r)
+			assert.Equal(t, tc.wantOK, ok)
+
+			if !ok {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestEnsureCSRFToken(t *testing.T) {
+	prevAuth := Context.auth
+	t.Cleanup(func() { Context.auth = prevAuth })
+
+	Context.auth = InitAuth(filepath.Join(t.TempDir(), "sessions.db"), nil, 60, nil, nil)
+	t.Cleanup(Context.auth.Close)
+
+	sess, err := newSessionToken()
+	require.NoError(t, err)
+
+	Context.auth.addSession(sess, &session{
+		userName: "name",
+		expire:   uint32(time.Now().Add(time.Hour).Unix()),
+	})
+
+	token := hex.EncodeToString(sess)
+
+	testCases := []struct {
+		name      string
+		sessToken string
+		csrfToken string
+		wantOK    bool
+	}{{
+		name:      "no_session",
+		sessToken: "",
+		csrfToken: "",
+		wantOK:    true,
+	}, {
+		name:      "unknown_session",
+		sessToken: "0123",
+		csrfToken: "",
+		wantOK:    true,
+	}, {
+		name:      "valid",
+		sessToken: token,
+		csrfToken: csrfToken(token),
+		wantOK:    true,
+	}, {
+		name:      "missing",
+		sessToken: token,
+		csrfToken: "",
+		wantOK:    false,
+	}, {
+		name:      "invalid",
+		sessToken: token,
+		csrfToken: csrfToken("0123"),
+		wantOK:    false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/control/test", nil)
+			if tc.sessToken != "" {
+				r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tc.sessToken})
+			}
+
+			if tc.csrfToken != "" {
+				r.Header.Set(csrfHeader, tc.csrfToken)
+			}
+
+			w := httptest.NewRecorder()
+			ok := ensureCSRFToken(w, r)
+			assert.Equal(t, tc.wantOK, ok)
+
+			if !ok {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
@@ -10,14 +10,20 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/anomaly"
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
 	"github.com/AdguardTeam/AdGuardHome/internal/client"
 	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/catalog"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/AdGuardHome/internal/secondary"
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/netutil"
@@ -54,6 +60,7 @@ func initDNS() (err error) {
 	}
 
 	statsConf := stats.Config{
+		Logger:            Context.logs.logger(logModuleStats),
 		Filename:          filepath.Join(statsDir, "stats.db"),
 		Limit:             config.Stats.Interval.Duration,
 		ConfigModified:    onConfigModified,
@@ -74,6 +81,7 @@ func initDNS() (err error) {
 	}
 
 	conf := querylog.Config{
+		Logger:            Context.logs.logger(logModuleQueryLog),
 		Anonymizer:        anonymizer,
 		ConfigModified:    onConfigModified,
 		HTTPRegister:      httpRegister,
@@ -97,12 +105,50 @@ func initDNS() (err error) {
 		return fmt.Errorf("init querylog: %w", err)
 	}
 
+	err = initCatalog()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
 	Context.filters, err = filtering.New(config.Filtering, nil)
 	if err != nil {
 		// Don't wrap the error, since it's informative enough as is.
 		return err
 	}
 
+	Context.bypass, err = bypass.New(&bypass.Config{
+		HTTPClient:     httpClient(),
+		ConfigModified: onConfigModified,
+		HTTPRegister:   httpRegister,
+		ListURL:        config.Bypass.ListURL,
+		Enabled:        config.Bypass.Enabled,
+	})
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	err = initGeoIP()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	err = initAnomaly()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	initRecursor()
+
+	err = initSecondary()
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
 	tlsConf := &tlsConfigSettings{}
 	Context.tls.WriteDiskConfig(tlsConf)
 
@@ -117,6 +163,141 @@ func initDNS() (err error) {
 	)
 }
 
+// initCatalog initializes the catalog of the filtering rule lists, if it's
+// enabled, and sets it to the filtering configuration.
+func initCatalog() (err error) {
+	conf := config.Filtering
+	if conf.CatalogURL == "" {
+		Context.catalog = nil
+		conf.Catalog = nil
+
+		return nil
+	}
+
+	Context.catalog, err = catalog.New(&catalog.Config{
+		Logger:       Context.logs.logger(logModuleFiltering),
+		HTTPClient:   httpClient(),
+		HTTPRegister: httpRegister,
+		URL:          conf.CatalogURL,
+		CachePath:    filepath.Join(Context.getDataDir(), "filters_catalog.json"),
+	})
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	conf.Catalog = Context.catalog
+
+	return nil
+}
+
+// initGeoIP validates the global GeoIP filtering policy and initializes the
+// GeoIP module, if any of the databases is configured.
+func initGeoIP() (err error) {
+	conf := &config.GeoIP
+	err = conf.Policy.Validate()
+	if err != nil {
+		return fmt.Errorf("geoip: policy: %w", err)
+	}
+
+	if conf.CountryDB == "" && conf.ASNDB == "" {
+		if !conf.Policy.IsEmpty() {
+			log.Info("geoip: no databases configured, policy is ignored")
+		}
+
+		return nil
+	}
+
+	Context.geoIP, err = geoip.New(&geoip.Config{
+		CountryDB: conf.CountryDB,
+		ASNDB:     conf.ASNDB,
+		ReloadIvl: conf.ReloadInterval.Duration,
+	})
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	return nil
+}
+
+// initAnomaly validates the configuration of the anomaly detection and
+// initializes the module, if it's enabled.
+func initAnomaly() (err error) {
+	conf := &config.AnomalyDetection
+	if !conf.Enabled {
+		Context.anomaly = nil
+
+		return nil
+	}
+
+	err = conf.Validate()
+	if err != nil {
+		return fmt.Errorf("anomaly_detection: %w", err)
+	}
+
+	Context.anomaly = anomaly.New(conf)
+
+	return nil
+}
+
+// initRecursor initializes the recursive resolver used by the upstreams
+// configured as [recursor.UpstreamAddr].  The resolver doesn't bind any sockets
+// until it's used, so it's always initialized.
+func initRecursor() {
+	conf := &config.Recursor
+	Context.recursor = recursor.New(&recursor.Config{
+		RootHints:         conf.RootHints,
+		Timeout:           config.DNS.UpstreamTimeout.Duration,
+		QNAMEMinimisation: conf.QNAMEMinimisation,
+	})
+}
+
+// initSecondary initializes the secondary zones module, if any zones are
+// configured.
+func initSecondary() (err error) {
+	zones := config.SecondaryZones
+	if len(zones) == 0 {
+		Context.secondary = nil
+
+		return nil
+	}
+
+	Context.secondary, err = secondary.New(&secondary.Config{
+		Zones:   zones,
+		Timeout: config.DNS.UpstreamTimeout.Duration,
+	})
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	return nil
+}
+
+// initUpstreamProxies initializes the proxies for the upstream connections, if
+// any are configured.
+func initUpstreamProxies() (err error) {
+	dnsConf := &config.DNS
+	if dnsConf.UpstreamProxy == "" && len(dnsConf.UpstreamProxies) == 0 {
+		Context.upsProxies = nil
+
+		return nil
+	}
+
+	Context.upsProxies, err = upsproxy.New(&upsproxy.Config{
+		Upstreams: dnsConf.UpstreamProxies,
+		Global:    dnsConf.UpstreamProxy,
+		Timeout:   dnsConf.UpstreamTimeout.Duration,
+	})
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	return nil
+}
+
 // initDNSServer initializes the [context.dnsServer].  To only use the internal
 // proxy, none of the arguments are required, but tlsConf still must not be nil,
 // in other cases all the arguments also must not be nil.  It also must not be
@@ -138,7 +319,9 @@ func initDNSServer(
 		Anonymizer:  anonymizer,
 		DHCPServer:  dhcpSrv,
 		EtcHosts:    Context.etcHosts,
+		Tracer:      Context.tracer,
 		LocalDomain: config.DHCP.LocalDomainName,
+		Logger:      Context.logs.logger(logModuleDNS),
 	})
 	defer func() {
 		if err != nil {
@@ -226,7 +409,7 @@ func newServerConfig(
 	tlsConf *tlsConfigSettings,
 	httpReg aghhttp.RegisterFunc,
 ) (newConf *dnsforward.ServerConfig, err error) {
-	hosts := aghalg.CoalesceSlice(dnsConf.BindHosts, []netip.Addr{netutil.IPv4Localhost()})
+	hosts := dnsBindHosts(dnsConf)
 
 	fwdConf := dnsConf.Config
 	fwdConf.FilterHandler = applyAdditionalFiltering
@@ -245,10 +428,21 @@ func newServerConfig(
 		LocalPTRResolvers:      dnsConf.LocalPTRResolvers,
 		UseDNS64:               dnsConf.UseDNS64,
 		DNS64Prefixes:          dnsConf.DNS64Prefixes,
+		DNS64Discovery:         dnsConf.DNS64Discovery,
+		DNS64Tags:              dnsConf.DNS64Tags,
+		DNS64ListenAddrs:       dnsConf.DNS64ListenAddrs,
 		UsePrivateRDNS:         dnsConf.UsePrivateRDNS,
 		ServeHTTP3:             dnsConf.ServeHTTP3,
 		UseHTTP3Upstreams:      dnsConf.UseHTTP3Upstreams,
 		ServePlainDNS:          dnsConf.ServePlainDNS,
+		Activated:              Context.sockets.DNSListeners(),
+		Bypass:                 Context.bypass,
+		GeoIP:                  Context.geoIP,
+		GeoIPPolicy:            &config.GeoIP.Policy,
+		Anomaly:                Context.anomaly,
+		Recursor:               Context.recursor,
+		Secondary:              Context.secondary,
+		UpstreamProxies:        Context.upsProxies,
 	}
 
 	var initialAddresses []netip.Addr
@@ -264,6 +458,7 @@ func newServerConfig(
 	newConf.AddrProcConf = &client.DefaultAddrProcConfig{
 		Exchanger:        Context.dnsServer,
 		AddressUpdater:   &Context.clients,
+		Tracer:           Context.tracer,
 		InitialAddresses: initialAddresses,
 		CatchPanics:      true,
 		UseRDNS:          clientSrcConf.RDNS,
@@ -277,6 +472,16 @@ func newServerConfig(
 		return nil, err
 	}
 
+	if dnsConf.MDNSDomain != "" {
+		newConf.MDNS, err = aghnet.NewMDNSBridge(&aghnet.MDNSConfig{
+			Domain:  dnsConf.MDNSDomain,
+			Timeout: dnsConf.MDNSTimeout.Duration,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("dns: %w", err)
+		}
+	}
+
 	return newConf, nil
 }
 
@@ -431,6 +636,10 @@ func applyAdditionalFiltering(clientIP netip.Addr, clientID string, setts *filte
 
 	setts.ClientName = c.Name
 	setts.ClientTags = c.Tags
+	setts.UseDNS64 = c.UseDNS64
+	setts.GeoIPPolicy = c.GeoIP
+	setts.IgnoreAnomalyDetection = c.IgnoreAnomalyDetection
+	setts.BlockingMode = Context.clients.blockingMode(c)
 	if !c.UseOwnSettings {
 		return
 	}
@@ -463,6 +672,22 @@ func startDNSServer() error {
 	Context.stats.Start()
 	Context.queryLog.Start()
 
+	if Context.bypass != nil {
+		Context.bypass.Start()
+	}
+
+	if Context.catalog != nil {
+		Context.catalog.Start()
+	}
+
+	if Context.geoIP != nil {
+		Context.geoIP.Start()
+	}
+
+	if Context.secondary != nil {
+		Context.secondary.Start()
+	}
+
 	return nil
 }
 
@@ -525,6 +750,26 @@ func closeDNSServer() {
 		Context.queryLog.Close()
 	}
 
+	if Context.bypass != nil {
+		Context.bypass.Close()
+		Context.bypass = nil
+	}
+
+	if Context.catalog != nil {
+		Context.catalog.Close()
+		Context.catalog = nil
+	}
+
+	if Context.geoIP != nil {
+		Context.geoIP.Close()
+		Context.geoIP = nil
+	}
+
+	if Context.secondary != nil {
+		Context.secondary.Close()
+		Context.secondary = nil
+	}
+
 	log.Debug("all dns modules are closed")
 }
 
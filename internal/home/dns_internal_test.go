@@ -7,6 +7,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/client"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/schedule"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -103,7 +104,7 @@ func TestApplyAdditionalFiltering(t *testing.T) {
 }
 
 func TestApplyAdditionalFiltering_blockedServices(t *testing.T) {
-	filtering.InitModule()
+	filtering.InitModule(slogutil.NewDiscardLogger())
 
 	var (
 		globalBlockedServices  = []string{"ok"}
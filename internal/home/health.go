@@ -0,0 +1,392 @@
+package home
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// healthStatus is the status of AdGuard Home or one of its components.
+type healthStatus string
+
+// healthStatus values.
+const (
+	// healthStatusOK means that the component works properly.
+	healthStatusOK healthStatus = "ok"
+
+	// healthStatusFailed means that the component doesn't work properly.
+	healthStatusFailed healthStatus = "failed"
+
+	// healthStatusDisabled means that the component is disabled in the
+	// configuration and isn't checked.
+	healthStatusDisabled healthStatus = "disabled"
+)
+
+// componentHealth is the health of a single component of AdGuard Home.
+type componentHealth struct {
+	// Status is the status of the component.
+	Status healthStatus `json:"status"`
+
+	// Message is the human-readable description of the status, if any.
+	Message string `json:"message,omitempty"`
+}
+
+// healthOK returns the health of a properly working component.
+func healthOK() (h *componentHealth) {
+	return &componentHealth{Status: healthStatusOK}
+}
+
+// healthDisabled returns the health of a disabled component.
+func healthDisabled() (h *componentHealth) {
+	return &componentHealth{Status: healthStatusDisabled}
+}
+
+// healthFailed returns the health of a failed component with the formatted
+// message.
+func healthFailed(format string, args ...any) (h *componentHealth) {
+	return &componentHealth{
+		Status:  healthStatusFailed,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// healthResp is the response to the GET /health/live and GET /health/ready
+// HTTP API.
+type healthResp struct {
+	// Components are the health statuses of each component by their names.
+	Components map[string]*componentHealth `json:"components,omitempty"`
+
+	// Status is [healthStatusOK] if none of the components has failed.
+	Status healthStatus `json:"status"`
+}
+
+// healthCheck is a single readiness check.
+type healthCheck struct {
+	// check returns the health of the component.
+	check func() (h *componentHealth)
+
+	// name is the name of the component.
+	name string
+}
+
+// readinessChecks are the checks of all the components performed on
+// readiness requests.
+var readinessChecks = []healthCheck{{
+	check: checkDNSHealth,
+	name:  "dns",
+}, {
+	check: checkUpstreamsHealth,
+	name:  "upstreams",
+}, {
+	check: checkFiltersHealth,
+	name:  "filters",
+}, {
+	check: checkDHCPHealth,
+	name:  "dhcp",
+}, {
+	check: checkQueryLogHealth,
+	name:  "querylog",
+}, {
+	check: checkTLSHealth,
+	name:  "tls",
+}}
+
+// registerHealthHandlers registers the HTTP handlers of the health endpoints.
+// They don't require authentication, so that they could be used by load
+// balancers and orchestration systems, and they're available before the
+// initial setup is finished.
+func registerHealthHandlers() {
+	Context.mux.HandleFunc("/health/live", ensure(http.MethodGet, handleHealthLive))
+	Context.mux.HandleFunc("/health/ready", ensure(http.MethodGet, handleHealthReady))
+}
+
+// handleHealthLive is the handler for the GET /health/live HTTP API.  It
+// responds with 200 OK as long as AdGuard Home is able to process HTTP
+// requests.
+func handleHealthLive(w http.ResponseWriter, r *http.Request) {
+	aghhttp.WriteJSONResponseOK(w, r, &healthResp{
+		Status: healthStatusOK,
+	})
+}
+
+// handleHealthReady is the handler for the GET /health/ready HTTP API.  It
+// responds with 200 OK if all the enabled components are working properly and
+// with 503 Service Unavailable otherwise.  The messages describing the
+// statuses of the components are only sent to the authenticated users, since
+// they may contain the names of the filters and the paths to the files.
+func handleHealthReady(w http.ResponseWriter, r *http.Request) {
+	resp := checkReadiness()
+
+	code := http.StatusOK
+	if resp.Status != healthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	if !isHealthDetailsAllowed(r) {
+		for name, h := range resp.Components {
+			resp.Components[name] = &componentHealth{Status: h.Status}
+		}
+	}
+
+	aghhttp.WriteJSONResponse(w, r, code, resp)
+}
+
+// isHealthDetailsAllowed returns true if r is authenticated or if the
+// authentication isn't required.
+func isHealthDetailsAllowed(r *http.Request) (ok bool) {
+	a := Context.auth
+	if a == nil || !a.authRequired() {
+		return true
+	} else if glProcessCookie(r) {
+		return true
+	}
+
+	cookie, err := r.Cookie(sessionCookieName)
+	if err == nil {
+		return a.checkSession(cookie.Value) == checkSessionOK
+	}
+
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	_, ok = a.findUser(user, pass)
+
+	return ok
+}
+
+// checkReadiness performs all the readiness checks.
+func checkReadiness() (resp *healthResp) {
+	resp = &healthResp{
+		Components: map[string]*componentHealth{},
+		Status:     healthStatusOK,
+	}
+
+	if Context.firstRun {
+		resp.Components["install"] = healthFailed("initial setup isn't finished")
+		resp.Status = healthStatusFailed
+
+		return resp
+	}
+
+	for _, c := range readinessChecks {
+		h := c.check()
+		if h.Status == healthStatusFailed {
+			resp.Status = healthStatusFailed
+		}
+
+		resp.Components[c.name] = h
+	}
+
+	return resp
+}
+
+// checkDNSHealth checks if the DNS server is running and its listeners
+// respond.
+func checkDNSHealth() (h *componentHealth) {
+	if !isRunning() {
+		return healthFailed("dns server isn't running")
+	}
+
+	err := probeDNSListeners()
+	if err != nil {
+		return healthFailed("%s", err)
+	}
+
+	return healthOK()
+}
+
+// upstreamsHealthTTL is the duration for which the result of the upstream
+// servers check is cached, since it requires exchanging with each of them.
+const upstreamsHealthTTL = 30 * time.Second
+
+// upstreamsHealthCache is the cached result of the upstream servers check.
+type upstreamsHealthCache struct {
+	// mu protects all the fields.  It's never held during the check itself.
+	mu *sync.Mutex
+
+	// checking is closed when the check in progress is finished.  It's nil if
+	// there is no check in progress.
+	checking chan struct{}
+
+	// checked is the time of the last check.
+	checked time.Time
+
+	// healthy is the number of the healthy upstream servers.
+	healthy int
+
+	// total is the total number of the upstream servers.
+	total int
+}
+
+// upstreamsHealth is the global cache of the upstream servers check.
+var upstreamsHealth = &upstreamsHealthCache{
+	mu: &sync.Mutex{},
+}
+
+// result returns the cached result of the check, if it's fresh.  Otherwise, it
+// performs the check with check, unless another one is in progress, in which
+// case it waits for that one to finish.
+func (c *upstreamsHealthCache) result(check func() (healthy, total int)) (healthy, total int) {
+	wait, start := c.begin()
+	if start {
+		healthy, total = check()
+		c.finish(healthy, total)
+
+		return healthy, total
+	} else if wait != nil {
+		<-wait
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.healthy, c.total
+}
+
+// begin returns the channel closed when the check in progress is finished, if
+// there is one.  start is true if the cached result has expired, in which case
+// the caller must perform the check and call [upstreamsHealthCache.finish].
+func (c *upstreamsHealthCache) begin() (wait <-chan struct{}, start bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checking != nil {
+		return c.checking, false
+	} else if time.Since(c.checked) < upstreamsHealthTTL {
+		return nil, false
+	}
+
+	c.checking = make(chan struct{})
+
+	return nil, true
+}
+
+// finish saves the result of the check started with
+// [upstreamsHealthCache.begin] and wakes up the waiting callers.
+func (c *upstreamsHealthCache) finish(healthy, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.healthy, c.total = healthy, total
+	c.checked = time.Now()
+
+	close(c.checking)
+	c.checking = nil
+}
+
+// checkUpstreamsHealth checks if at least one of the general upstream servers
+// is healthy.
+func checkUpstreamsHealth() (h *componentHealth) {
+	if !isRunning() {
+		return healthFailed("dns server isn't running")
+	}
+
+	healthy, total := upstreamsHealth.result(Context.dnsServer.CheckUpstreams)
+	if healthy == 0 {
+		return healthFailed("none of %d upstream servers is healthy", total)
+	}
+
+	return healthOK()
+}
+
+// checkFiltersHealth checks if all the enabled filter lists are loaded.
+func checkFiltersHealth() (h *componentHealth) {
+	if Context.filters == nil {
+		return healthFailed("filtering isn't initialized")
+	} else if !Context.filters.Settings().FilteringEnabled {
+		return healthDisabled()
+	}
+
+	unloaded := Context.filters.UnloadedFilters()
+	if len(unloaded) > 0 {
+		return healthFailed("filters not loaded: %s", strings.Join(unloaded, ", "))
+	}
+
+	return healthOK()
+}
+
+// checkDHCPHealth checks if the DHCP server is serving, if it's enabled.
+func checkDHCPHealth() (h *componentHealth) {
+	var enabled bool
+	func() {
+		config.RLock()
+		defer config.RUnlock()
+
+		enabled = config.DHCP != nil && config.DHCP.Enabled
+	}()
+
+	if !enabled {
+		return healthDisabled()
+	} else if Context.dhcpServer == nil || !Context.dhcpServer.Enabled() {
+		return healthFailed("dhcp server isn't serving")
+	}
+
+	return healthOK()
+}
+
+// checkQueryLogHealth checks if the query log directory is writable, if the
+// query log is enabled.
+func checkQueryLogHealth() (h *componentHealth) {
+	var enabled bool
+	var dir string
+	func() {
+		config.RLock()
+		defer config.RUnlock()
+
+		enabled = config.QueryLog.Enabled && config.QueryLog.FileEnabled
+		dir = config.QueryLog.DirPath
+	}()
+
+	if !enabled {
+		return healthDisabled()
+	}
+
+	if dir == "" {
+		dir = Context.getDataDir()
+	}
+
+	err := checkDirWritable(dir)
+	if err != nil {
+		return healthFailed("directory %q isn't writable: %s", dir, err)
+	}
+
+	return healthOK()
+}
+
+// checkDirWritable returns an error if a file can't be created in dir.
+func checkDirWritable(dir string) (err error) {
+	f, err := os.CreateTemp(dir, ".health-*")
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
+
+// checkTLSHealth checks if the certificate hasn't expired, if encryption is
+// enabled.
+func checkTLSHealth() (h *componentHealth) {
+	if Context.tls == nil {
+		return healthDisabled()
+	}
+
+	notAfter, enabled := Context.tls.certNotAfter()
+	if !enabled {
+		return healthDisabled()
+	} else if notAfter.IsZero() {
+		return healthFailed("certificate isn't loaded")
+	} else if time.Now().After(notAfter) {
+		return healthFailed("certificate expired at %s", notAfter.Format(time.RFC3339))
+	}
+
+	return healthOK()
+}
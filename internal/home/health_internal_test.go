@@ -0,0 +1,133 @@
+package home
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHealthReady_firstRun(t *testing.T) {
+	prevFirstRun := Context.firstRun
+	t.Cleanup(func() { Context.firstRun = prevFirstRun })
+
+	Context.firstRun = true
+
+	r := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+	w := httptest.NewRecorder()
+
+	handleHealthReady(w, r)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	resp := &healthResp{}
+	err := json.NewDecoder(w.Body).Decode(resp)
+	require.NoError(t, err)
+
+	assert.Equal(t, healthStatusFailed, resp.Status)
+	require.Contains(t, resp.Components, "install")
+
+	assert.Equal(t, healthStatusFailed, resp.Components["install"].Status)
+}
+
+func TestCheckTLSHealth(t *testing.T) {
+	prevTLS := Context.tls
+	t.Cleanup(func() { Context.tls = prevTLS })
+
+	testCases := []struct {
+		notAfter   time.Time
+		name       string
+		wantStatus healthStatus
+		enabled    bool
+	}{{
+		notAfter:   time.Time{},
+		name:       "disabled",
+		wantStatus: healthStatusDisabled,
+		enabled:    false,
+	}, {
+		notAfter:   time.Time{},
+		name:       "not_loaded",
+		wantStatus: healthStatusFailed,
+		enabled:    true,
+	}, {
+		notAfter:   time.Now().Add(-time.Hour),
+		name:       "expired",
+		wantStatus: healthStatusFailed,
+		enabled:    true,
+	}, {
+		notAfter:   time.Now().Add(time.Hour),
+		name:       "valid",
+		wantStatus: healthStatusOK,
+		enabled:    true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			Context.tls = &tlsManager{
+				status: &tlsConfigStatus{
+					NotAfter: tc.notAfter,
+				},
+			}
+			Context.tls.conf.Enabled = tc.enabled
+
+			assert.Equal(t, tc.wantStatus, checkTLSHealth().Status)
+		})
+	}
+}
+
+func TestCheckDirWritable(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, checkDirWritable(dir))
+
+	assert.Error(t, checkDirWritable(dir+"/non-existent"))
+}
+
+func TestUpstreamsHealthCache_result(t *testing.T) {
+	c := &upstreamsHealthCache{
+		mu: &sync.Mutex{},
+	}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	checks := &atomic.Int64{}
+	check := func() (healthy, total int) {
+		if checks.Add(1) == 1 {
+			close(started)
+		}
+
+		<-unblock
+
+		return 1, 2
+	}
+
+	const n = 4
+
+	wg := &sync.WaitGroup{}
+	wg.Add(n)
+	for range n {
+		go func() {
+			defer wg.Done()
+
+			healthy, total := c.result(check)
+			assert.Equal(t, 1, healthy)
+			assert.Equal(t, 2, total)
+		}()
+	}
+
+	<-started
+
+	// The lock must not be held during the check.
+	require.True(t, c.mu.TryLock())
+	c.mu.Unlock()
+
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), checks.Load())
+}
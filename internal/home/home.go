@@ -2,7 +2,9 @@
 package home
 
 import (
+	"cmp"
 	"context"
+	"crypto/ed25519"
 	"crypto/x509"
 	"fmt"
 	"io/fs"
@@ -24,15 +26,24 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghos"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtls"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
+	"github.com/AdguardTeam/AdGuardHome/internal/anomaly"
 	"github.com/AdguardTeam/AdGuardHome/internal/arpdb"
+	"github.com/AdguardTeam/AdGuardHome/internal/bypass"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
 	"github.com/AdguardTeam/AdGuardHome/internal/dnsforward"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/catalog"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/hashprefix"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/safesearch"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/AdGuardHome/internal/secondary"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 	"github.com/AdguardTeam/AdGuardHome/internal/stats"
 	"github.com/AdguardTeam/AdGuardHome/internal/updater"
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
 	"github.com/AdguardTeam/AdGuardHome/internal/version"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/errors"
@@ -56,6 +67,30 @@ type homeContext struct {
 	filters    *filtering.DNSFilter // DNS filtering module
 	web        *webAPI              // Web (HTTP, HTTPS) module
 	tls        *tlsManager          // TLS module
+	bypass     *bypass.Detector     // DNS bypass prevention module
+	geoIP      *geoip.Resolver      // GeoIP module
+	anomaly    *anomaly.Detector    // DNS tunneling and DGA detection module
+	recursor   *recursor.Resolver   // Recursive resolution module
+	secondary  *secondary.Manager   // Secondary zones module
+	upsProxies *upsproxy.Proxies    // Upstream proxies module
+	catalog    *catalog.Catalog     // Filter list catalog module
+
+	// logs creates the loggers of the modules.  It is nil until the logger is
+	// configured, in which case the loggers use [slog.Default].
+	logs *moduleLoggers
+
+	// tracer records the spans of the DNS, DHCP, and HTTP API requests
+	// processing.  It is nil if the tracing is disabled.
+	tracer *aghtrace.Tracer
+
+	// sockets are the pre-bound sockets passed by the service manager.  It is
+	// nil if there are none.
+	sockets *socketact.Sockets
+
+	// addrWatcher notifies about the changes of the addresses of the network
+	// interfaces, which the servers are bound to.  It is nil if there are no
+	// such bindings.
+	addrWatcher aghnet.AddrWatcher
 
 	// etcHosts contains IP-hostname mappings taken from the OS-specific hosts
 	// configuration files, for example /etc/hosts.
@@ -94,6 +129,8 @@ var Context homeContext
 
 // Main is the entry point
 func Main(clientBuildFS fs.FS) {
+	cmdlineBench(os.Args)
+
 	initCmdLineOpts()
 
 	// The configuration file path can be overridden, but other command-line
@@ -283,6 +320,8 @@ func initContextClients() (err error) {
 	config.DHCP.DataDir = Context.getDataDir()
 	config.DHCP.HTTPRegister = httpRegister
 	config.DHCP.ConfigModified = onConfigModified
+	config.DHCP.Tracer = Context.tracer
+	config.DHCP.Logger = Context.logs.logger(logModuleDHCP)
 
 	Context.dhcpServer, err = dhcpd.Create(config.DHCP)
 	if Context.dhcpServer == nil || err != nil {
@@ -298,12 +337,21 @@ func initContextClients() (err error) {
 		arpDB = arpdb.New()
 	}
 
+	// Initialize the upstream proxies before the clients, since the upstreams
+	// of the persistent clients are validated against them.
+	err = initUpstreamProxies()
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
 	return Context.clients.Init(
 		config.Clients.Persistent,
 		Context.dhcpServer,
 		Context.etcHosts,
 		arpDB,
 		config.Filtering,
+		config.Clients.TagBlockingModes,
 	)
 }
 
@@ -361,6 +409,9 @@ func setupDNSFilteringConf(conf *filtering.Config) (err error) {
 		pcTXTSuffix           = `pc.dns.adguard.com.`
 	)
 
+	logger := Context.logs.logger(logModuleFiltering)
+	conf.Logger = logger
+
 	conf.EtcHosts = Context.etcHosts
 	// TODO(s.chzhen):  Use empty interface.
 	if Context.etcHosts == nil || !config.DNS.HostsFileEnabled {
@@ -398,6 +449,7 @@ func setupDNSFilteringConf(conf *filtering.Config) (err error) {
 
 	conf.SafeBrowsingChecker = hashprefix.New(&hashprefix.Config{
 		Upstream:    sbUps,
+		Logger:      logger,
 		ServiceName: sbService,
 		TXTSuffix:   sbTXTSuffix,
 		CacheTime:   cacheTime,
@@ -410,7 +462,7 @@ func setupDNSFilteringConf(conf *filtering.Config) (err error) {
 	// default.
 	if conf.SafeBrowsingBlockHost == "" {
 		host := defaultSafeBrowsingBlockHost
-		log.Info("%s: warning: empty blocking host; using default: %q", sbService, host)
+		logger.Warn("empty blocking host; using default", "service", sbService, "host", host)
 
 		conf.SafeBrowsingBlockHost = host
 	}
@@ -422,6 +474,7 @@ func setupDNSFilteringConf(conf *filtering.Config) (err error) {
 
 	conf.ParentalControlChecker = hashprefix.New(&hashprefix.Config{
 		Upstream:    parUps,
+		Logger:      logger,
 		ServiceName: pcService,
 		TXTSuffix:   pcTXTSuffix,
 		CacheTime:   cacheTime,
@@ -434,13 +487,14 @@ func setupDNSFilteringConf(conf *filtering.Config) (err error) {
 	// default.
 	if conf.ParentalBlockHost == "" {
 		host := defaultParentalBlockHost
-		log.Info("%s: warning: empty blocking host; using default: %q", pcService, host)
+		logger.Warn("empty blocking host; using default", "service", pcService, "host", host)
 
 		conf.ParentalBlockHost = host
 	}
 
 	conf.SafeSearchConf.CustomResolver = safeSearchResolver{}
 	conf.SafeSearch, err = safesearch.NewDefault(
+		logger,
 		conf.SafeSearchConf,
 		"default",
 		conf.SafeSearchCacheSize,
@@ -494,14 +548,7 @@ func initWeb(opts options, clientBuildFS fs.FS, upd *updater.Updater) (web *webA
 		}
 	}
 
-	disableUpdate := opts.disableUpdate
-	switch version.Channel() {
-	case
-		version.ChannelDevelopment,
-		version.ChannelCandidate:
-		disableUpdate = true
-	}
-
+	disableUpdate := isUpdateDisabled(opts)
 	if disableUpdate {
 		log.Info("AdGuard Home updates are disabled")
 	}
@@ -511,7 +558,8 @@ func initWeb(opts options, clientBuildFS fs.FS, upd *updater.Updater) (web *webA
 
 		clientFS: clientFS,
 
-		BindAddr: config.HTTPConfig.Address,
+		BindAddr:      config.HTTPConfig.Address,
+		BindInterface: config.HTTPConfig.BindInterface,
 
 		ReadTimeout:       readTimeout,
 		ReadHeaderTimeout: readHdrTimeout,
@@ -531,6 +579,23 @@ func initWeb(opts options, clientBuildFS fs.FS, upd *updater.Updater) (web *webA
 	return web, nil
 }
 
+// isUpdateDisabled returns true if AdGuard Home must not check for updates.
+func isUpdateDisabled(opts options) (ok bool) {
+	switch version.Channel() {
+	case
+		version.ChannelDevelopment,
+		version.ChannelCandidate:
+		return true
+	default:
+		return opts.disableUpdate
+	}
+}
+
+// updateChannel returns the channel used to check for updates.
+func updateChannel() (ch string) {
+	return cmp.Or(config.Update.Channel, version.Channel())
+}
+
 func fatalOnError(err error) {
 	if err != nil {
 		log.Fatal(err)
@@ -557,16 +622,30 @@ func run(opts options, clientBuildFS fs.FS, done chan struct{}) {
 		log.Info("AdGuard Home is running as a service")
 	}
 
+	err = initSockets()
+	fatalOnError(err)
+
+	// Print the migrated configuration before parsing it, since parsing
+	// upgrades the configuration file in place.
+	cmdlineMigrate(opts)
+
+	// Check for an unconfirmed update before parsing the configuration, since
+	// the new version may be unable to parse it.
+	pendingUpd := loadPendingUpdate(opts.runningAsService)
+
 	err = setupContext(opts)
 	fatalOnError(err)
 
+	err = initTracer()
+	fatalOnError(err)
+
 	err = configureOS(config)
 	fatalOnError(err)
 
 	// Clients package uses filtering package's static data
 	// (filtering.BlockedSvcKnown()), so we have to initialize filtering static
 	// data first, but also to avoid relying on automatic Go init() function.
-	filtering.InitModule()
+	filtering.InitModule(Context.logs.logger(logModuleFiltering))
 
 	err = initContextClients()
 	fatalOnError(err)
@@ -581,16 +660,19 @@ func run(opts options, clientBuildFS fs.FS, done chan struct{}) {
 		Scheme: "https",
 		// TODO(a.garipov): Make configurable.
 		Host: "static.adtidy.org",
-		Path: path.Join("adguardhome", version.Channel(), "version.json"),
+		Path: path.Join("adguardhome", updateChannel(), "version.json"),
 	}
 
 	confPath := configFilePath()
 	log.Debug("using config path %q for updater", confPath)
 
+	updPubKey, err := updatePublicKey()
+	fatalOnError(err)
+
 	upd := updater.NewUpdater(&updater.Config{
 		Client:          config.Filtering.HTTPClient,
 		Version:         version.Version(),
-		Channel:         version.Channel(),
+		Channel:         updateChannel(),
 		GOARCH:          runtime.GOARCH,
 		GOOS:            runtime.GOOS,
 		GOARM:           version.GOARM(),
@@ -599,6 +681,8 @@ func run(opts options, clientBuildFS fs.FS, done chan struct{}) {
 		ConfName:        confPath,
 		ExecPath:        execPath,
 		VersionCheckURL: u.String(),
+		MaxVersion:      config.Update.MaxVersion,
+		PublicKey:       updPubKey,
 	})
 
 	// TODO(e.burkov): This could be made earlier, probably as the option's
@@ -625,6 +709,8 @@ func run(opts options, clientBuildFS fs.FS, done chan struct{}) {
 	Context.auth, err = initUsers()
 	fatalOnError(err)
 
+	cmdlineInstall(opts)
+
 	Context.tls, err = newTLSManager(config.TLS, config.DNS.ServePlainDNS)
 	if err != nil {
 		log.Error("initializing tls: %s", err)
@@ -656,12 +742,38 @@ func run(opts options, clientBuildFS fs.FS, done chan struct{}) {
 		}
 	}
 
+	if pendingUpd != nil {
+		go superviseUpdate(pendingUpd, opts.runningAsService)
+	}
+
+	if !Context.firstRun && config.Update.AutoUpdate && !isUpdateDisabled(opts) {
+		go autoUpdate(upd, execPath, opts.runningAsService)
+	}
+
+	initAddrWatcher()
+
 	Context.web.start()
 
 	// Wait for other goroutines to complete their job.
 	<-done
 }
 
+// updatePublicKey returns the public key used to verify the update packages,
+// if the build has one.
+func updatePublicKey() (key ed25519.PublicKey, err error) {
+	keyStr := version.UpdatePublicKey()
+	if keyStr == "" {
+		return nil, nil
+	}
+
+	key, err = updater.ParsePublicKey(keyStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing update public key: %w", err)
+	}
+
+	return key, nil
+}
+
 // initUsers initializes context auth module.  Clears config users field.
 func initUsers() (auth *Auth, err error) {
 	sessFilename := filepath.Join(Context.getDataDir(), "sessions.db")
@@ -806,6 +918,12 @@ func initWorkingDir(opts options) (err error) {
 func cleanup(ctx context.Context) {
 	log.Info("stopping AdGuard Home")
 
+	if Context.addrWatcher != nil {
+		if err := Context.addrWatcher.Close(); err != nil {
+			log.Error("closing address watcher: %s", err)
+		}
+	}
+
 	if Context.web != nil {
 		Context.web.close(ctx)
 		Context.web = nil
@@ -836,6 +954,8 @@ func cleanup(ctx context.Context) {
 	if Context.tls != nil {
 		Context.tls = nil
 	}
+
+	shutdownTracer(ctx)
 }
 
 // This function is called before application exits
@@ -0,0 +1,216 @@
+package home
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"unicode/utf8"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// installSeed is the structure of the seed file used for the non-interactive
+// initial setup.  It contains the same settings as the ones the install wizard
+// sends to /control/install/check_config and /control/install/configure.
+type installSeed struct {
+	// Username is the name of the admin user.  It must not be empty.
+	Username string `yaml:"username"`
+
+	// Password is the password of the admin user.  It must be at least
+	// [PasswordMinRunes] long.
+	Password string `yaml:"password"`
+
+	// Web are the settings for the web interface.
+	Web installSeedEnt `yaml:"web"`
+
+	// DNS are the settings for the DNS server.
+	DNS installSeedEnt `yaml:"dns"`
+
+	// SetStaticIP, if true, makes AdGuard Home try to set the static IP address
+	// for the interface on which the DNS server is going to listen.
+	SetStaticIP bool `yaml:"set_static_ip"`
+}
+
+// installSeedEnt is the address part of the seed file.
+type installSeedEnt struct {
+	// IP is the address to bind to.
+	IP netip.Addr `yaml:"ip"`
+
+	// Port is the port to bind to.  It must not be zero.
+	Port uint16 `yaml:"port"`
+
+	// Autofix, if true, makes AdGuard Home try to free the port automatically,
+	// for example by disabling the DNSStubListener of systemd-resolved.
+	Autofix bool `yaml:"autofix"`
+}
+
+// readInstallSeed reads and validates the seed file at seedPath.
+func readInstallSeed(seedPath string) (seed *installSeed, err error) {
+	// #nosec G304 -- Trust the path explicitly given by the user.
+	data, err := os.ReadFile(seedPath)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return nil, err
+	}
+
+	seed = &installSeed{}
+	err = yaml.Unmarshal(data, seed)
+	if err != nil {
+		return nil, fmt.Errorf("parsing seed file: %w", err)
+	}
+
+	err = seed.validate()
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return nil, err
+	}
+
+	return seed, nil
+}
+
+// validate returns an error if the seed settings can't be applied.
+func (seed *installSeed) validate() (err error) {
+	switch {
+	case seed.Username == "":
+		return errors.Error("username: empty value")
+	case utf8.RuneCountInString(seed.Password) < PasswordMinRunes:
+		return fmt.Errorf("password must be at least %d symbols long", PasswordMinRunes)
+	case !seed.Web.IP.IsValid():
+		return errors.Error("web.ip: not a valid ip address")
+	case !seed.DNS.IP.IsValid():
+		return errors.Error("dns.ip: not a valid ip address")
+	case seed.Web.Port == 0 || seed.DNS.Port == 0:
+		return errors.Error("ports cannot be 0")
+	default:
+		return nil
+	}
+}
+
+// checkConfReq converts the seed into the request structure used by
+// [webAPI.handleInstallCheckConfig].
+func (seed *installSeed) checkConfReq() (req *checkConfReq) {
+	return &checkConfReq{
+		Web: checkConfReqEnt{
+			IP:      seed.Web.IP,
+			Port:    seed.Web.Port,
+			Autofix: seed.Web.Autofix,
+		},
+		DNS: checkConfReqEnt{
+			IP:      seed.DNS.IP,
+			Port:    seed.DNS.Port,
+			Autofix: seed.DNS.Autofix,
+		},
+		SetStaticIP: seed.SetStaticIP,
+	}
+}
+
+// Install result statuses.
+const (
+	installStatusOK    = "ok"
+	installStatusError = "error"
+)
+
+// installResult is the machine-readable result of the non-interactive initial
+// setup.
+type installResult struct {
+	// Check is the result of the same checks the install wizard performs.  It
+	// is nil if the checks haven't been performed.
+	Check *checkConfResp `json:"check,omitempty"`
+
+	// Status is either [installStatusOK] or [installStatusError].
+	Status string `json:"status"`
+
+	// Error is the description of the error, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// cmdlineInstall performs the initial setup using the seed file from opts,
+// prints the result to stdout as JSON, and exits.  It does nothing if no seed
+// file is provided.
+func cmdlineInstall(opts options) {
+	if opts.installConfig == "" {
+		return
+	}
+
+	log.Info("cmdline install: using seed file %q", opts.installConfig)
+
+	res := &installResult{
+		Status: installStatusOK,
+	}
+
+	err := installFromSeed(opts.installConfig, res)
+	if err != nil {
+		log.Error("cmdline install: %s", err)
+
+		res.Status = installStatusError
+		res.Error = err.Error()
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	encErr := enc.Encode(res)
+	if encErr != nil {
+		log.Error("cmdline install: writing result: %s", encErr)
+	}
+
+	if err != nil || encErr != nil {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// installFromSeed validates the settings from the seed file at seedPath the
+// same way the install wizard does and, if they are valid, applies them and
+// writes the configuration file.  res must not be nil.
+func installFromSeed(seedPath string, res *installResult) (err error) {
+	if !Context.firstRun {
+		return fmt.Errorf("configuration file %q already exists", configFilePath())
+	}
+
+	seed, err := readInstallSeed(seedPath)
+	if err != nil {
+		return fmt.Errorf("reading seed file: %w", err)
+	}
+
+	res.Check = seed.checkConfReq().validate()
+	if s := res.Check.Web.Status; s != "" {
+		return fmt.Errorf("checking web: %s", s)
+	} else if s = res.Check.DNS.Status; s != "" {
+		return fmt.Errorf("checking dns: %s", s)
+	} else if seed.SetStaticIP && res.Check.StaticIP.Static == "error" {
+		return fmt.Errorf("setting static ip: %s", res.Check.StaticIP.Error)
+	}
+
+	err = aghnet.CheckPort("udp", netip.AddrPortFrom(seed.DNS.IP, seed.DNS.Port))
+	if err != nil {
+		return fmt.Errorf("checking dns udp address: %w", err)
+	}
+
+	err = aghnet.CheckPort("tcp", netip.AddrPortFrom(seed.DNS.IP, seed.DNS.Port))
+	if err != nil {
+		return fmt.Errorf("checking dns tcp address: %w", err)
+	}
+
+	config.HTTPConfig.Address = netip.AddrPortFrom(seed.Web.IP, seed.Web.Port)
+	config.DNS.BindHosts = []netip.Addr{seed.DNS.IP}
+	config.DNS.Port = seed.DNS.Port
+
+	err = Context.auth.addUser(&webUser{Name: seed.Username}, seed.Password)
+	if err != nil {
+		return fmt.Errorf("adding user: %w", err)
+	}
+
+	err = config.write()
+	if err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+
+	Context.firstRun = false
+
+	return nil
+}
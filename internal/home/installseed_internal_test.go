@@ -0,0 +1,87 @@
+package home
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadInstallSeed(t *testing.T) {
+	const validSeed = `
+username: admin
+password: password123
+web:
+  ip: 0.0.0.0
+  port: 8080
+dns:
+  ip: 127.0.0.1
+  port: 5353
+  autofix: true
+`
+
+	testCases := []struct {
+		want       *installSeed
+		name       string
+		data       string
+		wantErrMsg string
+	}{{
+		want: &installSeed{
+			Username: "admin",
+			Password: "password123",
+			Web: installSeedEnt{
+				IP:   netip.IPv4Unspecified(),
+				Port: 8080,
+			},
+			DNS: installSeedEnt{
+				IP:      netip.MustParseAddr("127.0.0.1"),
+				Port:    5353,
+				Autofix: true,
+			},
+		},
+		name:       "valid",
+		data:       validSeed,
+		wantErrMsg: "",
+	}, {
+		want:       nil,
+		name:       "short_password",
+		data:       "username: admin\npassword: short\n",
+		wantErrMsg: "password must be at least 8 symbols long",
+	}, {
+		want:       nil,
+		name:       "no_username",
+		data:       "password: password123\n",
+		wantErrMsg: "username: empty value",
+	}, {
+		want: nil,
+		name: "no_web_ip",
+		data: "username: admin\npassword: password123\n" +
+			"dns:\n  ip: 127.0.0.1\n  port: 53\n",
+		wantErrMsg: "web.ip: not a valid ip address",
+	}, {
+		want: nil,
+		name: "zero_port",
+		data: "username: admin\npassword: password123\n" +
+			"web:\n  ip: 127.0.0.1\n" +
+			"dns:\n  ip: 127.0.0.1\n  port: 53\n",
+		wantErrMsg: "ports cannot be 0",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			seedPath := filepath.Join(t.TempDir(), "seed.yaml")
+			err := os.WriteFile(seedPath, []byte(tc.data), 0o600)
+			require.NoError(t, err)
+
+			seed, err := readInstallSeed(seedPath)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			if tc.want != nil {
+				assert.Equal(t, tc.want, seed)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package home
 import (
 	"cmp"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"runtime"
 
@@ -16,11 +18,18 @@ import (
 // for logger output.
 const configSyslog = "syslog"
 
-// configureLogger configures logger level and output.
+// configureLogger configures logger level, format, and output.
 func configureLogger(opts options) (err error) {
 	ls := getLogSettings(opts)
 
-	// Configure logger level.
+	levels, err := ls.moduleLevels()
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	}
+
+	// Configure logger level.  The per-module levels only apply to the loggers
+	// of the modules.
 	if ls.Verbose {
 		log.SetLevel(log.DEBUG)
 	}
@@ -29,34 +38,56 @@ func configureLogger(opts options) (err error) {
 	// happen pretty quickly.
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 
-	// Write logs to stdout by default.
-	if ls.File == "" {
-		return nil
-	}
+	var out, w io.Writer
+	switch ls.File {
+	case "":
+		// Write logs to stderr by default.
+		out = os.Stderr
+	case configSyslog:
+		if cmp.Or(ls.Format, logFormatText) != logFormatText {
+			log.Info("warning: log format isn't supported for syslog")
+		}
 
-	if ls.File == configSyslog {
 		// Use syslog where it is possible and eventlog on Windows.
 		err = aghos.ConfigureSyslog(serviceName)
 		if err != nil {
 			return fmt.Errorf("cannot initialize syslog: %w", err)
 		}
 
-		return nil
+		textLS := *ls
+		textLS.Format = logFormatText
+		Context.logs, _, err = newModuleLoggers(&textLS, levels, nil)
+
+		// Don't wrap the error, because it's informative enough as is.
+		return err
+	default:
+		logFilePath := ls.File
+		if !filepath.IsAbs(logFilePath) {
+			logFilePath = filepath.Join(Context.workDir, logFilePath)
+		}
+
+		out = &lumberjack.Logger{
+			Filename:   logFilePath,
+			Compress:   ls.Compress,
+			LocalTime:  ls.LocalTime,
+			MaxBackups: ls.MaxBackups,
+			MaxSize:    ls.MaxSize,
+			MaxAge:     ls.MaxAge,
+		}
+	}
+
+	Context.logs, w, err = newModuleLoggers(ls, levels, out)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return err
 	}
 
-	logFilePath := ls.File
-	if !filepath.IsAbs(logFilePath) {
-		logFilePath = filepath.Join(Context.workDir, logFilePath)
+	if w != out {
+		// The structured records contain their own time.
+		log.SetFlags(0)
 	}
 
-	log.SetOutput(&lumberjack.Logger{
-		Filename:   logFilePath,
-		Compress:   ls.Compress,
-		LocalTime:  ls.LocalTime,
-		MaxBackups: ls.MaxBackups,
-		MaxSize:    ls.MaxSize,
-		MaxAge:     ls.MaxAge,
-	})
+	log.SetOutput(w)
 
 	return nil
 }
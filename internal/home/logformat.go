@@ -0,0 +1,364 @@
+package home
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	stdlog "log"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+)
+
+// Log output formats.
+const (
+	// logFormatText is the classic free-form text format of [log].
+	logFormatText = "text"
+
+	// logFormatJSON is the format with one JSON object per line.
+	logFormatJSON = "json"
+
+	// logFormatLogfmt is the format with one line of key=value pairs per
+	// record.
+	logFormatLogfmt = "logfmt"
+)
+
+// logModuleKey is the key of the attribute containing the module name.
+const logModuleKey = "module"
+
+// Names of the modules having their own loggers.
+const (
+	logModuleDHCP      = "dhcpd"
+	logModuleDNS       = "dnsforward"
+	logModuleFiltering = "filtering"
+	logModuleQueryLog  = "querylog"
+	logModuleStats     = "stats"
+)
+
+// logModules are the names of all modules, which could be used in
+// [logSettings.Levels].
+var logModules = []string{
+	logModuleDHCP,
+	logModuleDNS,
+	logModuleFiltering,
+	logModuleQueryLog,
+	logModuleStats,
+}
+
+// moduleLoggers creates the loggers of the modules, which write the records
+// according to the log settings and filter them according to the per-module
+// levels.
+type moduleLoggers struct {
+	// handler writes the records of all modules.
+	handler slog.Handler
+
+	// levels are the minimum levels of the records for each module.
+	levels map[string]slog.Level
+
+	// level is the minimum level of the records for the modules not in levels.
+	level slog.Level
+}
+
+// newModuleLoggers returns the loggers of the modules writing the records
+// formatted according to ls to out and filtered according to levels.  If the
+// format is structured, legacyOut is the writer converting the lines written
+// by [log] into the records as well, otherwise it's out.
+func newModuleLoggers(
+	ls *logSettings,
+	levels map[string]slog.Level,
+	out io.Writer,
+) (ml *moduleLoggers, legacyOut io.Writer, err error) {
+	ml = &moduleLoggers{
+		levels: levels,
+		level:  slog.LevelInfo,
+	}
+
+	if ls.Verbose {
+		ml.level = slog.LevelDebug
+	}
+
+	hdlrOpts := &slog.HandlerOptions{
+		// The records are filtered by the loggers of the modules.
+		Level:       slog.LevelDebug,
+		ReplaceAttr: replaceTimeAttr(ls.LocalTime),
+	}
+
+	switch ls.Format {
+	case "", logFormatText:
+		ml.handler = newTextLogHandler()
+
+		return ml, out, nil
+	case logFormatJSON:
+		ml.handler = slog.NewJSONHandler(out, hdlrOpts)
+	case logFormatLogfmt:
+		ml.handler = slog.NewTextHandler(out, hdlrOpts)
+	default:
+		return nil, nil, fmt.Errorf("log: format: unsupported value %q", ls.Format)
+	}
+
+	return ml, &legacyLogWriter{handler: ml.handler}, nil
+}
+
+// logger returns the logger of module with the attribute containing its name.
+// ml may be nil, in which case the logger writes the records using
+// [slog.Default].
+func (ml *moduleLoggers) logger(module string) (l *slog.Logger) {
+	if ml == nil {
+		return slog.Default().With(logModuleKey, module)
+	}
+
+	lvl, ok := ml.levels[module]
+	if !ok {
+		lvl = ml.level
+	}
+
+	return slog.New(slogutil.NewLevelHandler(lvl, ml.handler)).With(logModuleKey, module)
+}
+
+// replaceTimeAttr returns a function for [slog.HandlerOptions.ReplaceAttr],
+// which converts the time of the records to UTC, unless localTime is true.
+func replaceTimeAttr(localTime bool) (f func(groups []string, a slog.Attr) (res slog.Attr)) {
+	if localTime {
+		return nil
+	}
+
+	return func(groups []string, a slog.Attr) (res slog.Attr) {
+		if len(groups) == 0 && a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+			a.Value = slog.TimeValue(a.Value.Time().UTC())
+		}
+
+		return a
+	}
+}
+
+// textLogHandler is a [slog.Handler] writing the records in the classic text
+// format of [log] using the standard logger, so that the date and time prefix
+// and the output are the same as the ones of [log].  The module name is written
+// as the prefix of the message, and the other attributes follow it as the
+// key=value pairs.
+type textLogHandler struct {
+	// mu protects buf.
+	mu *sync.Mutex
+
+	// buf is the buffer for the attributes formatted by attrs.
+	buf *bytes.Buffer
+
+	// attrs formats the attributes of the records into buf.
+	attrs slog.Handler
+
+	// module is the name of the module, which is written before the message.
+	module string
+}
+
+// newTextLogHandler returns a new properly initialized *textLogHandler.
+func newTextLogHandler() (h *textLogHandler) {
+	buf := &bytes.Buffer{}
+
+	return &textLogHandler{
+		mu:  &sync.Mutex{},
+		buf: buf,
+		attrs: slog.NewTextHandler(buf, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(groups []string, a slog.Attr) (res slog.Attr) {
+				if len(groups) == 0 && (a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+					return slog.Attr{}
+				}
+
+				return a
+			},
+		}),
+	}
+}
+
+// type check
+var _ slog.Handler = (*textLogHandler)(nil)
+
+// Enabled implements the [slog.Handler] interface for *textLogHandler.
+func (h *textLogHandler) Enabled(_ context.Context, _ slog.Level) (ok bool) {
+	return true
+}
+
+// Handle implements the [slog.Handler] interface for *textLogHandler.
+func (h *textLogHandler) Handle(ctx context.Context, r slog.Record) (err error) {
+	sb := &strings.Builder{}
+
+	switch {
+	case r.Level >= slog.LevelError:
+		sb.WriteString("[error] ")
+	case r.Level >= slog.LevelWarn:
+		sb.WriteString("[info] warning: ")
+	case r.Level >= slog.LevelInfo:
+		sb.WriteString("[info] ")
+	default:
+		sb.WriteString("[debug] ")
+	}
+
+	if h.module != "" {
+		sb.WriteString(h.module)
+		sb.WriteString(": ")
+	}
+
+	sb.WriteString(r.Message)
+
+	attrs, err := h.formatAttrs(ctx, r)
+	if err != nil {
+		return fmt.Errorf("formatting attrs: %w", err)
+	}
+
+	if attrs != "" {
+		sb.WriteByte(' ')
+		sb.WriteString(attrs)
+	}
+
+	// Skip this method and the [slog.Logger] methods.
+	const callDepth = 4
+
+	return stdlog.Output(callDepth, sb.String())
+}
+
+// formatAttrs returns the attributes of r and the ones of the handler as the
+// key=value pairs.
+func (h *textLogHandler) formatAttrs(ctx context.Context, r slog.Record) (s string, err error) {
+	// Don't copy the level and the message, which are written separately.
+	attrRec := slog.NewRecord(time.Time{}, slog.LevelInfo, "", 0)
+	r.Attrs(func(a slog.Attr) (cont bool) {
+		attrRec.AddAttrs(a)
+
+		return true
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	err = h.attrs.Handle(ctx, attrRec)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	return strings.TrimSpace(h.buf.String()), nil
+}
+
+// WithAttrs implements the [slog.Handler] interface for *textLogHandler.
+func (h *textLogHandler) WithAttrs(attrs []slog.Attr) (res slog.Handler) {
+	clone := *h
+
+	i := slices.IndexFunc(attrs, func(a slog.Attr) (ok bool) { return a.Key == logModuleKey })
+	if i >= 0 {
+		clone.module = attrs[i].Value.String()
+		attrs = slices.Delete(slices.Clone(attrs), i, i+1)
+	}
+
+	clone.attrs = h.attrs.WithAttrs(attrs)
+
+	return &clone
+}
+
+// WithGroup implements the [slog.Handler] interface for *textLogHandler.
+func (h *textLogHandler) WithGroup(name string) (res slog.Handler) {
+	clone := *h
+	clone.attrs = h.attrs.WithGroup(name)
+
+	return &clone
+}
+
+// legacyLogLevels are the level prefixes [log] writes before the messages.
+var legacyLogLevels = []struct {
+	prefix string
+	level  slog.Level
+}{{
+	prefix: "[debug] ",
+	level:  slog.LevelDebug,
+}, {
+	prefix: "[info] ",
+	level:  slog.LevelInfo,
+}, {
+	prefix: "[error] ",
+	level:  slog.LevelError,
+}, {
+	prefix: "[fatal] ",
+	level:  slog.LevelError,
+}, {
+	prefix: "[panic] ",
+	level:  slog.LevelError,
+}}
+
+// legacyLogWriter is an [io.Writer] converting the lines written by [log] with
+// no date and time flags into the structured records.  These records have no
+// module attribute, since the modules writing them don't have their own
+// loggers yet.
+type legacyLogWriter struct {
+	// handler writes the records.
+	handler slog.Handler
+}
+
+// type check
+var _ io.Writer = (*legacyLogWriter)(nil)
+
+// Write implements the [io.Writer] interface for *legacyLogWriter.  p is
+// expected to contain a single message written by [log], possibly spanning
+// several lines.
+func (w *legacyLogWriter) Write(p []byte) (n int, err error) {
+	lvl, msg := parseLegacyLogLine(strings.TrimSuffix(string(p), "\n"))
+
+	err = w.handler.Handle(context.Background(), slog.NewRecord(time.Now(), lvl, msg, 0))
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// parseLegacyLogLine returns the level and the message of the line written by
+// [log] with no date and time flags, which has the following format:
+//
+//	[PID#GOID ][level] message
+//
+// The PID and goroutine ID are only written in the debug mode.  If the line
+// has no level prefix, for example because it's written by the standard logger
+// directly, the level is [slog.LevelInfo] and msg is the line itself.
+func parseLegacyLogLine(line string) (lvl slog.Level, msg string) {
+	rest := line
+	if ids, after, ok := strings.Cut(line, " "); ok && strings.Contains(ids, "#") {
+		rest = after
+	}
+
+	for _, l := range legacyLogLevels {
+		if msg, ok := strings.CutPrefix(rest, l.prefix); ok {
+			return l.level, msg
+		}
+	}
+
+	return slog.LevelInfo, line
+}
+
+// moduleLevels parses and validates the per-module log levels.
+func (ls *logSettings) moduleLevels() (levels map[string]slog.Level, err error) {
+	if len(ls.Levels) == 0 {
+		return nil, nil
+	}
+
+	levels = make(map[string]slog.Level, len(ls.Levels))
+	for module, lvlStr := range ls.Levels {
+		if !slices.Contains(logModules, module) {
+			return nil, fmt.Errorf("log: levels: unknown module %q", module)
+		}
+
+		var lvl slog.Level
+		err = lvl.UnmarshalText([]byte(lvlStr))
+		if err != nil {
+			return nil, fmt.Errorf("log: levels: module %q: %w", module, err)
+		}
+
+		levels[module] = lvl
+	}
+
+	return levels, nil
+}
@@ -0,0 +1,199 @@
+package home
+
+import (
+	"bytes"
+	"encoding/json"
+	stdlog "log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModuleLoggers_structured(t *testing.T) {
+	ls := &logSettings{
+		Levels: map[string]string{
+			logModuleDNS:      "debug",
+			logModuleQueryLog: "error",
+		},
+		Format: logFormatJSON,
+	}
+
+	levels, err := ls.moduleLevels()
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	ml, legacyOut, err := newModuleLoggers(ls, levels, buf)
+	require.NoError(t, err)
+
+	ml.logger(logModuleDNS).Debug("handling query", "client", "192.0.2.1")
+	ml.logger(logModuleFiltering).Debug("checking host")
+	ml.logger(logModuleFiltering).Info("initialized")
+	ml.logger(logModuleQueryLog).Info("flushing")
+	ml.logger(logModuleQueryLog).Error("cannot flush")
+
+	_, err = legacyOut.Write([]byte("[info] no module here\n"))
+	require.NoError(t, err)
+
+	type record struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Module string `json:"module"`
+		Client string `json:"client"`
+	}
+
+	want := []record{{
+		Level:  "DEBUG",
+		Msg:    "handling query",
+		Module: logModuleDNS,
+		Client: "192.0.2.1",
+	}, {
+		Level:  "INFO",
+		Msg:    "initialized",
+		Module: logModuleFiltering,
+	}, {
+		Level:  "ERROR",
+		Msg:    "cannot flush",
+		Module: logModuleQueryLog,
+	}, {
+		Level: "INFO",
+		Msg:   "no module here",
+	}}
+
+	got := []record{}
+	for _, l := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		r := record{}
+		err = json.Unmarshal([]byte(l), &r)
+		require.NoError(t, err)
+
+		got = append(got, r)
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestModuleLoggers_text(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	prevOut, prevFlags := stdlog.Writer(), stdlog.Flags()
+	t.Cleanup(func() {
+		stdlog.SetOutput(prevOut)
+		stdlog.SetFlags(prevFlags)
+	})
+
+	stdlog.SetOutput(buf)
+	stdlog.SetFlags(0)
+
+	ls := &logSettings{
+		Verbose: true,
+		Format:  logFormatText,
+	}
+
+	ml, legacyOut, err := newModuleLoggers(ls, nil, buf)
+	require.NoError(t, err)
+
+	assert.Same(t, buf, legacyOut)
+
+	ml.logger(logModuleDHCP).Debug("sending", "client", "192.0.2.1", "len", 300)
+	ml.logger(logModuleStats).Warn("flushing", slogutil.KeyError, "disk full")
+	ml.logger(logModuleFiltering).Error("loading filter")
+
+	want := "[debug] dhcpd: sending client=192.0.2.1 len=300\n" +
+		"[info] warning: stats: flushing err=\"disk full\"\n" +
+		"[error] filtering: loading filter\n"
+
+	assert.Equal(t, want, buf.String())
+}
+
+func TestParseLegacyLogLine(t *testing.T) {
+	testCases := []struct {
+		line      string
+		wantMsg   string
+		name      string
+		wantLevel slog.Level
+	}{{
+		line:      "[debug] checking host",
+		wantMsg:   "checking host",
+		name:      "debug",
+		wantLevel: slog.LevelDebug,
+	}, {
+		line:      "[error] cannot open file",
+		wantMsg:   "cannot open file",
+		name:      "error",
+		wantLevel: slog.LevelError,
+	}, {
+		line:      "1234#56 [info] starting",
+		wantMsg:   "starting",
+		name:      "ids",
+		wantLevel: slog.LevelInfo,
+	}, {
+		line:      "no level here",
+		wantMsg:   "no level here",
+		name:      "no_level",
+		wantLevel: slog.LevelInfo,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lvl, msg := parseLegacyLogLine(tc.line)
+			assert.Equal(t, tc.wantLevel, lvl)
+			assert.Equal(t, tc.wantMsg, msg)
+		})
+	}
+}
+
+func TestLogSettings_moduleLevels(t *testing.T) {
+	testCases := []struct {
+		levels     map[string]string
+		want       map[string]slog.Level
+		name       string
+		wantErrMsg string
+	}{{
+		levels:     nil,
+		want:       nil,
+		name:       "empty",
+		wantErrMsg: "",
+	}, {
+		levels: map[string]string{
+			"dhcpd":     "warn",
+			"filtering": "DEBUG",
+		},
+		want: map[string]slog.Level{
+			"dhcpd":     slog.LevelWarn,
+			"filtering": slog.LevelDebug,
+		},
+		name:       "valid",
+		wantErrMsg: "",
+	}, {
+		levels: map[string]string{
+			"unknown": "debug",
+		},
+		want:       nil,
+		name:       "bad_module",
+		wantErrMsg: `log: levels: unknown module "unknown"`,
+	}, {
+		levels: map[string]string{
+			"stats": "verbose",
+		},
+		want:       nil,
+		name:       "bad_level",
+		wantErrMsg: `log: levels: module "stats": slog: level string "verbose": unknown name`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ls := &logSettings{
+				Levels: tc.levels,
+			}
+
+			got, err := ls.moduleLevels()
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package home
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/configmigrate"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// cmdlineMigrate prints the configuration file downgraded to the schema version
+// from opts or the changes the migration would make, if requested, and exits.
+// It does nothing if neither is requested.  The configuration file itself is
+// never modified.
+func cmdlineMigrate(opts options) {
+	if !opts.migrateDryRun && opts.downgradeSchema == 0 {
+		return
+	}
+
+	out, err := migrateConfigFile(opts)
+	if err != nil {
+		log.Error("cmdline migrate: %s", err)
+
+		os.Exit(1)
+	}
+
+	_, err = fmt.Fprint(os.Stdout, out)
+	if err != nil {
+		log.Error("cmdline migrate: writing result: %s", err)
+
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// migrateConfigFile returns either the configuration file migrated to the
+// target schema version from opts or the difference between the current and
+// the migrated versions, if opts.migrateDryRun is true.  If no target schema
+// version is set, [configmigrate.LastSchemaVersion] is used.
+func migrateConfigFile(opts options) (out string, err error) {
+	confPath := configFilePath()
+
+	// #nosec G304 -- Trust the path to the configuration file, since it's
+	// either the default one or explicitly given by the user.
+	body, err := os.ReadFile(confPath)
+	if err != nil {
+		return "", fmt.Errorf("reading config file: %w", err)
+	}
+
+	migrator := configmigrate.New(&configmigrate.Config{
+		WorkingDir: Context.workDir,
+	})
+
+	target := configmigrate.LastSchemaVersion
+	if opts.downgradeSchema != 0 {
+		target = opts.downgradeSchema
+	}
+
+	if opts.migrateDryRun {
+		// Don't wrap the error, because it's informative enough as is.
+		return migrator.DryRun(body, target)
+	}
+
+	newBody, _, err := migrator.Downgrade(body, target)
+	if err != nil {
+		// Don't wrap the error, because it's informative enough as is.
+		return "", err
+	}
+
+	return string(newBody), nil
+}
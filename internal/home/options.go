@@ -9,6 +9,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/configmigrate"
 	"github.com/AdguardTeam/AdGuardHome/internal/version"
+	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/stringutil"
 )
@@ -55,9 +56,27 @@ type options struct {
 	// disableUpdate, if set, makes AdGuard Home not check for updates.
 	disableUpdate bool
 
+	// installConfig is the path to the seed file for the non-interactive
+	// initial setup.  If set, AdGuard Home performs the setup, prints the
+	// result, and exits.
+	installConfig string
+
 	// performUpdate, if set, updates AdGuard Home without GUI and exits.
 	performUpdate bool
 
+	// downgradeSchema is the schema version to downgrade the configuration file
+	// to.  If set, AdGuard Home prints the downgraded configuration and exits.
+	// Zero means no downgrade.
+	downgradeSchema uint
+
+	// migrateDryRun, if set, makes AdGuard Home print the changes the migration
+	// of the configuration file would make and exit.
+	migrateDryRun bool
+
+	// socketActivation, if set, makes the service installation also install
+	// the systemd socket units for the configured addresses.
+	socketActivation bool
+
 	// verbose shows if verbose logging is enabled.
 	verbose bool
 
@@ -251,6 +270,54 @@ var cmdLineOpts = []cmdLineOpt{{
 	description:     "Update the current binary and restart the service in case it's installed.",
 	longName:        "update",
 	shortName:       "",
+}, {
+	updateWithValue: func(o options, v string) (options, error) {
+		ver, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			err = fmt.Errorf("parsing schema version: %w", err)
+		} else if ver == 0 {
+			err = errors.Error("schema version must be positive")
+		} else {
+			o.downgradeSchema = uint(ver)
+		}
+
+		return o, err
+	},
+	updateNoValue: nil,
+	effect:        nil,
+	serialize:     func(o options) (val string, ok bool) { return "", false },
+	description: "Print the configuration file converted to the given older " +
+		"schema version and exit.  The configuration file isn't modified.",
+	longName:  "downgrade-config",
+	shortName: "",
+}, {
+	updateWithValue: nil,
+	updateNoValue:   func(o options) (options, error) { o.migrateDryRun = true; return o, nil },
+	effect:          nil,
+	serialize:       func(o options) (val string, ok bool) { return "", false },
+	description: "Print the changes the migration of the configuration file " +
+		"would make as a unified diff and exit.  Use with --downgrade-config to " +
+		"see the changes of the downgrade.",
+	longName:  "migrate-dry-run",
+	shortName: "",
+}, {
+	updateWithValue: func(o options, v string) (options, error) { o.installConfig = v; return o, nil },
+	updateNoValue:   nil,
+	effect:          nil,
+	serialize:       func(o options) (val string, ok bool) { return "", false },
+	description: "Perform the initial setup non-interactively using the settings " +
+		"from the YAML seed file, print the result as JSON, and exit.",
+	longName:  "install-config",
+	shortName: "",
+}, {
+	updateWithValue: nil,
+	updateNoValue:   func(o options) (options, error) { o.socketActivation = true; return o, nil },
+	effect:          nil,
+	serialize:       func(o options) (val string, ok bool) { return "", false },
+	description: "Install the systemd socket units for the configured addresses " +
+		"along with the service.  Use with -s install.",
+	longName:  "socket-activation",
+	shortName: "",
 }, {
 	updateWithValue: nil,
 	updateNoValue:   nil,
@@ -335,7 +402,12 @@ func printHelp(exec string) {
 	stringutil.WriteToBuilder(
 		b,
 		"Usage:\n\n",
-		fmt.Sprintf("%s [options]\n\n", exec),
+		fmt.Sprintf("%s [options]\n", exec),
+		fmt.Sprintf(
+			"%[1]s %[2]s [options] (DNS benchmark, see %[1]s %[2]s -help)\n\n",
+			exec,
+			benchCmdName,
+		),
 		"Options:\n",
 	)
 
@@ -125,6 +125,28 @@ func TestParsePerformUpdate(t *testing.T) {
 	assert.True(t, testParseOK(t, "--update").performUpdate, "--update is perform update")
 }
 
+func TestParseInstallConfig(t *testing.T) {
+	assert.Equal(t, "", testParseOK(t).installConfig, "empty is no install config")
+	assert.Equal(t, "path", testParseOK(t, "--install-config", "path").installConfig, "--install-config is install config")
+	testParseParamMissing(t, "--install-config")
+}
+
+func TestParseSocketActivation(t *testing.T) {
+	assert.False(t, testParseOK(t).socketActivation, "empty is no socket activation")
+	assert.True(t, testParseOK(t, "--socket-activation").socketActivation, "--socket-activation is socket activation")
+}
+
+func TestParseMigrate(t *testing.T) {
+	assert.Zero(t, testParseOK(t).downgradeSchema, "empty is no downgrade")
+	assert.Equal(t, uint(23), testParseOK(t, "--downgrade-config", "23").downgradeSchema, "--downgrade-config is downgrade")
+	testParseParamMissing(t, "--downgrade-config")
+	testParseErr(t, "zero schema version", "--downgrade-config", "0")
+	testParseErr(t, "not a number", "--downgrade-config", "x")
+
+	assert.False(t, testParseOK(t).migrateDryRun, "empty is not dry run")
+	assert.True(t, testParseOK(t, "--migrate-dry-run").migrateDryRun, "--migrate-dry-run is dry run")
+}
+
 // TODO(e.burkov):  Remove after v0.108.0.
 func TestParseDisableMemoryOptimization(t *testing.T) {
 	o, eff, err := parseCmdOpts("", []string{"--no-mem-optimization"})
@@ -0,0 +1,101 @@
+package home
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+)
+
+// probeTimeout is the timeout of a single probe of a listener.
+const probeTimeout = 2 * time.Second
+
+// probeAddr returns the address to probe the listener bound to ip on port.
+// The unspecified addresses are probed over the loopback of the same family.
+func probeAddr(ip netip.Addr, port uint16) (addr string) {
+	if ip.IsUnspecified() {
+		if ip.Is4() {
+			ip = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+		} else {
+			ip = netip.IPv6Loopback()
+		}
+	}
+
+	return netip.AddrPortFrom(ip, port).String()
+}
+
+// probeDNSListeners sends a request without questions to each of the plain
+// DNS listeners from the configuration over UDP and TCP.  Such requests are
+// answered with an error code right away, without involving the upstream
+// servers, so any response means that the listener is bound and served.  It
+// returns an error if any of the listeners doesn't respond.
+func probeDNSListeners() (err error) {
+	var hosts []netip.Addr
+	var port uint16
+	var servePlain bool
+	func() {
+		config.RLock()
+		defer config.RUnlock()
+
+		hosts = slices.Clone(config.DNS.BindHosts)
+		port = config.DNS.Port
+		servePlain = config.DNS.ServePlainDNS
+	}()
+
+	if !servePlain || port == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, h := range hosts {
+		addr := probeAddr(h, port)
+		for _, network := range []string{"udp", "tcp"} {
+			errs = append(errs, probeDNS(network, addr))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// probeDNS sends a request without questions to the DNS listener on addr over
+// network and returns an error if there is no response.
+func probeDNS(network, addr string) (err error) {
+	cli := &dns.Client{
+		Net:     network,
+		Timeout: probeTimeout,
+	}
+
+	req := &dns.Msg{}
+	req.Id = dns.Id()
+
+	_, _, err = cli.Exchange(req, addr)
+	if err != nil {
+		return fmt.Errorf("probing dns on %s://%s: %w", network, addr, err)
+	}
+
+	return nil
+}
+
+// probeWebListener connects to the web listener from the configuration and
+// returns an error if the connection fails.
+func probeWebListener() (err error) {
+	var addrPort netip.AddrPort
+	func() {
+		config.RLock()
+		defer config.RUnlock()
+
+		addrPort = config.HTTPConfig.Address
+	}()
+
+	addr := probeAddr(addrPort.Addr(), addrPort.Port())
+	conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+	if err != nil {
+		return fmt.Errorf("probing web on %s: %w", addr, err)
+	}
+
+	return conn.Close()
+}
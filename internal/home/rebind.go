@@ -0,0 +1,156 @@
+package home
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+)
+
+// rebindDelay is the time to wait for the related changes of the interfaces
+// after the first one before rebinding, since a single reconfiguration of an
+// interface usually causes several notifications.
+const rebindDelay = 1 * time.Second
+
+// dnsBindHosts returns the addresses to serve DNS on, which are the configured
+// ones and the current addresses of the configured interfaces.  If there are
+// none, it returns the IPv4 localhost.  dnsConf must not be nil.
+func dnsBindHosts(dnsConf *dnsConfig) (hosts []netip.Addr) {
+	hosts = slices.Clone(dnsConf.BindHosts)
+
+	// Binding to both an unspecified address and a specific one of the same
+	// port fails, and the unspecified one already covers the interfaces.
+	if names := dnsConf.BindInterfaces; len(names) > 0 &&
+		!slices.ContainsFunc(hosts, netip.Addr.IsUnspecified) {
+		ifaceAddrs, err := aghnet.InterfacesAddrs(names)
+		if err != nil {
+			log.Error("dns: getting addresses of bind interfaces: %s", err)
+		}
+
+		for _, addr := range ifaceAddrs {
+			if !slices.Contains(hosts, addr) {
+				hosts = append(hosts, addr)
+			}
+		}
+	}
+
+	return aghalg.CoalesceSlice(hosts, []netip.Addr{netutil.IPv4Localhost()})
+}
+
+// webBindAddrs returns the addresses to serve the web UI on.  If ifaceName is
+// not empty, these are the current addresses of the interface with the port
+// of addr.  Otherwise, or if the interface has no addresses, it's addr.
+func webBindAddrs(addr netip.AddrPort, ifaceName string) (addrs []netip.AddrPort) {
+	if ifaceName == "" {
+		return []netip.AddrPort{addr}
+	}
+
+	ips, err := aghnet.InterfacesAddrs([]string{ifaceName})
+	if err != nil {
+		log.Error("web: getting addresses of bind interface: %s", err)
+	} else if len(ips) == 0 {
+		log.Info("web: warning: interface %q has no addresses; using %s", ifaceName, addr)
+	}
+
+	if len(ips) == 0 {
+		return []netip.AddrPort{addr}
+	}
+
+	for _, ip := range ips {
+		addrs = append(addrs, netip.AddrPortFrom(ip, addr.Port()))
+	}
+
+	return addrs
+}
+
+// initAddrWatcher starts watching the addresses of the network interfaces, if
+// the DNS or the web servers are bound to any.
+func initAddrWatcher() {
+	if len(config.DNS.BindInterfaces) == 0 && config.HTTPConfig.BindInterface == "" {
+		return
+	}
+
+	w, err := aghnet.NewAddrWatcher()
+	if err != nil {
+		log.Info("warning: not rebinding on interface address changes: %s", err)
+
+		return
+	}
+
+	err = w.Start()
+	if err != nil {
+		log.Error("starting address watcher: %s", err)
+
+		return
+	}
+
+	Context.addrWatcher = w
+
+	go handleAddrChanges(w.Events())
+}
+
+// handleAddrChanges rebinds the DNS and the web servers when the addresses of
+// their interfaces change.  It is intended to be used as a goroutine.
+func handleAddrChanges(events <-chan struct{}) {
+	defer log.OnPanic("handling address changes")
+
+	dnsHosts, webAddrs := currentBindAddrs()
+
+	for range events {
+		time.Sleep(rebindDelay)
+
+		// Skip the event about the changes which happened during the delay.
+		select {
+		case <-events:
+			// Go on.
+		default:
+			// Go on.
+		}
+
+		newDNSHosts, newWebAddrs := currentBindAddrs()
+
+		if !slices.Equal(dnsHosts, newDNSHosts) {
+			dnsHosts = newDNSHosts
+			rebindDNS(dnsHosts)
+		}
+
+		if !slices.Equal(webAddrs, newWebAddrs) {
+			webAddrs = newWebAddrs
+			log.Info("web: addresses changed to %s; rebinding", webAddrs)
+			if web := Context.web; web != nil {
+				web.rebind(context.Background())
+			}
+		}
+	}
+}
+
+// currentBindAddrs returns the current addresses to serve DNS and the web UI
+// on.
+func currentBindAddrs() (dnsHosts []netip.Addr, webAddrs []netip.AddrPort) {
+	config.RLock()
+	defer config.RUnlock()
+
+	dnsHosts = dnsBindHosts(&config.DNS)
+	webAddrs = webBindAddrs(config.HTTPConfig.Address, config.HTTPConfig.BindInterface)
+
+	return dnsHosts, webAddrs
+}
+
+// rebindDNS reconfigures the DNS server, if it's running, to serve on hosts.
+func rebindDNS(hosts []netip.Addr) {
+	if !isRunning() {
+		return
+	}
+
+	log.Info("dns: addresses changed to %s; rebinding", hosts)
+
+	err := reconfigureDNSServer()
+	if err != nil {
+		log.Error("dns: rebinding: %s", err)
+	}
+}
@@ -0,0 +1,56 @@
+package home
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSBindHosts(t *testing.T) {
+	addr := netip.MustParseAddr("192.168.0.1")
+
+	testCases := []struct {
+		name string
+		conf *dnsConfig
+		want []netip.Addr
+	}{{
+		name: "empty",
+		conf: &dnsConfig{},
+		want: []netip.Addr{netutil.IPv4Localhost()},
+	}, {
+		name: "hosts",
+		conf: &dnsConfig{
+			BindHosts: []netip.Addr{addr},
+		},
+		want: []netip.Addr{addr},
+	}, {
+		name: "unspecified",
+		conf: &dnsConfig{
+			BindHosts:      []netip.Addr{netip.IPv4Unspecified()},
+			BindInterfaces: []string{"lo"},
+		},
+		want: []netip.Addr{netip.IPv4Unspecified()},
+	}, {
+		name: "no_interface",
+		conf: &dnsConfig{
+			BindHosts:      []netip.Addr{addr},
+			BindInterfaces: []string{"nonexistent0"},
+		},
+		want: []netip.Addr{addr},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, dnsBindHosts(tc.conf))
+		})
+	}
+}
+
+func TestWebBindAddrs(t *testing.T) {
+	addr := netip.MustParseAddrPort("127.0.0.1:3000")
+
+	assert.Equal(t, []netip.AddrPort{addr}, webBindAddrs(addr, ""))
+	assert.Equal(t, []netip.AddrPort{addr}, webBindAddrs(addr, "nonexistent0"))
+}
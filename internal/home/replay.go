@@ -0,0 +1,343 @@
+package home
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
+	"github.com/AdguardTeam/AdGuardHome/internal/querylog"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/timeutil"
+)
+
+const (
+	// defaultReplayPeriod is the default period of the query log history
+	// replayed.
+	defaultReplayPeriod = 7 * timeutil.Day
+
+	// defaultReplayLimit is the default maximum number of the domains and the
+	// clients in each list of the replay response.
+	defaultReplayLimit = 100
+
+	// maxReplayLimit is the maximum value of the limit in the replay request.
+	maxReplayLimit = 1000
+)
+
+// replayReq is the request to the POST /control/querylog/replay HTTP API.
+type replayReq struct {
+	// Since is the time since which the history is replayed.  If zero, the
+	// history for the last week is replayed.
+	Since time.Time `json:"since"`
+
+	// URLs are the URLs or the absolute paths of the additional blocklists.
+	URLs []string `json:"urls"`
+
+	// Rules are the additional custom filtering rules.
+	Rules []string `json:"rules"`
+
+	// BlockedServices are the IDs of the additional blocked services.
+	BlockedServices []string `json:"blocked_services"`
+
+	// DisabledFilterIDs are the IDs of the currently enabled blocklists, which
+	// are disabled in the candidate configuration.
+	DisabledFilterIDs []rulelist.URLFilterID `json:"disabled_filter_ids"`
+
+	// Limit is the maximum number of the domains and the clients in each list
+	// of the response.
+	Limit int `json:"limit"`
+}
+
+// replayDomainJSON is a domain, the filtering of which is changed by the
+// candidate configuration.
+type replayDomainJSON struct {
+	// Domain is the requested hostname.
+	Domain string `json:"domain"`
+
+	// Rule is the text of the rule, which blocks the domain in the candidate
+	// configuration for the newly blocked domains or in the current one for
+	// the newly unblocked domains.
+	Rule string `json:"rule"`
+
+	// Count is the number of the requests for the domain.
+	Count uint64 `json:"count"`
+
+	// ClientsNum is the number of the unique clients, which requested the
+	// domain.
+	ClientsNum int `json:"clients_num"`
+}
+
+// replayClientJSON is a client, the requests of which are affected by the
+// candidate configuration.
+type replayClientJSON struct {
+	// Client is the ClientID or the IP address of the client.
+	Client string `json:"client"`
+
+	// NewlyBlocked is the number of the requests of the client, which would
+	// be blocked.
+	NewlyBlocked uint64 `json:"newly_blocked"`
+
+	// NewlyUnblocked is the number of the requests of the client, which would
+	// not be blocked anymore.
+	NewlyUnblocked uint64 `json:"newly_unblocked"`
+}
+
+// replayResp is the response to the POST /control/querylog/replay HTTP API.
+type replayResp struct {
+	NewlyBlocked   []*replayDomainJSON `json:"newly_blocked"`
+	NewlyUnblocked []*replayDomainJSON `json:"newly_unblocked"`
+	Clients        []*replayClientJSON `json:"clients"`
+
+	// Processed is the number of the replayed requests.
+	Processed uint64 `json:"processed"`
+
+	// NewlyBlockedNum is the total number of the requests, which would be
+	// blocked.
+	NewlyBlockedNum uint64 `json:"newly_blocked_num"`
+
+	// NewlyUnblockedNum is the total number of the requests, which would not
+	// be blocked anymore.
+	NewlyUnblockedNum uint64 `json:"newly_unblocked_num"`
+}
+
+// replayDomain is the accumulated statistics of a domain.
+type replayDomain struct {
+	clients map[string]struct{}
+	rule    string
+	count   uint64
+}
+
+// replayStats accumulates the results of the replay.
+type replayStats struct {
+	blocked   map[string]*replayDomain
+	unblocked map[string]*replayDomain
+	clients   map[string]*replayClientJSON
+
+	// setts caches the filtering settings of the clients by their keys.
+	setts map[string]*filtering.Settings
+
+	processed    uint64
+	blockedNum   uint64
+	unblockedNum uint64
+}
+
+// newReplayStats returns a new properly initialized *replayStats.
+func newReplayStats() (s *replayStats) {
+	return &replayStats{
+		blocked:   map[string]*replayDomain{},
+		unblocked: map[string]*replayDomain{},
+		clients:   map[string]*replayClientJSON{},
+		setts:     map[string]*filtering.Settings{},
+	}
+}
+
+// settings returns the current filtering settings for the client.
+func (s *replayStats) settings(
+	key string,
+	ip netip.Addr,
+	clientID string,
+) (setts *filtering.Settings) {
+	setts, ok := s.setts[key]
+	if ok {
+		return setts
+	}
+
+	setts = Context.filters.Settings()
+	applyAdditionalFiltering(ip, clientID, setts)
+
+	// Evaluate the rules as if the protection was enabled to see the
+	// difference even when it's paused.
+	setts.ProtectionEnabled = true
+	s.setts[key] = setts
+
+	return setts
+}
+
+// add records the change of the filtering of the request of the client.
+func (s *replayStats) add(domains map[string]*replayDomain, host, client, rule string) {
+	d, ok := domains[host]
+	if !ok {
+		d = &replayDomain{
+			clients: map[string]struct{}{},
+			rule:    rule,
+		}
+		domains[host] = d
+	}
+
+	d.count++
+	d.clients[client] = struct{}{}
+}
+
+// client returns the statistics of the client creating it if necessary.
+func (s *replayStats) client(client string) (c *replayClientJSON) {
+	c, ok := s.clients[client]
+	if !ok {
+		c = &replayClientJSON{
+			Client: client,
+		}
+		s.clients[client] = c
+	}
+
+	return c
+}
+
+// check checks the entry against the current and the candidate configurations
+// and records the difference.
+func (s *replayStats) check(cand *filtering.Candidate, e *querylog.ReplayEntry) {
+	s.processed++
+
+	client := e.ClientID
+	if client == "" {
+		client = e.ClientIP.String()
+	}
+
+	setts := s.settings(client, e.ClientIP, e.ClientID)
+	cur, next, err := cand.Check(e.Host, e.QType, setts)
+	if err != nil {
+		log.Debug("replay: checking %q: %s", e.Host, err)
+
+		return
+	}
+
+	switch {
+	case !cur.IsFiltered && next.IsFiltered:
+		s.blockedNum++
+		s.client(client).NewlyBlocked++
+		s.add(s.blocked, e.Host, client, resultRuleText(next))
+	case cur.IsFiltered && !next.IsFiltered:
+		s.unblockedNum++
+		s.client(client).NewlyUnblocked++
+		s.add(s.unblocked, e.Host, client, resultRuleText(cur))
+	default:
+		// Go on.
+	}
+}
+
+// resultRuleText returns the text of the first rule of res, if any.
+func resultRuleText(res filtering.Result) (text string) {
+	if len(res.Rules) == 0 {
+		return ""
+	}
+
+	return res.Rules[0].Text
+}
+
+// toJSON returns the response with at most limit items in each list sorted by
+// the number of the requests.
+func (s *replayStats) toJSON(limit int) (resp *replayResp) {
+	resp = &replayResp{
+		NewlyBlocked:      domainsToJSON(s.blocked, limit),
+		NewlyUnblocked:    domainsToJSON(s.unblocked, limit),
+		Clients:           make([]*replayClientJSON, 0, len(s.clients)),
+		Processed:         s.processed,
+		NewlyBlockedNum:   s.blockedNum,
+		NewlyUnblockedNum: s.unblockedNum,
+	}
+
+	for _, c := range s.clients {
+		resp.Clients = append(resp.Clients, c)
+	}
+
+	slices.SortFunc(resp.Clients, func(a, b *replayClientJSON) (res int) {
+		return cmp.Or(
+			cmp.Compare(b.NewlyBlocked+b.NewlyUnblocked, a.NewlyBlocked+a.NewlyUnblocked),
+			cmp.Compare(a.Client, b.Client),
+		)
+	})
+
+	if len(resp.Clients) > limit {
+		resp.Clients = resp.Clients[:limit]
+	}
+
+	return resp
+}
+
+// domainsToJSON returns at most limit domains sorted by the number of the
+// requests.
+func domainsToJSON(domains map[string]*replayDomain, limit int) (res []*replayDomainJSON) {
+	res = make([]*replayDomainJSON, 0, len(domains))
+	for host, d := range domains {
+		res = append(res, &replayDomainJSON{
+			Domain:     host,
+			Rule:       d.rule,
+			Count:      d.count,
+			ClientsNum: len(d.clients),
+		})
+	}
+
+	slices.SortFunc(res, func(a, b *replayDomainJSON) (r int) {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Domain, b.Domain))
+	})
+
+	if len(res) > limit {
+		res = res[:limit]
+	}
+
+	return res
+}
+
+// handleQueryLogReplay is the handler for the POST /control/querylog/replay
+// HTTP API.  It replays the query log history against the candidate filtering
+// configuration, which extends the current one, and reports the difference.
+// The current configuration isn't changed.
+func handleQueryLogReplay(w http.ResponseWriter, r *http.Request) {
+	req := &replayReq{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusBadRequest, "reading req: %s", err)
+
+		return
+	}
+
+	if req.Limit < 0 || req.Limit > maxReplayLimit {
+		aghhttp.Error(r, w, http.StatusUnprocessableEntity, "limit: out of range: %d", req.Limit)
+
+		return
+	} else if req.Limit == 0 {
+		req.Limit = defaultReplayLimit
+	}
+
+	if req.Since.IsZero() {
+		req.Since = time.Now().Add(-defaultReplayPeriod)
+	}
+
+	if Context.filters == nil || Context.queryLog == nil {
+		aghhttp.Error(r, w, http.StatusServiceUnavailable, "filtering or query log not ready")
+
+		return
+	}
+
+	cand, err := Context.filters.NewCandidate(&filtering.CandidateConfig{
+		URLs:            req.URLs,
+		UserRules:       req.Rules,
+		BlockedServices: req.BlockedServices,
+		DisabledFilters: req.DisabledFilterIDs,
+	})
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusUnprocessableEntity, "%s", err)
+
+		return
+	}
+	defer cand.Close()
+
+	ctx := r.Context()
+	s := newReplayStats()
+	err = Context.queryLog.Replay(req.Since, func(e *querylog.ReplayEntry) (cont bool) {
+		s.check(cand, e)
+
+		// Stop if the client has gone away.
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		aghhttp.Error(r, w, http.StatusInternalServerError, "%s", err)
+
+		return
+	}
+
+	aghhttp.WriteJSONResponseOK(w, r, s.toJSON(req.Limit))
+}
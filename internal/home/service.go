@@ -277,9 +277,16 @@ func handleServiceCommand(s service.Service, action string, opts options) (err e
 
 		initConfigFilename(opts)
 
+		if opts.socketActivation {
+			if err = installSocketUnits(); err != nil {
+				return fmt.Errorf("installing socket units: %w", err)
+			}
+		}
+
 		handleServiceInstallCommand(s)
 	case "uninstall":
 		handleServiceUninstallCommand(s)
+		uninstallSocketUnits()
 	default:
 		if err = svcAction(s, action); err != nil {
 			return fmt.Errorf("executing action %q: %w", action, err)
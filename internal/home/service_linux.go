@@ -3,7 +3,17 @@
 package home
 
 import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/AdguardTeam/AdGuardHome/internal/aghos"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/renameio/v2/maybe"
 	"github.com/kardianos/service"
 )
 
@@ -82,3 +92,216 @@ func (svc sysvService) Uninstall() (err error) {
 	// Don't wrap an error since it's informative enough as is.
 	return err
 }
+
+// systemdUnitDir is the directory for the systemd units installed by the
+// administrator.
+const systemdUnitDir = "/etc/systemd/system"
+
+// socketsDropInPath returns the path to the drop-in file of the service unit,
+// which makes the service use the socket units.
+func socketsDropInPath() (p string) {
+	return filepath.Join(systemdUnitDir, serviceName+".service.d", "sockets.conf")
+}
+
+// socketUnit is a systemd socket unit passing the sockets to AdGuard Home.
+type socketUnit struct {
+	// name is the name of the sockets, see [socketact.NameDNS] and the
+	// others.
+	name string
+
+	// streams are the addresses of the stream sockets.
+	streams []netip.AddrPort
+
+	// datagrams are the addresses of the datagram sockets.
+	datagrams []netip.AddrPort
+}
+
+// fileName returns the file name of the unit.
+func (u *socketUnit) fileName() (name string) {
+	return fmt.Sprintf("%s-%s.socket", serviceName, u.name)
+}
+
+// content returns the content of the unit file.
+func (u *socketUnit) content() (data []byte) {
+	b := &strings.Builder{}
+
+	_, _ = fmt.Fprintf(b, "[Unit]\nDescription=AdGuard Home %s sockets\n\n", u.name)
+	_, _ = b.WriteString("[Socket]\n")
+	for _, addr := range u.streams {
+		_, _ = fmt.Fprintf(b, "ListenStream=%s\n", addr)
+	}
+
+	for _, addr := range u.datagrams {
+		_, _ = fmt.Fprintf(b, "ListenDatagram=%s\n", addr)
+	}
+
+	_, _ = fmt.Fprintf(b, "FileDescriptorName=%s\n", u.name)
+	_, _ = fmt.Fprintf(b, "Service=%s.service\n\n", serviceName)
+	_, _ = b.WriteString("[Install]\nWantedBy=sockets.target\n")
+
+	return []byte(b.String())
+}
+
+// newSocketUnits returns the socket units for the addresses from conf.  conf
+// must not be nil.
+func newSocketUnits(conf *configuration) (units []*socketUnit) {
+	addrs := func(hosts []netip.Addr, port uint16) (res []netip.AddrPort) {
+		if port == 0 {
+			return nil
+		}
+
+		for _, h := range hosts {
+			res = append(res, netip.AddrPortFrom(h, port))
+		}
+
+		return res
+	}
+
+	dnsAddrs := addrs(conf.DNS.BindHosts, conf.DNS.Port)
+	webHosts := []netip.Addr{conf.HTTPConfig.Address.Addr()}
+
+	units = []*socketUnit{{
+		name:      socketact.NameDNS,
+		streams:   dnsAddrs,
+		datagrams: dnsAddrs,
+	}, {
+		name:    socketact.NameWeb,
+		streams: []netip.AddrPort{conf.HTTPConfig.Address},
+	}}
+
+	if tlsConf := conf.TLS; tlsConf.Enabled {
+		units = append(units, &socketUnit{
+			name:    socketact.NameDNSOverTLS,
+			streams: addrs(conf.DNS.BindHosts, tlsConf.PortDNSOverTLS),
+		}, &socketUnit{
+			name:      socketact.NameDNSOverQUIC,
+			datagrams: addrs(conf.DNS.BindHosts, tlsConf.PortDNSOverQUIC),
+		}, &socketUnit{
+			name:    socketact.NameWebHTTPS,
+			streams: addrs(webHosts, tlsConf.PortHTTPS),
+		})
+	}
+
+	// Don't install the units without any sockets.
+	n := 0
+	for _, u := range units {
+		if len(u.streams)+len(u.datagrams) > 0 {
+			units[n] = u
+			n++
+		}
+	}
+
+	return units[:n]
+}
+
+// socketsDropIn returns the content of the service drop-in file for units.
+func socketsDropIn(units []*socketUnit) (data []byte) {
+	names := make([]string, 0, len(units))
+	for _, u := range units {
+		names = append(names, u.fileName())
+	}
+
+	list := strings.Join(names, " ")
+
+	return []byte(fmt.Sprintf(
+		"[Unit]\nRequires=%[1]s\nAfter=%[1]s\n\n[Service]\nSockets=%[1]s\n",
+		list,
+	))
+}
+
+// installSocketUnits installs and enables the systemd socket units for the
+// addresses from the configuration file.  It must be called before installing
+// the service itself.
+func installSocketUnits() (err error) {
+	if sys := service.ChosenSystem().String(); sys != "linux-systemd" {
+		return fmt.Errorf("socket activation is not supported by %q", sys)
+	}
+
+	if detectFirstRun() {
+		return errors.Error("socket activation requires a configuration file; " +
+			"finish the initial setup first")
+	}
+
+	err = parseConfig()
+	if err != nil {
+		return fmt.Errorf("parsing configuration file: %w", err)
+	}
+
+	units := newSocketUnits(config)
+	names := make([]string, 0, len(units))
+	for _, u := range units {
+		p := filepath.Join(systemdUnitDir, u.fileName())
+		err = maybe.WriteFile(p, u.content(), 0o644)
+		if err != nil {
+			return fmt.Errorf("writing socket unit: %w", err)
+		}
+
+		names = append(names, u.fileName())
+		log.Info("service: installed socket unit %q", p)
+	}
+
+	dropIn := socketsDropInPath()
+	err = os.MkdirAll(filepath.Dir(dropIn), 0o755)
+	if err != nil {
+		return fmt.Errorf("creating drop-in directory: %w", err)
+	}
+
+	err = maybe.WriteFile(dropIn, socketsDropIn(units), 0o644)
+	if err != nil {
+		return fmt.Errorf("writing drop-in: %w", err)
+	}
+
+	err = runSystemctl("daemon-reload")
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	// Don't wrap the error since it's informative enough as is.
+	return runSystemctl(append([]string{"enable"}, names...)...)
+}
+
+// uninstallSocketUnits disables and removes the systemd socket units, if
+// there are any.  It logs the errors, since the service itself is already
+// uninstalled.
+func uninstallSocketUnits() {
+	paths, err := filepath.Glob(filepath.Join(systemdUnitDir, serviceName+"-*.socket"))
+	if err != nil || len(paths) == 0 {
+		return
+	}
+
+	args := []string{"disable", "--now"}
+	for _, p := range paths {
+		args = append(args, filepath.Base(p))
+	}
+
+	err = runSystemctl(args...)
+	if err != nil {
+		log.Info("service: warning: %s", err)
+	}
+
+	for _, p := range append(paths, socketsDropInPath()) {
+		err = os.Remove(p)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Info("service: warning: removing %q: %s", p, err)
+		}
+	}
+
+	err = runSystemctl("daemon-reload")
+	if err != nil {
+		log.Info("service: warning: %s", err)
+	}
+}
+
+// runSystemctl runs systemctl with args and returns an error if it fails.
+func runSystemctl(args ...string) (err error) {
+	code, out, err := aghos.RunCommand("systemctl", args...)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	} else if code != 0 {
+		return fmt.Errorf("systemctl %s: code %d: %s", args[0], code, out)
+	}
+
+	return nil
+}
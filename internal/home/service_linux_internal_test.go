@@ -0,0 +1,70 @@
+//go:build linux
+
+package home
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSocketUnits(t *testing.T) {
+	conf := &configuration{
+		DNS: dnsConfig{
+			BindHosts: []netip.Addr{netip.MustParseAddr("192.168.0.1")},
+			Port:      53,
+		},
+		HTTPConfig: httpConfig{
+			Address: netip.MustParseAddrPort("0.0.0.0:3000"),
+		},
+		TLS: tlsConfigSettings{
+			Enabled:         true,
+			PortHTTPS:       443,
+			PortDNSOverTLS:  853,
+			PortDNSOverQUIC: 0,
+		},
+	}
+
+	units := newSocketUnits(conf)
+	require.Len(t, units, 4)
+
+	names := make([]string, 0, len(units))
+	for _, u := range units {
+		names = append(names, u.name)
+	}
+
+	wantNames := []string{
+		socketact.NameDNS,
+		socketact.NameWeb,
+		socketact.NameDNSOverTLS,
+		socketact.NameWebHTTPS,
+	}
+	assert.Equal(t, wantNames, names)
+
+	const wantDNS = `[Unit]
+Description=AdGuard Home dns sockets
+
+[Socket]
+ListenStream=192.168.0.1:53
+ListenDatagram=192.168.0.1:53
+FileDescriptorName=dns
+Service=AdGuardHome.service
+
+[Install]
+WantedBy=sockets.target
+`
+	assert.Equal(t, "AdGuardHome-dns.socket", units[0].fileName())
+	assert.Equal(t, wantDNS, string(units[0].content()))
+
+	const wantDropIn = `[Unit]
+Requires=AdGuardHome-dns.socket AdGuardHome-web.socket
+After=AdGuardHome-dns.socket AdGuardHome-web.socket
+
+[Service]
+Sockets=AdGuardHome-dns.socket AdGuardHome-web.socket
+`
+	assert.Equal(t, wantDropIn, string(socketsDropIn(units[:2])))
+}
@@ -431,3 +431,13 @@ func (sysLogger) Infof(format string, a ...any) error {
 
 	return nil
 }
+
+// installSocketUnits returns an error, since socket activation is only
+// supported by systemd.
+func installSocketUnits() (err error) {
+	return errors.Error("socket activation is only supported on linux with systemd")
+}
+
+// uninstallSocketUnits does nothing, since socket activation is only supported
+// by systemd.
+func uninstallSocketUnits() {}
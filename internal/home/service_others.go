@@ -2,6 +2,18 @@
 
 package home
 
+import "github.com/AdguardTeam/golibs/errors"
+
 // chooseSystem checks the current system detected and substitutes it with local
 // implementation if needed.
 func chooseSystem() {}
+
+// installSocketUnits returns an error, since socket activation is only
+// supported by systemd.
+func installSocketUnits() (err error) {
+	return errors.Error("socket activation is only supported on linux with systemd")
+}
+
+// uninstallSocketUnits does nothing, since socket activation is only supported
+// by systemd.
+func uninstallSocketUnits() {}
@@ -303,6 +303,16 @@ type tlsConfigSettingsExt struct {
 	ServePlainDNS aghalg.NullBool `yaml:"-" json:"serve_plain_dns"`
 }
 
+// certNotAfter returns the expiration time of the first certificate in the
+// chain and whether the encryption is enabled.  notAfter is zero if no
+// certificate is loaded.
+func (m *tlsManager) certNotAfter() (notAfter time.Time, enabled bool) {
+	m.confLock.Lock()
+	defer m.confLock.Unlock()
+
+	return m.status.NotAfter, m.conf.Enabled
+}
+
 // handleTLSStatus is the handler for the GET /control/tls/status HTTP API.
 func (m *tlsManager) handleTLSStatus(w http.ResponseWriter, r *http.Request) {
 	m.confLock.Lock()
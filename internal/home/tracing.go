@@ -0,0 +1,155 @@
+package home
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
+	"github.com/AdguardTeam/AdGuardHome/internal/version"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// tracingConfig is the block with the tracing settings.
+type tracingConfig struct {
+	// OTLPEndpoint is the URL of the OTLP/HTTP traces endpoint of an
+	// OpenTelemetry collector.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+
+	// ServiceName is the name of the service reported to the collector.
+	ServiceName string `yaml:"service_name"`
+
+	// SampleRatio is the share of the traces to record, from 0 to 1.
+	SampleRatio float64 `yaml:"sample_ratio"`
+
+	// Enabled defines if the tracing is enabled.
+	Enabled bool `yaml:"enabled"`
+}
+
+// defaultTracingConfig returns the default tracing configuration.
+func defaultTracingConfig() (c *tracingConfig) {
+	return &tracingConfig{
+		OTLPEndpoint: "http://localhost:4318/v1/traces",
+		ServiceName:  "adguardhome",
+		SampleRatio:  0.01,
+		Enabled:      false,
+	}
+}
+
+// validate returns an error if the tracing configuration is invalid.
+func (c *tracingConfig) validate() (err error) {
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio: out of range: must be from 0 to 1, got %v", c.SampleRatio)
+	}
+
+	if !c.Enabled {
+		return nil
+	}
+
+	u, err := url.ParseRequestURI(c.OTLPEndpoint)
+	if err != nil {
+		return fmt.Errorf("otlp_endpoint: %w", err)
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("otlp_endpoint: unsupported scheme %q", u.Scheme)
+	}
+
+	return nil
+}
+
+// initTracer creates the global tracer, if the tracing is enabled.
+func initTracer() (err error) {
+	c := config.Tracing
+	if c == nil || !c.Enabled {
+		return nil
+	}
+
+	exp, err := aghtrace.NewOTLPExporter(context.Background(), c.OTLPEndpoint)
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+
+	Context.tracer = aghtrace.New(&aghtrace.Config{
+		Exporter:       exp,
+		ServiceName:    c.ServiceName,
+		ServiceVersion: version.Version(),
+		SampleRatio:    c.SampleRatio,
+	})
+
+	log.Info("tracing: exporting to %s with sample ratio %v", c.OTLPEndpoint, c.SampleRatio)
+
+	return nil
+}
+
+// shutdownTracer exports the remaining spans and stops the global tracer.  The
+// tracer itself is kept, since it's still used by the handlers running
+// concurrently, but the spans started after that aren't recorded.
+func shutdownTracer(ctx context.Context) {
+	err := Context.tracer.Shutdown(ctx)
+	if err != nil {
+		log.Error("tracing: shutting down: %s", err)
+	}
+}
+
+// traceHTTP wraps the HTTP API handler into a tracing span.  The span continues
+// the trace from the W3C Trace Context headers of the request, if any.
+func traceHTTP(
+	handler func(http.ResponseWriter, *http.Request),
+) (wrapped func(http.ResponseWriter, *http.Request)) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Context.tracer.StartServer(
+			Context.tracer.Extract(r.Context(), r.Header),
+			"http "+r.Method+" "+r.URL.Path,
+			aghtrace.String("http.request.method", r.Method),
+			aghtrace.String("url.path", r.URL.Path),
+		)
+		if span == nil {
+			handler(w, r)
+
+			return
+		}
+
+		defer span.End()
+
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(rw, r.WithContext(ctx))
+
+		span.SetAttrs(aghtrace.Int("http.response.status_code", rw.status))
+		if rw.status >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("status %d", rw.status))
+		}
+	}
+}
+
+// statusRecorder is an [http.ResponseWriter] that remembers the status code of
+// the response.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	// status is the status code of the response.
+	status int
+}
+
+// WriteHeader implements the [http.ResponseWriter] interface for
+// *statusRecorder.
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// type check
+var _ http.Flusher = (*statusRecorder)(nil)
+
+// Flush implements the [http.Flusher] interface for *statusRecorder.  It does
+// nothing if the underlying writer doesn't support flushing.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, so that [http.ResponseController]
+// could use it.
+func (r *statusRecorder) Unwrap() (w http.ResponseWriter) {
+	return r.ResponseWriter
+}
@@ -0,0 +1,106 @@
+package home
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghtrace"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		conf       *tracingConfig
+		name       string
+		wantErrMsg string
+	}{{
+		conf:       defaultTracingConfig(),
+		name:       "default",
+		wantErrMsg: "",
+	}, {
+		conf: &tracingConfig{
+			OTLPEndpoint: "https://collector.example:4318/v1/traces",
+			SampleRatio:  1,
+			Enabled:      true,
+		},
+		name:       "valid",
+		wantErrMsg: "",
+	}, {
+		conf: &tracingConfig{
+			SampleRatio: 1.5,
+		},
+		name:       "bad_ratio",
+		wantErrMsg: "sample_ratio: out of range: must be from 0 to 1, got 1.5",
+	}, {
+		conf: &tracingConfig{
+			OTLPEndpoint: "udp://collector.example:4318",
+			Enabled:      true,
+		},
+		name:       "bad_scheme",
+		wantErrMsg: `otlp_endpoint: unsupported scheme "udp"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.conf.validate())
+		})
+	}
+}
+
+func TestTraceHTTP(t *testing.T) {
+	const (
+		traceIDStr = "4bf92f3577b34da6a3ce929d0e0e4736"
+		spanIDStr  = "00f067aa0ba902b7"
+	)
+
+	prevTracer := Context.tracer
+	t.Cleanup(func() { Context.tracer = prevTracer })
+
+	exp := tracetest.NewInMemoryExporter()
+	Context.tracer = aghtrace.New(&aghtrace.Config{
+		Exporter:    exp,
+		SampleRatio: 1,
+	})
+
+	h := traceHTTP(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+
+		f, ok := w.(http.Flusher)
+		require.True(t, ok)
+
+		f.Flush()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/control/status", nil)
+	r.Header.Set("Traceparent", "00-"+traceIDStr+"-"+spanIDStr+"-01")
+
+	w := httptest.NewRecorder()
+	h(w, r)
+
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	assert.True(t, w.Flushed)
+
+	err := Context.tracer.Flush(context.Background())
+	require.NoError(t, err)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 1)
+
+	s := spans[0]
+	assert.Equal(t, "http GET /control/status", s.Name)
+	assert.Equal(t, trace.SpanKindServer, s.SpanKind)
+	assert.Contains(t, s.Attributes, aghtrace.Int("http.response.status_code", 503))
+	assert.Equal(t, codes.Error, s.Status.Code)
+	assert.Equal(t, "status 503", s.Status.Description)
+
+	assert.Equal(t, traceIDStr, s.SpanContext.TraceID().String())
+	assert.Equal(t, spanIDStr, s.Parent.SpanID().String())
+}
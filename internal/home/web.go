@@ -12,6 +12,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 	"github.com/AdguardTeam/AdGuardHome/internal/updater"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
@@ -42,6 +43,10 @@ type webConfig struct {
 	// BindAddr is the binding address with port for plain HTTP web interface.
 	BindAddr netip.AddrPort
 
+	// BindInterface is the name of the network interface to serve the web
+	// interface on instead of the address of BindAddr.  It may be empty.
+	BindInterface string
+
 	// ReadTimeout is an option to pass to http.Server for setting an
 	// appropriate field.
 	ReadTimeout time.Duration
@@ -107,6 +112,8 @@ func newWebAPI(conf *webConfig) (w *webAPI) {
 	// if not configured, redirect / to /install.html, otherwise redirect /install.html to /
 	Context.mux.Handle("/", withMiddlewares(clientFS, gziphandler.GzipHandler, optionalAuthHandler, postInstallHandler))
 
+	registerHealthHandlers()
+
 	// add handlers for /install paths, we only need them when we're not configured yet
 	if conf.firstRun {
 		log.Info("This is the first launch of AdGuard Home, redirecting everything to /install.html ")
@@ -184,10 +191,12 @@ func (web *webAPI) start() {
 		// Use an h2c handler to support unencrypted HTTP/2, e.g. for proxies.
 		hdlr := h2c.NewHandler(withMiddlewares(Context.mux, limitRequestBody), &http2.Server{})
 
+		addrs := webBindAddrs(web.conf.BindAddr, web.conf.BindInterface)
+
 		// Create a new instance, because the Web is not usable after Shutdown.
 		web.httpServer = &http.Server{
 			ErrorLog:          log.StdLog("web: plain", log.DEBUG),
-			Addr:              web.conf.BindAddr.String(),
+			Addr:              addrs[0].String(),
 			Handler:           hdlr,
 			ReadTimeout:       web.conf.ReadTimeout,
 			ReadHeaderTimeout: web.conf.ReadHeaderTimeout,
@@ -196,7 +205,7 @@ func (web *webAPI) start() {
 		go func() {
 			defer log.OnPanic("web: plain")
 
-			errs <- web.httpServer.ListenAndServe()
+			errs <- serveWeb(web.httpServer, socketact.NameWeb, addrs)
 		}()
 
 		err := <-errs
@@ -210,6 +219,25 @@ func (web *webAPI) start() {
 	}
 }
 
+// rebind restarts the HTTP and HTTPS servers, so that they are bound to the
+// current addresses of the bind interface.
+func (web *webAPI) rebind(ctx context.Context) {
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithTimeout(ctx, shutdownTimeout)
+	defer cancel()
+
+	web.httpsServer.cond.L.Lock()
+	if web.httpsServer.server != nil {
+		// The HTTPS server loop restarts the server, since it's still enabled.
+		shutdownSrv(ctx, web.httpsServer.server)
+		shutdownSrv3(web.httpsServer.server3)
+	}
+	web.httpsServer.cond.L.Unlock()
+
+	// The main loop restarts the HTTP server after it's closed.
+	shutdownSrv(ctx, web.httpServer)
+}
+
 // close gracefully shuts down the HTTP servers.
 func (web *webAPI) close(ctx context.Context) {
 	log.Info("stopping http server...")
@@ -256,7 +284,12 @@ func (web *webAPI) tlsServerLoop() {
 			portHTTPS = config.TLS.PortHTTPS
 		}()
 
-		addr := netip.AddrPortFrom(web.conf.BindAddr.Addr(), portHTTPS).String()
+		addrs := webBindAddrs(
+			netip.AddrPortFrom(web.conf.BindAddr.Addr(), portHTTPS),
+			web.conf.BindInterface,
+		)
+
+		addr := addrs[0].String()
 		web.httpsServer.server = &http.Server{
 			ErrorLog: log.StdLog("web: https", log.DEBUG),
 			Addr:     addr,
@@ -279,7 +312,7 @@ func (web *webAPI) tlsServerLoop() {
 		}
 
 		log.Debug("web: starting https server")
-		err := web.httpsServer.server.ListenAndServeTLS("", "")
+		err := serveWeb(web.httpsServer.server, socketact.NameWebHTTPS, addrs)
 		if !errors.Is(err, http.ErrServerClosed) {
 			cleanupAlways()
 			log.Fatalf("web: https: %s", err)
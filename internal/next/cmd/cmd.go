@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/next/configmgr"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 	"github.com/AdguardTeam/AdGuardHome/internal/version"
 	"github.com/AdguardTeam/golibs/log"
 )
@@ -40,11 +41,19 @@ func Main(embeddedFrontend fs.FS) {
 	frontend, err := frontendFromOpts(opts, embeddedFrontend)
 	check(err)
 
+	sockets, err := socketact.FromEnv()
+	check(err)
+
+	if names := sockets.Names(); len(names) > 0 {
+		log.Info("socket activation: got sockets named %q", names)
+	}
+
 	confMgrConf := &configmgr.Config{
 		Frontend: frontend,
 		WebAddr:  opts.webAddr,
 		Start:    start,
 		FileName: opts.confFile,
+		Sockets:  sockets,
 	}
 
 	confMgr, err := newConfigMgr(confMgrConf)
@@ -17,6 +17,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/next/agh"
 	"github.com/AdguardTeam/AdGuardHome/internal/next/dnssvc"
 	"github.com/AdguardTeam/AdGuardHome/internal/next/websvc"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/timeutil"
@@ -75,6 +76,10 @@ type Config struct {
 
 	// FileName is the path to the configuration file.
 	FileName string
+
+	// Sockets are the pre-bound sockets passed by the service manager.  It may
+	// be nil.
+	Sockets *socketact.Sockets
 }
 
 // New creates a new *Manager that persists changes to the file pointed to by
@@ -98,7 +103,7 @@ func New(ctx context.Context, c *Config) (m *Manager, err error) {
 		fileName: c.FileName,
 	}
 
-	err = m.assemble(ctx, conf, c.Frontend, c.WebAddr, c.Start)
+	err = m.assemble(ctx, conf, c)
 	if err != nil {
 		return nil, fmt.Errorf("creating config manager: %w", err)
 	}
@@ -128,14 +133,9 @@ func read(fileName string) (conf *config, err error) {
 }
 
 // assemble creates all services and puts them into the corresponding fields.
-// The fields of conf must not be modified after calling assemble.
-func (m *Manager) assemble(
-	ctx context.Context,
-	conf *config,
-	frontend fs.FS,
-	webAddr netip.AddrPort,
-	start time.Time,
-) (err error) {
+// The fields of conf must not be modified after calling assemble.  c must not
+// be nil.
+func (m *Manager) assemble(ctx context.Context, conf *config, c *Config) (err error) {
 	dnsConf := &dnssvc.Config{
 		Addresses:           conf.DNS.Addresses,
 		BootstrapServers:    conf.DNS.BootstrapDNS,
@@ -144,6 +144,7 @@ func (m *Manager) assemble(
 		UpstreamTimeout:     conf.DNS.UpstreamTimeout.Duration,
 		BootstrapPreferIPv6: conf.DNS.BootstrapPreferIPv6,
 		UseDNS64:            conf.DNS.UseDNS64,
+		Sockets:             c.Sockets,
 	}
 	err = m.updateDNS(ctx, dnsConf)
 	if err != nil {
@@ -156,13 +157,14 @@ func (m *Manager) assemble(
 			Enabled: conf.HTTP.Pprof.Enabled,
 		},
 		ConfigManager: m,
-		Frontend:      frontend,
+		Frontend:      c.Frontend,
 		// TODO(a.garipov): Fill from config file.
 		TLS:             nil,
-		Start:           start,
+		Sockets:         c.Sockets,
+		Start:           c.Start,
 		Addresses:       conf.HTTP.Addresses,
 		SecureAddresses: conf.HTTP.SecureAddresses,
-		OverrideAddress: webAddr,
+		OverrideAddress: c.WebAddr,
 		Timeout:         conf.HTTP.Timeout.Duration,
 		ForceHTTPS:      conf.HTTP.ForceHTTPS,
 	}
@@ -3,6 +3,8 @@ package dnssvc
 import (
 	"net/netip"
 	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 )
 
 // Config is the AdGuard Home DNS service configuration structure.
@@ -32,4 +34,9 @@ type Config struct {
 
 	// UseDNS64, if true, enables DNS64 protection for incoming requests.
 	UseDNS64 bool
+
+	// Sockets are the pre-bound sockets passed by the service manager.  If it
+	// contains plain DNS sockets, they are served instead of Addresses.  It
+	// may be nil.
+	Sockets *socketact.Sockets
 }
@@ -14,12 +14,15 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/next/agh"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 
 	// TODO(a.garipov): Add a “dnsproxy proxy” package to shield us from changes
 	// and replacement of module dnsproxy.
 	"github.com/AdguardTeam/dnsproxy/proxy"
 	"github.com/AdguardTeam/dnsproxy/upstream"
 	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
 )
 
 // Service is the AdGuard Home DNS service.  A nil *Service is a valid
@@ -29,6 +32,9 @@ import (
 // fields that are only used in [New] and [Service.Config].
 type Service struct {
 	proxy               *proxy.Proxy
+	sockets             *socketact.Sockets
+	activated           *socketact.DNSServer
+	addrs               []netip.AddrPort
 	bootstraps          []string
 	bootstrapResolvers  []*upstream.UpstreamResolver
 	upstreams           []string
@@ -48,6 +54,8 @@ func New(c *Config) (svc *Service, err error) {
 	}
 
 	svc = &Service{
+		sockets:             c.Sockets,
+		addrs:               c.Addresses,
 		bootstraps:          c.BootstrapServers,
 		upstreams:           c.UpstreamServers,
 		dns64Prefixes:       c.DNS64Prefixes,
@@ -67,7 +75,7 @@ func New(c *Config) (svc *Service, err error) {
 	}
 
 	svc.bootstrapResolvers = resolvers
-	svc.proxy, err = proxy.New(&proxy.Config{
+	proxyConf := &proxy.Config{
 		UDPListenAddr: udpAddrs(c.Addresses),
 		TCPListenAddr: tcpAddrs(c.Addresses),
 		UpstreamConfig: &proxy.UpstreamConfig{
@@ -75,7 +83,35 @@ func New(c *Config) (svc *Service, err error) {
 		},
 		UseDNS64:   c.UseDNS64,
 		DNS64Prefs: c.DNS64Prefixes,
-	})
+	}
+
+	act := c.Sockets.DNSListeners()
+	if act.HasPlain() {
+		// Only serve the plain DNS sockets, since the encrypted protocols
+		// aren't supported here yet.
+		act = &socketact.DNSListeners{
+			UDP: act.UDP,
+			TCP: act.TCP,
+		}
+
+		if len(act.UDP) > 0 {
+			proxyConf.UDPListenAddr = nil
+		}
+
+		if len(act.TCP) > 0 {
+			proxyConf.TCPListenAddr = nil
+		}
+
+		svc.activated = socketact.NewDNSServer(&socketact.DNSServerConfig{
+			Listeners: act,
+			TLSConfig: nil,
+			Handler:   svc.handleActivated,
+		})
+
+		log.Info("dnssvc: serving activated sockets instead of the configured addresses")
+	}
+
+	svc.proxy, err = proxy.New(proxyConf)
 	if err != nil {
 		return nil, fmt.Errorf("proxy: %w", err)
 	}
@@ -83,6 +119,18 @@ func New(c *Config) (svc *Service, err error) {
 	return svc, nil
 }
 
+// handleActivated resolves the request received over an activated socket.
+func (svc *Service) handleActivated(dctx *proxy.DNSContext) {
+	err := svc.proxy.Resolve(dctx)
+	if err != nil {
+		log.Debug("dnssvc: resolving activated request: %s", err)
+
+		if dctx.Res == nil {
+			dctx.Res = (&dns.Msg{}).SetRcode(dctx.Req, dns.RcodeServerFailure)
+		}
+	}
+}
+
 // addressesToUpstreams is a wrapper around [upstream.AddressToUpstream].  It
 // accepts a slice of addresses and other upstream parameters, and returns a
 // slice of upstreams.
@@ -170,6 +218,20 @@ func (svc *Service) Start() (err error) {
 		svc.running.Store(err == nil)
 	}()
 
+	if svc.activated != nil {
+		err = svc.activated.Start()
+		if err != nil {
+			return fmt.Errorf("starting activated sockets: %w", err)
+		}
+	}
+
+	c := svc.proxy.Config
+	if len(c.UDPListenAddr)+len(c.TCPListenAddr) == 0 {
+		// The proxy refuses to start without listen addresses, which is the
+		// case when all of them are replaced by the activated sockets.
+		return nil
+	}
+
 	return svc.proxy.Start(context.Background())
 }
 
@@ -184,6 +246,10 @@ func (svc *Service) Shutdown(ctx context.Context) (err error) {
 		svc.proxy.Shutdown(ctx),
 	}
 
+	if svc.activated != nil {
+		errs = append(errs, svc.activated.Shutdown(ctx))
+	}
+
 	for _, b := range svc.bootstrapResolvers {
 		errs = append(errs, errors.Annotate(b.Close(), "closing bootstrap %s: %w", b.Address()))
 	}
@@ -199,7 +265,11 @@ func (svc *Service) Config() (c *Config) {
 	// TODO(a.garipov): Do we need to get the TCP addresses separately?
 
 	var addrs []netip.AddrPort
-	if svc.running.Load() {
+	if svc.activated != nil {
+		// The proxy doesn't know about the activated sockets, so report the
+		// configured addresses.
+		addrs = svc.addrs
+	} else if svc.running.Load() {
 		udpAddrs := svc.proxy.Addrs(proxy.ProtoUDP)
 		addrs = make([]netip.AddrPort, len(udpAddrs))
 		for i, a := range udpAddrs {
@@ -222,6 +292,7 @@ func (svc *Service) Config() (c *Config) {
 		UpstreamTimeout:     svc.upsTimeout,
 		BootstrapPreferIPv6: svc.bootstrapPreferIPv6,
 		UseDNS64:            svc.useDNS64,
+		Sockets:             svc.sockets,
 	}
 
 	return c
@@ -5,6 +5,8 @@ import (
 	"io/fs"
 	"net/netip"
 	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 )
 
 // Config is the AdGuard Home web service configuration structure.
@@ -24,6 +26,11 @@ type Config struct {
 	// SecureAddresses must not be empty.
 	TLS *tls.Config
 
+	// Sockets are the pre-bound sockets passed by the service manager.  If it
+	// contains the web sockets, they are served instead of Addresses and
+	// SecureAddresses, unless OverrideAddress is set.  It may be nil.
+	Sockets *socketact.Sockets
+
 	// Start is the time of start of AdGuard Home.
 	Start time.Time
 
@@ -66,6 +73,7 @@ func (svc *Service) Config() (c *Config) {
 		},
 		ConfigManager: svc.confMgr,
 		TLS:           svc.tls,
+		Sockets:       svc.sockets,
 		// Leave Addresses and SecureAddresses empty and get the actual
 		// addresses that include the :0 ones later.
 		Start:      svc.start,
@@ -67,6 +67,7 @@ func (svc *Service) handlePatchSettingsDNS(w http.ResponseWriter, r *http.Reques
 		UpstreamTimeout:     time.Duration(req.UpstreamTimeout),
 		BootstrapPreferIPv6: req.BootstrapPreferIPv6,
 		UseDNS64:            req.UseDNS64,
+		Sockets:             svc.sockets,
 	}
 
 	ctx := r.Context()
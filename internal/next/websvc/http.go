@@ -60,6 +60,7 @@ func (svc *Service) handlePatchSettingsHTTP(w http.ResponseWriter, r *http.Reque
 		ConfigManager:   svc.confMgr,
 		Frontend:        svc.frontend,
 		TLS:             svc.tls,
+		Sockets:         svc.sockets,
 		Addresses:       req.Addresses,
 		SecureAddresses: req.SecureAddresses,
 		Timeout:         time.Duration(req.Timeout),
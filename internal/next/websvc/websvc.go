@@ -21,9 +21,11 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/next/agh"
 	"github.com/AdguardTeam/AdGuardHome/internal/next/dnssvc"
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/mathutil"
+	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/golibs/pprofutil"
 	httptreemux "github.com/dimfeld/httptreemux/v5"
 )
@@ -44,12 +46,24 @@ type Service struct {
 	frontend     fs.FS
 	tls          *tls.Config
 	pprof        *http.Server
+	sockets      *socketact.Sockets
 	start        time.Time
 	overrideAddr netip.AddrPort
 	servers      []*http.Server
-	timeout      time.Duration
-	pprofPort    uint16
-	forceHTTPS   bool
+
+	// activated are the pre-bound sockets of the servers which serve them
+	// instead of binding their addresses.  It is nil if there are none.
+	activated map[*http.Server]net.Listener
+
+	// addresses and secureAddresses are the configured addresses, which are
+	// reported instead of the ones of the servers, if they serve the
+	// activated sockets.
+	addresses       []netip.AddrPort
+	secureAddresses []netip.AddrPort
+
+	timeout    time.Duration
+	pprofPort  uint16
+	forceHTTPS bool
 }
 
 // New returns a new properly initialized *Service.  If c is nil, svc is a nil
@@ -67,6 +81,7 @@ func New(c *Config) (svc *Service, err error) {
 		confMgr:      c.ConfigManager,
 		frontend:     c.Frontend,
 		tls:          c.TLS,
+		sockets:      c.Sockets,
 		start:        c.Start,
 		overrideAddr: c.OverrideAddress,
 		timeout:      c.Timeout,
@@ -77,7 +92,7 @@ func New(c *Config) (svc *Service, err error) {
 
 	if svc.overrideAddr != (netip.AddrPort{}) {
 		svc.servers = []*http.Server{newSrv(svc.overrideAddr, nil, mux, c.Timeout)}
-	} else {
+	} else if !svc.setupActivated(c, mux) {
 		for _, a := range c.Addresses {
 			svc.servers = append(svc.servers, newSrv(a, nil, mux, c.Timeout))
 		}
@@ -92,6 +107,42 @@ func New(c *Config) (svc *Service, err error) {
 	return svc, nil
 }
 
+// setupActivated sets up the servers for the pre-bound sockets from c, if
+// there are any.  ok is false if there are none.
+func (svc *Service) setupActivated(c *Config, h http.Handler) (ok bool) {
+	ls := c.Sockets.Listeners(socketact.NameWeb)
+
+	var secureLs []net.Listener
+	if c.TLS != nil {
+		secureLs = c.Sockets.Listeners(socketact.NameWebHTTPS)
+	}
+
+	if len(ls)+len(secureLs) == 0 {
+		return false
+	}
+
+	log.Info("websvc: serving activated sockets instead of the configured addresses")
+
+	svc.activated = make(map[*http.Server]net.Listener, len(ls)+len(secureLs))
+	svc.addresses, svc.secureAddresses = c.Addresses, c.SecureAddresses
+
+	add := func(l net.Listener, tlsConf *tls.Config) {
+		srv := newSrv(netutil.NetAddrToAddrPort(l.Addr()), tlsConf, h, c.Timeout)
+		svc.servers = append(svc.servers, srv)
+		svc.activated[srv] = l
+	}
+
+	for _, l := range ls {
+		add(l, nil)
+	}
+
+	for _, l := range secureLs {
+		add(l, c.TLS)
+	}
+
+	return true
+}
+
 // setupPprof sets the pprof properties of svc.
 func (svc *Service) setupPprof(c *PprofConfig) {
 	if !c.Enabled {
@@ -211,6 +262,8 @@ func newMux(svc *Service) (mux *httptreemux.ContextMux) {
 func (svc *Service) addrs() (addrs, secureAddrs []netip.AddrPort) {
 	if svc.overrideAddr != (netip.AddrPort{}) {
 		return []netip.AddrPort{svc.overrideAddr}, nil
+	} else if svc.activated != nil {
+		return svc.addresses, svc.secureAddresses
 	}
 
 	for _, srv := range svc.servers {
@@ -253,11 +306,11 @@ func (svc *Service) Start() (err error) {
 	wg := &sync.WaitGroup{}
 	wg.Add(srvNum)
 	for _, srv := range svc.servers {
-		go serve(srv, wg)
+		go serve(srv, svc.activated[srv], wg)
 	}
 
 	if pprofEnabled {
-		go serve(svc.pprof, wg)
+		go serve(svc.pprof, nil, wg)
 	}
 
 	wg.Wait()
@@ -265,20 +318,26 @@ func (svc *Service) Start() (err error) {
 	return nil
 }
 
-// serve starts and runs srv and writes all errors into its log.
-func serve(srv *http.Server, wg *sync.WaitGroup) {
+// serve starts and runs srv and writes all errors into its log.  If l is not
+// nil, srv serves it instead of binding its address.
+func serve(srv *http.Server, l net.Listener, wg *sync.WaitGroup) {
 	addr := srv.Addr
 	defer log.OnPanic(addr)
 
 	var proto string
-	var l net.Listener
 	var err error
 	if srv.TLSConfig == nil {
 		proto = "http"
-		l, err = net.Listen("tcp", addr)
+		if l == nil {
+			l, err = net.Listen("tcp", addr)
+		}
 	} else {
 		proto = "https"
-		l, err = tls.Listen("tcp", addr, srv.TLSConfig)
+		if l == nil {
+			l, err = tls.Listen("tcp", addr, srv.TLSConfig)
+		} else {
+			l = tls.NewListener(l, srv.TLSConfig)
+		}
 	}
 	if err != nil {
 		srv.ErrorLog.Printf("starting srv %s: binding: %s", addr, err)
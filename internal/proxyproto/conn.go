@@ -0,0 +1,322 @@
+package proxyproto
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+)
+
+// headerTimeout is the maximum duration of waiting for the header on a stream
+// connection.
+const headerTimeout = 5 * time.Second
+
+// Addr is the address of a client connected through a proxy.
+type Addr struct {
+	// Proxy is the address of the proxy, to which the responses are sent.
+	Proxy net.Addr
+
+	// Client is the original address of the client.
+	Client netip.AddrPort
+}
+
+// type check
+var _ net.Addr = (*Addr)(nil)
+
+// Network implements the [net.Addr] interface for *Addr.
+func (a *Addr) Network() (n string) {
+	return a.Proxy.Network()
+}
+
+// String implements the [net.Addr] interface for *Addr.
+func (a *Addr) String() (s string) {
+	return a.Client.String()
+}
+
+// AddrPort returns the original address of the client.
+func (a *Addr) AddrPort() (ap netip.AddrPort) {
+	return a.Client
+}
+
+// isTrusted returns true if addr belongs to trusted.
+func isTrusted(trusted netutil.SubnetSet, addr net.Addr) (ok bool) {
+	ap := netutil.NetAddrToAddrPort(addr)
+
+	return ap.IsValid() && trusted.Contains(ap.Addr().Unmap())
+}
+
+// Listener is a [net.Listener] which reads the PROXY protocol headers on the
+// connections from the trusted proxies.  The header is optional, so that the
+// health checks of the proxy work.  The headers on the connections from other
+// addresses aren't parsed.
+type Listener struct {
+	net.Listener
+
+	trusted netutil.SubnetSet
+}
+
+// NewListener returns a new *Listener wrapping l.  trusted must not be nil.
+func NewListener(l net.Listener, trusted netutil.SubnetSet) (pl *Listener) {
+	return &Listener{
+		Listener: l,
+		trusted:  trusted,
+	}
+}
+
+// type check
+var _ net.Listener = (*Listener)(nil)
+
+// Accept implements the [net.Listener] interface for *Listener.  The header is
+// read on the first call to Read or RemoteAddr of the returned connection, so
+// that a slow client doesn't block the accepting loop.
+func (l *Listener) Accept() (c net.Conn, err error) {
+	c, err = l.Listener.Accept()
+	if err != nil || !isTrusted(l.trusted, c.RemoteAddr()) {
+		return c, err
+	}
+
+	return &conn{
+		Conn:   c,
+		reader: bufio.NewReaderSize(c, maxLenV2),
+		once:   &sync.Once{},
+		remote: c.RemoteAddr(),
+		mu:     &sync.Mutex{},
+	}, nil
+}
+
+// SetDeadline sets the deadline of the underlying listener, if it supports
+// deadlines.
+func (l *Listener) SetDeadline(t time.Time) (err error) {
+	d, ok := l.Listener.(interface{ SetDeadline(t time.Time) (err error) })
+	if !ok {
+		return nil
+	}
+
+	return d.SetDeadline(t)
+}
+
+// conn is a connection from a trusted proxy, which may start with a PROXY
+// protocol header.
+type conn struct {
+	net.Conn
+
+	// reader contains the data following the header.
+	reader *bufio.Reader
+
+	// once is used to read the header once.
+	once *sync.Once
+
+	// remote is the original address of the client, if the header has one, or
+	// the address of the proxy.
+	remote net.Addr
+
+	// err is the error of reading the header.
+	err error
+
+	// mu protects readDeadline.
+	mu *sync.Mutex
+
+	// readDeadline is the read deadline set by the user of the connection.  It
+	// is restored after reading the header.
+	readDeadline time.Time
+}
+
+// type check
+var _ net.Conn = (*conn)(nil)
+
+// Read implements the [net.Conn] interface for *conn.
+func (c *conn) Read(b []byte) (n int, err error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr implements the [net.Conn] interface for *conn.
+func (c *conn) RemoteAddr() (addr net.Addr) {
+	c.once.Do(c.readHeader)
+
+	return c.remote
+}
+
+// SetDeadline implements the [net.Conn] interface for *conn.
+func (c *conn) SetDeadline(t time.Time) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline implements the [net.Conn] interface for *conn.
+func (c *conn) SetReadDeadline(t time.Time) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+
+	return c.Conn.SetReadDeadline(t)
+}
+
+// readHeader reads the header, if there is one, and sets c.remote or c.err.
+func (c *conn) readHeader() {
+	c.mu.Lock()
+	userDeadline := c.readDeadline
+	c.mu.Unlock()
+
+	deadline := time.Now().Add(headerTimeout)
+	if !userDeadline.IsZero() && userDeadline.Before(deadline) {
+		deadline = userDeadline
+	}
+
+	err := c.Conn.SetReadDeadline(deadline)
+	if err != nil {
+		c.err = fmt.Errorf("proxyproto: setting deadline: %w", err)
+
+		return
+	}
+
+	src, err := c.parseHeader()
+	if err != nil {
+		log.Debug("proxyproto: reading header from %s: %s", c.Conn.RemoteAddr(), err)
+
+		c.err = fmt.Errorf("proxyproto: reading header: %w", err)
+
+		return
+	}
+
+	if src.IsValid() {
+		c.remote = net.TCPAddrFromAddrPort(src)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err = c.Conn.SetReadDeadline(c.readDeadline)
+	if err != nil {
+		c.err = fmt.Errorf("proxyproto: restoring deadline: %w", err)
+	}
+}
+
+// parseHeader reads and parses the header, if there is one.  src is invalid if
+// there is no header or it doesn't carry the address.
+func (c *conn) parseHeader() (src netip.AddrPort, err error) {
+	// All of the supported protocols send more than the signature in the first
+	// message, so peeking doesn't block waiting for the data that never comes.
+	b, err := c.reader.Peek(len(sigV2))
+	if errors.Is(err, io.EOF) {
+		// The data is too short to contain a header.
+		return netip.AddrPort{}, nil
+	} else if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	switch {
+	case hasV2Prefix(b):
+		b, err = c.reader.Peek(fixedLenV2)
+		if err != nil {
+			return netip.AddrPort{}, err
+		}
+
+		hdrLen := lenV2(b)
+		if hdrLen > maxLenV2 {
+			return netip.AddrPort{}, fmt.Errorf("v2: header too long: %d bytes", hdrLen)
+		}
+
+		b, err = c.reader.Peek(hdrLen)
+		if err != nil {
+			return netip.AddrPort{}, err
+		}
+
+		var n int
+		src, n, err = parseV2(b)
+		if err != nil {
+			return netip.AddrPort{}, err
+		}
+
+		_, err = c.reader.Discard(n)
+
+		return src, err
+	case hasV1Prefix(b):
+		var line []byte
+		line, err = c.reader.ReadSlice('\n')
+		if err != nil {
+			return netip.AddrPort{}, err
+		} else if len(line) > maxLenV1 {
+			return netip.AddrPort{}, errors.Error("v1: header too long")
+		}
+
+		return parseV1(line)
+	default:
+		return netip.AddrPort{}, nil
+	}
+}
+
+// PacketConn is a [net.PacketConn] which reads the PROXY protocol version 2
+// headers on the datagrams from the trusted proxies.  The addresses returned
+// for such datagrams are of type *Addr, and the datagrams written to them are
+// sent to the proxy.  The header is optional.  The headers on the datagrams
+// from other addresses aren't parsed.
+type PacketConn struct {
+	net.PacketConn
+
+	trusted netutil.SubnetSet
+}
+
+// NewPacketConn returns a new *PacketConn wrapping c.  trusted must not be nil.
+func NewPacketConn(c net.PacketConn, trusted netutil.SubnetSet) (pc *PacketConn) {
+	return &PacketConn{
+		PacketConn: c,
+		trusted:    trusted,
+	}
+}
+
+// type check
+var _ net.PacketConn = (*PacketConn)(nil)
+
+// ReadFrom implements the [net.PacketConn] interface for *PacketConn.  The
+// datagrams with invalid headers are dropped.
+func (c *PacketConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	for {
+		n, addr, err = c.PacketConn.ReadFrom(b)
+		if err != nil || !isTrusted(c.trusted, addr) || !hasV2Prefix(b[:n]) {
+			return n, addr, err
+		}
+
+		src, hdrLen, parseErr := parseV2(b[:n])
+		if parseErr != nil {
+			log.Debug("proxyproto: reading header from %s: %s", addr, parseErr)
+
+			continue
+		}
+
+		n = copy(b, b[hdrLen:n])
+		if !src.IsValid() {
+			return n, addr, nil
+		}
+
+		return n, &Addr{
+			Proxy:  addr,
+			Client: src,
+		}, nil
+	}
+}
+
+// WriteTo implements the [net.PacketConn] interface for *PacketConn.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	if a, ok := addr.(*Addr); ok {
+		addr = a.Proxy
+	}
+
+	return c.PacketConn.WriteTo(b, addr)
+}
@@ -0,0 +1,140 @@
+package proxyproto_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/proxyproto"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
+
+// Common sets of the trusted networks for tests.
+var (
+	trustedLocal = netutil.SliceSubnetSet{netip.MustParsePrefix("127.0.0.0/8")}
+	trustedOther = netutil.SliceSubnetSet{netip.MustParsePrefix("192.0.2.0/24")}
+)
+
+// testPayload is the data sent after the header.
+const testPayload = "payload"
+
+func TestListener(t *testing.T) {
+	testCases := []struct {
+		trusted    netutil.SubnetSet
+		name       string
+		header     string
+		wantRemote string
+		wantData   string
+	}{{
+		trusted:    trustedLocal,
+		name:       "v1",
+		header:     "PROXY TCP4 192.0.2.1 192.0.2.2 12345 53\r\n",
+		wantRemote: "192.0.2.1:12345",
+		wantData:   testPayload,
+	}, {
+		trusted:    trustedLocal,
+		name:       "v1_unknown",
+		header:     "PROXY UNKNOWN\r\n",
+		wantRemote: "",
+		wantData:   testPayload,
+	}, {
+		trusted:    trustedLocal,
+		name:       "no_header",
+		header:     "",
+		wantRemote: "",
+		wantData:   testPayload,
+	}, {
+		trusted:    trustedOther,
+		name:       "untrusted",
+		header:     "PROXY TCP4 192.0.2.1 192.0.2.2 12345 53\r\n",
+		wantRemote: "",
+		wantData:   "PROXY TCP4 192.0.2.1 192.0.2.2 12345 53\r\n" + testPayload,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+
+			pl := proxyproto.NewListener(l, tc.trusted)
+			testutil.CleanupAndRequireSuccess(t, pl.Close)
+
+			cli, err := net.Dial("tcp", l.Addr().String())
+			require.NoError(t, err)
+			testutil.CleanupAndRequireSuccess(t, cli.Close)
+
+			_, err = io.WriteString(cli, tc.header+testPayload)
+			require.NoError(t, err)
+			require.NoError(t, cli.(*net.TCPConn).CloseWrite())
+
+			conn, err := pl.Accept()
+			require.NoError(t, err)
+			testutil.CleanupAndRequireSuccess(t, conn.Close)
+
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(testTimeout)))
+
+			wantRemote := tc.wantRemote
+			if wantRemote == "" {
+				wantRemote = cli.LocalAddr().String()
+			}
+
+			assert.Equal(t, wantRemote, conn.RemoteAddr().String())
+
+			data, err := io.ReadAll(conn)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantData, string(data))
+		})
+	}
+}
+
+func TestPacketConn(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	pc := proxyproto.NewPacketConn(c, trustedLocal)
+	testutil.CleanupAndRequireSuccess(t, pc.Close)
+
+	cli, err := net.Dial("udp", c.LocalAddr().String())
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, cli.Close)
+
+	hdr := []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x12")
+	hdr = binary.BigEndian.AppendUint16(hdr, 12)
+	hdr = append(hdr, 192, 0, 2, 1, 192, 0, 2, 2, 0x30, 0x39, 0, 53)
+
+	_, err = cli.Write(append(hdr, testPayload...))
+	require.NoError(t, err)
+
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(testTimeout)))
+
+	buf := make([]byte, 512)
+	n, addr, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, testPayload, string(buf[:n]))
+
+	ppAddr := testutil.RequireTypeAssert[*proxyproto.Addr](t, addr)
+	assert.Equal(t, netip.MustParseAddrPort("192.0.2.1:12345"), ppAddr.AddrPort())
+	assert.Equal(t, cli.LocalAddr().String(), ppAddr.Proxy.String())
+
+	// The response must be sent to the proxy.
+	_, err = pc.WriteTo([]byte("response"), addr)
+	require.NoError(t, err)
+
+	require.NoError(t, cli.SetReadDeadline(time.Now().Add(testTimeout)))
+
+	n, err = cli.Read(buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "response", string(buf[:n]))
+}
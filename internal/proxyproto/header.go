@@ -0,0 +1,177 @@
+// Package proxyproto implements the receiving side of the PROXY protocol
+// versions 1 and 2, which the load balancers and the reverse proxies use to
+// pass the original addresses of the clients.
+//
+// See https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt.
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// sigV1 is the prefix of the version 1 header.
+const sigV1 = "PROXY "
+
+// sigV2 is the signature of the version 2 header.
+var sigV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Lengths of the headers.
+const (
+	// maxLenV1 is the maximum length of the version 1 header including the
+	// CRLF.
+	maxLenV1 = 107
+
+	// fixedLenV2 is the length of the fixed part of the version 2 header.
+	fixedLenV2 = 16
+
+	// maxLenV2 is the maximum length of the version 2 header accepted.  The
+	// protocol allows longer headers, but the type-length-value fields
+	// exceeding this aren't sent by the common proxies.
+	maxLenV2 = 4096
+)
+
+// Commands of the version 2 header.
+const (
+	cmdLocal = 0x0
+	cmdProxy = 0x1
+)
+
+// Address families of the version 2 header.
+const (
+	famUnspec = 0x0
+	famInet   = 0x1
+	famInet6  = 0x2
+)
+
+// Lengths of the address blocks of the version 2 header.
+const (
+	addrsLenInet  = 2*4 + 2*2
+	addrsLenInet6 = 2*16 + 2*2
+)
+
+// ErrNoHeader is returned when the data doesn't start with a PROXY protocol
+// header.
+const ErrNoHeader errors.Error = "no proxy protocol header"
+
+// hasV1Prefix returns true if b starts with the prefix of a version 1 header.
+func hasV1Prefix(b []byte) (ok bool) {
+	return bytes.HasPrefix(b, []byte(sigV1))
+}
+
+// hasV2Prefix returns true if b starts with the signature of a version 2
+// header.
+func hasV2Prefix(b []byte) (ok bool) {
+	return bytes.HasPrefix(b, sigV2)
+}
+
+// lenV2 returns the full length of the version 2 header starting b.  b must
+// contain at least fixedLenV2 bytes.
+func lenV2(b []byte) (n int) {
+	return fixedLenV2 + int(binary.BigEndian.Uint16(b[14:fixedLenV2]))
+}
+
+// parseV2 parses the version 2 header at the start of b.  src is the original
+// source address or an invalid one if the header doesn't carry it, for example
+// for the health checks of the proxy.  n is the length of the header.
+func parseV2(b []byte) (src netip.AddrPort, n int, err error) {
+	if !hasV2Prefix(b) {
+		return netip.AddrPort{}, 0, ErrNoHeader
+	} else if len(b) < fixedLenV2 {
+		return netip.AddrPort{}, 0, errors.Error("v2: header too short")
+	}
+
+	verCmd, fam := b[12], b[13]
+	if ver := verCmd >> 4; ver != 2 {
+		return netip.AddrPort{}, 0, fmt.Errorf("v2: bad version %d", ver)
+	}
+
+	n = lenV2(b)
+	if n > maxLenV2 {
+		return netip.AddrPort{}, 0, fmt.Errorf("v2: header too long: %d bytes", n)
+	} else if len(b) < n {
+		return netip.AddrPort{}, 0, errors.Error("v2: header truncated")
+	}
+
+	switch cmd := verCmd & 0xf; cmd {
+	case cmdLocal:
+		return netip.AddrPort{}, n, nil
+	case cmdProxy:
+		// Go on.
+	default:
+		return netip.AddrPort{}, 0, fmt.Errorf("v2: bad command %d", cmd)
+	}
+
+	addrs := b[fixedLenV2:n]
+	switch fam >> 4 {
+	case famInet:
+		if len(addrs) < addrsLenInet {
+			return netip.AddrPort{}, 0, errors.Error("v2: ipv4 addresses truncated")
+		}
+
+		ip := netip.AddrFrom4([4]byte(addrs[:4]))
+		port := binary.BigEndian.Uint16(addrs[8:10])
+
+		return netip.AddrPortFrom(ip, port), n, nil
+	case famInet6:
+		if len(addrs) < addrsLenInet6 {
+			return netip.AddrPort{}, 0, errors.Error("v2: ipv6 addresses truncated")
+		}
+
+		ip := netip.AddrFrom16([16]byte(addrs[:16])).Unmap()
+		port := binary.BigEndian.Uint16(addrs[32:34])
+
+		return netip.AddrPortFrom(ip, port), n, nil
+	default:
+		// Unspecified and Unix socket addresses don't carry an IP address.
+		return netip.AddrPort{}, n, nil
+	}
+}
+
+// parseV1 parses the version 1 header line, including the CRLF.  src is the
+// original source address or an invalid one if the protocol is UNKNOWN.
+func parseV1(line []byte) (src netip.AddrPort, err error) {
+	s, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return netip.AddrPort{}, errors.Error("v1: no crlf")
+	}
+
+	fields := strings.Split(s, " ")
+	if len(fields) < 2 || fields[0] != strings.TrimSpace(sigV1) {
+		return netip.AddrPort{}, errors.Error("v1: bad header")
+	}
+
+	proto := fields[1]
+	switch proto {
+	case "UNKNOWN":
+		return netip.AddrPort{}, nil
+	case "TCP4", "TCP6":
+		// Go on.
+	default:
+		return netip.AddrPort{}, fmt.Errorf("v1: bad protocol %q", proto)
+	}
+
+	if len(fields) != 6 {
+		return netip.AddrPort{}, fmt.Errorf("v1: bad number of fields: %d", len(fields))
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("v1: source address: %w", err)
+	} else if ip.Is4() != (proto == "TCP4") {
+		return netip.AddrPort{}, fmt.Errorf("v1: source address %s for %s", ip, proto)
+	}
+
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("v1: source port: %w", err)
+	}
+
+	return netip.AddrPortFrom(ip, uint16(port)), nil
+}
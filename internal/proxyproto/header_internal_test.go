@@ -0,0 +1,160 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// newV2Header returns a version 2 header with the given command, family, and
+// address block.
+func newV2Header(verCmd, fam byte, addrs []byte) (b []byte) {
+	b = append([]byte{}, sigV2...)
+	b = append(b, verCmd, fam)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(addrs)))
+
+	return append(b, addrs...)
+}
+
+func TestParseV2(t *testing.T) {
+	inet := []byte{
+		// Source and destination addresses.
+		192, 0, 2, 1,
+		192, 0, 2, 2,
+		// Source and destination ports.
+		0x30, 0x39,
+		0, 53,
+	}
+
+	inet6 := make([]byte, addrsLenInet6)
+	copy(inet6, netip.MustParseAddr("2001:db8::1").AsSlice())
+	binary.BigEndian.PutUint16(inet6[32:], 12345)
+
+	testCases := []struct {
+		name       string
+		wantErrMsg string
+		in         []byte
+		wantSrc    netip.AddrPort
+		wantLen    int
+	}{{
+		name:       "inet",
+		wantErrMsg: "",
+		in:         newV2Header(0x21, 0x12, inet),
+		wantSrc:    netip.MustParseAddrPort("192.0.2.1:12345"),
+		wantLen:    fixedLenV2 + addrsLenInet,
+	}, {
+		name:       "inet6",
+		wantErrMsg: "",
+		in:         newV2Header(0x21, 0x21, inet6),
+		wantSrc:    netip.MustParseAddrPort("[2001:db8::1]:12345"),
+		wantLen:    fixedLenV2 + addrsLenInet6,
+	}, {
+		name:       "local",
+		wantErrMsg: "",
+		in:         newV2Header(0x20, 0x00, nil),
+		wantSrc:    netip.AddrPort{},
+		wantLen:    fixedLenV2,
+	}, {
+		name:       "tlvs",
+		wantErrMsg: "",
+		in:         newV2Header(0x21, 0x11, append(inet, 0x04, 0x00, 0x01, 0xff)),
+		wantSrc:    netip.MustParseAddrPort("192.0.2.1:12345"),
+		wantLen:    fixedLenV2 + addrsLenInet + 4,
+	}, {
+		name:       "no_header",
+		wantErrMsg: string(ErrNoHeader),
+		in:         []byte("\x00\x1c\x12\x34\x01\x00\x00\x01\x00\x00\x00\x00"),
+		wantSrc:    netip.AddrPort{},
+		wantLen:    0,
+	}, {
+		name:       "bad_version",
+		wantErrMsg: "v2: bad version 1",
+		in:         newV2Header(0x11, 0x11, inet),
+		wantSrc:    netip.AddrPort{},
+		wantLen:    0,
+	}, {
+		name:       "bad_command",
+		wantErrMsg: "v2: bad command 2",
+		in:         newV2Header(0x22, 0x11, inet),
+		wantSrc:    netip.AddrPort{},
+		wantLen:    0,
+	}, {
+		name:       "truncated",
+		wantErrMsg: "v2: header truncated",
+		in:         newV2Header(0x21, 0x11, inet)[:fixedLenV2+4],
+		wantSrc:    netip.AddrPort{},
+		wantLen:    0,
+	}, {
+		name:       "short_addrs",
+		wantErrMsg: "v2: ipv4 addresses truncated",
+		in:         newV2Header(0x21, 0x11, inet[:8]),
+		wantSrc:    netip.AddrPort{},
+		wantLen:    0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, n, err := parseV2(tc.in)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.wantSrc, src)
+			assert.Equal(t, tc.wantLen, n)
+		})
+	}
+}
+
+func TestParseV1(t *testing.T) {
+	testCases := []struct {
+		name       string
+		in         string
+		wantErrMsg string
+		wantSrc    netip.AddrPort
+	}{{
+		name:       "tcp4",
+		in:         "PROXY TCP4 192.0.2.1 192.0.2.2 12345 53\r\n",
+		wantErrMsg: "",
+		wantSrc:    netip.MustParseAddrPort("192.0.2.1:12345"),
+	}, {
+		name:       "tcp6",
+		in:         "PROXY TCP6 2001:db8::1 2001:db8::2 12345 853\r\n",
+		wantErrMsg: "",
+		wantSrc:    netip.MustParseAddrPort("[2001:db8::1]:12345"),
+	}, {
+		name:       "unknown",
+		in:         "PROXY UNKNOWN\r\n",
+		wantErrMsg: "",
+		wantSrc:    netip.AddrPort{},
+	}, {
+		name:       "no_crlf",
+		in:         "PROXY TCP4 192.0.2.1 192.0.2.2 12345 53\n",
+		wantErrMsg: "v1: no crlf",
+		wantSrc:    netip.AddrPort{},
+	}, {
+		name:       "bad_protocol",
+		in:         "PROXY UDP4 192.0.2.1 192.0.2.2 12345 53\r\n",
+		wantErrMsg: `v1: bad protocol "UDP4"`,
+		wantSrc:    netip.AddrPort{},
+	}, {
+		name:       "family_mismatch",
+		in:         "PROXY TCP6 192.0.2.1 192.0.2.2 12345 53\r\n",
+		wantErrMsg: "v1: source address 192.0.2.1 for TCP6",
+		wantSrc:    netip.AddrPort{},
+	}, {
+		name:       "bad_fields",
+		in:         "PROXY TCP4 192.0.2.1 12345\r\n",
+		wantErrMsg: "v1: bad number of fields: 4",
+		wantSrc:    netip.AddrPort{},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := parseV1([]byte(tc.in))
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.wantSrc, src)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/netip"
 	"strings"
@@ -13,7 +14,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering/rulelist"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/urlfilter/rules"
 	"github.com/miekg/dns"
 )
@@ -174,26 +175,32 @@ var logEntryHandlers = map[string]logEntryHandler{
 }
 
 // decodeResultRuleKey decodes the token of "Rules" type to logEntry struct.
-func decodeResultRuleKey(key string, i int, dec *json.Decoder, ent *logEntry) {
+func decodeResultRuleKey(
+	logger *slog.Logger,
+	key string,
+	i int,
+	dec *json.Decoder,
+	ent *logEntry,
+) {
 	var vToken json.Token
 	switch key {
 	case "FilterListID":
-		ent.Result.Rules, vToken = decodeVTokenAndAddRule(key, i, dec, ent.Result.Rules)
+		ent.Result.Rules, vToken = decodeVTokenAndAddRule(logger, key, i, dec, ent.Result.Rules)
 		if n, ok := vToken.(json.Number); ok {
 			id, _ := n.Int64()
 			ent.Result.Rules[i].FilterListID = rulelist.URLFilterID(id)
 		}
 	case "IP":
-		ent.Result.Rules, vToken = decodeVTokenAndAddRule(key, i, dec, ent.Result.Rules)
+		ent.Result.Rules, vToken = decodeVTokenAndAddRule(logger, key, i, dec, ent.Result.Rules)
 		if ipStr, ok := vToken.(string); ok {
 			if ip, err := netip.ParseAddr(ipStr); err == nil {
 				ent.Result.Rules[i].IP = ip
 			} else {
-				log.Debug("querylog: decoding ipStr value: %s", err)
+				logger.Debug("decoding rule ip", slogutil.KeyError, err)
 			}
 		}
 	case "Text":
-		ent.Result.Rules, vToken = decodeVTokenAndAddRule(key, i, dec, ent.Result.Rules)
+		ent.Result.Rules, vToken = decodeVTokenAndAddRule(logger, key, i, dec, ent.Result.Rules)
 		if s, ok := vToken.(string); ok {
 			ent.Result.Rules[i].Text = s
 		}
@@ -205,6 +212,7 @@ func decodeResultRuleKey(key string, i int, dec *json.Decoder, ent *logEntry) {
 // decodeVTokenAndAddRule decodes the "Rules" toke as [filtering.ResultRule]
 // and then adds the decoded object to the slice of result rules.
 func decodeVTokenAndAddRule(
+	logger *slog.Logger,
 	key string,
 	i int,
 	dec *json.Decoder,
@@ -215,7 +223,7 @@ func decodeVTokenAndAddRule(
 	vToken, err := dec.Token()
 	if err != nil {
 		if err != io.EOF {
-			log.Debug("decodeResultRuleKey %s err: %s", key, err)
+			logger.Debug("decoding rule", "key", key, slogutil.KeyError, err)
 		}
 
 		return newRules, nil
@@ -230,12 +238,12 @@ func decodeVTokenAndAddRule(
 
 // decodeResultRules parses the dec's tokens into logEntry ent interpreting it
 // as a slice of the result rules.
-func decodeResultRules(dec *json.Decoder, ent *logEntry) {
+func decodeResultRules(logger *slog.Logger, dec *json.Decoder, ent *logEntry) {
 	for {
 		delimToken, err := dec.Token()
 		if err != nil {
 			if err != io.EOF {
-				log.Debug("decodeResultRules err: %s", err)
+				logger.Debug("decoding rules", slogutil.KeyError, err)
 			}
 
 			return
@@ -244,13 +252,13 @@ func decodeResultRules(dec *json.Decoder, ent *logEntry) {
 		if d, ok := delimToken.(json.Delim); !ok {
 			return
 		} else if d != '[' {
-			log.Debug("decodeResultRules: unexpected delim %q", d)
+			logger.Debug("decoding rules: unexpected delim", "delim", d)
 		}
 
-		err = decodeResultRuleToken(dec, ent)
+		err = decodeResultRuleToken(logger, dec, ent)
 		if err != nil {
 			if err != io.EOF && !errors.Is(err, ErrEndOfToken) {
-				log.Debug("decodeResultRules err: %s", err)
+				logger.Debug("decoding rules", slogutil.KeyError, err)
 			}
 
 			return
@@ -259,7 +267,7 @@ func decodeResultRules(dec *json.Decoder, ent *logEntry) {
 }
 
 // decodeResultRuleToken decodes the tokens of "Rules" type to the logEntry ent.
-func decodeResultRuleToken(dec *json.Decoder, ent *logEntry) (err error) {
+func decodeResultRuleToken(logger *slog.Logger, dec *json.Decoder, ent *logEntry) (err error) {
 	i := 0
 	for {
 		var keyToken json.Token
@@ -287,7 +295,7 @@ func decodeResultRuleToken(dec *json.Decoder, ent *logEntry) (err error) {
 			return fmt.Errorf("keyToken is %T (%[1]v) and not string", keyToken)
 		}
 
-		decodeResultRuleKey(key, i, dec, ent)
+		decodeResultRuleKey(logger, key, i, dec, ent)
 	}
 }
 
@@ -296,12 +304,12 @@ func decodeResultRuleToken(dec *json.Decoder, ent *logEntry) (err error) {
 // other occurrences of DNSRewriteResult in the entry since hosts container's
 // rewrites currently has the highest priority along the entire filtering
 // pipeline.
-func decodeResultReverseHosts(dec *json.Decoder, ent *logEntry) {
+func decodeResultReverseHosts(logger *slog.Logger, dec *json.Decoder, ent *logEntry) {
 	for {
 		itemToken, err := dec.Token()
 		if err != nil {
 			if err != io.EOF {
-				log.Debug("decodeResultReverseHosts err: %s", err)
+				logger.Debug("decoding reverse hosts", slogutil.KeyError, err)
 			}
 
 			return
@@ -315,7 +323,7 @@ func decodeResultReverseHosts(dec *json.Decoder, ent *logEntry) {
 				return
 			}
 
-			log.Debug("decodeResultReverseHosts: unexpected delim %q", v)
+			logger.Debug("decoding reverse hosts: unexpected delim", "delim", v)
 
 			return
 		case string:
@@ -346,12 +354,12 @@ func decodeResultReverseHosts(dec *json.Decoder, ent *logEntry) {
 
 // decodeResultIPList parses the dec's tokens into logEntry ent interpreting it
 // as the result IP addresses list.
-func decodeResultIPList(dec *json.Decoder, ent *logEntry) {
+func decodeResultIPList(logger *slog.Logger, dec *json.Decoder, ent *logEntry) {
 	for {
 		itemToken, err := dec.Token()
 		if err != nil {
 			if err != io.EOF {
-				log.Debug("decodeResultIPList err: %s", err)
+				logger.Debug("decoding ip list", slogutil.KeyError, err)
 			}
 
 			return
@@ -365,7 +373,7 @@ func decodeResultIPList(dec *json.Decoder, ent *logEntry) {
 				return
 			}
 
-			log.Debug("decodeResultIPList: unexpected delim %q", v)
+			logger.Debug("decoding ip list: unexpected delim", "delim", v)
 
 			return
 		case string:
@@ -382,7 +390,12 @@ func decodeResultIPList(dec *json.Decoder, ent *logEntry) {
 
 // decodeResultDNSRewriteResultKey decodes the token of "DNSRewriteResult" type
 // to the logEntry struct.
-func decodeResultDNSRewriteResultKey(key string, dec *json.Decoder, ent *logEntry) {
+func decodeResultDNSRewriteResultKey(
+	logger *slog.Logger,
+	key string,
+	dec *json.Decoder,
+	ent *logEntry,
+) {
 	var err error
 
 	switch key {
@@ -391,7 +404,7 @@ func decodeResultDNSRewriteResultKey(key string, dec *json.Decoder, ent *logEntr
 		vToken, err = dec.Token()
 		if err != nil {
 			if err != io.EOF {
-				log.Debug("decodeResultDNSRewriteResultKey err: %s", err)
+				logger.Debug("decoding dnsrewrite result", "key", key, slogutil.KeyError, err)
 			}
 
 			return
@@ -419,7 +432,7 @@ func decodeResultDNSRewriteResultKey(key string, dec *json.Decoder, ent *logEntr
 		// decoding and correct the values.
 		err = dec.Decode(&ent.Result.DNSRewriteResult.Response)
 		if err != nil {
-			log.Debug("decodeResultDNSRewriteResultKey response err: %s", err)
+			logger.Debug("decoding dnsrewrite result response", slogutil.KeyError, err)
 		}
 
 		ent.parseDNSRewriteResultIPs()
@@ -430,12 +443,12 @@ func decodeResultDNSRewriteResultKey(key string, dec *json.Decoder, ent *logEntr
 
 // decodeResultDNSRewriteResult parses the dec's tokens into logEntry ent
 // interpreting it as the result DNSRewriteResult.
-func decodeResultDNSRewriteResult(dec *json.Decoder, ent *logEntry) {
+func decodeResultDNSRewriteResult(logger *slog.Logger, dec *json.Decoder, ent *logEntry) {
 	for {
 		key, err := parseKeyToken(dec)
 		if err != nil {
 			if err != io.EOF && !errors.Is(err, ErrEndOfToken) {
-				log.Debug("decodeResultDNSRewriteResult: %s", err)
+				logger.Debug("decoding dnsrewrite result", slogutil.KeyError, err)
 			}
 
 			return
@@ -445,7 +458,7 @@ func decodeResultDNSRewriteResult(dec *json.Decoder, ent *logEntry) {
 			continue
 		}
 
-		decodeResultDNSRewriteResultKey(key, dec, ent)
+		decodeResultDNSRewriteResultKey(logger, key, dec, ent)
 	}
 }
 
@@ -508,14 +521,14 @@ func parseKeyToken(dec *json.Decoder) (key string, err error) {
 }
 
 // decodeResult decodes a token of "Result" type to logEntry struct.
-func decodeResult(dec *json.Decoder, ent *logEntry) {
+func decodeResult(logger *slog.Logger, dec *json.Decoder, ent *logEntry) {
 	defer translateResult(ent)
 
 	for {
 		key, err := parseKeyToken(dec)
 		if err != nil {
 			if err != io.EOF && !errors.Is(err, ErrEndOfToken) {
-				log.Debug("decodeResult: %s", err)
+				logger.Debug("decoding result", slogutil.KeyError, err)
 			}
 
 			return
@@ -527,7 +540,7 @@ func decodeResult(dec *json.Decoder, ent *logEntry) {
 
 		decHandler, ok := resultDecHandlers[key]
 		if ok {
-			decHandler(dec, ent)
+			decHandler(logger, dec, ent)
 
 			continue
 		}
@@ -543,7 +556,7 @@ func decodeResult(dec *json.Decoder, ent *logEntry) {
 		}
 
 		if err = handler(val, ent); err != nil {
-			log.Debug("decodeResult handler err: %s", err)
+			logger.Debug("decoding result", "key", key, slogutil.KeyError, err)
 
 			return
 		}
@@ -637,15 +650,16 @@ var resultHandlers = map[string]logEntryHandler{
 }
 
 // resultDecHandlers is the map of decode handlers for various keys.
-var resultDecHandlers = map[string]func(dec *json.Decoder, ent *logEntry){
+var resultDecHandlers = map[string]func(logger *slog.Logger, dec *json.Decoder, ent *logEntry){
 	"ReverseHosts":     decodeResultReverseHosts,
 	"IPList":           decodeResultIPList,
 	"Rules":            decodeResultRules,
 	"DNSRewriteResult": decodeResultDNSRewriteResult,
 }
 
-// decodeLogEntry decodes string str to logEntry ent.
-func decodeLogEntry(ent *logEntry, str string) {
+// decodeLogEntry decodes string str to logEntry ent.  logger is used to log
+// the decoding errors.
+func decodeLogEntry(logger *slog.Logger, ent *logEntry, str string) {
 	dec := json.NewDecoder(strings.NewReader(str))
 	dec.UseNumber()
 
@@ -653,7 +667,7 @@ func decodeLogEntry(ent *logEntry, str string) {
 		keyToken, err := dec.Token()
 		if err != nil {
 			if err != io.EOF {
-				log.Debug("decodeLogEntry err: %s", err)
+				logger.Debug("decoding log entry", slogutil.KeyError, err)
 			}
 
 			return
@@ -665,13 +679,22 @@ func decodeLogEntry(ent *logEntry, str string) {
 
 		key, ok := keyToken.(string)
 		if !ok {
-			log.Debug("decodeLogEntry: keyToken is %T (%[1]v) and not string", keyToken)
+			logger.Debug("decoding log entry: key token is not a string", "token", keyToken)
 
 			return
 		}
 
 		if key == "Result" {
-			decodeResult(dec, ent)
+			decodeResult(logger, dec, ent)
+
+			continue
+		} else if key == "Geo" {
+			err = dec.Decode(&ent.AnswerGeo)
+			if err != nil {
+				logger.Debug("decoding log entry geo", slogutil.KeyError, err)
+
+				return
+			}
 
 			continue
 		}
@@ -687,7 +710,7 @@ func decodeLogEntry(ent *logEntry, str string) {
 		}
 
 		if err = handler(val, ent); err != nil {
-			log.Debug("decodeLogEntry handler err: %s", err)
+			logger.Debug("decoding log entry", "key", key, slogutil.KeyError, err)
 
 			return
 		}
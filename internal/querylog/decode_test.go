@@ -3,15 +3,16 @@ package querylog
 import (
 	"bytes"
 	"encoding/base64"
+	"log/slog"
 	"net"
 	"net/netip"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/AdguardTeam/urlfilter/rules"
 	"github.com/miekg/dns"
@@ -21,9 +22,10 @@ import (
 
 func TestDecodeLogEntry(t *testing.T) {
 	logOutput := &bytes.Buffer{}
-
-	aghtest.ReplaceLogWriter(t, logOutput)
-	aghtest.ReplaceLogLevel(t, log.DEBUG)
+	logger := slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: slogutil.RemoveTime,
+	}))
 
 	t.Run("success", func(t *testing.T) {
 		const ansStr = `Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==`
@@ -36,6 +38,7 @@ func TestDecodeLogEntry(t *testing.T) {
 			`"CP":"",` +
 			`"ECS":"1.2.3.0/24",` +
 			`"Answer":"` + ansStr + `",` +
+			`"Geo":[{"ip":"0.0.0.0","country":"DE","as_org":"Example","asn":64500}],` +
 			`"Cached":true,` +
 			`"AD":true,` +
 			`"Result":{` +
@@ -63,7 +66,13 @@ func TestDecodeLogEntry(t *testing.T) {
 			ClientProto: "",
 			ReqECS:      "1.2.3.0/24",
 			Answer:      ans,
-			Cached:      true,
+			AnswerGeo: []*geoip.IPInfo{{
+				IP:      netip.IPv4Unspecified(),
+				Country: "DE",
+				ASOrg:   "Example",
+				ASN:     64500,
+			}},
+			Cached: true,
 			Result: filtering.Result{
 				DNSRewriteResult: &filtering.DNSRewriteResult{
 					RCode: dns.RcodeSuccess,
@@ -92,7 +101,7 @@ func TestDecodeLogEntry(t *testing.T) {
 		}
 
 		got := &logEntry{}
-		decodeLogEntry(got, data)
+		decodeLogEntry(logger, got, data)
 
 		s := logOutput.String()
 		assert.Empty(t, s)
@@ -113,11 +122,13 @@ func TestDecodeLogEntry(t *testing.T) {
 	}, {
 		name: "bad_filter_id_old_rule",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3,"FilterID":1.5},"Elapsed":837429}`,
-		want: "decodeResult handler err: strconv.ParseInt: parsing \"1.5\": invalid syntax\n",
+		want: `level=DEBUG msg="decoding result" key=FilterID ` +
+			`err="strconv.ParseInt: parsing \"1.5\": invalid syntax"` + "\n",
 	}, {
 		name: "bad_is_filtered",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":trooe,"Reason":3},"Elapsed":837429}`,
-		want: "decodeLogEntry err: invalid character 'o' in literal true (expecting 'u')\n",
+		want: `level=DEBUG msg="decoding log entry" ` +
+			`err="invalid character 'o' in literal true (expecting 'u')"` + "\n",
 	}, {
 		name: "bad_elapsed",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3},"Elapsed":-1}`,
@@ -129,7 +140,9 @@ func TestDecodeLogEntry(t *testing.T) {
 	}, {
 		name: "bad_time",
 		log:  `{"IP":"127.0.0.1","T":"12/09/1998T15:00:00.000000+05:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3},"Elapsed":837429}`,
-		want: "decodeLogEntry handler err: parsing time \"12/09/1998T15:00:00.000000+05:00\" as \"2006-01-02T15:04:05Z07:00\": cannot parse \"12/09/1998T15:00:00.000000+05:00\" as \"2006\"\n",
+		want: `level=DEBUG msg="decoding log entry" key=T err="parsing time ` +
+			`\"12/09/1998T15:00:00.000000+05:00\" as \"2006-01-02T15:04:05Z07:00\": ` +
+			`cannot parse \"12/09/1998T15:00:00.000000+05:00\" as \"2006\""` + "\n",
 	}, {
 		name: "bad_host",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":6,"QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3},"Elapsed":837429}`,
@@ -149,7 +162,8 @@ func TestDecodeLogEntry(t *testing.T) {
 	}, {
 		name: "very_bad_client_proto",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"dog","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3},"Elapsed":837429}`,
-		want: "decodeLogEntry handler err: invalid client proto: \"dog\"\n",
+		want: `level=DEBUG msg="decoding log entry" key=CP ` +
+			`err="invalid client proto: \"dog\""` + "\n",
 	}, {
 		name: "bad_answer",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":0.9,"Result":{"IsFiltered":true,"Reason":3},"Elapsed":837429}`,
@@ -157,7 +171,8 @@ func TestDecodeLogEntry(t *testing.T) {
 	}, {
 		name: "very_bad_answer",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3},"Elapsed":837429}`,
-		want: "decodeLogEntry handler err: illegal base64 data at input byte 61\n",
+		want: `level=DEBUG msg="decoding log entry" key=Answer ` +
+			`err="illegal base64 data at input byte 61"` + "\n",
 	}, {
 		name: "bad_rule",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3,"Rule":false},"Elapsed":837429}`,
@@ -169,16 +184,16 @@ func TestDecodeLogEntry(t *testing.T) {
 	}, {
 		name: "bad_reverse_hosts",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3,"ReverseHosts":[{}]},"Elapsed":837429}`,
-		want: "decodeResultReverseHosts: unexpected delim \"{\"\n",
+		want: `level=DEBUG msg="decoding reverse hosts: unexpected delim" delim={` + "\n",
 	}, {
 		name: "bad_ip_list",
 		log:  `{"IP":"127.0.0.1","T":"2020-11-25T18:55:56.519796+03:00","QH":"an.yandex.ru","QT":"A","QC":"IN","CP":"","Answer":"Qz+BgAABAAEAAAAAAmFuBnlhbmRleAJydQAAAQABwAwAAQABAAAACgAEAAAAAA==","Result":{"IsFiltered":true,"Reason":3,"ReverseHosts":["example.net"],"IPList":[{}]},"Elapsed":837429}`,
-		want: "decodeResultIPList: unexpected delim \"{\"\n",
+		want: `level=DEBUG msg="decoding ip list: unexpected delim" delim={` + "\n",
 	}}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			decodeLogEntry(new(logEntry), tc.log)
+			decodeLogEntry(logger, new(logEntry), tc.log)
 
 			s := logOutput.String()
 			if tc.want == "" {
@@ -249,7 +264,7 @@ func TestDecodeLogEntry_backwardCompatability(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			e := &logEntry{}
-			decodeLogEntry(e, tc.entry)
+			decodeLogEntry(slogutil.NewDiscardLogger(), e, tc.entry)
 
 			assert.Equal(t, tc.want, e)
 		})
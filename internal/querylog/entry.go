@@ -1,12 +1,14 @@
 package querylog
 
 import (
+	"log/slog"
 	"net"
 	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/miekg/dns"
 )
 
@@ -31,6 +33,10 @@ type logEntry struct {
 	Answer     []byte `json:",omitempty"`
 	OrigAnswer []byte `json:",omitempty"`
 
+	// AnswerGeo is the information about the countries and the autonomous
+	// systems of the addresses in the answer.
+	AnswerGeo []*geoip.IPInfo `json:"Geo,omitempty"`
+
 	IP net.IP `json:"IP"`
 
 	Result filtering.Result
@@ -50,8 +56,8 @@ func (e *logEntry) shallowClone() (clone *logEntry) {
 
 // addResponse adds data from resp to e.Answer if resp is not nil.  If isOrig is
 // true, addResponse sets the e.OrigAnswer field instead of e.Answer.  Any
-// errors are logged.
-func (e *logEntry) addResponse(resp *dns.Msg, isOrig bool) {
+// errors are logged with logger.
+func (e *logEntry) addResponse(logger *slog.Logger, resp *dns.Msg, isOrig bool) {
 	if resp == nil {
 		return
 	}
@@ -65,7 +71,7 @@ func (e *logEntry) addResponse(resp *dns.Msg, isOrig bool) {
 		err = errors.Annotate(err, "packing answer: %w")
 	}
 	if err != nil {
-		log.Error("querylog: %s", err)
+		logger.Error("adding response", slogutil.KeyError, err)
 	}
 }
 
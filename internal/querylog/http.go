@@ -3,6 +3,7 @@ package querylog
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math"
 	"net"
 	"net/http"
@@ -15,7 +16,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"golang.org/x/net/idna"
 )
@@ -74,7 +75,7 @@ func (l *queryLog) initWeb() {
 
 // handleQueryLog is the handler for the GET /control/querylog HTTP API.
 func (l *queryLog) handleQueryLog(w http.ResponseWriter, r *http.Request) {
-	params, err := parseSearchParams(r)
+	params, err := parseSearchParams(l.logger, r)
 	if err != nil {
 		aghhttp.Error(r, w, http.StatusBadRequest, "parsing params: %s", err)
 
@@ -90,7 +91,7 @@ func (l *queryLog) handleQueryLog(w http.ResponseWriter, r *http.Request) {
 		entries, oldest = l.search(params)
 	}()
 
-	resp := entriesToJSON(entries, oldest, l.anonymizer.Load())
+	resp := l.entriesToJSON(entries, oldest, l.anonymizer.Load())
 
 	aghhttp.WriteJSONResponseOK(w, r, resp)
 }
@@ -280,7 +281,8 @@ func getDoubleQuotesEnclosedValue(s *string) bool {
 }
 
 // parseSearchCriterion parses a search criterion from the query parameter.
-func parseSearchCriterion(q url.Values, name string, ct criterionType) (
+// logger is used to log the values, which can't be converted.
+func parseSearchCriterion(logger *slog.Logger, q url.Values, name string, ct criterionType) (
 	ok bool,
 	sc searchCriterion,
 	err error,
@@ -301,7 +303,7 @@ func parseSearchCriterion(q url.Values, name string, ct criterionType) (
 		// TODO(e.burkov):  Make it work with parts of IDNAs somehow.
 		loweredVal := strings.ToLower(val)
 		if asciiVal, err = idna.ToASCII(loweredVal); err != nil {
-			log.Debug("can't convert %q to ascii: %s", val, err)
+			logger.Debug("converting to ascii", "value", val, slogutil.KeyError, err)
 		} else if asciiVal == loweredVal {
 			// Purge asciiVal to prevent checking the same value
 			// twice.
@@ -331,7 +333,7 @@ func parseSearchCriterion(q url.Values, name string, ct criterionType) (
 
 // parseSearchParams parses search parameters from the HTTP request's query
 // string.
-func parseSearchParams(r *http.Request) (p *searchParams, err error) {
+func parseSearchParams(logger *slog.Logger, r *http.Request) (p *searchParams, err error) {
 	p = newSearchParams()
 
 	q := r.URL.Query()
@@ -369,7 +371,7 @@ func parseSearchParams(r *http.Request) (p *searchParams, err error) {
 	}} {
 		var ok bool
 		var c searchCriterion
-		ok, c, err = parseSearchCriterion(q, v.urlField, v.ct)
+		ok, c, err = parseSearchCriterion(logger, q, v.urlField, v.ct)
 		if err != nil {
 			return nil, err
 		}
@@ -8,7 +8,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/miekg/dns"
 	"golang.org/x/net/idna"
 )
@@ -19,7 +19,7 @@ import (
 type jobject = map[string]any
 
 // entriesToJSON converts query log entries to JSON.
-func entriesToJSON(
+func (l *queryLog) entriesToJSON(
 	entries []*logEntry,
 	oldest time.Time,
 	anonFunc aghnet.IPMutFunc,
@@ -28,7 +28,7 @@ func entriesToJSON(
 
 	// The elements order is already reversed to be from newer to older.
 	for _, entry := range entries {
-		jsonEntry := entryToJSON(entry, anonFunc)
+		jsonEntry := l.entryToJSON(entry, anonFunc)
 		data = append(data, jsonEntry)
 	}
 
@@ -44,7 +44,7 @@ func entriesToJSON(
 }
 
 // entryToJSON converts a log entry's data into an entry for the JSON API.
-func entryToJSON(entry *logEntry, anonFunc aghnet.IPMutFunc) (jsonEntry jobject) {
+func (l *queryLog) entryToJSON(entry *logEntry, anonFunc aghnet.IPMutFunc) (jsonEntry jobject) {
 	hostname := entry.QHost
 	question := jobject{
 		"type":  entry.QType,
@@ -53,7 +53,7 @@ func entryToJSON(entry *logEntry, anonFunc aghnet.IPMutFunc) (jsonEntry jobject)
 	}
 
 	if qhost, err := idna.ToUnicode(hostname); err != nil {
-		log.Debug("querylog: translating %q into unicode: %s", hostname, err)
+		l.logger.Debug("translating into unicode", "host", hostname, slogutil.KeyError, err)
 	} else if qhost != hostname && qhost != "" {
 		question["unicode_name"] = qhost
 	}
@@ -96,21 +96,25 @@ func entryToJSON(entry *logEntry, anonFunc aghnet.IPMutFunc) (jsonEntry jobject)
 		jsonEntry["service_name"] = entry.Result.ServiceName
 	}
 
-	setMsgData(entry, jsonEntry)
-	setOrigAns(entry, jsonEntry)
+	if len(entry.AnswerGeo) > 0 {
+		jsonEntry["answer_geo"] = entry.AnswerGeo
+	}
+
+	l.setMsgData(entry, jsonEntry)
+	l.setOrigAns(entry, jsonEntry)
 
 	return jsonEntry
 }
 
 // setMsgData sets the message data in jsonEntry.
-func setMsgData(entry *logEntry, jsonEntry jobject) {
+func (l *queryLog) setMsgData(entry *logEntry, jsonEntry jobject) {
 	if len(entry.Answer) == 0 {
 		return
 	}
 
 	msg := &dns.Msg{}
 	if err := msg.Unpack(entry.Answer); err != nil {
-		log.Debug("querylog: failed to unpack dns msg answer: %v: %s", entry.Answer, err)
+		l.logger.Debug("unpacking answer", "answer", entry.Answer, slogutil.KeyError, err)
 
 		return
 	}
@@ -126,7 +130,7 @@ func setMsgData(entry *logEntry, jsonEntry jobject) {
 }
 
 // setOrigAns sets the original answer data in jsonEntry.
-func setOrigAns(entry *logEntry, jsonEntry jobject) {
+func (l *queryLog) setOrigAns(entry *logEntry, jsonEntry jobject) {
 	if len(entry.OrigAnswer) == 0 {
 		return
 	}
@@ -134,7 +138,13 @@ func setOrigAns(entry *logEntry, jsonEntry jobject) {
 	orig := &dns.Msg{}
 	err := orig.Unpack(entry.OrigAnswer)
 	if err != nil {
-		log.Debug("querylog: orig.Unpack(entry.OrigAnswer): %v: %s", entry.OrigAnswer, err)
+		l.logger.Debug(
+			"unpacking original answer",
+			"answer",
+			entry.OrigAnswer,
+			slogutil.KeyError,
+			err,
+		)
 
 		return
 	}
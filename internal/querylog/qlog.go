@@ -3,6 +3,7 @@ package querylog
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
@@ -11,7 +12,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/timeutil"
 	"github.com/miekg/dns"
 )
@@ -22,6 +23,9 @@ const queryLogFileName = "querylog.json"
 
 // queryLog is a structure that writes and reads the DNS query log.
 type queryLog struct {
+	// logger is used for logging the operation of the query log.
+	logger *slog.Logger
+
 	// confMu protects conf.
 	confMu *sync.RWMutex
 
@@ -91,7 +95,7 @@ func (l *queryLog) Close() {
 	if l.conf.FileEnabled {
 		err := l.flushLogBuffer()
 		if err != nil {
-			log.Error("querylog: closing: %s", err)
+			l.logger.Error("closing", slogutil.KeyError, err)
 		}
 	}
 }
@@ -146,19 +150,20 @@ func (l *queryLog) clear() {
 	oldLogFile := l.logFile + ".1"
 	err := os.Remove(oldLogFile)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Error("removing old log file %q: %s", oldLogFile, err)
+		l.logger.Error("removing old log file", "file", oldLogFile, slogutil.KeyError, err)
 	}
 
 	err = os.Remove(l.logFile)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Error("removing log file %q: %s", l.logFile, err)
+		l.logger.Error("removing log file", "file", l.logFile, slogutil.KeyError, err)
 	}
 
-	log.Debug("querylog: cleared")
+	l.logger.Debug("cleared")
 }
 
-// newLogEntry creates an instance of logEntry from parameters.
-func newLogEntry(params *AddParams) (entry *logEntry) {
+// newLogEntry creates an instance of logEntry from parameters.  logger is used
+// to log the errors of packing the responses.
+func newLogEntry(logger *slog.Logger, params *AddParams) (entry *logEntry) {
 	q := params.Question.Question[0]
 	qHost := aghnet.NormalizeDomain(q.Name)
 
@@ -181,14 +186,16 @@ func newLogEntry(params *AddParams) (entry *logEntry) {
 
 		Cached:            params.Cached,
 		AuthenticatedData: params.AuthenticatedData,
+
+		AnswerGeo: params.AnswerGeo,
 	}
 
 	if params.ReqECS != nil {
 		entry.ReqECS = params.ReqECS.String()
 	}
 
-	entry.addResponse(params.Answer, false)
-	entry.addResponse(params.OrigAnswer, true)
+	entry.addResponse(logger, params.Answer, false)
+	entry.addResponse(logger, params.OrigAnswer, true)
 
 	return entry
 }
@@ -211,7 +218,7 @@ func (l *queryLog) Add(params *AddParams) {
 
 	err := params.validate()
 	if err != nil {
-		log.Error("querylog: adding record: %s, skipping", err)
+		l.logger.Error("adding record, skipping", slogutil.KeyError, err)
 
 		return
 	}
@@ -220,7 +227,7 @@ func (l *queryLog) Add(params *AddParams) {
 		params.Result = &filtering.Result{}
 	}
 
-	entry := newLogEntry(params)
+	entry := newLogEntry(l.logger.With("client", params.ClientIP), params)
 
 	l.bufferLock.Lock()
 	defer l.bufferLock.Unlock()
@@ -234,7 +241,7 @@ func (l *queryLog) Add(params *AddParams) {
 		go func() {
 			flushErr := l.flushLogBuffer()
 			if flushErr != nil {
-				log.Error("querylog: flushing after adding: %s", flushErr)
+				l.logger.Error("flushing after adding", slogutil.KeyError, flushErr)
 			}
 		}()
 	}
@@ -247,7 +254,7 @@ func (l *queryLog) ShouldLog(host string, _, _ uint16, ids []string) bool {
 
 	c, err := l.findClient(ids)
 	if err != nil {
-		log.Error("querylog: finding client: %s", err)
+		l.logger.Error("finding client", "ids", ids, slogutil.KeyError, err)
 	}
 
 	if c != nil && c.IgnoreQueryLog {
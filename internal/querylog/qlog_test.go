@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
@@ -394,3 +395,62 @@ func assertLogEntry(t *testing.T, entry *logEntry, host string, answer, client n
 	a := testutil.RequireTypeAssert[*dns.A](t, msg.Answer[0])
 	assert.Equal(t, answer, a.A.To16())
 }
+
+func TestQueryLog_Replay(t *testing.T) {
+	l, err := newQueryLog(Config{
+		Enabled:     true,
+		FileEnabled: true,
+		RotationIvl: timeutil.Day,
+		MemSize:     100,
+		BaseDir:     t.TempDir(),
+	})
+	require.NoError(t, err)
+
+	addEntry(l, "first.example", net.IPv4(1, 1, 1, 1), net.IPv4(2, 2, 2, 1))
+	require.NoError(t, l.flushLogBuffer())
+	require.NoError(t, l.rotate())
+
+	addEntry(l, "second.example", net.IPv4(1, 1, 1, 2), net.IPv4(2, 2, 2, 2))
+	require.NoError(t, l.flushLogBuffer())
+
+	addEntry(l, "third.example", net.IPv4(1, 1, 1, 3), net.IPv4(2, 2, 2, 3))
+
+	t.Run("all", func(t *testing.T) {
+		var hosts []string
+		err = l.Replay(time.Time{}, func(e *ReplayEntry) (cont bool) {
+			assert.Equal(t, dns.TypeA, e.QType)
+			assert.True(t, e.ClientIP.Is4())
+
+			hosts = append(hosts, e.Host)
+
+			return true
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"third.example", "second.example", "first.example"}, hosts)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var hosts []string
+		err = l.Replay(time.Time{}, func(e *ReplayEntry) (cont bool) {
+			hosts = append(hosts, e.Host)
+
+			return len(hosts) < 2
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"third.example", "second.example"}, hosts)
+	})
+
+	t.Run("since", func(t *testing.T) {
+		var hosts []string
+		err = l.Replay(time.Now().Add(time.Hour), func(e *ReplayEntry) (cont bool) {
+			hosts = append(hosts, e.Host)
+
+			return true
+		})
+		require.NoError(t, err)
+
+		assert.Empty(t, hosts)
+	})
+}
@@ -3,13 +3,14 @@ package querylog
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 )
 
 const (
@@ -35,6 +36,9 @@ const (
 // pointer to a specific position in the file, and it reads lines in reverse
 // order starting from that position.
 type qLogFile struct {
+	// logger is used for logging the operation of the file.
+	logger *slog.Logger
+
 	// file is the query log file.
 	file *os.File
 
@@ -55,13 +59,16 @@ type qLogFile struct {
 }
 
 // newQLogFile initializes a new instance of the qLogFile.
-func newQLogFile(path string) (qf *qLogFile, err error) {
+func newQLogFile(logger *slog.Logger, path string) (qf *qLogFile, err error) {
 	f, err := os.OpenFile(path, os.O_RDONLY, 0o644)
 	if err != nil {
 		return nil, err
 	}
 
-	return &qLogFile{file: f}, nil
+	return &qLogFile{
+		logger: logger,
+		file:   f,
+	}, nil
 }
 
 // validateQLogLineIdx returns error if the line index is not valid to continue
@@ -149,7 +156,7 @@ func (q *qLogFile) seekTS(timestamp int64) (pos int64, depth int, err error) {
 		lastProbeLineIdx = lineIdx
 
 		// Get the timestamp from the query log record.
-		ts := readQLogTimestamp(line)
+		ts := readQLogTimestamp(q.logger, line)
 		if ts == 0 {
 			return 0, depth, fmt.Errorf(
 				"looking up timestamp %d in %q: record %q has empty timestamp",
@@ -382,21 +389,22 @@ func readJSONValue(s, prefix string) string {
 	return s[start:end]
 }
 
-// readQLogTimestamp reads the timestamp field from the query log line.
-func readQLogTimestamp(str string) int64 {
+// readQLogTimestamp reads the timestamp field from the query log line.  The
+// errors are logged with logger.
+func readQLogTimestamp(logger *slog.Logger, str string) int64 {
 	val := readJSONValue(str, `"T":"`)
 	if len(val) == 0 {
 		val = readJSONValue(str, `"Time":"`)
 	}
 
 	if len(val) == 0 {
-		log.Error("Couldn't find timestamp: %s", str)
+		logger.Error("finding timestamp", "line", str)
 		return 0
 	}
 
 	tm, err := time.Parse(time.RFC3339Nano, val)
 	if err != nil {
-		log.Error("Couldn't parse timestamp: %s", val)
+		logger.Error("parsing timestamp", "value", val, slogutil.KeyError, err)
 		return 0
 	}
 
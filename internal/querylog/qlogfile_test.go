@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -80,7 +81,7 @@ func newTestQLogFile(t *testing.T, linesNum int) (file *qLogFile) {
 	testFile := prepareTestFiles(t, 1, linesNum)[0]
 
 	// Create the new qLogFile instance.
-	file, err := newQLogFile(testFile)
+	file, err := newQLogFile(slogutil.NewDiscardLogger(), testFile)
 	require.NoError(t, err)
 
 	assert.NotNil(t, file)
@@ -171,7 +172,7 @@ func TestQLogFile_SeekTS_good(t *testing.T) {
 			t.Run(l.name+"_"+tc.name, func(t *testing.T) {
 				line, err := getQLogFileLine(q, tc.line)
 				require.NoError(t, err)
-				ts := readQLogTimestamp(line)
+				ts := readQLogTimestamp(slogutil.NewDiscardLogger(), line)
 				assert.NotEqualValues(t, 0, ts)
 
 				// Try seeking to that line now.
@@ -221,7 +222,7 @@ func TestQLogFile_SeekTS_bad(t *testing.T) {
 
 		line, err := getQLogFileLine(q, l.num/2)
 		require.NoError(t, err)
-		testCases[2].ts = readQLogTimestamp(line) - 1
+		testCases[2].ts = readQLogTimestamp(slogutil.NewDiscardLogger(), line) - 1
 
 		for _, tc := range testCases {
 			t.Run(tc.name, func(t *testing.T) {
@@ -293,7 +294,7 @@ func newTestQLogFileData(t *testing.T, data string) (file *qLogFile) {
 	_, err = f.WriteString(data)
 	require.NoError(t, err)
 
-	file, err = newQLogFile(f.Name())
+	file, err = newQLogFile(slogutil.NewDiscardLogger(), f.Name())
 	require.NoError(t, err)
 	testutil.CleanupAndRequireSuccess(t, file.Close)
 
@@ -3,10 +3,11 @@ package querylog
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 )
 
 // qLogReader allows reading from multiple query log files in the reverse
@@ -25,11 +26,12 @@ type qLogReader struct {
 }
 
 // newQLogReader initializes a qLogReader instance with the specified files.
-func newQLogReader(files []string) (*qLogReader, error) {
+// logger is used for logging the operation of the files.
+func newQLogReader(logger *slog.Logger, files []string) (*qLogReader, error) {
 	qFiles := make([]*qLogFile, 0)
 
 	for _, f := range files {
-		q, err := newQLogFile(f)
+		q, err := newQLogFile(logger, f)
 		if err != nil {
 			if errors.Is(err, os.ErrNotExist) {
 				continue
@@ -38,7 +40,7 @@ func newQLogReader(files []string) (*qLogReader, error) {
 			// Close what we've already opened.
 			cErr := closeQFiles(qFiles)
 			if cErr != nil {
-				log.Debug("querylog: closing files: %s", cErr)
+				logger.Debug("closing files", slogutil.KeyError, cErr)
 			}
 
 			return nil, err
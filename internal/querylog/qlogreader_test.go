@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,7 +19,7 @@ func newTestQLogReader(t *testing.T, filesNum, linesNum int) (reader *qLogReader
 	testFiles := prepareTestFiles(t, filesNum, linesNum)
 
 	// Create the new qLogReader instance.
-	reader, err := newQLogReader(testFiles)
+	reader, err := newQLogReader(slogutil.NewDiscardLogger(), testFiles)
 	require.NoError(t, err)
 
 	assert.NotNil(t, reader)
@@ -2,6 +2,7 @@ package querylog
 
 import (
 	"fmt"
+	"log/slog"
 	"net"
 	"path/filepath"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
+	"github.com/AdguardTeam/AdGuardHome/internal/geoip"
 	"github.com/AdguardTeam/golibs/errors"
 	"github.com/miekg/dns"
 )
@@ -30,12 +32,21 @@ type QueryLog interface {
 
 	// ShouldLog returns true if request for the host should be logged.
 	ShouldLog(host string, qType, qClass uint16, ids []string) bool
+
+	// Replay calls f for each logged request since the time, starting from the
+	// newest one, until f returns false.  The requests for the ignored hosts
+	// are skipped.
+	Replay(since time.Time, f ReplayFunc) (err error)
 }
 
 // Config is the query log configuration structure.
 //
 // Do not alter any fields of this structure after using it.
 type Config struct {
+	// Logger is used for logging the operation of the query log.  If nil,
+	// [slog.Default] is used.
+	Logger *slog.Logger
+
 	// Ignored contains the list of host names, which should not be written to
 	// log, and matches them.
 	Ignored *aghnet.IgnoreEngine
@@ -111,6 +122,10 @@ type AddParams struct {
 
 	// AuthenticatedData shows if the response had the AD bit set.
 	AuthenticatedData bool
+
+	// AnswerGeo is the information about the countries and the autonomous
+	// systems of the addresses in the answer.
+	AnswerGeo []*geoip.IPInfo
 }
 
 // validate returns an error if the parameters aren't valid.
@@ -150,7 +165,13 @@ func newQueryLog(conf Config) (l *queryLog, err error) {
 		memSize = 1
 	}
 
+	logger := conf.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	l = &queryLog{
+		logger:     logger,
 		findClient: findClient,
 
 		buffer: aghalg.NewRingBuffer[*logEntry](memSize),
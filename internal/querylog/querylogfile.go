@@ -2,13 +2,14 @@ package querylog
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 )
 
 // flushLogBuffer flushes the current buffer to file and resets the current
@@ -55,7 +56,14 @@ func (l *queryLog) encodeEntries() (b *bytes.Buffer, err error) {
 	}
 
 	elapsed := time.Since(start)
-	log.Debug("%d elements serialized via json in %v: %d kB, %v/entry, %v/entry", bufLen, elapsed, b.Len()/1024, float64(b.Len())/float64(bufLen), elapsed/time.Duration(bufLen))
+	l.logger.Debug(
+		"serialized elements via json",
+		"count", bufLen,
+		"elapsed", elapsed,
+		"bytes", b.Len(),
+		"bytes_per_entry", float64(b.Len())/float64(bufLen),
+		"elapsed_per_entry", elapsed/time.Duration(bufLen),
+	)
 
 	l.buffer.Clear()
 	l.flushPending = false
@@ -82,7 +90,7 @@ func (l *queryLog) flushToFile(b *bytes.Buffer) (err error) {
 		return fmt.Errorf("writing to file %q: %w", filename, err)
 	}
 
-	log.Debug("querylog: ok %q: %v bytes written", filename, n)
+	l.logger.Debug("flushed to file", "file", filename, "bytes", n)
 
 	return nil
 }
@@ -94,7 +102,7 @@ func (l *queryLog) rotate() error {
 	err := os.Rename(from, to)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			log.Debug("querylog: no log to rotate")
+			l.logger.Debug("no log to rotate")
 
 			return nil
 		}
@@ -102,7 +110,7 @@ func (l *queryLog) rotate() error {
 		return fmt.Errorf("failed to rename old file: %w", err)
 	}
 
-	log.Debug("querylog: renamed %s into %s", from, to)
+	l.logger.Debug("renamed log file", "from", from, "to", to)
 
 	return nil
 }
@@ -129,13 +137,13 @@ func (l *queryLog) readFileFirstTimeValue() (first time.Time, err error) {
 		return time.Time{}, err
 	}
 
-	log.Debug("querylog: the oldest log entry: %s", val)
+	l.logger.Debug("found oldest log entry", "time", val)
 
 	return t, nil
 }
 
 func (l *queryLog) periodicRotate() {
-	defer log.OnPanic("querylog: rotating")
+	defer slogutil.RecoverAndLog(context.TODO(), l.logger)
 
 	l.checkAndRotate()
 
@@ -167,27 +175,23 @@ func (l *queryLog) checkAndRotate() {
 
 	oldest, err := l.readFileFirstTimeValue()
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		log.Error("querylog: reading oldest record for rotation: %s", err)
+		l.logger.Error("reading oldest record for rotation", slogutil.KeyError, err)
 
 		return
 	}
 
 	if rotTime, now := oldest.Add(rotationIvl), time.Now(); rotTime.After(now) {
-		log.Debug(
-			"querylog: %s <= %s, not rotating",
-			now.Format(time.RFC3339),
-			rotTime.Format(time.RFC3339),
-		)
+		l.logger.Debug("not rotating", "now", now, "rotation_time", rotTime)
 
 		return
 	}
 
 	err = l.rotate()
 	if err != nil {
-		log.Error("querylog: rotating: %s", err)
+		l.logger.Error("rotating", slogutil.KeyError, err)
 
 		return
 	}
 
-	log.Debug("querylog: rotated successfully")
+	l.logger.Debug("rotated successfully")
 }
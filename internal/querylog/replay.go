@@ -0,0 +1,130 @@
+package querylog
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+)
+
+// ReplayEntry is a logged request passed to the replay callback.
+type ReplayEntry struct {
+	// Time is the time of the request.
+	Time time.Time
+
+	// ClientIP is the IP address of the client.
+	ClientIP netip.Addr
+
+	// ClientID is the ClientID of the client, if any.
+	ClientID string
+
+	// Host is the requested hostname.
+	Host string
+
+	// QType is the type of the request.
+	QType uint16
+}
+
+// ReplayFunc is called for each replayed entry.  e must not be retained.  The
+// replay stops if cont is false.
+type ReplayFunc func(e *ReplayEntry) (cont bool)
+
+// Replay implements the [QueryLog] interface for *queryLog.
+func (l *queryLog) Replay(since time.Time, f ReplayFunc) (err error) {
+	l.confMu.RLock()
+	defer l.confMu.RUnlock()
+
+	if !l.replayMemory(since, f) {
+		return nil
+	}
+
+	r, err := l.setQLogReader(time.Time{})
+	if err != nil {
+		return fmt.Errorf("querylog: replay: %w", err)
+	} else if r == nil {
+		return nil
+	}
+	defer func() { err = errors.WithDeferred(err, r.Close()) }()
+
+	sinceNano := since.UnixNano()
+	for {
+		var line string
+		line, err = r.ReadNext()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("querylog: replay: reading: %w", err)
+		}
+
+		if readQLogTimestamp(l.logger, line) < sinceNano {
+			return nil
+		}
+
+		e := &logEntry{}
+		decodeLogEntry(l.logger, e, line)
+		if !l.replayEntry(e, f) {
+			return nil
+		}
+	}
+}
+
+// replayMemory replays the entries from the memory buffer since the time.  It
+// returns false if the replay must be stopped.  l.confMu is expected to be
+// locked.
+func (l *queryLog) replayMemory(since time.Time, f ReplayFunc) (cont bool) {
+	if l.conf.MemSize == 0 {
+		return true
+	}
+
+	var entries []*logEntry
+	func() {
+		l.bufferLock.Lock()
+		defer l.bufferLock.Unlock()
+
+		l.buffer.ReverseRange(func(e *logEntry) (ok bool) {
+			if e.Time.Before(since) {
+				return false
+			}
+
+			entries = append(entries, e)
+
+			return true
+		})
+	}()
+
+	for _, e := range entries {
+		if !l.replayEntry(e, f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// replayEntry converts e and calls f with it, unless e is ignored or invalid.
+// l.confMu is expected to be locked.
+func (l *queryLog) replayEntry(e *logEntry, f ReplayFunc) (cont bool) {
+	if e.QHost == "" || l.isIgnored(e.QHost) {
+		return true
+	}
+
+	qtype, ok := dns.StringToType[e.QType]
+	if !ok {
+		l.logger.Debug("replay: bad qtype", "qtype", e.QType, "time", e.Time)
+
+		return true
+	}
+
+	ip, _ := netip.AddrFromSlice(e.IP)
+
+	return f(&ReplayEntry{
+		Time:     e.Time,
+		ClientIP: ip.Unmap(),
+		ClientID: e.ClientID,
+		Host:     e.QHost,
+		QType:    qtype,
+	})
+}
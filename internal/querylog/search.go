@@ -3,11 +3,12 @@ package querylog
 import (
 	"fmt"
 	"io"
+	"log/slog"
 	"slices"
 	"time"
 
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 )
 
 // client finds the client info, if any, by its ClientID and IP address,
@@ -66,9 +67,13 @@ func (l *queryLog) searchMemory(params *searchParams, cache clientCache) (entrie
 		var err error
 		e.client, err = l.client(e.ClientID, e.IP.String(), cache)
 		if err != nil {
-			msg := "querylog: enriching memory record at time %s" +
-				" for client %q (clientid %q): %s"
-			log.Error(msg, e.Time, e.IP, e.ClientID, err)
+			l.logger.Error(
+				"enriching memory record",
+				"time", e.Time,
+				"client", e.IP,
+				"client_id", e.ClientID,
+				slogutil.KeyError, err,
+			)
 
 			// Go on and try to match anyway.
 		}
@@ -96,10 +101,10 @@ func (l *queryLog) search(params *searchParams) (entries []*logEntry, oldest tim
 	cache := clientCache{}
 
 	memoryEntries, bufLen := l.searchMemory(params, cache)
-	log.Debug("querylog: got %d entries from memory", len(memoryEntries))
+	l.logger.Debug("got entries from memory", "count", len(memoryEntries))
 
 	fileEntries, oldest, total := l.searchFiles(params, cache)
-	log.Debug("querylog: got %d entries from files", len(fileEntries))
+	l.logger.Debug("got entries from files", "count", len(fileEntries))
 
 	total += bufLen
 
@@ -134,12 +139,12 @@ func (l *queryLog) search(params *searchParams) (entries []*logEntry, oldest tim
 		oldest = entries[len(entries)-1].Time
 	}
 
-	log.Debug(
-		"querylog: prepared data (%d/%d) older than %s in %s",
-		len(entries),
-		total,
-		params.olderThan,
-		time.Since(start),
+	l.logger.Debug(
+		"prepared data",
+		"count", len(entries),
+		"total", total,
+		"older_than", params.olderThan,
+		"elapsed", time.Since(start),
 	)
 
 	return entries, oldest
@@ -170,7 +175,7 @@ func (l *queryLog) setQLogReader(olderThan time.Time) (qr *qLogReader, err error
 		l.logFile,
 	}
 
-	r, err := newQLogReader(files)
+	r, err := newQLogReader(l.logger, files)
 	if err != nil {
 		return nil, fmt.Errorf("opening qlog reader: %s", err)
 	}
@@ -178,7 +183,7 @@ func (l *queryLog) setQLogReader(olderThan time.Time) (qr *qLogReader, err error
 	err = r.seekRecord(olderThan)
 	if err != nil {
 		defer func() { err = errors.WithDeferred(err, r.Close()) }()
-		log.Debug("querylog: cannot seek to %s: %s", olderThan, err)
+		l.logger.Debug("seeking", "older_than", olderThan, slogutil.KeyError, err)
 
 		return nil, nil
 	}
@@ -205,7 +210,7 @@ func (l *queryLog) readEntries(
 				break
 			}
 
-			log.Error("querylog: reading next entry: %s", rErr)
+			l.logger.Error("reading next entry", slogutil.KeyError, rErr)
 		}
 
 		oldestNano = ts
@@ -236,7 +241,7 @@ func (l *queryLog) searchFiles(
 ) (entries []*logEntry, oldest time.Time, total int) {
 	r, err := l.setQLogReader(params.olderThan)
 	if err != nil {
-		log.Error("querylog: %s", err)
+		l.logger.Error("setting reader", slogutil.KeyError, err)
 	}
 
 	if r == nil {
@@ -245,7 +250,7 @@ func (l *queryLog) searchFiles(
 
 	defer func() {
 		if closeErr := r.Close(); closeErr != nil {
-			log.Error("querylog: closing file: %s", closeErr)
+			l.logger.Error("closing file", slogutil.KeyError, closeErr)
 		}
 	}()
 
@@ -261,6 +266,7 @@ func (l *queryLog) searchFiles(
 // quickMatchClientFinder is a wrapper around the usual client finding function
 // to make it easier to use with quick matches.
 type quickMatchClientFinder struct {
+	logger *slog.Logger
 	client func(clientID, ip string, cache clientCache) (c *Client, err error)
 	cache  clientCache
 }
@@ -270,11 +276,11 @@ func (f quickMatchClientFinder) findClient(clientID, ip string) (c *Client) {
 	var err error
 	c, err = f.client(clientID, ip, f.cache)
 	if err != nil {
-		log.Error(
-			"querylog: enriching file record for quick search: for client %q (clientid %q): %s",
-			ip,
-			clientID,
-			err,
+		f.logger.Error(
+			"enriching file record for quick search",
+			"client", ip,
+			"client_id", clientID,
+			slogutil.KeyError, err,
 		)
 	}
 
@@ -297,18 +303,19 @@ func (l *queryLog) readNextEntry(
 	}
 
 	clientFinder := quickMatchClientFinder{
+		logger: l.logger,
 		client: l.client,
 		cache:  cache,
 	}
 
 	if !params.quickMatch(line, clientFinder.findClient) {
-		ts = readQLogTimestamp(line)
+		ts = readQLogTimestamp(l.logger, line)
 
 		return nil, ts, nil
 	}
 
 	e = &logEntry{}
-	decodeLogEntry(e, line)
+	decodeLogEntry(l.logger, e, line)
 
 	if l.isIgnored(e.QHost) {
 		return nil, ts, nil
@@ -316,12 +323,12 @@ func (l *queryLog) readNextEntry(
 
 	e.client, err = l.client(e.ClientID, e.IP.String(), cache)
 	if err != nil {
-		log.Error(
-			"querylog: enriching file record at time %s for client %q (clientid %q): %s",
-			e.Time,
-			e.IP,
-			e.ClientID,
-			err,
+		l.logger.Error(
+			"enriching file record",
+			"time", e.Time,
+			"client", e.IP,
+			"client_id", e.ClientID,
+			slogutil.KeyError, err,
 		)
 
 		// Go on and try to match anyway.
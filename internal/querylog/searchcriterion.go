@@ -173,9 +173,16 @@ func (c *searchCriterion) ctFilteringStatusCase(
 			filtering.RewrittenRule,
 		)
 	case filteringStatusProcessed:
+		if reason == filtering.FilteredAnomaly {
+			// The flagged requests are processed as usual.
+			return !isFiltered
+		}
+
 		return !reason.In(
 			filtering.FilteredBlockList,
 			filtering.FilteredBlockedService,
+			filtering.FilteredBypass,
+			filtering.FilteredGeoIP,
 			filtering.NotFilteredAllowList,
 		)
 	default:
@@ -194,7 +201,13 @@ func (c *searchCriterion) ctFilteringStatusCase(
 func (c *searchCriterion) isFilteredWithReason(reason filtering.Reason) (matched bool) {
 	switch c.value {
 	case filteringStatusBlocked:
-		return reason.In(filtering.FilteredBlockList, filtering.FilteredBlockedService)
+		return reason.In(
+			filtering.FilteredBlockList,
+			filtering.FilteredBlockedService,
+			filtering.FilteredBypass,
+			filtering.FilteredGeoIP,
+			filtering.FilteredAnomaly,
+		)
 	case filteringStatusBlockedParental:
 		return reason == filtering.FilteredParental
 	case filteringStatusBlockedSafebrowsing:
@@ -0,0 +1,131 @@
+package recursor
+
+import (
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxInfraItems is the maximum number of the zones and of the nameserver names
+// cached each.
+const maxInfraItems = 10_000
+
+// maxInfraTTL is the maximum duration, for which the delegations and the
+// addresses are cached.
+const maxInfraTTL = 24 * time.Hour
+
+// infraItem is a cached set of addresses.
+type infraItem struct {
+	// expire is the time, after which the item isn't used.
+	expire time.Time
+
+	// addrs are the cached addresses.
+	addrs []netip.Addr
+}
+
+// infraCache is the infrastructure cache, which contains the addresses of the
+// nameservers of the zones and the addresses of the nameserver names.
+type infraCache struct {
+	// mu protects zones and hosts.
+	mu *sync.Mutex
+
+	// zones are the addresses of the nameservers by the lowercased FQDN of the
+	// zone.
+	zones map[string]*infraItem
+
+	// hosts are the addresses by the lowercased FQDN of a nameserver.
+	hosts map[string]*infraItem
+
+	// now returns the current time.  It's replaced in tests.
+	now func() (t time.Time)
+}
+
+// newInfraCache returns a new empty *infraCache.
+func newInfraCache() (c *infraCache) {
+	return &infraCache{
+		mu:    &sync.Mutex{},
+		zones: map[string]*infraItem{},
+		hosts: map[string]*infraItem{},
+		now:   time.Now,
+	}
+}
+
+// closestZone returns the closest enclosing zone of name with cached
+// nameserver addresses.  ok is false if there is none.
+func (c *infraCache) closestZone(name string) (zone string, addrs []netip.Addr, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		zone = name[off:]
+		if item, found := c.zones[zone]; found && now.Before(item.expire) {
+			return zone, item.addrs, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// setZone caches the addresses of the nameservers of zone for ttl.
+func (c *infraCache) setZone(zone string, addrs []netip.Addr, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(c.zones, zone, addrs, ttl)
+}
+
+// host returns the cached addresses of the nameserver name.
+func (c *infraCache) host(name string) (addrs []netip.Addr, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.hosts[name]
+	if !ok || !c.now().Before(item.expire) {
+		return nil, false
+	}
+
+	return item.addrs, true
+}
+
+// setHost caches the addresses of the nameserver name for ttl.
+func (c *infraCache) setHost(name string, addrs []netip.Addr, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(c.hosts, name, addrs, ttl)
+}
+
+// set puts addrs into m for ttl, removing the expired items if m is full.  If
+// it's still full, addrs aren't cached.  c.mu is expected to be locked.
+func (c *infraCache) set(
+	m map[string]*infraItem,
+	k string,
+	addrs []netip.Addr,
+	ttl time.Duration,
+) {
+	if ttl <= 0 || len(addrs) == 0 {
+		return
+	}
+
+	now := c.now()
+	if _, ok := m[k]; !ok && len(m) >= maxInfraItems {
+		for key, item := range m {
+			if !now.Before(item.expire) {
+				delete(m, key)
+			}
+		}
+
+		if len(m) >= maxInfraItems {
+			return
+		}
+	}
+
+	m[k] = &infraItem{
+		expire: now.Add(min(ttl, maxInfraTTL)),
+		addrs:  slices.Clone(addrs),
+	}
+}
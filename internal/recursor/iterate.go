@@ -0,0 +1,446 @@
+package recursor
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+)
+
+// Limits of a single resolution.
+const (
+	// maxQueries is the maximum number of the queries sent to the
+	// authoritative servers, including the ones for the nameserver addresses.
+	maxQueries = 64
+
+	// maxReferrals is the maximum number of the steps of a single iteration.
+	maxReferrals = 32
+
+	// maxCNAMEs is the maximum length of a CNAME chain.
+	maxCNAMEs = 8
+
+	// maxDepth is the maximum nesting of the resolutions of the nameserver
+	// addresses.
+	maxDepth = 3
+
+	// maxNSNames is the maximum number of the nameserver names resolved for a
+	// delegation without the glue.
+	maxNSNames = 3
+
+	// maxServerTries is the maximum number of the servers of a zone tried for
+	// a single query.
+	maxServerTries = 4
+)
+
+// udpSize is the EDNS UDP payload size advertised to the authoritative
+// servers.
+const udpSize = 1232
+
+// resolution is the state of the resolution of a single request.
+type resolution struct {
+	// resolver is the resolver performing the resolution.
+	resolver *Resolver
+
+	// queries is the number of the queries sent so far.
+	queries int
+
+	// depth is the current nesting of the resolutions of the nameserver
+	// addresses.
+	depth int
+
+	// dnssecOK is true if the DO bit is set in the request.
+	dnssecOK bool
+}
+
+// resolve resolves name of type qtype following the CNAME chains.  The records
+// of the chain are prepended to the answer section of the final response.  Only
+// the answers within the zone of the responding servers and owned by the names
+// within the chain are kept, since the other ones are outside of the bailiwick
+// of the servers.
+func (res *resolution) resolve(
+	ctx context.Context,
+	name string,
+	qtype uint16,
+) (resp *dns.Msg, err error) {
+	name = dns.CanonicalName(name)
+
+	var chain []dns.RR
+	for range maxCNAMEs {
+		var zone string
+		resp, zone, err = res.iterate(ctx, name, qtype)
+		if err != nil {
+			// Don't wrap the error, since it's informative enough as is.
+			return nil, err
+		}
+
+		answer := inBailiwick(resp.Answer, zone)
+		names, complete := followCNAMEs(answer, name, qtype)
+		chain = append(chain, ownedBy(answer, names)...)
+
+		end := names[len(names)-1]
+		if complete || end == name || resp.Rcode != dns.RcodeSuccess {
+			resp.Answer = chain
+
+			return resp, nil
+		}
+
+		name = end
+	}
+
+	return nil, errors.Error("cname chain too long")
+}
+
+// followCNAMEs follows the CNAME chain starting at name within rrs.  names are
+// the names of the chain starting with name, so the last one is the end of the
+// chain.  complete is true if rrs contain the records of qtype for the end.
+func followCNAMEs(rrs []dns.RR, name string, qtype uint16) (names []string, complete bool) {
+	names = []string{name}
+	end := name
+	for range maxCNAMEs {
+		next := ""
+		for _, rr := range rrs {
+			hdr := rr.Header()
+			if !strings.EqualFold(hdr.Name, end) {
+				continue
+			}
+
+			if hdr.Rrtype == qtype {
+				return names, true
+			} else if cname, ok := rr.(*dns.CNAME); ok {
+				next = dns.CanonicalName(cname.Target)
+			}
+		}
+
+		if next == "" || slices.Contains(names, next) {
+			break
+		}
+
+		end = next
+		names = append(names, end)
+	}
+
+	return names, false
+}
+
+// inBailiwick returns the records from rrs owned by the names within zone.
+func inBailiwick(rrs []dns.RR, zone string) (filtered []dns.RR) {
+	for _, rr := range rrs {
+		if dns.IsSubDomain(zone, dns.CanonicalName(rr.Header().Name)) {
+			filtered = append(filtered, rr)
+		} else {
+			log.Debug("recursor: dropping record %s outside of zone %s", rr, zone)
+		}
+	}
+
+	return filtered
+}
+
+// ownedBy returns the records from rrs owned by any of names.
+func ownedBy(rrs []dns.RR, names []string) (owned []dns.RR) {
+	for _, rr := range rrs {
+		owner := dns.CanonicalName(rr.Header().Name)
+		if slices.Contains(names, owner) {
+			owned = append(owned, rr)
+		} else {
+			log.Debug("recursor: dropping record %s outside of cname chain", rr)
+		}
+	}
+
+	return owned
+}
+
+// iterate resolves name of type qtype by following the delegations from the
+// closest known zone.  zone is the zone of the servers, which responded with
+// resp.  The CNAME records aren't followed.
+func (res *resolution) iterate(
+	ctx context.Context,
+	name string,
+	qtype uint16,
+) (resp *dns.Msg, zone string, err error) {
+	r := res.resolver
+	zone, servers, ok := r.infra.closestZone(name)
+	if !ok {
+		zone, servers = ".", r.rootHints
+	}
+
+	qmin := r.qmin
+	labels := dns.CountLabel(zone) + 1
+	for range maxReferrals {
+		qname, qt := name, qtype
+		minimised := qmin && labels < dns.CountLabel(name)
+		if minimised {
+			// Use A instead of NS, as RFC 9156, section 2.3 recommends, since
+			// some servers respond incorrectly to the NS queries for the names
+			// that aren't zone cuts.
+			qname, qt = lastLabels(name, labels), dns.TypeA
+		}
+
+		resp, err = res.exchange(ctx, servers, qname, qt)
+		if err != nil {
+			if minimised {
+				// Some servers fail on the minimised queries, so retry with the
+				// full name, see RFC 9156, section 3.
+				qmin = false
+
+				continue
+			}
+
+			return nil, "", fmt.Errorf("zone %s: %w", zone, err)
+		}
+
+		child, nsNames := referral(resp, zone, qname)
+		if child != "" {
+			servers, err = res.delegate(ctx, resp, zone, child, nsNames)
+			if err != nil {
+				return nil, "", fmt.Errorf("zone %s: %w", child, err)
+			}
+
+			zone, labels = child, dns.CountLabel(child)+1
+
+			continue
+		}
+
+		if !minimised {
+			return resp, zone, nil
+		}
+
+		if resp.Rcode == dns.RcodeSuccess {
+			// There is no zone cut at qname, so reveal one more label.
+			labels++
+		} else {
+			// Don't rely on the NXDOMAIN responses for the minimised names,
+			// since some servers return them for the empty non-terminals.
+			qmin = false
+		}
+	}
+
+	return nil, "", errors.Error("too many referrals")
+}
+
+// lastLabels returns the part of name containing n last labels.
+func lastLabels(name string, n int) (sub string) {
+	idx := dns.Split(name)
+
+	return name[idx[len(idx)-n]:]
+}
+
+// referral returns the child zone and the names of its nameservers, if resp is
+// a referral from zone for qname.  child is empty otherwise.
+func referral(resp *dns.Msg, zone, qname string) (child string, nsNames []string) {
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 {
+		return "", nil
+	}
+
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		owner := dns.CanonicalName(ns.Hdr.Name)
+		if owner == zone || !dns.IsSubDomain(zone, owner) || !dns.IsSubDomain(owner, qname) {
+			// Only the delegations down towards qname are followed.
+			continue
+		} else if child == "" {
+			child = owner
+		} else if owner != child {
+			continue
+		}
+
+		nsNames = append(nsNames, dns.CanonicalName(ns.Ns))
+	}
+
+	return child, nsNames
+}
+
+// delegate returns the addresses of the nameservers of child delegated from
+// zone by the referral resp and caches them.  The glue records are only
+// accepted within zone.  The addresses of the nameservers without glue are
+// resolved.
+func (res *resolution) delegate(
+	ctx context.Context,
+	resp *dns.Msg,
+	zone string,
+	child string,
+	nsNames []string,
+) (addrs []netip.Addr, err error) {
+	r := res.resolver
+	ttl := nsTTL(resp.Ns, child)
+	for _, name := range nsNames {
+		if !dns.IsSubDomain(zone, name) {
+			continue
+		}
+
+		glue := addrsOf(resp.Extra, name)
+		addrs = append(addrs, glue...)
+		r.infra.setHost(name, glue, ttl)
+	}
+
+	for i := 0; len(addrs) == 0 && i < len(nsNames) && i < maxNSNames; i++ {
+		addrs, err = res.nsAddrs(ctx, nsNames[i])
+		if err != nil {
+			log.Debug("recursor: resolving nameserver %s of %s: %s", nsNames[i], child, err)
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, errNoServers
+	}
+
+	r.infra.setZone(child, addrs, ttl)
+
+	return addrs, nil
+}
+
+// nsAddrs returns the addresses of the nameserver name, resolving them if they
+// aren't cached.
+func (res *resolution) nsAddrs(
+	ctx context.Context,
+	name string,
+) (addrs []netip.Addr, err error) {
+	r := res.resolver
+	if cached, ok := r.infra.host(name); ok {
+		return cached, nil
+	}
+
+	if res.depth >= maxDepth {
+		return nil, errors.Error("nameserver resolution too deep")
+	}
+
+	res.depth++
+	defer func() { res.depth-- }()
+
+	resp, err := res.resolve(ctx, name, dns.TypeA)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	names, _ := followCNAMEs(resp.Answer, name, dns.TypeA)
+	addrs = addrsOf(resp.Answer, names[len(names)-1])
+	r.infra.setHost(name, addrs, minTTL(resp.Answer))
+
+	return addrs, nil
+}
+
+// exchange sends the query for qname of type qtype to servers until one of them
+// responds with a meaningful response.
+func (res *resolution) exchange(
+	ctx context.Context,
+	servers []netip.Addr,
+	qname string,
+	qtype uint16,
+) (resp *dns.Msg, err error) {
+	req := (&dns.Msg{}).SetQuestion(qname, qtype)
+	req.RecursionDesired = false
+	req.SetEdns0(udpSize, res.dnssecOK)
+
+	var errs []error
+	for i, addr := range serverOrder(servers) {
+		if i >= maxServerTries {
+			break
+		} else if res.queries >= maxQueries {
+			return nil, errors.Error("too many queries")
+		}
+
+		res.queries++
+
+		resp, err = res.resolver.exchanger.Exchange(ctx, req, netip.AddrPortFrom(addr, 53))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", addr, err))
+
+			continue
+		}
+
+		switch resp.Rcode {
+		case dns.RcodeServerFailure, dns.RcodeRefused, dns.RcodeNotImplemented:
+			errs = append(errs, fmt.Errorf("%s: %s", addr, dns.RcodeToString[resp.Rcode]))
+		default:
+			return resp, nil
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil, errNoServers
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// serverOrder returns servers shuffled to spread the load, with the IPv4
+// addresses first.
+func serverOrder(servers []netip.Addr) (ordered []netip.Addr) {
+	ordered = slices.Clone(servers)
+	rand.Shuffle(len(ordered), func(i, j int) {
+		ordered[i], ordered[j] = ordered[j], ordered[i]
+	})
+
+	slices.SortStableFunc(ordered, func(a, b netip.Addr) (res int) {
+		switch {
+		case a.Is4() == b.Is4():
+			return 0
+		case a.Is4():
+			return -1
+		default:
+			return 1
+		}
+	})
+
+	return ordered
+}
+
+// addrsOf returns the addresses from the A and AAAA records of name in rrs.
+func addrsOf(rrs []dns.RR, name string) (addrs []netip.Addr) {
+	for _, rr := range rrs {
+		if !strings.EqualFold(rr.Header().Name, name) {
+			continue
+		}
+
+		var ip netip.Addr
+		switch rr := rr.(type) {
+		case *dns.A:
+			ip, _ = netip.AddrFromSlice(rr.A.To4())
+		case *dns.AAAA:
+			ip, _ = netip.AddrFromSlice(rr.AAAA)
+		default:
+			continue
+		}
+
+		if ip.IsValid() {
+			addrs = append(addrs, ip)
+		}
+	}
+
+	return addrs
+}
+
+// nsTTL returns the TTL of the NS records of zone in rrs.
+func nsTTL(rrs []dns.RR, zone string) (ttl time.Duration) {
+	var nsRRs []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeNS && strings.EqualFold(rr.Header().Name, zone) {
+			nsRRs = append(nsRRs, rr)
+		}
+	}
+
+	return minTTL(nsRRs)
+}
+
+// minTTL returns the minimum TTL of rrs or zero if rrs are empty.
+func minTTL(rrs []dns.RR) (ttl time.Duration) {
+	for i, rr := range rrs {
+		rrTTL := time.Duration(rr.Header().Ttl) * time.Second
+		if i == 0 || rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+
+	return ttl
+}
@@ -0,0 +1,160 @@
+// Package recursor implements the full recursive resolution of the DNS
+// requests, starting from the root servers and following the delegations down
+// to the authoritative servers.
+package recursor
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+)
+
+// Exchanger sends the queries to the authoritative servers.
+type Exchanger interface {
+	// Exchange sends req to the server at addr and returns the response.  It
+	// must be safe for concurrent use.
+	Exchange(ctx context.Context, req *dns.Msg, addr netip.AddrPort) (resp *dns.Msg, err error)
+}
+
+// DefaultTimeout is the default timeout of the resolution of a single request.
+const DefaultTimeout = 10 * time.Second
+
+// queryTimeout is the timeout of a single query to an authoritative server.
+const queryTimeout = 2 * time.Second
+
+// Config is the configuration of a *Resolver.
+type Config struct {
+	// Exchanger sends the queries to the authoritative servers.  If nil, the
+	// queries are sent over UDP and retried over TCP if the responses are
+	// truncated.
+	Exchanger Exchanger
+
+	// RootHints are the addresses of the root servers.  If empty, the
+	// addresses of the IANA root servers are used.
+	RootHints []netip.Addr
+
+	// Timeout is the timeout of the resolution of a single request.  If zero,
+	// [DefaultTimeout] is used.
+	Timeout time.Duration
+
+	// QNAMEMinimisation defines if the names sent to the authoritative servers
+	// are minimised to reveal only a single label beyond the zone cut, see RFC
+	// 9156.  The minimised queries have the type A.
+	QNAMEMinimisation bool
+}
+
+// Resolver resolves the requests iteratively, starting from the root servers.
+// The delegations and the addresses of the nameservers are cached between the
+// requests.  The responses are never marked as authenticated, since the DNSSEC
+// signatures aren't validated, but the signatures are returned if the client
+// has requested them.
+type Resolver struct {
+	exchanger Exchanger
+	infra     *infraCache
+	rootHints []netip.Addr
+	timeout   time.Duration
+	qmin      bool
+}
+
+// New returns a new properly initialized *Resolver.  conf must not be nil.
+func New(conf *Config) (r *Resolver) {
+	r = &Resolver{
+		exchanger: conf.Exchanger,
+		infra:     newInfraCache(),
+		rootHints: conf.RootHints,
+		timeout:   conf.Timeout,
+		qmin:      conf.QNAMEMinimisation,
+	}
+
+	if r.exchanger == nil {
+		r.exchanger = &netExchanger{}
+	}
+
+	if len(r.rootHints) == 0 {
+		r.rootHints = ianaRootServers
+	}
+
+	if r.timeout == 0 {
+		r.timeout = DefaultTimeout
+	}
+
+	return r
+}
+
+// Resolve resolves req, which must have a single question, and returns the
+// response to it.  The DO bit of req is passed to the authoritative servers,
+// but the DNSSEC signatures in their responses aren't validated, so the
+// responses are never more trustworthy than the ones of the plain upstreams.
+// It's safe for concurrent use.
+func (r *Resolver) Resolve(ctx context.Context, req *dns.Msg) (resp *dns.Msg, err error) {
+	if len(req.Question) != 1 {
+		return nil, fmt.Errorf("recursor: bad number of questions: %d", len(req.Question))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	opt := req.IsEdns0()
+	res := &resolution{
+		resolver: r,
+		dnssecOK: opt != nil && opt.Do(),
+	}
+
+	q := req.Question[0]
+	final, err := res.resolve(ctx, q.Name, q.Qtype)
+	if err != nil {
+		return nil, fmt.Errorf("recursor: resolving %s %s: %w", q.Name, dns.Type(q.Qtype), err)
+	}
+
+	resp = (&dns.Msg{}).SetRcode(req, final.Rcode)
+	resp.RecursionAvailable = true
+	resp.Answer = final.Answer
+	resp.Ns = final.Ns
+	if opt != nil {
+		resp.SetEdns0(opt.UDPSize(), opt.Do())
+	}
+
+	return resp, nil
+}
+
+// netExchanger is the default [Exchanger], which sends the queries over the
+// network.
+type netExchanger struct{}
+
+// type check
+var _ Exchanger = (*netExchanger)(nil)
+
+// Exchange implements the [Exchanger] interface for *netExchanger.
+func (e *netExchanger) Exchange(
+	ctx context.Context,
+	req *dns.Msg,
+	addr netip.AddrPort,
+) (resp *dns.Msg, err error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	c := &dns.Client{Net: "udp"}
+	resp, _, err = c.ExchangeContext(ctx, req, addr.String())
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	} else if !resp.Truncated {
+		return resp, nil
+	}
+
+	c.Net = "tcp"
+	resp, _, err = c.ExchangeContext(ctx, req, addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("retrying over tcp: %w", err)
+	}
+
+	return resp, nil
+}
+
+// errNoServers is returned when the addresses of the nameservers of a zone
+// can't be found.
+const errNoServers errors.Error = "no nameserver addresses"
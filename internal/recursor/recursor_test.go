@@ -0,0 +1,361 @@
+package recursor_test
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/recursor"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Addresses of the servers of the fake hierarchy.
+var (
+	rootAddr     = netip.MustParseAddr("192.0.2.1")
+	comAddr      = netip.MustParseAddr("192.0.2.2")
+	exampleAddr  = netip.MustParseAddr("192.0.2.3")
+	gluelessAddr = netip.MustParseAddr("192.0.2.4")
+)
+
+// fakeZone is an authoritative server of a single zone.
+type fakeZone struct {
+	// delegations are the NS and glue records of the child zones by their
+	// names.
+	delegations map[string][]dns.RR
+
+	// origin is the name of the zone.
+	origin string
+
+	// records are the records of the zone.
+	records []dns.RR
+
+	// injected are the records added to each non-empty answer to check that
+	// the records outside of the bailiwick are dropped.
+	injected []dns.RR
+}
+
+// newRRs parses the records from the presentation format.
+func newRRs(t testing.TB, lines ...string) (rrs []dns.RR) {
+	t.Helper()
+
+	for _, l := range lines {
+		rr, err := dns.NewRR(l)
+		require.NoError(t, err)
+
+		rrs = append(rrs, rr)
+	}
+
+	return rrs
+}
+
+// respond returns the response of z to req.
+func (z *fakeZone) respond(t testing.TB, req *dns.Msg) (resp *dns.Msg) {
+	q := req.Question[0]
+	name := dns.CanonicalName(q.Name)
+	resp = (&dns.Msg{}).SetReply(req)
+
+	for child, rrs := range z.delegations {
+		if !dns.IsSubDomain(child, name) {
+			continue
+		}
+
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeNS {
+				resp.Ns = append(resp.Ns, rr)
+			} else {
+				resp.Extra = append(resp.Extra, rr)
+			}
+		}
+
+		return resp
+	}
+
+	resp.Authoritative = true
+	exists := false
+	for _, rr := range z.records {
+		owner := rr.Header().Name
+		if owner != name {
+			exists = exists || dns.IsSubDomain(name, owner)
+
+			continue
+		}
+
+		exists = true
+		if rrType := rr.Header().Rrtype; rrType == q.Qtype || rrType == dns.TypeCNAME {
+			resp.Answer = append(resp.Answer, rr)
+		}
+	}
+
+	if len(resp.Answer) > 0 {
+		resp.Answer = append(resp.Answer, z.injected...)
+
+		return resp
+	}
+
+	if !exists {
+		resp.Rcode = dns.RcodeNameError
+	}
+
+	resp.Ns = newRRs(t, z.origin+" 300 IN SOA ns."+z.origin+" admin."+z.origin+" 1 2 3 4 60")
+
+	return resp
+}
+
+// fakeExchanger is a [recursor.Exchanger] routing the queries to the fake
+// zones by the server address.
+type fakeExchanger struct {
+	t     testing.TB
+	zones map[netip.Addr]*fakeZone
+
+	// mu protects queries.
+	mu *sync.Mutex
+
+	// queries are the questions received by each server, in the
+	// "name type do" format.
+	queries map[netip.Addr][]string
+}
+
+// type check
+var _ recursor.Exchanger = (*fakeExchanger)(nil)
+
+// Exchange implements the [recursor.Exchanger] interface for *fakeExchanger.
+func (e *fakeExchanger) Exchange(
+	_ context.Context,
+	req *dns.Msg,
+	addr netip.AddrPort,
+) (resp *dns.Msg, err error) {
+	require.Equal(e.t, uint16(53), addr.Port())
+	require.False(e.t, req.RecursionDesired)
+
+	z, ok := e.zones[addr.Addr()]
+	if !ok {
+		return nil, errors.Error("no route to host")
+	}
+
+	q := req.Question[0]
+	do := "-"
+	if opt := req.IsEdns0(); opt != nil && opt.Do() {
+		do = "do"
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.queries[addr.Addr()] = append(
+		e.queries[addr.Addr()],
+		strings.Join([]string{q.Name, dns.Type(q.Qtype).String(), do}, " "),
+	)
+
+	return z.respond(e.t, req), nil
+}
+
+// newFakeExchanger returns a new *fakeExchanger serving the fake hierarchy:
+// the root zone, the com zone, the example.com zone, and the glueless.com zone,
+// the nameserver of which has no glue.
+func newFakeExchanger(t testing.TB) (e *fakeExchanger) {
+	t.Helper()
+
+	return &fakeExchanger{
+		t: t,
+		zones: map[netip.Addr]*fakeZone{
+			rootAddr: {
+				origin: ".",
+				delegations: map[string][]dns.RR{
+					"com.": newRRs(
+						t,
+						"com. 3600 IN NS ns.nic.com.",
+						"ns.nic.com. 3600 IN A "+comAddr.String(),
+					),
+				},
+			},
+			comAddr: {
+				origin: "com.",
+				delegations: map[string][]dns.RR{
+					"example.com.": newRRs(
+						t,
+						"example.com. 3600 IN NS ns.example.com.",
+						"ns.example.com. 3600 IN A "+exampleAddr.String(),
+					),
+					"glueless.com.": newRRs(t, "glueless.com. 3600 IN NS ns2.example.com."),
+				},
+			},
+			exampleAddr: {
+				origin: "example.com.",
+				records: newRRs(
+					t,
+					"ns.example.com. 3600 IN A "+exampleAddr.String(),
+					"ns2.example.com. 3600 IN A "+gluelessAddr.String(),
+					"www.example.com. 300 IN A 203.0.113.1",
+					"alias.example.com. 300 IN CNAME www.example.com.",
+					"far.example.com. 300 IN CNAME host.glueless.com.",
+					"a.b.c.example.com. 300 IN A 203.0.113.3",
+				),
+				injected: newRRs(
+					t,
+					"host.glueless.com. 300 IN A 198.51.100.1",
+					"other.example.com. 300 IN A 198.51.100.2",
+				),
+			},
+			gluelessAddr: {
+				origin:  "glueless.com.",
+				records: newRRs(t, "host.glueless.com. 300 IN A 203.0.113.2"),
+			},
+		},
+		mu:      &sync.Mutex{},
+		queries: map[netip.Addr][]string{},
+	}
+}
+
+// newTestResolver returns a new *recursor.Resolver using e and the fake root
+// server.
+func newTestResolver(e *fakeExchanger, qmin bool) (r *recursor.Resolver) {
+	return recursor.New(&recursor.Config{
+		Exchanger:         e,
+		RootHints:         []netip.Addr{rootAddr},
+		QNAMEMinimisation: qmin,
+	})
+}
+
+// answerStrings returns the data of the records in rrs.
+func answerStrings(rrs []dns.RR) (data []string) {
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		data = append(data, strings.TrimPrefix(rr.String(), hdr.String()))
+	}
+
+	return data
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	testCases := []struct {
+		name       string
+		host       string
+		wantAnswer []string
+		qtype      uint16
+		wantRcode  int
+	}{{
+		name:       "answer",
+		host:       "www.example.com",
+		wantAnswer: []string{"203.0.113.1"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		name:       "nodata",
+		host:       "www.example.com",
+		wantAnswer: nil,
+		qtype:      dns.TypeAAAA,
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		name:       "nxdomain",
+		host:       "none.example.com",
+		wantAnswer: nil,
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeNameError,
+	}, {
+		name:       "cname",
+		host:       "alias.example.com",
+		wantAnswer: []string{"www.example.com.", "203.0.113.1"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		name:       "glueless",
+		host:       "host.glueless.com",
+		wantAnswer: []string{"203.0.113.2"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		name:       "cname_other_zone",
+		host:       "far.example.com",
+		wantAnswer: []string{"host.glueless.com.", "203.0.113.2"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+	}, {
+		name:       "empty_non_terminal",
+		host:       "a.b.c.example.com",
+		wantAnswer: []string{"203.0.113.3"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+	}}
+
+	for _, qmin := range []bool{false, true} {
+		r := newTestResolver(newFakeExchanger(t), qmin)
+
+		for _, tc := range testCases {
+			name := tc.name
+			if qmin {
+				name += "_qmin"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				req := (&dns.Msg{}).SetQuestion(dns.Fqdn(tc.host), tc.qtype)
+
+				resp, err := r.Resolve(context.Background(), req)
+				require.NoError(t, err)
+
+				assert.Equal(t, req.Id, resp.Id)
+				assert.Equal(t, tc.wantRcode, resp.Rcode)
+				assert.True(t, resp.RecursionAvailable)
+				assert.False(t, resp.Authoritative)
+				assert.False(t, resp.AuthenticatedData)
+				assert.Equal(t, tc.wantAnswer, answerStrings(resp.Answer))
+			})
+		}
+	}
+}
+
+func TestResolver_Resolve_qnameMinimisation(t *testing.T) {
+	e := newFakeExchanger(t)
+	r := newTestResolver(e, true)
+
+	req := (&dns.Msg{}).SetQuestion("a.b.c.example.com.", dns.TypeAAAA)
+	req.SetEdns0(dns.DefaultMsgSize, true)
+
+	resp, err := r.Resolve(context.Background(), req)
+	require.NoError(t, err)
+
+	opt := resp.IsEdns0()
+	require.NotNil(t, opt)
+
+	assert.True(t, opt.Do())
+	assert.Equal(t, map[netip.Addr][]string{
+		rootAddr: {"com. A do"},
+		comAddr:  {"example.com. A do"},
+		exampleAddr: {
+			"c.example.com. A do",
+			"b.c.example.com. A do",
+			"a.b.c.example.com. AAAA do",
+		},
+	}, e.queries)
+}
+
+func TestResolver_Resolve_infraCache(t *testing.T) {
+	e := newFakeExchanger(t)
+	r := newTestResolver(e, false)
+
+	for _, host := range []string{"www.example.com.", "alias.example.com."} {
+		req := (&dns.Msg{}).SetQuestion(host, dns.TypeA)
+		_, err := r.Resolve(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	assert.Len(t, e.queries[rootAddr], 1)
+	assert.Len(t, e.queries[comAddr], 1)
+}
+
+func TestResolver_Resolve_error(t *testing.T) {
+	e := newFakeExchanger(t)
+	r := recursor.New(&recursor.Config{
+		Exchanger: e,
+		RootHints: []netip.Addr{netip.MustParseAddr("192.0.2.255")},
+	})
+
+	req := (&dns.Msg{}).SetQuestion("www.example.com.", dns.TypeA)
+	_, err := r.Resolve(context.Background(), req)
+
+	assert.ErrorContains(t, err, "192.0.2.255: no route to host")
+}
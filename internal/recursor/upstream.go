@@ -0,0 +1,51 @@
+package recursor
+
+import (
+	"context"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/miekg/dns"
+)
+
+// UpstreamAddr is the address of the upstream resolving the requests
+// recursively in the upstream configuration.
+const UpstreamAddr = "recursive"
+
+// Upstream is an [upstream.Upstream] resolving the requests with a *Resolver.
+// Many of them may share the same *Resolver and so its infrastructure cache.
+type Upstream struct {
+	resolver *Resolver
+}
+
+// NewUpstream returns a new *Upstream using r.  If r is nil, the upstream
+// responds to all requests with an error, which is useful for validating the
+// configuration.
+func NewUpstream(r *Resolver) (u *Upstream) {
+	return &Upstream{
+		resolver: r,
+	}
+}
+
+// type check
+var _ upstream.Upstream = (*Upstream)(nil)
+
+// Exchange implements the [upstream.Upstream] interface for *Upstream.
+func (u *Upstream) Exchange(req *dns.Msg) (resp *dns.Msg, err error) {
+	if u.resolver == nil {
+		return nil, errors.Error("recursor: recursive resolution isn't available")
+	}
+
+	return u.resolver.Resolve(context.Background(), req)
+}
+
+// Address implements the [upstream.Upstream] interface for *Upstream.
+func (u *Upstream) Address() (addr string) {
+	return UpstreamAddr
+}
+
+// Close implements the [upstream.Upstream] interface for *Upstream.  It does
+// nothing, since the *Resolver may be shared.
+func (u *Upstream) Close() (err error) {
+	return nil
+}
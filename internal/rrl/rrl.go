@@ -0,0 +1,383 @@
+// Package rrl implements the response rate limiting (RRL), which mitigates the
+// use of the DNS server in the reflection and amplification attacks.  The
+// responses are grouped into classes by the network of the client, the
+// requested name, and the kind of the response, and each class has its own
+// token bucket.
+//
+// See https://kb.isc.org/docs/aa-00994.
+package rrl
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/timeutil"
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Default values of the configuration.
+const (
+	DefaultResponsesPerSecond uint = 5
+	DefaultWindow                  = 15 * time.Second
+	DefaultSlip               uint = 2
+	DefaultIPv4PrefixLen      int  = 24
+	DefaultIPv6PrefixLen      int  = 56
+)
+
+// Config is the configuration of the response rate limiting.
+type Config struct {
+	// ResponsesPerSecond is the number of the identical responses with answers
+	// and the empty responses sent to a single network per second.
+	ResponsesPerSecond uint `yaml:"responses_per_second"`
+
+	// NXDOMAINsPerSecond is the number of the NXDOMAIN responses for a single
+	// registrable domain sent to a single network per second.  Zero means
+	// ResponsesPerSecond.
+	NXDOMAINsPerSecond uint `yaml:"nxdomains_per_second"`
+
+	// ErrorsPerSecond is the number of the error responses sent to a single
+	// network per second.  Zero means ResponsesPerSecond.
+	ErrorsPerSecond uint `yaml:"errors_per_second"`
+
+	// Window is the duration, over which the excess of responses is
+	// remembered.  It must be at least one second.
+	Window timeutil.Duration `yaml:"window"`
+
+	// Slip defines how many of the limited responses are truncated instead of
+	// being dropped: each Slip-th one.  The truncated responses make the
+	// legitimate clients retry over TCP.  Zero means that all of the limited
+	// responses are dropped.
+	Slip uint `yaml:"slip"`
+
+	// IPv4PrefixLen is the length of the network prefix, by which the IPv4
+	// clients are grouped.
+	IPv4PrefixLen int `yaml:"ipv4_prefix_len"`
+
+	// IPv6PrefixLen is the length of the network prefix, by which the IPv6
+	// clients are grouped.
+	IPv6PrefixLen int `yaml:"ipv6_prefix_len"`
+
+	// LogOnly defines if the limited responses are only logged and counted,
+	// but still sent as usual.
+	LogOnly bool `yaml:"log_only"`
+
+	// Enabled defines if the responses are limited.
+	Enabled bool `yaml:"enabled"`
+}
+
+// Validate returns an error if c is not valid.
+func (c *Config) Validate() (err error) {
+	var errs []error
+	if c.ResponsesPerSecond == 0 {
+		errs = append(errs, errors.Error("responses_per_second: must be positive"))
+	}
+
+	if c.Window.Duration < time.Second {
+		errs = append(errs, fmt.Errorf("window: must be at least 1s, got %s", c.Window))
+	}
+
+	if c.IPv4PrefixLen < 0 || c.IPv4PrefixLen > 32 {
+		errs = append(errs, fmt.Errorf("ipv4_prefix_len: out of range: %d", c.IPv4PrefixLen))
+	}
+
+	if c.IPv6PrefixLen < 0 || c.IPv6PrefixLen > 128 {
+		errs = append(errs, fmt.Errorf("ipv6_prefix_len: out of range: %d", c.IPv6PrefixLen))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Action is the action performed on a response.
+type Action uint8
+
+// Action values.
+const (
+	// ActionAllow means that the response is sent as usual.
+	ActionAllow Action = iota
+
+	// ActionDrop means that the response isn't sent.
+	ActionDrop
+
+	// ActionSlip means that a truncated response without the records is sent
+	// instead of the response.
+	ActionSlip
+)
+
+// String implements the [fmt.Stringer] interface for Action.
+func (a Action) String() (s string) {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionDrop:
+		return "drop"
+	case ActionSlip:
+		return "slip"
+	default:
+		return fmt.Sprintf("!bad_action_%d", a)
+	}
+}
+
+// class is the kind of the response.
+type class uint8
+
+// class values.
+const (
+	// classAnswer is a response with records in the answer section.  Such
+	// responses are grouped by the name and the type of the question.
+	classAnswer class = iota
+
+	// classNoData is a successful response without records in the answer
+	// section.  Such responses are grouped by the registrable domain.
+	classNoData
+
+	// classNXDomain is an NXDOMAIN response.  Such responses are grouped by the
+	// registrable domain, since the attackers use random subdomains to avoid
+	// the limits.
+	classNXDomain
+
+	// classError is a response with any other code.  Such responses are
+	// grouped by the network of the client only.
+	classError
+)
+
+// String implements the [fmt.Stringer] interface for class.
+func (c class) String() (s string) {
+	switch c {
+	case classAnswer:
+		return "answer"
+	case classNoData:
+		return "nodata"
+	case classNXDomain:
+		return "nxdomain"
+	case classError:
+		return "error"
+	default:
+		return fmt.Sprintf("!bad_class_%d", c)
+	}
+}
+
+// key identifies the bucket of a class of responses.
+type key struct {
+	// subnet is the network of the clients.
+	subnet netip.Prefix
+
+	// name is the lowercased requested name or its registrable domain.  It's
+	// empty for classError.
+	name string
+
+	// qtype is the question type for classAnswer and zero otherwise.
+	qtype uint16
+
+	// class is the kind of the responses.
+	class class
+}
+
+// String implements the [fmt.Stringer] interface for key.
+func (k key) String() (s string) {
+	if k.class == classError {
+		return fmt.Sprintf("%s responses to %s", k.class, k.subnet)
+	} else if k.class == classAnswer {
+		return fmt.Sprintf(
+			"%s responses for %s %s to %s",
+			k.class,
+			k.name,
+			dns.Type(k.qtype),
+			k.subnet,
+		)
+	}
+
+	return fmt.Sprintf("%s responses for %s to %s", k.class, k.name, k.subnet)
+}
+
+// bucket is the state of the rate limiting of a class of responses.
+type bucket struct {
+	// updated is the time of the last response of the class.
+	updated time.Time
+
+	// balance is the number of the responses, which can be sent before the
+	// limiting starts.  It's negative while the class is limited.
+	balance float64
+
+	// limited is the number of the limited responses since the limiting has
+	// started.  It's used to slip each Slip-th response.
+	limited uint
+}
+
+// maxBuckets is the maximum number of the tracked classes of responses.  The
+// responses of the classes exceeding it aren't limited until the idle ones are
+// removed, so that the memory usage is bounded under a spoofed flood.
+const maxBuckets = 100_000
+
+// Limiter limits the rate of the responses.
+type Limiter struct {
+	// mu protects buckets and swept.
+	mu      *sync.Mutex
+	buckets map[key]*bucket
+	swept   time.Time
+
+	// now returns the current time.  It's replaced in tests.
+	now func() (t time.Time)
+
+	conf *Config
+}
+
+// New returns a new properly initialized *Limiter.  conf must not be nil and
+// must be valid.
+func New(conf *Config) (l *Limiter) {
+	return &Limiter{
+		mu:      &sync.Mutex{},
+		buckets: map[key]*bucket{},
+		swept:   time.Now(),
+		now:     time.Now,
+		conf:    conf,
+	}
+}
+
+// LogOnly returns true if the limited responses must be sent as usual.
+func (l *Limiter) LogOnly() (ok bool) {
+	return l.conf.LogOnly
+}
+
+// Check returns the action to perform on resp, which is a response to the
+// request from addr.  Only the responses sent over UDP should be checked,
+// since the clients using TCP can't spoof their addresses.  resp must not be
+// nil and must have a question.  It's safe for concurrent use.
+func (l *Limiter) Check(addr netip.Addr, resp *dns.Msg) (a Action) {
+	k := l.key(addr, resp)
+	rate := float64(l.rate(k.class))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[k]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			return ActionAllow
+		}
+
+		b = &bucket{
+			updated: now,
+			balance: rate,
+		}
+		l.buckets[k] = b
+	} else {
+		elapsed := now.Sub(b.updated).Seconds()
+		b.balance = min(b.balance+elapsed*rate, rate)
+		b.updated = now
+	}
+
+	b.balance--
+	if b.balance >= 0 {
+		if b.limited > 0 {
+			log.Debug("rrl: stopped limiting %s", k)
+
+			b.limited = 0
+		}
+
+		return ActionAllow
+	}
+
+	b.balance = max(b.balance, -rate*l.conf.Window.Seconds())
+	b.limited++
+	if b.limited == 1 {
+		verb := "limiting"
+		if l.conf.LogOnly {
+			verb = "would limit"
+		}
+
+		log.Info("rrl: %s %s", verb, k)
+	}
+
+	if slip := l.conf.Slip; slip > 0 && b.limited%slip == 0 {
+		return ActionSlip
+	}
+
+	return ActionDrop
+}
+
+// key returns the key of the class of resp sent to addr.
+func (l *Limiter) key(addr netip.Addr, resp *dns.Msg) (k key) {
+	addr = addr.Unmap()
+	bits := l.conf.IPv6PrefixLen
+	if addr.Is4() {
+		bits = l.conf.IPv4PrefixLen
+	}
+
+	// Don't check the error, since the prefix length is validated.
+	k.subnet, _ = addr.Prefix(bits)
+
+	q := resp.Question[0]
+	name := strings.ToLower(strings.TrimSuffix(q.Name, "."))
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess:
+		if len(resp.Answer) > 0 {
+			k.class, k.name, k.qtype = classAnswer, name, q.Qtype
+		} else {
+			k.class, k.name = classNoData, registrableDomain(name)
+		}
+	case dns.RcodeNameError:
+		k.class, k.name = classNXDomain, registrableDomain(name)
+	default:
+		k.class = classError
+	}
+
+	return k
+}
+
+// registrableDomain returns the registrable domain of name or name itself if
+// it's a public suffix.
+func registrableDomain(name string) (d string) {
+	d, err := publicsuffix.EffectiveTLDPlusOne(name)
+	if err != nil {
+		return name
+	}
+
+	return d
+}
+
+// rate returns the number of the responses of class c allowed per second.
+func (l *Limiter) rate(c class) (r uint) {
+	r = l.conf.ResponsesPerSecond
+	switch c {
+	case classNXDomain:
+		if l.conf.NXDOMAINsPerSecond > 0 {
+			r = l.conf.NXDOMAINsPerSecond
+		}
+	case classError:
+		if l.conf.ErrorsPerSecond > 0 {
+			r = l.conf.ErrorsPerSecond
+		}
+	default:
+		// Go on.
+	}
+
+	return r
+}
+
+// sweep removes the buckets not updated within the window, once per window, so
+// that the memory isn't spent on the classes no longer requested.  l.mu is
+// expected to be locked.
+func (l *Limiter) sweep(now time.Time) {
+	window := l.conf.Window.Duration
+	if now.Sub(l.swept) < window {
+		return
+	}
+
+	for k, b := range l.buckets {
+		if now.Sub(b.updated) > window {
+			delete(l.buckets, k)
+		}
+	}
+
+	l.swept = now
+}
@@ -0,0 +1,207 @@
+package rrl
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/timeutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestConfig returns a new *Config with the default values.
+func newTestConfig() (conf *Config) {
+	return &Config{
+		ResponsesPerSecond: DefaultResponsesPerSecond,
+		Window:             timeutil.Duration{Duration: DefaultWindow},
+		Slip:               DefaultSlip,
+		IPv4PrefixLen:      DefaultIPv4PrefixLen,
+		IPv6PrefixLen:      DefaultIPv6PrefixLen,
+		Enabled:            true,
+	}
+}
+
+// newTestLimiter returns a new *Limiter with the clock, which is advanced by
+// the returned function.
+func newTestLimiter(conf *Config) (l *Limiter, advance func(d time.Duration)) {
+	now := time.Now()
+	l = New(conf)
+	l.now = func() (t time.Time) { return now }
+
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+// newResp returns a new response for name with the code rcode and the given
+// number of A records.
+func newResp(name string, rcode, answers int) (resp *dns.Msg) {
+	resp = (&dns.Msg{}).SetQuestion(dns.Fqdn(name), dns.TypeA)
+	resp.Response = true
+	resp.Rcode = rcode
+	for range answers {
+		resp.Answer = append(resp.Answer, &dns.A{
+			Hdr: dns.RR_Header{
+				Name:   resp.Question[0].Name,
+				Rrtype: dns.TypeA,
+				Class:  dns.ClassINET,
+			},
+		})
+	}
+
+	return resp
+}
+
+// checkN calls l.Check n times and returns the actions.
+func checkN(l *Limiter, addr netip.Addr, resp *dns.Msg, n int) (actions []Action) {
+	for range n {
+		actions = append(actions, l.Check(addr, resp))
+	}
+
+	return actions
+}
+
+func TestLimiter_Check(t *testing.T) {
+	conf := newTestConfig()
+	conf.ResponsesPerSecond = 2
+	conf.Slip = 2
+
+	l, advance := newTestLimiter(conf)
+
+	addr := netip.MustParseAddr("192.0.2.1")
+	resp := newResp("www.example.com", dns.RcodeSuccess, 1)
+
+	got := checkN(l, addr, resp, 6)
+	assert.Equal(t, []Action{
+		ActionAllow,
+		ActionAllow,
+		ActionDrop,
+		ActionSlip,
+		ActionDrop,
+		ActionSlip,
+	}, got)
+
+	t.Run("same_subnet", func(t *testing.T) {
+		neighbour := netip.MustParseAddr("192.0.2.254")
+		assert.Equal(t, ActionDrop, l.Check(neighbour, resp))
+	})
+
+	t.Run("other_subnet", func(t *testing.T) {
+		other := netip.MustParseAddr("198.51.100.1")
+		assert.Equal(t, ActionAllow, l.Check(other, resp))
+	})
+
+	t.Run("other_name", func(t *testing.T) {
+		otherResp := newResp("www.example.org", dns.RcodeSuccess, 1)
+		assert.Equal(t, ActionAllow, l.Check(addr, otherResp))
+	})
+
+	t.Run("debt", func(t *testing.T) {
+		// The balance is -5 now, so a second isn't enough to pay the debt.
+		advance(time.Second)
+		assert.NotEqual(t, ActionAllow, l.Check(addr, resp))
+
+		advance(5 * time.Second)
+		assert.Equal(t, ActionAllow, l.Check(addr, resp))
+	})
+}
+
+func TestLimiter_Check_classes(t *testing.T) {
+	conf := newTestConfig()
+	conf.ResponsesPerSecond = 1
+	conf.NXDOMAINsPerSecond = 2
+	conf.ErrorsPerSecond = 3
+	conf.Slip = 0
+
+	addr := netip.MustParseAddr("2001:db8::1")
+
+	testCases := []struct {
+		first   *dns.Msg
+		second  *dns.Msg
+		name    string
+		allowed int
+	}{{
+		first:   newResp("a.example.com", dns.RcodeSuccess, 1),
+		second:  newResp("b.example.com", dns.RcodeSuccess, 1),
+		name:    "answer",
+		allowed: 1,
+	}, {
+		first:   newResp("a.example.com", dns.RcodeSuccess, 0),
+		second:  newResp("b.example.com", dns.RcodeSuccess, 0),
+		name:    "nodata",
+		allowed: 1,
+	}, {
+		first:   newResp("a.example.com", dns.RcodeNameError, 0),
+		second:  newResp("b.example.com", dns.RcodeNameError, 0),
+		name:    "nxdomain",
+		allowed: 2,
+	}, {
+		first:   newResp("a.example.com", dns.RcodeServerFailure, 0),
+		second:  newResp("b.example.org", dns.RcodeRefused, 0),
+		name:    "error",
+		allowed: 3,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l, _ := newTestLimiter(conf)
+
+			got := checkN(l, addr, tc.first, tc.allowed)
+			for _, a := range got {
+				require.Equal(t, ActionAllow, a)
+			}
+
+			sameClass := l.key(addr, tc.first) == l.key(addr, tc.second)
+			wantSecond := ActionAllow
+			if sameClass {
+				wantSecond = ActionDrop
+			}
+
+			assert.Equal(t, wantSecond, l.Check(addr, tc.second))
+		})
+	}
+}
+
+func TestLimiter_sweep(t *testing.T) {
+	conf := newTestConfig()
+	l, advance := newTestLimiter(conf)
+
+	addr := netip.MustParseAddr("192.0.2.1")
+	_ = l.Check(addr, newResp("www.example.com", dns.RcodeSuccess, 1))
+	require.Len(t, l.buckets, 1)
+
+	advance(conf.Window.Duration + time.Second)
+	_ = l.Check(addr, newResp("www.example.org", dns.RcodeSuccess, 1))
+
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		conf       *Config
+		name       string
+		wantErrMsg string
+	}{{
+		conf:       newTestConfig(),
+		name:       "valid",
+		wantErrMsg: "",
+	}, {
+		conf: &Config{
+			Window:        timeutil.Duration{Duration: time.Millisecond},
+			IPv4PrefixLen: 33,
+			IPv6PrefixLen: -1,
+		},
+		name: "invalid",
+		wantErrMsg: "responses_per_second: must be positive\n" +
+			"window: must be at least 1s, got 1ms\n" +
+			"ipv4_prefix_len: out of range: 33\n" +
+			"ipv6_prefix_len: out of range: -1",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.conf.Validate())
+		})
+	}
+}
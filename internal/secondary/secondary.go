@@ -0,0 +1,322 @@
+// Package secondary implements the secondary DNS zones, which are transferred
+// from the primary servers by AXFR and IXFR and served authoritatively from the
+// local copy.
+package secondary
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+)
+
+// DefaultTimeout is the default timeout of the SOA queries and of reading a
+// single message of a zone transfer.
+const DefaultTimeout = 10 * time.Second
+
+// defaultPort is the port of the primary servers used if none is specified.
+const defaultPort uint16 = 53
+
+// ZoneConfig is the configuration of a single secondary zone.
+type ZoneConfig struct {
+	// TSIG is the key used to sign the queries to the primary servers.  If
+	// nil, the queries aren't signed.
+	TSIG *TSIGConfig `yaml:"tsig"`
+
+	// Name is the name of the zone, for example "ad.corp".
+	Name string `yaml:"name"`
+
+	// Primaries are the addresses of the primary servers of the zone, with
+	// optional ports, for example "192.168.1.2" or "192.168.1.2:5353".  The
+	// NOTIFY messages are only accepted from these addresses.
+	Primaries []string `yaml:"primaries"`
+}
+
+// TSIGConfig is the configuration of a TSIG key.
+type TSIGConfig struct {
+	// Name is the name of the key.
+	Name string `yaml:"name"`
+
+	// Algorithm is the name of the HMAC algorithm, for example "hmac-sha256".
+	// If empty, hmac-sha256 is used.
+	Algorithm string `yaml:"algorithm"`
+
+	// Secret is the base64-encoded secret of the key.
+	Secret string `yaml:"secret"`
+}
+
+// tsigAlgorithms are the supported TSIG algorithms.
+var tsigAlgorithms = []string{
+	dns.HmacSHA1,
+	dns.HmacSHA224,
+	dns.HmacSHA256,
+	dns.HmacSHA384,
+	dns.HmacSHA512,
+}
+
+// tsigKey is a validated TSIG key.
+type tsigKey struct {
+	// name is the name of the key as an FQDN.
+	name string
+
+	// algorithm is the name of the algorithm as an FQDN.
+	algorithm string
+
+	// secret is the base64-encoded secret.
+	secret string
+}
+
+// toKey validates c and converts it into a *tsigKey.
+func (c *TSIGConfig) toKey() (k *tsigKey, err error) {
+	if c.Name == "" {
+		return nil, errors.Error("name: empty value")
+	}
+
+	alg := dns.HmacSHA256
+	if c.Algorithm != "" {
+		alg = dns.Fqdn(strings.ToLower(c.Algorithm))
+	}
+
+	if !isKnownAlgorithm(alg) {
+		return nil, fmt.Errorf("algorithm: unsupported value %q", c.Algorithm)
+	}
+
+	_, err = base64.StdEncoding.DecodeString(c.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("secret: %w", err)
+	} else if c.Secret == "" {
+		return nil, errors.Error("secret: empty value")
+	}
+
+	return &tsigKey{
+		name:      dns.CanonicalName(c.Name),
+		algorithm: alg,
+		secret:    c.Secret,
+	}, nil
+}
+
+// isKnownAlgorithm returns true if alg is one of [tsigAlgorithms].
+func isKnownAlgorithm(alg string) (ok bool) {
+	for _, known := range tsigAlgorithms {
+		if alg == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// secrets returns the secrets map in the format used by the [dns] package.
+func (k *tsigKey) secrets() (m map[string]string) {
+	return map[string]string{k.name: k.secret}
+}
+
+// parsePrimary parses the address of a primary server with an optional port.
+func parsePrimary(s string) (addr netip.AddrPort, err error) {
+	addr, err = netip.ParseAddrPort(s)
+	if err == nil {
+		return addr, nil
+	}
+
+	ip, ipErr := netip.ParseAddr(s)
+	if ipErr != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return netip.AddrPort{}, err
+	}
+
+	return netip.AddrPortFrom(ip, defaultPort), nil
+}
+
+// Config is the configuration of a [Manager].
+type Config struct {
+	// Zones are the secondary zones.
+	Zones []*ZoneConfig
+
+	// Timeout is the timeout of the SOA queries and of reading a single
+	// message of a zone transfer.  If zero, [DefaultTimeout] is used.
+	Timeout time.Duration
+}
+
+// Manager keeps the secondary zones up to date and answers the requests for
+// them.
+type Manager struct {
+	// done is closed when the manager is closed.
+	done chan struct{}
+
+	// zones are the secondary zones.  It's not modified after creation.
+	zones []*zone
+}
+
+// New returns a new properly initialized *Manager.  conf must not be nil.  The
+// zones aren't transferred until [Manager.Start] is called.
+func New(conf *Config) (m *Manager, err error) {
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	m = &Manager{
+		done: make(chan struct{}),
+	}
+
+	names := map[string]struct{}{}
+	var errs []error
+	for i, zc := range conf.Zones {
+		var z *zone
+		z, err = newZone(zc, timeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("zone at index %d: %w", i, err))
+
+			continue
+		}
+
+		if _, ok := names[z.name]; ok {
+			errs = append(errs, fmt.Errorf("zone at index %d: duplicate name %q", i, zc.Name))
+
+			continue
+		}
+
+		names[z.name] = struct{}{}
+		m.zones = append(m.zones, z)
+	}
+
+	if err = errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("secondary: %w", err)
+	}
+
+	return m, nil
+}
+
+// newZone validates zc and returns a new *zone for it.
+func newZone(zc *ZoneConfig, timeout time.Duration) (z *zone, err error) {
+	if zc == nil {
+		return nil, errors.Error("no value")
+	}
+
+	if _, ok := dns.IsDomainName(zc.Name); !ok || zc.Name == "" {
+		return nil, fmt.Errorf("name: bad domain name %q", zc.Name)
+	}
+
+	if len(zc.Primaries) == 0 {
+		return nil, errors.Error("primaries: empty value")
+	}
+
+	z = newEmptyZone(dns.CanonicalName(zc.Name), timeout)
+	for _, p := range zc.Primaries {
+		var addr netip.AddrPort
+		addr, err = parsePrimary(p)
+		if err != nil {
+			return nil, fmt.Errorf("primaries: %w", err)
+		}
+
+		z.primaries = append(z.primaries, addr)
+	}
+
+	if zc.TSIG != nil {
+		z.tsig, err = zc.TSIG.toKey()
+		if err != nil {
+			return nil, fmt.Errorf("tsig: %w", err)
+		}
+	}
+
+	return z, nil
+}
+
+// Start starts keeping the zones up to date.  It must only be called once.
+func (m *Manager) Start() {
+	for _, z := range m.zones {
+		go z.refreshLoop(m.done)
+	}
+}
+
+// Close stops keeping the zones up to date.  It must only be called once.
+func (m *Manager) Close() {
+	close(m.done)
+}
+
+// zoneFor returns the closest secondary zone containing name or nil if there
+// is none.
+func (m *Manager) zoneFor(name string) (z *zone) {
+	for _, cur := range m.zones {
+		if !dns.IsSubDomain(cur.name, name) {
+			continue
+		} else if z == nil || len(cur.name) > len(z.name) {
+			z = cur
+		}
+	}
+
+	return z
+}
+
+// Answer returns the authoritative response to req from the local copy of the
+// zone containing the requested name.  resp is nil if there is no such zone or
+// if it isn't loaded or has expired, so that the request should be resolved as
+// usual.  It's safe for concurrent use.
+func (m *Manager) Answer(req *dns.Msg) (resp *dns.Msg) {
+	if req.Opcode != dns.OpcodeQuery || len(req.Question) != 1 {
+		return nil
+	}
+
+	q := req.Question[0]
+	if q.Qclass != dns.ClassINET {
+		return nil
+	}
+
+	name := dns.CanonicalName(q.Name)
+	z := m.zoneFor(name)
+	if z == nil || (q.Qtype == dns.TypeDS && name == z.name) {
+		// The DS records of the zone are served by the parent zone.
+		return nil
+	}
+
+	data := z.data.Load()
+	if data == nil || !z.now().Before(data.expire) {
+		return nil
+	}
+
+	return data.answer(req, name, q.Qtype)
+}
+
+// Notify handles the NOTIFY request req received from the address from and
+// returns the response.  The refresh of the zone is scheduled if from is one of
+// its primaries.  It's safe for concurrent use.
+//
+// See RFC 1996.
+func (m *Manager) Notify(req *dns.Msg, from netip.Addr) (resp *dns.Msg) {
+	resp = (&dns.Msg{}).SetReply(req)
+	if len(req.Question) != 1 {
+		return resp.SetRcode(req, dns.RcodeFormatError)
+	}
+
+	name := dns.CanonicalName(req.Question[0].Name)
+	z := m.zoneFor(name)
+	if z == nil || z.name != name {
+		log.Debug("secondary: notify for unknown zone %q from %s", name, from)
+
+		return resp.SetRcode(req, dns.RcodeNotAuth)
+	}
+
+	if !z.isPrimary(from) {
+		log.Info("secondary: zone %s: notify from unknown address %s", name, from)
+
+		return resp.SetRcode(req, dns.RcodeRefused)
+	}
+
+	log.Debug("secondary: zone %s: notify from %s", name, from)
+
+	select {
+	case z.notify <- struct{}{}:
+		// Go on.
+	default:
+		// A refresh has already been scheduled.
+	}
+
+	resp.Authoritative = true
+
+	return resp
+}
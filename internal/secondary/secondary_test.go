@@ -0,0 +1,454 @@
+package secondary_test
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/secondary"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Common constants for tests.
+const (
+	testZone    = "ad.corp."
+	testTimeout = 1 * time.Second
+	testKeyName = "transfer."
+	testSecret  = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+)
+
+// newRRs parses the records from the presentation format.
+func newRRs(t testing.TB, lines ...string) (rrs []dns.RR) {
+	t.Helper()
+
+	for _, l := range lines {
+		rr, err := dns.NewRR(l)
+		require.NoError(t, err)
+
+		rrs = append(rrs, rr)
+	}
+
+	return rrs
+}
+
+// testPrimary is a primary server of a single zone, which keeps all the
+// versions of the zone to serve the incremental transfers.
+type testPrimary struct {
+	t testing.TB
+
+	// mu protects versions and qtypes.
+	mu *sync.Mutex
+
+	// versions are the records of each version of the zone.  The first record
+	// of each version is its SOA.
+	versions [][]dns.RR
+
+	// qtypes are the types of the requests received.
+	qtypes []uint16
+}
+
+// type check
+var _ dns.Handler = (*testPrimary)(nil)
+
+// ServeDNS implements the [dns.Handler] interface for *testPrimary.
+func (p *testPrimary) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	q := req.Question[0]
+	p.qtypes = append(p.qtypes, q.Qtype)
+
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		resp := (&dns.Msg{}).SetRcode(req, dns.RcodeRefused)
+		assert.NoError(p.t, w.WriteMsg(resp))
+
+		return
+	}
+
+	cur := p.versions[len(p.versions)-1]
+	var rrs []dns.RR
+	switch q.Qtype {
+	case dns.TypeSOA:
+		resp := (&dns.Msg{}).SetReply(req)
+		resp.Answer = cur[:1]
+		resp.SetTsig(testKeyName, dns.HmacSHA256, 300, time.Now().Unix())
+		assert.NoError(p.t, w.WriteMsg(resp))
+
+		return
+	case dns.TypeAXFR:
+		rrs = append(slices.Clone(cur), cur[0])
+	case dns.TypeIXFR:
+		rrs = p.diff(req.Ns[0].(*dns.SOA).Serial)
+	default:
+		p.t.Errorf("unexpected qtype %d", q.Qtype)
+
+		return
+	}
+
+	ch := make(chan *dns.Envelope, 1)
+	ch <- &dns.Envelope{RR: rrs}
+	close(ch)
+
+	assert.NoError(p.t, (&dns.Transfer{}).Out(w, req, ch))
+}
+
+// diff returns the incremental transfer since serial.  p.mu is expected to be
+// locked.
+func (p *testPrimary) diff(serial uint32) (rrs []dns.RR) {
+	cur := p.versions[len(p.versions)-1]
+	rrs = []dns.RR{cur[0]}
+
+	old := slices.IndexFunc(p.versions, func(v []dns.RR) (ok bool) {
+		return v[0].(*dns.SOA).Serial == serial
+	})
+	if !assert.GreaterOrEqual(p.t, old, 0) {
+		return []dns.RR{cur[0]}
+	}
+
+	for i := old; i < len(p.versions)-1; i++ {
+		prev, next := p.versions[i], p.versions[i+1]
+		rrs = append(rrs, prev[0])
+		rrs = append(rrs, missing(prev[1:], next[1:])...)
+		rrs = append(rrs, next[0])
+		rrs = append(rrs, missing(next[1:], prev[1:])...)
+	}
+
+	return append(rrs, cur[0])
+}
+
+// missing returns the records from a, which are missing in b.
+func missing(a, b []dns.RR) (rrs []dns.RR) {
+	for _, rr := range a {
+		if !slices.ContainsFunc(b, func(other dns.RR) (ok bool) {
+			return dns.IsDuplicate(rr, other)
+		}) {
+			rrs = append(rrs, rr)
+		}
+	}
+
+	return rrs
+}
+
+// addVersion adds a new version of the zone.
+func (p *testPrimary) addVersion(rrs []dns.RR) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.versions = append(p.versions, rrs)
+}
+
+// requested returns true if p has received a request of qtype.
+func (p *testPrimary) requested(qtype uint16) (ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Contains(p.qtypes, qtype)
+}
+
+// startPrimary starts serving p over UDP and TCP on the same port of the
+// loopback interface and returns its address.
+func startPrimary(t testing.TB, p *testPrimary) (addr netip.AddrPort) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	require.NoError(t, err)
+
+	secret := map[string]string{testKeyName: testSecret}
+	for _, srv := range []*dns.Server{{
+		PacketConn: pc,
+		Handler:    p,
+		TsigSecret: secret,
+	}, {
+		Listener:   l,
+		Handler:    p,
+		TsigSecret: secret,
+	}} {
+		go func() { _ = srv.ActivateAndServe() }()
+		testutil.CleanupAndRequireSuccess(t, srv.Shutdown)
+	}
+
+	return netip.MustParseAddrPort(pc.LocalAddr().String())
+}
+
+// zoneV1 and zoneV2 are the presentation formats of the versions of the test
+// zone.
+var (
+	zoneV1 = []string{
+		testZone + " 3600 IN SOA ns.ad.corp. admin.ad.corp. 1 900 60 86400 300",
+		testZone + " 3600 IN NS ns.ad.corp.",
+		"ns.ad.corp. 3600 IN A 192.0.2.53",
+		"dc1.ad.corp. 600 IN A 192.0.2.10",
+		"www.ad.corp. 600 IN CNAME dc1.ad.corp.",
+		"ext.ad.corp. 600 IN CNAME www.example.com.",
+		"*.wild.ad.corp. 600 IN A 192.0.2.20",
+		"host.ent.ad.corp. 600 IN A 192.0.2.30",
+		"sub.ad.corp. 3600 IN NS ns.sub.ad.corp.",
+		"ns.sub.ad.corp. 3600 IN A 192.0.2.40",
+	}
+	zoneV2 = []string{
+		testZone + " 3600 IN SOA ns.ad.corp. admin.ad.corp. 2 900 60 86400 300",
+		testZone + " 3600 IN NS ns.ad.corp.",
+		"ns.ad.corp. 3600 IN A 192.0.2.53",
+		"dc1.ad.corp. 600 IN A 192.0.2.11",
+		"www.ad.corp. 600 IN CNAME dc1.ad.corp.",
+		"ext.ad.corp. 600 IN CNAME www.example.com.",
+		"*.wild.ad.corp. 600 IN A 192.0.2.20",
+		"host.ent.ad.corp. 600 IN A 192.0.2.30",
+		"sub.ad.corp. 3600 IN NS ns.sub.ad.corp.",
+		"ns.sub.ad.corp. 3600 IN A 192.0.2.40",
+	}
+)
+
+// newTestManager returns a started *secondary.Manager of the test zone
+// transferred from p.
+func newTestManager(t testing.TB, p *testPrimary) (m *secondary.Manager) {
+	t.Helper()
+
+	addr := startPrimary(t, p)
+	m, err := secondary.New(&secondary.Config{
+		Zones: []*secondary.ZoneConfig{{
+			TSIG: &secondary.TSIGConfig{
+				Name:   testKeyName,
+				Secret: testSecret,
+			},
+			Name:      "AD.corp",
+			Primaries: []string{addr.String()},
+		}},
+		Timeout: testTimeout,
+	})
+	require.NoError(t, err)
+
+	m.Start()
+	testutil.CleanupAndRequireSuccess(t, func() (err error) {
+		m.Close()
+
+		return nil
+	})
+
+	return m
+}
+
+// answerOf returns the answer of m for name of qtype.
+func answerOf(m *secondary.Manager, name string, qtype uint16) (resp *dns.Msg) {
+	return m.Answer((&dns.Msg{}).SetQuestion(name, qtype))
+}
+
+// rrStrings returns the data of the records in rrs.
+func rrStrings(rrs []dns.RR) (data []string) {
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		data = append(data, hdr.Name+" "+strings.TrimPrefix(rr.String(), hdr.String()))
+	}
+
+	return data
+}
+
+func TestManager_Answer(t *testing.T) {
+	p := &testPrimary{
+		t:        t,
+		mu:       &sync.Mutex{},
+		versions: [][]dns.RR{newRRs(t, zoneV1...)},
+	}
+	m := newTestManager(t, p)
+
+	require.Eventually(t, func() (ok bool) {
+		return answerOf(m, "dc1.ad.corp.", dns.TypeA) != nil
+	}, testTimeout, testTimeout/10)
+
+	testCases := []struct {
+		name       string
+		host       string
+		wantAnswer []string
+		wantNs     []string
+		qtype      uint16
+		wantRcode  int
+		wantAA     bool
+	}{{
+		name:       "answer",
+		host:       "DC1.ad.corp.",
+		wantAnswer: []string{"dc1.ad.corp. 192.0.2.10"},
+		wantNs:     nil,
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     true,
+	}, {
+		name:       "cname",
+		host:       "www.ad.corp.",
+		wantAnswer: []string{"www.ad.corp. dc1.ad.corp.", "dc1.ad.corp. 192.0.2.10"},
+		wantNs:     nil,
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     true,
+	}, {
+		name:       "cname_external",
+		host:       "ext.ad.corp.",
+		wantAnswer: []string{"ext.ad.corp. www.example.com."},
+		wantNs:     nil,
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     true,
+	}, {
+		name:       "nodata",
+		host:       "dc1.ad.corp.",
+		wantAnswer: nil,
+		wantNs:     []string{"ad.corp. ns.ad.corp. admin.ad.corp. 1 900 60 86400 300"},
+		qtype:      dns.TypeAAAA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     true,
+	}, {
+		name:       "empty_non_terminal",
+		host:       "ent.ad.corp.",
+		wantAnswer: nil,
+		wantNs:     []string{"ad.corp. ns.ad.corp. admin.ad.corp. 1 900 60 86400 300"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     true,
+	}, {
+		name:       "nxdomain",
+		host:       "none.ad.corp.",
+		wantAnswer: nil,
+		wantNs:     []string{"ad.corp. ns.ad.corp. admin.ad.corp. 1 900 60 86400 300"},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeNameError,
+		wantAA:     true,
+	}, {
+		name:       "wildcard",
+		host:       "any.wild.ad.corp.",
+		wantAnswer: []string{"any.wild.ad.corp. 192.0.2.20"},
+		wantNs:     nil,
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     true,
+	}, {
+		name:       "referral",
+		host:       "host.sub.ad.corp.",
+		wantAnswer: nil,
+		wantNs:     []string{"sub.ad.corp. ns.sub.ad.corp."},
+		qtype:      dns.TypeA,
+		wantRcode:  dns.RcodeSuccess,
+		wantAA:     false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := answerOf(m, tc.host, tc.qtype)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantRcode, resp.Rcode)
+			assert.Equal(t, tc.wantAA, resp.Authoritative)
+			assert.Equal(t, tc.wantAnswer, rrStrings(resp.Answer))
+			assert.Equal(t, tc.wantNs, rrStrings(resp.Ns))
+		})
+	}
+
+	t.Run("not_authoritative", func(t *testing.T) {
+		assert.Nil(t, answerOf(m, "www.example.com.", dns.TypeA))
+		assert.Nil(t, answerOf(m, testZone, dns.TypeDS))
+	})
+}
+
+func TestManager_Notify(t *testing.T) {
+	p := &testPrimary{
+		t:        t,
+		mu:       &sync.Mutex{},
+		versions: [][]dns.RR{newRRs(t, zoneV1...)},
+	}
+	m := newTestManager(t, p)
+
+	require.Eventually(t, func() (ok bool) {
+		return answerOf(m, "dc1.ad.corp.", dns.TypeA) != nil
+	}, testTimeout, testTimeout/10)
+
+	notify := (&dns.Msg{}).SetNotify(testZone)
+	localhost := netip.MustParseAddr("127.0.0.1")
+
+	t.Run("unknown_zone", func(t *testing.T) {
+		resp := m.Notify((&dns.Msg{}).SetNotify("example.com."), localhost)
+		assert.Equal(t, dns.RcodeNotAuth, resp.Rcode)
+	})
+
+	t.Run("unknown_primary", func(t *testing.T) {
+		resp := m.Notify(notify, netip.MustParseAddr("192.0.2.1"))
+		assert.Equal(t, dns.RcodeRefused, resp.Rcode)
+	})
+
+	p.addVersion(newRRs(t, zoneV2...))
+
+	resp := m.Notify(notify, localhost)
+	require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+
+	assert.Equal(t, dns.OpcodeNotify, resp.Opcode)
+	assert.True(t, resp.Authoritative)
+
+	assert.Eventually(t, func() (ok bool) {
+		updated := answerOf(m, "dc1.ad.corp.", dns.TypeA)
+
+		return slices.Equal(rrStrings(updated.Answer), []string{"dc1.ad.corp. 192.0.2.11"})
+	}, testTimeout, testTimeout/10)
+
+	assert.True(t, p.requested(dns.TypeIXFR))
+	assert.Len(t, answerOf(m, "ns.sub.ad.corp.", dns.TypeA).Ns, 1)
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name       string
+		wantErrMsg string
+		zones      []*secondary.ZoneConfig
+	}{{
+		name:       "success",
+		wantErrMsg: "",
+		zones: []*secondary.ZoneConfig{{
+			Name:      "ad.corp",
+			Primaries: []string{"192.0.2.1", "[2001:db8::1]:5353"},
+		}},
+	}, {
+		name:       "no_primaries",
+		wantErrMsg: "secondary: zone at index 0: primaries: empty value",
+		zones: []*secondary.ZoneConfig{{
+			Name: "ad.corp",
+		}},
+	}, {
+		name: "bad_algorithm",
+		wantErrMsg: `secondary: zone at index 0: tsig: algorithm: ` +
+			`unsupported value "hmac-md4"`,
+		zones: []*secondary.ZoneConfig{{
+			TSIG: &secondary.TSIGConfig{
+				Name:      testKeyName,
+				Algorithm: "hmac-md4",
+				Secret:    testSecret,
+			},
+			Name:      "ad.corp",
+			Primaries: []string{"192.0.2.1"},
+		}},
+	}, {
+		name:       "duplicate",
+		wantErrMsg: `secondary: zone at index 1: duplicate name "AD.corp."`,
+		zones: []*secondary.ZoneConfig{{
+			Name:      "ad.corp",
+			Primaries: []string{"192.0.2.1"},
+		}, {
+			Name:      "AD.corp.",
+			Primaries: []string{"192.0.2.1"},
+		}},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := secondary.New(&secondary.Config{
+				Zones: tc.zones,
+			})
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package secondary
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+)
+
+// Refresh intervals.
+const (
+	// minRefreshIvl is the minimum interval between the refreshes of a zone,
+	// which protects the primaries from the too small SOA timers.
+	minRefreshIvl = 5 * time.Second
+
+	// initialRetryIvl is the interval between the attempts to transfer a zone
+	// for the first time.
+	initialRetryIvl = 1 * time.Minute
+)
+
+// errUpToDate is returned by [zone.ixfr] when the primary has no changes.
+const errUpToDate errors.Error = "zone is up to date"
+
+// refreshLoop keeps z up to date until done is closed.  It's intended to be
+// used as a goroutine.
+func (z *zone) refreshLoop(done <-chan struct{}) {
+	defer log.OnPanic("secondary: refreshing " + z.name)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			// Go on.
+		case <-z.notify:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-done:
+			return
+		}
+
+		timer.Reset(z.refresh())
+	}
+}
+
+// refresh checks the primaries of z for the updates and transfers the zone, if
+// there are any.  wait is the interval until the next refresh.
+func (z *zone) refresh() (wait time.Duration) {
+	data := z.data.Load()
+
+	var errs []error
+	for _, addr := range z.primaries {
+		err := z.refreshFrom(data, addr)
+		if err == nil {
+			return max(time.Duration(z.data.Load().soa.Refresh)*time.Second, minRefreshIvl)
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", addr, err))
+	}
+
+	log.Error("secondary: zone %s: refreshing: %s", z.name, errors.Join(errs...))
+
+	if data == nil {
+		return initialRetryIvl
+	}
+
+	return max(time.Duration(data.soa.Retry)*time.Second, minRefreshIvl)
+}
+
+// refreshFrom updates the local copy data of z from the primary at addr.  data
+// is nil if the zone hasn't been transferred yet.
+func (z *zone) refreshFrom(data *zoneData, addr netip.AddrPort) (err error) {
+	if data == nil {
+		return z.axfr(addr)
+	}
+
+	serial, err := z.primarySerial(addr)
+	if err != nil {
+		return fmt.Errorf("querying soa: %w", err)
+	}
+
+	if !isNewerSerial(data.soa.Serial, serial) {
+		log.Debug("secondary: zone %s: serial %d is up to date", z.name, data.soa.Serial)
+		z.data.Store(data.withExpire(z.now()))
+
+		return nil
+	}
+
+	err = z.ixfr(data, addr)
+	if errors.Is(err, errUpToDate) {
+		// The primary may be behind the one answering the SOA query, so wait
+		// for the next refresh.
+		z.data.Store(data.withExpire(z.now()))
+
+		return nil
+	} else if err != nil {
+		log.Info("secondary: zone %s: ixfr from %s: %s; trying axfr", z.name, addr, err)
+
+		return z.axfr(addr)
+	}
+
+	return nil
+}
+
+// newMsg returns a new non-recursive request for the zone of type qtype.
+func (z *zone) newMsg(qtype uint16) (req *dns.Msg) {
+	req = (&dns.Msg{}).SetQuestion(z.name, qtype)
+	req.RecursionDesired = false
+
+	return req
+}
+
+// sign signs req with the TSIG key of z, if any.
+func (z *zone) sign(req *dns.Msg) {
+	if z.tsig != nil {
+		req.SetTsig(z.tsig.name, z.tsig.algorithm, 300, time.Now().Unix())
+	}
+}
+
+// primarySerial returns the serial of the zone on the primary at addr.
+func (z *zone) primarySerial(addr netip.AddrPort) (serial uint32, err error) {
+	req := z.newMsg(dns.TypeSOA)
+	z.sign(req)
+
+	cli := &dns.Client{
+		Timeout: z.timeout,
+	}
+	if z.tsig != nil {
+		cli.TsigSecret = z.tsig.secrets()
+	}
+
+	resp, _, err := cli.Exchange(req, addr.String())
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return 0, err
+	} else if resp.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("bad rcode %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok && dns.CanonicalName(soa.Hdr.Name) == z.name {
+			return soa.Serial, nil
+		}
+	}
+
+	return 0, errors.Error("no soa in response")
+}
+
+// transfer performs the zone transfer req from the primary at addr and returns
+// all the received records.
+func (z *zone) transfer(req *dns.Msg, addr netip.AddrPort) (rrs []dns.RR, err error) {
+	z.sign(req)
+
+	t := &dns.Transfer{
+		DialTimeout:  z.timeout,
+		ReadTimeout:  z.timeout,
+		WriteTimeout: z.timeout,
+	}
+	if z.tsig != nil {
+		t.TsigSecret = z.tsig.secrets()
+	}
+
+	envs, err := t.In(req, addr.String())
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	for env := range envs {
+		if env.Error != nil {
+			err = env.Error
+		}
+
+		rrs = append(rrs, env.RR...)
+	}
+
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	} else if len(rrs) == 0 {
+		return nil, errors.Error("empty transfer")
+	}
+
+	return rrs, nil
+}
+
+// axfr transfers the whole zone from the primary at addr and replaces the local
+// copy with it.
+func (z *zone) axfr(addr netip.AddrPort) (err error) {
+	req := z.newMsg(dns.TypeAXFR)
+	rrs, err := z.transfer(req, addr)
+	if err != nil {
+		return fmt.Errorf("axfr: %w", err)
+	}
+
+	soa, ok := rrs[0].(*dns.SOA)
+	if !ok || dns.CanonicalName(soa.Hdr.Name) != z.name {
+		return errors.Error("axfr: no leading soa")
+	}
+
+	data := newZoneData(z.name, soa, rrs[1:], z.now())
+	z.data.Store(data)
+
+	log.Info("secondary: zone %s: transferred serial %d from %s", z.name, soa.Serial, addr)
+
+	return nil
+}
+
+// ixfr transfers the changes of the zone since the serial of data from the
+// primary at addr and applies them to the local copy.  The primary may also
+// respond with the whole zone, see RFC 1995.
+func (z *zone) ixfr(data *zoneData, addr netip.AddrPort) (err error) {
+	req := z.newMsg(dns.TypeIXFR)
+	req.Ns = []dns.RR{&dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   z.name,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+		},
+		Ns:     data.soa.Ns,
+		Mbox:   data.soa.Mbox,
+		Serial: data.soa.Serial,
+	}}
+
+	rrs, err := z.transfer(req, addr)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return err
+	}
+
+	soa, ok := rrs[0].(*dns.SOA)
+	if !ok || dns.CanonicalName(soa.Hdr.Name) != z.name {
+		return errors.Error("no leading soa")
+	} else if !isNewerSerial(data.soa.Serial, soa.Serial) {
+		return errUpToDate
+	} else if len(rrs) == 1 {
+		return errors.Error("no changes sent")
+	}
+
+	var next *zoneData
+	if len(rrs) > 1 && isSOAWithSerial(rrs[1], data.soa.Serial) {
+		next, err = applyIXFR(data, rrs[1:len(rrs)-1], z.now())
+		if err != nil {
+			return fmt.Errorf("applying changes: %w", err)
+		}
+	} else {
+		// The primary responded with the whole zone.
+		next = newZoneData(z.name, soa, rrs[1:], z.now())
+	}
+
+	z.data.Store(next)
+
+	log.Info(
+		"secondary: zone %s: updated from serial %d to %d from %s",
+		z.name,
+		data.soa.Serial,
+		soa.Serial,
+		addr,
+	)
+
+	return nil
+}
+
+// isSOAWithSerial returns true if rr is an SOA record with serial.
+func isSOAWithSerial(rr dns.RR, serial uint32) (ok bool) {
+	soa, ok := rr.(*dns.SOA)
+
+	return ok && soa.Serial == serial
+}
+
+// applyIXFR returns the new zone data with the changes applied to data.
+// changes are the difference sequences of an incremental transfer without the
+// leading and trailing SOA records of the new version.  Each sequence consists
+// of the SOA record of the old version, the deleted records, the SOA record of
+// the new version, and the added records.
+func applyIXFR(data *zoneData, changes []dns.RR, now time.Time) (next *zoneData, err error) {
+	var rrs []dns.RR
+	for _, owned := range data.records {
+		rrs = append(rrs, owned...)
+	}
+
+	soa := data.soa
+	deleting := false
+	for _, rr := range changes {
+		if newSOA, ok := rr.(*dns.SOA); ok {
+			deleting = !deleting
+			if deleting && newSOA.Serial != soa.Serial {
+				return nil, fmt.Errorf("serial %d doesn't match %d", newSOA.Serial, soa.Serial)
+			}
+
+			soa = newSOA
+
+			continue
+		}
+
+		rrs = slices.DeleteFunc(rrs, func(existing dns.RR) (ok bool) {
+			return dns.IsDuplicate(existing, rr)
+		})
+
+		if !deleting {
+			rrs = append(rrs, rr)
+		}
+	}
+
+	if deleting {
+		return nil, errors.Error("incomplete difference sequence")
+	}
+
+	return newZoneData(data.origin, soa, rrs, now), nil
+}
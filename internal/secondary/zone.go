@@ -0,0 +1,297 @@
+package secondary
+
+import (
+	"net/netip"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEs is the maximum length of a CNAME chain followed within a zone.
+const maxCNAMEs = 8
+
+// zone is a single secondary zone.
+type zone struct {
+	// data is the local copy of the zone.  It's nil until the zone is
+	// transferred for the first time.
+	data *atomic.Pointer[zoneData]
+
+	// notify receives the NOTIFY signals.  It's buffered, so that the
+	// notifications received during a refresh aren't lost.
+	notify chan struct{}
+
+	// tsig is the key used to sign the queries to the primaries.  It's nil if
+	// the queries aren't signed.
+	tsig *tsigKey
+
+	// now returns the current time.  It's replaced in tests.
+	now func() (t time.Time)
+
+	// name is the lowercased FQDN of the zone.
+	name string
+
+	// primaries are the addresses of the primary servers.
+	primaries []netip.AddrPort
+
+	// timeout is the timeout of the SOA queries and of reading a single
+	// message of a zone transfer.
+	timeout time.Duration
+}
+
+// newEmptyZone returns a new *zone named name without any primaries.
+func newEmptyZone(name string, timeout time.Duration) (z *zone) {
+	return &zone{
+		data:    &atomic.Pointer[zoneData]{},
+		notify:  make(chan struct{}, 1),
+		now:     time.Now,
+		name:    name,
+		timeout: timeout,
+	}
+}
+
+// isPrimary returns true if addr is the address of one of the primaries of z.
+func (z *zone) isPrimary(addr netip.Addr) (ok bool) {
+	addr = addr.Unmap()
+	for _, p := range z.primaries {
+		if p.Addr().Unmap() == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// zoneData is an immutable local copy of a zone.
+type zoneData struct {
+	// soa is the SOA record of the zone.
+	soa *dns.SOA
+
+	// records are the records of the zone by their lowercased owner names.
+	records map[string][]dns.RR
+
+	// names are the lowercased names existing in the zone, including the empty
+	// non-terminals.
+	names map[string]struct{}
+
+	// expire is the time, after which the copy mustn't be used, unless the
+	// primaries confirm it.
+	expire time.Time
+
+	// origin is the lowercased FQDN of the zone.
+	origin string
+}
+
+// newZoneData returns the zone data for origin containing rrs.  The records
+// outside of origin and the SOA records other than soa are ignored.
+func newZoneData(origin string, soa *dns.SOA, rrs []dns.RR, now time.Time) (d *zoneData) {
+	d = &zoneData{
+		soa:     soa,
+		records: map[string][]dns.RR{},
+		names:   map[string]struct{}{},
+		origin:  origin,
+	}
+
+	d.add(soa)
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.SOA); !ok {
+			d.add(rr)
+		}
+	}
+
+	return d.withExpire(now)
+}
+
+// add adds rr to d, unless it's already there.  It must only be used while d is
+// built.
+func (d *zoneData) add(rr dns.RR) {
+	name := dns.CanonicalName(rr.Header().Name)
+	if !dns.IsSubDomain(d.origin, name) {
+		return
+	}
+
+	for _, existing := range d.records[name] {
+		if dns.IsDuplicate(existing, rr) {
+			return
+		}
+	}
+
+	d.records[name] = append(d.records[name], rr)
+	for off, end := 0, false; !end; off, end = dns.NextLabel(name, off) {
+		sub := name[off:]
+		d.names[sub] = struct{}{}
+		if sub == d.origin {
+			break
+		}
+	}
+}
+
+// withExpire returns a shallow copy of d, which expires after the expire
+// interval of its SOA record passes since now.
+func (d *zoneData) withExpire(now time.Time) (c *zoneData) {
+	c = &zoneData{}
+	*c = *d
+	c.expire = now.Add(time.Duration(d.soa.Expire) * time.Second)
+
+	return c
+}
+
+// answer returns the authoritative response to req for name of type qtype.
+// name must be within d.
+func (d *zoneData) answer(req *dns.Msg, name string, qtype uint16) (resp *dns.Msg) {
+	resp = (&dns.Msg{}).SetReply(req)
+	resp.RecursionAvailable = true
+
+	if d.refer(resp, name, qtype) {
+		return resp
+	}
+
+	resp.Authoritative = true
+	for range maxCNAMEs {
+		rrs, ok := d.lookup(name)
+		if !ok {
+			// The response code refers to the last name of the chain, see RFC
+			// 6604.
+			resp.Rcode = dns.RcodeNameError
+			d.setNegative(resp)
+
+			return resp
+		}
+
+		found := filterRRs(rrs, qtype)
+		if len(found) > 0 {
+			resp.Answer = append(resp.Answer, found...)
+
+			return resp
+		}
+
+		cnames := filterRRs(rrs, dns.TypeCNAME)
+		if len(cnames) == 0 {
+			d.setNegative(resp)
+
+			return resp
+		}
+
+		resp.Answer = append(resp.Answer, cnames[0])
+
+		target := dns.CanonicalName(cnames[0].(*dns.CNAME).Target)
+		if !dns.IsSubDomain(d.origin, target) || d.isDelegated(target) {
+			// The client resolves the rest of the chain itself.
+			return resp
+		}
+
+		name = target
+	}
+
+	return resp
+}
+
+// lookup returns the records of name, including the ones synthesized from a
+// wildcard, if any.  ok is false if name doesn't exist.  rrs are empty for the
+// empty non-terminals.
+func (d *zoneData) lookup(name string) (rrs []dns.RR, ok bool) {
+	if _, ok = d.names[name]; ok {
+		return d.records[name], true
+	}
+
+	// Find the closest encloser and check its wildcard, see RFC 4592.
+	for off, end := 0, false; !end && name != d.origin; {
+		off, end = dns.NextLabel(name, off)
+		encloser := name[off:]
+		if _, ok = d.names[encloser]; !ok {
+			continue
+		}
+
+		wildcard := d.records["*."+encloser]
+		if len(wildcard) == 0 {
+			return nil, false
+		}
+
+		rrs = make([]dns.RR, 0, len(wildcard))
+		for _, rr := range wildcard {
+			rr = dns.Copy(rr)
+			rr.Header().Name = name
+			rrs = append(rrs, rr)
+		}
+
+		return rrs, true
+	}
+
+	return nil, false
+}
+
+// refer sets the referral to the delegated child zone containing name into
+// resp, if there is one.  The NS records at the zone cut are only authoritative
+// in the child zone, except for the DS requests.
+func (d *zoneData) refer(resp *dns.Msg, name string, qtype uint16) (ok bool) {
+	cut := d.delegation(name)
+	if cut == "" || (cut == name && qtype == dns.TypeDS) {
+		return false
+	}
+
+	nss := filterRRs(d.records[cut], dns.TypeNS)
+	resp.Ns = append(resp.Ns, nss...)
+	for _, rr := range nss {
+		target := dns.CanonicalName(rr.(*dns.NS).Ns)
+		if !dns.IsSubDomain(d.origin, target) {
+			continue
+		}
+
+		for _, glue := range d.records[target] {
+			switch glue.(type) {
+			case *dns.A, *dns.AAAA:
+				resp.Extra = append(resp.Extra, glue)
+			default:
+				// Go on.
+			}
+		}
+	}
+
+	return true
+}
+
+// isDelegated returns true if name is within a child zone delegated from d.
+func (d *zoneData) isDelegated(name string) (ok bool) {
+	return d.delegation(name) != ""
+}
+
+// delegation returns the topmost zone cut below the origin of d containing
+// name, if there is one.
+func (d *zoneData) delegation(name string) (cut string) {
+	labels := dns.Split(name)
+	originLabels := dns.CountLabel(d.origin)
+	for i := len(labels) - originLabels - 1; i >= 0; i-- {
+		sub := name[labels[i]:]
+		if len(filterRRs(d.records[sub], dns.TypeNS)) > 0 {
+			return sub
+		}
+	}
+
+	return ""
+}
+
+// setNegative adds the SOA record of d to the authority section of the negative
+// response resp.  Its TTL is the negative caching TTL, see RFC 2308.
+func (d *zoneData) setNegative(resp *dns.Msg) {
+	soa := dns.Copy(d.soa).(*dns.SOA)
+	soa.Hdr.Ttl = min(soa.Hdr.Ttl, soa.Minttl)
+	resp.Ns = append(resp.Ns, soa)
+}
+
+// filterRRs returns the records of qtype from rrs.  All records match
+// [dns.TypeANY].
+func filterRRs(rrs []dns.RR, qtype uint16) (found []dns.RR) {
+	for _, rr := range rrs {
+		if qtype == dns.TypeANY || rr.Header().Rrtype == qtype {
+			found = append(found, rr)
+		}
+	}
+
+	return found
+}
+
+// isNewerSerial returns true if serial b is newer than serial a according to
+// the serial number arithmetic, see RFC 1982.
+func isNewerSerial(a, b uint32) (ok bool) {
+	return int32(b-a) > 0
+}
@@ -0,0 +1,465 @@
+package socketact
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// DNSListeners are the pre-bound DNS sockets.
+type DNSListeners struct {
+	// UDP are the plain DNS datagram sockets.
+	UDP []net.PacketConn
+
+	// TCP are the plain DNS stream sockets.
+	TCP []net.Listener
+
+	// TLS are the DNS-over-TLS stream sockets.
+	TLS []net.Listener
+
+	// QUIC are the DNS-over-QUIC datagram sockets.
+	QUIC []net.PacketConn
+}
+
+// isEmpty returns true if l contains no sockets.
+func (l *DNSListeners) isEmpty() (ok bool) {
+	return len(l.UDP)+len(l.TCP)+len(l.TLS)+len(l.QUIC) == 0
+}
+
+// HasPlain returns true if l contains plain DNS sockets.  l may be nil.
+func (l *DNSListeners) HasPlain() (ok bool) {
+	return l != nil && len(l.UDP)+len(l.TCP) > 0
+}
+
+// HasEncrypted returns true if l contains DNS-over-TLS or DNS-over-QUIC
+// sockets.  l may be nil.
+func (l *DNSListeners) HasEncrypted() (ok bool) {
+	return l != nil && len(l.TLS)+len(l.QUIC) > 0
+}
+
+// DNSHandler handles the DNS request from dctx and sets its response.  If the
+// response is nil, the request is dropped.
+type DNSHandler func(dctx *proxy.DNSContext)
+
+// DNSServerConfig is the configuration of a [DNSServer].
+type DNSServerConfig struct {
+	// Listeners are the sockets to serve.  It must not be nil.  The sockets
+	// are never closed by the server.
+	Listeners *DNSListeners
+
+	// TLSConfig is the TLS configuration for the DNS-over-TLS and
+	// DNS-over-QUIC sockets.  If it's nil, these sockets aren't served.
+	TLSConfig *tls.Config
+
+	// Handler handles the DNS requests.  It must not be nil.
+	Handler DNSHandler
+}
+
+// Timeouts of the stream connections.
+const (
+	// streamIdleTimeout is the maximum duration of waiting for the next
+	// request on a stream connection.
+	streamIdleTimeout = 2 * time.Minute
+
+	// writeTimeout is the maximum duration of writing a response.
+	writeTimeout = 10 * time.Second
+
+	// quicStreamTimeout is the maximum duration of serving a DNS-over-QUIC
+	// stream.
+	quicStreamTimeout = 10 * time.Second
+)
+
+// nextProtoDoQ is the ALPN token of DNS-over-QUIC, see RFC 9250.
+const nextProtoDoQ = "doq"
+
+// DoQ error codes, see RFC 9250.
+const (
+	doqCodeNoError       quic.ApplicationErrorCode = 0
+	doqCodeProtocolError quic.StreamErrorCode      = 2
+)
+
+// DNSServer serves DNS requests over the pre-bound sockets.  Unlike the servers
+// of [proxy.Proxy], it never closes the sockets, so that they can be served
+// again after a reconfiguration.
+type DNSServer struct {
+	listeners *DNSListeners
+	tlsConf   *tls.Config
+	handler   DNSHandler
+
+	// mu protects closers and conns.
+	mu *sync.Mutex
+
+	// closers are the wrapped sockets and the DNS-over-QUIC listeners which
+	// are closed on shutdown.
+	closers []io.Closer
+
+	// conns are the accepted connections which are closed on shutdown.
+	conns map[io.Closer]struct{}
+
+	// wg is used to wait for the serving loops to exit on shutdown, so that
+	// they don't read from the sockets served by the next server.
+	wg *sync.WaitGroup
+}
+
+// NewDNSServer returns a new properly initialized *DNSServer.  c must not be
+// nil.
+func NewDNSServer(c *DNSServerConfig) (srv *DNSServer) {
+	return &DNSServer{
+		listeners: c.Listeners,
+		tlsConf:   c.TLSConfig,
+		handler:   c.Handler,
+		mu:        &sync.Mutex{},
+		conns:     map[io.Closer]struct{}{},
+		wg:        &sync.WaitGroup{},
+	}
+}
+
+// Start starts serving the sockets.
+func (srv *DNSServer) Start() (err error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for _, c := range srv.listeners.UDP {
+		wrapped := newPacketConn(NewSessionConn(c))
+		srv.closers = append(srv.closers, wrapped)
+		srv.serve(func() { srv.serveUDP(wrapped) })
+	}
+
+	for _, l := range srv.listeners.TCP {
+		wrapped := newListener(l)
+		srv.closers = append(srv.closers, wrapped)
+		srv.serve(func() { srv.serveStreams(wrapped, proxy.ProtoTCP) })
+	}
+
+	if srv.tlsConf == nil {
+		if srv.listeners.HasEncrypted() {
+			log.Info("socketact: warning: no tls configuration; not serving encrypted dns sockets")
+		}
+
+		return nil
+	}
+
+	for _, l := range srv.listeners.TLS {
+		wrapped := newListener(l)
+		srv.closers = append(srv.closers, wrapped)
+		tlsListener := tls.NewListener(wrapped, srv.tlsConf)
+		srv.serve(func() { srv.serveStreams(tlsListener, proxy.ProtoTLS) })
+	}
+
+	quicTLSConf := srv.tlsConf.Clone()
+	quicTLSConf.NextProtos = []string{nextProtoDoQ}
+	for _, c := range srv.listeners.QUIC {
+		wrapped := newPacketConn(c)
+
+		var ql *quic.Listener
+		ql, err = quic.Listen(wrapped, quicTLSConf, &quic.Config{
+			MaxIdleTimeout: streamIdleTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("listening quic on %s: %w", c.LocalAddr(), err)
+		}
+
+		// Close the listener before the socket, since the listener resets its
+		// read deadline.
+		srv.closers = append(srv.closers, ql, wrapped)
+		srv.serve(func() { srv.serveQUIC(ql) })
+	}
+
+	return nil
+}
+
+// serve runs the serving loop f in a separate goroutine.
+func (srv *DNSServer) serve(f func()) {
+	srv.wg.Add(1)
+	go func() {
+		defer srv.wg.Done()
+		defer log.OnPanic("socketact: serving dns")
+
+		f()
+	}()
+}
+
+// Shutdown stops serving the sockets without closing them.
+func (srv *DNSServer) Shutdown(_ context.Context) (err error) {
+	srv.mu.Lock()
+
+	var errs []error
+	for _, c := range srv.closers {
+		errs = append(errs, c.Close())
+	}
+
+	for c := range srv.conns {
+		// Don't report the errors from closing the connections, since they may
+		// be closed by the clients at the same time.
+		_ = c.Close()
+	}
+
+	srv.closers = nil
+	clear(srv.conns)
+
+	srv.mu.Unlock()
+
+	srv.wg.Wait()
+
+	return errors.Annotate(errors.Join(errs...), "shutting down activated dns: %w")
+}
+
+// track adds c to the connections closed on shutdown, if add is true, or
+// removes it otherwise.
+func (srv *DNSServer) track(c io.Closer, add bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if add {
+		srv.conns[c] = struct{}{}
+	} else {
+		delete(srv.conns, c)
+	}
+}
+
+// addrPorter is the interface for the addresses of other types that can be
+// converted into [netip.AddrPort], such as the addresses of the clients
+// connected through a proxy.
+type addrPorter interface {
+	AddrPort() (ap netip.AddrPort)
+}
+
+// newDNSContext returns a new DNS context for the request from addr.
+func newDNSContext(proto proxy.Proto, req *dns.Msg, addr net.Addr) (dctx *proxy.DNSContext) {
+	ap := netutil.NetAddrToAddrPort(addr)
+	if a, ok := addr.(addrPorter); ok && !ap.IsValid() {
+		ap = a.AddrPort()
+	}
+
+	return &proxy.DNSContext{
+		Proto:     proto,
+		Req:       req,
+		Addr:      ap,
+		RequestID: rand.Uint64(),
+	}
+}
+
+// serveUDP serves plain DNS requests from c until it's closed.
+func (srv *DNSServer) serveUDP(c net.PacketConn) {
+	buf := make([]byte, dns.MaxMsgSize)
+	for {
+		n, addr, err := c.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			log.Debug("socketact: reading udp: %s", err)
+
+			continue
+		}
+
+		req := &dns.Msg{}
+		err = req.Unpack(buf[:n])
+		if err != nil {
+			log.Debug("socketact: unpacking udp request from %s: %s", addr, err)
+
+			continue
+		}
+
+		go srv.handleUDP(c, req, addr)
+	}
+}
+
+// handleUDP handles a single plain DNS request received over UDP.
+func (srv *DNSServer) handleUDP(c net.PacketConn, req *dns.Msg, addr net.Addr) {
+	defer log.OnPanic("socketact: handling udp")
+
+	dctx := newDNSContext(proxy.ProtoUDP, req, addr)
+	srv.handler(dctx)
+
+	resp := dctx.Res
+	if resp == nil {
+		return
+	}
+
+	size := dns.MinMsgSize
+	if opt := req.IsEdns0(); opt != nil {
+		size = int(opt.UDPSize())
+	}
+
+	resp.Truncate(size)
+
+	b, err := resp.Pack()
+	if err != nil {
+		log.Debug("socketact: packing udp response to %s: %s", addr, err)
+
+		return
+	}
+
+	_, err = c.WriteTo(b, addr)
+	if err != nil {
+		log.Debug("socketact: writing udp response to %s: %s", addr, err)
+	}
+}
+
+// serveStreams accepts the connections from l until it's closed.
+func (srv *DNSServer) serveStreams(l net.Listener, proto proxy.Proto) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			log.Debug("socketact: accepting %s: %s", proto, err)
+
+			continue
+		}
+
+		srv.track(conn, true)
+		go srv.serveStream(conn, proto)
+	}
+}
+
+// serveStream serves DNS requests from a single stream connection.
+func (srv *DNSServer) serveStream(conn net.Conn, proto proxy.Proto) {
+	defer log.OnPanic("socketact: serving stream")
+	defer func() {
+		srv.track(conn, false)
+		_ = conn.Close()
+	}()
+
+	dnsConn := &dns.Conn{Conn: conn}
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(streamIdleTimeout))
+		req, err := dnsConn.ReadMsg()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Debug("socketact: reading %s request: %s", proto, err)
+			}
+
+			return
+		}
+
+		dctx := newDNSContext(proto, req, conn.RemoteAddr())
+		dctx.Conn = conn
+		srv.handler(dctx)
+
+		if dctx.Res == nil {
+			return
+		}
+
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		err = dnsConn.WriteMsg(dctx.Res)
+		if err != nil {
+			log.Debug("socketact: writing %s response: %s", proto, err)
+
+			return
+		}
+	}
+}
+
+// serveQUIC accepts the DNS-over-QUIC connections from l until it's closed.
+func (srv *DNSServer) serveQUIC(l *quic.Listener) {
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			if !errors.Is(err, quic.ErrServerClosed) {
+				log.Debug("socketact: accepting quic: %s", err)
+			}
+
+			return
+		}
+
+		qc := quicCloser{conn: conn}
+		srv.track(qc, true)
+		go srv.serveQUICConn(qc)
+	}
+}
+
+// quicCloser is an [io.Closer] closing a QUIC connection without an error.
+type quicCloser struct {
+	conn quic.Connection
+}
+
+// type check
+var _ io.Closer = quicCloser{}
+
+// Close implements the [io.Closer] interface for quicCloser.
+func (c quicCloser) Close() (err error) {
+	return c.conn.CloseWithError(doqCodeNoError, "")
+}
+
+// serveQUICConn serves the streams of a single DNS-over-QUIC connection.
+func (srv *DNSServer) serveQUICConn(qc quicCloser) {
+	defer log.OnPanic("socketact: serving quic conn")
+	defer srv.track(qc, false)
+
+	for {
+		stream, err := qc.conn.AcceptStream(context.Background())
+		if err != nil {
+			// The connection is closed.
+			return
+		}
+
+		go srv.serveQUICStream(qc.conn, stream)
+	}
+}
+
+// serveQUICStream serves a single DNS-over-QUIC request.  Each request is
+// prefixed with its length and sent over a separate stream, see RFC 9250.
+func (srv *DNSServer) serveQUICStream(conn quic.Connection, stream quic.Stream) {
+	defer log.OnPanic("socketact: serving quic stream")
+	defer func() { _ = stream.Close() }()
+
+	_ = stream.SetDeadline(time.Now().Add(quicStreamTimeout))
+
+	buf, err := io.ReadAll(io.LimitReader(stream, dns.MaxMsgSize+2))
+	if err != nil || len(buf) < 2 || int(binary.BigEndian.Uint16(buf)) != len(buf)-2 {
+		stream.CancelRead(doqCodeProtocolError)
+
+		return
+	}
+
+	req := &dns.Msg{}
+	err = req.Unpack(buf[2:])
+	if err != nil {
+		stream.CancelRead(doqCodeProtocolError)
+
+		return
+	}
+
+	dctx := newDNSContext(proxy.ProtoQUIC, req, conn.RemoteAddr())
+	dctx.QUICConnection = conn
+	dctx.QUICStream = stream
+	srv.handler(dctx)
+
+	if dctx.Res == nil {
+		return
+	}
+
+	b, err := dctx.Res.Pack()
+	if err != nil {
+		log.Debug("socketact: packing quic response: %s", err)
+
+		return
+	}
+
+	out := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(b)), uint16(len(b)))
+	out = append(out, b...)
+
+	_, err = stream.Write(out)
+	if err != nil {
+		log.Debug("socketact: writing quic response: %s", err)
+	}
+}
@@ -0,0 +1,84 @@
+package socketact_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/socketact"
+	"github.com/AdguardTeam/dnsproxy/proxy"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
+
+func TestDNSServer(t *testing.T) {
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, udpConn.Close)
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, tcpListener.Close)
+
+	protos := make(chan proxy.Proto, 1)
+	handler := func(dctx *proxy.DNSContext) {
+		protos <- dctx.Proto
+
+		dctx.Res = (&dns.Msg{}).SetReply(dctx.Req)
+	}
+
+	ls := &socketact.DNSListeners{
+		UDP: []net.PacketConn{udpConn},
+		TCP: []net.Listener{tcpListener},
+	}
+
+	req := (&dns.Msg{}).SetQuestion("example.org.", dns.TypeA)
+
+	// Serve the same sockets twice to make sure they aren't closed on
+	// shutdown.
+	for range 2 {
+		srv := socketact.NewDNSServer(&socketact.DNSServerConfig{
+			Listeners: ls,
+			TLSConfig: nil,
+			Handler:   handler,
+		})
+
+		err = srv.Start()
+		require.NoError(t, err)
+
+		testCases := []struct {
+			addr      net.Addr
+			network   string
+			wantProto proxy.Proto
+		}{{
+			addr:      udpConn.LocalAddr(),
+			network:   "udp",
+			wantProto: proxy.ProtoUDP,
+		}, {
+			addr:      tcpListener.Addr(),
+			network:   "tcp",
+			wantProto: proxy.ProtoTCP,
+		}}
+
+		for _, tc := range testCases {
+			cli := &dns.Client{Net: tc.network, Timeout: testTimeout}
+			resp, _, exchErr := cli.Exchange(req, tc.addr.String())
+			require.NoError(t, exchErr)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, req.Id, resp.Id)
+			assert.Equal(t, tc.wantProto, <-protos)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		err = srv.Shutdown(ctx)
+		cancel()
+		require.NoError(t, err)
+	}
+}
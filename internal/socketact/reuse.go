@@ -0,0 +1,114 @@
+package socketact
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// deadlineSetter is the interface for the listeners supporting deadlines, such
+// as *net.TCPListener.
+type deadlineSetter interface {
+	SetDeadline(t time.Time) (err error)
+}
+
+// listener is a [net.Listener] which doesn't close the underlying socket.
+// Instead, it makes the pending and the following calls to Accept fail.
+type listener struct {
+	net.Listener
+
+	closed atomic.Bool
+}
+
+// newListener returns a new listener wrapping l.  It clears the deadline of l,
+// which may have been set by the previous wrapper.
+func newListener(l net.Listener) (wrapped *listener) {
+	if d, ok := l.(deadlineSetter); ok {
+		_ = d.SetDeadline(time.Time{})
+	}
+
+	return &listener{
+		Listener: l,
+	}
+}
+
+// type check
+var _ net.Listener = (*listener)(nil)
+
+// Accept implements the [net.Listener] interface for *listener.
+func (l *listener) Accept() (c net.Conn, err error) {
+	c, err = l.Listener.Accept()
+	if err != nil && l.closed.Load() {
+		return nil, net.ErrClosed
+	}
+
+	return c, err
+}
+
+// Close implements the [net.Listener] interface for *listener.  If the
+// underlying listener doesn't support deadlines, it's closed.
+func (l *listener) Close() (err error) {
+	if l.closed.Swap(true) {
+		return nil
+	}
+
+	d, ok := l.Listener.(deadlineSetter)
+	if !ok {
+		return l.Listener.Close()
+	}
+
+	return d.SetDeadline(time.Now())
+}
+
+// type check
+var _ deadlineSetter = (*listener)(nil)
+
+// SetDeadline implements the [deadlineSetter] interface for *listener.
+func (l *listener) SetDeadline(t time.Time) (err error) {
+	d, ok := l.Listener.(deadlineSetter)
+	if !ok {
+		return nil
+	}
+
+	return d.SetDeadline(t)
+}
+
+// packetConn is a [net.PacketConn] which doesn't close the underlying socket.
+// Instead, it makes the pending and the following calls to ReadFrom fail.
+type packetConn struct {
+	net.PacketConn
+
+	closed atomic.Bool
+}
+
+// newPacketConn returns a new packetConn wrapping c.  It clears the read
+// deadline of c, which may have been set by the previous wrapper.
+func newPacketConn(c net.PacketConn) (wrapped *packetConn) {
+	_ = c.SetReadDeadline(time.Time{})
+
+	return &packetConn{
+		PacketConn: c,
+	}
+}
+
+// type check
+var _ net.PacketConn = (*packetConn)(nil)
+
+// ReadFrom implements the [net.PacketConn] interface for *packetConn.
+func (c *packetConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	n, addr, err = c.PacketConn.ReadFrom(b)
+	if err != nil && c.closed.Load() {
+		return 0, nil, net.ErrClosed
+	}
+
+	return n, addr, err
+}
+
+// Close implements the [net.PacketConn] interface for *packetConn.
+func (c *packetConn) Close() (err error) {
+	if c.closed.Swap(true) {
+		return nil
+	}
+
+	return c.PacketConn.SetReadDeadline(time.Now())
+}
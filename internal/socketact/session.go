@@ -0,0 +1,168 @@
+package socketact
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// sessionConn is a [net.PacketConn] which receives the local address of each
+// datagram from the control messages and sends the responses from the same
+// address.  It's necessary for the sockets bound to the unspecified addresses,
+// since otherwise the responses may be sent from an address other than the one
+// the client has sent the request to.
+type sessionConn struct {
+	*net.UDPConn
+}
+
+// NewSessionConn returns c wrapped to send the responses from the local
+// addresses the requests have been received on, if c is a UDP socket bound to
+// an unspecified address and the system supports that.  Otherwise, it returns
+// c as is.  The addresses returned by ReadFrom of the wrapped connection
+// implement the AddrPort method and must be passed to its WriteTo as is.
+//
+// [DNSServer] wraps its UDP sockets automatically, so it's only needed for the
+// sockets wrapped by other types before being passed to the server.
+func NewSessionConn(c net.PacketConn) (wrapped net.PacketConn) {
+	uc, ok := c.(*net.UDPConn)
+	if !ok {
+		return c
+	}
+
+	laddr, ok := uc.LocalAddr().(*net.UDPAddr)
+	if !ok || !laddr.IP.IsUnspecified() {
+		return c
+	}
+
+	err := setControlMessages(uc)
+	if err != nil {
+		log.Debug("socketact: receiving local addresses on %s: %s", laddr, err)
+
+		return c
+	}
+
+	return &sessionConn{
+		UDPConn: uc,
+	}
+}
+
+// Control message flags used to receive the local addresses of datagrams.
+const (
+	ctrlFlags4 = ipv4.FlagDst | ipv4.FlagInterface
+	ctrlFlags6 = ipv6.FlagDst | ipv6.FlagInterface
+)
+
+// oobSize is the size of the buffer for the control messages of a received
+// datagram.
+var oobSize = max(
+	len(ipv4.NewControlMessage(ctrlFlags4)),
+	len(ipv6.NewControlMessage(ctrlFlags6)),
+)
+
+// setControlMessages makes c receive the local addresses of the datagrams.
+// Dual-stack sockets receive them for both families, so it only fails if
+// neither family is supported.
+func setControlMessages(c *net.UDPConn) (err error) {
+	err6 := ipv6.NewPacketConn(c).SetControlMessage(ctrlFlags6, true)
+	err4 := ipv4.NewPacketConn(c).SetControlMessage(ctrlFlags4, true)
+	if err6 != nil && err4 != nil {
+		return errors.Join(err4, err6)
+	}
+
+	return nil
+}
+
+// type check
+var _ net.PacketConn = (*sessionConn)(nil)
+
+// ReadFrom implements the [net.PacketConn] interface for *sessionConn.  addr
+// is a *sessionAddr.
+func (c *sessionConn) ReadFrom(b []byte) (n int, addr net.Addr, err error) {
+	oob := make([]byte, oobSize)
+	n, oobn, _, remote, err := c.ReadMsgUDPAddrPort(b, oob)
+	if err != nil {
+		return n, nil, err
+	}
+
+	return n, &sessionAddr{
+		remote: remote,
+		local:  localAddrFromOOB(oob[:oobn]),
+	}, nil
+}
+
+// WriteTo implements the [net.PacketConn] interface for *sessionConn.
+func (c *sessionConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	sa, ok := addr.(*sessionAddr)
+	if !ok {
+		return c.UDPConn.WriteTo(b, addr)
+	}
+
+	n, _, err = c.WriteMsgUDPAddrPort(b, oobWithSrc(sa.local), sa.remote)
+
+	return n, err
+}
+
+// localAddrFromOOB returns the local address from the control messages of a
+// received datagram.  ip is invalid if there is none.
+func localAddrFromOOB(oob []byte) (ip netip.Addr) {
+	cm6 := &ipv6.ControlMessage{}
+	if cm6.Parse(oob) == nil && cm6.Dst != nil {
+		ip, _ = netip.AddrFromSlice(cm6.Dst)
+
+		return ip
+	}
+
+	cm4 := &ipv4.ControlMessage{}
+	if cm4.Parse(oob) == nil && cm4.Dst != nil {
+		ip, _ = netip.AddrFromSlice(cm4.Dst)
+
+		return ip
+	}
+
+	return netip.Addr{}
+}
+
+// oobWithSrc returns the control message setting the source address of an
+// outgoing datagram to ip.  oob is nil if ip is invalid.
+func oobWithSrc(ip netip.Addr) (oob []byte) {
+	switch {
+	case !ip.IsValid():
+		return nil
+	case ip.Is4(), ip.Is4In6():
+		return (&ipv4.ControlMessage{Src: ip.Unmap().AsSlice()}).Marshal()
+	default:
+		return (&ipv6.ControlMessage{Src: ip.AsSlice()}).Marshal()
+	}
+}
+
+// sessionAddr is the address of the client which has sent a datagram along
+// with the local address the datagram has been received on.
+type sessionAddr struct {
+	// remote is the address of the client.
+	remote netip.AddrPort
+
+	// local is the local address of the datagram.  It's invalid if unknown.
+	local netip.Addr
+}
+
+// type check
+var _ net.Addr = (*sessionAddr)(nil)
+
+// Network implements the [net.Addr] interface for *sessionAddr.
+func (a *sessionAddr) Network() (n string) {
+	return "udp"
+}
+
+// String implements the [net.Addr] interface for *sessionAddr.
+func (a *sessionAddr) String() (s string) {
+	return a.remote.String()
+}
+
+// AddrPort returns the address of the client.
+func (a *sessionAddr) AddrPort() (ap netip.AddrPort) {
+	return a.remote
+}
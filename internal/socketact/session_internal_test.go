@@ -0,0 +1,64 @@
+package socketact
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSessionConn(t *testing.T) {
+	uc, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = uc.Close() })
+
+	c := NewSessionConn(uc)
+	require.IsType(t, (*sessionConn)(nil), c)
+
+	port := uint16(uc.LocalAddr().(*net.UDPAddr).Port)
+	srvAddr := netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), port)
+
+	client, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(srvAddr))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	_, err = client.Write([]byte("req"))
+	require.NoError(t, err)
+
+	require.NoError(t, c.SetDeadline(time.Now().Add(time.Second)))
+
+	buf := make([]byte, 16)
+	n, addr, err := c.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Equal(t, "req", string(buf[:n]))
+
+	sa, ok := addr.(*sessionAddr)
+	require.True(t, ok)
+
+	assert.Equal(t, netip.MustParseAddr("127.0.0.1"), sa.local.Unmap())
+	assert.Equal(t, client.LocalAddr().String(), sa.String())
+
+	_, err = c.WriteTo([]byte("resp"), addr)
+	require.NoError(t, err)
+
+	require.NoError(t, client.SetDeadline(time.Now().Add(time.Second)))
+
+	n, from, err := client.ReadFromUDPAddrPort(buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "resp", string(buf[:n]))
+	assert.Equal(t, srvAddr, from)
+}
+
+func TestNewSessionConn_specified(t *testing.T) {
+	uc, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = uc.Close() })
+
+	// The sockets bound to the specified addresses already send the
+	// responses from the right address.
+	assert.Same(t, uc, NewSessionConn(uc))
+}
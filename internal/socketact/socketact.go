@@ -0,0 +1,269 @@
+// Package socketact implements receiving pre-bound sockets from a service
+// manager, such as systemd, using the socket activation protocol.
+//
+// See https://www.freedesktop.org/software/systemd/man/latest/sd_listen_fds.html.
+package socketact
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// Socket names.  These are the values of the FileDescriptorName setting of the
+// systemd socket units.
+const (
+	// NameDNS is the name of the plain DNS sockets.  Both datagram and stream
+	// sockets are accepted.
+	NameDNS = "dns"
+
+	// NameDNSOverTLS is the name of the DNS-over-TLS stream sockets.
+	NameDNSOverTLS = "dns-tls"
+
+	// NameDNSOverQUIC is the name of the DNS-over-QUIC datagram sockets.
+	NameDNSOverQUIC = "dns-quic"
+
+	// NameWeb is the name of the plain HTTP stream sockets of the web
+	// interface.
+	NameWeb = "web"
+
+	// NameWebHTTPS is the name of the HTTPS stream sockets of the web
+	// interface.
+	NameWebHTTPS = "web-https"
+)
+
+// Environment variables of the socket activation protocol.
+const (
+	envListenPID     = "LISTEN_PID"
+	envListenFDs     = "LISTEN_FDS"
+	envListenFDNames = "LISTEN_FDNAMES"
+)
+
+// listenFDsStart is the first file descriptor passed by the service manager.
+const listenFDsStart = 3
+
+// unknownName is the name of the sockets which have no explicit name, as
+// reported by sd_listen_fds_with_names.
+const unknownName = "unknown"
+
+// Sockets are the sockets passed by the service manager, grouped by their
+// names.  A nil *Sockets is valid and contains no sockets.
+type Sockets struct {
+	listeners   map[string][]net.Listener
+	packetConns map[string][]net.PacketConn
+}
+
+// FromEnv returns the sockets passed to the current process by the service
+// manager.  s is nil if there are none.  FromEnv unsets the environment
+// variables of the protocol, so that they aren't inherited by the child
+// processes.  It must only be called once.
+func FromEnv() (s *Sockets, err error) {
+	defer func() {
+		for _, k := range []string{envListenPID, envListenFDs, envListenFDNames} {
+			_ = os.Unsetenv(k)
+		}
+	}()
+
+	names, err := parseEnv(
+		os.Getenv(envListenPID),
+		os.Getenv(envListenFDs),
+		os.Getenv(envListenFDNames),
+		os.Getpid(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	} else if len(names) == 0 {
+		return nil, nil
+	}
+
+	files := make([]*os.File, 0, len(names))
+	for i, name := range names {
+		files = append(files, os.NewFile(uintptr(listenFDsStart+i), name))
+	}
+
+	return newSockets(files, names)
+}
+
+// parseEnv parses the values of the protocol environment variables and returns
+// the names of the passed sockets in the order of their file descriptors.
+// names is empty if the sockets weren't passed to the process with the given
+// pid.
+func parseEnv(pidStr, fdsStr, namesStr string, pid int) (names []string, err error) {
+	if fdsStr == "" {
+		return nil, nil
+	}
+
+	if pidStr != "" {
+		var listenPID int
+		listenPID, err = strconv.Atoi(pidStr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", envListenPID, err)
+		} else if listenPID != pid {
+			// The sockets are meant for another process.
+			return nil, nil
+		}
+	}
+
+	n, err := strconv.Atoi(fdsStr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", envListenFDs, err)
+	} else if n < 0 {
+		return nil, fmt.Errorf("%s: negative value %d", envListenFDs, n)
+	}
+
+	if namesStr == "" {
+		names = make([]string, n)
+		for i := range names {
+			names[i] = unknownName
+		}
+
+		return names, nil
+	}
+
+	names = strings.Split(namesStr, ":")
+	if len(names) != n {
+		return nil, fmt.Errorf(
+			"%s: got %d names, want %d",
+			envListenFDNames,
+			len(names),
+			n,
+		)
+	}
+
+	return names, nil
+}
+
+// newSockets returns the sockets created from files with the corresponding
+// names.  The files are closed, since the sockets use the duplicated file
+// descriptors.
+func newSockets(files []*os.File, names []string) (s *Sockets, err error) {
+	s = &Sockets{
+		listeners:   map[string][]net.Listener{},
+		packetConns: map[string][]net.PacketConn{},
+	}
+
+	var errs []error
+	for i, f := range files {
+		name := names[i]
+		err = s.add(f, name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("socket %d named %q: %w", i, name, err))
+		}
+
+		err = f.Close()
+		if err != nil {
+			log.Debug("socketact: closing file of socket %q: %s", name, err)
+		}
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return nil, fmt.Errorf("socket activation: %w", err)
+	}
+
+	return s, nil
+}
+
+// add adds the socket from f to s using the socket type to distinguish the
+// stream sockets from the datagram ones.
+func (s *Sockets) add(f *os.File, name string) (err error) {
+	l, lErr := net.FileListener(f)
+	if lErr == nil {
+		s.listeners[name] = append(s.listeners[name], l)
+
+		log.Debug("socketact: got stream socket %q at %s", name, l.Addr())
+
+		return nil
+	}
+
+	c, cErr := net.FilePacketConn(f)
+	if cErr == nil {
+		s.packetConns[name] = append(s.packetConns[name], c)
+
+		log.Debug("socketact: got datagram socket %q at %s", name, c.LocalAddr())
+
+		return nil
+	}
+
+	return errors.Join(lErr, cErr)
+}
+
+// Names returns the sorted names of all sockets in s.
+func (s *Sockets) Names() (names []string) {
+	if s == nil {
+		return nil
+	}
+
+	for name := range s.listeners {
+		names = append(names, name)
+	}
+
+	for name := range s.packetConns {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Listeners returns the stream sockets with the given name.  Closing the
+// returned listeners doesn't close the underlying sockets, so that the next
+// call to Listeners returns the listeners which can be served again.  The
+// previously returned listeners must be closed by then.
+func (s *Sockets) Listeners(name string) (ls []net.Listener) {
+	if s == nil {
+		return nil
+	}
+
+	for _, l := range s.listeners[name] {
+		ls = append(ls, newListener(l))
+	}
+
+	return ls
+}
+
+// PacketConns returns the datagram sockets with the given name.  Closing the
+// returned connections doesn't close the underlying sockets, see
+// [Sockets.Listeners].
+func (s *Sockets) PacketConns(name string) (cs []net.PacketConn) {
+	if s == nil {
+		return nil
+	}
+
+	for _, c := range s.packetConns[name] {
+		cs = append(cs, newPacketConn(c))
+	}
+
+	return cs
+}
+
+// DNSListeners returns the DNS sockets from s.  l is nil if there are none.
+// The returned sockets must not be closed, they are meant to be served by a
+// [DNSServer], which wraps them itself.
+func (s *Sockets) DNSListeners() (l *DNSListeners) {
+	if s == nil {
+		return nil
+	}
+
+	l = &DNSListeners{
+		UDP:  slices.Clone(s.packetConns[NameDNS]),
+		TCP:  slices.Clone(s.listeners[NameDNS]),
+		TLS:  slices.Clone(s.listeners[NameDNSOverTLS]),
+		QUIC: slices.Clone(s.packetConns[NameDNSOverQUIC]),
+	}
+
+	if l.isEmpty() {
+		return nil
+	}
+
+	return l
+}
@@ -0,0 +1,148 @@
+package socketact
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnv(t *testing.T) {
+	const pid = 1234
+
+	testCases := []struct {
+		name       string
+		pidStr     string
+		fdsStr     string
+		namesStr   string
+		wantErrMsg string
+		want       []string
+	}{{
+		name:       "not_activated",
+		pidStr:     "",
+		fdsStr:     "",
+		namesStr:   "",
+		wantErrMsg: "",
+		want:       nil,
+	}, {
+		name:       "named",
+		pidStr:     "1234",
+		fdsStr:     "3",
+		namesStr:   "dns:dns:web",
+		wantErrMsg: "",
+		want:       []string{NameDNS, NameDNS, NameWeb},
+	}, {
+		name:       "unnamed",
+		pidStr:     "1234",
+		fdsStr:     "2",
+		namesStr:   "",
+		wantErrMsg: "",
+		want:       []string{unknownName, unknownName},
+	}, {
+		name:       "other_pid",
+		pidStr:     "4321",
+		fdsStr:     "1",
+		namesStr:   "dns",
+		wantErrMsg: "",
+		want:       nil,
+	}, {
+		name:       "bad_fds",
+		pidStr:     "1234",
+		fdsStr:     "many",
+		namesStr:   "",
+		wantErrMsg: `parsing LISTEN_FDS: strconv.Atoi: parsing "many": invalid syntax`,
+		want:       nil,
+	}, {
+		name:       "names_mismatch",
+		pidStr:     "1234",
+		fdsStr:     "2",
+		namesStr:   "dns",
+		wantErrMsg: "LISTEN_FDNAMES: got 1 names, want 2",
+		want:       nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			names, err := parseEnv(tc.pidStr, tc.fdsStr, tc.namesStr, pid)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.want, names)
+		})
+	}
+}
+
+func TestNewSockets(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, l.Close)
+
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, c.Close)
+
+	lf, err := l.(*net.TCPListener).File()
+	require.NoError(t, err)
+
+	cf, err := c.(*net.UDPConn).File()
+	require.NoError(t, err)
+
+	s, err := newSockets([]*os.File{lf, cf}, []string{NameWeb, NameDNS})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{NameDNS, NameWeb}, s.Names())
+
+	ls := s.Listeners(NameWeb)
+	require.Len(t, ls, 1)
+
+	assert.Equal(t, l.Addr().String(), ls[0].Addr().String())
+	assert.Empty(t, s.Listeners(NameDNS))
+
+	cs := s.PacketConns(NameDNS)
+	require.Len(t, cs, 1)
+
+	assert.Equal(t, c.LocalAddr().String(), cs[0].LocalAddr().String())
+	assert.Nil(t, s.DNSListeners().TCP)
+
+	var nilSockets *Sockets
+	assert.Nil(t, nilSockets.DNSListeners())
+	assert.Empty(t, nilSockets.Names())
+}
+
+func TestSockets_Listeners_reuse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, l.Close)
+
+	s := &Sockets{
+		listeners: map[string][]net.Listener{NameWeb: {l}},
+	}
+
+	for i := range 2 {
+		ls := s.Listeners(NameWeb)
+		require.Len(t, ls, 1)
+
+		wrapped := ls[0]
+		accepted := make(chan error, 1)
+		go func() {
+			conn, acceptErr := wrapped.Accept()
+			if conn != nil {
+				_ = conn.Close()
+			}
+
+			accepted <- acceptErr
+		}()
+
+		conn, dialErr := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, dialErr, "iteration %d", i)
+		require.NoError(t, <-accepted, "iteration %d", i)
+		require.NoError(t, conn.Close())
+
+		require.NoError(t, wrapped.Close())
+
+		_, err = wrapped.Accept()
+		require.ErrorIs(t, err, net.ErrClosed)
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/aghalg"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghhttp"
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
-	"github.com/AdguardTeam/golibs/log"
 	"github.com/AdguardTeam/golibs/timeutil"
 )
 
@@ -32,6 +31,9 @@ type StatsResp struct {
 	TopUpstreamsResponses []topAddrs      `json:"top_upstreams_responses"`
 	TopUpstreamsAvgTime   []topAddrsFloat `json:"top_upstreams_avg_time"`
 
+	TopCountries []topAddrs `json:"top_countries"`
+	TopASNs      []topAddrs `json:"top_asns"`
+
 	DNSQueries []uint64 `json:"dns_queries"`
 
 	BlockedFiltering     []uint64 `json:"blocked_filtering"`
@@ -43,6 +45,8 @@ type StatsResp struct {
 	NumReplacedSafebrowsing uint64 `json:"num_replaced_safebrowsing"`
 	NumReplacedSafesearch   uint64 `json:"num_replaced_safesearch"`
 	NumReplacedParental     uint64 `json:"num_replaced_parental"`
+	NumRRLDropped           uint64 `json:"num_rrl_dropped"`
+	NumRRLSlipped           uint64 `json:"num_rrl_slipped"`
 
 	AvgProcessingTime float64 `json:"avg_processing_time"`
 }
@@ -62,7 +66,7 @@ func (s *StatsCtx) handleStats(w http.ResponseWriter, r *http.Request) {
 		resp, ok = s.getData(uint32(s.limit.Hours()))
 	}()
 
-	log.Debug("stats: prepared data in %v", time.Since(start))
+	s.logger.Debug("prepared data", "elapsed", time.Since(start))
 
 	if !ok {
 		// Don't bring the message to the lower case since it's a part of UI
@@ -74,6 +74,8 @@ func TestStats(t *testing.T) {
 	t.Run("data", func(t *testing.T) {
 		const reqDomain = "domain"
 		const respUpstream = "upstream"
+		const respCountry = "DE"
+		const respASN = "AS3320"
 
 		entries := []*stats.Entry{{
 			Domain:         reqDomain,
@@ -89,6 +91,9 @@ func TestStats(t *testing.T) {
 			ProcessingTime: time.Microsecond * 123456,
 			Upstream:       respUpstream,
 			UpstreamTime:   time.Microsecond * 222222,
+			Countries:      []string{respCountry},
+			ASNs:           []string{respASN},
+			RRLSlipped:     true,
 		}}
 
 		wantData := &stats.StatsResp{
@@ -98,6 +103,8 @@ func TestStats(t *testing.T) {
 			TopBlocked:            []map[string]uint64{0: {reqDomain: 1}},
 			TopUpstreamsResponses: []map[string]uint64{0: {respUpstream: 2}},
 			TopUpstreamsAvgTime:   []map[string]float64{0: {respUpstream: 0.222222}},
+			TopCountries:          []map[string]uint64{0: {respCountry: 1}},
+			TopASNs:               []map[string]uint64{0: {respASN: 1}},
 			DNSQueries: []uint64{
 				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
 				0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
@@ -119,6 +126,8 @@ func TestStats(t *testing.T) {
 			NumReplacedSafebrowsing: 0,
 			NumReplacedSafesearch:   0,
 			NumReplacedParental:     0,
+			NumRRLDropped:           0,
+			NumRRLSlipped:           1,
 			AvgProcessingTime:       0.123456,
 		}
 
@@ -152,6 +161,8 @@ func TestStats(t *testing.T) {
 			TopBlocked:            []map[string]uint64{},
 			TopUpstreamsResponses: []map[string]uint64{},
 			TopUpstreamsAvgTime:   []map[string]float64{},
+			TopCountries:          []map[string]uint64{},
+			TopASNs:               []map[string]uint64{},
 			DNSQueries:            _24zeroes[:],
 			BlockedFiltering:      _24zeroes[:],
 			ReplacedSafebrowsing:  _24zeroes[:],
@@ -10,7 +10,7 @@ import (
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
 	"github.com/AdguardTeam/golibs/errors"
-	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/logutil/slogutil"
 	"go.etcd.io/bbolt"
 	"golang.org/x/exp/maps"
 )
@@ -24,6 +24,10 @@ const (
 
 	// maxUpstreams is the max number of top upstreams to return.
 	maxUpstreams = 100
+
+	// maxGeo is the max number of top countries and autonomous systems to
+	// return.
+	maxGeo = 100
 )
 
 // UnitIDGenFunc is the signature of a function that generates a unique ID for
@@ -74,6 +78,22 @@ type Entry struct {
 
 	// UpstreamTime is the duration of the successful request to the upstream.
 	UpstreamTime time.Duration
+
+	// Countries are the unique ISO 3166-1 alpha-2 codes of the countries of
+	// the addresses in the answer.
+	Countries []string
+
+	// ASNs are the unique autonomous systems of the addresses in the answer in
+	// the "AS<number>" format.
+	ASNs []string
+
+	// RRLDropped is true if the response has been dropped by the response rate
+	// limiting.
+	RRLDropped bool
+
+	// RRLSlipped is true if the response has been replaced with a truncated
+	// one by the response rate limiting.
+	RRLSlipped bool
 }
 
 // validate returns an error if entry is not valid.
@@ -111,6 +131,14 @@ type unit struct {
 	// microseconds to each upstream.
 	upstreamsTimeSum map[string]uint64
 
+	// countries stores the number of responses with addresses from each
+	// country.
+	countries map[string]uint64
+
+	// asns stores the number of responses with addresses from each autonomous
+	// system.
+	asns map[string]uint64
+
 	// nResult stores the number of requests grouped by it's result.
 	nResult []uint64
 
@@ -127,6 +155,14 @@ type unit struct {
 	// timeSum stores the sum of processing time in microseconds of each request
 	// written by the unit.
 	timeSum uint64
+
+	// rrlDropped stores the number of responses dropped by the response rate
+	// limiting.
+	rrlDropped uint64
+
+	// rrlSlipped stores the number of responses truncated by the response rate
+	// limiting.
+	rrlSlipped uint64
 }
 
 // newUnit allocates the new *unit.
@@ -137,6 +173,8 @@ func newUnit(id uint32) (u *unit) {
 		clients:            map[string]uint64{},
 		upstreamsResponses: map[string]uint64{},
 		upstreamsTimeSum:   map[string]uint64{},
+		countries:          map[string]uint64{},
+		asns:               map[string]uint64{},
 		nResult:            make([]uint64, resultLast),
 		id:                 id,
 	}
@@ -173,9 +211,24 @@ type unitDB struct {
 	// responses from each upstream.
 	UpstreamsTimeSum []countPair
 
+	// Countries is the number of responses with addresses from each country.
+	Countries []countPair
+
+	// ASNs is the number of responses with addresses from each autonomous
+	// system.
+	ASNs []countPair
+
 	// NTotal is the total number of requests.
 	NTotal uint64
 
+	// RRLDropped is the number of responses dropped by the response rate
+	// limiting.
+	RRLDropped uint64
+
+	// RRLSlipped is the number of responses truncated by the response rate
+	// limiting.
+	RRLSlipped uint64
+
 	// TimeAvg is the average of processing times in microseconds of all the
 	// requests in the unit.
 	TimeAvg uint32
@@ -273,17 +326,23 @@ func (u *unit) serialize() (udb *unitDB) {
 		Clients:            convertMapToSlice(u.clients, maxClients),
 		UpstreamsResponses: convertMapToSlice(u.upstreamsResponses, maxUpstreams),
 		UpstreamsTimeSum:   convertMapToSlice(u.upstreamsTimeSum, maxUpstreams),
+		Countries:          convertMapToSlice(u.countries, maxGeo),
+		ASNs:               convertMapToSlice(u.asns, maxGeo),
+		RRLDropped:         u.rrlDropped,
+		RRLSlipped:         u.rrlSlipped,
 		TimeAvg:            timeAvg,
 	}
 }
 
-func loadUnitFromDB(tx *bbolt.Tx, id uint32) (udb *unitDB) {
+// loadUnitFromDB loads the unit with id from the database.  udb is nil if there
+// is no such unit or it can't be decoded.
+func (s *StatsCtx) loadUnitFromDB(tx *bbolt.Tx, id uint32) (udb *unitDB) {
 	bkt := tx.Bucket(idToUnitName(id))
 	if bkt == nil {
 		return nil
 	}
 
-	log.Tracef("Loading unit %d", id)
+	s.logger.Debug("loading unit", "id", id)
 
 	var buf bytes.Buffer
 	buf.Write(bkt.Get([]byte{0}))
@@ -291,7 +350,7 @@ func loadUnitFromDB(tx *bbolt.Tx, id uint32) (udb *unitDB) {
 
 	err := gob.NewDecoder(&buf).Decode(udb)
 	if err != nil {
-		log.Error("gob Decode: %s", err)
+		s.logger.Error("decoding unit", "id", id, slogutil.KeyError, err)
 
 		return nil
 	}
@@ -314,7 +373,11 @@ func (u *unit) deserialize(udb *unitDB) {
 	u.clients = convertSliceToMap(udb.Clients)
 	u.upstreamsResponses = convertSliceToMap(udb.UpstreamsResponses)
 	u.upstreamsTimeSum = convertSliceToMap(udb.UpstreamsTimeSum)
+	u.countries = convertSliceToMap(udb.Countries)
+	u.asns = convertSliceToMap(udb.ASNs)
 	u.timeSum = uint64(udb.TimeAvg) * udb.NTotal
+	u.rrlDropped = udb.RRLDropped
+	u.rrlSlipped = udb.RRLSlipped
 }
 
 // add adds new data to u.  It's safe for concurrent use.
@@ -336,11 +399,27 @@ func (u *unit) add(e *Entry) {
 		ut := uint64(e.UpstreamTime.Microseconds())
 		u.upstreamsTimeSum[e.Upstream] += ut
 	}
+
+	for _, c := range e.Countries {
+		u.countries[c]++
+	}
+
+	for _, asn := range e.ASNs {
+		u.asns[asn]++
+	}
+
+	if e.RRLDropped {
+		u.rrlDropped++
+	}
+
+	if e.RRLSlipped {
+		u.rrlSlipped++
+	}
 }
 
 // flushUnitToDB puts udb to the database at id.
-func (udb *unitDB) flushUnitToDB(tx *bbolt.Tx, id uint32) (err error) {
-	log.Debug("stats: flushing unit with id %d and total of %d", id, udb.NTotal)
+func (s *StatsCtx) flushUnitToDB(udb *unitDB, tx *bbolt.Tx, id uint32) (err error) {
+	s.logger.Debug("flushing unit", "id", id, "total", udb.NTotal)
 
 	bkt, err := tx.CreateBucketIfNotExists(idToUnitName(id))
 	if err != nil {
@@ -418,6 +497,8 @@ func (s *StatsCtx) getData(limit uint32) (resp *StatsResp, ok bool) {
 			TopQueried:            []topAddrs{},
 			TopUpstreamsResponses: []topAddrs{},
 			TopUpstreamsAvgTime:   []topAddrsFloat{},
+			TopCountries:          []topAddrs{},
+			TopASNs:               []topAddrs{},
 
 			BlockedFiltering:     []uint64{},
 			DNSQueries:           []uint64{},
@@ -444,6 +525,8 @@ func (s *StatsCtx) dataFromUnits(units []*unitDB, curID uint32) (resp *StatsResp
 		TopUpstreamsResponses: topUpstreamsResponses,
 		TopUpstreamsAvgTime:   topUpstreamsAvgTime,
 		TopClients:            topsCollector(units, maxClients, nil, topClientPairs(s)),
+		TopCountries:          topsCollector(units, maxGeo, nil, func(u *unitDB) (pairs []countPair) { return u.Countries }),
+		TopASNs:               topsCollector(units, maxGeo, nil, func(u *unitDB) (pairs []countPair) { return u.ASNs }),
 	}
 
 	s.fillCollectedStats(resp, units, curID)
@@ -463,6 +546,8 @@ func (s *StatsCtx) dataFromUnits(units []*unitDB, curID uint32) (resp *StatsResp
 		sum.NResult[RSafeBrowsing] += u.NResult[RSafeBrowsing]
 		sum.NResult[RSafeSearch] += u.NResult[RSafeSearch]
 		sum.NResult[RParental] += u.NResult[RParental]
+		sum.RRLDropped += u.RRLDropped
+		sum.RRLSlipped += u.RRLSlipped
 	}
 
 	resp.NumDNSQueries = sum.NTotal
@@ -470,6 +555,8 @@ func (s *StatsCtx) dataFromUnits(units []*unitDB, curID uint32) (resp *StatsResp
 	resp.NumReplacedSafebrowsing = sum.NResult[RSafeBrowsing]
 	resp.NumReplacedSafesearch = sum.NResult[RSafeSearch]
 	resp.NumReplacedParental = sum.NResult[RParental]
+	resp.NumRRLDropped = sum.RRLDropped
+	resp.NumRRLSlipped = sum.RRLSlipped
 
 	if timeN != 0 {
 		resp.AvgProcessingTime = microsecondsToSeconds(float64(sum.TimeAvg / timeN))
@@ -24,6 +24,8 @@ func TestUnit_Deserialize(t *testing.T) {
 			timeSum:            0,
 			upstreamsResponses: map[string]uint64{},
 			upstreamsTimeSum:   map[string]uint64{},
+			countries:          map[string]uint64{},
+			asns:               map[string]uint64{},
 		},
 		db: &unitDB{
 			NResult:            []uint64{0, 0, 0, 0, 0, 0},
@@ -57,6 +59,8 @@ func TestUnit_Deserialize(t *testing.T) {
 			upstreamsTimeSum: map[string]uint64{
 				"1.2.3.4": 246912,
 			},
+			countries: map[string]uint64{},
+			asns:      map[string]uint64{},
 		},
 		db: &unitDB{
 			NResult: []uint64{0, 1, 1, 0, 0, 0},
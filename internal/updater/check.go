@@ -13,6 +13,7 @@ import (
 	"github.com/AdguardTeam/golibs/ioutil"
 	"github.com/AdguardTeam/golibs/log"
 	"golang.org/x/exp/maps"
+	"golang.org/x/mod/semver"
 )
 
 // TODO(a.garipov): Make configurable.
@@ -95,6 +96,15 @@ func (u *Updater) parseVersionResponse(data []byte) (VersionInfo, error) {
 		return info, fmt.Errorf("version.json: no package URL: key %q not found in object", key)
 	}
 
+	if !u.isAllowed(info.NewVersion) {
+		log.Info("updater: version %s is greater than the maximum allowed %s", info.NewVersion, u.maxVersion)
+
+		u.newVersion = ""
+		u.packageURL = ""
+
+		return info, nil
+	}
+
 	info.CanAutoUpdate = aghalg.BoolToNullBool(info.NewVersion != u.version)
 
 	u.newVersion = info.NewVersion
@@ -103,6 +113,17 @@ func (u *Updater) parseVersionResponse(data []byte) (VersionInfo, error) {
 	return info, nil
 }
 
+// isAllowed returns true if AdGuard Home is allowed to update to newVersion
+// according to the configured maximum version.
+func (u *Updater) isAllowed(newVersion string) (ok bool) {
+	if u.maxVersion == "" {
+		return true
+	}
+
+	// Don't allow updating to versions that can't be compared.
+	return semver.IsValid(newVersion) && semver.Compare(newVersion, u.maxVersion) <= 0
+}
+
 // downloadURL returns the download URL for current build as well as its key in
 // versionObj.  If the key is not found, it additionally prints an informative
 // log message.
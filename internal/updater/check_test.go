@@ -146,3 +146,64 @@ func TestUpdater_VersionInfo_others(t *testing.T) {
 		assert.Equal(t, aghalg.NBTrue, info.CanAutoUpdate)
 	}
 }
+
+func TestUpdater_VersionInfo_maxVersion(t *testing.T) {
+	const jsonData = `{
+  "version": "v0.107.50",
+  "announcement": "AdGuard Home v0.107.50 is now available!",
+  "announcement_url": "https://github.com/AdguardTeam/AdGuardHome/internal/releases",
+  "selfupdate_min_version": "v0.0",
+  "download_linux_amd64": "https://static.adtidy.org/adguardhome/release/AdGuardHome_linux_amd64.tar.gz"
+}`
+
+	fakeClient, fakeURL := aghtest.StartHTTPServer(t, []byte(jsonData))
+	fakeURL = fakeURL.JoinPath("adguardhome", version.ChannelRelease, "version.json")
+
+	testCases := []struct {
+		want       aghalg.NullBool
+		name       string
+		maxVersion string
+	}{{
+		want:       aghalg.NBTrue,
+		name:       "no_max",
+		maxVersion: "",
+	}, {
+		want:       aghalg.NBTrue,
+		name:       "equal",
+		maxVersion: "v0.107.50",
+	}, {
+		want:       aghalg.NBTrue,
+		name:       "greater",
+		maxVersion: "v0.108.0",
+	}, {
+		want:       aghalg.NBFalse,
+		name:       "pinned",
+		maxVersion: "v0.107.49",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := updater.NewUpdater(&updater.Config{
+				Client:          fakeClient,
+				Version:         "v0.107.49",
+				Channel:         version.ChannelRelease,
+				GOOS:            "linux",
+				GOARCH:          "amd64",
+				VersionCheckURL: fakeURL.String(),
+				MaxVersion:      tc.maxVersion,
+			})
+
+			info, err := u.VersionInfo(false)
+			require.NoError(t, err)
+
+			assert.Equal(t, "v0.107.50", info.NewVersion)
+			assert.Equal(t, tc.want, info.CanAutoUpdate)
+
+			if tc.want == aghalg.NBFalse {
+				assert.Empty(t, u.NewVersion())
+			} else {
+				assert.Equal(t, "v0.107.50", u.NewVersion())
+			}
+		})
+	}
+}
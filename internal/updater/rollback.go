@@ -0,0 +1,176 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// backupDirName is the name of the directory within the working directory
+// where the backup of the previous version is stored.
+const backupDirName = "agh-backup"
+
+// pendingFileName is the name of the file within the backup directory which
+// describes the installed update that hasn't been confirmed as healthy yet.
+const pendingFileName = "pending.json"
+
+// Pending describes an installed update that hasn't been confirmed as healthy
+// yet.  The previous version is stored in the backup directory and can be
+// restored with [Pending.Rollback].
+type Pending struct {
+	// workDir is the working directory of AdGuard Home.
+	workDir string
+
+	// PrevVersion is the version of AdGuard Home before the update.
+	PrevVersion string `json:"prev_version"`
+
+	// NewVersion is the version of AdGuard Home the update has installed.
+	NewVersion string `json:"new_version"`
+
+	// ExecPath is the path to the executable file.
+	ExecPath string `json:"exec_path"`
+
+	// ConfName is the path to the configuration file.  It is empty if the
+	// configuration file didn't exist at the time of the update.
+	ConfName string `json:"conf_name"`
+
+	// Files are the names of the supporting files that have been replaced by
+	// the update.
+	Files []string `json:"files"`
+
+	// Attempts is the number of times the new version has been started
+	// without being confirmed.
+	Attempts uint `json:"attempts"`
+}
+
+// ReadPending reads the pending update information from workDir, increments
+// the number of start attempts, and saves it back.  If there is no pending
+// update, p is nil.
+func ReadPending(workDir string) (p *Pending, err error) {
+	pendingPath := filepath.Join(workDir, backupDirName, pendingFileName)
+
+	// #nosec G304 -- Trust the path, since it's constructed from the working
+	// directory and constants.
+	data, err := os.ReadFile(pendingPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading pending update: %w", err)
+	}
+
+	p = &Pending{}
+	err = json.Unmarshal(data, p)
+	if err != nil {
+		return nil, fmt.Errorf("decoding pending update: %w", err)
+	}
+
+	p.workDir = workDir
+	p.Attempts++
+
+	return p, p.write()
+}
+
+// Confirm marks the pending update as healthy, so that it's no longer rolled
+// back.
+func (p *Pending) Confirm() (err error) {
+	log.Info("updater: confirming update from %s to %s", p.PrevVersion, p.NewVersion)
+
+	err = os.Remove(p.path())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing pending update: %w", err)
+	}
+
+	return nil
+}
+
+// Rollback restores the previous version of the executable, the supporting
+// files, and the configuration file from the backup directory.  The caller is
+// responsible for restarting AdGuard Home afterwards.
+func (p *Pending) Rollback() (err error) {
+	log.Info("updater: rolling back from %s to %s", p.NewVersion, p.PrevVersion)
+
+	backupDir := filepath.Join(p.workDir, backupDirName)
+	exeName := filepath.Base(p.ExecPath)
+	backupExeName := filepath.Join(backupDir, exeName)
+	failedExeName := filepath.Join(backupDir, "failed-"+exeName)
+
+	_, err = os.Stat(backupExeName)
+	if err != nil {
+		return fmt.Errorf("checking backup executable: %w", err)
+	}
+
+	// Move the failed executable away first, since on Windows it's impossible
+	// to overwrite an executable file that is running, but it's possible to
+	// rename it.
+	err = os.Rename(p.ExecPath, failedExeName)
+	if err != nil {
+		return fmt.Errorf("moving failed executable: %w", err)
+	}
+
+	err = os.Rename(backupExeName, p.ExecPath)
+	if err != nil {
+		return fmt.Errorf("restoring executable: %w", err)
+	}
+
+	err = copySupportingFiles(p.Files, backupDir, p.workDir)
+	if err != nil {
+		return fmt.Errorf("restoring supporting files: %w", err)
+	}
+
+	if p.ConfName != "" {
+		err = copyFile(confBackupPath(backupDir, p.ConfName), p.ConfName)
+		if err != nil {
+			return fmt.Errorf("restoring configuration file: %w", err)
+		}
+	}
+
+	return p.Confirm()
+}
+
+// confBackupPath returns the path to the backup of the configuration file
+// confName within backupDir.
+func confBackupPath(backupDir, confName string) (backupPath string) {
+	return filepath.Join(backupDir, filepath.Base(confName))
+}
+
+// path returns the path to the pending update file.
+func (p *Pending) path() (pendingPath string) {
+	return filepath.Join(p.workDir, backupDirName, pendingFileName)
+}
+
+// write saves p into the backup directory.
+func (p *Pending) write() (err error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encoding pending update: %w", err)
+	}
+
+	err = os.WriteFile(p.path(), data, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing pending update: %w", err)
+	}
+
+	return nil
+}
+
+// writePending saves the information about the just installed update, so that
+// it can be rolled back if the new version fails to become healthy.
+func (u *Updater) writePending(firstRun bool) (err error) {
+	p := &Pending{
+		workDir:     u.workDir,
+		PrevVersion: u.version,
+		NewVersion:  u.newVersion,
+		ExecPath:    u.currentExeName,
+		Files:       u.unpackedFiles,
+	}
+
+	if !firstRun {
+		p.ConfName = u.confName
+	}
+
+	return p.write()
+}
@@ -0,0 +1,108 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/ioutil"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// signatureExt is the extension of the detached signature file.  The URL of
+// the signature is the URL of the package with this extension appended.
+const signatureExt = ".sig"
+
+// maxSignatureFileSize is the maximum size of the detached signature file in
+// bytes.  It must be enough to hold a base64-encoded Ed25519 signature along
+// with some whitespace.
+const maxSignatureFileSize = 1024
+
+// ParsePublicKey parses a base64-encoded Ed25519 public key.
+func ParsePublicKey(s string) (key ed25519.PublicKey, err error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding public key: %w", err)
+	}
+
+	if l := len(b); l != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("bad public key length %d, want %d", l, ed25519.PublicKeySize)
+	}
+
+	return ed25519.PublicKey(b), nil
+}
+
+// errNoPublicKey is returned by [Updater.verifyPackageSignature] when the build
+// has no public key to verify the package with.
+const errNoPublicKey errors.Error = "no public key to verify the package with; " +
+	"this build can't be updated automatically"
+
+// verifyPackageSignature downloads the detached signature of the package and
+// checks it against the downloaded package file.  It returns [errNoPublicKey]
+// if no public key is configured, so that unverified packages are never
+// installed.
+func (u *Updater) verifyPackageSignature() (err error) {
+	if len(u.publicKey) == 0 {
+		return errNoPublicKey
+	}
+
+	sigURL := u.packageURL + signatureExt
+	log.Debug("updater: downloading signature from %s", sigURL)
+
+	sig, err := u.downloadSignature(sigURL)
+	if err != nil {
+		return fmt.Errorf("downloading signature: %w", err)
+	}
+
+	pkg, err := os.ReadFile(u.packageName)
+	if err != nil {
+		return fmt.Errorf("reading package: %w", err)
+	}
+
+	if !ed25519.Verify(u.publicKey, pkg, sig) {
+		return errors.Error("signature mismatch")
+	}
+
+	log.Debug("updater: package signature is valid")
+
+	return nil
+}
+
+// downloadSignature downloads and decodes the base64-encoded detached Ed25519
+// signature from sigURL.
+func (u *Updater) downloadSignature(sigURL string) (sig []byte, err error) {
+	resp, err := u.client.Get(sigURL)
+	if err != nil {
+		return nil, fmt.Errorf("http request failed: %w", err)
+	}
+	defer func() { err = errors.WithDeferred(err, resp.Body.Close()) }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	r := ioutil.LimitReader(resp.Body, maxSignatureFileSize)
+
+	// This use of ReadAll is safe, because we just limited the appropriate
+	// ReadCloser.
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	sig, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(body)))
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	if l := len(sig); l != ed25519.SignatureSize {
+		return nil, fmt.Errorf("bad signature length %d, want %d", l, ed25519.SignatureSize)
+	}
+
+	return sig, nil
+}
@@ -5,6 +5,7 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"crypto/ed25519"
 	"fmt"
 	"io"
 	"net/http"
@@ -25,6 +26,10 @@ import (
 type Updater struct {
 	client *http.Client
 
+	// publicKey is the key used to verify the signatures of the update
+	// packages.  If it is empty, the updates are refused.
+	publicKey ed25519.PublicKey
+
 	version string
 	channel string
 	goarch  string
@@ -37,6 +42,10 @@ type Updater struct {
 	execPath        string
 	versionCheckURL string
 
+	// maxVersion is the maximum version allowed to update to.  If it is
+	// empty, any version is allowed.
+	maxVersion string
+
 	// mu protects all fields below.
 	mu *sync.RWMutex
 
@@ -82,6 +91,18 @@ type Config struct {
 
 	// VersionCheckURL is url to the latest version announcement.
 	VersionCheckURL string
+
+	// MaxVersion is the maximum version allowed to update to.  Versions
+	// greater than this one are announced but cannot be installed.  Setting it
+	// to the current version effectively pins it.  If it is empty, any version
+	// is allowed.  It must be either empty or a valid semantic version with the
+	// "v" prefix.
+	MaxVersion string
+
+	// PublicKey is the Ed25519 public key used to verify the detached
+	// signatures of the update packages.  If it is empty, the packages are
+	// never installed.
+	PublicKey ed25519.PublicKey
 }
 
 // NewUpdater creates a new Updater.
@@ -89,6 +110,8 @@ func NewUpdater(conf *Config) *Updater {
 	return &Updater{
 		client: conf.Client,
 
+		publicKey: conf.PublicKey,
+
 		version: conf.Version,
 		channel: conf.Channel,
 		goarch:  conf.GOARCH,
@@ -100,6 +123,7 @@ func NewUpdater(conf *Config) *Updater {
 		workDir:         conf.WorkDir,
 		execPath:        conf.ExecPath,
 		versionCheckURL: conf.VersionCheckURL,
+		maxVersion:      conf.MaxVersion,
 
 		mu: &sync.RWMutex{},
 	}
@@ -132,6 +156,11 @@ func (u *Updater) Update(firstRun bool) (err error) {
 		return fmt.Errorf("downloading package file: %w", err)
 	}
 
+	err = u.verifyPackageSignature()
+	if err != nil {
+		return fmt.Errorf("verifying package signature: %w", err)
+	}
+
 	err = u.unpack()
 	if err != nil {
 		return fmt.Errorf("unpacking: %w", err)
@@ -154,6 +183,11 @@ func (u *Updater) Update(firstRun bool) (err error) {
 		return fmt.Errorf("replacing: %w", err)
 	}
 
+	err = u.writePending(firstRun)
+	if err != nil {
+		return fmt.Errorf("saving pending update: %w", err)
+	}
+
 	return nil
 }
 
@@ -183,7 +217,7 @@ func (u *Updater) prepare() (err error) {
 	}
 
 	u.packageName = filepath.Join(u.updateDir, pkgNameOnly)
-	u.backupDir = filepath.Join(u.workDir, "agh-backup")
+	u.backupDir = filepath.Join(u.workDir, backupDirName)
 
 	updateExeName := "AdGuardHome"
 	if u.goos == "windows" {
@@ -265,7 +299,7 @@ func (u *Updater) backup(firstRun bool) (err error) {
 	log.Debug("updater: backing up current configuration")
 	_ = os.Mkdir(u.backupDir, 0o755)
 	if !firstRun {
-		err = copyFile(u.confName, filepath.Join(u.backupDir, "AdGuardHome.yaml"))
+		err = copyFile(u.confName, confBackupPath(u.backupDir, u.confName))
 		if err != nil {
 			return fmt.Errorf("copyFile() failed: %w", err)
 		}
@@ -1,11 +1,15 @@
 package updater
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/aghtest"
+	"github.com/AdguardTeam/golibs/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -105,3 +109,121 @@ func TestUpdater_internal(t *testing.T) {
 		assert.Equal(t, "AdGuardHome.yaml", string(d))
 	}
 }
+
+func TestUpdater_verifyPackageSignature(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	_, otherPrivKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	pkgData := []byte("package data")
+	pkgPath := filepath.Join(t.TempDir(), "AdGuardHome.tar.gz")
+	require.NoError(t, os.WriteFile(pkgPath, pkgData, 0o644))
+
+	testCases := []struct {
+		name       string
+		sig        string
+		wantErrMsg string
+		key        ed25519.PublicKey
+	}{{
+		name:       "valid",
+		sig:        base64.StdEncoding.EncodeToString(ed25519.Sign(privKey, pkgData)) + "\n",
+		wantErrMsg: "",
+		key:        pubKey,
+	}, {
+		name: "no_key",
+		sig:  "",
+		wantErrMsg: "no public key to verify the package with; " +
+			"this build can't be updated automatically",
+		key: nil,
+	}, {
+		name:       "other_key",
+		sig:        base64.StdEncoding.EncodeToString(ed25519.Sign(otherPrivKey, pkgData)),
+		wantErrMsg: "signature mismatch",
+		key:        pubKey,
+	}, {
+		name:       "bad_length",
+		sig:        base64.StdEncoding.EncodeToString([]byte("short")),
+		wantErrMsg: "downloading signature: bad signature length 5, want 64",
+		key:        pubKey,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClient, fakeURL := aghtest.StartHTTPServer(t, []byte(tc.sig))
+
+			u := NewUpdater(&Config{
+				Client:    fakeClient,
+				PublicKey: tc.key,
+			})
+
+			u.packageURL = fakeURL.JoinPath("AdGuardHome.tar.gz").String()
+			u.packageName = pkgPath
+
+			err = u.verifyPackageSignature()
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
+
+func TestPending_Rollback(t *testing.T) {
+	wd := t.TempDir()
+	backupDir := filepath.Join(wd, backupDirName)
+	require.NoError(t, os.Mkdir(backupDir, 0o755))
+
+	exePath := filepath.Join(wd, "AdGuardHome")
+	yamlPath := filepath.Join(t.TempDir(), "custom.yaml")
+	readmePath := filepath.Join(wd, "README.md")
+
+	require.NoError(t, os.WriteFile(exePath, []byte("new exe"), 0o755))
+	require.NoError(t, os.WriteFile(yamlPath, []byte("new yaml"), 0o644))
+	require.NoError(t, os.WriteFile(readmePath, []byte("new readme"), 0o644))
+
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, "AdGuardHome"), []byte("old exe"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, "custom.yaml"), []byte("old yaml"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, "README.md"), []byte("old readme"), 0o644))
+
+	u := NewUpdater(&Config{
+		Version:  "v0.107.0",
+		WorkDir:  wd,
+		ConfName: yamlPath,
+	})
+	u.newVersion = "v0.107.1"
+	u.currentExeName = exePath
+	u.unpackedFiles = []string{"AdGuardHome", "README.md"}
+
+	require.NoError(t, u.writePending(false))
+
+	p, err := ReadPending(wd)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+
+	assert.Equal(t, uint(1), p.Attempts)
+	assert.Equal(t, "v0.107.0", p.PrevVersion)
+	assert.Equal(t, "v0.107.1", p.NewVersion)
+
+	p, err = ReadPending(wd)
+	require.NoError(t, err)
+	require.NotNil(t, p)
+
+	assert.Equal(t, uint(2), p.Attempts)
+
+	require.NoError(t, p.Rollback())
+
+	for path, want := range map[string]string{
+		exePath:    "old exe",
+		yamlPath:   "old yaml",
+		readmePath: "old readme",
+	} {
+		d, readErr := os.ReadFile(path)
+		require.NoError(t, readErr)
+
+		assert.Equal(t, want, string(d))
+	}
+
+	p, err = ReadPending(wd)
+	require.NoError(t, err)
+
+	assert.Nil(t, p)
+}
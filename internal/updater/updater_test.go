@@ -1,6 +1,9 @@
 package updater_test
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -48,10 +51,18 @@ func TestUpdater_Update(t *testing.T) {
 	pkgData, err := os.ReadFile("testdata/AdGuardHome_unix.tar.gz")
 	require.NoError(t, err)
 
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(privKey, pkgData))
+
 	mux := http.NewServeMux()
 	mux.HandleFunc(packagePath, func(w http.ResponseWriter, _ *http.Request) {
 		_, _ = w.Write(pkgData)
 	})
+	mux.HandleFunc(packagePath+".sig", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(sig))
+	})
 
 	versionPath := path.Join("/adguardhome", version.ChannelBeta, "version.json")
 	mux.HandleFunc(versionPath, func(w http.ResponseWriter, r *http.Request) {
@@ -77,6 +88,7 @@ func TestUpdater_Update(t *testing.T) {
 		WorkDir:         wd,
 		ExecPath:        exePath,
 		VersionCheckURL: versionCheckURL,
+		PublicKey:       pubKey,
 	})
 
 	_, err = u.VersionInfo(false)
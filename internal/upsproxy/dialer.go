@@ -0,0 +1,219 @@
+package upsproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"golang.org/x/net/proxy"
+)
+
+// Proxy URL schemes.
+const (
+	// schemeSOCKS5 is the scheme of the SOCKS5 proxies, for which the
+	// hostnames are resolved locally.
+	schemeSOCKS5 = "socks5"
+
+	// schemeSOCKS5H is the scheme of the SOCKS5 proxies, which resolve the
+	// hostnames themselves.
+	schemeSOCKS5H = "socks5h"
+
+	// schemeHTTP is the scheme of the HTTP proxies supporting the CONNECT
+	// method.
+	schemeHTTP = "http"
+)
+
+// dialer connects to the addresses through a proxy.
+type dialer struct {
+	// dial connects to addr through the proxy.
+	dial func(ctx context.Context, network, addr string) (conn net.Conn, err error)
+
+	// remoteResolve is true if the proxy resolves the hostnames itself.
+	remoteResolve bool
+}
+
+// newDialer returns a new *dialer for the proxy at u.
+func newDialer(u *url.URL, timeout time.Duration) (d *dialer, err error) {
+	fwd := &net.Dialer{
+		Timeout: timeout,
+	}
+
+	switch u.Scheme {
+	case schemeSOCKS5, schemeSOCKS5H:
+		var auth *proxy.Auth
+		if u.User != nil {
+			pass, _ := u.User.Password()
+			auth = &proxy.Auth{
+				User:     u.User.Username(),
+				Password: pass,
+			}
+		}
+
+		var pd proxy.Dialer
+		pd, err = proxy.SOCKS5("tcp", hostPort(u, "1080"), auth, fwd)
+		if err != nil {
+			return nil, fmt.Errorf("socks5: %w", err)
+		}
+
+		cd, ok := pd.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("socks5: unexpected dialer type %T", pd)
+		}
+
+		return &dialer{
+			dial:          cd.DialContext,
+			remoteResolve: u.Scheme == schemeSOCKS5H,
+		}, nil
+	case schemeHTTP:
+		cd := &connectDialer{
+			forward:   fwd,
+			proxyAddr: hostPort(u, "80"),
+		}
+
+		if u.User != nil {
+			pass, _ := u.User.Password()
+			creds := u.User.Username() + ":" + pass
+			cd.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+		}
+
+		return &dialer{
+			dial:          cd.DialContext,
+			remoteResolve: true,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+}
+
+// hostPort returns the host and the port of u, using defaultPort if there is
+// none.
+func hostPort(u *url.URL, defaultPort string) (addr string) {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// connectDialer connects to the addresses through an HTTP proxy using the
+// CONNECT method.
+type connectDialer struct {
+	// forward connects to the proxy.
+	forward *net.Dialer
+
+	// auth is the value of the Proxy-Authorization header.  It's empty if
+	// the proxy doesn't require authentication.
+	auth string
+
+	// proxyAddr is the address of the proxy.
+	proxyAddr string
+}
+
+// DialContext connects to addr through the proxy.  network must be a TCP one.
+func (d *connectDialer) DialContext(
+	ctx context.Context,
+	network string,
+	addr string,
+) (conn net.Conn, err error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		// Go on.
+	default:
+		return nil, fmt.Errorf("http proxy: network %q isn't supported", network)
+	}
+
+	conn, err = d.forward.DialContext(ctx, "tcp", d.proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.WithDeferred(err, conn.Close())
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		err = conn.SetDeadline(deadline)
+		if err != nil {
+			return nil, fmt.Errorf("http proxy: setting deadline: %w", err)
+		}
+	}
+
+	br, err := d.connect(conn, addr)
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: %w", err)
+	}
+
+	err = conn.SetDeadline(time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: resetting deadline: %w", err)
+	}
+
+	if br.Buffered() > 0 {
+		// Don't lose the data the proxy has sent after the response.
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+
+	return conn, nil
+}
+
+// connect sends the CONNECT request for addr over conn and reads the response.
+// br is the reader of conn, which may have buffered the data sent after the
+// response.
+func (d *connectDialer) connect(conn net.Conn, addr string) (br *bufio.Reader, err error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+
+	if d.auth != "" {
+		req.Header.Set("Proxy-Authorization", d.auth)
+	}
+
+	err = req.Write(conn)
+	if err != nil {
+		return nil, fmt.Errorf("writing request: %w", err)
+	}
+
+	br = bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("closing response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("connecting to %s: %s", addr, resp.Status)
+	}
+
+	return br, nil
+}
+
+// bufferedConn is a net.Conn, the reads from which are performed through a
+// buffered reader.
+type bufferedConn struct {
+	net.Conn
+
+	r *bufio.Reader
+}
+
+// type check
+var _ net.Conn = (*bufferedConn)(nil)
+
+// Read implements the [net.Conn] interface for *bufferedConn.
+func (c *bufferedConn) Read(b []byte) (n int, err error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,196 @@
+// Package upsproxy routes the connections to the upstream DNS servers through
+// SOCKS5 and HTTP CONNECT proxies.
+package upsproxy
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// DefaultTimeout is the default timeout of connecting to a proxy.
+const DefaultTimeout = 10 * time.Second
+
+// ErrUnsupportedScheme is returned by [Proxies.Wrap] for the upstreams, which
+// can't connect to their servers through a proxy, such as the DNS-over-QUIC and
+// DNSCrypt ones.
+const ErrUnsupportedScheme errors.Error = "only plain dns, dns-over-tls, and " +
+	"dns-over-https upstreams support proxies"
+
+// Config is the configuration of the upstream proxies.
+type Config struct {
+	// Upstreams are the URLs of the proxies by the addresses of the upstreams,
+	// overriding Global.  An empty URL means that the upstream is connected to
+	// directly.
+	Upstreams map[string]string
+
+	// Global is the URL of the proxy used for all the upstreams, including
+	// the bootstrap ones, unless overridden.  If empty, the upstreams are
+	// connected to directly.
+	Global string
+
+	// Timeout is the timeout of connecting to a proxy.  If zero,
+	// [DefaultTimeout] is used.
+	Timeout time.Duration
+}
+
+// Proxies chooses the proxies for the upstreams.  A nil *Proxies is valid and
+// doesn't route any upstreams through proxies.
+type Proxies struct {
+	// global is the dialer of the global proxy.  It's nil if there is none.
+	global *dialer
+
+	// byAddr are the dialers by the normalized addresses of the upstreams.
+	// A nil dialer means that the upstream is connected to directly.
+	byAddr map[string]*dialer
+}
+
+// New returns a new properly initialized *Proxies.  conf must not be nil.
+func New(conf *Config) (p *Proxies, err error) {
+	timeout := conf.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	dialers := map[string]*dialer{}
+	p = &Proxies{
+		byAddr: make(map[string]*dialer, len(conf.Upstreams)),
+	}
+
+	p.global, err = dialerFor(dialers, conf.Global, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("upsproxy: global proxy: %w", err)
+	}
+
+	var errs []error
+	for addr, proxyURL := range conf.Upstreams {
+		var key string
+		key, err = normalizeAddr(addr)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upstream %q: %w", addr, err))
+
+			continue
+		}
+
+		p.byAddr[key], err = dialerFor(dialers, proxyURL, timeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upstream %q: proxy: %w", addr, err))
+		}
+	}
+
+	if err = errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("upsproxy: %w", err)
+	}
+
+	return p, nil
+}
+
+// dialerFor returns the dialer for the proxy at rawURL reusing the ones from
+// dialers.  d is nil if rawURL is empty.
+func dialerFor(
+	dialers map[string]*dialer,
+	rawURL string,
+	timeout time.Duration,
+) (d *dialer, err error) {
+	if rawURL == "" {
+		return nil, nil
+	} else if d = dialers[rawURL]; d != nil {
+		return d, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	d, err = newDialer(u, timeout)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	dialers[rawURL] = d
+
+	return d, nil
+}
+
+// normalizeAddr returns the address of the upstream at addr in the format of
+// [upstream.Upstream.Address].
+func normalizeAddr(addr string) (norm string, err error) {
+	u, err := upstream.AddressToUpstream(addr, &upstream.Options{})
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return "", err
+	}
+
+	norm = u.Address()
+
+	return norm, u.Close()
+}
+
+// Wrap returns an upstream connecting to the server of u through the proxy
+// configured for it.  If there is none, wrapped is u.  Otherwise, u isn't used
+// and should be closed by the caller.  opts are the options u was created
+// with.
+func (p *Proxies) Wrap(
+	u upstream.Upstream,
+	opts *upstream.Options,
+) (wrapped upstream.Upstream, err error) {
+	if p == nil {
+		return u, nil
+	}
+
+	return wrap(u, p.proxyFor(u), opts)
+}
+
+// IsProxied returns true if there is a proxy configured for u.
+func (p *Proxies) IsProxied(u upstream.Upstream) (ok bool) {
+	return p != nil && p.proxyFor(u) != nil
+}
+
+// proxyFor returns the dialer of the proxy configured for u.  d is nil if u is
+// connected to directly.  p must not be nil.
+func (p *Proxies) proxyFor(u upstream.Upstream) (d *dialer) {
+	d, ok := p.byAddr[u.Address()]
+	if !ok {
+		d = p.global
+	}
+
+	return d
+}
+
+// WrapBootstrap is like [Proxies.Wrap] but only uses the global proxy, since
+// the bootstrap upstreams aren't configured individually.
+func (p *Proxies) WrapBootstrap(
+	u upstream.Upstream,
+	opts *upstream.Options,
+) (wrapped upstream.Upstream, err error) {
+	if p == nil {
+		return u, nil
+	}
+
+	return wrap(u, p.global, opts)
+}
+
+// wrap returns an upstream connecting to the server of u through d.  If d is
+// nil, wrapped is u.
+func wrap(
+	u upstream.Upstream,
+	d *dialer,
+	opts *upstream.Options,
+) (wrapped upstream.Upstream, err error) {
+	if d == nil {
+		return u, nil
+	}
+
+	wrapped, err = newUpstream(u.Address(), d, opts)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %q: %w", u.Address(), err)
+	}
+
+	return wrapped, nil
+}
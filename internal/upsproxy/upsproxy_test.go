@@ -0,0 +1,373 @@
+package upsproxy_test
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/upsproxy"
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTimeout is the common timeout for tests.
+const testTimeout = 1 * time.Second
+
+// testAnswer is the address in the answers of the test DNS server.
+var testAnswer = netip.MustParseAddr("192.0.2.1")
+
+// startDNSServer starts a DNS server answering all requests over TCP and
+// returns its address.
+func startDNSServer(t testing.TB) (addr string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := &dns.Server{
+		Listener: l,
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			resp := (&dns.Msg{}).SetReply(req)
+			resp.Answer = append(resp.Answer, &dns.A{
+				Hdr: dns.RR_Header{
+					Name:   req.Question[0].Name,
+					Rrtype: dns.TypeA,
+					Class:  dns.ClassINET,
+					Ttl:    60,
+				},
+				A: testAnswer.AsSlice(),
+			})
+
+			assert.NoError(t, w.WriteMsg(resp))
+		}),
+	}
+
+	go func() { _ = srv.ActivateAndServe() }()
+	testutil.CleanupAndRequireSuccess(t, srv.Shutdown)
+
+	return l.Addr().String()
+}
+
+// testProxy is a proxy recording the addresses it connects to.
+type testProxy struct {
+	// mu protects targets.
+	mu *sync.Mutex
+
+	// targets are the addresses requested by the clients.
+	targets []string
+}
+
+// newTestProxy returns a new *testProxy.
+func newTestProxy() (p *testProxy) {
+	return &testProxy{
+		mu: &sync.Mutex{},
+	}
+}
+
+// requested returns the addresses requested by the clients of p.
+func (p *testProxy) requested() (targets []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]string(nil), p.targets...)
+}
+
+// serve accepts the connections on l and handles them with handshake, which
+// returns the address of the target, until l is closed.
+func (p *testProxy) serve(
+	t testing.TB,
+	l net.Listener,
+	handshake func(conn net.Conn, br *bufio.Reader) (target string, err error),
+) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer func() { _ = conn.Close() }()
+
+			br := bufio.NewReader(conn)
+			target, err := handshake(conn, br)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			p.mu.Lock()
+			p.targets = append(p.targets, target)
+			p.mu.Unlock()
+
+			upConn, err := net.Dial("tcp", target)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer func() { _ = upConn.Close() }()
+
+			go func() { _, _ = io.Copy(upConn, br) }()
+			_, _ = io.Copy(conn, upConn)
+		}()
+	}
+}
+
+// startProxy starts p with handshake on the loopback interface and returns its
+// address.
+func startProxy(
+	t testing.TB,
+	p *testProxy,
+	handshake func(conn net.Conn, br *bufio.Reader) (target string, err error),
+) (addr string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	testutil.CleanupAndRequireSuccess(t, l.Close)
+
+	go p.serve(t, l, handshake)
+
+	return l.Addr().String()
+}
+
+// connectHandshake handles the HTTP CONNECT request.
+func connectHandshake(conn net.Conn, br *bufio.Reader) (target string, err error) {
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return "", err
+	}
+
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != wantAuth {
+		_, err = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+
+		return "", err
+	}
+
+	_, err = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+
+	return req.Host, err
+}
+
+// socks5Handshake handles the SOCKS5 handshake without authentication, see
+// RFC 1928.
+func socks5Handshake(conn net.Conn, br *bufio.Reader) (target string, err error) {
+	greeting := make([]byte, 2)
+	_, err = io.ReadFull(br, greeting)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.ReadFull(br, make([]byte, greeting[1]))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = conn.Write([]byte{5, 0})
+	if err != nil {
+		return "", err
+	}
+
+	hdr := make([]byte, 4)
+	_, err = io.ReadFull(br, hdr)
+	if err != nil {
+		return "", err
+	}
+
+	var host string
+	switch hdr[3] {
+	case 1:
+		ip := make([]byte, 4)
+		_, err = io.ReadFull(br, ip)
+		host = net.IP(ip).String()
+	case 3:
+		var l byte
+		l, err = br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+
+		name := make([]byte, l)
+		_, err = io.ReadFull(br, name)
+		host = string(name)
+	default:
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+
+	port := make([]byte, 2)
+	_, err = io.ReadFull(br, port)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	if err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// exchange sends a request through u and returns the answer.
+func exchange(t testing.TB, u upstream.Upstream) (ans []dns.RR) {
+	t.Helper()
+
+	req := (&dns.Msg{}).SetQuestion("example.org.", dns.TypeA)
+	resp, err := u.Exchange(req)
+	require.NoError(t, err)
+	require.Equal(t, req.Id, resp.Id)
+
+	return resp.Answer
+}
+
+func TestProxies_Wrap(t *testing.T) {
+	dnsAddr := startDNSServer(t)
+
+	httpProxy := newTestProxy()
+	httpAddr := startProxy(t, httpProxy, connectHandshake)
+
+	socksProxy := newTestProxy()
+	socksAddr := startProxy(t, socksProxy, socks5Handshake)
+
+	opts := &upstream.Options{
+		Timeout: testTimeout,
+	}
+
+	p, err := upsproxy.New(&upsproxy.Config{
+		Upstreams: map[string]string{
+			"tcp://" + dnsAddr: "socks5://" + socksAddr,
+			"tls://192.0.2.2":  "",
+		},
+		Global:  "http://user:pass@" + httpAddr,
+		Timeout: testTimeout,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		proxy *testProxy
+		name  string
+		addr  string
+	}{{
+		proxy: httpProxy,
+		name:  "global_plain",
+		addr:  dnsAddr,
+	}, {
+		proxy: socksProxy,
+		name:  "per_upstream",
+		addr:  "tcp://" + dnsAddr,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, uErr := upstream.AddressToUpstream(tc.addr, opts)
+			require.NoError(t, uErr)
+			testutil.CleanupAndRequireSuccess(t, u.Close)
+
+			wrapped, uErr := p.Wrap(u, opts)
+			require.NoError(t, uErr)
+			testutil.CleanupAndRequireSuccess(t, wrapped.Close)
+
+			require.IsType(t, &upsproxy.Upstream{}, wrapped)
+			assert.Equal(t, u.Address(), wrapped.Address())
+
+			ans := exchange(t, wrapped)
+			require.Len(t, ans, 1)
+
+			assert.Equal(t, testAnswer.AsSlice(), []byte(ans[0].(*dns.A).A.To4()))
+			assert.Contains(t, tc.proxy.requested(), dnsAddr)
+
+			// Make sure that the connection is reused.
+			_ = exchange(t, wrapped)
+			assert.Len(t, tc.proxy.requested(), 1)
+		})
+	}
+
+	t.Run("direct", func(t *testing.T) {
+		u, uErr := upstream.AddressToUpstream("tls://192.0.2.2", opts)
+		require.NoError(t, uErr)
+		testutil.CleanupAndRequireSuccess(t, u.Close)
+
+		assert.False(t, p.IsProxied(u))
+
+		wrapped, uErr := p.Wrap(u, opts)
+		require.NoError(t, uErr)
+
+		assert.Same(t, u, wrapped)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		u, uErr := upstream.AddressToUpstream("quic://192.0.2.2", opts)
+		require.NoError(t, uErr)
+		testutil.CleanupAndRequireSuccess(t, u.Close)
+
+		assert.True(t, p.IsProxied(u))
+
+		_, uErr = p.Wrap(u, opts)
+		assert.ErrorIs(t, uErr, upsproxy.ErrUnsupportedScheme)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		u, uErr := upstream.AddressToUpstream(dnsAddr, opts)
+		require.NoError(t, uErr)
+		testutil.CleanupAndRequireSuccess(t, u.Close)
+
+		var nilProxies *upsproxy.Proxies
+		assert.False(t, nilProxies.IsProxied(u))
+
+		wrapped, uErr := nilProxies.Wrap(u, opts)
+		require.NoError(t, uErr)
+
+		assert.Same(t, u, wrapped)
+	})
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		upstreams  map[string]string
+		name       string
+		global     string
+		wantErrMsg string
+	}{{
+		upstreams:  nil,
+		name:       "empty",
+		global:     "",
+		wantErrMsg: "",
+	}, {
+		upstreams:  map[string]string{"1.1.1.1": "socks5h://127.0.0.1:9050"},
+		name:       "success",
+		global:     "http://proxy.example:3128",
+		wantErrMsg: "",
+	}, {
+		upstreams:  nil,
+		name:       "bad_scheme",
+		global:     "ftp://proxy.example",
+		wantErrMsg: `upsproxy: global proxy: unsupported scheme "ftp"`,
+	}, {
+		upstreams:  map[string]string{"1.1.1.1": "https://proxy.example"},
+		name:       "bad_upstream_scheme",
+		global:     "",
+		wantErrMsg: `upsproxy: upstream "1.1.1.1": proxy: unsupported scheme "https"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := upsproxy.New(&upsproxy.Config{
+				Upstreams: tc.upstreams,
+				Global:    tc.global,
+			})
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
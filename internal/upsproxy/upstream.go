@@ -0,0 +1,372 @@
+package upsproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/miekg/dns"
+)
+
+// maxIdleConns is the maximum number of the idle TCP and TLS connections kept
+// for reuse by a single upstream.
+const maxIdleConns = 4
+
+// Upstream is an [upstream.Upstream] connecting to the server through a proxy.
+// Since the proxies only support TCP, the plain DNS upstreams are queried over
+// TCP, and the DNS-over-HTTPS ones only use HTTP/1.1 and HTTP/2.
+//
+// The upstreams of dnsproxy can't be reused here, since they don't accept a
+// custom dialer, so Upstream implements the TCP-based protocols itself.
+type Upstream struct {
+	// dialer connects to the server through the proxy.
+	dialer *dialer
+
+	// boot resolves the hostname of the server, if the proxy doesn't resolve
+	// it itself.  It may be nil if the hostname is an IP address.
+	boot upstream.Resolver
+
+	// tlsConf is the TLS configuration for DNS-over-TLS.  It's nil for the
+	// other protocols.
+	tlsConf *tls.Config
+
+	// httpCli is the HTTP client for DNS-over-HTTPS.  It's nil for the other
+	// protocols.
+	httpCli *http.Client
+
+	// mu protects idle.
+	mu *sync.Mutex
+
+	// idle are the connections available for reuse.
+	idle []*dns.Conn
+
+	// addr is the address of the upstream as configured.
+	addr string
+
+	// endpoint is the URL of the DNS-over-HTTPS server.  It's empty for the
+	// other protocols.
+	endpoint string
+
+	// hostPort is the address of the server.
+	hostPort string
+
+	// timeout is the timeout of a single exchange.
+	timeout time.Duration
+
+	// preferIPv6 defines if the IPv6 addresses of the server are tried first.
+	preferIPv6 bool
+}
+
+// type check
+var _ upstream.Upstream = (*Upstream)(nil)
+
+// newUpstream returns a new *Upstream for the upstream at addr in the format of
+// [upstream.Upstream.Address].
+func newUpstream(addr string, d *dialer, opts *upstream.Options) (u *Upstream, err error) {
+	rawURL := addr
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "udp://" + rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	u = &Upstream{
+		dialer:     d,
+		boot:       opts.Bootstrap,
+		mu:         &sync.Mutex{},
+		addr:       addr,
+		timeout:    opts.Timeout,
+		preferIPv6: opts.PreferIPv6,
+	}
+
+	host := parsed.Hostname()
+	tlsConf := &tls.Config{
+		ServerName:         host,
+		RootCAs:            opts.RootCAs,
+		CipherSuites:       opts.CipherSuites,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	switch parsed.Scheme {
+	case "udp", "tcp":
+		u.hostPort = hostPort(parsed, "53")
+	case "tls":
+		u.hostPort = hostPort(parsed, "853")
+		u.tlsConf = tlsConf
+	case "https":
+		u.hostPort = hostPort(parsed, "443")
+		u.endpoint = parsed.String()
+		u.httpCli = &http.Client{
+			Transport: &http.Transport{
+				DialContext:       u.dial,
+				TLSClientConfig:   tlsConf,
+				ForceAttemptHTTP2: true,
+			},
+		}
+	default:
+		return nil, fmt.Errorf("scheme %q: %w", parsed.Scheme, ErrUnsupportedScheme)
+	}
+
+	if u.timeout == 0 {
+		u.timeout = DefaultTimeout
+	}
+
+	return u, nil
+}
+
+// Exchange implements the [upstream.Upstream] interface for *Upstream.
+func (u *Upstream) Exchange(req *dns.Msg) (resp *dns.Msg, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), u.timeout)
+	defer cancel()
+
+	if u.httpCli != nil {
+		return u.exchangeHTTPS(ctx, req)
+	}
+
+	if conn := u.takeIdle(); conn != nil {
+		resp, err = u.exchangeConn(ctx, conn, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		// The server may have closed the idle connection, so retry with a new
+		// one.
+		log.Debug("upsproxy: %s: reused connection: %s", u.addr, err)
+	}
+
+	conn, err := u.dialConn(ctx)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	return u.exchangeConn(ctx, conn, req)
+}
+
+// Address implements the [upstream.Upstream] interface for *Upstream.
+func (u *Upstream) Address() (addr string) {
+	return u.addr
+}
+
+// Close implements the [upstream.Upstream] interface for *Upstream.
+func (u *Upstream) Close() (err error) {
+	if u.httpCli != nil {
+		u.httpCli.CloseIdleConnections()
+
+		return nil
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var errs []error
+	for _, conn := range u.idle {
+		errs = append(errs, conn.Close())
+	}
+
+	u.idle = nil
+
+	return errors.Join(errs...)
+}
+
+// takeIdle returns an idle connection or nil if there is none.
+func (u *Upstream) takeIdle() (conn *dns.Conn) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.idle) == 0 {
+		return nil
+	}
+
+	conn = u.idle[len(u.idle)-1]
+	u.idle = u.idle[:len(u.idle)-1]
+
+	return conn
+}
+
+// release puts conn back for reuse or closes it, if there are too many idle
+// connections.
+func (u *Upstream) release(conn *dns.Conn) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.idle) < maxIdleConns {
+		u.idle = append(u.idle, conn)
+
+		return
+	}
+
+	err := conn.Close()
+	if err != nil {
+		log.Debug("upsproxy: %s: closing connection: %s", u.addr, err)
+	}
+}
+
+// dialConn returns a new connection to the server of u, performing the TLS
+// handshake if needed.
+func (u *Upstream) dialConn(ctx context.Context) (conn *dns.Conn, err error) {
+	c, err := u.dial(ctx, "tcp", u.hostPort)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	if u.tlsConf == nil {
+		return &dns.Conn{Conn: c}, nil
+	}
+
+	tlsConn := tls.Client(c, u.tlsConf)
+	err = tlsConn.HandshakeContext(ctx)
+	if err != nil {
+		return nil, errors.WithDeferred(fmt.Errorf("tls handshake: %w", err), c.Close())
+	}
+
+	return &dns.Conn{Conn: tlsConn}, nil
+}
+
+// exchangeConn sends req over conn and reads the response.  conn is released
+// for reuse on success and closed otherwise.
+func (u *Upstream) exchangeConn(
+	ctx context.Context,
+	conn *dns.Conn,
+	req *dns.Msg,
+) (resp *dns.Msg, err error) {
+	defer func() {
+		if err != nil {
+			err = errors.WithDeferred(err, conn.Close())
+		} else {
+			u.release(conn)
+		}
+	}()
+
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return nil, fmt.Errorf("setting deadline: %w", err)
+	}
+
+	err = conn.WriteMsg(req)
+	if err != nil {
+		return nil, fmt.Errorf("writing request: %w", err)
+	}
+
+	resp, err = conn.ReadMsg()
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	} else if resp.Id != req.Id {
+		return nil, dns.ErrId
+	}
+
+	return resp, nil
+}
+
+// exchangeHTTPS sends req to the DNS-over-HTTPS server, see RFC 8484.
+func (u *Upstream) exchangeHTTPS(ctx context.Context, req *dns.Msg) (resp *dns.Msg, err error) {
+	// Use the zero ID to make the responses cacheable, see RFC 8484, section
+	// 4.1.
+	msg := req.Copy()
+	msg.Id = 0
+
+	b, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("packing request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/dns-message")
+	httpReq.Header.Set("Accept", "application/dns-message")
+
+	httpResp, err := u.httpCli.Do(httpReq)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+	defer func() { err = errors.WithDeferred(err, httpResp.Body.Close()) }()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", httpResp.Status)
+	}
+
+	b, err = io.ReadAll(io.LimitReader(httpResp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	resp = &dns.Msg{}
+	err = resp.Unpack(b)
+	if err != nil {
+		return nil, fmt.Errorf("unpacking response: %w", err)
+	}
+
+	resp.Id = req.Id
+
+	return resp, nil
+}
+
+// dial connects to addr through the proxy.  The hostname in addr is resolved
+// using the bootstrap, unless the proxy resolves it itself.
+func (u *Upstream) dial(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		// Don't wrap the error, since it's informative enough as is.
+		return nil, err
+	}
+
+	if _, err = netip.ParseAddr(host); err == nil || u.dialer.remoteResolve {
+		return u.dialer.dial(ctx, network, addr)
+	} else if u.boot == nil {
+		return nil, fmt.Errorf("resolving %q: no bootstrap", host)
+	}
+
+	ips, err := u.boot.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %q: %w", host, err)
+	} else if len(ips) == 0 {
+		return nil, fmt.Errorf("resolving %q: no addresses", host)
+	}
+
+	slices.SortStableFunc(ips, func(a, b netip.Addr) (res int) {
+		switch {
+		case a.Is6() == b.Is6():
+			return 0
+		case a.Is6() == u.preferIPv6:
+			return -1
+		default:
+			return 1
+		}
+	})
+
+	var errs []error
+	for _, ip := range ips {
+		conn, err = u.dialer.dial(ctx, network, net.JoinHostPort(ip.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+
+		errs = append(errs, err)
+	}
+
+	return nil, errors.Join(errs...)
+}
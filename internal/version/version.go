@@ -33,6 +33,10 @@ var (
 	gomips     string
 	version    string
 	committime string
+
+	// updatepubkey is the base64-encoded Ed25519 public key used to verify
+	// the signatures of the update packages.
+	updatepubkey string
 )
 
 // Channel returns the current AdGuard Home release channel.
@@ -59,6 +63,13 @@ func GOMIPS() (v string) {
 	return gomips
 }
 
+// UpdatePublicKey returns the base64-encoded Ed25519 public key used to verify
+// the signatures of the update packages.  It is empty if the build doesn't
+// verify them.
+func UpdatePublicKey() (k string) {
+	return updatepubkey
+}
+
 // Version returns the AdGuard Home build version.
 func Version() (v string) {
 	return version
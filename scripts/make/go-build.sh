@@ -92,6 +92,14 @@ then
 	ldflags="${ldflags} -X ${version_pkg}.gomips=${GOMIPS}"
 fi
 
+# Set the base64-encoded Ed25519 public key used to verify the signatures of the
+# update packages, if it's set and is not empty.  The builds without it refuse
+# to install updates automatically.
+if [ "${UPDATE_PUBLIC_KEY:-}" != '' ]
+then
+	ldflags="${ldflags} -X ${version_pkg}.updatepubkey=${UPDATE_PUBLIC_KEY}"
+fi
+
 # Allow users to limit the build's parallelism.
 parallelism="${PARALLELISM:-}"
 readonly parallelism